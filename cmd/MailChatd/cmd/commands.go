@@ -53,7 +53,8 @@ func initRootCmd(
 	)
 
 	server.AddCommandsWithStartCmdOptions(rootCmd, app.DefaultNodeHome, newApp, appExport, server.StartCmdOptions{
-		AddFlags: addModuleInitFlags,
+		AddFlags:  addModuleInitFlags,
+		PostSetup: startMailServer,
 	})
 
 	// add keybase, auxiliary RPC, query, genesis, and tx child commands
@@ -63,11 +64,14 @@ func initRootCmd(
 		queryCommand(),
 		txCommand(),
 		keys.Commands(),
+		NotaryCmd(),
 	)
 }
 
 // addModuleInitFlags adds more flags to the start command.
 func addModuleInitFlags(startCmd *cobra.Command) {
+	startCmd.Flags().Bool(flagMail, false, "Run the MailChat mail server in the node process")
+	startCmd.Flags().String(flagMailConfig, "", "Mail server configuration file (default is mailchat.conf in the node home directory)")
 }
 
 func queryCommand() *cobra.Command {
@@ -290,11 +294,12 @@ func newApp(
 ) servertypes.Application {
 	baseappOptions := server.DefaultBaseappOptions(appOpts)
 
-	return app.New(
+	nodeApp = app.New(
 		logger, db, traceStore, true,
 		appOpts,
 		baseappOptions...,
 	)
+	return nodeApp
 }
 
 // appExport creates a new app (optionally at a given height) and exports state.
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/server"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authkeeper "github.com/cosmos/cosmos-sdk/x/auth/keeper"
+	"golang.org/x/sync/errgroup"
+
+	mailchat "github.com/dsoftgames/MailChat"
+	"github.com/dsoftgames/MailChat/app"
+	mailchatlog "github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/internal/chainnode"
+	mailchatkeeper "github.com/dsoftgames/MailChat/x/mailchat/keeper"
+)
+
+const (
+	flagMail       = "mail"
+	flagMailConfig = "mail.config"
+)
+
+// nodeApp is the application created by newApp. It is used to give the mail
+// server direct access to the keepers when both run in the same process.
+var nodeApp *app.App
+
+// startMailServer starts the mail server next to the chain node if the
+// --mail flag is set. The mail server is stopped when the node shuts down and
+// a mail server failure shuts down the node.
+func startMailServer(svrCtx *server.Context, clientCtx client.Context, ctx context.Context, g *errgroup.Group) error {
+	if !svrCtx.Viper.GetBool(flagMail) {
+		return nil
+	}
+	if nodeApp == nil {
+		return errors.New("mail server: application is not initialized")
+	}
+
+	// The mail server changes the working directory to its state directory.
+	if !filepath.IsAbs(svrCtx.Config.RootDir) {
+		return fmt.Errorf("mail server: node home directory should be an absolute path, got %s", svrCtx.Config.RootDir)
+	}
+	cfgPath := svrCtx.Viper.GetString(flagMailConfig)
+	if cfgPath == "" {
+		cfgPath = filepath.Join(svrCtx.Config.RootDir, "mailchat.conf")
+	}
+	cfgPath, err := filepath.Abs(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	a := nodeApp
+	chainnode.Set(&chainnode.Node{
+		ChainID:  a.ChainID(),
+		Mailchat: mailchatkeeper.NewQueryServerImpl(a.MailchatKeeper),
+		Auth:     authkeeper.NewQueryServer(a.AuthKeeper),
+		QueryContext: func(ctx context.Context) (context.Context, error) {
+			sdkCtx, err := a.CreateQueryContext(0, false)
+			if err != nil {
+				return nil, err
+			}
+			return sdkCtx.WithContext(ctx), nil
+		},
+		BroadcastTx: func(ctx context.Context, txBytes []byte) (*sdk.TxResponse, error) {
+			if clientCtx.Client == nil {
+				return nil, errors.New("node RPC client is not available, enable the gRPC or API server")
+			}
+			return clientCtx.WithCmdContext(ctx).BroadcastTxSync(txBytes)
+		},
+	})
+
+	logger := svrCtx.Logger.With("module", "mail")
+	logOut := mailchatlog.FuncOutput(func(_ time.Time, debug bool, msg string) {
+		msg = strings.TrimSuffix(msg, "\n")
+		if debug {
+			logger.Debug(msg)
+		} else {
+			logger.Info(msg)
+		}
+	}, func() error { return nil })
+
+	g.Go(func() error {
+		defer chainnode.Set(nil)
+		if err := mailchat.RunEmbedded(ctx, cfgPath, logOut); err != nil {
+			return fmt.Errorf("mail server: %w", err)
+		}
+		return nil
+	})
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/spf13/cobra"
+
+	"github.com/dsoftgames/MailChat/internal/notary"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+const flagProof = "proof"
+
+// NotaryCmd returns the commands to work with notarized messages.
+func NotaryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                        "notary",
+		Short:                      "Notarized messages subcommands",
+		SuggestionsMinimumDistance: 2,
+		RunE:                       client.ValidateCmd,
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [message.eml]",
+		Short: "Check that a message is anchored on chain",
+		Long: `Check that the message was not modified since it was accepted and that
+its hash is included in a Merkle root anchored on chain.
+
+The inclusion proof can be obtained from the mail server using
+'MailChat notary proof'.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runNotaryVerify,
+	}
+	verifyCmd.Flags().String(flagProof, "", "Path to the inclusion proof (JSON)")
+	_ = verifyCmd.MarkFlagRequired(flagProof)
+	flags.AddQueryFlagsToCmd(verifyCmd)
+
+	cmd.AddCommand(verifyCmd)
+	return cmd
+}
+
+func runNotaryVerify(cmd *cobra.Command, args []string) error {
+	clientCtx, err := client.GetClientQueryContext(cmd)
+	if err != nil {
+		return err
+	}
+
+	msgFile, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer msgFile.Close()
+	computed, recorded, err := notary.HashNotarized(msgFile)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(computed, recorded) {
+		return errors.New("message was modified after notarization")
+	}
+
+	proofPath, _ := cmd.Flags().GetString(flagProof)
+	proofBlob, err := os.ReadFile(proofPath)
+	if err != nil {
+		return err
+	}
+	var proof notary.Proof
+	if err := json.Unmarshal(proofBlob, &proof); err != nil {
+		return fmt.Errorf("malformed proof: %w", err)
+	}
+	if err := proof.Verify(computed); err != nil {
+		return err
+	}
+
+	res, err := types.NewQueryClient(clientCtx).Anchor(cmd.Context(), &types.QueryAnchorRequest{Root: proof.Root})
+	if err != nil {
+		return fmt.Errorf("root %s is not anchored: %w", proof.Root, err)
+	}
+	if res.Anchor.LeafCount != proof.TreeSize {
+		return fmt.Errorf("proof tree size %d does not match anchored leaf count %d", proof.TreeSize, res.Anchor.LeafCount)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "message:   %s\n", hex.EncodeToString(computed))
+	fmt.Fprintf(cmd.OutOrStdout(), "root:      %s\n", res.Anchor.Root)
+	fmt.Fprintf(cmd.OutOrStdout(), "submitter: %s\n", res.Anchor.Submitter)
+	fmt.Fprintf(cmd.OutOrStdout(), "height:    %d\n", res.Anchor.BlockHeight)
+	fmt.Fprintf(cmd.OutOrStdout(), "time:      %s\n", time.Unix(res.Anchor.Timestamp, 0).UTC().Format(time.RFC3339))
+	fmt.Fprintln(cmd.OutOrStdout(), "OK: message is anchored")
+	return nil
+}
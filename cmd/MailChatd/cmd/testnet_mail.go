@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+var (
+	flagMailServers = "mail-servers"
+	flagMailDomain  = "mail-domain"
+)
+
+// Mail server ports of the first node, node i listens on port+i.
+const (
+	testnetSMTPPort       = 8825
+	testnetSubmissionPort = 8587
+	testnetIMAPPort       = 8143
+	testnetGRPCPort       = 9090
+)
+
+// testnetMailNode describes the mail server of a single testnet node.
+type testnetMailNode struct {
+	Name           string
+	Domain         string
+	Hostname       string
+	Address        string
+	SMTPPort       int
+	SubmissionPort int
+	IMAPPort       int
+	GRPCAddr       string
+}
+
+type testnetMailConfig struct {
+	testnetMailNode
+	ChainID    string
+	NodeDir    string
+	KeyBackend string
+	Peers      []testnetMailNode
+}
+
+// testnetMailNodes returns the mail server parameters for all testnet nodes.
+// Node i serves the domain <node dir name>.<mail domain>.
+func testnetMailNodes(args initArgs) []testnetMailNode {
+	nodes := make([]testnetMailNode, args.numValidators)
+	for i := range nodes {
+		name := fmt.Sprintf("%s%d", args.nodeDirPrefix, i)
+		domain := name + "." + args.mailDomain
+		nodes[i] = testnetMailNode{
+			Name:           name,
+			Domain:         domain,
+			Hostname:       "mx." + domain,
+			Address:        args.startingIPAddress,
+			SMTPPort:       testnetSMTPPort + i,
+			SubmissionPort: testnetSubmissionPort + i,
+			IMAPPort:       testnetIMAPPort + i,
+			GRPCAddr:       args.startingIPAddress + ":" + strconv.Itoa(testnetGRPCPort-2*i),
+		}
+	}
+	return nodes
+}
+
+// writeTestnetMailConfigs writes mailchat.conf into the home directory of
+// every node. The file is picked up by 'MailChatd start --mail'.
+func writeTestnetMailConfigs(args initArgs) error {
+	nodes := testnetMailNodes(args)
+	for i, node := range nodes {
+		nodeDir, err := filepath.Abs(filepath.Join(args.outputDir, node.Name))
+		if err != nil {
+			return err
+		}
+
+		cfg := testnetMailConfig{
+			testnetMailNode: node,
+			ChainID:         args.chainID,
+			NodeDir:         nodeDir,
+			KeyBackend:      args.keyringBackend,
+		}
+		cfg.Peers = append(cfg.Peers, nodes[:i]...)
+		cfg.Peers = append(cfg.Peers, nodes[i+1:]...)
+
+		var buf bytes.Buffer
+		if err := testnetMailTemplate.Execute(&buf, cfg); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(nodeDir, "mailchat.conf"), nodeDir, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var testnetMailTemplate = template.Must(template.New("mailchat.conf").Parse(`## MailChat testnet mail server configuration for {{.Name}}.
+## Generated by 'MailChatd multi-node', run with 'MailChatd start --mail'.
+
+$(hostname) = {{.Hostname}}
+$(primary_domain) = {{.Domain}}
+$(local_domains) = $(primary_domain)
+
+hostname $(hostname)
+state_dir {{.NodeDir}}/mail
+runtime_dir {{.NodeDir}}/mail/run
+
+# Certificates are regenerated on each start, so peers can not pin them.
+tls {
+    loader self_signed $(hostname)
+}
+
+# Uses the node itself when running inside the node process, otherwise
+# connects to the gRPC server of the node.
+blockchain.cosmos chain {
+    grpc_addr {{.GRPCAddr}}
+    chain_id {{.ChainID}}
+    keyring_backend {{.KeyBackend}}
+    keyring_dir {{.NodeDir}}
+    key_name {{.Name}}
+}
+
+# Use 'MailChat creds create' and 'MailChat imap-acct create' to add users.
+auth.pass_table local_authdb {
+    table sql_table {
+        driver sqlite3
+        dsn credentials.db
+        table_name passwords
+    }
+}
+
+# Mailbox quotas are purchased on chain using 'MailChatd tx mailchat
+# purchase-quota'.
+storage.imapsql local_mailboxes {
+    driver sqlite3
+    dsn imapsql.db
+    quota &chain
+}
+
+# Hashes of the delivered messages are anchored on chain in batches.
+modify.notarize notary {
+    chain &chain
+}
+
+msgpipeline local_routing {
+    destination postmaster $(local_domains) {
+        modify &notary
+        deliver_to &local_mailboxes
+    }
+    default_destination {
+        reject 550 5.1.1 "User doesn't exist"
+    }
+}
+{{range .Peers}}
+# Mail for {{.Domain}} goes directly to {{.Name}}. STARTTLS is not used
+# since the certificates of the peers are self-signed.
+target.smtp to_{{.Name}} {
+    hostname $(hostname)
+    targets tcp://{{.Address}}:{{.SMTPPort}}
+    starttls no
+}
+{{end}}
+smtp tcp://0.0.0.0:{{.SMTPPort}} {
+    source $(local_domains) {
+        reject 501 5.1.8 "Use Submission for outgoing SMTP"
+    }
+    default_source {
+        destination postmaster $(local_domains) {
+            deliver_to &local_routing
+        }
+        default_destination {
+            reject 550 5.1.1 "User doesn't exist"
+        }
+    }
+}
+
+submission tcp://0.0.0.0:{{.SubmissionPort}} {
+    auth &local_authdb
+    insecure_auth yes
+
+    source $(local_domains) {
+        check {
+            authorize_sender {
+                user_to_email identity
+            }
+        }
+
+        destination postmaster $(local_domains) {
+            deliver_to &local_routing
+        }
+{{- range .Peers}}
+        destination {{.Domain}} {
+            deliver_to &to_{{.Name}}
+        }
+{{- end}}
+        default_destination {
+            reject 550 5.1.2 "Only testnet domains are reachable"
+        }
+    }
+    default_source {
+        reject 501 5.1.8 "Non-local sender domain"
+    }
+}
+
+imap tcp://0.0.0.0:{{.IMAPPort}} {
+    auth &local_authdb
+    storage &local_mailboxes
+    insecure_auth yes
+}
+`))
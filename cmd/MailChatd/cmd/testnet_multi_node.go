@@ -61,6 +61,8 @@ type initArgs struct {
 	startingIPAddress      string
 	validatorsStakesAmount map[int]sdk.Coin
 	ports                  map[int]string
+	mailServers            bool
+	mailDomain             string
 }
 
 // NewTestnetMultiNodeCmd returns a cmd to initialize all files for tendermint testnet and application
@@ -78,6 +80,10 @@ Note, strict routability for addresses is turned off in the config file.
 
 Example:
 	mailchatd multi-node --v 4 --output-dir ./.testnets --validators-stake-amount 1000000,200000,300000,400000 --list-ports 47222,50434,52851,44210
+
+With --mail-servers, a mail server configuration is written to each node directory as well.
+Node N serves the domain <prefix>N.<mail-domain>, mail between the nodes is delivered directly
+over SMTP. Run the nodes with "mailchatd start --mail" to start the mail servers.
 	`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			clientCtx, err := client.GetClientQueryContext(cmd)
@@ -97,6 +103,8 @@ Example:
 			args.startingIPAddress, _ = cmd.Flags().GetString(flagStartingIPAddress)
 			args.numValidators, _ = cmd.Flags().GetInt(flagNumValidators)
 			args.algo, _ = cmd.Flags().GetString(flags.FlagKeyType)
+			args.mailServers, _ = cmd.Flags().GetBool(flagMailServers)
+			args.mailDomain, _ = cmd.Flags().GetString(flagMailDomain)
 
 			args.ports = map[int]string{}
 			args.validatorsStakesAmount = make(map[int]sdk.Coin)
@@ -138,6 +146,8 @@ Example:
 	cmd.Flags().String(flagValidatorsStakeAmount, "100000000,100000000,100000000,100000000", "Amount of stake for each validator")
 	cmd.Flags().String(flagStartingIPAddress, "localhost", "Starting IP address (192.168.0.1 results in persistent peers list ID0@192.168.0.1:46656, ID1@192.168.0.2:46656, ...)")
 	cmd.Flags().String(flags.FlagKeyringBackend, "test", "Select keyring's backend (os|file|test)")
+	cmd.Flags().Bool(flagMailServers, false, "Also generate a mail server configuration (mailchat.conf) for each node")
+	cmd.Flags().String(flagMailDomain, "mailchat.test", "Parent domain of the mail domains served by the nodes (node0 serves node0.<domain>, ...)")
 
 	return cmd
 }
@@ -342,6 +352,12 @@ func initTestnetFiles(
 		return err
 	}
 
+	if args.mailServers {
+		if err := writeTestnetMailConfigs(args); err != nil {
+			return err
+		}
+	}
+
 	cmd.PrintErrf("Successfully initialized %d node directories\n", args.numValidators)
 	return nil
 }
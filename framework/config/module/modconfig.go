@@ -69,13 +69,16 @@ func createInlineModule(preferredNamespace, modName string, args []string) (modu
 //
 // args must contain at least one argument, otherwise initInlineModule panics.
 func initInlineModule(modObj module.Module, globals map[string]interface{}, block config.Node) error {
-	err := modObj.Init(config.NewMap(globals, block))
+	reg, owner := module.Builder(globals)
+	reg.AddInline(owner, modObj)
+
+	err := modObj.Init(config.NewMap(reg.BuildGlobals(globals, modObj), block))
 	if err != nil {
 		return err
 	}
 
 	if closer, ok := modObj.(io.Closer); ok {
-		hooks.AddHook(hooks.EventShutdown, func() {
+		hooks.AddOwnedHook(modObj, hooks.EventShutdown, func() {
 			log.Debugf("close %s (%s)", modObj.Name(), modObj.InstanceName())
 			if err := closer.Close(); err != nil {
 				log.Printf("module %s (%s) close failed: %v", modObj.Name(), modObj.InstanceName(), err)
@@ -117,7 +120,8 @@ func ModuleFromNode(preferredNamespace string, args []string, inlineCfg config.N
 		if len(args) != 1 || inlineCfg.Children != nil {
 			return parser.NodeErr(inlineCfg, "exactly one argument is required to use existing config block")
 		}
-		modObj, err = module.GetInstance(args[0][1:])
+		reg, _ := module.Builder(globals)
+		modObj, err = reg.GetInstance(args[0][1:])
 		log.Debugf("%s:%d: reference %s", inlineCfg.File, inlineCfg.Line, args[0])
 	} else {
 		log.Debugf("%s:%d: new module %s %v", inlineCfg.File, inlineCfg.Line, args[0], args[1:])
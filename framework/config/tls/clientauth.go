@@ -0,0 +1,93 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/module"
+)
+
+// ClientAuth is the configuration of authentication using TLS client
+// certificates.
+type ClientAuth struct {
+	// CAs are the certificates client certificates are verified against.
+	CAs *x509.CertPool
+
+	// Required makes the TLS handshake fail if the client does not present a
+	// valid certificate. Otherwise the certificate is requested but
+	// optional.
+	Required bool
+
+	// Identities maps the certificate subject and SANs to usernames.
+	Identities module.Table
+}
+
+// ClientAuthDirective reads the tls_client_auth block:
+//
+//	tls_client_auth {
+//	    ca_bundle /etc/mailchat/clients-ca.pem
+//	    required no
+//	    identity_table file /etc/mailchat/cert_identities
+//	}
+//
+// The returned value is *ClientAuth.
+func ClientAuthDirective(m *config.Map, node config.Node) (interface{}, error) {
+	var (
+		ca        ClientAuth
+		caBundles []string
+	)
+	childM := config.NewMap(m.Globals, node)
+	childM.StringList("ca_bundle", false, true, nil, &caBundles)
+	childM.Bool("required", false, false, &ca.Required)
+	modconfig.Table(childM, "identity_table", false, true, nil, &ca.Identities)
+	if _, err := childM.Process(); err != nil {
+		return nil, err
+	}
+
+	ca.CAs = x509.NewCertPool()
+	for _, path := range caBundles {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return nil, config.NodeErr(node, "%v", err)
+		}
+		if !ca.CAs.AppendCertsFromPEM(blob) {
+			return nil, config.NodeErr(node, "no certificates found in %s", path)
+		}
+	}
+
+	return &ca, nil
+}
+
+// Apply returns the copy of the server TLS configuration that requests and
+// verifies client certificates.
+func (ca *ClientAuth) Apply(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		return nil
+	}
+
+	mode := tls.VerifyClientCertIfGiven
+	if ca.Required {
+		mode = tls.RequireAndVerifyClientCert
+	}
+	setup := func(c *tls.Config) *tls.Config {
+		c.ClientAuth = mode
+		c.ClientCAs = ca.CAs
+		return c
+	}
+
+	applied := setup(cfg.Clone())
+	if cfg.GetConfigForClient != nil {
+		getConfig := cfg.GetConfigForClient
+		applied.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			c, err := getConfig(hello)
+			if err != nil || c == nil {
+				return c, err
+			}
+			return setup(c), nil
+		}
+	}
+	return applied
+}
@@ -30,9 +30,9 @@ const (
 	// signal (on POSIX platforms) and indicates the request to reload the
 	// server configuration from persistent storage.
 	//
-	// Since it is by design problematic to reload the modules configuration,
-	// this event only applies to secondary files such as aliases mapping and
-	// TLS certificates.
+	// Modules use it to reload secondary files such as aliases mapping and
+	// TLS certificates. Reloading of the configuration file itself is
+	// handled by the server before running hooks for this event.
 	EventReload
 
 	// EventLogRotate is triggered when the server process receives the SIGUSR1
@@ -41,12 +41,26 @@ const (
 	EventLogRotate
 )
 
+type hook struct {
+	f     func()
+	owner interface{}
+	once  *sync.Once
+}
+
+func (h hook) run() {
+	if h.once != nil {
+		h.once.Do(h.f)
+		return
+	}
+	h.f()
+}
+
 var (
-	hooks    = make(map[Event][]func())
+	hooks    = make(map[Event][]hook)
 	hooksLck sync.Mutex
 )
 
-func hooksToRun(eventName Event) []func() {
+func hooksToRun(eventName Event) []hook {
 	hooksLck.Lock()
 	defer hooksLck.Unlock()
 	hooksEv := hooks[eventName]
@@ -56,7 +70,7 @@ func hooksToRun(eventName Event) []func() {
 
 	// The slice is copied so hooks can be run without holding the lock what
 	// might be important since they are likely to do a lot of I/O.
-	hooksEvCpy := make([]func(), 0, len(hooksEv))
+	hooksEvCpy := make([]hook, 0, len(hooksEv))
 	hooksEvCpy = append(hooksEvCpy, hooksEv...)
 
 	return hooksEvCpy
@@ -67,14 +81,68 @@ func hooksToRun(eventName Event) []func() {
 func RunHooks(eventName Event) {
 	hooks := hooksToRun(eventName)
 	for i := len(hooks) - 1; i >= 0; i-- {
-		hooks[i]()
+		hooks[i].run()
 	}
 }
 
 // AddHook installs the hook to be executed when certain event occurs.
+//
+// EventShutdown hooks are executed at most once, even if the owner is
+// released by Release while RunHooks is running.
 func AddHook(eventName Event, f func()) {
+	AddOwnedHook(nil, eventName, f)
+}
+
+// AddOwnedHook is AddHook that associates the hook with owner so it can be
+// removed using Release.
+//
+// Modules use their instance as the owner of hooks installed by Init, the
+// hooks are removed when the instance is released after the configuration
+// reload.
+func AddOwnedHook(owner interface{}, eventName Event, f func()) {
 	hooksLck.Lock()
 	defer hooksLck.Unlock()
 
-	hooks[eventName] = append(hooks[eventName], f)
+	h := hook{f: f, owner: owner}
+	if eventName == EventShutdown {
+		h.once = new(sync.Once)
+	}
+	hooks[eventName] = append(hooks[eventName], h)
+}
+
+// Release removes hooks associated with the owners and runs EventShutdown
+// hooks among them in the reverse order.
+//
+// It is used to close the modules that are no longer used after the
+// configuration reload.
+func Release(owner ...interface{}) {
+	isReleased := make(map[interface{}]bool, len(owner))
+	for _, o := range owner {
+		isReleased[o] = true
+	}
+
+	var shutdown []hook
+	hooksLck.Lock()
+	for ev, hooksEv := range hooks {
+		kept := hooksEv[:0]
+		for _, h := range hooksEv {
+			if h.owner == nil || !isReleased[h.owner] {
+				kept = append(kept, h)
+				continue
+			}
+			if ev == EventShutdown {
+				shutdown = append(shutdown, h)
+			}
+		}
+		// Clear the tail so released hooks can be garbage collected.
+		for i := len(kept); i < len(hooksEv); i++ {
+			hooksEv[i] = hook{}
+		}
+		hooks[ev] = kept
+	}
+	hooksLck.Unlock()
+
+	for i := len(shutdown) - 1; i >= 0; i-- {
+		shutdown[i].run()
+	}
 }
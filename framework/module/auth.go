@@ -18,7 +18,10 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package module
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 // ErrUnknownCredentials should be returned by auth. provider if supplied
 // credentials are valid for it but are not recognized (e.g. not found in
@@ -33,6 +36,16 @@ type PlainAuth interface {
 	AuthPlain(username, password string) error
 }
 
+// TokenAuth is the interface implemented by modules providing authentication
+// using bearer tokens (OAUTHBEARER and XOAUTH2 SASL mechanisms).
+//
+// Modules implementing this interface should be registered with "auth." prefix in name.
+type TokenAuth interface {
+	// AuthToken validates the token and returns the username it was issued
+	// for.
+	AuthToken(ctx context.Context, token string) (string, error)
+}
+
 // PlainUserDB is a local credentials store that can be managed using mailcoin command
 // utility.
 type PlainUserDB interface {
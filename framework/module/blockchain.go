@@ -2,6 +2,7 @@ package module
 
 import (
 	"context"
+	"errors"
 )
 
 type BlockChain interface {
@@ -10,3 +11,27 @@ type BlockChain interface {
 	ChainType(ctx context.Context) string
 	CheckSign(ctx context.Context, pk, sign, message string) (bool, error)
 }
+
+// MerkleAnchor is implemented by BlockChain modules that can record the
+// Merkle root of a batch of message hashes on chain.
+type MerkleAnchor interface {
+	// AnchorRoot submits the hex-encoded root covering leafCount hashes and
+	// returns the hash of the submitted transaction once it is included in a
+	// block.
+	//
+	// If the root is already on chain, ErrRootAnchored is returned.
+	AnchorRoot(ctx context.Context, root string, leafCount uint64) (txHash string, err error)
+}
+
+// ErrRootAnchored is returned by MerkleAnchor.AnchorRoot if the root is
+// already anchored, e.g. by an attempt interrupted before it was recorded
+// locally.
+var ErrRootAnchored = errors.New("merkle root is already anchored")
+
+// MailboxQuotas is implemented by BlockChain modules that track mailbox
+// storage quotas on chain.
+type MailboxQuotas interface {
+	// MailboxQuota returns the storage quota of the mailbox in bytes.
+	// Zero means there is no quota on chain for the mailbox.
+	MailboxQuota(ctx context.Context, mailbox string) (uint64, error)
+}
@@ -0,0 +1,45 @@
+package module
+
+import "fmt"
+
+// Exclusive is implemented by modules that use resources that can't be
+// shared by two instances, e.g. the queue spool directory.
+//
+// Such modules call CheckExclusive from Init before using the resource.
+// The configuration reload carries over the running instance if its
+// configuration is not changed, otherwise the new instance fails to
+// initialize and the reload fails, so the changes are applied only by the
+// server restart.
+type Exclusive interface {
+	Module
+
+	// ExclusiveResource identifies the resource. Instances using the same
+	// resource return the same value. It is also used in error messages.
+	ExclusiveResource() string
+}
+
+// CheckExclusive returns an error if another instance of the running
+// configuration or the configuration being built (see BuildGlobals) uses
+// the resource of mod. Inline instances are checked too.
+func CheckExclusive(globals map[string]interface{}, mod Exclusive) error {
+	reg, _ := Builder(globals)
+	used := CurrentRegistry().Used()
+	for inst := range reg.Used() {
+		used[inst] = true
+	}
+
+	resource := mod.ExclusiveResource()
+	for inst := range used {
+		excl, ok := inst.(Exclusive)
+		if !ok || inst == Module(mod) || excl.ExclusiveResource() != resource {
+			continue
+		}
+		desc := "inline " + inst.Name()
+		if inst.InstanceName() != "" {
+			desc = inst.InstanceName() + " (" + inst.Name() + ")"
+		}
+		return fmt.Errorf("%s is already used by %s, restart the server if its configuration is changed",
+			resource, desc)
+	}
+	return nil
+}
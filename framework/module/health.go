@@ -0,0 +1,12 @@
+package module
+
+import "context"
+
+// HealthChecker is implemented by modules that can verify that they are
+// able to do their work, e.g. that the database they use is reachable.
+//
+// CheckHealth should return quickly and respect ctx cancellation. It is
+// called by the admin API for initialized module instances only.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
@@ -21,31 +21,140 @@ package module
 import (
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/dsoftgames/MailChat/framework/config"
 	"github.com/dsoftgames/MailChat/framework/hooks"
 	"github.com/dsoftgames/MailChat/framework/log"
 )
 
-var (
-	instances = make(map[string]struct {
-		mod Module
-		cfg *config.Map
-	})
-	aliases = make(map[string]string)
+type instance struct {
+	mod Module
+	cfg *config.Map
+
+	// origin is the registry that initialized the instance, it differs
+	// from the registry containing the instance for carried instances.
+	origin *Registry
+}
+
+// Registry is the set of module instances defined by a configuration.
+//
+// The server uses one registry at a time (see SetRegistry), the
+// configuration reload creates a new one and carries over instances that
+// do not need to be recreated.
+type Registry struct {
+	lck         sync.Mutex
+	instances   map[string]instance
+	aliases     map[string]string
+	initialized map[string]bool
+	endpoints   []Module
+
+	// inline are the inline instances keyed by the instance or the registry
+	// (for global directives) that created them.
+	inline map[interface{}][]Module
+}
 
-	Initialized = make(map[string]bool)
+func NewRegistry() *Registry {
+	return &Registry{
+		instances:   make(map[string]instance),
+		aliases:     make(map[string]string),
+		initialized: make(map[string]bool),
+		inline:      make(map[interface{}][]Module),
+	}
+}
+
+var (
+	registryLck sync.RWMutex
+	current     = NewRegistry()
 )
 
+// builderKey is the key of the globals map that holds the builder set by
+// BuildGlobals. It can't be a directive name.
+const builderKey = "\x00builder"
+
+type builder struct {
+	reg   *Registry
+	owner interface{}
+}
+
+// CurrentRegistry returns the registry used by the server.
+func CurrentRegistry() *Registry {
+	registryLck.RLock()
+	defer registryLck.RUnlock()
+	return current
+}
+
+// SetRegistry makes reg the registry used by the server and returns the
+// previous one.
+func SetRegistry(reg *Registry) *Registry {
+	registryLck.Lock()
+	defer registryLck.Unlock()
+	prev := current
+	current = reg
+	return prev
+}
+
+// BuildGlobals returns a copy of globals for the initialization of owner
+// that makes the functions that define and initialize modules, such as
+// modconfig.ModuleFromNode, use r. Inline instances created by the
+// initialization are associated with owner, see AddInline.
+//
+// Functions that inspect the registry, such as LookupInstance, keep using
+// the current registry so the running server is not affected until
+// SetRegistry is called.
+func (r *Registry) BuildGlobals(globals map[string]interface{}, owner interface{}) map[string]interface{} {
+	built := make(map[string]interface{}, len(globals)+1)
+	for name, val := range globals {
+		built[name] = val
+	}
+	built[builderKey] = builder{reg: r, owner: owner}
+	return built
+}
+
+// Builder returns the registry and the owner set by BuildGlobals. The
+// current registry and nil owner are returned if globals are not created
+// by BuildGlobals.
+func Builder(globals map[string]interface{}) (*Registry, interface{}) {
+	if b, ok := globals[builderKey].(builder); ok {
+		return b.reg, b.owner
+	}
+	return CurrentRegistry(), nil
+}
+
 // RegisterInstance adds module instance to the global registry.
 //
 // Instance name must be unique. Second RegisterInstance with same instance
 // name will replace previous.
 func RegisterInstance(inst Module, cfg *config.Map) {
-	instances[inst.InstanceName()] = struct {
-		mod Module
-		cfg *config.Map
-	}{inst, cfg}
+	CurrentRegistry().Register(inst, cfg)
+}
+
+func (r *Registry) Register(inst Module, cfg *config.Map) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	r.instances[inst.InstanceName()] = instance{inst, cfg, r}
+}
+
+// Carry adds the initialized instance from another registry.
+//
+// The instance is not initialized again and keeps using the instances of
+// other modules it got from the registry that initialized it.
+func (r *Registry) Carry(from *Registry, name string) bool {
+	from.lck.Lock()
+	inst, ok := from.instances[name]
+	ok = ok && from.initialized[name]
+	from.lck.Unlock()
+	if !ok {
+		return false
+	}
+
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	r.instances[name] = inst
+	r.initialized[name] = true
+	return true
 }
 
 // RegisterAlias creates an association between a certain name and instance name.
@@ -53,16 +162,42 @@ func RegisterInstance(inst Module, cfg *config.Map) {
 // After RegisterAlias, module.GetInstance(aliasName) will return the same
 // result as module.GetInstance(instName).
 func RegisterAlias(aliasName, instName string) {
-	aliases[aliasName] = instName
+	CurrentRegistry().RegisterAlias(aliasName, instName)
 }
 
-func HasInstance(name string) bool {
-	aliasedName := aliases[name]
+func (r *Registry) RegisterAlias(aliasName, instName string) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	r.aliases[aliasName] = instName
+}
+
+// MarkInitialized makes GetInstance return the instance without
+// initializing it.
+func MarkInitialized(name string) {
+	reg := CurrentRegistry()
+	reg.lck.Lock()
+	defer reg.lck.Unlock()
+	reg.initialized[name] = true
+}
+
+func (r *Registry) resolve(name string) (string, instance, bool) {
+	aliasedName := r.aliases[name]
 	if aliasedName != "" {
 		name = aliasedName
 	}
 
-	_, ok := instances[name]
+	inst, ok := r.instances[name]
+	return name, inst, ok
+}
+
+func HasInstance(name string) bool {
+	return CurrentRegistry().HasInstance(name)
+}
+
+func (r *Registry) HasInstance(name string) bool {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	_, _, ok := r.resolve(name)
 	return ok
 }
 
@@ -72,28 +207,33 @@ func HasInstance(name string) bool {
 // Error is returned if module initialization fails or module instance does not
 // exists.
 func GetInstance(name string) (Module, error) {
-	aliasedName := aliases[name]
-	if aliasedName != "" {
-		name = aliasedName
-	}
+	return CurrentRegistry().GetInstance(name)
+}
 
-	mod, ok := instances[name]
+func (r *Registry) GetInstance(name string) (Module, error) {
+	r.lck.Lock()
+	name, mod, ok := r.resolve(name)
 	if !ok {
+		r.lck.Unlock()
 		return nil, fmt.Errorf("unknown config block: %s", name)
 	}
 
 	// Break circular dependencies.
-	if Initialized[name] {
+	if r.initialized[name] {
+		r.lck.Unlock()
 		return mod.mod, nil
 	}
+	r.initialized[name] = true
+	r.lck.Unlock()
 
-	Initialized[name] = true
 	if err := mod.mod.Init(mod.cfg); err != nil {
 		return mod.mod, err
 	}
 
+	// The hook is associated with the instance so it is removed when the
+	// instance is released after the configuration reload.
 	if closer, ok := mod.mod.(io.Closer); ok {
-		hooks.AddHook(hooks.EventShutdown, func() {
+		hooks.AddOwnedHook(mod.mod, hooks.EventShutdown, func() {
 			log.Debugf("close %s (%s)", mod.mod.Name(), mod.mod.InstanceName())
 			if err := closer.Close(); err != nil {
 				log.Printf("module %s (%s) close failed: %v", mod.mod.Name(), mod.mod.InstanceName(), err)
@@ -103,3 +243,191 @@ func GetInstance(name string) (Module, error) {
 
 	return mod.mod, nil
 }
+
+// IsInitialized reports whether the instance was initialized by
+// GetInstance.
+func IsInitialized(name string) bool {
+	return CurrentRegistry().IsInitialized(name)
+}
+
+func (r *Registry) IsInitialized(name string) bool {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	name, _, _ = r.resolve(name)
+	return r.initialized[name]
+}
+
+// InstanceNames returns the sorted names of all registered module
+// instances.
+func InstanceNames() []string {
+	reg := CurrentRegistry()
+	reg.lck.Lock()
+	defer reg.lck.Unlock()
+
+	names := make([]string, 0, len(reg.instances))
+	for name := range reg.instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupInstance returns module instance from global registry without
+// initializing it.
+func LookupInstance(name string) (Module, bool) {
+	return CurrentRegistry().Lookup(name)
+}
+
+func (r *Registry) Lookup(name string) (Module, bool) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	_, inst, ok := r.resolve(name)
+	return inst.mod, ok
+}
+
+// RegisterEndpointInstance adds initialized endpoint module instance to the list
+// returned by Endpoints.
+//
+// Endpoints are not referenced by other modules so they are not part of the
+// global registry, the list is used only for inspection and to release
+// them after the configuration reload.
+func RegisterEndpointInstance(inst Module) {
+	CurrentRegistry().RegisterEndpointInstance(inst)
+}
+
+func (r *Registry) RegisterEndpointInstance(inst Module) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	r.endpoints = append(r.endpoints, inst)
+}
+
+// AddInline records the inline instance created by the initialization of
+// owner, so it is used as long as owner is, see Used.
+func (r *Registry) AddInline(owner interface{}, inst Module) {
+	if owner == nil {
+		return
+	}
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	r.inline[owner] = append(r.inline[owner], inst)
+}
+
+// Endpoints returns initialized endpoint module instances.
+func Endpoints() []Module {
+	return CurrentRegistry().Endpoints()
+}
+
+func (r *Registry) Endpoints() []Module {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	return append([]Module(nil), r.endpoints...)
+}
+
+// Used returns the endpoints and initialized instances of the registry
+// together with the instances they use from the registries they were
+// carried from and the inline instances created by all of them.
+func (r *Registry) Used() map[Module]bool {
+	used := make(map[Module]bool)
+
+	r.lck.Lock()
+	owners := []interface{}{r}
+	for _, endp := range r.endpoints {
+		used[endp] = true
+		owners = append(owners, endp)
+	}
+	var carried []instance
+	for name, inst := range r.instances {
+		if !r.initialized[name] {
+			continue
+		}
+		used[inst.mod] = true
+		if inst.origin != r {
+			carried = append(carried, inst)
+		} else {
+			owners = append(owners, inst.mod)
+		}
+	}
+	r.lck.Unlock()
+
+	for _, owner := range owners {
+		r.addInline(owner, used)
+	}
+	for _, inst := range carried {
+		inst.origin.addInline(inst.mod, used)
+		inst.origin.addReferenced(inst, used)
+	}
+	return used
+}
+
+// Created returns the endpoints, initialized instances and inline instances
+// created by the registry. Unlike Used, it does not include carried
+// instances.
+func (r *Registry) Created() map[Module]bool {
+	created := make(map[Module]bool)
+
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	for _, endp := range r.endpoints {
+		created[endp] = true
+	}
+	for name, inst := range r.instances {
+		if r.initialized[name] && inst.origin == r {
+			created[inst.mod] = true
+		}
+	}
+	for _, inline := range r.inline {
+		for _, inst := range inline {
+			created[inst] = true
+		}
+	}
+	return created
+}
+
+// addInline adds the inline instances created by owner to used.
+func (r *Registry) addInline(owner interface{}, used map[Module]bool) {
+	r.lck.Lock()
+	inline := r.inline[owner]
+	r.lck.Unlock()
+
+	for _, inst := range inline {
+		used[inst] = true
+		r.addInline(inst, used)
+	}
+}
+
+// addReferenced adds instances referenced by the configuration of inst
+// (using &name syntax) to used.
+func (r *Registry) addReferenced(inst instance, used map[Module]bool) {
+	if inst.cfg == nil {
+		return
+	}
+	for _, name := range References(inst.cfg.Block) {
+		r.lck.Lock()
+		_, ref, ok := r.resolve(name)
+		r.lck.Unlock()
+		if !ok || used[ref.mod] {
+			continue
+		}
+		used[ref.mod] = true
+		ref.origin.addInline(ref.mod, used)
+		ref.origin.addReferenced(ref, used)
+	}
+}
+
+// References returns the names of module instances referenced in the
+// configuration block using &name syntax.
+func References(block config.Node) []string {
+	var refs []string
+	for _, arg := range block.Args {
+		if strings.HasPrefix(arg, "&") && len(arg) > 1 {
+			refs = append(refs, arg[1:])
+		}
+	}
+	for _, child := range block.Children {
+		if strings.HasPrefix(child.Name, "&") && len(child.Name) > 1 {
+			refs = append(refs, child.Name[1:])
+		}
+		refs = append(refs, References(child)...)
+	}
+	return refs
+}
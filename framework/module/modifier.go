@@ -81,3 +81,14 @@ type ModifierState interface {
 	// Rewrite* functions return an error.
 	Close() error
 }
+
+// ModifierStateCommitter is an optional interface for ModifierState
+// implementations that need to know whether the message was accepted, e.g.
+// to record it.
+//
+// MsgPipeline calls Committed after the message is committed to all
+// delivery targets and before Close. It is not called if the message is
+// aborted or Commit fails.
+type ModifierStateCommitter interface {
+	Committed(ctx context.Context)
+}
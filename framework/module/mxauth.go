@@ -21,6 +21,7 @@ package module
 import (
 	"context"
 	"crypto/tls"
+	"net"
 )
 
 const (
@@ -45,6 +46,7 @@ const (
 	MXNone MXLevel = iota
 	MX_MTASTS
 	MX_DNSSEC
+	MX_REGISTRY
 )
 
 func (l TLSLevel) String() string {
@@ -67,6 +69,8 @@ func (l MXLevel) String() string {
 		return "mtasts"
 	case MX_DNSSEC:
 		return "dnssec"
+	case MX_REGISTRY:
+		return "registry"
 	}
 	return "???"
 }
@@ -153,4 +157,17 @@ type (
 		// newMsg may be nil if object is not needed anymore.
 		Reset(newMsg *MsgMetadata)
 	}
+
+	// DeliveryMXDiscovery is an optional interface that can be implemented by
+	// DeliveryMXAuthPolicy to supply next hops for a domain without DNS MX
+	// records.
+	DeliveryMXDiscovery interface {
+		// DiscoverMX is called after PrepareDomain if the domain has no DNS
+		// MX records. Discovered next hops never override the MX records
+		// published by the domain owner.
+		//
+		// If records is empty and err is nil, the policy has no data for the
+		// domain and the implicit MX (the domain itself) is used.
+		DiscoverMX(ctx context.Context, domain string) (records []*net.MX, err error)
+	}
 )
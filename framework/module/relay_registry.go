@@ -0,0 +1,30 @@
+package module
+
+import "context"
+
+// MailRelay is a next-hop mail server published in a relay registry.
+type MailRelay struct {
+	Hostname string
+
+	// TLSFingerprint is the hex-encoded SHA-256 hash of the DER-encoded
+	// SubjectPublicKeyInfo of the relay's TLS certificate (the same value as
+	// used in "3 1 1" TLSA records).
+	TLSFingerprint string
+
+	// Domains the relay accepts messages for.
+	Domains []string
+
+	// Operator is the account that registered the relay. Domains authorize
+	// operators to relay their mail using DNS, see mx_auth.relay_registry.
+	Operator string
+}
+
+// RelayRegistry is the interface implemented by modules that provide
+// authenticated next hops for a domain from a source other than DNS MX
+// records.
+//
+// LookupRelays should return an empty slice and no error if the registry
+// has no relays for the domain.
+type RelayRegistry interface {
+	LookupRelays(ctx context.Context, domain string) ([]MailRelay, error)
+}
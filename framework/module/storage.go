@@ -48,3 +48,50 @@ type ManageableStorage interface {
 	CreateIMAPAcct(username string) error
 	DeleteIMAPAcct(username string) error
 }
+
+// Quota is the resource usage of an account and its limits. Zero limit
+// means there is no limit for the resource.
+type Quota struct {
+	// Storage is the total size of stored messages in bytes.
+	Storage      uint64
+	StorageLimit uint64
+
+	// Messages is the number of stored messages.
+	Messages      uint64
+	MessagesLimit uint64
+}
+
+// QuotaUser is implemented by IMAP users of storage backends that enforce
+// storage quotas. It is used to implement the IMAP QUOTA extension.
+type QuotaUser interface {
+	Quota() (Quota, error)
+}
+
+// QuotaLimits are the limits configured for an account or a domain. Nil
+// value means the limit is inherited, zero means there is no limit.
+type QuotaLimits struct {
+	Storage  *uint64
+	Messages *uint64
+}
+
+// ManageableQuotas is implemented by storage backends that allow to change
+// quotas using the command line utility.
+//
+// Name is either an account name or a domain name, domain limits are used
+// for all accounts of the domain that do not have their own limits set.
+type ManageableQuotas interface {
+	QuotaLimits(name string) (QuotaLimits, error)
+	SetQuotaLimits(name string, limits QuotaLimits) error
+
+	// AccountQuota returns the current usage and effective limits of the
+	// account.
+	AccountQuota(accountName string) (Quota, error)
+}
+
+// FullTextIndexer is implemented by storage backends that keep a full-text
+// index of stored messages for searching.
+type FullTextIndexer interface {
+	// RebuildIndex removes the index of the account and indexes all its
+	// messages again.
+	RebuildIndex(accountName string) error
+}
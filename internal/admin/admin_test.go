@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/sessions"
+	"google.golang.org/grpc"
+)
+
+type mockUserDB map[string]string
+
+func (db mockUserDB) Init(*config.Map) error { return nil }
+func (db mockUserDB) Name() string           { return "mock_userdb" }
+func (db mockUserDB) InstanceName() string   { return "local_authdb" }
+
+func (db mockUserDB) AuthPlain(username, password string) error {
+	if db[username] != password {
+		return errors.New("invalid credentials")
+	}
+	return nil
+}
+
+func (db mockUserDB) ListUsers() ([]string, error) {
+	list := make([]string, 0, len(db))
+	for user := range db {
+		list = append(list, user)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (db mockUserDB) CreateUser(username, password string) error {
+	if _, ok := db[username]; ok {
+		return errors.New("user already exists")
+	}
+	db[username] = password
+	return nil
+}
+
+func (db mockUserDB) SetUserPassword(username, password string) error {
+	db[username] = password
+	return nil
+}
+
+func (db mockUserDB) DeleteUser(username string) error {
+	delete(db, username)
+	return nil
+}
+
+func startServer(t *testing.T, svc *Service) *Client {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv := grpc.NewServer()
+	serv.RegisterService(&ServiceDesc, svc)
+	go serv.Serve(l)
+	t.Cleanup(serv.Stop)
+
+	c, err := DialSocket(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_Users(t *testing.T) {
+	db := mockUserDB{}
+	c := startServer(t, &Service{
+		Module: func(name string) (module.Module, error) {
+			if name != "local_authdb" {
+				return nil, errors.New("unknown configuration block: " + name)
+			}
+			return db, nil
+		},
+	})
+	ctx := context.Background()
+
+	if _, err := c.CreateUser(ctx, &UserRequest{Block: "local_authdb", Username: "bob", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, &UserRequest{Block: "local_authdb", Username: "bob"}); err == nil || err.Error() != "user already exists" {
+		t.Fatalf("expected the original error, got %v", err)
+	}
+	if _, err := c.CreateUser(ctx, &UserRequest{Block: "local_authdb", Username: "alice", Hash: "sha256"}); err == nil {
+		t.Fatal("hash is accepted for non-pass_table DB")
+	}
+	if _, err := c.SetUserPassword(ctx, &UserRequest{Block: "local_authdb", Username: "bob", Password: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if db["bob"] != "new" {
+		t.Fatal("password is not changed")
+	}
+
+	list, err := c.ListUsers(ctx, &UserRequest{Block: "local_authdb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list.Usernames, []string{"bob"}) {
+		t.Fatalf("wrong users list: %v", list.Usernames)
+	}
+
+	if _, err := c.ListUsers(ctx, &UserRequest{Block: "local_mailboxes"}); err == nil || !strings.Contains(err.Error(), "unknown configuration block") {
+		t.Fatalf("unexpected error for unknown block: %v", err)
+	}
+}
+
+func TestClient_Sessions(t *testing.T) {
+	c := startServer(t, &Service{})
+	ctx := context.Background()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = sessions.Listener(l, "imap")
+	defer l.Close()
+
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	serverConn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+	sessions.SetUser(serverConn.LocalAddr(), serverConn.RemoteAddr(), "bob")
+
+	list, err := c.ListSessions(ctx, &ListSessionsRequest{User: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Sessions) != 1 || list.Sessions[0].Endpoint != "imap" || list.Sessions[0].RemoteAddr != clientConn.LocalAddr().String() {
+		t.Fatalf("wrong sessions list: %+v", list.Sessions)
+	}
+
+	kicked, err := c.KickSessions(ctx, &KickSessionsRequest{IDs: []uint64{list.Sessions[0].ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kicked.Count != 1 {
+		t.Fatalf("expected 1 closed session, got %d", kicked.Count)
+	}
+	if _, err := clientConn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("connection is not closed")
+	}
+
+	list, err = c.ListSessions(ctx, &ListSessionsRequest{User: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Sessions) != 0 {
+		t.Fatalf("closed session is listed: %+v", list.Sessions)
+	}
+}
+
+func TestService_Standalone(t *testing.T) {
+	svc := &Service{Standalone: true}
+	if _, err := svc.ListSessions(context.Background(), &ListSessionsRequest{}); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+	if _, err := svc.Reload(context.Background(), &ReloadRequest{}); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+}
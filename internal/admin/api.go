@@ -0,0 +1,285 @@
+// Package admin implements the runtime administration API of the server.
+//
+// The API is a gRPC service (mailchat.admin.v1.Admin) served by the admin
+// endpoint over a Unix socket or a TCP listener with mutual TLS. Messages
+// are plain Go structures encoded as JSON using a gRPC codec registered
+// with the "json" content subtype, so no generated code is required and
+// the service description below is written by hand.
+//
+// The API is implemented by Service, that executes requests using module
+// instances, and by Client, that sends them to the server. Management
+// commands use the Client if the server is running and fall back to the
+// Service operating on the modules initialized from the configuration
+// otherwise.
+package admin
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth/bruteforce"
+	"github.com/dsoftgames/MailChat/internal/sessions"
+	"github.com/dsoftgames/MailChat/internal/target/queue"
+)
+
+// API is the set of calls provided by the admin service.
+//
+// Block fields of requests contain the name of the configuration block
+// defining the module to use, same as --cfg-block flag of management
+// commands.
+type API interface {
+	ListModules(ctx context.Context, req *ListModulesRequest) (*ListModulesResponse, error)
+	Reload(ctx context.Context, req *ReloadRequest) (*Empty, error)
+
+	ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error)
+	KickSessions(ctx context.Context, req *KickSessionsRequest) (*KickSessionsResponse, error)
+
+	ListAuthLockouts(ctx context.Context, req *ListAuthLockoutsRequest) (*ListAuthLockoutsResponse, error)
+	ClearAuthLockouts(ctx context.Context, req *ClearAuthLockoutsRequest) (*ClearAuthLockoutsResponse, error)
+
+	ManageQueue(ctx context.Context, req *QueueRequest) (*QueueResponse, error)
+
+	ListUsers(ctx context.Context, req *UserRequest) (*UserList, error)
+	CreateUser(ctx context.Context, req *UserRequest) (*Empty, error)
+	DeleteUser(ctx context.Context, req *UserRequest) (*Empty, error)
+	SetUserPassword(ctx context.Context, req *UserRequest) (*Empty, error)
+
+	ListAccounts(ctx context.Context, req *AccountRequest) (*UserList, error)
+	CreateAccount(ctx context.Context, req *AccountRequest) (*CreateAccountResponse, error)
+	DeleteAccount(ctx context.Context, req *AccountRequest) (*Empty, error)
+	AppendLimit(ctx context.Context, req *AppendLimitRequest) (*AppendLimitResponse, error)
+	Quota(ctx context.Context, req *QuotaRequest) (*QuotaResponse, error)
+	Reindex(ctx context.Context, req *AccountRequest) (*UserList, error)
+
+	ListMailboxes(ctx context.Context, req *MailboxRequest) (*MailboxList, error)
+	CreateMailbox(ctx context.Context, req *MailboxRequest) (*Empty, error)
+	DeleteMailbox(ctx context.Context, req *MailboxRequest) (*Empty, error)
+	RenameMailbox(ctx context.Context, req *MailboxRequest) (*Empty, error)
+
+	AddMessage(ctx context.Context, req *MessageRequest) (*AddMessageResponse, error)
+	ListMessages(ctx context.Context, req *MessageRequest) (*MessageList, error)
+	UpdateMessageFlags(ctx context.Context, req *MessageRequest) (*Empty, error)
+	RemoveMessages(ctx context.Context, req *MessageRequest) (*Empty, error)
+}
+
+type Empty struct{}
+
+type ReloadRequest struct{}
+
+type ListModulesRequest struct {
+	// Health enables health checks of modules that support them.
+	Health bool
+}
+
+type ModuleInfo struct {
+	Name     string
+	Instance string
+	Endpoint bool
+
+	// Initialized is false for modules that are defined in the
+	// configuration but not used by the server.
+	Initialized bool
+
+	// Health is "ok" or the error returned by the health check. It is
+	// empty if the module does not support health checks or they were not
+	// requested.
+	Health string
+}
+
+type ListModulesResponse struct {
+	Modules []ModuleInfo
+}
+
+type ListSessionsRequest struct {
+	// Endpoint and User filter returned sessions if not empty.
+	Endpoint string
+	User     string
+}
+
+type ListSessionsResponse struct {
+	Sessions []sessions.Session
+}
+
+type KickSessionsRequest struct {
+	// Sessions with the listed IDs and all sessions of User are closed.
+	IDs  []uint64
+	User string
+}
+
+type KickSessionsResponse struct {
+	Count int
+}
+
+type ListAuthLockoutsRequest struct {
+	// All includes clients and usernames with failed attempts that are not
+	// locked out.
+	All bool
+}
+
+type ListAuthLockoutsResponse struct {
+	Entries []bruteforce.Entry
+}
+
+type ClearAuthLockoutsRequest struct {
+	// Values are IP addresses, networks or usernames to clear. All entries
+	// are cleared if All is set.
+	Values []string
+	All    bool
+}
+
+type ClearAuthLockoutsResponse struct {
+	Count int
+}
+
+type QueueRequest struct {
+	Block string
+
+	// Op is one of the commands accepted by queue.Queue.Manage.
+	Op     string
+	ID     string
+	Domain string
+	Sender string
+}
+
+type QueueResponse struct {
+	Messages []queue.MessageInfo
+	Count    int
+}
+
+type UserRequest struct {
+	Block    string
+	Username string
+	Password string
+
+	// Hash and BcryptCost are used by CreateUser for pass_table modules.
+	// Empty Hash means the default algorithm.
+	Hash       string
+	BcryptCost int
+}
+
+type UserList struct {
+	Usernames []string
+}
+
+type AccountRequest struct {
+	Block string
+
+	// Usernames is used by Reindex, all accounts are reindexed if it is
+	// empty. Other calls use Username.
+	Username  string
+	Usernames []string
+
+	// SpecialUse maps SPECIAL-USE attributes (e.g. \Sent) to the names of
+	// mailboxes created by CreateAccount.
+	SpecialUse map[string]string
+}
+
+type CreateAccountResponse struct {
+	// Warnings are errors that did not prevent account creation, e.g.
+	// failures to create special-use mailboxes.
+	Warnings []string
+}
+
+type AppendLimitRequest struct {
+	Block    string
+	Username string
+
+	// Limit is set if Set is true, nil means no limit.
+	Set   bool
+	Limit *uint32
+}
+
+type AppendLimitResponse struct {
+	Limit *uint32
+}
+
+type QuotaRequest struct {
+	Block string
+
+	// Name is the account name or the domain name prefixed with '@'.
+	Name string
+
+	Set    bool
+	Limits module.QuotaLimits
+}
+
+type QuotaResponse struct {
+	Limits module.QuotaLimits
+
+	// Usage is set for accounts.
+	Usage *module.Quota
+}
+
+type MailboxRequest struct {
+	Block    string
+	Username string
+	Mailbox  string
+
+	// Subscribed is used by ListMailboxes, NewName by RenameMailbox and
+	// SpecialUse attribute by CreateMailbox.
+	Subscribed bool
+	NewName    string
+	SpecialUse string
+}
+
+type MailboxInfo struct {
+	Name       string
+	Attributes []string
+}
+
+type MailboxList struct {
+	Mailboxes []MailboxInfo
+}
+
+type MessageRequest struct {
+	Block    string
+	Username string
+	Mailbox  string
+
+	// UID selects whether SeqSet contains UIDs or sequence numbers.
+	UID    bool
+	SeqSet string
+
+	// Flags are set on messages by AddMessage and added or removed by
+	// UpdateMessageFlags depending on RemoveFlags.
+	Flags       []string
+	RemoveFlags bool
+
+	// Date and Body are used by AddMessage.
+	Date time.Time
+	Body []byte
+}
+
+type AddMessageResponse struct {
+	UID uint32
+}
+
+type MessageInfo struct {
+	SeqNum uint32
+	UID    uint32
+	Flags  []string
+	Date   time.Time
+}
+
+type MessageList struct {
+	Messages []MessageInfo
+}
+
+// ReadOnly reports whether the request to the method does not change the
+// server state. The admin endpoint uses it to log all changes.
+func ReadOnly(method string, req interface{}) bool {
+	if strings.HasPrefix(method[strings.LastIndex(method, "/")+1:], "List") {
+		return true
+	}
+	switch req := req.(type) {
+	case *AppendLimitRequest:
+		return !req.Set
+	case *QuotaRequest:
+		return !req.Set
+	case *QueueRequest:
+		return req.Op == "list" || req.Op == "show"
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ServiceName = "mailchat.admin.v1.Admin"
+
+	codecName = "json"
+
+	// MaxMessageSize is the limit for the size of encoded requests and
+	// responses. It is larger than gRPC default to allow AddMessage with
+	// big messages.
+	MaxMessageSize = 128 << 20
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// SocketPath returns the default path of the admin endpoint socket.
+func SocketPath() string {
+	return filepath.Join(config.RuntimeDirectory, "admin.sock")
+}
+
+func unaryHandler[Req, Resp any](method string, call func(API, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(API), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + ServiceName + "/" + method,
+			}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(API), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+// ServiceDesc describes the admin service for grpc.Server.RegisterService.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*API)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("ListModules", API.ListModules),
+		unaryHandler("Reload", API.Reload),
+		unaryHandler("ListSessions", API.ListSessions),
+		unaryHandler("KickSessions", API.KickSessions),
+		unaryHandler("ListAuthLockouts", API.ListAuthLockouts),
+		unaryHandler("ClearAuthLockouts", API.ClearAuthLockouts),
+		unaryHandler("ManageQueue", API.ManageQueue),
+		unaryHandler("ListUsers", API.ListUsers),
+		unaryHandler("CreateUser", API.CreateUser),
+		unaryHandler("DeleteUser", API.DeleteUser),
+		unaryHandler("SetUserPassword", API.SetUserPassword),
+		unaryHandler("ListAccounts", API.ListAccounts),
+		unaryHandler("CreateAccount", API.CreateAccount),
+		unaryHandler("DeleteAccount", API.DeleteAccount),
+		unaryHandler("AppendLimit", API.AppendLimit),
+		unaryHandler("Quota", API.Quota),
+		unaryHandler("Reindex", API.Reindex),
+		unaryHandler("ListMailboxes", API.ListMailboxes),
+		unaryHandler("CreateMailbox", API.CreateMailbox),
+		unaryHandler("DeleteMailbox", API.DeleteMailbox),
+		unaryHandler("RenameMailbox", API.RenameMailbox),
+		unaryHandler("AddMessage", API.AddMessage),
+		unaryHandler("ListMessages", API.ListMessages),
+		unaryHandler("UpdateMessageFlags", API.UpdateMessageFlags),
+		unaryHandler("RemoveMessages", API.RemoveMessages),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// Client is the API implementation that sends requests to the server.
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+var _ API = &Client{}
+
+// DialSocket connects to the admin endpoint listening on the Unix socket.
+//
+// Unlike gRPC clients that connect lazily, it fails immediately if the
+// server is not running.
+func DialSocket(path string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	return newClient("unix:"+path, insecure.NewCredentials())
+}
+
+// DialTLS connects to the admin endpoint listening on the TCP address.
+// tlsConfig should contain the client certificate and the CA used to
+// verify the server certificate.
+func DialTLS(addr string, tlsConfig *tls.Config) (*Client, error) {
+	return newClient("dns:///"+addr, credentials.NewTLS(tlsConfig))
+}
+
+func newClient(target string, creds credentials.TransportCredentials) (*Client, error) {
+	cc, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(
+			grpc.CallContentSubtype(codecName),
+			grpc.MaxCallRecvMsgSize(MaxMessageSize),
+			grpc.MaxCallSendMsgSize(MaxMessageSize),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cc: cc}, nil
+}
+
+func (c *Client) Close() error {
+	return c.cc.Close()
+}
+
+func invoke[Resp any](ctx context.Context, c *Client, method string, req interface{}) (*Resp, error) {
+	resp := new(Resp)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/"+method, req, resp); err != nil {
+		// Error messages are returned by the server as is, strip the gRPC
+		// wrapping so they look the same as errors of the local Service.
+		if st, ok := status.FromError(err); ok {
+			return nil, errors.New(st.Message())
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) ListModules(ctx context.Context, req *ListModulesRequest) (*ListModulesResponse, error) {
+	return invoke[ListModulesResponse](ctx, c, "ListModules", req)
+}
+
+func (c *Client) Reload(ctx context.Context, req *ReloadRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "Reload", req)
+}
+
+func (c *Client) ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return invoke[ListSessionsResponse](ctx, c, "ListSessions", req)
+}
+
+func (c *Client) KickSessions(ctx context.Context, req *KickSessionsRequest) (*KickSessionsResponse, error) {
+	return invoke[KickSessionsResponse](ctx, c, "KickSessions", req)
+}
+
+func (c *Client) ListAuthLockouts(ctx context.Context, req *ListAuthLockoutsRequest) (*ListAuthLockoutsResponse, error) {
+	return invoke[ListAuthLockoutsResponse](ctx, c, "ListAuthLockouts", req)
+}
+
+func (c *Client) ClearAuthLockouts(ctx context.Context, req *ClearAuthLockoutsRequest) (*ClearAuthLockoutsResponse, error) {
+	return invoke[ClearAuthLockoutsResponse](ctx, c, "ClearAuthLockouts", req)
+}
+
+func (c *Client) ManageQueue(ctx context.Context, req *QueueRequest) (*QueueResponse, error) {
+	return invoke[QueueResponse](ctx, c, "ManageQueue", req)
+}
+
+func (c *Client) ListUsers(ctx context.Context, req *UserRequest) (*UserList, error) {
+	return invoke[UserList](ctx, c, "ListUsers", req)
+}
+
+func (c *Client) CreateUser(ctx context.Context, req *UserRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "CreateUser", req)
+}
+
+func (c *Client) DeleteUser(ctx context.Context, req *UserRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "DeleteUser", req)
+}
+
+func (c *Client) SetUserPassword(ctx context.Context, req *UserRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "SetUserPassword", req)
+}
+
+func (c *Client) ListAccounts(ctx context.Context, req *AccountRequest) (*UserList, error) {
+	return invoke[UserList](ctx, c, "ListAccounts", req)
+}
+
+func (c *Client) CreateAccount(ctx context.Context, req *AccountRequest) (*CreateAccountResponse, error) {
+	return invoke[CreateAccountResponse](ctx, c, "CreateAccount", req)
+}
+
+func (c *Client) DeleteAccount(ctx context.Context, req *AccountRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "DeleteAccount", req)
+}
+
+func (c *Client) AppendLimit(ctx context.Context, req *AppendLimitRequest) (*AppendLimitResponse, error) {
+	return invoke[AppendLimitResponse](ctx, c, "AppendLimit", req)
+}
+
+func (c *Client) Quota(ctx context.Context, req *QuotaRequest) (*QuotaResponse, error) {
+	return invoke[QuotaResponse](ctx, c, "Quota", req)
+}
+
+func (c *Client) Reindex(ctx context.Context, req *AccountRequest) (*UserList, error) {
+	return invoke[UserList](ctx, c, "Reindex", req)
+}
+
+func (c *Client) ListMailboxes(ctx context.Context, req *MailboxRequest) (*MailboxList, error) {
+	return invoke[MailboxList](ctx, c, "ListMailboxes", req)
+}
+
+func (c *Client) CreateMailbox(ctx context.Context, req *MailboxRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "CreateMailbox", req)
+}
+
+func (c *Client) DeleteMailbox(ctx context.Context, req *MailboxRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "DeleteMailbox", req)
+}
+
+func (c *Client) RenameMailbox(ctx context.Context, req *MailboxRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "RenameMailbox", req)
+}
+
+func (c *Client) AddMessage(ctx context.Context, req *MessageRequest) (*AddMessageResponse, error) {
+	return invoke[AddMessageResponse](ctx, c, "AddMessage", req)
+}
+
+func (c *Client) ListMessages(ctx context.Context, req *MessageRequest) (*MessageList, error) {
+	return invoke[MessageList](ctx, c, "ListMessages", req)
+}
+
+func (c *Client) UpdateMessageFlags(ctx context.Context, req *MessageRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "UpdateMessageFlags", req)
+}
+
+func (c *Client) RemoveMessages(ctx context.Context, req *MessageRequest) (*Empty, error) {
+	return invoke[Empty](ctx, c, "RemoveMessages", req)
+}
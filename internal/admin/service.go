@@ -0,0 +1,621 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth/bruteforce"
+	"github.com/dsoftgames/MailChat/internal/auth/pass_table"
+	"github.com/dsoftgames/MailChat/internal/sessions"
+	"github.com/dsoftgames/MailChat/internal/target/queue"
+	"github.com/emersion/go-imap"
+	imapbackend "github.com/emersion/go-imap/backend"
+)
+
+// ErrNotRunning is returned by Service calls that need the running server.
+var ErrNotRunning = errors.New("admin: the server is not running")
+
+// ReloadServer reloads the configuration of the running server, it is set
+// by the server and used as Service.ReloadConfig by the admin endpoint.
+var ReloadServer func() error
+
+// healthTimeout is the time allowed for the health check of each module.
+const healthTimeout = 5 * time.Second
+
+// Service is the API implementation that executes requests using module
+// instances.
+type Service struct {
+	// Module returns the initialized module instance defined by the
+	// configuration block.
+	Module func(name string) (module.Module, error)
+
+	// ReloadConfig is called to reload the server configuration.
+	ReloadConfig func() error
+
+	// Standalone is set if Service is used by the management command without
+	// the running server. Calls that inspect the server state fail with
+	// ErrNotRunning.
+	Standalone bool
+}
+
+var _ API = &Service{}
+
+// ServerModule returns the instance from the global registry, it is used
+// as Service.Module by the server.
+func ServerModule(name string) (module.Module, error) {
+	if _, ok := module.LookupInstance(name); !ok {
+		return nil, fmt.Errorf("unknown configuration block: %s", name)
+	}
+	return module.GetInstance(name)
+}
+
+func (s *Service) ListModules(ctx context.Context, req *ListModulesRequest) (*ListModulesResponse, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+
+	resp := &ListModulesResponse{}
+	for _, endp := range module.Endpoints() {
+		info := ModuleInfo{
+			Name:        endp.Name(),
+			Instance:    endp.InstanceName(),
+			Endpoint:    true,
+			Initialized: true,
+		}
+		if req.Health {
+			info.Health = checkHealth(ctx, endp)
+		}
+		resp.Modules = append(resp.Modules, info)
+	}
+	for _, name := range module.InstanceNames() {
+		mod, _ := module.LookupInstance(name)
+		info := ModuleInfo{
+			Name:        mod.Name(),
+			Instance:    name,
+			Initialized: module.IsInitialized(name),
+		}
+		if req.Health && info.Initialized {
+			info.Health = checkHealth(ctx, mod)
+		}
+		resp.Modules = append(resp.Modules, info)
+	}
+	return resp, nil
+}
+
+func checkHealth(ctx context.Context, mod module.Module) string {
+	hc, ok := mod.(module.HealthChecker)
+	if !ok {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	if err := hc.CheckHealth(ctx); err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func (s *Service) Reload(_ context.Context, _ *ReloadRequest) (*Empty, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+	if s.ReloadConfig == nil {
+		return nil, errors.New("admin: reload is not supported")
+	}
+	return &Empty{}, s.ReloadConfig()
+}
+
+func (s *Service) ListSessions(_ context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+
+	resp := &ListSessionsResponse{}
+	for _, sess := range sessions.List() {
+		if req.Endpoint != "" && sess.Endpoint != req.Endpoint {
+			continue
+		}
+		if req.User != "" && sess.User != req.User {
+			continue
+		}
+		resp.Sessions = append(resp.Sessions, sess)
+	}
+	return resp, nil
+}
+
+func (s *Service) KickSessions(_ context.Context, req *KickSessionsRequest) (*KickSessionsResponse, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+	if len(req.IDs) == 0 && req.User == "" {
+		return nil, errors.New("admin: no sessions specified")
+	}
+
+	ids := make(map[uint64]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+	count := sessions.Kick(func(sess sessions.Session) bool {
+		return ids[sess.ID] || (req.User != "" && sess.User == req.User)
+	})
+	return &KickSessionsResponse{Count: count}, nil
+}
+
+func (s *Service) ListAuthLockouts(_ context.Context, req *ListAuthLockoutsRequest) (*ListAuthLockoutsResponse, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+	return &ListAuthLockoutsResponse{Entries: bruteforce.Default.List(!req.All)}, nil
+}
+
+func (s *Service) ClearAuthLockouts(_ context.Context, req *ClearAuthLockoutsRequest) (*ClearAuthLockoutsResponse, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+	if len(req.Values) == 0 && !req.All {
+		return nil, errors.New("admin: no entries specified")
+	}
+
+	resp := &ClearAuthLockoutsResponse{}
+	if req.All {
+		resp.Count = bruteforce.Default.Clear("")
+		return resp, nil
+	}
+	for _, value := range req.Values {
+		if value == "" {
+			continue
+		}
+		resp.Count += bruteforce.Default.Clear(value)
+	}
+	return resp, nil
+}
+
+func (s *Service) ManageQueue(_ context.Context, req *QueueRequest) (*QueueResponse, error) {
+	if s.Standalone {
+		return nil, ErrNotRunning
+	}
+
+	mod, err := s.Module(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	q, ok := mod.(*queue.Queue)
+	if !ok {
+		return nil, fmt.Errorf("configuration block %s is not a target.queue", req.Block)
+	}
+
+	msgs, count, err := q.Manage(req.Op, req.ID, req.Domain, req.Sender)
+	if err != nil {
+		return nil, err
+	}
+	return &QueueResponse{Messages: msgs, Count: count}, nil
+}
+
+func (s *Service) userDB(block string) (module.PlainUserDB, error) {
+	mod, err := s.Module(block)
+	if err != nil {
+		return nil, err
+	}
+	db, ok := mod.(module.PlainUserDB)
+	if !ok {
+		return nil, fmt.Errorf("configuration block %s is not a local credentials store", block)
+	}
+	return db, nil
+}
+
+func (s *Service) ListUsers(_ context.Context, req *UserRequest) (*UserList, error) {
+	db, err := s.userDB(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	list, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	return &UserList{Usernames: list}, nil
+}
+
+func (s *Service) CreateUser(_ context.Context, req *UserRequest) (*Empty, error) {
+	db, err := s.userDB(req.Block)
+	if err != nil {
+		return nil, err
+	}
+
+	if tbl, ok := db.(*pass_table.Auth); ok && req.Hash != "" {
+		return &Empty{}, tbl.CreateUserHash(req.Username, req.Password, req.Hash, pass_table.HashOpts{
+			BcryptCost: req.BcryptCost,
+		})
+	} else if !ok && (req.Hash != "" || req.BcryptCost != 0) {
+		return nil, errors.New("hash algorithm cannot be set for non-pass_table credentials DB")
+	}
+	return &Empty{}, db.CreateUser(req.Username, req.Password)
+}
+
+func (s *Service) DeleteUser(_ context.Context, req *UserRequest) (*Empty, error) {
+	db, err := s.userDB(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, db.DeleteUser(req.Username)
+}
+
+func (s *Service) SetUserPassword(_ context.Context, req *UserRequest) (*Empty, error) {
+	db, err := s.userDB(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, db.SetUserPassword(req.Username, req.Password)
+}
+
+func (s *Service) storage(block string) (module.Storage, error) {
+	mod, err := s.Module(block)
+	if err != nil {
+		return nil, err
+	}
+	store, ok := mod.(module.Storage)
+	if !ok {
+		return nil, fmt.Errorf("configuration block %s is not an IMAP storage", block)
+	}
+	return store, nil
+}
+
+func (s *Service) manageableStorage(block string) (module.ManageableStorage, error) {
+	store, err := s.storage(block)
+	if err != nil {
+		return nil, err
+	}
+	mstore, ok := store.(module.ManageableStorage)
+	if !ok {
+		return nil, errors.New("storage backend does not support accounts management")
+	}
+	return mstore, nil
+}
+
+// SpecialUseUser is implemented by storage accounts that support
+// SPECIAL-USE IMAP extension.
+type SpecialUseUser interface {
+	CreateMailboxSpecial(name, specialUseAttr string) error
+}
+
+func (s *Service) ListAccounts(_ context.Context, req *AccountRequest) (*UserList, error) {
+	store, err := s.manageableStorage(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	list, err := store.ListIMAPAccts()
+	if err != nil {
+		return nil, err
+	}
+	return &UserList{Usernames: list}, nil
+}
+
+func (s *Service) CreateAccount(_ context.Context, req *AccountRequest) (*CreateAccountResponse, error) {
+	store, err := s.manageableStorage(req.Block)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.CreateIMAPAcct(req.Username); err != nil {
+		return nil, err
+	}
+	if len(req.SpecialUse) == 0 {
+		return &CreateAccountResponse{}, nil
+	}
+
+	u, err := store.GetIMAPAcct(req.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	defer u.Logout()
+
+	resp := &CreateAccountResponse{}
+	suu, ok := u.(SpecialUseUser)
+	if !ok {
+		resp.Warnings = append(resp.Warnings, "storage backend does not support SPECIAL-USE IMAP extension")
+	}
+	for attr, name := range req.SpecialUse {
+		if suu != nil {
+			err = suu.CreateMailboxSpecial(name, attr)
+		} else {
+			err = u.CreateMailbox(name)
+		}
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("failed to create %s mailbox: %v", name, err))
+		}
+	}
+	return resp, nil
+}
+
+func (s *Service) DeleteAccount(_ context.Context, req *AccountRequest) (*Empty, error) {
+	store, err := s.manageableStorage(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, store.DeleteIMAPAcct(req.Username)
+}
+
+// AppendLimitUser is extension for backend.User interface which allows to
+// set append limit value for testing and administration purposes.
+type AppendLimitUser interface {
+	imapbackend.AppendLimitUser
+
+	// SetMessageLimit sets new value for limit.
+	// nil pointer means no limit.
+	SetMessageLimit(val *uint32) error
+}
+
+func (s *Service) AppendLimit(_ context.Context, req *AppendLimitRequest) (*AppendLimitResponse, error) {
+	store, err := s.storage(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	u, err := store.GetIMAPAcct(req.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Logout()
+
+	userAL, ok := u.(AppendLimitUser)
+	if !ok {
+		return nil, errors.New("storage backend does not support per-user append limit")
+	}
+	if req.Set {
+		return &AppendLimitResponse{Limit: req.Limit}, userAL.SetMessageLimit(req.Limit)
+	}
+	return &AppendLimitResponse{Limit: userAL.CreateMessageLimit()}, nil
+}
+
+func (s *Service) Quota(_ context.Context, req *QuotaRequest) (*QuotaResponse, error) {
+	store, err := s.storage(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	mq, ok := store.(module.ManageableQuotas)
+	if !ok {
+		return nil, errors.New("storage backend does not support quotas management")
+	}
+
+	if req.Set {
+		if err := mq.SetQuotaLimits(req.Name, req.Limits); err != nil {
+			return nil, err
+		}
+		return &QuotaResponse{Limits: req.Limits}, nil
+	}
+
+	resp := &QuotaResponse{}
+	resp.Limits, err = mq.QuotaLimits(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(req.Name, "@") {
+		return resp, nil
+	}
+	usage, err := mq.AccountQuota(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	resp.Usage = &usage
+	return resp, nil
+}
+
+func (s *Service) Reindex(_ context.Context, req *AccountRequest) (*UserList, error) {
+	store, err := s.storage(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	idx, ok := store.(module.FullTextIndexer)
+	if !ok {
+		return nil, errors.New("storage backend does not support full-text search")
+	}
+
+	accounts := req.Usernames
+	if len(accounts) == 0 {
+		mstore, ok := store.(module.ManageableStorage)
+		if !ok {
+			return nil, errors.New("storage backend does not support accounts management")
+		}
+		accounts, err = mstore.ListIMAPAccts()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &UserList{}
+	for _, acct := range accounts {
+		if err := idx.RebuildIndex(acct); err != nil {
+			return resp, fmt.Errorf("%s: %w", acct, err)
+		}
+		resp.Usernames = append(resp.Usernames, acct)
+	}
+	return resp, nil
+}
+
+func (s *Service) account(block, username string) (imapbackend.User, error) {
+	store, err := s.storage(block)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetIMAPAcct(username)
+}
+
+func (s *Service) ListMailboxes(_ context.Context, req *MailboxRequest) (*MailboxList, error) {
+	u, err := s.account(req.Block, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Logout()
+
+	mboxes, err := u.ListMailboxes(req.Subscribed)
+	if err != nil {
+		return nil, err
+	}
+	resp := &MailboxList{}
+	for _, mbox := range mboxes {
+		resp.Mailboxes = append(resp.Mailboxes, MailboxInfo{
+			Name:       mbox.Name,
+			Attributes: mbox.Attributes,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Service) CreateMailbox(_ context.Context, req *MailboxRequest) (*Empty, error) {
+	u, err := s.account(req.Block, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Logout()
+
+	if req.SpecialUse == "" {
+		return &Empty{}, u.CreateMailbox(req.Mailbox)
+	}
+	suu, ok := u.(SpecialUseUser)
+	if !ok {
+		return nil, errors.New("storage backend does not support SPECIAL-USE IMAP extension")
+	}
+	return &Empty{}, suu.CreateMailboxSpecial(req.Mailbox, req.SpecialUse)
+}
+
+func (s *Service) DeleteMailbox(_ context.Context, req *MailboxRequest) (*Empty, error) {
+	u, err := s.account(req.Block, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Logout()
+	return &Empty{}, u.DeleteMailbox(req.Mailbox)
+}
+
+func (s *Service) RenameMailbox(_ context.Context, req *MailboxRequest) (*Empty, error) {
+	u, err := s.account(req.Block, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Logout()
+	return &Empty{}, u.RenameMailbox(req.Mailbox, req.NewName)
+}
+
+func (s *Service) AddMessage(_ context.Context, req *MessageRequest) (*AddMessageResponse, error) {
+	if len(req.Body) == 0 {
+		return nil, errors.New("empty message, refusing to continue")
+	}
+
+	u, err := s.account(req.Block, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Logout()
+
+	status, err := u.Status(req.Mailbox, []imap.StatusItem{imap.StatusUidNext})
+	if err != nil {
+		return nil, err
+	}
+
+	flags := req.Flags
+	if flags == nil {
+		flags = []string{}
+	}
+	date := req.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	if err := u.CreateMessage(req.Mailbox, flags, date, bytes.NewReader(req.Body), nil); err != nil {
+		return nil, err
+	}
+
+	// TODO: Use APPENDUID
+	return &AddMessageResponse{UID: status.UidNext}, nil
+}
+
+// mailbox opens the mailbox and parses the request SeqSet. Returned
+// function should be called to close the mailbox.
+func (s *Service) mailbox(req *MessageRequest, readOnly bool) (imapbackend.Mailbox, *imap.SeqSet, func(), error) {
+	seqStr := req.SeqSet
+	if seqStr == "" {
+		seqStr = "1:*"
+	}
+	seq, err := imap.ParseSeqSet(seqStr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	u, err := s.account(req.Block, req.Username)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, mbox, err := u.GetMailbox(req.Mailbox, readOnly, nil)
+	if err != nil {
+		u.Logout()
+		return nil, nil, nil, err
+	}
+	return mbox, seq, func() {
+		mbox.Close()
+		u.Logout()
+	}, nil
+}
+
+func (s *Service) ListMessages(_ context.Context, req *MessageRequest) (*MessageList, error) {
+	mbox, seq, closeMbox, err := s.mailbox(req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMbox()
+
+	ch := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- mbox.ListMessages(req.UID, seq, []imap.FetchItem{imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid}, ch)
+	}()
+
+	resp := &MessageList{}
+	for msg := range ch {
+		resp.Messages = append(resp.Messages, MessageInfo{
+			SeqNum: msg.SeqNum,
+			UID:    msg.Uid,
+			Flags:  msg.Flags,
+			Date:   msg.InternalDate,
+		})
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *Service) UpdateMessageFlags(_ context.Context, req *MessageRequest) (*Empty, error) {
+	mbox, seq, closeMbox, err := s.mailbox(req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMbox()
+
+	var op imap.FlagsOp = imap.AddFlags
+	if req.RemoveFlags {
+		op = imap.RemoveFlags
+	}
+	return &Empty{}, mbox.UpdateMessagesFlags(req.UID, seq, op, false, req.Flags)
+}
+
+func (s *Service) RemoveMessages(_ context.Context, req *MessageRequest) (*Empty, error) {
+	if req.SeqSet == "" {
+		return nil, errors.New("no messages specified")
+	}
+
+	mbox, seq, closeMbox, err := s.mailbox(req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMbox()
+
+	delMbox, ok := mbox.(interface {
+		DelMessages(uid bool, seqset *imap.SeqSet) error
+	})
+	if !ok {
+		return nil, errors.New("storage backend does not support messages removal")
+	}
+	return &Empty{}, delMbox.DelMessages(req.UID, seq)
+}
@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// LoadCertPool reads PEM-encoded certificates from the files.
+func LoadCertPool(paths []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(blob) {
+			return nil, fmt.Errorf("no certificates was loaded from %s", path)
+		}
+	}
+	return pool, nil
+}
+
+// ClientTLSConfig returns the configuration for DialTLS using the client
+// certificate and the CA certificate used to verify the server.
+func ClientTLSConfig(certPath, keyPath, caPath string) (*tls.Config, error) {
+	keypair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{keypair},
+	}
+	if caPath != "" {
+		cfg.RootCAs, err = LoadCertPool([]string{caPath})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
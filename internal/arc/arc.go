@@ -0,0 +1,408 @@
+// Package arc implements validation and sealing of Authenticated Received
+// Chains (RFC 8617).
+package arc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+// Status is the chain validation status (cv= tag).
+type Status string
+
+const (
+	StatusNone Status = "none"
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+)
+
+// MaxInstances is the maximum amount of ARC sets in the chain.
+const MaxInstances = 50
+
+// TrustedSealerParam is the Authentication-Results property set by check.arc
+// for passing chains sealed by a trusted intermediary. DMARC evaluation uses
+// it to override the policy for forwarded messages.
+const TrustedSealerParam = "policy.trusted-sealer"
+
+const (
+	fieldSeal        = "arc-seal"
+	fieldSignature   = "arc-message-signature"
+	fieldAuthResults = "arc-authentication-results"
+)
+
+type failError struct {
+	reason string
+	temp   bool
+}
+
+func (err failError) Error() string {
+	return "arc: " + err.reason
+}
+
+func permFail(reason string) error {
+	return failError{reason: reason}
+}
+
+func tempFail(reason string) error {
+	return failError{reason: reason, temp: true}
+}
+
+// IsTempFail reports whether the error is caused by a temporary condition,
+// such as a DNS lookup failure.
+func IsTempFail(err error) bool {
+	var fErr failError
+	return errors.As(err, &fErr) && fErr.temp
+}
+
+// Set is a single ARC set.
+type Set struct {
+	Instance int
+
+	// Domain and Selector of the ARC-Seal.
+	Domain   string
+	Selector string
+
+	// ChainStatus is the cv= value of the ARC-Seal.
+	ChainStatus Status
+
+	// AuthResults is the ARC-Authentication-Results value without the
+	// instance tag.
+	AuthResults string
+
+	seal, signature, authResults field
+	sealTags, signatureTags      map[string]string
+}
+
+// Result is the outcome of the chain validation.
+type Result struct {
+	Status Status
+
+	// Reason describes why the chain failed validation.
+	Reason string
+
+	// Sets contains all ARC sets found in the message, ordered by instance.
+	// It is empty if the chain is structurally invalid.
+	Sets []Set
+
+	// OldestPass is the lowest instance whose ARC-Message-Signature still
+	// validates, 0 if all do. Valid only for passing chains.
+	OldestPass int
+}
+
+// Latest returns the most recent ARC set or nil if there are none.
+func (res *Result) Latest() *Set {
+	if len(res.Sets) == 0 {
+		return nil
+	}
+	return &res.Sets[len(res.Sets)-1]
+}
+
+func parseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ";") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			return nil, errors.New("malformed tag: " + tag)
+		}
+		name = strings.TrimSpace(name)
+		if _, ok := tags[name]; ok {
+			return nil, errors.New("duplicate tag: " + name)
+		}
+		tags[name] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+func stripWSP(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func parseInstance(s string) (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || i < 1 || i > MaxInstances {
+		return 0, errors.New("invalid instance: " + s)
+	}
+	return i, nil
+}
+
+// collectSets groups ARC header fields into sets. The chain is structurally
+// invalid if some instance is missing, duplicated or incomplete.
+func collectSets(fields []field) ([]Set, error) {
+	sets := map[int]*Set{}
+	get := func(i int) *Set {
+		if sets[i] == nil {
+			sets[i] = &Set{Instance: i}
+		}
+		return sets[i]
+	}
+
+	for _, f := range fields {
+		switch f.key() {
+		case fieldSeal, fieldSignature:
+			tags, err := parseTags(f.value())
+			if err != nil {
+				return nil, err
+			}
+			i, err := parseInstance(tags["i"])
+			if err != nil {
+				return nil, err
+			}
+			set := get(i)
+			if f.key() == fieldSeal {
+				if set.seal != "" {
+					return nil, errors.New("duplicate ARC-Seal for instance " + tags["i"])
+				}
+				set.seal, set.sealTags = f, tags
+			} else {
+				if set.signature != "" {
+					return nil, errors.New("duplicate ARC-Message-Signature for instance " + tags["i"])
+				}
+				set.signature, set.signatureTags = f, tags
+			}
+		case fieldAuthResults:
+			instTag, rest, _ := strings.Cut(f.value(), ";")
+			name, value, _ := strings.Cut(instTag, "=")
+			if strings.TrimSpace(name) != "i" {
+				return nil, errors.New("ARC-Authentication-Results without instance")
+			}
+			i, err := parseInstance(value)
+			if err != nil {
+				return nil, err
+			}
+			set := get(i)
+			if set.authResults != "" {
+				return nil, errors.New("duplicate ARC-Authentication-Results for instance " + value)
+			}
+			set.authResults = f
+			set.AuthResults = strings.TrimSpace(compressWSP(strings.ReplaceAll(rest, crlf, "")))
+		}
+	}
+
+	res := make([]Set, 0, len(sets))
+	for i := 1; i <= len(sets); i++ {
+		set := sets[i]
+		if set == nil {
+			return nil, errors.New("missing instance " + strconv.Itoa(i))
+		}
+		if set.seal == "" || set.signature == "" || set.authResults == "" {
+			return nil, errors.New("incomplete set for instance " + strconv.Itoa(i))
+		}
+		set.Domain = set.sealTags["d"]
+		set.Selector = set.sealTags["s"]
+		set.ChainStatus = Status(strings.ToLower(set.sealTags["cv"]))
+		res = append(res, *set)
+	}
+	return res, nil
+}
+
+// Verify validates the ARC chain of the message as described in RFC 8617
+// Section 5.2.
+//
+// Returned error is non-nil only for temporary failures, the chain is
+// considered failed in other cases.
+func Verify(ctx context.Context, r Resolver, header textproto.Header, body io.Reader) (*Result, error) {
+	var hdrBuf bytes.Buffer
+	if err := textproto.WriteHeader(&hdrBuf, header); err != nil {
+		return nil, err
+	}
+	fields := readFields(hdrBuf.Bytes())
+
+	sets, err := collectSets(fields)
+	if err != nil {
+		return &Result{Status: StatusFail, Reason: err.Error()}, nil
+	}
+	if len(sets) == 0 {
+		return &Result{Status: StatusNone}, nil
+	}
+	res := &Result{Status: StatusFail, Sets: sets}
+
+	for _, set := range sets {
+		expected := StatusPass
+		if set.Instance == 1 {
+			expected = StatusNone
+		}
+		if set.ChainStatus != expected {
+			res.Reason = "unexpected chain status " + string(set.ChainStatus) + " in instance " + strconv.Itoa(set.Instance)
+			return res, nil
+		}
+	}
+
+	bodyBlob, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := sets[len(sets)-1]
+	if err := verifySignature(ctx, r, fields, latest, bodyBlob); err != nil {
+		if IsTempFail(err) {
+			return nil, err
+		}
+		res.Reason = "ARC-Message-Signature " + strconv.Itoa(latest.Instance) + ": " + strings.TrimPrefix(err.Error(), "arc: ")
+		return res, nil
+	}
+
+	for i := len(sets) - 1; i >= 0; i-- {
+		if err := verifySeal(ctx, r, sets[:i+1]); err != nil {
+			if IsTempFail(err) {
+				return nil, err
+			}
+			res.Reason = "ARC-Seal " + strconv.Itoa(sets[i].Instance) + ": " + strings.TrimPrefix(err.Error(), "arc: ")
+			return res, nil
+		}
+	}
+
+	res.Status = StatusPass
+	for i := len(sets) - 2; i >= 0; i-- {
+		if err := verifySignature(ctx, r, fields, sets[i], bodyBlob); err != nil {
+			res.OldestPass = sets[i].Instance + 1
+			break
+		}
+	}
+	return res, nil
+}
+
+// signedFields selects fields listed in h= in the order they are signed,
+// using fields from the bottom for repeated names.
+func signedFields(fields []field, keys []string) []field {
+	used := map[string]int{}
+	res := make([]field, 0, len(keys))
+	for _, key := range keys {
+		key = strings.ToLower(strings.TrimSpace(key))
+		skip := used[key]
+		used[key]++
+		for i := len(fields) - 1; i >= 0; i-- {
+			if fields[i].key() != key {
+				continue
+			}
+			if skip == 0 {
+				res = append(res, fields[i])
+				break
+			}
+			skip--
+		}
+	}
+	return res
+}
+
+func verifySignature(ctx context.Context, r Resolver, fields []field, set Set, body []byte) error {
+	tags := set.signatureTags
+	for _, tag := range []string{"a", "b", "bh", "d", "h", "s"} {
+		if tags[tag] == "" {
+			return permFail("missing required tag " + tag)
+		}
+	}
+
+	headerCanon, bodyCanon := CanonSimple, CanonSimple
+	if c := tags["c"]; c != "" {
+		headerCanon, bodyCanon, _ = strings.Cut(c, "/")
+		if bodyCanon == "" {
+			bodyCanon = CanonSimple
+		}
+	}
+	for _, c := range []string{headerCanon, bodyCanon} {
+		if c != CanonSimple && c != CanonRelaxed {
+			return permFail("unsupported canonicalization " + c)
+		}
+	}
+
+	keys := strings.Split(tags["h"], ":")
+	for _, key := range keys {
+		if strings.EqualFold(strings.TrimSpace(key), fieldSeal) {
+			return permFail("ARC-Seal is signed")
+		}
+	}
+
+	limit := int64(-1)
+	if l := tags["l"]; l != "" {
+		var err error
+		limit, err = strconv.ParseInt(l, 10, 64)
+		if err != nil || limit < 0 {
+			return permFail("malformed body length")
+		}
+	}
+	canonBodyBlob, err := canonBody(bodyCanon, bytes.NewReader(body), limit)
+	if err != nil {
+		return err
+	}
+	bodyHash := sha256.Sum256(canonBodyBlob)
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != stripWSP(tags["bh"]) {
+		return permFail("body hash mismatch")
+	}
+
+	var data strings.Builder
+	for _, f := range signedFields(fields, keys) {
+		data.WriteString(canonHeader(headerCanon, f))
+	}
+	data.WriteString(strings.TrimSuffix(canonHeader(headerCanon, field(stripSignature(set.signature))), crlf))
+
+	return verifyData(ctx, r, tags, data.String())
+}
+
+func sealData(sets []Set, latestSeal string) string {
+	var data strings.Builder
+	for i, set := range sets {
+		data.WriteString(canonHeader(CanonRelaxed, set.authResults))
+		data.WriteString(canonHeader(CanonRelaxed, set.signature))
+		if i == len(sets)-1 {
+			data.WriteString(strings.TrimSuffix(canonHeader(CanonRelaxed, field(latestSeal)), crlf))
+			break
+		}
+		data.WriteString(canonHeader(CanonRelaxed, set.seal))
+	}
+	return data.String()
+}
+
+func verifySeal(ctx context.Context, r Resolver, sets []Set) error {
+	latest := sets[len(sets)-1]
+	tags := latest.sealTags
+	for _, tag := range []string{"a", "b", "cv", "d", "s"} {
+		if tags[tag] == "" {
+			return permFail("missing required tag " + tag)
+		}
+	}
+	if _, ok := tags["h"]; ok {
+		return permFail("h= tag is not allowed in ARC-Seal")
+	}
+
+	return verifyData(ctx, r, tags, sealData(sets, stripSignature(latest.seal)))
+}
+
+func verifyData(ctx context.Context, r Resolver, tags map[string]string, data string) error {
+	algo := strings.ToLower(tags["a"])
+	if algo != "rsa-sha256" && algo != "ed25519-sha256" {
+		return permFail("unsupported algorithm " + algo)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(stripWSP(tags["b"]))
+	if err != nil {
+		return permFail("malformed signature: " + err.Error())
+	}
+
+	key, err := lookupKey(ctx, r, algo, tags["d"], tags["s"])
+	if err != nil {
+		return err
+	}
+
+	hashed := sha256.Sum256([]byte(data))
+	if err := key.verify(hashed[:], sig); err != nil {
+		return permFail("signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/foxcpp/go-mockdns"
+)
+
+const testMsg = "From: Alice <alice@example.org>\r\n" +
+	"To: list@example.net\r\n" +
+	"Subject: Hello\r\n" +
+	"Date: Mon, 02 Jan 2026 03:04:05 +0000\r\n" +
+	"Message-Id: <1@example.org>\r\n" +
+	"\r\n" +
+	"Hello,  world!  \r\n" +
+	"\r\n" +
+	"\r\n"
+
+var testHeaderKeys = []string{"From", "To", "Subject", "Date", "Message-Id"}
+
+func testKeys(t *testing.T) (crypto.Signer, crypto.Signer, *mockdns.Resolver) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rsaKey, edKey, &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"rsa._domainkey.example.net.": {
+			TXT: []string{"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPub)},
+		},
+		"ed._domainkey.example.com.": {
+			TXT: []string{"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub)},
+		},
+	}}
+}
+
+func readMsg(t *testing.T, msg string) (textproto.Header, []byte) {
+	t.Helper()
+	br := bufio.NewReader(strings.NewReader(msg))
+	hdr, err := textproto.ReadHeader(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(br); err != nil {
+		t.Fatal(err)
+	}
+	return hdr, body.Bytes()
+}
+
+func verify(t *testing.T, r Resolver, hdr textproto.Header, body []byte) *Result {
+	t.Helper()
+	res, err := Verify(context.Background(), r, hdr, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func seal(t *testing.T, hdr *textproto.Header, body []byte, cv Status, signer crypto.Signer, domain, selector string) {
+	t.Helper()
+	err := Seal(hdr, bytes.NewReader(body), cv, SealOptions{
+		Domain:      domain,
+		Selector:    selector,
+		Signer:      signer,
+		AuthServID:  "mx." + domain,
+		AuthResults: "spf=pass smtp.mailfrom=example.org; dkim=pass header.d=example.org",
+		HeaderKeys:  testHeaderKeys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify_NoChain(t *testing.T) {
+	hdr, body := readMsg(t, testMsg)
+	if res := verify(t, &mockdns.Resolver{}, hdr, body); res.Status != StatusNone {
+		t.Fatalf("expected none, got %s (%s)", res.Status, res.Reason)
+	}
+}
+
+func TestSealVerify(t *testing.T) {
+	rsaKey, edKey, r := testKeys(t)
+	hdr, body := readMsg(t, testMsg)
+
+	seal(t, &hdr, body, StatusNone, rsaKey, "example.net", "rsa")
+	res := verify(t, r, hdr, body)
+	if res.Status != StatusPass {
+		t.Fatalf("expected pass after first seal, got %s (%s)", res.Status, res.Reason)
+	}
+	if latest := res.Latest(); latest.Instance != 1 || latest.Domain != "example.net" || latest.ChainStatus != StatusNone {
+		t.Errorf("wrong latest set: %+v", latest)
+	}
+	if !strings.HasPrefix(res.Latest().AuthResults, "mx.example.net; spf=pass") {
+		t.Errorf("wrong AAR: %s", res.Latest().AuthResults)
+	}
+
+	// Forwarder validates the chain, modifies the message and seals it
+	// again.
+	hdr.Set("Subject", "[list] Hello")
+	body = append(body, []byte("-- \r\nList footer\r\n")...)
+	seal(t, &hdr, body, res.Status, edKey, "example.com", "ed")
+
+	var buf bytes.Buffer
+	if err := textproto.WriteHeader(&buf, hdr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "ARC-Seal: i=2; a=ed25519-sha256; cv=pass;") {
+		t.Errorf("ARC-Seal is not on top:\n%s", buf.String())
+	}
+
+	res = verify(t, r, hdr, body)
+	if res.Status != StatusPass {
+		t.Fatalf("expected pass after second seal, got %s (%s)", res.Status, res.Reason)
+	}
+	if len(res.Sets) != 2 || res.Sets[1].ChainStatus != StatusPass || res.Sets[1].Domain != "example.com" {
+		t.Errorf("wrong sets: %+v", res.Sets)
+	}
+	if res.OldestPass != 2 {
+		t.Errorf("expected oldest-pass 2, got %d", res.OldestPass)
+	}
+
+	// Round trip through serialization.
+	buf.Write(body)
+	hdr2, body2 := readMsg(t, buf.String())
+	if res := verify(t, r, hdr2, body2); res.Status != StatusPass {
+		t.Fatalf("expected pass after re-parsing, got %s (%s)", res.Status, res.Reason)
+	}
+}
+
+func TestVerify_Modified(t *testing.T) {
+	rsaKey, _, r := testKeys(t)
+	hdr, body := readMsg(t, testMsg)
+	seal(t, &hdr, body, StatusNone, rsaKey, "example.net", "rsa")
+
+	res := verify(t, r, hdr, append(body, 'x'))
+	if res.Status != StatusFail || !strings.Contains(res.Reason, "body hash") {
+		t.Errorf("expected body hash failure, got %s (%s)", res.Status, res.Reason)
+	}
+
+	hdr.Set("Subject", "Changed")
+	res = verify(t, r, hdr, body)
+	if res.Status != StatusFail {
+		t.Errorf("expected failure for changed header, got %s", res.Status)
+	}
+}
+
+func TestVerify_TamperedSeal(t *testing.T) {
+	rsaKey, _, r := testKeys(t)
+	hdr, body := readMsg(t, testMsg)
+	seal(t, &hdr, body, StatusNone, rsaKey, "example.net", "rsa")
+
+	aar := hdr.Get("ARC-Authentication-Results")
+	hdr.Del("ARC-Authentication-Results")
+	hdr.Add("ARC-Authentication-Results", strings.Replace(aar, "dkim=pass", "dkim=fail", 1))
+	res := verify(t, r, hdr, body)
+	if res.Status != StatusFail || !strings.HasPrefix(res.Reason, "ARC-Seal 1") {
+		t.Errorf("expected seal failure, got %s (%s)", res.Status, res.Reason)
+	}
+
+	// Failed chain is sealed with cv=fail once and not extended further.
+	seal(t, &hdr, body, res.Status, rsaKey, "example.net", "rsa")
+	if res := verify(t, r, hdr, body); res.Status != StatusFail || res.Latest().ChainStatus != StatusFail {
+		t.Errorf("expected cv=fail chain, got %s", res.Status)
+	}
+	err := Seal(&hdr, bytes.NewReader(body), StatusFail, SealOptions{
+		Domain: "example.net", Selector: "rsa", Signer: rsaKey, HeaderKeys: testHeaderKeys,
+	})
+	if err != ErrNoSeal {
+		t.Errorf("expected ErrNoSeal, got %v", err)
+	}
+}
+
+func TestVerify_Structure(t *testing.T) {
+	test := func(fields string) {
+		t.Helper()
+		hdr, body := readMsg(t, fields+testMsg)
+		if res := verify(t, &mockdns.Resolver{}, hdr, body); res.Status != StatusFail {
+			t.Errorf("expected failure, got %s", res.Status)
+		}
+	}
+
+	// Incomplete set.
+	test("ARC-Seal: i=1; a=rsa-sha256; cv=none; d=example.net; s=rsa; b=AAAA\r\n")
+	// Missing instance 1.
+	test("ARC-Seal: i=2; a=rsa-sha256; cv=pass; d=example.net; s=rsa; b=AAAA\r\n" +
+		"ARC-Message-Signature: i=2; a=rsa-sha256; d=example.net; s=rsa; h=from; bh=AAAA; b=AAAA\r\n" +
+		"ARC-Authentication-Results: i=2; mx.example.net; none\r\n")
+	// Wrong cv for the first instance.
+	test("ARC-Seal: i=1; a=rsa-sha256; cv=pass; d=example.net; s=rsa; b=AAAA\r\n" +
+		"ARC-Message-Signature: i=1; a=rsa-sha256; d=example.net; s=rsa; h=from; bh=AAAA; b=AAAA\r\n" +
+		"ARC-Authentication-Results: i=1; mx.example.net; none\r\n")
+}
+
+func TestCanonBody(t *testing.T) {
+	test := func(canon, in, expected string) {
+		t.Helper()
+		out, err := canonBody(canon, strings.NewReader(in), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != expected {
+			t.Errorf("%s(%q) = %q, expected %q", canon, in, out, expected)
+		}
+	}
+
+	test(CanonSimple, "", "\r\n")
+	test(CanonSimple, "a \r\n\r\n\r\n", "a \r\n")
+	test(CanonRelaxed, "", "")
+	test(CanonRelaxed, " C \r\nD \t E\r\n\r\n\r\n", " C\r\nD E\r\n")
+	test(CanonRelaxed, "no newline", "no newline\r\n")
+}
@@ -0,0 +1,153 @@
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+const crlf = "\r\n"
+
+// Canonicalization algorithms defined in RFC 6376 Section 3.4.
+const (
+	CanonSimple  = "simple"
+	CanonRelaxed = "relaxed"
+)
+
+// field is a raw header field including the trailing CRLF and folding.
+type field string
+
+func (f field) key() string {
+	key, _, _ := strings.Cut(string(f), ":")
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+func (f field) value() string {
+	_, value, _ := strings.Cut(string(f), ":")
+	return value
+}
+
+// readFields splits the serialized header into raw fields, top to bottom.
+func readFields(hdr []byte) []field {
+	var fields []field
+	s := bufio.NewScanner(bytes.NewReader(hdr))
+	s.Buffer(nil, len(hdr)+1)
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" {
+			break
+		}
+		if len(fields) != 0 && (line[0] == ' ' || line[0] == '\t') {
+			fields[len(fields)-1] += field(line + crlf)
+			continue
+		}
+		fields = append(fields, field(line+crlf))
+	}
+	return fields
+}
+
+func canonHeader(canon string, f field) string {
+	if canon == CanonSimple {
+		return string(f)
+	}
+
+	key, value, _ := strings.Cut(string(f), ":")
+	value = strings.ReplaceAll(value, "\r", "")
+	value = strings.ReplaceAll(value, "\n", "")
+	return strings.ToLower(strings.TrimSpace(key)) + ":" + strings.TrimSpace(compressWSP(value)) + crlf
+}
+
+func compressWSP(s string) string {
+	var b strings.Builder
+	wsp := false
+	for _, ch := range s {
+		if ch == ' ' || ch == '\t' {
+			wsp = true
+			continue
+		}
+		if wsp {
+			b.WriteByte(' ')
+			wsp = false
+		}
+		b.WriteRune(ch)
+	}
+	if wsp {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// canonBody returns the canonicalized body, truncated to limit bytes if limit
+// is not negative.
+func canonBody(canon string, r io.Reader, limit int64) ([]byte, error) {
+	var (
+		out     bytes.Buffer
+		pending int // empty lines not yet written
+	)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if canon == CanonRelaxed {
+			line = strings.TrimRight(compressWSP(line), " ")
+		}
+		if line == "" {
+			pending++
+		} else {
+			for ; pending > 0; pending-- {
+				out.WriteString(crlf)
+			}
+			out.WriteString(line + crlf)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if out.Len() == 0 && canon == CanonSimple {
+		out.WriteString(crlf)
+	}
+
+	body := out.Bytes()
+	if limit >= 0 && int64(len(body)) > limit {
+		body = body[:limit]
+	}
+	return body, nil
+}
+
+// stripSignature removes the value of the b= tag from the raw signature
+// field, keeping everything else intact.
+func stripSignature(f field) string {
+	s := string(f)
+	colon := strings.IndexByte(s, ':')
+	if colon == -1 {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteString(s[:colon+1])
+	tags := strings.Split(s[colon+1:], ";")
+	for i, tag := range tags {
+		if i != 0 {
+			b.WriteByte(';')
+		}
+		name, _, ok := strings.Cut(tag, "=")
+		if ok && strings.TrimSpace(name) == "b" {
+			b.WriteString(tag[:strings.IndexByte(tag, '=')+1])
+			if i == len(tags)-1 {
+				b.WriteString(crlf)
+			}
+			continue
+		}
+		b.WriteString(tag)
+	}
+	return b.String()
+}
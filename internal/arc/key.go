@@ -0,0 +1,147 @@
+package arc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dsoftgames/MailChat/framework/dns"
+)
+
+// Resolver is the subset of dns.Resolver used to look up signing keys.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+type verifier interface {
+	verify(hashed, sig []byte) error
+}
+
+type rsaVerifier struct{ *rsa.PublicKey }
+
+func (v rsaVerifier) verify(hashed, sig []byte) error {
+	return rsa.VerifyPKCS1v15(v.PublicKey, crypto.SHA256, hashed, sig)
+}
+
+type ed25519Verifier struct{ ed25519.PublicKey }
+
+func (v ed25519Verifier) verify(hashed, sig []byte) error {
+	if !ed25519.Verify(v.PublicKey, hashed, sig) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// lookupKey fetches the public key published using the DKIM key record
+// format (RFC 6376 Section 3.6.1), ARC uses the same records.
+func lookupKey(ctx context.Context, r Resolver, algo, domain, selector string) (verifier, error) {
+	txts, err := r.LookupTXT(ctx, dns.FQDN(selector+"._domainkey."+domain))
+	if err != nil {
+		if dns.IsNotFound(err) {
+			return nil, permFail("no key for signature")
+		}
+		return nil, tempFail("key lookup failed: " + err.Error())
+	}
+	if len(txts) == 0 {
+		return nil, permFail("no key for signature")
+	}
+
+	tags, err := parseTags(strings.Join(txts, ""))
+	if err != nil {
+		return nil, permFail("malformed key record: " + err.Error())
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, permFail("incompatible key record version")
+	}
+	if h, ok := tags["h"]; ok && !containsTag(h, "sha256") {
+		return nil, permFail("key does not allow sha256")
+	}
+	if s, ok := tags["s"]; ok && !containsTag(s, "*") && !containsTag(s, "email") {
+		return nil, permFail("key is not for email use")
+	}
+
+	p := stripWSP(tags["p"])
+	if p == "" {
+		return nil, permFail("key is revoked")
+	}
+	blob, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, permFail("malformed public key: " + err.Error())
+	}
+
+	keyType := tags["k"]
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	if keyType+"-sha256" != algo {
+		return nil, permFail(fmt.Sprintf("key type %s does not match algorithm %s", keyType, algo))
+	}
+
+	switch keyType {
+	case "rsa":
+		var pub *rsa.PublicKey
+		if key, err := x509.ParsePKIXPublicKey(blob); err == nil {
+			rsaKey, ok := key.(*rsa.PublicKey)
+			if !ok {
+				return nil, permFail("not a RSA public key")
+			}
+			pub = rsaKey
+		} else if pub, err = x509.ParsePKCS1PublicKey(blob); err != nil {
+			return nil, permFail("malformed public key: " + err.Error())
+		}
+		if pub.N.BitLen() < 1024 {
+			return nil, permFail("key is too short")
+		}
+		return rsaVerifier{pub}, nil
+	case "ed25519":
+		if len(blob) != ed25519.PublicKeySize {
+			return nil, permFail("malformed public key")
+		}
+		return ed25519Verifier{ed25519.PublicKey(blob)}, nil
+	default:
+		return nil, permFail("unsupported key type: " + keyType)
+	}
+}
+
+func containsTag(list, value string) bool {
+	for _, v := range strings.Split(list, ":") {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// algorithmFor returns the signature algorithm name for the key.
+func algorithmFor(signer crypto.Signer) (string, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return "rsa-sha256", nil
+	case ed25519.PublicKey:
+		return "ed25519-sha256", nil
+	default:
+		return "", fmt.Errorf("arc: unsupported key type: %T", signer.Public())
+	}
+}
+
+func sign(signer crypto.Signer, data string) (string, error) {
+	hashed := sha256.Sum256([]byte(data))
+
+	opts := crypto.SignerOpts(crypto.SHA256)
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		opts = crypto.Hash(0)
+	}
+	sig, err := signer.Sign(rand.Reader, hashed[:], opts)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,182 @@
+package arc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+// ErrNoSeal is returned by Seal if the message cannot be sealed because its
+// chain is malformed, already failed or has the maximum amount of sets.
+var ErrNoSeal = errors.New("arc: chain cannot be extended")
+
+// SealOptions contains parameters of the new ARC set.
+type SealOptions struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer
+
+	// AuthServID and AuthResults form the ARC-Authentication-Results field:
+	// results of the authentication checks done by the sealer, formatted as
+	// for Authentication-Results, without the authserv-id.
+	AuthServID  string
+	AuthResults string
+
+	// HeaderKeys lists fields to sign with ARC-Message-Signature. Fields
+	// missing in the message are skipped, ARC fields are never signed.
+	HeaderKeys []string
+
+	// Time is the signature timestamp. Current time is used if it is zero.
+	Time time.Time
+}
+
+// Seal adds a new ARC set to the header (RFC 8617 Section 5.1). cv is the
+// chain validation status determined when the message was received, before
+// any modifications.
+func Seal(header *textproto.Header, body io.Reader, cv Status, opts SealOptions) error {
+	var hdrBuf bytes.Buffer
+	if err := textproto.WriteHeader(&hdrBuf, *header); err != nil {
+		return err
+	}
+	fields := readFields(hdrBuf.Bytes())
+
+	sets, err := collectSets(fields)
+	if err != nil {
+		return ErrNoSeal
+	}
+	if len(sets) != 0 && sets[len(sets)-1].ChainStatus == StatusFail {
+		return ErrNoSeal
+	}
+	instance := len(sets) + 1
+	if instance > MaxInstances {
+		return ErrNoSeal
+	}
+	switch {
+	case instance == 1:
+		cv = StatusNone
+	case cv != StatusPass:
+		cv = StatusFail
+	}
+
+	algo, err := algorithmFor(opts.Signer)
+	if err != nil {
+		return err
+	}
+	ts := opts.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	i := strconv.Itoa(instance)
+	t := strconv.FormatInt(ts.Unix(), 10)
+
+	authResults := "none"
+	if opts.AuthResults != "" {
+		authResults = opts.AuthResults
+	}
+	aar := field(formatField("ARC-Authentication-Results",
+		[]string{"i=" + i, opts.AuthServID}, authResults))
+
+	var keys []string
+	for _, key := range opts.HeaderKeys {
+		if strings.HasPrefix(strings.ToLower(key), "arc-") {
+			continue
+		}
+		for f := header.FieldsByKey(key); f.Next(); {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("arc: no fields to sign")
+	}
+
+	canonBodyBlob, err := canonBody(CanonRelaxed, body, -1)
+	if err != nil {
+		return err
+	}
+	bodyHash := sha256.Sum256(canonBodyBlob)
+
+	amsTags := []string{
+		"i=" + i, "a=" + algo, "c=relaxed/relaxed",
+		"d=" + opts.Domain, "s=" + opts.Selector, "t=" + t,
+		"h=" + strings.Join(keys, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+	}
+	var data strings.Builder
+	for _, f := range signedFields(fields, keys) {
+		data.WriteString(canonHeader(CanonRelaxed, f))
+	}
+	data.WriteString(strings.TrimSuffix(canonHeader(CanonRelaxed,
+		field(formatSignature("ARC-Message-Signature", amsTags, ""))), crlf))
+	sig, err := sign(opts.Signer, data.String())
+	if err != nil {
+		return err
+	}
+	ams := field(formatSignature("ARC-Message-Signature", amsTags, sig))
+
+	asTags := []string{
+		"i=" + i, "a=" + algo, "cv=" + string(cv),
+		"d=" + opts.Domain, "s=" + opts.Selector, "t=" + t,
+	}
+	sets = append(sets, Set{authResults: aar, signature: ams})
+	sig, err = sign(opts.Signer, sealData(sets, formatSignature("ARC-Seal", asTags, "")))
+	if err != nil {
+		return err
+	}
+	as := formatSignature("ARC-Seal", asTags, sig)
+
+	// AddRaw prepends, so the ARC-Seal ends up on the top.
+	header.AddRaw([]byte(aar))
+	header.AddRaw([]byte(ams))
+	header.AddRaw([]byte(as))
+	return nil
+}
+
+const maxLineLen = 76
+
+// formatField joins the field name and values separated with "; ", folding
+// long lines.
+func formatField(name string, values []string, last string) string {
+	var b strings.Builder
+	lineLen := len(name) + 1
+	b.WriteString(name + ":")
+	for i, v := range append(values, last) {
+		sep := " "
+		if lineLen+len(v)+2 > maxLineLen && i != 0 {
+			sep = crlf + "\t"
+			lineLen = 1
+		}
+		b.WriteString(sep + v)
+		lineLen += len(sep) + len(v)
+		if i != len(values) {
+			b.WriteString(";")
+			lineLen++
+		}
+	}
+	return b.String() + crlf
+}
+
+// formatSignature formats the signature field with the b= tag last and its
+// value folded. Value is omitted from the field used for signing.
+func formatSignature(name string, tags []string, sig string) string {
+	s := strings.TrimSuffix(formatField(name, tags, "b="), crlf)
+	for len(sig) != 0 {
+		n := maxLineLen - 8
+		if n > len(sig) {
+			n = len(sig)
+		}
+		if !strings.HasSuffix(s, "b=") {
+			s += crlf + "\t"
+		}
+		s += sig[:n]
+		sig = sig[n:]
+	}
+	return s + crlf
+}
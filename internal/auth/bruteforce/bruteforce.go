@@ -0,0 +1,344 @@
+// Package bruteforce tracks failed authentication attempts to slow down and
+// temporarily lock out password guessing.
+//
+// Failures are counted per client IP, per IPv6 /64 network and per
+// username. Each failure is followed by a delay that doubles with the
+// number of recent failures, and the client or username is locked out once
+// the number of failures in the window reaches the limit.
+//
+// The state is kept by the process-wide Default tracker used by
+// auth.SASLAuth, so it applies to all endpoints and authentication
+// providers.
+package bruteforce
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+)
+
+// Scopes of tracked failures.
+const (
+	ScopeIP   = "ip"
+	ScopeNet  = "net"
+	ScopeUser = "user"
+)
+
+// ErrLockedOut is returned by Check if the client or the username is locked
+// out.
+var ErrLockedOut = exterrors.WithTemporary(
+	errors.New("bruteforce: too many failed authentication attempts, try again later"), true)
+
+// sweepInterval is the minimal interval between removals of stale entries.
+const sweepInterval = 1 * time.Minute
+
+// Config is the configuration of the Tracker.
+type Config struct {
+	// Disabled turns off tracking, all attempts are allowed.
+	Disabled bool
+
+	// Window is the time after the last failure when failures are
+	// forgotten.
+	Window time.Duration
+
+	// Delay is the delay after the first failure, it doubles with each
+	// subsequent failure up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
+
+	// Lockout is the time the client or username is locked out for after
+	// reaching the limit of failures.
+	Lockout time.Duration
+
+	// Limits of failures in the window for each scope. Zero disables the
+	// lockout for the scope.
+	MaxIPFailures   int
+	MaxNetFailures  int
+	MaxUserFailures int
+
+	// Allow are the networks not subject to tracking.
+	Allow []net.IPNet
+}
+
+// DefaultConfig returns the configuration used if auth_bruteforce is not
+// specified.
+func DefaultConfig() Config {
+	cfg := Config{
+		Window:          15 * time.Minute,
+		Delay:           1 * time.Second,
+		MaxDelay:        30 * time.Second,
+		Lockout:         15 * time.Minute,
+		MaxIPFailures:   10,
+		MaxNetFailures:  50,
+		MaxUserFailures: 20,
+	}
+	for _, n := range []string{"127.0.0.0/8", "::1/128"} {
+		_, ipNet, _ := net.ParseCIDR(n)
+		cfg.Allow = append(cfg.Allow, *ipNet)
+	}
+	return cfg
+}
+
+// Entry is the state of the tracked client, network or username.
+type Entry struct {
+	Scope       string
+	Value       string
+	Failures    int
+	LastFailure time.Time
+
+	// LockedUntil is zero if the entry is not locked out.
+	LockedUntil time.Time
+}
+
+func (e Entry) locked(now time.Time) bool {
+	return e.LockedUntil.After(now)
+}
+
+type key struct {
+	scope string
+	value string
+}
+
+// Tracker keeps the failure counts.
+type Tracker struct {
+	Log log.Logger
+
+	lck       sync.Mutex
+	cfg       Config
+	entries   map[key]*Entry
+	lastSweep time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func New(cfg Config) *Tracker {
+	return &Tracker{
+		Log:     log.Logger{Name: "auth/bruteforce"},
+		cfg:     cfg,
+		entries: make(map[key]*Entry),
+		now:     time.Now,
+		sleep:   time.Sleep,
+	}
+}
+
+// Default is the tracker used for all authentication attempts.
+var Default = New(DefaultConfig())
+
+// Configure replaces the configuration keeping the tracked state.
+func (t *Tracker) Configure(cfg Config) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+	t.cfg = cfg
+}
+
+// keys returns the keys the attempt is tracked by. nil is returned if the
+// attempt is not tracked.
+func (t *Tracker) keys(addr net.Addr, username string) []key {
+	if t.cfg.Disabled {
+		return nil
+	}
+
+	var ip net.IP
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	}
+	// Connections over Unix sockets and attempts with unknown source are
+	// not tracked.
+	if ip == nil {
+		return nil
+	}
+	for _, allowed := range t.cfg.Allow {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+
+	keys := []key{{ScopeIP, ip.String()}}
+	if ip.To4() == nil {
+		netIP := ip.Mask(net.CIDRMask(64, 128))
+		keys = append(keys, key{ScopeNet, netIP.String() + "/64"})
+	}
+	if username != "" {
+		keys = append(keys, key{ScopeUser, strings.ToLower(username)})
+	}
+	return keys
+}
+
+func (t *Tracker) maxFailures(scope string) int {
+	switch scope {
+	case ScopeIP:
+		return t.cfg.MaxIPFailures
+	case ScopeNet:
+		return t.cfg.MaxNetFailures
+	case ScopeUser:
+		return t.cfg.MaxUserFailures
+	}
+	return 0
+}
+
+// entry returns the entry for the key, resetting it if the failures are
+// forgotten.
+func (t *Tracker) entry(k key, now time.Time, create bool) *Entry {
+	e, ok := t.entries[k]
+	if ok && !e.locked(now) && now.Sub(e.LastFailure) > t.cfg.Window {
+		delete(t.entries, k)
+		ok = false
+	}
+	if !ok && create {
+		e = &Entry{Scope: k.scope, Value: k.value}
+		t.entries[k] = e
+	}
+	return e
+}
+
+// Check returns ErrLockedOut if the client or the username is locked out.
+func (t *Tracker) Check(addr net.Addr, username string) error {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	now := t.now()
+	for _, k := range t.keys(addr, username) {
+		if e := t.entry(k, now, false); e != nil && e.locked(now) {
+			lockedAttempts.WithLabelValues(k.scope).Inc()
+			return ErrLockedOut
+		}
+	}
+	return nil
+}
+
+// Failed records the failed attempt and delays the caller.
+func (t *Tracker) Failed(addr net.Addr, username string) {
+	delay := t.failed(addr, username)
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
+
+func (t *Tracker) failed(addr net.Addr, username string) time.Duration {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	keys := t.keys(addr, username)
+	if len(keys) == 0 {
+		return 0
+	}
+
+	now := t.now()
+	t.sweep(now)
+
+	failures := 0
+	for _, k := range keys {
+		failedAttempts.WithLabelValues(k.scope).Inc()
+
+		e := t.entry(k, now, true)
+		e.Failures++
+		e.LastFailure = now
+		if e.Failures > failures {
+			failures = e.Failures
+		}
+
+		if max := t.maxFailures(k.scope); max != 0 && e.Failures >= max && !e.locked(now) {
+			e.LockedUntil = now.Add(t.cfg.Lockout)
+			lockouts.WithLabelValues(k.scope).Inc()
+			t.Log.Msg("locked out after failed authentication attempts",
+				"scope", k.scope, "value", k.value, "failures", e.Failures, "until", e.LockedUntil)
+		}
+	}
+
+	delay := t.cfg.Delay
+	for i := 1; i < failures && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	return delay
+}
+
+// Succeeded resets the failures of the username.
+func (t *Tracker) Succeeded(addr net.Addr, username string) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	for _, k := range t.keys(addr, username) {
+		if k.scope == ScopeUser {
+			delete(t.entries, k)
+		}
+	}
+}
+
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < sweepInterval {
+		return
+	}
+	t.lastSweep = now
+	for k := range t.entries {
+		t.entry(k, now, false)
+	}
+}
+
+// List returns the tracked entries. If lockedOnly is set, only entries that
+// are locked out are returned.
+func (t *Tracker) List(lockedOnly bool) []Entry {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	now := t.now()
+	var list []Entry
+	for k := range t.entries {
+		e := t.entry(k, now, false)
+		if e == nil || (lockedOnly && !e.locked(now)) {
+			continue
+		}
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Scope != list[j].Scope {
+			return list[i].Scope < list[j].Scope
+		}
+		return list[i].Value < list[j].Value
+	})
+	return list
+}
+
+// Clear removes the entries with the value in any scope, e.g. IP address,
+// network or username. All entries are removed if value is empty. It returns
+// the number of removed entries.
+func (t *Tracker) Clear(value string) int {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	count := 0
+	for k := range t.entries {
+		if value == "" || k.value == value || (k.scope == ScopeUser && k.value == strings.ToLower(value)) {
+			delete(t.entries, k)
+			count++
+		}
+	}
+	return count
+}
+
+// lockedCount returns the number of entries in the scope that are locked out.
+func (t *Tracker) lockedCount(scope string) int {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+
+	now := t.now()
+	count := 0
+	for k, e := range t.entries {
+		if k.scope == scope && e.locked(now) {
+			count++
+		}
+	}
+	return count
+}
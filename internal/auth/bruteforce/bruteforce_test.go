@@ -0,0 +1,116 @@
+package bruteforce
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+func testTracker(t *testing.T, cfg Config) (*Tracker, *time.Time, *[]time.Duration) {
+	now := time.Unix(1600000000, 0)
+	var delays []time.Duration
+
+	tr := New(cfg)
+	tr.Log = testutils.Logger(t, "bruteforce")
+	tr.now = func() time.Time { return now }
+	tr.sleep = func(d time.Duration) { delays = append(delays, d) }
+	return tr, &now, &delays
+}
+
+func addr(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}
+}
+
+func TestTracker_IPLockout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxIPFailures = 3
+	cfg.MaxDelay = 3 * time.Second
+	tr, now, delays := testTracker(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		if err := tr.Check(addr("192.0.2.1"), "user"+string(rune('a'+i))); err != nil {
+			t.Fatal("locked out too early:", err)
+		}
+		tr.Failed(addr("192.0.2.1"), "user"+string(rune('a'+i)))
+	}
+	want := []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second}
+	if len(*delays) != len(want) {
+		t.Fatal("wrong delays:", *delays)
+	}
+	for i := range want {
+		if (*delays)[i] != want[i] {
+			t.Fatal("wrong delays:", *delays)
+		}
+	}
+
+	if err := tr.Check(addr("192.0.2.1"), "other"); !errors.Is(err, ErrLockedOut) {
+		t.Fatal("IP is not locked out:", err)
+	}
+	if err := tr.Check(addr("192.0.2.2"), "other"); err != nil {
+		t.Fatal("other IP is locked out:", err)
+	}
+	if list := tr.List(true); len(list) != 1 || list[0].Scope != ScopeIP || list[0].Value != "192.0.2.1" {
+		t.Fatalf("wrong lockouts list: %+v", list)
+	}
+
+	*now = now.Add(cfg.Lockout + time.Second)
+	if err := tr.Check(addr("192.0.2.1"), "other"); err != nil {
+		t.Fatal("lockout is not expired:", err)
+	}
+}
+
+func TestTracker_UserAndNet(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxUserFailures = 2
+	cfg.MaxNetFailures = 3
+	tr, _, _ := testTracker(t, cfg)
+
+	// Different IPs in the same /64.
+	tr.Failed(addr("2001:db8::1"), "foo@example.org")
+	tr.Succeeded(addr("2001:db8::1"), "foo@example.org")
+	tr.Failed(addr("2001:db8::2"), "foo@example.org")
+	if err := tr.Check(addr("2001:db8::3"), "foo@example.org"); err != nil {
+		t.Fatal("success does not reset user failures:", err)
+	}
+	tr.Failed(addr("2001:db8::3"), "Foo@example.org")
+	if err := tr.Check(addr("192.0.2.1"), "foo@example.org"); !errors.Is(err, ErrLockedOut) {
+		t.Fatal("username is not locked out:", err)
+	}
+	if err := tr.Check(addr("2001:db8::4"), "bar@example.org"); !errors.Is(err, ErrLockedOut) {
+		t.Fatal("network is not locked out:", err)
+	}
+
+	if n := tr.Clear("2001:db8::/64"); n != 1 {
+		t.Fatal("wrong number of cleared entries:", n)
+	}
+	if err := tr.Check(addr("2001:db8::4"), "bar@example.org"); err != nil {
+		t.Fatal("network lockout is not cleared:", err)
+	}
+	tr.Clear("FOO@example.org")
+	if err := tr.Check(addr("192.0.2.1"), "foo@example.org"); err != nil {
+		t.Fatal("username lockout is not cleared:", err)
+	}
+}
+
+func TestTracker_NotTracked(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxIPFailures = 1
+	tr, _, delays := testTracker(t, cfg)
+
+	tr.Failed(addr("127.0.0.1"), "foo")
+	tr.Failed(nil, "foo")
+	tr.Failed(&net.UnixAddr{Name: "/run/auth.sock", Net: "unix"}, "foo")
+	if len(*delays) != 0 || len(tr.List(false)) != 0 {
+		t.Fatal("attempts from allowed or unknown addresses are tracked")
+	}
+
+	cfg.Disabled = true
+	tr.Configure(cfg)
+	tr.Failed(addr("192.0.2.1"), "foo")
+	if err := tr.Check(addr("192.0.2.1"), "foo"); err != nil {
+		t.Fatal("disabled tracker locks out:", err)
+	}
+}
@@ -0,0 +1,74 @@
+package bruteforce
+
+import (
+	"net"
+	"strings"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+)
+
+// ConfigDirective parses the auth_bruteforce directive:
+//
+//	auth_bruteforce off
+//	auth_bruteforce {
+//	    window 15m
+//	    delay 1s
+//	    max_delay 30s
+//	    lockout 15m
+//	    max_ip_failures 10
+//	    max_net_failures 50
+//	    max_user_failures 20
+//	    allow_networks 127.0.0.0/8 ::1
+//	}
+//
+// Omitted directives have default values.
+func ConfigDirective(m *config.Map, node config.Node) (interface{}, error) {
+	cfg := DefaultConfig()
+
+	if len(node.Args) == 1 && node.Args[0] == "off" {
+		if len(node.Children) != 0 {
+			return nil, config.NodeErr(node, "can't use block with 'off'")
+		}
+		cfg.Disabled = true
+		return cfg, nil
+	}
+	if len(node.Args) != 0 {
+		return nil, config.NodeErr(node, "unexpected arguments")
+	}
+
+	var allow []string
+	block := config.NewMap(m.Globals, node)
+	block.Duration("window", false, false, cfg.Window, &cfg.Window)
+	block.Duration("delay", false, false, cfg.Delay, &cfg.Delay)
+	block.Duration("max_delay", false, false, cfg.MaxDelay, &cfg.MaxDelay)
+	block.Duration("lockout", false, false, cfg.Lockout, &cfg.Lockout)
+	block.Int("max_ip_failures", false, false, cfg.MaxIPFailures, &cfg.MaxIPFailures)
+	block.Int("max_net_failures", false, false, cfg.MaxNetFailures, &cfg.MaxNetFailures)
+	block.Int("max_user_failures", false, false, cfg.MaxUserFailures, &cfg.MaxUserFailures)
+	block.StringList("allow_networks", false, false, []string{"127.0.0.0/8", "::1/128"}, &allow)
+	if _, err := block.Process(); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxDelay < cfg.Delay {
+		return nil, config.NodeErr(node, "max_delay should not be less than delay")
+	}
+
+	cfg.Allow = nil
+	for _, n := range allow {
+		if !strings.Contains(n, "/") {
+			if strings.Contains(n, ":") {
+				n += "/128"
+			} else {
+				n += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, config.NodeErr(node, "%v", err)
+		}
+		cfg.Allow = append(cfg.Allow, *ipNet)
+	}
+
+	return cfg, nil
+}
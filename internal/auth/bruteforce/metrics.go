@@ -0,0 +1,54 @@
+package bruteforce
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	failedAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "mailcoin",
+			Subsystem: "auth",
+			Name:      "failed_attempts",
+			Help:      "Failed authentication attempts counted for brute-force protection",
+		},
+		[]string{"scope"},
+	)
+	lockouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "mailcoin",
+			Subsystem: "auth",
+			Name:      "lockouts",
+			Help:      "Clients and usernames locked out after too many failed attempts",
+		},
+		[]string{"scope"},
+	)
+	lockedAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "mailcoin",
+			Subsystem: "auth",
+			Name:      "locked_attempts",
+			Help:      "Authentication attempts rejected due to lockout",
+		},
+		[]string{"scope"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(failedAttempts)
+	prometheus.MustRegister(lockouts)
+	prometheus.MustRegister(lockedAttempts)
+	for _, scope := range []string{ScopeIP, ScopeNet, ScopeUser} {
+		scope := scope
+		prometheus.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace:   "mailcoin",
+				Subsystem:   "auth",
+				Name:        "locked_out",
+				Help:        "Clients and usernames currently locked out",
+				ConstLabels: prometheus.Labels{"scope": scope},
+			},
+			func() float64 {
+				return float64(Default.lockedCount(scope))
+			},
+		))
+	}
+}
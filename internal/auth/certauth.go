@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var ErrNoClientCert = errors.New("auth: no verified client certificate")
+
+// CertIdentityKeys returns the keys the certificate is looked up by in the
+// identity table, in order of preference: e-mail addresses, DNS names and
+// URIs from subjectAltName, subject common name and the full subject DN
+// (e.g. "CN=printer,O=Example").
+func CertIdentityKeys(cert *x509.Certificate) []string {
+	var keys []string
+	keys = append(keys, cert.EmailAddresses...)
+	keys = append(keys, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		keys = append(keys, uri.String())
+	}
+	if cert.Subject.CommonName != "" {
+		keys = append(keys, cert.Subject.CommonName)
+	}
+	if dn := cert.Subject.String(); dn != "" {
+		keys = append(keys, dn)
+	}
+	return keys
+}
+
+// AuthExternal returns the username mapped from the client certificate
+// verified during the TLS handshake using CertIdentities table. If authzid is
+// not empty, it must match the username.
+func (s *SASLAuth) AuthExternal(ctx context.Context, tlsState *tls.ConnectionState, authzid string) (string, error) {
+	if s.CertIdentities == nil {
+		return "", ErrUnsupportedMech
+	}
+	if tlsState == nil || len(tlsState.VerifiedChains) == 0 || len(tlsState.VerifiedChains[0]) == 0 {
+		return "", ErrNoClientCert
+	}
+	cert := tlsState.VerifiedChains[0][0]
+
+	for _, key := range CertIdentityKeys(cert) {
+		username, ok, err := s.CertIdentities.Lookup(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+
+		s.Log.DebugMsg("client certificate mapped", "key", key, "username", username)
+
+		if authzid != "" {
+			if s.AuthNormalize != nil {
+				authzid, err = s.AuthNormalize(authzid)
+				if err != nil {
+					return "", err
+				}
+				username, err = s.AuthNormalize(username)
+				if err != nil {
+					return "", err
+				}
+			}
+			if authzid != username {
+				return "", fmt.Errorf("auth: certificate is mapped to %s, not %s", username, authzid)
+			}
+		}
+		return username, nil
+	}
+
+	return "", fmt.Errorf("auth: certificate %s is not mapped to a username", cert.Subject)
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	tls2 "github.com/dsoftgames/MailChat/framework/config/tls"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+func issueCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (tls.Certificate, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert, key
+}
+
+// handshake returns the state of the server side of the TLS connection with
+// the client presenting clientCert.
+func handshake(t *testing.T, serverCfg *tls.Config, clientCert *tls.Certificate) (*tls.ConnectionState, error) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	clientCfg := &tls.Config{InsecureSkipVerify: true}
+	if clientCert != nil {
+		// Send the certificate even if it is not issued by the CA
+		// requested by the server.
+		clientCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return clientCert, nil
+		}
+	}
+	go func() {
+		c := tls.Client(clientConn, clientCfg)
+		_ = c.Handshake()
+		// Let the server finish reading the client certificate.
+		buf := make([]byte, 1)
+		_, _ = c.Read(buf)
+	}()
+
+	srv := tls.Server(serverConn, serverCfg)
+	if err := srv.Handshake(); err != nil {
+		return nil, err
+	}
+	state := srv.ConnectionState()
+	return &state, nil
+}
+
+func TestAuthExternal(t *testing.T) {
+	_, ca, caKey := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+
+	serverCert, _, _ := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mx.example.org"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil, nil)
+	printerCert, _, _ := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "printer", Organization: []string{"Example"}},
+		DNSNames:     []string{"printer.example.org"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	unknownCert, _, _ := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "scanner"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	selfSigned, _, _ := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(5),
+		Subject:      pkix.Name{CommonName: "printer"},
+		DNSNames:     []string{"printer.example.org"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil, nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	clientAuth := &tls2.ClientAuth{
+		CAs: pool,
+		Identities: testutils.Table{M: map[string]string{
+			"printer.example.org": "printer@example.org",
+		}},
+	}
+	serverCfg := clientAuth.Apply(&tls.Config{Certificates: []tls.Certificate{serverCert}})
+
+	a := SASLAuth{
+		Log:            testutils.Logger(t, "saslauth"),
+		CertIdentities: clientAuth.Identities,
+	}
+
+	authenticate := func(state *tls.ConnectionState, authzid string) (string, error) {
+		var identity string
+		srv := a.CreateSASLConn(context.Background(), "EXTERNAL", &net.TCPAddr{}, state, func(id string, _ ContextData) error {
+			identity = id
+			return nil
+		})
+		_, _, err := srv.Next([]byte(authzid))
+		return identity, err
+	}
+
+	state, err := handshake(t, serverCfg, &printerCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := authenticate(state, ""); err != nil || id != "printer@example.org" {
+		t.Fatal("Unexpected result:", id, err)
+	}
+	if id, err := authenticate(state, "printer@example.org"); err != nil || id != "printer@example.org" {
+		t.Fatal("Unexpected result with authzid:", id, err)
+	}
+	if _, err := authenticate(state, "admin@example.org"); err == nil {
+		t.Fatal("No error for authzid mismatch")
+	}
+
+	state, err = handshake(t, serverCfg, &unknownCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(state, ""); err == nil {
+		t.Fatal("No error for unmapped certificate")
+	}
+
+	state, err = handshake(t, serverCfg, nil)
+	if err != nil {
+		t.Fatal("Certificate is required:", err)
+	}
+	if _, err := authenticate(state, ""); err == nil {
+		t.Fatal("No error without certificate")
+	}
+
+	if _, err := handshake(t, serverCfg, &selfSigned); err == nil {
+		t.Fatal("Certificate not signed by CA is accepted")
+	}
+
+	clientAuth.Required = true
+	if _, err := handshake(t, clientAuth.Apply(&tls.Config{Certificates: []tls.Certificate{serverCert}}), nil); err == nil {
+		t.Fatal("Handshake without required certificate succeeded")
+	}
+}
@@ -0,0 +1,194 @@
+// Package jwt implements auth.jwt module that authenticates users using JSON
+// Web Tokens issued by an OAuth 2.0 / OpenID Connect identity provider.
+//
+// Tokens are accepted via OAUTHBEARER and XOAUTH2 SASL mechanisms. The
+// signature is verified using keys from a JWKS document, "iss", "aud", "exp"
+// and "nbf" claims are checked and the username is taken from the configured
+// claim, optionally translated using a table.
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+)
+
+const modName = "auth.jwt"
+
+func init() {
+	var _ module.TokenAuth = &Auth{}
+	module.Register(modName, New)
+}
+
+var defaultAlgorithms = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+type Auth struct {
+	instName string
+	log      log.Logger
+
+	keys          *keySource
+	algorithms    []jose.SignatureAlgorithm
+	issuer        string
+	audience      []string
+	usernameClaim string
+	usernameTable module.Table
+	clockSkew     time.Duration
+}
+
+func New(_, instName string, _, _ []string) (module.Module, error) {
+	return &Auth{
+		instName: instName,
+		log:      log.Logger{Name: modName},
+	}, nil
+}
+
+func (a *Auth) Init(cfg *config.Map) error {
+	var (
+		jwksURL    string
+		jwksFile   string
+		algorithms []string
+	)
+	a.keys = &keySource{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	cfg.Bool("debug", true, false, &a.log.Debug)
+	cfg.String("jwks_url", false, false, "", &jwksURL)
+	cfg.String("jwks_file", false, false, "", &jwksFile)
+	cfg.Duration("jwks_refresh", false, false, 1*time.Hour, &a.keys.refresh)
+	cfg.StringList("algorithms", false, false, defaultAlgorithms, &algorithms)
+	cfg.String("issuer", false, true, "", &a.issuer)
+	cfg.StringList("audience", false, true, nil, &a.audience)
+	cfg.String("username_claim", false, false, "email", &a.usernameClaim)
+	modconfig.Table(cfg, "username_table", false, false, nil, &a.usernameTable)
+	cfg.Duration("clock_skew", false, false, 1*time.Minute, &a.clockSkew)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+	a.keys.log = a.log
+
+	switch {
+	case jwksURL != "" && jwksFile != "":
+		return fmt.Errorf("%s: jwks_url and jwks_file can't be used together", modName)
+	case jwksURL != "":
+		if !strings.HasPrefix(jwksURL, "https://") && !strings.HasPrefix(jwksURL, "http://") {
+			return fmt.Errorf("%s: jwks_url should be a HTTP(S) URL", modName)
+		}
+		a.keys.url = jwksURL
+	case jwksFile != "":
+		keys, err := loadKeyFile(jwksFile)
+		if err != nil {
+			return fmt.Errorf("%s: jwks_file: %w", modName, err)
+		}
+		a.keys.keys = keys
+	default:
+		return fmt.Errorf("%s: jwks_url or jwks_file is required", modName)
+	}
+
+	for _, alg := range algorithms {
+		a.algorithms = append(a.algorithms, jose.SignatureAlgorithm(alg))
+	}
+	// Check names early, ParseSigned does not distinguish unknown algorithms
+	// from unexpected ones.
+	for _, alg := range a.algorithms {
+		if !knownAlgorithm(alg) {
+			return fmt.Errorf("%s: unknown signature algorithm: %s", modName, alg)
+		}
+	}
+
+	return nil
+}
+
+func knownAlgorithm(alg jose.SignatureAlgorithm) bool {
+	switch alg {
+	case jose.EdDSA, jose.HS256, jose.HS384, jose.HS512,
+		jose.RS256, jose.RS384, jose.RS512,
+		jose.ES256, jose.ES384, jose.ES512,
+		jose.PS256, jose.PS384, jose.PS512:
+		return true
+	}
+	return false
+}
+
+func (a *Auth) Name() string {
+	return modName
+}
+
+func (a *Auth) InstanceName() string {
+	return a.instName
+}
+
+func (a *Auth) AuthToken(ctx context.Context, token string) (string, error) {
+	tok, err := josejwt.ParseSigned(token, a.algorithms)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", modName, err)
+	}
+	if len(tok.Headers) == 0 {
+		return "", fmt.Errorf("%s: no signature", modName)
+	}
+	kid := tok.Headers[0].KeyID
+
+	keys, err := a.keys.get(ctx, kid)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", modName, err)
+	}
+
+	var (
+		claims josejwt.Claims
+		custom map[string]interface{}
+	)
+	verified := false
+	for _, key := range candidates(keys, kid) {
+		if err := tok.Claims(key.Key, &claims, &custom); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", fmt.Errorf("%s: signature verification failed (kid %q)", modName, kid)
+	}
+
+	if claims.Expiry == nil {
+		return "", fmt.Errorf("%s: token has no expiration time", modName)
+	}
+	err = claims.ValidateWithLeeway(josejwt.Expected{
+		Issuer:      a.issuer,
+		AnyAudience: a.audience,
+		Time:        time.Now(),
+	}, a.clockSkew)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", modName, err)
+	}
+
+	value, ok := custom[a.usernameClaim].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%s: token has no %s claim", modName, a.usernameClaim)
+	}
+
+	if a.usernameTable == nil {
+		return value, nil
+	}
+	username, ok, err := a.usernameTable.Lookup(ctx, value)
+	if err != nil {
+		return "", fmt.Errorf("%s: username lookup: %w", modName, err)
+	}
+	if !ok {
+		return "", fmt.Errorf("%s: %s %s is not mapped to a username: %w",
+			modName, a.usernameClaim, value, module.ErrUnknownCredentials)
+	}
+	return username, nil
+}
@@ -0,0 +1,261 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	"github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+)
+
+type testKey struct {
+	kid  string
+	priv *ecdsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, priv: priv}
+}
+
+func keySetJSON(t *testing.T, keys ...testKey) []byte {
+	t.Helper()
+	set := jose.JSONWebKeySet{}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       &k.priv.PublicKey,
+			KeyID:     k.kid,
+			Algorithm: string(jose.ES256),
+			Use:       "sig",
+		})
+	}
+	blob, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+func (k testKey) sign(t *testing.T, claims josejwt.Claims, extra map[string]interface{}) string {
+	t.Helper()
+	opts := &jose.SignerOptions{}
+	if k.kid != "" {
+		opts = opts.WithHeader(jose.HeaderKey("kid"), k.kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: k.priv}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := josejwt.Signed(signer).Claims(claims).Claims(extra).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func validClaims() josejwt.Claims {
+	now := time.Now()
+	return josejwt.Claims{
+		Issuer:   "https://idp.example.org",
+		Audience: josejwt.Audience{"mail"},
+		Expiry:   josejwt.NewNumericDate(now.Add(time.Hour)),
+		IssuedAt: josejwt.NewNumericDate(now),
+	}
+}
+
+func initAuth(t *testing.T, children ...config.Node) *Auth {
+	t.Helper()
+	mod, err := New(modName, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := mod.(*Auth)
+	a.log = testutils.Logger(t, modName)
+
+	children = append(children,
+		config.Node{Name: "issuer", Args: []string{"https://idp.example.org"}},
+		config.Node{Name: "audience", Args: []string{"mail", "other"}},
+	)
+	if err := a.Init(config.NewMap(nil, config.Node{Children: children})); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+type staticTable map[string]string
+
+func (t staticTable) Lookup(_ context.Context, key string) (string, bool, error) {
+	val, ok := t[key]
+	return val, ok, nil
+}
+
+func TestAuthToken(t *testing.T) {
+	key := newTestKey(t, "key1")
+	other := newTestKey(t, "key1")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, keySetJSON(t, key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := initAuth(t, config.Node{Name: "jwks_file", Args: []string{path}})
+
+	email := map[string]interface{}{"email": "foo@example.org"}
+
+	username, err := a.AuthToken(context.Background(), key.sign(t, validClaims(), email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "foo@example.org" {
+		t.Fatal("Wrong username:", username)
+	}
+
+	expired := validClaims()
+	expired.Expiry = josejwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := validClaims()
+	noExpiry.Expiry = nil
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "https://evil.example.org"
+	wrongAudience := validClaims()
+	wrongAudience.Audience = josejwt.Audience{"web"}
+
+	for name, token := range map[string]string{
+		"expired":         key.sign(t, expired, email),
+		"no expiry":       key.sign(t, noExpiry, email),
+		"wrong issuer":    key.sign(t, wrongIssuer, email),
+		"wrong audience":  key.sign(t, wrongAudience, email),
+		"no claim":        key.sign(t, validClaims(), map[string]interface{}{"sub": "123"}),
+		"wrong signature": other.sign(t, validClaims(), email),
+		"malformed":       "not.a.token",
+	} {
+		if _, err := a.AuthToken(context.Background(), token); err == nil {
+			t.Error("No error for", name, "token")
+		}
+	}
+
+	// Single key in the set is used for tokens without key ID.
+	noKid := testKey{priv: key.priv}
+	if _, err := a.AuthToken(context.Background(), noKid.sign(t, validClaims(), email)); err != nil {
+		t.Error("Token without kid is rejected:", err)
+	}
+
+	a.usernameTable = staticTable{"foo@example.org": "foo"}
+	username, err = a.AuthToken(context.Background(), key.sign(t, validClaims(), email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "foo" {
+		t.Fatal("Wrong mapped username:", username)
+	}
+	_, err = a.AuthToken(context.Background(), key.sign(t, validClaims(), map[string]interface{}{"email": "bar@example.org"}))
+	if !errors.Is(err, module.ErrUnknownCredentials) {
+		t.Fatal("Unexpected error for unmapped username:", err)
+	}
+}
+
+func TestAuthToken_JWKSURL(t *testing.T) {
+	key1 := newTestKey(t, "key1")
+	key2 := newTestKey(t, "key2")
+
+	var (
+		rotated  atomic.Bool
+		requests atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if rotated.Load() {
+			w.Write(keySetJSON(t, key1, key2))
+			return
+		}
+		w.Write(keySetJSON(t, key1))
+	}))
+	defer srv.Close()
+
+	a := initAuth(t,
+		config.Node{Name: "jwks_url", Args: []string{srv.URL}},
+		config.Node{Name: "username_claim", Args: []string{"preferred_username"}},
+	)
+	claims := map[string]interface{}{"preferred_username": "foo"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := a.AuthToken(context.Background(), key1.sign(t, validClaims(), claims)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatal("Key set is not cached, requests:", n)
+	}
+
+	// New key is picked up without waiting for the refresh.
+	rotated.Store(true)
+	a.keys.lastAttempt = time.Time{}
+	if _, err := a.AuthToken(context.Background(), key2.sign(t, validClaims(), claims)); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatal("Key set is not fetched for unknown key, requests:", n)
+	}
+}
+
+func TestAuthToken_SlowJWKS(t *testing.T) {
+	key1 := newTestKey(t, "key1")
+	key2 := newTestKey(t, "key2")
+
+	var requests atomic.Int32
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			<-unblock
+		}
+		w.Write(keySetJSON(t, key1))
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	a := initAuth(t,
+		config.Node{Name: "jwks_url", Args: []string{srv.URL}},
+		config.Node{Name: "username_claim", Args: []string{"preferred_username"}},
+	)
+	claims := map[string]interface{}{"preferred_username": "foo"}
+
+	if _, err := a.AuthToken(context.Background(), key1.sign(t, validClaims(), claims)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Token signed by the unknown key waits for the download that does not
+	// complete, other tokens are verified using the cached keys meanwhile.
+	a.keys.lastAttempt = time.Time{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := a.AuthToken(ctx, key2.sign(t, validClaims(), claims)); err == nil {
+		t.Fatal("token signed by the unknown key is accepted")
+	}
+
+	a.keys.lck.Lock()
+	a.keys.fetched = time.Time{}
+	a.keys.lck.Unlock()
+	start := time.Now()
+	if _, err := a.AuthToken(context.Background(), key1.sign(t, validClaims(), claims)); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("verification is blocked by the key set download")
+	}
+}
@@ -0,0 +1,169 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/go-jose/go-jose/v4"
+)
+
+// minRefetchInterval limits the rate of JWKS downloads caused by tokens signed
+// by unknown keys.
+const minRefetchInterval = 1 * time.Minute
+
+// maxJWKSSize is the maximum size of the downloaded key set.
+const maxJWKSSize = 1024 * 1024
+
+// keySource provides the keys used to verify token signatures.
+//
+// Keys from the URL are downloaded on the first use and after the refresh
+// interval. If the token is signed by an unknown key, they are downloaded
+// again, so rotated keys are picked up without waiting for the refresh. The
+// previously downloaded keys are used if the download fails.
+//
+// Downloads run in the background, only callers that have no usable keys
+// wait for them.
+type keySource struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	log     log.Logger
+
+	lck         sync.Mutex
+	keys        *jose.JSONWebKeySet
+	fetched     time.Time
+	lastAttempt time.Time
+	lastErr     error
+	// fetching is closed once the running download finishes, nil if there
+	// is none.
+	fetching chan struct{}
+}
+
+func loadKeyFile(path string) (*jose.JSONWebKeySet, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeySet(blob)
+}
+
+func parseKeySet(blob []byte) (*jose.JSONWebKeySet, error) {
+	keys := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(blob, keys); err != nil {
+		return nil, fmt.Errorf("malformed key set: %w", err)
+	}
+	if len(keys.Keys) == 0 {
+		return nil, fmt.Errorf("key set is empty")
+	}
+	return keys, nil
+}
+
+func (s *keySource) fetch(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	blob, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseKeySet(blob)
+}
+
+// get returns the key set that is expected to contain the key with the
+// specified ID.
+func (s *keySource) get(ctx context.Context, kid string) (*jose.JSONWebKeySet, error) {
+	s.lck.Lock()
+
+	// Keys loaded from the file.
+	if s.url == "" {
+		s.lck.Unlock()
+		return s.keys, nil
+	}
+
+	now := time.Now()
+	missing := s.keys == nil || (kid != "" && len(s.keys.Key(kid)) == 0)
+	if (missing || now.Sub(s.fetched) > s.refresh) &&
+		s.fetching == nil && now.Sub(s.lastAttempt) >= minRefetchInterval {
+		s.lastAttempt = now
+		s.fetching = make(chan struct{})
+		go s.download(s.fetching)
+	}
+	keys, fetching := s.keys, s.fetching
+	s.lck.Unlock()
+
+	// Expired keys are used until the new ones are downloaded.
+	if !missing || fetching == nil {
+		if keys == nil {
+			return nil, exterrors.WithTemporary(fmt.Errorf("key set is not available"), true)
+		}
+		return keys, nil
+	}
+
+	select {
+	case <-fetching:
+	case <-ctx.Done():
+		if keys == nil {
+			return nil, exterrors.WithTemporary(fmt.Errorf("fetch key set: %w", ctx.Err()), true)
+		}
+		return keys, nil
+	}
+
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	if s.keys == nil {
+		return nil, exterrors.WithTemporary(fmt.Errorf("fetch key set: %w", s.lastErr), true)
+	}
+	return s.keys, nil
+}
+
+// download fetches the key set and closes done once it is stored.
+func (s *keySource) download(done chan struct{}) {
+	keys, err := s.fetch(context.Background())
+
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	defer close(done)
+	s.fetching = nil
+
+	if err != nil {
+		s.lastErr = err
+		s.log.Error("failed to fetch key set", err, "url", s.url)
+		return
+	}
+	s.log.DebugMsg("fetched key set", "url", s.url, "keys", len(keys.Keys))
+	s.keys = keys
+	s.fetched = time.Now()
+}
+
+// candidates returns the keys that can be used to verify the token signed
+// using the key with the specified ID. Tokens without the key ID are accepted
+// only if the set contains a single key.
+func candidates(keys *jose.JSONWebKeySet, kid string) []jose.JSONWebKey {
+	if kid == "" {
+		if len(keys.Keys) == 1 {
+			return keys.Keys
+		}
+		return nil
+	}
+	return keys.Key(kid)
+}
@@ -20,6 +20,7 @@ package auth
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -27,9 +28,12 @@ import (
 	"github.com/emersion/go-sasl"
 	"github.com/dsoftgames/MailChat/framework/config"
 	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
 	"github.com/dsoftgames/MailChat/framework/log"
 	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth/bruteforce"
 	"github.com/dsoftgames/MailChat/internal/auth/sasllogin"
+	"github.com/dsoftgames/MailChat/internal/auth/sasloauth"
 	"github.com/dsoftgames/MailChat/internal/authz"
 )
 
@@ -55,6 +59,11 @@ type SASLAuth struct {
 	AuthNormalize authz.NormalizeFunc
 
 	Plain []module.PlainAuth
+	Token []module.TokenAuth
+
+	// CertIdentities enables EXTERNAL mechanism that authenticates the
+	// client using the TLS client certificate, see AuthExternal.
+	CertIdentities module.Table
 }
 
 func (s *SASLAuth) SASLMechanisms() []string {
@@ -66,6 +75,12 @@ func (s *SASLAuth) SASLMechanisms() []string {
 			mechs = append(mechs, sasl.Login)
 		}
 	}
+	if len(s.Token) != 0 {
+		mechs = append(mechs, sasloauth.OAuthBearer, sasloauth.XOAuth2)
+	}
+	if s.CertIdentities != nil {
+		mechs = append(mechs, sasl.External)
+	}
 
 	return mechs
 }
@@ -98,11 +113,32 @@ func (s *SASLAuth) usernameForAuth(ctx context.Context, saslUsername string) (st
 	return mapped, nil
 }
 
-func (s *SASLAuth) AuthPlain(username, password string) error {
+// AuthPlain checks the credentials using the configured providers.
+//
+// Failed attempts are tracked by bruteforce.Default, the caller is delayed
+// after a failure and locked out clients get an error without checking the
+// credentials.
+func (s *SASLAuth) AuthPlain(remoteAddr net.Addr, username, password string) error {
 	if len(s.Plain) == 0 {
 		return ErrUnsupportedMech
 	}
 
+	if err := bruteforce.Default.Check(remoteAddr, username); err != nil {
+		return err
+	}
+
+	err := s.authPlain(username, password)
+	if err != nil {
+		if !exterrors.IsTemporary(err) {
+			bruteforce.Default.Failed(remoteAddr, username)
+		}
+		return err
+	}
+	bruteforce.Default.Succeeded(remoteAddr, username)
+	return nil
+}
+
+func (s *SASLAuth) authPlain(username, password string) error {
 	var lastErr error
 	for _, p := range s.Plain {
 		mappedUsername, err := s.usernameForAuth(context.TODO(), username)
@@ -123,6 +159,63 @@ func (s *SASLAuth) AuthPlain(username, password string) error {
 	return fmt.Errorf("no auth. provider accepted creds, last err: %w", lastErr)
 }
 
+// AuthToken checks the bearer token using the configured providers and
+// returns the username it was issued for. If authzid is not empty, it must
+// match the username.
+//
+// Failed attempts are tracked by bruteforce.Default per client address.
+func (s *SASLAuth) AuthToken(ctx context.Context, remoteAddr net.Addr, authzid, token string) (string, error) {
+	if len(s.Token) == 0 {
+		return "", ErrUnsupportedMech
+	}
+
+	if err := bruteforce.Default.Check(remoteAddr, ""); err != nil {
+		return "", err
+	}
+
+	username, err := s.authToken(ctx, authzid, token)
+	if err != nil {
+		if !exterrors.IsTemporary(err) {
+			bruteforce.Default.Failed(remoteAddr, "")
+		}
+		return "", err
+	}
+	bruteforce.Default.Succeeded(remoteAddr, username)
+	return username, nil
+}
+
+func (s *SASLAuth) authToken(ctx context.Context, authzid, token string) (string, error) {
+	var lastErr error
+	for _, p := range s.Token {
+		var username string
+		username, lastErr = p.AuthToken(ctx, token)
+		if lastErr != nil {
+			s.Log.DebugMsg("token rejected", "module", p, "reason", lastErr)
+			continue
+		}
+
+		if authzid != "" {
+			if s.AuthNormalize != nil {
+				var err error
+				authzid, err = s.AuthNormalize(authzid)
+				if err != nil {
+					return "", err
+				}
+				username, err = s.AuthNormalize(username)
+				if err != nil {
+					return "", err
+				}
+			}
+			if authzid != username {
+				return "", fmt.Errorf("auth: token is issued for %s, not %s", username, authzid)
+			}
+		}
+		return username, nil
+	}
+
+	return "", fmt.Errorf("no auth. provider accepted token, last err: %w", lastErr)
+}
+
 type ContextData struct {
 	// Authentication username. May be different from identity.
 	Username string
@@ -133,6 +226,13 @@ type ContextData struct {
 
 // CreateSASL creates the sasl.Server instance for the corresponding mechanism.
 func (s *SASLAuth) CreateSASL(mech string, remoteAddr net.Addr, successCb func(identity string, data ContextData) error) sasl.Server {
+	return s.CreateSASLConn(context.Background(), mech, remoteAddr, nil, successCb)
+}
+
+// CreateSASLConn is like CreateSASL, but also takes the context of the
+// connection used for lookups and the state of the TLS connection used by
+// EXTERNAL mechanism. tlsState is nil if TLS is not used.
+func (s *SASLAuth) CreateSASLConn(ctx context.Context, mech string, remoteAddr net.Addr, tlsState *tls.ConnectionState, successCb func(identity string, data ContextData) error) sasl.Server {
 	switch mech {
 	case sasl.Plain:
 		return sasl.NewPlainServer(func(identity, username, password string) error {
@@ -143,7 +243,7 @@ func (s *SASLAuth) CreateSASL(mech string, remoteAddr net.Addr, successCb func(i
 				return ErrInvalidAuthCred
 			}
 
-			err := s.AuthPlain(username, password)
+			err := s.AuthPlain(remoteAddr, username, password)
 			if err != nil {
 				s.Log.Error("authentication failed", err, "username", username, "src_ip", remoteAddr)
 				return ErrInvalidAuthCred
@@ -165,7 +265,7 @@ func (s *SASLAuth) CreateSASL(mech string, remoteAddr net.Addr, successCb func(i
 				return err
 			}
 
-			err = s.AuthPlain(username, password)
+			err = s.AuthPlain(remoteAddr, username, password)
 			if err != nil {
 				s.Log.Error("authentication failed", err, "username", username, "src_ip", remoteAddr)
 				return ErrInvalidAuthCred
@@ -176,6 +276,42 @@ func (s *SASLAuth) CreateSASL(mech string, remoteAddr net.Addr, successCb func(i
 				Password: password,
 			})
 		})
+	case sasloauth.OAuthBearer, sasloauth.XOAuth2:
+		if len(s.Token) == 0 {
+			return FailingSASLServ{Err: ErrUnsupportedMech}
+		}
+
+		authenticate := func(authzid, token string) error {
+			username, err := s.AuthToken(ctx, remoteAddr, authzid, token)
+			if err != nil {
+				s.Log.Error("authentication failed", err, "mech", mech, "username", authzid, "src_ip", remoteAddr)
+				return ErrInvalidAuthCred
+			}
+
+			return successCb(username, ContextData{
+				Username: username,
+			})
+		}
+		if mech == sasloauth.XOAuth2 {
+			return sasloauth.NewXOAuth2Server(authenticate)
+		}
+		return sasloauth.NewOAuthBearerServer(authenticate)
+	case sasl.External:
+		if s.CertIdentities == nil {
+			return FailingSASLServ{Err: ErrUnsupportedMech}
+		}
+
+		return sasl.NewExternalServer(func(authzid string) error {
+			username, err := s.AuthExternal(ctx, tlsState, authzid)
+			if err != nil {
+				s.Log.Error("authentication failed", err, "mech", mech, "username", authzid, "src_ip", remoteAddr)
+				return ErrInvalidAuthCred
+			}
+
+			return successCb(username, ContextData{
+				Username: username,
+			})
+		})
 	}
 	return FailingSASLServ{Err: ErrUnsupportedMech}
 }
@@ -193,6 +329,10 @@ func (s *SASLAuth) AddProvider(m *config.Map, node config.Node) error {
 		s.Plain = append(s.Plain, plainAuth)
 		hasAny = true
 	}
+	if tokenAuth, ok := any.(module.TokenAuth); ok {
+		s.Token = append(s.Token, tokenAuth)
+		hasAny = true
+	}
 
 	if !hasAny {
 		return config.NodeErr(node, "auth: specified module does not provide any SASL mechanism")
@@ -19,6 +19,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package auth
 
 import (
+	"context"
 	"errors"
 	"net"
 	"testing"
@@ -39,6 +40,16 @@ func (m mockAuth) AuthPlain(username, _ string) error {
 	return nil
 }
 
+type mockTokenAuth map[string]string
+
+func (m mockTokenAuth) AuthToken(_ context.Context, token string) (string, error) {
+	username, ok := m[token]
+	if !ok {
+		return "", errors.New("invalid token")
+	}
+	return username, nil
+}
+
 func TestCreateSASL(t *testing.T) {
 	a := SASLAuth{
 		Log: testutils.Logger(t, "saslauth"),
@@ -87,3 +98,56 @@ func TestCreateSASL(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateSASL_Token(t *testing.T) {
+	a := SASLAuth{
+		Log:   testutils.Logger(t, "saslauth"),
+		Token: []module.TokenAuth{mockTokenAuth{"good": "user1"}},
+	}
+
+	if mechs := a.SASLMechanisms(); len(mechs) != 2 || mechs[0] != "OAUTHBEARER" || mechs[1] != "XOAUTH2" {
+		t.Fatal("Wrong mechanisms:", mechs)
+	}
+
+	for _, c := range []struct {
+		name     string
+		mech     string
+		response string
+		ok       bool
+	}{
+		{"OAUTHBEARER", "OAUTHBEARER", "n,,\x01auth=Bearer good\x01\x01", true},
+		{"OAUTHBEARER with authzid", "OAUTHBEARER", "n,a=user1,\x01host=mx.example.org\x01port=993\x01auth=Bearer good\x01\x01", true},
+		{"OAUTHBEARER authzid mismatch", "OAUTHBEARER", "n,a=user2,\x01auth=Bearer good\x01\x01", false},
+		{"OAUTHBEARER invalid token", "OAUTHBEARER", "n,,\x01auth=Bearer bad\x01\x01", false},
+		{"XOAUTH2", "XOAUTH2", "user=user1\x01auth=Bearer good\x01\x01", true},
+		{"XOAUTH2 invalid token", "XOAUTH2", "user=user1\x01auth=Bearer bad\x01\x01", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var identity string
+			srv := a.CreateSASL(c.mech, &net.TCPAddr{}, func(id string, _ ContextData) error {
+				identity = id
+				return nil
+			})
+
+			challenge, done, err := srv.Next([]byte(c.response))
+			if c.ok {
+				if err != nil || !done {
+					t.Fatal("Unexpected error:", err, done)
+				}
+				if identity != "user1" {
+					t.Fatal("Wrong identity passed to callback:", identity)
+				}
+				return
+			}
+
+			// Failure is reported in the challenge first.
+			if err != nil || done || len(challenge) == 0 || challenge[0] != '{' {
+				t.Fatalf("Expected error challenge, got %q %v %v", challenge, done, err)
+			}
+			_, done, err = srv.Next([]byte{})
+			if err == nil || !done {
+				t.Fatal("No error after error challenge")
+			}
+		})
+	}
+}
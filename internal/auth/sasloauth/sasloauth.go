@@ -0,0 +1,183 @@
+// Package sasloauth implements server side of OAUTHBEARER (RFC 7628) and
+// XOAUTH2 SASL mechanisms.
+//
+// go-sasl provides an OAUTHBEARER server, but it does not support XOAUTH2
+// and fails on an empty response to the error challenge that is sent by
+// many clients instead of %x01.
+package sasloauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/emersion/go-sasl"
+)
+
+const (
+	OAuthBearer = sasl.OAuthBearer
+	XOAuth2     = "XOAUTH2"
+)
+
+// Authenticator checks the bearer token. authzid is the username requested by
+// the client, it is empty if not specified.
+type Authenticator func(authzid, token string) error
+
+// Failure is the JSON object sent to the client in the error challenge.
+type Failure struct {
+	Status  string `json:"status"`
+	Schemes string `json:"schemes,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+}
+
+// ErrMalformed is returned if the client response cannot be parsed.
+var ErrMalformed = errors.New("sasloauth: malformed client response")
+
+type server struct {
+	parse        func(response []byte) (authzid, token string, err error)
+	failStatus   string
+	authenticate Authenticator
+
+	done    bool
+	failErr error
+}
+
+// NewOAuthBearerServer returns the server implementation of the OAUTHBEARER
+// mechanism.
+func NewOAuthBearerServer(auth Authenticator) sasl.Server {
+	return &server{
+		parse:        parseOAuthBearer,
+		failStatus:   "invalid_token",
+		authenticate: auth,
+	}
+}
+
+// NewXOAuth2Server returns the server implementation of the XOAUTH2
+// mechanism as used by Google and Microsoft.
+func NewXOAuth2Server(auth Authenticator) sasl.Server {
+	return &server{
+		parse:        parseXOAuth2,
+		failStatus:   "401",
+		authenticate: auth,
+	}
+}
+
+func (s *server) fail(status string, err error) ([]byte, bool, error) {
+	blob, jsonErr := json.Marshal(Failure{
+		Status:  status,
+		Schemes: "bearer",
+	})
+	if jsonErr != nil {
+		return nil, true, jsonErr
+	}
+	s.failErr = err
+	return blob, false, nil
+}
+
+func (s *server) Next(response []byte) ([]byte, bool, error) {
+	// The error is reported in the challenge, the client is expected to send
+	// a dummy response (%x01 per RFC 7628, XOAUTH2 clients send an empty
+	// one) before the exchange fails.
+	if s.failErr != nil {
+		return nil, true, s.failErr
+	}
+	if s.done {
+		return nil, true, sasl.ErrUnexpectedClientResponse
+	}
+
+	// No initial response, ask for it.
+	if response == nil {
+		return []byte{}, false, nil
+	}
+	s.done = true
+
+	authzid, token, err := s.parse(response)
+	if err != nil {
+		status := s.failStatus
+		if status == "invalid_token" {
+			status = "invalid_request"
+		}
+		return s.fail(status, err)
+	}
+
+	if err := s.authenticate(authzid, token); err != nil {
+		return s.fail(s.failStatus, err)
+	}
+	return nil, true, nil
+}
+
+// bearerToken extracts the token from the value of auth parameter.
+func bearerToken(value string) (string, error) {
+	const prefix = "bearer "
+	// Token type is case-insensitive.
+	if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return "", ErrMalformed
+	}
+	return strings.TrimSpace(value[len(prefix):]), nil
+}
+
+// parseParams parses key=value pairs separated by %x01.
+func parseParams(b []byte) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, p := range bytes.Split(b, []byte{0x01}) {
+		if len(p) == 0 {
+			continue
+		}
+		key, value, ok := bytes.Cut(p, []byte{'='})
+		if !ok {
+			return nil, ErrMalformed
+		}
+		params[string(key)] = string(value)
+	}
+	return params, nil
+}
+
+// parseOAuthBearer parses the client response in format
+//
+//	n,a=authzid,%x01host=...%x01port=...%x01auth=Bearer token%x01%x01
+func parseOAuthBearer(response []byte) (string, string, error) {
+	parts := bytes.SplitN(response, []byte{','}, 3)
+	if len(parts) != 3 {
+		return "", "", ErrMalformed
+	}
+	// Channel binding is not supported.
+	if !bytes.Equal(parts[0], []byte{'n'}) {
+		return "", "", ErrMalformed
+	}
+
+	var authzid string
+	if len(parts[1]) != 0 {
+		if !bytes.HasPrefix(parts[1], []byte("a=")) {
+			return "", "", ErrMalformed
+		}
+		authzid = string(parts[1][2:])
+		// RFC 5801 saslname encoding.
+		authzid = strings.NewReplacer("=2C", ",", "=3D", "=").Replace(authzid)
+	}
+
+	params, err := parseParams(parts[2])
+	if err != nil {
+		return "", "", err
+	}
+	token, err := bearerToken(params["auth"])
+	if err != nil {
+		return "", "", err
+	}
+	return authzid, token, nil
+}
+
+// parseXOAuth2 parses the client response in format
+//
+//	user=username%x01auth=Bearer token%x01%x01
+func parseXOAuth2(response []byte) (string, string, error) {
+	params, err := parseParams(response)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := bearerToken(params["auth"])
+	if err != nil {
+		return "", "", err
+	}
+	return params["user"], token, nil
+}
@@ -0,0 +1,67 @@
+package sasloauth
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/emersion/go-sasl"
+)
+
+func TestOAuthBearer(t *testing.T) {
+	var gotAuthzid, gotToken string
+	srv := NewOAuthBearerServer(func(authzid, token string) error {
+		gotAuthzid, gotToken = authzid, token
+		return nil
+	})
+
+	// No initial response.
+	challenge, done, err := srv.Next(nil)
+	if err != nil || done || len(challenge) != 0 {
+		t.Fatalf("Unexpected first step: %q %v %v", challenge, done, err)
+	}
+	_, done, err = srv.Next([]byte("n,a=foo=2Cbar,\x01auth=BEARER abc.def\x01\x01"))
+	if err != nil || !done {
+		t.Fatal("Unexpected error:", err)
+	}
+	if gotAuthzid != "foo,bar" || gotToken != "abc.def" {
+		t.Fatalf("Wrong credentials: %q %q", gotAuthzid, gotToken)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	authErr := errors.New("bad token")
+	auth := func(string, string) error { return authErr }
+
+	for _, c := range []struct {
+		name     string
+		mech     func(Authenticator) sasl.Server
+		response string
+		status   string
+		err      error
+	}{
+		{"OAUTHBEARER", NewOAuthBearerServer, "n,,\x01auth=Bearer abc\x01\x01", "invalid_token", authErr},
+		{"OAUTHBEARER malformed", NewOAuthBearerServer, "p=tls-unique,,\x01auth=Bearer abc\x01\x01", "invalid_request", ErrMalformed},
+		{"XOAUTH2", NewXOAuth2Server, "user=foo\x01auth=Bearer abc\x01\x01", "401", authErr},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := c.mech(auth)
+			challenge, done, err := srv.Next([]byte(c.response))
+			if err != nil || done {
+				t.Fatal("Expected error challenge, got", done, err)
+			}
+			var failure Failure
+			if err := json.Unmarshal(challenge, &failure); err != nil {
+				t.Fatal(err)
+			}
+			if failure.Status != c.status || failure.Schemes != "bearer" {
+				t.Fatalf("Wrong failure: %+v", failure)
+			}
+
+			_, done, err = srv.Next([]byte{0x01})
+			if !done || !errors.Is(err, c.err) {
+				t.Fatal("Unexpected final step:", done, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cosmossdk.io/x/tx/signing"
+	"github.com/cosmos/cosmos-sdk/client"
+	clienttx "github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/cosmos/cosmos-sdk/codec"
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/cosmos/cosmos-sdk/std"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/cosmos/gogoproto/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/chainnode"
+	mailchattypes "github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+// CosmosBlockChain submits transactions to the MailChat chain (or any other
+// Cosmos SDK chain) via the node gRPC endpoint. Transactions are signed
+// using a key from the local keyring.
+//
+// If the mail server runs inside the chain node process, the node is used
+// directly and grpc_addr is ignored.
+type CosmosBlockChain struct {
+	modName  string
+	instName string
+	log      log.Logger
+
+	grpcAddr       string
+	chainID        string
+	keyringBackend string
+	keyringService string
+	keyringDir     string
+	keyName        string
+	addressPrefix  string
+	gas            uint64
+	fees           string
+
+	cdc      codec.Codec
+	txConfig client.TxConfig
+	keyring  keyring.Keyring
+	address  string
+	node     *chainnode.Node
+	conn     *grpc.ClientConn
+
+	// seqLock serializes transactions from the key so sequence numbers of
+	// the transactions in the same block do not clash.
+	seqLock  sync.Mutex
+	sequence uint64
+}
+
+func NewCosmosBlockChain(modName, instName string, _, _ []string) (module.Module, error) {
+	return &CosmosBlockChain{
+		modName:  modName,
+		instName: instName,
+		log:      log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+	}, nil
+}
+
+func (b *CosmosBlockChain) Init(cfg *config.Map) error {
+	cfg.Bool("debug", true, log.DefaultLogger.Debug, &b.log.Debug)
+	cfg.String("grpc_addr", false, false, "127.0.0.1:9090", &b.grpcAddr)
+	cfg.String("chain_id", false, false, "", &b.chainID)
+	cfg.String("keyring_backend", false, false, keyring.BackendTest, &b.keyringBackend)
+	cfg.String("keyring_service", false, false, sdk.KeyringServiceName(), &b.keyringService)
+	cfg.String("keyring_dir", false, true, "", &b.keyringDir)
+	cfg.String("key_name", false, true, "", &b.keyName)
+	cfg.String("address_prefix", false, false, "mcc", &b.addressPrefix)
+	cfg.UInt64("gas", false, false, 200000, &b.gas)
+	cfg.String("fees", false, false, "", &b.fees)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	b.node = chainnode.Local()
+	if b.chainID == "" && b.node != nil {
+		b.chainID = b.node.ChainID
+	}
+	if b.chainID == "" {
+		return fmt.Errorf("%s: chain_id is required", b.modName)
+	}
+
+	addrCodec := addresscodec.NewBech32Codec(b.addressPrefix)
+	registry, err := codectypes.NewInterfaceRegistryWithOptions(codectypes.InterfaceRegistryOptions{
+		ProtoFiles: proto.HybridResolver,
+		SigningOptions: signing.Options{
+			AddressCodec:          addrCodec,
+			ValidatorAddressCodec: addresscodec.NewBech32Codec(b.addressPrefix + "valoper"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	std.RegisterInterfaces(registry)
+	authtypes.RegisterInterfaces(registry)
+	mailchattypes.RegisterInterfaces(registry)
+	protoCodec := codec.NewProtoCodec(registry)
+	b.cdc = protoCodec
+	b.txConfig = authtx.NewTxConfig(protoCodec, authtx.DefaultSignModes)
+
+	b.keyring, err = keyring.New(b.keyringService, b.keyringBackend, b.keyringDir, nil, b.cdc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.modName, err)
+	}
+	record, err := b.keyring.Key(b.keyName)
+	if err != nil {
+		return fmt.Errorf("%s: key %s: %w", b.modName, b.keyName, err)
+	}
+	addr, err := record.GetAddress()
+	if err != nil {
+		return fmt.Errorf("%s: key %s: %w", b.modName, b.keyName, err)
+	}
+	b.address, err = addrCodec.BytesToString(addr)
+	if err != nil {
+		return err
+	}
+
+	if b.node != nil {
+		b.log.Debugln("using in-process chain node")
+		return nil
+	}
+
+	b.conn, err = grpc.NewClient(b.grpcAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(protoCodec.GRPCCodec())))
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.modName, err)
+	}
+
+	return nil
+}
+
+// Address returns the account address used to sign transactions.
+func (b *CosmosBlockChain) Address() string {
+	return b.address
+}
+
+func (b *CosmosBlockChain) broadcast(ctx context.Context, txBytes []byte) (string, error) {
+	var txResp *sdk.TxResponse
+	if b.node != nil {
+		var err error
+		txResp, err = b.node.BroadcastTx(ctx, txBytes)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		resp, err := txtypes.NewServiceClient(b.conn).BroadcastTx(ctx, &txtypes.BroadcastTxRequest{
+			TxBytes: txBytes,
+			Mode:    txtypes.BroadcastMode_BROADCAST_MODE_SYNC,
+		})
+		if err != nil {
+			return "", err
+		}
+		txResp = resp.TxResponse
+	}
+
+	if txResp.Code != 0 {
+		return txResp.TxHash, fmt.Errorf("transaction %s rejected: %s (code %d)",
+			txResp.TxHash, txResp.RawLog, txResp.Code)
+	}
+	return txResp.TxHash, nil
+}
+
+func (b *CosmosBlockChain) accountInfo(ctx context.Context) (*authtypes.BaseAccount, error) {
+	req := &authtypes.QueryAccountInfoRequest{Address: b.address}
+
+	var (
+		resp *authtypes.QueryAccountInfoResponse
+		err  error
+	)
+	if b.node != nil {
+		qctx, qerr := b.node.QueryContext(ctx)
+		if qerr != nil {
+			return nil, qerr
+		}
+		resp, err = b.node.Auth.AccountInfo(qctx, req)
+	} else {
+		resp, err = authtypes.NewQueryClient(b.conn).AccountInfo(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("account %s: %w", b.address, err)
+	}
+	return resp.Info, nil
+}
+
+func (b *CosmosBlockChain) signAndBroadcast(ctx context.Context, msg proto.Message) (string, error) {
+	b.seqLock.Lock()
+	defer b.seqLock.Unlock()
+
+	info, err := b.accountInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Account state queried from the node does not include transactions
+	// that are not in a block yet.
+	if info.Sequence > b.sequence {
+		b.sequence = info.Sequence
+	}
+
+	txf := clienttx.Factory{}.
+		WithTxConfig(b.txConfig).
+		WithKeybase(b.keyring).
+		WithChainID(b.chainID).
+		WithAccountNumber(info.AccountNumber).
+		WithSequence(b.sequence).
+		WithGas(b.gas).
+		WithFees(b.fees)
+	builder, err := txf.BuildUnsignedTx(msg)
+	if err != nil {
+		return "", err
+	}
+	if err := clienttx.Sign(ctx, txf, b.keyName, builder, true); err != nil {
+		return "", err
+	}
+	txBytes, err := b.txConfig.TxEncoder()(builder.GetTx())
+	if err != nil {
+		return "", err
+	}
+
+	txHash, err := b.broadcast(ctx, txBytes)
+	if err != nil {
+		// Sequence is re-read from the chain on the next attempt.
+		b.sequence = 0
+		return "", err
+	}
+	b.sequence++
+	return txHash, nil
+}
+
+// anchorPollInterval is how often the chain is checked for the submitted
+// root while waiting for the transaction to be included in a block.
+const anchorPollInterval = time.Second
+
+// AnchorRoot submits MsgAnchorRoot signed by the configured key and waits
+// until the root is recorded on chain.
+//
+// Broadcast transactions are only checked against the mempool, so the
+// anchor is queried instead of relying on the broadcast result.
+func (b *CosmosBlockChain) AnchorRoot(ctx context.Context, root string, leafCount uint64) (string, error) {
+	anchored, err := b.anchored(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	if anchored {
+		return "", module.ErrRootAnchored
+	}
+
+	txHash, err := b.signAndBroadcast(ctx, &mailchattypes.MsgAnchorRoot{
+		Submitter: b.address,
+		Root:      root,
+		LeafCount: leafCount,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ticker := time.NewTicker(anchorPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("transaction %s is not included in a block: %w", txHash, ctx.Err())
+		case <-ticker.C:
+		}
+
+		anchored, err := b.anchored(ctx, root)
+		if err != nil {
+			b.log.Error("failed to query anchor", err, "root", root, "tx", txHash)
+			continue
+		}
+		if anchored {
+			b.log.DebugMsg("anchored merkle root", "root", root, "leaves", leafCount, "tx", txHash)
+			return txHash, nil
+		}
+	}
+}
+
+// anchored reports whether the root is recorded in the x/mailchat module.
+func (b *CosmosBlockChain) anchored(ctx context.Context, root string) (bool, error) {
+	req := &mailchattypes.QueryAnchorRequest{Root: root}
+
+	var err error
+	if b.node != nil {
+		qctx, qerr := b.node.QueryContext(ctx)
+		if qerr != nil {
+			return false, qerr
+		}
+		_, err = b.node.Mailchat.Anchor(qctx, req)
+	} else {
+		_, err = mailchattypes.NewQueryClient(b.conn).Anchor(ctx, req)
+	}
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("anchor %s: %w", root, err)
+	}
+	return true, nil
+}
+
+// MailboxQuota returns the effective storage quota of the mailbox recorded in
+// the x/mailchat module.
+func (b *CosmosBlockChain) MailboxQuota(ctx context.Context, mailbox string) (uint64, error) {
+	req := &mailchattypes.QueryMailboxQuotaRequest{Mailbox: mailbox}
+
+	var (
+		resp *mailchattypes.QueryMailboxQuotaResponse
+		err  error
+	)
+	if b.node != nil {
+		qctx, qerr := b.node.QueryContext(ctx)
+		if qerr != nil {
+			return 0, qerr
+		}
+		resp, err = b.node.Mailchat.MailboxQuota(qctx, req)
+	} else {
+		resp, err = mailchattypes.NewQueryClient(b.conn).MailboxQuota(ctx, req)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("mailbox quota %s: %w", mailbox, err)
+	}
+	return resp.LimitBytes, nil
+}
+
+// SendRawTx broadcasts a signed transaction encoded using base64 or hex.
+func (b *CosmosBlockChain) SendRawTx(ctx context.Context, rawTx string) error {
+	txBytes, err := base64.StdEncoding.DecodeString(rawTx)
+	if err != nil {
+		txBytes, err = hex.DecodeString(strings.TrimPrefix(rawTx, "0x"))
+		if err != nil {
+			return errors.New("transaction should be encoded using base64 or hex")
+		}
+	}
+	_, err = b.broadcast(ctx, txBytes)
+	return err
+}
+
+// CheckSign verifies a secp256k1 signature of message. pk is the
+// hex-encoded compressed public key, sign is the hex-encoded 64-byte
+// signature.
+func (b *CosmosBlockChain) CheckSign(ctx context.Context, pk, sign, message string) (bool, error) {
+	pkBytes, err := hex.DecodeString(strings.TrimPrefix(pk, "0x"))
+	if err != nil {
+		return false, err
+	}
+	if len(pkBytes) != secp256k1.PubKeySize {
+		return false, fmt.Errorf("invalid public key length")
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(sign, "0x"))
+	if err != nil {
+		return false, err
+	}
+	pubKey := &secp256k1.PubKey{Key: pkBytes}
+	return pubKey.VerifySignature([]byte(message), sigBytes), nil
+}
+
+func (b *CosmosBlockChain) ChainType(ctx context.Context) string {
+	return "cosmos"
+}
+
+func (b *CosmosBlockChain) Name() string { return b.modName }
+
+func (b *CosmosBlockChain) InstanceName() string {
+	return b.instName
+}
+
+func (b *CosmosBlockChain) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn.Close()
+}
+
+func init() {
+	module.Register("blockchain.cosmos", NewCosmosBlockChain)
+}
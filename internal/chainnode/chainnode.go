@@ -0,0 +1,56 @@
+// Package chainnode provides mail server modules access to the MailChat
+// chain node running in the same process (see 'MailChatd start --mail').
+//
+// Modules such as blockchain.cosmos and table.chain_registry use it to
+// query the keepers directly instead of going through the network RPC.
+package chainnode
+
+import (
+	"context"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+// Node is the chain node running in the same process.
+type Node struct {
+	// ChainID is the chain ID of the node.
+	ChainID string
+
+	// Mailchat serves x/mailchat queries using the keeper.
+	Mailchat types.QueryServer
+
+	// Auth serves x/auth queries using the keeper.
+	Auth authtypes.QueryServer
+
+	// QueryContext returns the context to pass to the query servers. It is
+	// bound to the latest committed state.
+	QueryContext func(ctx context.Context) (context.Context, error)
+
+	// BroadcastTx submits the signed transaction to the node mempool.
+	BroadcastTx func(ctx context.Context, txBytes []byte) (*sdk.TxResponse, error)
+}
+
+var (
+	lock  sync.RWMutex
+	local *Node
+)
+
+// Set makes the node available to the mail server modules. It should be
+// called before the modules are initialized.
+func Set(n *Node) {
+	lock.Lock()
+	defer lock.Unlock()
+	local = n
+}
+
+// Local returns the node running in the same process or nil if the mail
+// server runs standalone.
+func Local() *Node {
+	lock.RLock()
+	defer lock.RUnlock()
+	return local
+}
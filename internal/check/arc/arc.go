@@ -0,0 +1,239 @@
+package arc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/trace"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-msgauth/authres"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/arc"
+	"github.com/dsoftgames/MailChat/internal/target"
+)
+
+// Check validates the ARC chain of incoming messages (RFC 8617).
+//
+// The result is added to Authentication-Results. If the chain passes and the
+// most recent set sealed by one of trusted_sealers reports dmarc=pass, the
+// result is marked so that DMARC evaluation does not apply the policy to the
+// message.
+type Check struct {
+	instName string
+	log      log.Logger
+
+	trustedSealers    map[string]struct{}
+	brokenChainAction modconfig.FailAction
+	failOpen          bool
+
+	resolver arc.Resolver
+}
+
+func New(_, instName string, _, inlineArgs []string) (module.Module, error) {
+	if len(inlineArgs) != 0 {
+		return nil, errors.New("check.arc: inline arguments are not used")
+	}
+	return &Check{
+		instName: instName,
+		log:      log.Logger{Name: "check.arc"},
+		resolver: dns.DefaultResolver(),
+	}, nil
+}
+
+func (c *Check) Init(cfg *config.Map) error {
+	var trustedSealers []string
+
+	cfg.Bool("debug", true, false, &c.log.Debug)
+	cfg.StringList("trusted_sealers", false, false, nil, &trustedSealers)
+	cfg.Bool("fail_open", false, false, &c.failOpen)
+	cfg.Custom("broken_chain_action", false, false,
+		func() (interface{}, error) {
+			return modconfig.FailAction{}, nil
+		}, modconfig.FailActionDirective, &c.brokenChainAction)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	c.trustedSealers = make(map[string]struct{}, len(trustedSealers))
+	for _, domain := range trustedSealers {
+		normDomain, err := dns.ForLookup(domain)
+		if err != nil {
+			return err
+		}
+		c.trustedSealers[normDomain] = struct{}{}
+	}
+	return nil
+}
+
+func (c *Check) Name() string {
+	return "check.arc"
+}
+
+func (c *Check) InstanceName() string {
+	return c.instName
+}
+
+// trustedSealer returns the domain of the most recent trusted sealer that
+// reports passing DMARC for the message.
+func (c *Check) trustedSealer(chain *arc.Result) string {
+	for i := len(chain.Sets) - 1; i >= 0; i-- {
+		set := chain.Sets[i]
+		domain, err := dns.ForLookup(set.Domain)
+		if err != nil {
+			continue
+		}
+		if _, ok := c.trustedSealers[domain]; !ok {
+			continue
+		}
+
+		_, results, err := authres.Parse(set.AuthResults)
+		if err != nil {
+			return ""
+		}
+		for _, res := range results {
+			if res, ok := res.(*authres.DMARCResult); ok && res.Value == authres.ResultPass {
+				return domain
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+type state struct {
+	c       *Check
+	msgMeta *module.MsgMetadata
+	log     log.Logger
+}
+
+func (s *state) CheckConnection(ctx context.Context) module.CheckResult {
+	return module.CheckResult{}
+}
+
+func (s *state) CheckSender(ctx context.Context, mailFrom string) module.CheckResult {
+	return module.CheckResult{}
+}
+
+func (s *state) CheckRcpt(ctx context.Context, rcptTo string) module.CheckResult {
+	return module.CheckResult{}
+}
+
+func (s *state) CheckBody(ctx context.Context, header textproto.Header, body buffer.Buffer) module.CheckResult {
+	defer trace.StartRegion(ctx, "check.arc/CheckBody").End()
+
+	if !header.Has("ARC-Seal") && !header.Has("ARC-Message-Signature") && !header.Has("ARC-Authentication-Results") {
+		return module.CheckResult{
+			AuthResult: []authres.Result{
+				&authres.GenericResult{Method: "arc", Value: authres.ResultNone},
+			},
+		}
+	}
+
+	bodyRdr, err := body.Open()
+	if err != nil {
+		return module.CheckResult{
+			Reject: true,
+			Reason: exterrors.WithTemporary(
+				exterrors.WithFields(err, map[string]interface{}{
+					"check":    "check.arc",
+					"smtp_msg": "Internal I/O error",
+				}),
+				true,
+			),
+		}
+	}
+	defer bodyRdr.Close()
+
+	chain, err := arc.Verify(ctx, s.c.resolver, header, bodyRdr)
+	if err != nil {
+		if !s.c.failOpen {
+			return module.CheckResult{
+				Reject: true,
+				Reason: &exterrors.SMTPError{
+					Code:         421,
+					EnhancedCode: exterrors.EnhancedCode{4, 7, 29},
+					Message:      "Temporary error during ARC validation",
+					CheckName:    "check.arc",
+					Err:          err,
+				},
+			}
+		}
+		s.log.Error("temporary error during validation", err)
+		return module.CheckResult{
+			AuthResult: []authres.Result{
+				&authres.GenericResult{
+					Method: "arc",
+					Value:  authres.ResultTempError,
+					Params: map[string]string{"reason": strings.TrimPrefix(err.Error(), "arc: ")},
+				},
+			},
+		}
+	}
+
+	res := &authres.GenericResult{
+		Method: "arc",
+		Value:  authres.ResultValue(chain.Status),
+		Params: map[string]string{},
+	}
+	if s.msgMeta.Conn != nil {
+		if tcpAddr, ok := s.msgMeta.Conn.RemoteAddr.(*net.TCPAddr); ok {
+			res.Params["smtp.remote-ip"] = tcpAddr.IP.String()
+		}
+	}
+
+	if chain.Status != arc.StatusPass {
+		s.log.DebugMsg("chain validation failed", "reason", chain.Reason)
+		res.Params["reason"] = chain.Reason
+		return s.c.brokenChainAction.Apply(module.CheckResult{
+			Reason: &exterrors.SMTPError{
+				Code:         550,
+				EnhancedCode: exterrors.EnhancedCode{5, 7, 29},
+				Message:      "ARC chain validation failed",
+				CheckName:    "check.arc",
+				Misc: map[string]interface{}{
+					"reason": chain.Reason,
+				},
+			},
+			AuthResult: []authres.Result{res},
+		})
+	}
+
+	if chain.OldestPass != 0 {
+		res.Params["header.oldest-pass"] = strconv.Itoa(chain.OldestPass)
+	}
+	if sealer := s.c.trustedSealer(chain); sealer != "" {
+		res.Params[arc.TrustedSealerParam] = sealer
+		s.log.DebugMsg("chain sealed by a trusted sealer", "sealer", sealer)
+	}
+	return module.CheckResult{AuthResult: []authres.Result{res}}
+}
+
+func (s *state) Name() string {
+	return "check.arc"
+}
+
+func (s *state) Close() error {
+	return nil
+}
+
+func (c *Check) CheckStateForMsg(ctx context.Context, msgMeta *module.MsgMetadata) (module.CheckState, error) {
+	return &state{
+		c:       c,
+		msgMeta: msgMeta,
+		log:     target.DeliveryLogger(c.log, msgMeta),
+	}, nil
+}
+
+func init() {
+	module.Register("check.arc", New)
+}
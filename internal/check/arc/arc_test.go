@@ -0,0 +1,141 @@
+package arc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-msgauth/authres"
+	"github.com/foxcpp/go-mockdns"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/arc"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+func sealedMsg(t *testing.T, aar string) (textproto.Header, []byte, *mockdns.Resolver) {
+	t.Helper()
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hdr := textproto.Header{}
+	hdr.Add("From", "<alice@example.org>")
+	hdr.Add("Subject", "Hello")
+	body := []byte("Hello!\r\n")
+	err = arc.Seal(&hdr, bytes.NewReader(body), arc.StatusNone, arc.SealOptions{
+		Domain:      "lists.example.net",
+		Selector:    "default",
+		Signer:      key,
+		AuthServID:  "mx.example.net",
+		AuthResults: aar,
+		HeaderKeys:  []string{"From", "Subject"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hdr, body, &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"default._domainkey.lists.example.net.": {
+			TXT: []string{"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)},
+		},
+	}}
+}
+
+func testCheck(t *testing.T, r arc.Resolver, trusted []string) *Check {
+	t.Helper()
+
+	mod, err := New("", "test", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := mod.(*Check)
+	c.log = testutils.Logger(t, c.Name())
+	c.resolver = r
+	var children []config.Node
+	if len(trusted) != 0 {
+		children = append(children, config.Node{Name: "trusted_sealers", Args: trusted})
+	}
+	if err := c.Init(config.NewMap(nil, config.Node{Children: children})); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func checkMsg(t *testing.T, c *Check, hdr textproto.Header, body []byte) (module.CheckResult, *authres.GenericResult) {
+	t.Helper()
+
+	s, err := c.CheckStateForMsg(context.Background(), &module.MsgMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := s.CheckBody(context.Background(), hdr, buffer.MemoryBuffer{Slice: body})
+	if len(res.AuthResult) != 1 {
+		t.Fatalf("expected 1 auth result, got %d", len(res.AuthResult))
+	}
+	arcRes, ok := res.AuthResult[0].(*authres.GenericResult)
+	if !ok || arcRes.Method != "arc" {
+		t.Fatalf("wrong auth result: %#v", res.AuthResult[0])
+	}
+	return res, arcRes
+}
+
+func TestCheck(t *testing.T) {
+	hdr, body, r := sealedMsg(t, "dmarc=pass header.from=example.org")
+
+	_, res := checkMsg(t, testCheck(t, r, nil), hdr, body)
+	if res.Value != authres.ResultPass {
+		t.Fatalf("expected pass, got %s (%s)", res.Value, res.Params["reason"])
+	}
+	if res.Params[arc.TrustedSealerParam] != "" {
+		t.Error("untrusted sealer is marked as trusted")
+	}
+
+	_, res = checkMsg(t, testCheck(t, r, []string{"lists.example.net"}), hdr, body)
+	if res.Params[arc.TrustedSealerParam] != "lists.example.net" {
+		t.Errorf("trusted sealer is not reported: %v", res.Params)
+	}
+}
+
+func TestCheck_TrustedNoDMARC(t *testing.T) {
+	hdr, body, r := sealedMsg(t, "dmarc=fail header.from=example.org")
+
+	_, res := checkMsg(t, testCheck(t, r, []string{"lists.example.net"}), hdr, body)
+	if res.Value != authres.ResultPass {
+		t.Fatalf("expected pass, got %s", res.Value)
+	}
+	if res.Params[arc.TrustedSealerParam] != "" {
+		t.Error("sealer is trusted despite failed DMARC")
+	}
+}
+
+func TestCheck_Broken(t *testing.T) {
+	hdr, body, r := sealedMsg(t, "dmarc=pass header.from=example.org")
+	body = append(body, "footer\r\n"...)
+
+	res, arcRes := checkMsg(t, testCheck(t, r, []string{"lists.example.net"}), hdr, body)
+	if arcRes.Value != authres.ResultFail || arcRes.Params[arc.TrustedSealerParam] != "" {
+		t.Errorf("wrong result: %+v", arcRes)
+	}
+	if res.Reject || res.Quarantine {
+		t.Error("message is rejected with default broken_chain_action")
+	}
+}
+
+func TestCheck_NoChain(t *testing.T) {
+	hdr := textproto.Header{}
+	hdr.Add("From", "<alice@example.org>")
+
+	_, res := checkMsg(t, testCheck(t, &mockdns.Resolver{}, nil), hdr, []byte("Hello!\r\n"))
+	if res.Value != authres.ResultNone {
+		t.Errorf("expected none, got %s", res.Value)
+	}
+}
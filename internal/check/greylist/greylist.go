@@ -0,0 +1,536 @@
+// Package greylist implements the check.greylist module that temporarily
+// rejects messages from unknown (client network, sender, recipient)
+// triplets.
+//
+// Legitimate MTAs retry delivery after a temporary failure while most of
+// spam software does not. The triplet is accepted when the delivery is
+// retried after the delay but within the retry window and remembered for
+// the expiry period after each accepted message.
+package greylist
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"blitiri.com.ar/go/spf"
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/target"
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+const modName = "check.greylist"
+
+// cleanupInterval is the interval between removals of expired triplets
+// from the table.
+const cleanupInterval = 1 * time.Hour
+
+type Check struct {
+	instName string
+
+	delay       time.Duration
+	retryWindow time.Duration
+	expire      time.Duration
+	ipv4Prefix  int
+	ipv6Prefix  int
+
+	allowAuthenticated bool
+	allowNets          []net.IPNet
+	allowDomains       []string
+
+	table    module.MutableTable
+	resolver dns.Resolver
+	log      log.Logger
+
+	now         func() time.Time
+	stopCleanup chan struct{}
+	cleanupDone sync.WaitGroup
+}
+
+func New(_, instName string, _, _ []string) (module.Module, error) {
+	return &Check{
+		instName: instName,
+		resolver: dns.DefaultResolver(),
+		log:      log.Logger{Name: modName},
+		now:      time.Now,
+	}, nil
+}
+
+func (c *Check) Name() string {
+	return modName
+}
+
+func (c *Check) InstanceName() string {
+	return c.instName
+}
+
+func (c *Check) Init(cfg *config.Map) error {
+	var (
+		tbl       module.Table
+		allowNets []string
+	)
+	cfg.Bool("debug", true, false, &c.log.Debug)
+	cfg.Duration("delay", false, false, 5*time.Minute, &c.delay)
+	cfg.Duration("retry_window", false, false, 24*time.Hour, &c.retryWindow)
+	cfg.Duration("expire", false, false, 36*24*time.Hour, &c.expire)
+	cfg.Int("ipv4_prefix", false, false, 24, &c.ipv4Prefix)
+	cfg.Int("ipv6_prefix", false, false, 64, &c.ipv6Prefix)
+	cfg.Bool("allow_authenticated", false, true, &c.allowAuthenticated)
+	cfg.StringList("allow_networks", false, false, nil, &allowNets)
+	cfg.StringList("allow_domains", false, false, nil, &c.allowDomains)
+	modconfig.Table(cfg, "table", false, false, nil, &tbl)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if c.ipv4Prefix < 0 || c.ipv4Prefix > 32 {
+		return fmt.Errorf("%s: invalid ipv4_prefix: %d", modName, c.ipv4Prefix)
+	}
+	if c.ipv6Prefix < 0 || c.ipv6Prefix > 128 {
+		return fmt.Errorf("%s: invalid ipv6_prefix: %d", modName, c.ipv6Prefix)
+	}
+	if c.retryWindow <= c.delay {
+		return fmt.Errorf("%s: retry_window should be longer than delay", modName)
+	}
+
+	for _, n := range allowNets {
+		// Plain IP address, same as in dnsbl responses.
+		if !strings.Contains(n, "/") {
+			if strings.Contains(n, ":") {
+				n += "/128"
+			} else {
+				n += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(n)
+		if err != nil {
+			return fmt.Errorf("%s: %w", modName, err)
+		}
+		c.allowNets = append(c.allowNets, *ipNet)
+	}
+
+	for i, domain := range c.allowDomains {
+		c.allowDomains[i] = dns.FQDN(strings.ToLower(domain))
+	}
+
+	if tbl == nil {
+		c.log.Msg("no table configured, greylisting state is kept in memory and lost on restart")
+		c.table = newMemoryTable()
+	} else {
+		mtbl, ok := tbl.(module.MutableTable)
+		if !ok {
+			return fmt.Errorf("%s: table should be a mutable table", modName)
+		}
+		c.table = mtbl
+	}
+
+	c.stopCleanup = make(chan struct{})
+	c.cleanupDone.Add(1)
+	go c.cleanupLoop()
+
+	return nil
+}
+
+func (c *Check) Close() error {
+	if c.stopCleanup != nil {
+		close(c.stopCleanup)
+		c.cleanupDone.Wait()
+	}
+	return nil
+}
+
+// entry is the state of the triplet stored in the table as
+// "<first seen> <last accepted>" Unix timestamps. Last accepted is 0 until
+// the triplet passes greylisting.
+type entry struct {
+	firstSeen    time.Time
+	lastAccepted time.Time
+}
+
+func parseEntry(val string) (entry, error) {
+	parts := strings.Fields(val)
+	if len(parts) != 2 {
+		return entry{}, fmt.Errorf("malformed entry: %q", val)
+	}
+	first, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return entry{}, fmt.Errorf("malformed entry: %q", val)
+	}
+	last, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return entry{}, fmt.Errorf("malformed entry: %q", val)
+	}
+
+	e := entry{firstSeen: time.Unix(first, 0)}
+	if last != 0 {
+		e.lastAccepted = time.Unix(last, 0)
+	}
+	return e, nil
+}
+
+func (e entry) String() string {
+	var last int64
+	if !e.lastAccepted.IsZero() {
+		last = e.lastAccepted.Unix()
+	}
+	return strconv.FormatInt(e.firstSeen.Unix(), 10) + " " + strconv.FormatInt(last, 10)
+}
+
+// expired reports whether the entry can be removed from the table.
+func (c *Check) expired(e entry, now time.Time) bool {
+	if e.lastAccepted.IsZero() {
+		return now.Sub(e.firstSeen) > c.retryWindow
+	}
+	return now.Sub(e.lastAccepted) > c.expire
+}
+
+func (c *Check) cleanupLoop() {
+	defer c.cleanupDone.Done()
+
+	t := time.NewTicker(cleanupInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stopCleanup:
+			return
+		case <-t.C:
+			c.cleanup()
+		}
+	}
+}
+
+func (c *Check) cleanup() {
+	keys, err := c.table.Keys()
+	if err != nil {
+		c.log.Error("failed to list triplets", err)
+		return
+	}
+
+	now := c.now()
+	removed := 0
+	for _, key := range keys {
+		val, ok, err := c.table.Lookup(context.Background(), key)
+		if err != nil || !ok {
+			continue
+		}
+		e, err := parseEntry(val)
+		if err == nil && !c.expired(e, now) {
+			continue
+		}
+		if err := c.table.RemoveKey(key); err != nil {
+			c.log.Error("failed to remove triplet", err, "key", key)
+			continue
+		}
+		removed++
+	}
+	c.log.DebugMsg("expired triplets removed", "count", removed)
+}
+
+// clientNet returns the network of the client IP used in the triplet.
+func (c *Check) clientNet(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(c.ipv4Prefix, 32)).String() + "/" + strconv.Itoa(c.ipv4Prefix)
+	}
+	return ip.Mask(net.CIDRMask(c.ipv6Prefix, 128)).String() + "/" + strconv.Itoa(c.ipv6Prefix)
+}
+
+func (c *Check) allowedDomain(domain string) bool {
+	domain = dns.FQDN(strings.ToLower(domain))
+	for _, allowed := range c.allowDomains {
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+type state struct {
+	c       *Check
+	msgMeta *module.MsgMetadata
+	log     log.Logger
+
+	skip     bool
+	clientIP net.IP
+
+	// spfChecked and spfPass cache the SPF result for allow_domains.
+	spfChecked bool
+	spfPass    bool
+
+	// greylisted is the result returned by CheckBody unless the message has
+	// a valid DKIM signature of the allowed sender domain.
+	greylisted *module.CheckResult
+}
+
+func (c *Check) CheckStateForMsg(ctx context.Context, msgMeta *module.MsgMetadata) (module.CheckState, error) {
+	return &state{
+		c:       c,
+		msgMeta: msgMeta,
+		log:     target.DeliveryLogger(c.log, msgMeta),
+	}, nil
+}
+
+func (s *state) CheckConnection(ctx context.Context) module.CheckResult {
+	if s.msgMeta.Conn == nil {
+		s.skip = true
+		s.log.Println("locally generated message, skipping")
+		return module.CheckResult{}
+	}
+	if s.c.allowAuthenticated && s.msgMeta.Conn.AuthUser != "" {
+		s.skip = true
+		return module.CheckResult{}
+	}
+
+	tcpAddr, ok := s.msgMeta.Conn.RemoteAddr.(*net.TCPAddr)
+	if !ok {
+		s.skip = true
+		s.log.Println("non-IP SrcAddr")
+		return module.CheckResult{}
+	}
+	s.clientIP = tcpAddr.IP
+
+	for _, allowed := range s.c.allowNets {
+		if allowed.Contains(s.clientIP) {
+			s.skip = true
+			s.log.DebugMsg("client network is allowed", "ip", s.clientIP)
+			return module.CheckResult{}
+		}
+	}
+
+	return module.CheckResult{}
+}
+
+func (s *state) CheckSender(ctx context.Context, addr string) module.CheckResult {
+	return module.CheckResult{}
+}
+
+// checkSPF evaluates SPF for the sender domain, the result is used only to
+// skip greylisting for allow_domains.
+func (s *state) checkSPF(ctx context.Context) bool {
+	if s.spfChecked {
+		return s.spfPass
+	}
+	s.spfChecked = true
+
+	mailFrom := s.msgMeta.OriginalFrom
+	if mailFrom == "" {
+		mailFrom = "postmaster@" + s.msgMeta.Conn.Hostname
+	}
+	_, domain, err := address.Split(mailFrom)
+	if err != nil || !s.c.allowedDomain(domain) {
+		return false
+	}
+
+	res, err := spf.CheckHostWithSender(s.clientIP, dns.FQDN(s.msgMeta.Conn.Hostname), mailFrom,
+		spf.WithContext(ctx), spf.WithResolver(s.c.resolver))
+	s.log.DebugMsg("SPF result for allowed domain", "domain", domain, "result", res, "err", err)
+	s.spfPass = res == spf.Pass
+	return s.spfPass
+}
+
+func (s *state) CheckRcpt(ctx context.Context, rcptTo string) module.CheckResult {
+	if s.skip {
+		return module.CheckResult{}
+	}
+
+	defer trace.StartRegion(ctx, "check.greylist/CheckRcpt").End()
+
+	if len(s.c.allowDomains) != 0 && s.checkSPF(ctx) {
+		return module.CheckResult{}
+	}
+
+	key := strings.Join([]string{
+		s.c.clientNet(s.clientIP),
+		strings.ToLower(s.msgMeta.OriginalFrom),
+		strings.ToLower(rcptTo),
+	}, " ")
+
+	res := s.check(ctx, key)
+	if res.Reason == nil {
+		return res
+	}
+
+	// Messages from allowed domains are accepted if they have a valid DKIM
+	// signature, that is known only after the body is received.
+	if _, domain, err := address.Split(s.msgMeta.OriginalFrom); err == nil && s.c.allowedDomain(domain) {
+		s.log.DebugMsg("greylisting deferred until DKIM verification", "rcpt", rcptTo)
+		s.greylisted = &res
+		return module.CheckResult{}
+	}
+
+	return res
+}
+
+// check updates the state of the triplet and returns the result for it.
+func (s *state) check(ctx context.Context, key string) module.CheckResult {
+	now := s.c.now()
+
+	val, ok, err := s.c.table.Lookup(ctx, key)
+	if err != nil {
+		// Greylisting is not critical enough to refuse mail when the
+		// table is not available.
+		s.log.Error("triplet lookup failed, accepting", err)
+		return module.CheckResult{}
+	}
+
+	var e entry
+	if ok {
+		e, err = parseEntry(val)
+		if err != nil {
+			s.log.Error("resetting triplet", err, "key", key)
+			ok = false
+		}
+	}
+
+	switch {
+	case !ok || s.c.expired(e, now):
+		e = entry{firstSeen: now}
+		s.log.DebugMsg("new triplet", "key", key)
+	case !e.lastAccepted.IsZero():
+		e.lastAccepted = now
+	case now.Sub(e.firstSeen) < s.c.delay:
+		s.log.DebugMsg("retried too early", "key", key)
+		return s.greylistResult(e, now)
+	default:
+		s.log.DebugMsg("triplet passed", "key", key, "delay", now.Sub(e.firstSeen).Round(time.Second))
+		e.lastAccepted = now
+	}
+
+	if err := s.c.table.SetKey(key, e.String()); err != nil {
+		s.log.Error("triplet update failed, accepting", err)
+		return module.CheckResult{}
+	}
+
+	if e.lastAccepted.IsZero() {
+		return s.greylistResult(e, now)
+	}
+	return module.CheckResult{}
+}
+
+func (s *state) greylistResult(e entry, now time.Time) module.CheckResult {
+	return module.CheckResult{
+		Reject: true,
+		Reason: &exterrors.SMTPError{
+			Code:         451,
+			EnhancedCode: exterrors.EnhancedCode{4, 7, 1},
+			Message:      "Greylisted, please try again later",
+			CheckName:    modName,
+			Misc: map[string]interface{}{
+				"retry_in": e.firstSeen.Add(s.c.delay).Sub(now).Round(time.Second).String(),
+			},
+		},
+	}
+}
+
+func (s *state) CheckBody(ctx context.Context, hdr textproto.Header, body buffer.Buffer) module.CheckResult {
+	if s.greylisted == nil {
+		return module.CheckResult{}
+	}
+
+	defer trace.StartRegion(ctx, "check.greylist/CheckBody").End()
+
+	_, domain, _ := address.Split(s.msgMeta.OriginalFrom)
+	if s.validDKIM(ctx, hdr, body, domain) {
+		s.log.DebugMsg("valid DKIM signature of allowed domain", "domain", domain)
+		return module.CheckResult{}
+	}
+	return *s.greylisted
+}
+
+// validDKIM reports whether the message has a valid DKIM signature of the
+// domain or its parent domain.
+func (s *state) validDKIM(ctx context.Context, hdr textproto.Header, body buffer.Buffer, domain string) bool {
+	b := bytes.Buffer{}
+	_ = textproto.WriteHeader(&b, hdr)
+	bodyRdr, err := body.Open()
+	if err != nil {
+		s.log.Error("failed to open body", err)
+		return false
+	}
+	defer bodyRdr.Close()
+
+	verifications, err := dkim.VerifyWithOptions(io.MultiReader(&b, bodyRdr), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			return s.c.resolver.LookupTXT(ctx, domain)
+		},
+	})
+	if err != nil {
+		s.log.Error("DKIM verification failed", err)
+		return false
+	}
+
+	domain = dns.FQDN(strings.ToLower(domain))
+	for _, verif := range verifications {
+		if verif.Err != nil {
+			continue
+		}
+		sigDomain := dns.FQDN(strings.ToLower(verif.Domain))
+		if domain == sigDomain || strings.HasSuffix(domain, "."+sigDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *state) Close() error {
+	return nil
+}
+
+// memoryTable is the table used if no table is configured.
+type memoryTable struct {
+	lock sync.RWMutex
+	m    map[string]string
+}
+
+func newMemoryTable() *memoryTable {
+	return &memoryTable{m: make(map[string]string)}
+}
+
+func (t *memoryTable) Lookup(_ context.Context, key string) (string, bool, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	val, ok := t.m[key]
+	return val, ok, nil
+}
+
+func (t *memoryTable) Keys() ([]string, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	keys := make([]string, 0, len(t.m))
+	for k := range t.m {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (t *memoryTable) SetKey(key, val string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.m[key] = val
+	return nil
+}
+
+func (t *memoryTable) RemoveKey(key string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.m, key)
+	return nil
+}
+
+func init() {
+	module.Register(modName, New)
+}
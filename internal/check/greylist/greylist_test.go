@@ -0,0 +1,124 @@
+package greylist
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	"github.com/foxcpp/go-mockdns"
+)
+
+func testCheck(t *testing.T, now *time.Time) *Check {
+	return &Check{
+		delay:       5 * time.Minute,
+		retryWindow: 4 * time.Hour,
+		expire:      36 * 24 * time.Hour,
+		ipv4Prefix:  24,
+		ipv6Prefix:  64,
+		table:       newMemoryTable(),
+		resolver:    &mockdns.Resolver{},
+		log:         testutils.Logger(t, modName),
+		now:         func() time.Time { return *now },
+	}
+}
+
+func checkRcpt(t *testing.T, c *Check, ip net.IP, authUser, from, rcpt string) module.CheckResult {
+	t.Helper()
+	msgMeta := &module.MsgMetadata{
+		ID:           "test",
+		OriginalFrom: from,
+		Conn: &module.ConnState{
+			Hostname:   "mx.example.org",
+			RemoteAddr: &net.TCPAddr{IP: ip, Port: 25},
+			AuthUser:   authUser,
+		},
+	}
+	st, err := c.CheckStateForMsg(context.Background(), msgMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	if res := st.CheckConnection(context.Background()); res.Reason != nil {
+		t.Fatal("unexpected CheckConnection result:", res.Reason)
+	}
+	if res := st.CheckSender(context.Background(), from); res.Reason != nil {
+		t.Fatal("unexpected CheckSender result:", res.Reason)
+	}
+	return st.CheckRcpt(context.Background(), rcpt)
+}
+
+func TestGreylist_Triplet(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := testCheck(t, &now)
+	ip := net.IPv4(192, 0, 2, 10)
+
+	expect := func(res module.CheckResult, greylisted bool) {
+		t.Helper()
+		if greylisted && (res.Reason == nil || !res.Reject) {
+			t.Fatal("expected the triplet to be greylisted")
+		}
+		if !greylisted && res.Reason != nil {
+			t.Fatal("unexpected rejection:", res.Reason)
+		}
+	}
+
+	expect(checkRcpt(t, c, ip, "", "foo@example.com", "bar@example.org"), true)
+
+	now = now.Add(1 * time.Minute)
+	expect(checkRcpt(t, c, ip, "", "foo@example.com", "bar@example.org"), true)
+
+	// Same client network, retried after the delay.
+	now = now.Add(5 * time.Minute)
+	expect(checkRcpt(t, c, net.IPv4(192, 0, 2, 20), "", "FOO@example.com", "bar@example.org"), false)
+
+	// Accepted triplets are not delayed again.
+	now = now.Add(24 * time.Hour)
+	expect(checkRcpt(t, c, ip, "", "foo@example.com", "bar@example.org"), false)
+
+	// Different recipient is a different triplet.
+	expect(checkRcpt(t, c, ip, "", "foo@example.com", "baz@example.org"), true)
+
+	// Retry after the retry window starts over.
+	now = now.Add(5 * time.Hour)
+	expect(checkRcpt(t, c, ip, "", "foo@example.com", "baz@example.org"), true)
+
+	// Accepted triplets expire.
+	now = now.Add(37 * 24 * time.Hour)
+	expect(checkRcpt(t, c, ip, "", "foo@example.com", "bar@example.org"), true)
+
+	c.cleanup()
+	keys, _ := c.table.Keys()
+	if len(keys) != 1 {
+		t.Fatal("expected expired triplets to be removed, got", keys)
+	}
+}
+
+func TestGreylist_Allowlist(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := testCheck(t, &now)
+	_, allowed, _ := net.ParseCIDR("198.51.100.0/24")
+	c.allowNets = []net.IPNet{*allowed}
+	c.allowAuthenticated = true
+	c.allowDomains = []string{dns.FQDN("example.com")}
+	c.resolver = &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"example.com.": {TXT: []string{"v=spf1 ip4:203.0.113.0/24 -all"}},
+	}}
+
+	if res := checkRcpt(t, c, net.IPv4(198, 51, 100, 1), "", "foo@example.net", "bar@example.org"); res.Reason != nil {
+		t.Error("allowed network is greylisted:", res.Reason)
+	}
+	if res := checkRcpt(t, c, net.IPv4(192, 0, 2, 1), "foo", "foo@example.net", "bar@example.org"); res.Reason != nil {
+		t.Error("authenticated user is greylisted:", res.Reason)
+	}
+	if res := checkRcpt(t, c, net.IPv4(203, 0, 113, 1), "", "foo@example.com", "bar@example.org"); res.Reason != nil {
+		t.Error("allowed domain passing SPF is greylisted:", res.Reason)
+	}
+	if res := checkRcpt(t, c, net.IPv4(192, 0, 2, 1), "", "foo@example.net", "bar@example.org"); res.Reason == nil {
+		t.Error("unknown triplet is not greylisted")
+	}
+}
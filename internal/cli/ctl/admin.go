@@ -0,0 +1,277 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dsoftgames/MailChat/internal/admin"
+	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	modulesCmd := &cobra.Command{
+		Use:   "modules",
+		Short: "List modules of the running server",
+		Long: `List endpoints and configuration blocks of the running server.
+
+With --health, modules that support it check whether they are able to work,
+e.g. whether the database is reachable. The command fails if any check
+fails.`,
+		Args: cobra.NoArgs,
+		RunE: modulesList,
+	}
+	modulesCmd.Flags().Bool("health", false, "Run health checks")
+
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Client sessions of the running server",
+		Long: `These subcommands can be used to inspect and close IMAP and SMTP
+connections of the running server.
+
+Connections received via a proxy using PROXY protocol are shown with the
+address of the proxy.`,
+	}
+
+	sessionsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List open connections",
+		Args:  cobra.NoArgs,
+		RunE:  sessionsList,
+	}
+	sessionsListCmd.Flags().String("endpoint", "", "Show only connections to the endpoint (imap, smtp, submission, lmtp)")
+	sessionsListCmd.Flags().String("user", "", "Show only connections authenticated as the user")
+
+	sessionsKickCmd := &cobra.Command{
+		Use:   "kick [ID...]",
+		Short: "Close connections",
+		Long:  `Close connections with the specified IDs and, with --user, all connections of the user.`,
+		RunE:  sessionsKick,
+	}
+	sessionsKickCmd.Flags().String("user", "", "Close all connections authenticated as the user")
+
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsKickCmd)
+
+	lockoutsCmd := &cobra.Command{
+		Use:   "auth-lockouts",
+		Short: "Brute-force protection state of the running server",
+		Long: `These subcommands can be used to inspect and clear failed authentication
+attempts tracked by the running server.
+
+Failures are counted per client IP, per IPv6 /64 network and per username,
+see auth_bruteforce directive.`,
+	}
+
+	lockoutsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List locked out clients and usernames",
+		Args:  cobra.NoArgs,
+		RunE:  lockoutsList,
+	}
+	lockoutsListCmd.Flags().Bool("all", false, "Also list entries with failed attempts that are not locked out")
+
+	lockoutsClearCmd := &cobra.Command{
+		Use:   "clear [VALUE...]",
+		Short: "Clear failed attempts",
+		Long: `Clear failed attempts and lockouts of the specified IP addresses, networks
+(as shown by the list command) or usernames.`,
+		RunE: lockoutsClear,
+	}
+	lockoutsClearCmd.Flags().Bool("all", false, "Clear all entries")
+
+	lockoutsCmd.AddCommand(lockoutsListCmd, lockoutsClearCmd)
+
+	reloadCmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Reload the configuration of the running server",
+		Long: `Reload the configuration file of the running server, same as sending
+SIGUSR2 to the server process.
+
+Open connections are served using the previous configuration until they are
+closed. If the new configuration is invalid, the command fails and the
+server keeps running with the previous one.`,
+		Args: cobra.NoArgs,
+		RunE: serverReload,
+	}
+
+	mailchatcli.AddSubcommand(modulesCmd)
+	mailchatcli.AddSubcommand(sessionsCmd)
+	mailchatcli.AddSubcommand(lockoutsCmd)
+	mailchatcli.AddSubcommand(reloadCmd)
+}
+
+func modulesList(cmd *cobra.Command, args []string) error {
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	health, _ := cmd.Flags().GetBool("health")
+	resp, err := api.ListModules(context.Background(), &admin.ListModulesRequest{Health: health})
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMODULE\tSTATUS\tHEALTH")
+	for _, mod := range resp.Modules {
+		status := "unused"
+		switch {
+		case mod.Endpoint:
+			status = "endpoint"
+		case mod.Initialized:
+			status = "active"
+		}
+		modHealth := mod.Health
+		switch modHealth {
+		case "":
+			modHealth = "-"
+		case "ok":
+		default:
+			failed = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mod.Instance, mod.Name, status, modHealth)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed {
+		return errors.New("some health checks failed")
+	}
+	return nil
+}
+
+func sessionsList(cmd *cobra.Command, args []string) error {
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	endpoint, _ := cmd.Flags().GetString("endpoint")
+	user, _ := cmd.Flags().GetString("user")
+	resp, err := api.ListSessions(context.Background(), &admin.ListSessionsRequest{
+		Endpoint: endpoint,
+		User:     user,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Sessions) == 0 {
+		fmt.Fprintln(os.Stderr, "No sessions.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tENDPOINT\tREMOTE\tLOCAL\tUSER\tSTARTED")
+	for _, sess := range resp.Sessions {
+		sessUser := sess.User
+		if sessUser == "" {
+			sessUser = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", sess.ID, sess.Endpoint, sess.RemoteAddr, sess.LocalAddr,
+			sessUser, formatQueueTime(sess.Started))
+	}
+	return w.Flush()
+}
+
+func sessionsKick(cmd *cobra.Command, args []string) error {
+	user, _ := cmd.Flags().GetString("user")
+	req := &admin.KickSessionsRequest{User: user}
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid session ID: %s", arg)
+		}
+		req.IDs = append(req.IDs, id)
+	}
+	if len(req.IDs) == 0 && req.User == "" {
+		return errors.New("Error: session IDs or --user are required")
+	}
+
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	resp, err := api.KickSessions(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Closed %d connection(s).\n", resp.Count)
+	return nil
+}
+
+func lockoutsList(cmd *cobra.Command, args []string) error {
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	all, _ := cmd.Flags().GetBool("all")
+	resp, err := api.ListAuthLockouts(context.Background(), &admin.ListAuthLockoutsRequest{All: all})
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Entries) == 0 {
+		fmt.Fprintln(os.Stderr, "No entries.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCOPE\tVALUE\tFAILURES\tLAST FAILURE\tLOCKED UNTIL")
+	for _, e := range resp.Entries {
+		lockedUntil := "-"
+		if e.LockedUntil.After(time.Now()) {
+			lockedUntil = formatQueueTime(e.LockedUntil)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", e.Scope, e.Value, e.Failures,
+			formatQueueTime(e.LastFailure), lockedUntil)
+	}
+	return w.Flush()
+}
+
+func lockoutsClear(cmd *cobra.Command, args []string) error {
+	all, _ := cmd.Flags().GetBool("all")
+	if len(args) == 0 && !all {
+		return errors.New("Error: values to clear or --all are required")
+	}
+
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	resp, err := api.ClearAuthLockouts(context.Background(), &admin.ClearAuthLockoutsRequest{
+		Values: args,
+		All:    all,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Cleared %d entries.\n", resp.Count)
+	return nil
+}
+
+func serverReload(cmd *cobra.Command, args []string) error {
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	_, err = api.Reload(context.Background(), &admin.ReloadRequest{})
+	return err
+}
@@ -19,61 +19,43 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package ctl
 
 import (
+	"context"
 	"fmt"
 
-	imapbackend "github.com/emersion/go-imap/backend"
+	"github.com/dsoftgames/MailChat/internal/admin"
 	"github.com/spf13/cobra"
-	"github.com/dsoftgames/MailChat/framework/module"
 )
 
-// Copied from go-imap-backend-tests.
-
-// AppendLimitUser is extension for backend.User interface which allows to
-// set append limit value for testing and administration purposes.
-type AppendLimitUser interface {
-	imapbackend.AppendLimitUser
-
-	// SetMessageLimit sets new value for limit.
-	// nil pointer means no limit.
-	SetMessageLimit(val *uint32) error
-}
-
-func imapAcctAppendlimit(be module.Storage, cmd *cobra.Command, args []string) error {
+func imapAcctAppendlimit(api admin.API, cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("USERNAME is required")
 	}
-	username := args[0]
-
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
-	}
-	userAL, ok := u.(AppendLimitUser)
-	if !ok {
-		return fmt.Errorf("module.Storage does not support per-user append limit")
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.AppendLimitRequest{
+		Block:    cfgBlock,
+		Username: args[0],
 	}
 
 	if cmd.Flags().Changed("value") {
 		val, _ := cmd.Flags().GetInt("value")
 
-		var err error
-		if val == -1 {
-			err = userAL.SetMessageLimit(nil)
-		} else {
+		req.Set = true
+		if val != -1 {
 			val32 := uint32(val)
-			err = userAL.SetMessageLimit(&val32)
-		}
-		if err != nil {
-			return err
-		}
-	} else {
-		lim := userAL.CreateMessageLimit()
-		if lim == nil {
-			fmt.Println("No limit")
-		} else {
-			fmt.Println(*lim)
+			req.Limit = &val32
 		}
+		_, err := api.AppendLimit(context.Background(), req)
+		return err
 	}
 
+	resp, err := api.AppendLimit(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if resp.Limit == nil {
+		fmt.Println("No limit")
+	} else {
+		fmt.Println(*resp.Limit)
+	}
 	return nil
 }
@@ -19,12 +19,13 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package ctl
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/bcrypt"
-	"github.com/dsoftgames/MailChat/internal/auth/pass_table"
+	"github.com/dsoftgames/MailChat/internal/admin"
 	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
 )
 
@@ -87,67 +88,66 @@ flag for subcommands.`,
 }
 
 func credsList(cmd *cobra.Command, args []string) error {
-	be, err := openUserDB(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
-	list, err := be.ListUsers()
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	list, err := api.ListUsers(context.Background(), &admin.UserRequest{Block: cfgBlock})
 	if err != nil {
 		return err
 	}
 
 	quiet, _ := cmd.Flags().GetBool("quiet")
-	if len(list) == 0 && !quiet {
+	if len(list.Usernames) == 0 && !quiet {
 		fmt.Fprintln(os.Stderr, "No users.")
 	}
 
-	for _, user := range list {
+	for _, user := range list.Usernames {
 		fmt.Println(user)
 	}
 	return nil
 }
 
 func credsCreate(cmd *cobra.Command, args []string) error {
-	be, err := openUserDB(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
-	username := args[0]
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.UserRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+	}
 
-	var pass string
 	if cmd.Flags().Changed("password") {
-		pass, _ = cmd.Flags().GetString("password")
+		req.Password, _ = cmd.Flags().GetString("password")
 	} else {
-		var err error
-		pass, err = mailchatcli.ReadPassword("Enter password for new user")
+		req.Password, err = mailchatcli.ReadPassword("Enter password for new user")
 		if err != nil {
 			return err
 		}
 	}
 
-	if beHash, ok := be.(*pass_table.Auth); ok {
-		hashAlgo, _ := cmd.Flags().GetString("hash")
-		bcryptCost, _ := cmd.Flags().GetInt("bcrypt-cost")
-		return beHash.CreateUserHash(username, pass, hashAlgo, pass_table.HashOpts{
-			BcryptCost: bcryptCost,
-		})
-	} else if cmd.Flags().Changed("hash") || cmd.Flags().Changed("bcrypt-cost") {
-		return fmt.Errorf("--hash cannot be used with non-pass_table credentials DB")
-	} else {
-		return be.CreateUser(username, pass)
+	if cmd.Flags().Changed("hash") || cmd.Flags().Changed("bcrypt-cost") {
+		req.Hash, _ = cmd.Flags().GetString("hash")
+		req.BcryptCost, _ = cmd.Flags().GetInt("bcrypt-cost")
 	}
+
+	_, err = api.CreateUser(context.Background(), req)
+	return err
 }
 
 func credsRemove(cmd *cobra.Command, args []string) error {
-	be, err := openUserDB(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
 	username := args[0]
 
@@ -158,28 +158,33 @@ func credsRemove(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return be.DeleteUser(username)
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	_, err = api.DeleteUser(context.Background(), &admin.UserRequest{Block: cfgBlock, Username: username})
+	return err
 }
 
 func credsPassword(cmd *cobra.Command, args []string) error {
-	be, err := openUserDB(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
-	username := args[0]
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.UserRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+	}
 
-	var pass string
 	if cmd.Flags().Changed("password") {
-		pass, _ = cmd.Flags().GetString("password")
+		req.Password, _ = cmd.Flags().GetString("password")
 	} else {
-		var err error
-		pass, err = mailchatcli.ReadPassword("Enter new password")
+		req.Password, err = mailchatcli.ReadPassword("Enter new password")
 		if err != nil {
 			return err
 		}
 	}
 
-	return be.SetUserPassword(username, pass)
-}
\ No newline at end of file
+	_, err = api.SetUserPassword(context.Background(), req)
+	return err
+}
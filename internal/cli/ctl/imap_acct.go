@@ -19,19 +19,16 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package ctl
 
 import (
+	"context"
 	"fmt"
 	"os"
 
-	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/admin"
 	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
 	"github.com/emersion/go-imap"
 	"github.com/spf13/cobra"
 )
 
-type SpecialUseUser interface {
-	CreateMailboxSpecial(name, specialUseAttr string) error
-}
-
 func init() {
 	imapAcctCmd := &cobra.Command{
 		Use:   "imap-acct",
@@ -106,117 +103,116 @@ APPENDLIMIT value (either global or per-account) cannot be larger than
 	appendlimitCmd.Flags().String("cfg-block", "local_mailboxes", "Module configuration block to use")
 	appendlimitCmd.Flags().IntP("value", "v", 0, "Set APPENDLIMIT to specified value (in bytes)")
 
-	imapAcctCmd.AddCommand(listCmd, createCmd, removeCmd, appendlimitCmd)
+	// Quota subcommand
+	quotaCmd := &cobra.Command{
+		Use:   "quota NAME",
+		Short: "Query or set account's or domain's storage quota",
+		Long: `NAME is either an account name or a domain name prefixed with '@'
+(e.g. @example.org). Domain limits apply to all accounts of the domain
+that do not have their own limits set, accounts and domains without limits
+use the defaults from server configuration.
+
+Without flags, the command shows the limits set for NAME and, for accounts,
+the current usage and effective limits.
+
+Limit values are either a number (storage limit accepts size suffixes,
+e.g. 1G), 0 for no limit or 'default' to remove the limit set for NAME.
+
+Changes take effect after quota_cache_ttl passes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: imapAcctQuotaCmd,
+	}
+	quotaCmd.Flags().String("cfg-block", "local_mailboxes", "Module configuration block to use")
+	quotaCmd.Flags().String("storage", "", "Set storage limit")
+	quotaCmd.Flags().String("messages", "", "Set messages count limit")
+	quotaCmd.Flags().Bool("reset", false, "Remove all limits set for NAME")
+
+	// Reindex subcommand
+	reindexCmd := &cobra.Command{
+		Use:   "reindex [USERNAME...]",
+		Short: "Rebuild full-text search index",
+		Long: `Remove the full-text search index of specified accounts and index
+all their messages again. All accounts are reindexed if no USERNAME is given.
+
+The index is maintained automatically if full_text_search is enabled for
+the storage, this command is needed only to index messages stored before
+it was enabled or to recover from failures.`,
+		RunE: imapAcctReindex,
+	}
+	reindexCmd.Flags().String("cfg-block", "local_mailboxes", "Module configuration block to use")
+
+	imapAcctCmd.AddCommand(listCmd, createCmd, removeCmd, appendlimitCmd, quotaCmd, reindexCmd)
 	mailchatcli.AddSubcommand(imapAcctCmd)
 }
 
 func imapAcctList(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
-
-	mbe, ok := be.(module.ManageableStorage)
-	if !ok {
-		return fmt.Errorf("storage backend does not support accounts management using MailChat command")
-	}
+	defer closeAPI()
 
-	list, err := mbe.ListIMAPAccts()
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	list, err := api.ListAccounts(context.Background(), &admin.AccountRequest{Block: cfgBlock})
 	if err != nil {
 		return err
 	}
 
-	if len(list) == 0 {
+	if len(list.Usernames) == 0 {
 		fmt.Fprintln(os.Stderr, "No users.")
 	}
 
-	for _, user := range list {
+	for _, user := range list.Usernames {
 		fmt.Println(user)
 	}
 	return nil
 }
 
 func imapAcctCreate(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
-
-	mbe, ok := be.(module.ManageableStorage)
-	if !ok {
-		return fmt.Errorf("storage backend does not support accounts management using MailChat command")
-	}
-
-	username := args[0]
-
-	if err := mbe.CreateIMAPAcct(username); err != nil {
-		return err
-	}
+	defer closeAPI()
 
-	act, err := mbe.GetIMAPAcct(username)
-	if err != nil {
-		return fmt.Errorf("failed to get user: %w", err)
-	}
-
-	suu, ok := act.(SpecialUseUser)
-	if !ok {
-		fmt.Fprintf(os.Stderr, "Note: Storage backend does not support SPECIAL-USE IMAP extension")
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.AccountRequest{
+		Block:      cfgBlock,
+		Username:   args[0],
+		SpecialUse: map[string]string{},
 	}
 
 	noSpecialUse, _ := cmd.Flags().GetBool("no-specialuse")
-	if noSpecialUse {
-		return nil
-	}
-
-	createMbox := func(name, specialUseAttr string) error {
-		if suu == nil {
-			return act.CreateMailbox(name)
+	if !noSpecialUse {
+		for flag, attr := range map[string]string{
+			"sent-name":    imap.SentAttr,
+			"trash-name":   imap.TrashAttr,
+			"junk-name":    imap.JunkAttr,
+			"drafts-name":  imap.DraftsAttr,
+			"archive-name": imap.ArchiveAttr,
+		} {
+			if name, _ := cmd.Flags().GetString(flag); name != "" {
+				req.SpecialUse[attr] = name
+			}
 		}
-		return suu.CreateMailboxSpecial(name, specialUseAttr)
 	}
 
-	if name, _ := cmd.Flags().GetString("sent-name"); name != "" {
-		if err := createMbox(name, imap.SentAttr); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create sent folder: %v", err)
-		}
-	}
-	if name, _ := cmd.Flags().GetString("trash-name"); name != "" {
-		if err := createMbox(name, imap.TrashAttr); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create trash folder: %v", err)
-		}
-	}
-	if name, _ := cmd.Flags().GetString("junk-name"); name != "" {
-		if err := createMbox(name, imap.JunkAttr); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create junk folder: %v", err)
-		}
-	}
-	if name, _ := cmd.Flags().GetString("drafts-name"); name != "" {
-		if err := createMbox(name, imap.DraftsAttr); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create drafts folder: %v", err)
-		}
+	resp, err := api.CreateAccount(context.Background(), req)
+	if err != nil {
+		return err
 	}
-	if name, _ := cmd.Flags().GetString("archive-name"); name != "" {
-		if err := createMbox(name, imap.ArchiveAttr); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create archive folder: %v", err)
-		}
+	for _, warn := range resp.Warnings {
+		fmt.Fprintln(os.Stderr, "Note:", warn)
 	}
-
 	return nil
 }
 
 func imapAcctRemove(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
-
-	mbe, ok := be.(module.ManageableStorage)
-	if !ok {
-		return fmt.Errorf("storage backend does not support accounts management using MailChat command")
-	}
+	defer closeAPI()
 
 	username := args[0]
 
@@ -227,15 +223,44 @@ func imapAcctRemove(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return mbe.DeleteIMAPAcct(username)
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	_, err = api.DeleteAccount(context.Background(), &admin.AccountRequest{Block: cfgBlock, Username: username})
+	return err
 }
 
 func imapAcctAppendlimitCmd(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
-	return imapAcctAppendlimit(be, cmd, args)
-}
\ No newline at end of file
+	return imapAcctAppendlimit(api, cmd, args)
+}
+
+func imapAcctQuotaCmd(cmd *cobra.Command, args []string) error {
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	return imapAcctQuota(api, cmd, args)
+}
+
+func imapAcctReindex(cmd *cobra.Command, args []string) error {
+	api, closeAPI, err := openAPI(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeAPI()
+
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	resp, err := api.Reindex(context.Background(), &admin.AccountRequest{Block: cfgBlock, Usernames: args})
+	if resp != nil {
+		for _, acct := range resp.Usernames {
+			fmt.Println(acct)
+		}
+	}
+	return err
+}
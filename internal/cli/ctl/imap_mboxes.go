@@ -19,8 +19,10 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package ctl
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/dsoftgames/MailChat/internal/admin"
 	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
 	"github.com/emersion/go-imap"
 	"github.com/spf13/cobra"
@@ -78,26 +80,24 @@ func init() {
 }
 
 func mboxesList(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
-	if err != nil {
-		return err
-	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-
-	u, err := be.GetIMAPAcct(username)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
+	defer closeAPI()
 
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
 	subscribed, _ := cmd.Flags().GetBool("subscribed")
-	mboxes, err := u.ListMailboxes(subscribed)
+	list, err := api.ListMailboxes(context.Background(), &admin.MailboxRequest{
+		Block:      cfgBlock,
+		Username:   args[0],
+		Subscribed: subscribed,
+	})
 	if err != nil {
 		return err
 	}
 
-	for _, mbox := range mboxes {
+	for _, mbox := range list.Mailboxes {
 		attrs := mbox.Attributes
 		if len(attrs) == 0 {
 			fmt.Printf("%s\n", mbox.Name)
@@ -114,62 +114,47 @@ func mboxesList(cmd *cobra.Command, args []string) error {
 }
 
 func mboxesCreate(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-	name := args[1]
+	defer closeAPI()
 
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.MailboxRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+		Mailbox:  args[1],
 	}
 
 	if cmd.Flags().Changed("special") {
 		special, _ := cmd.Flags().GetString("special")
-		var attr string
 		switch special {
 		case "archive":
-			attr = imap.ArchiveAttr
+			req.SpecialUse = imap.ArchiveAttr
 		case "drafts":
-			attr = imap.DraftsAttr
+			req.SpecialUse = imap.DraftsAttr
 		case "junk":
-			attr = imap.JunkAttr
+			req.SpecialUse = imap.JunkAttr
 		case "sent":
-			attr = imap.SentAttr
+			req.SpecialUse = imap.SentAttr
 		case "trash":
-			attr = imap.TrashAttr
+			req.SpecialUse = imap.TrashAttr
 		default:
 			return fmt.Errorf("unknown special-use attribute: %s", special)
 		}
-
-		if suu, ok := u.(SpecialUseUser); ok {
-			return suu.CreateMailboxSpecial(name, attr)
-		} else {
-			return fmt.Errorf("backend does not support SPECIAL-USE IMAP extension")
-		}
 	}
 
-	return u.CreateMailbox(name)
+	_, err = api.CreateMailbox(context.Background(), req)
+	return err
 }
 
 func mboxesRemove(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
-	if err != nil {
-		return err
-	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-	name := args[1]
-
-	u, err := be.GetIMAPAcct(username)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
+	defer closeAPI()
 
 	yes, _ := cmd.Flags().GetBool("yes")
 	if !yes {
@@ -178,24 +163,28 @@ func mboxesRemove(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return u.DeleteMailbox(name)
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	_, err = api.DeleteMailbox(context.Background(), &admin.MailboxRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+		Mailbox:  args[1],
+	})
+	return err
 }
 
 func mboxesRename(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-	oldName := args[1]
-	newName := args[2]
+	defer closeAPI()
 
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
-	}
-
-	return u.RenameMailbox(oldName, newName)
-}
\ No newline at end of file
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	_, err = api.RenameMailbox(context.Background(), &admin.MailboxRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+		Mailbox:  args[1],
+		NewName:  args[2],
+	})
+	return err
+}
@@ -19,14 +19,14 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package ctl
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"time"
 
+	"github.com/dsoftgames/MailChat/internal/admin"
 	"github.com/emersion/go-imap"
-	imapsql "github.com/foxcpp/go-imap-sql"
 	"github.com/spf13/cobra"
 	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
 )
@@ -95,211 +95,121 @@ func init() {
 }
 
 func msgsAdd(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
-	username := args[0]
-	name := args[1]
-
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
-	}
-
-	flags, _ := cmd.Flags().GetStringSlice("flag")
-	if flags == nil {
-		flags = []string{}
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.MessageRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+		Mailbox:  args[1],
+		Date:     time.Now(),
 	}
 
-	date := time.Now()
+	req.Flags, _ = cmd.Flags().GetStringSlice("flag")
 	if cmd.Flags().Changed("date") {
 		dateStr, _ := cmd.Flags().GetString("date")
-		parsedDate, err := time.Parse(time.RFC3339, dateStr)
+		req.Date, err = time.Parse(time.RFC3339, dateStr)
 		if err != nil {
 			return fmt.Errorf("invalid date format: %w", err)
 		}
-		date = parsedDate
-	}
-
-	buf := bytes.Buffer{}
-	if _, err := io.Copy(&buf, os.Stdin); err != nil {
-		return err
-	}
-
-	if buf.Len() == 0 {
-		return fmt.Errorf("empty message, refusing to continue")
 	}
 
-	status, err := u.Status(name, []imap.StatusItem{imap.StatusUidNext})
+	req.Body, err = io.ReadAll(os.Stdin)
 	if err != nil {
 		return err
 	}
 
-	if err := u.CreateMessage(name, flags, date, &buf, nil); err != nil {
+	resp, err := api.AddMessage(context.Background(), req)
+	if err != nil {
 		return err
 	}
-
-	// TODO: Use APPENDUID
-	fmt.Println(status.UidNext)
-
+	fmt.Println(resp.UID)
 	return nil
 }
 
-func msgsAddFlags(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
-	if err != nil {
-		return err
-	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-	mailbox := args[1]
-	seqsetStr := args[2]
-	flags := args[3:]
-
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
-	}
-
-	seq, err := imap.ParseSeqSet(seqsetStr)
-	if err != nil {
-		return err
-	}
-
-	_, mbox, err := u.GetMailbox(mailbox, true, nil)
+func msgsUpdateFlags(cmd *cobra.Command, args []string, remove bool) error {
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
+	defer closeAPI()
 
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
 	useUID, _ := cmd.Flags().GetBool("uid")
-	mboxB := mbox.(*imapsql.Mailbox)
+	_, err = api.UpdateMessageFlags(context.Background(), &admin.MessageRequest{
+		Block:       cfgBlock,
+		Username:    args[0],
+		Mailbox:     args[1],
+		UID:         useUID,
+		SeqSet:      args[2],
+		Flags:       args[3:],
+		RemoveFlags: remove,
+	})
+	return err
+}
 
-	return mboxB.UpdateMessagesFlags(useUID, seq, imap.AddFlags, false, flags)
+func msgsAddFlags(cmd *cobra.Command, args []string) error {
+	return msgsUpdateFlags(cmd, args, false)
 }
 
 func msgsRemoveFlags(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
-	if err != nil {
-		return err
-	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-	mailbox := args[1]
-	seqsetStr := args[2]
-	flags := args[3:]
-
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
-	}
-
-	seq, err := imap.ParseSeqSet(seqsetStr)
-	if err != nil {
-		return err
-	}
-
-	_, mbox, err := u.GetMailbox(mailbox, true, nil)
-	if err != nil {
-		return err
-	}
-
-	useUID, _ := cmd.Flags().GetBool("uid")
-	mboxB := mbox.(*imapsql.Mailbox)
-
-	return mboxB.UpdateMessagesFlags(useUID, seq, imap.RemoveFlags, false, flags)
+	return msgsUpdateFlags(cmd, args, true)
 }
 
 func msgsList(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
-	defer closeIfNeeded(be)
+	defer closeAPI()
 
-	username := args[0]
-	mailbox := args[1]
-
-	var seqsetStr string
-	if len(args) >= 3 {
-		seqsetStr = args[2]
-	} else {
-		seqsetStr = "1:*"
-	}
-
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	useUID, _ := cmd.Flags().GetBool("uid")
+	req := &admin.MessageRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+		Mailbox:  args[1],
+		UID:      useUID,
 	}
-
-	seq, err := imap.ParseSeqSet(seqsetStr)
-	if err != nil {
-		return err
+	if len(args) >= 3 {
+		req.SeqSet = args[2]
 	}
 
-	_, mbox, err := u.GetMailbox(mailbox, false, nil)
+	list, err := api.ListMessages(context.Background(), req)
 	if err != nil {
 		return err
 	}
 
-	useUID, _ := cmd.Flags().GetBool("uid")
-	items := []imap.FetchItem{imap.FetchFlags, imap.FetchInternalDate}
-	if useUID {
-		items = append(items, imap.FetchUid)
-	}
-
-	ch := make(chan *imap.Message, 1)
-	done := make(chan error, 1)
-	go func() {
-		done <- mbox.ListMessages(useUID, seq, items, ch)
-	}()
-
-	for msg := range ch {
+	for _, msg := range list.Messages {
 		if useUID {
-			fmt.Printf("UID %d: ", msg.Uid)
+			fmt.Printf("UID %d: ", msg.UID)
 		} else {
 			fmt.Printf("SeqNum %d: ", msg.SeqNum)
 		}
-		fmt.Printf("Flags: %v, Date: %s\n", msg.Flags, msg.InternalDate.Format(time.RFC3339))
+		fmt.Printf("Flags: %v, Date: %s\n", msg.Flags, msg.Date.Format(time.RFC3339))
 	}
-
-	return <-done
+	return nil
 }
 
 func msgsRemove(cmd *cobra.Command, args []string) error {
-	be, err := openStorage(cmd)
-	if err != nil {
-		return err
-	}
-	defer closeIfNeeded(be)
-
-	username := args[0]
-	name := args[1]
-	seqset := args[2]
-
 	useUID, _ := cmd.Flags().GetBool("uid")
 	if !useUID {
 		fmt.Fprintln(os.Stderr, "WARNING: --uid=true will be the default in future versions")
 	}
 
-	seq, err := imap.ParseSeqSet(seqset)
-	if err != nil {
+	if _, err := imap.ParseSeqSet(args[2]); err != nil {
 		return err
 	}
 
-	u, err := be.GetIMAPAcct(username)
-	if err != nil {
-		return err
-	}
-
-	_, mbox, err := u.GetMailbox(name, true, nil)
+	api, closeAPI, err := openAPI(cmd)
 	if err != nil {
 		return err
 	}
+	defer closeAPI()
 
 	yes, _ := cmd.Flags().GetBool("yes")
 	if !yes {
@@ -308,6 +218,13 @@ func msgsRemove(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	mboxB := mbox.(*imapsql.Mailbox)
-	return mboxB.DelMessages(useUID, seq)
-}
\ No newline at end of file
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	_, err = api.RemoveMessages(context.Background(), &admin.MessageRequest{
+		Block:    cfgBlock,
+		Username: args[0],
+		Mailbox:  args[1],
+		UID:      useUID,
+		SeqSet:   args[2],
+	})
+	return err
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/dsoftgames/MailChat"
@@ -30,6 +31,8 @@ import (
 	"github.com/dsoftgames/MailChat/framework/config"
 	"github.com/dsoftgames/MailChat/framework/hooks"
 	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/admin"
+	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
 	"github.com/dsoftgames/MailChat/internal/updatepipe"
 )
 
@@ -39,7 +42,9 @@ func closeIfNeeded(i interface{}) {
 	}
 }
 
-func getCfgBlockModule(cmd *cobra.Command) (map[string]interface{}, *mailchat.ModInfo, error) {
+// loadConfig reads the configuration file and registers module instances
+// defined in it without initializing them.
+func loadConfig(cmd *cobra.Command) (map[string]interface{}, []mailchat.ModInfo, error) {
 	cfgPath, _ := cmd.Flags().GetString("config")
 	if cfgPath == "" {
 		return nil, nil, fmt.Errorf("config is required")
@@ -68,6 +73,14 @@ func getCfgBlockModule(cmd *cobra.Command) (map[string]interface{}, *mailchat.Mo
 	if err != nil {
 		return nil, nil, err
 	}
+	return globals, mods, nil
+}
+
+func getCfgBlockModule(cmd *cobra.Command) (map[string]interface{}, *mailchat.ModInfo, error) {
+	globals, mods, err := loadConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer hooks.RunHooks(hooks.EventShutdown)
 
 	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
@@ -88,48 +101,112 @@ func getCfgBlockModule(cmd *cobra.Command) (map[string]interface{}, *mailchat.Mo
 	return globals, &mod, nil
 }
 
-func openStorage(cmd *cobra.Command) (module.Storage, error) {
-	globals, mod, err := getCfgBlockModule(cmd)
-	if err != nil {
-		return nil, err
-	}
+var (
+	adminSocket string
+	adminAddr   string
+	adminCert   string
+	adminKey    string
+	adminCA     string
+)
 
-	storage, ok := mod.Instance.(module.Storage)
-	if !ok {
-		cfgBlock, _ := cmd.Flags().GetString("cfg-block")
-		return nil, fmt.Errorf("configuration block %s is not an IMAP storage", cfgBlock)
-	}
+func init() {
+	mailchatcli.AddGlobalStringFlag("admin-socket", "Admin API socket of the running server", "MAILCHAT_ADMIN_SOCKET", "", &adminSocket)
+	mailchatcli.AddGlobalStringFlag("admin-addr", "Admin API TCP address of the remote server", "MAILCHAT_ADMIN_ADDR", "", &adminAddr)
+	mailchatcli.AddGlobalStringFlag("admin-cert", "Client certificate for --admin-addr", "MAILCHAT_ADMIN_CERT", "", &adminCert)
+	mailchatcli.AddGlobalStringFlag("admin-key", "Client certificate key for --admin-addr", "MAILCHAT_ADMIN_KEY", "", &adminKey)
+	mailchatcli.AddGlobalStringFlag("admin-ca", "CA certificate used to verify the server at --admin-addr", "MAILCHAT_ADMIN_CA", "", &adminCA)
+}
 
-	if err := mod.Instance.Init(config.NewMap(globals, mod.Cfg)); err != nil {
-		return nil, fmt.Errorf("Error: module initialization failed: %w", err)
+// openAPI returns the admin API client connected to the running server.
+//
+// If the server is not running, the API is implemented locally using the
+// modules initialized from the configuration. Returned function should be
+// called to close the client or the modules.
+func openAPI(cmd *cobra.Command) (admin.API, func(), error) {
+	if adminAddr != "" {
+		if adminCert == "" || adminKey == "" {
+			return nil, nil, fmt.Errorf("--admin-cert and --admin-key are required with --admin-addr")
+		}
+		tlsConfig, err := admin.ClientTLSConfig(adminCert, adminKey, adminCA)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := admin.DialTLS(adminAddr, tlsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c, func() { c.Close() }, nil
 	}
 
-	if updStore, ok := mod.Instance.(updatepipe.Backend); ok {
-		if err := updStore.EnableUpdatePipe(updatepipe.ModePush); err != nil && !errors.Is(err, os.ErrNotExist) {
-			fmt.Fprintf(os.Stderr, "Failed to initialize update pipe, do not remove messages from mailboxes open by clients: %v\n", err)
+	if adminSocket != "" {
+		c, err := admin.DialSocket(adminSocket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to the admin socket: %w", err)
 		}
-	} else {
-		fmt.Fprintf(os.Stderr, "No update pipe support, do not remove messages from mailboxes open by clients\n")
+		return c, func() { c.Close() }, nil
 	}
 
-	return storage, nil
-}
-
-func openUserDB(cmd *cobra.Command) (module.PlainUserDB, error) {
-	globals, mod, err := getCfgBlockModule(cmd)
+	globals, mods, err := loadConfig(cmd)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	userDB, ok := mod.Instance.(module.PlainUserDB)
-	if !ok {
-		cfgBlock, _ := cmd.Flags().GetString("cfg-block")
-		return nil, fmt.Errorf("configuration block %s is not a local credentials store", cfgBlock)
+	c, err := admin.DialSocket(admin.SocketPath())
+	if err == nil {
+		hooks.RunHooks(hooks.EventShutdown)
+		return c, func() { c.Close() }, nil
 	}
-
-	if err := mod.Instance.Init(config.NewMap(globals, mod.Cfg)); err != nil {
-		return nil, fmt.Errorf("Error: module initialization failed: %w", err)
+	// The socket is missing or left over by a stopped server, use the
+	// local modules. Other errors (e.g. no permission to access the
+	// socket) mean the server is running and modifying its state directly
+	// is not safe.
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, syscall.ECONNREFUSED) {
+		hooks.RunHooks(hooks.EventShutdown)
+		return nil, nil, fmt.Errorf("failed to connect to the admin socket: %w", err)
 	}
 
-	return userDB, nil
+	var initialized []module.Module
+	initModule := func(m mailchat.ModInfo) (module.Module, error) {
+		if err := m.Instance.Init(config.NewMap(globals, m.Cfg)); err != nil {
+			return nil, fmt.Errorf("Error: module initialization failed: %w", err)
+		}
+		initialized = append(initialized, m.Instance)
+
+		if updStore, ok := m.Instance.(updatepipe.Backend); ok {
+			if err := updStore.EnableUpdatePipe(updatepipe.ModePush); err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "Failed to initialize update pipe, do not remove messages from mailboxes open by clients: %v\n", err)
+			}
+		} else if _, ok := m.Instance.(module.Storage); ok {
+			fmt.Fprintf(os.Stderr, "No update pipe support, do not remove messages from mailboxes open by clients\n")
+		}
+		return m.Instance, nil
+	}
+
+	svc := &admin.Service{
+		Standalone: true,
+		Module: func(name string) (module.Module, error) {
+			inst, ok := module.LookupInstance(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown configuration block: %s", name)
+			}
+			for _, m := range initialized {
+				if m == inst {
+					return inst, nil
+				}
+			}
+			for _, m := range mods {
+				if m.Instance == inst {
+					return initModule(m)
+				}
+			}
+			return nil, fmt.Errorf("unknown configuration block: %s", name)
+		},
+	}
+
+	return svc, func() {
+		for _, m := range initialized {
+			closeIfNeeded(m)
+		}
+		hooks.RunHooks(hooks.EventShutdown)
+	}, nil
 }
@@ -0,0 +1,88 @@
+package ctl
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
+	"github.com/dsoftgames/MailChat/internal/notary"
+)
+
+func init() {
+	notaryCmd := &cobra.Command{
+		Use:   "notary",
+		Short: "Message notarization proofs",
+		Long: `These subcommands can be used to retrieve inclusion proofs for messages
+notarized by modify.notarize.
+
+The corresponding modifier should be configured in mailchat.conf and be
+defined in a top-level configuration block. By default, the name of that
+block should be notary but this can be changed using --cfg-block
+flag for subcommands.`,
+	}
+
+	proofCmd := &cobra.Command{
+		Use:   "proof HASH|FILE",
+		Short: "Print the inclusion proof for a message",
+		Long: `Print the inclusion proof for a message identified either by its hash
+(as shown in the X-MailChat-Notary header field) or by the path to the message
+file.
+
+The proof is printed in JSON format and can be checked against the chain
+using 'MailChatd notary verify'.`,
+		Args: cobra.ExactArgs(1),
+		RunE: notaryProof,
+	}
+	proofCmd.Flags().String("cfg-block", "notary", "Module configuration block to use")
+
+	notaryCmd.AddCommand(proofCmd)
+	mailchatcli.AddSubcommand(notaryCmd)
+}
+
+type proofSource interface {
+	Proof(msgHash string) (*notary.Proof, error)
+}
+
+func notaryProof(cmd *cobra.Command, args []string) error {
+	msgHash := args[0]
+	if f, err := os.Open(args[0]); err == nil {
+		_, recorded, err := notary.HashNotarized(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		msgHash = hex.EncodeToString(recorded)
+	}
+
+	globals, mod, err := getCfgBlockModule(cmd)
+	if err != nil {
+		return err
+	}
+	src, ok := mod.Instance.(proofSource)
+	if !ok {
+		cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+		return fmt.Errorf("configuration block %s is not a notarization modifier", cfgBlock)
+	}
+	if err := mod.Instance.Init(config.NewMap(globals, mod.Cfg)); err != nil {
+		return fmt.Errorf("Error: module initialization failed: %w", err)
+	}
+	defer closeIfNeeded(mod.Instance)
+
+	p, err := src.Proof(msgHash)
+	if err != nil {
+		if errors.Is(err, notary.ErrNoProof) {
+			return fmt.Errorf("message %s is not anchored yet", msgHash)
+		}
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
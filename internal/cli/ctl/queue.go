@@ -0,0 +1,307 @@
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	mailchatcli "github.com/dsoftgames/MailChat/internal/cli"
+	"github.com/dsoftgames/MailChat/internal/target/queue"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	queueCmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Outbound queue inspection and management",
+		Long: `These subcommands can be used to inspect and manage messages in
+target.queue spool.
+
+The queue should be defined in a top-level configuration block. By default,
+the name of that block should be remote_queue but this can be changed using
+--cfg-block flag for subcommands.
+
+Commands are executed by the running server. If the server is not running,
+only list and show subcommands are available and they read the spool
+directly.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List queued messages",
+		Args:  cobra.NoArgs,
+		RunE:  queueList,
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show ID",
+		Short: "Show message details",
+		Args:  cobra.ExactArgs(1),
+		RunE:  queueShow,
+	}
+
+	retryCmd := &cobra.Command{
+		Use:   "retry ID...",
+		Short: "Attempt delivery now",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return queueEach(cmd, args, (*queue.AdminClient).Retry)
+		},
+	}
+
+	holdCmd := &cobra.Command{
+		Use:   "hold ID...",
+		Short: "Stop delivery attempts until the message is released",
+		Long: `Held messages are kept in the queue, including across server restarts,
+but not delivered until released using 'release' subcommand.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return queueEach(cmd, args, (*queue.AdminClient).Hold)
+		},
+	}
+
+	releaseCmd := &cobra.Command{
+		Use:   "release ID...",
+		Short: "Release held messages and attempt delivery now",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return queueEach(cmd, args, (*queue.AdminClient).Release)
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Remove messages from the queue without notifying senders",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !confirmQueueChange(cmd, "Remove %d message(s) from the queue?", len(args)) {
+				return errors.New("Cancelled")
+			}
+			return queueEach(cmd, args, (*queue.AdminClient).Delete)
+		},
+	}
+	deleteCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+
+	bounceCmd := &cobra.Command{
+		Use:   "bounce ID...",
+		Short: "Remove messages from the queue and send failure notifications",
+		Long: `The delivery status notification is sent to the sender of each
+message using the bounce {} pipeline of the queue.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !confirmQueueChange(cmd, "Bounce %d message(s)?", len(args)) {
+				return errors.New("Cancelled")
+			}
+			return queueEach(cmd, args, (*queue.AdminClient).Bounce)
+		},
+	}
+	bounceCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+
+	purgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove messages by recipient domain or sender",
+		Long: `With --domain, recipients at the domain are removed from all queued
+messages. With --sender, all messages from the sender are removed. Messages
+without recipients left are removed from the queue. Senders are not notified.
+
+Messages that are being delivered at the moment are skipped.`,
+		Args: cobra.NoArgs,
+		RunE: queuePurge,
+	}
+	purgeCmd.Flags().String("domain", "", "Recipient domain")
+	purgeCmd.Flags().String("sender", "", "Sender address")
+	purgeCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+
+	for _, cmd := range []*cobra.Command{listCmd, showCmd, retryCmd, holdCmd, releaseCmd, deleteCmd, bounceCmd, purgeCmd} {
+		cmd.Flags().String("cfg-block", "remote_queue", "Module configuration block to use")
+	}
+
+	queueCmd.AddCommand(listCmd, showCmd, retryCmd, holdCmd, releaseCmd, deleteCmd, bounceCmd, purgeCmd)
+	mailchatcli.AddSubcommand(queueCmd)
+}
+
+// openQueue initializes the queue defined in the configuration block
+// without starting deliveries.
+func openQueue(cmd *cobra.Command) (*queue.Queue, error) {
+	globals, mod, err := getCfgBlockModule(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	q, ok := mod.Instance.(*queue.Queue)
+	if !ok {
+		cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+		return nil, fmt.Errorf("configuration block %s is not a target.queue", cfgBlock)
+	}
+
+	// Does not start deliveries, see module.NoRun.
+	if err := q.Init(config.NewMap(globals, mod.Cfg)); err != nil {
+		return nil, fmt.Errorf("Error: module initialization failed: %w", err)
+	}
+	return q, nil
+}
+
+func dialQueue(cmd *cobra.Command) (*queue.AdminClient, error) {
+	q, err := openQueue(cmd)
+	if err != nil {
+		return nil, err
+	}
+	c, err := queue.DialAdmin(q.Location())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the server, is it running? %w", err)
+	}
+	return c, nil
+}
+
+func confirmQueueChange(cmd *cobra.Command, format string, args ...interface{}) bool {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return true
+	}
+	return mailchatcli.Confirmation(fmt.Sprintf(format, args...), false)
+}
+
+func queueEach(cmd *cobra.Command, ids []string, op func(*queue.AdminClient, string) error) error {
+	c, err := dialQueue(cmd)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	failed := false
+	for _, id := range ids {
+		if err := op(c, id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("some operations failed")
+	}
+	return nil
+}
+
+func formatQueueTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func queueStatus(info queue.MessageInfo) string {
+	switch {
+	case info.Held:
+		return "held"
+	case info.NextAttempt.IsZero():
+		return "-"
+	}
+	return formatQueueTime(info.NextAttempt)
+}
+
+func queueList(cmd *cobra.Command, args []string) error {
+	q, err := openQueue(cmd)
+	if err != nil {
+		return err
+	}
+
+	var msgs []queue.MessageInfo
+	if c, err := queue.DialAdmin(q.Location()); err == nil {
+		defer c.Close()
+		msgs, err = c.List()
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "Server is not running, reading the spool directly.")
+		msgs, err = q.ReadSpool()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(msgs) == 0 {
+		fmt.Fprintln(os.Stderr, "Queue is empty.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSIZE\tFROM\tRCPTS\tTRIES\tQUEUED\tNEXT ATTEMPT")
+	for _, info := range msgs {
+		from := info.From
+		if from == "" {
+			from = "<>"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t%s\t%s\n", info.ID, info.Size, from, len(info.To),
+			info.Tries, formatQueueTime(info.FirstAttempt), queueStatus(info))
+	}
+	return w.Flush()
+}
+
+func queueShow(cmd *cobra.Command, args []string) error {
+	q, err := openQueue(cmd)
+	if err != nil {
+		return err
+	}
+
+	var info queue.MessageInfo
+	if c, err := queue.DialAdmin(q.Location()); err == nil {
+		defer c.Close()
+		info, err = c.Show(args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "Server is not running, reading the spool directly.")
+		info, err = q.ReadMessage(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("ID:", info.ID)
+	fmt.Println("From:", info.From)
+	fmt.Println("Size:", info.Size)
+	fmt.Println("Queued:", formatQueueTime(info.FirstAttempt))
+	fmt.Println("Last attempt:", formatQueueTime(info.LastAttempt))
+	fmt.Println("Next attempt:", queueStatus(info))
+	fmt.Println("Recipients:")
+	for _, rcpt := range info.To {
+		line := "  " + rcpt
+		if rcptErr := info.RcptErrs[rcpt]; rcptErr != nil {
+			line += ": " + strconv.Itoa(rcptErr.Code) + " " + rcptErr.Message
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+	fmt.Print(strings.ReplaceAll(info.Header, "\r\n", "\n"))
+	return nil
+}
+
+func queuePurge(cmd *cobra.Command, args []string) error {
+	domain, _ := cmd.Flags().GetString("domain")
+	sender, _ := cmd.Flags().GetString("sender")
+	if domain == "" && sender == "" {
+		return errors.New("Error: --domain or --sender is required")
+	}
+
+	c, err := dialQueue(cmd)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if !confirmQueueChange(cmd, "Remove queued messages matching the criteria?") {
+		return errors.New("Cancelled")
+	}
+
+	removed, err := c.Purge(domain, sender)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d message(s).\n", removed)
+	return nil
+}
@@ -0,0 +1,103 @@
+package ctl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/admin"
+	"github.com/spf13/cobra"
+)
+
+// parseLimitFlag parses the value of --storage or --messages flag. It
+// returns nil for "default", meaning the limit is inherited.
+func parseLimitFlag(val string, dataSize bool) (*uint64, error) {
+	if val == "default" {
+		return nil, nil
+	}
+	var limit uint64
+	if dataSize && val != "0" {
+		size, err := config.ParseDataSize(val)
+		if err != nil {
+			return nil, err
+		}
+		limit = uint64(size)
+	} else {
+		var err error
+		limit, err = strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &limit, nil
+}
+
+func formatLimit(limit *uint64, unit string) string {
+	switch {
+	case limit == nil:
+		return "default"
+	case *limit == 0:
+		return "no limit"
+	}
+	return strconv.FormatUint(*limit, 10) + unit
+}
+
+func formatUsage(usage, limit uint64, unit string) string {
+	if limit == 0 {
+		return strconv.FormatUint(usage, 10) + unit + " (no limit)"
+	}
+	return fmt.Sprintf("%d%s of %d%s (%d%%)", usage, unit, limit, unit, usage*100/limit)
+}
+
+func imapAcctQuota(api admin.API, cmd *cobra.Command, args []string) error {
+	cfgBlock, _ := cmd.Flags().GetString("cfg-block")
+	req := &admin.QuotaRequest{
+		Block: cfgBlock,
+		Name:  args[0],
+	}
+
+	resp, err := api.Quota(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	limits := resp.Limits
+
+	reset, _ := cmd.Flags().GetBool("reset")
+	changed := reset
+	if reset {
+		limits = module.QuotaLimits{}
+	}
+	if cmd.Flags().Changed("storage") {
+		val, _ := cmd.Flags().GetString("storage")
+		limits.Storage, err = parseLimitFlag(val, true)
+		if err != nil {
+			return fmt.Errorf("invalid --storage value: %w", err)
+		}
+		changed = true
+	}
+	if cmd.Flags().Changed("messages") {
+		val, _ := cmd.Flags().GetString("messages")
+		limits.Messages, err = parseLimitFlag(val, false)
+		if err != nil {
+			return fmt.Errorf("invalid --messages value: %w", err)
+		}
+		changed = true
+	}
+	if changed {
+		req.Set = true
+		req.Limits = limits
+		_, err := api.Quota(context.Background(), req)
+		return err
+	}
+
+	fmt.Println("Storage limit:", formatLimit(limits.Storage, " bytes"))
+	fmt.Println("Messages limit:", formatLimit(limits.Messages, ""))
+
+	if q := resp.Usage; q != nil {
+		fmt.Println("Storage used:", formatUsage(q.Storage, q.StorageLimit, " bytes"))
+		fmt.Println("Messages stored:", formatUsage(q.Messages, q.MessagesLimit, ""))
+	}
+	return nil
+}
@@ -39,4 +39,9 @@ const (
 	PolicyNone       = dmarc.PolicyNone
 	PolicyReject     = dmarc.PolicyReject
 	PolicyQuarantine = dmarc.PolicyQuarantine
+
+	FailureAll  = dmarc.FailureAll
+	FailureAny  = dmarc.FailureAny
+	FailureDKIM = dmarc.FailureDKIM
+	FailureSPF  = dmarc.FailureSPF
 )
@@ -0,0 +1,216 @@
+package dmarc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-msgauth/authres"
+)
+
+// ReportRecord is the outcome of the DMARC evaluation for a single message,
+// kept for aggregate reporting (RFC 7489 Section 7.2).
+type ReportRecord struct {
+	Time       time.Time `json:"time"`
+	SourceIP   string    `json:"source_ip"`
+	HeaderFrom string    `json:"header_from"`
+
+	// Domain the policy was found at and the published policy itself.
+	PolicyDomain string          `json:"policy_domain"`
+	Policy       PolicyPublished `json:"policy"`
+	ReportURIs   []string        `json:"rua,omitempty"`
+
+	Disposition Policy   `json:"disposition"`
+	DKIMAligned bool     `json:"dkim_aligned"`
+	SPFAligned  bool     `json:"spf_aligned"`
+	Reasons     []string `json:"reasons,omitempty"`
+
+	DKIM []DKIMAuthResult `json:"dkim,omitempty"`
+	SPF  []SPFAuthResult  `json:"spf,omitempty"`
+}
+
+func (rec ReportRecord) RecordTime() time.Time {
+	return rec.Time
+}
+
+// PolicyPublished is the subset of the DMARC record that is included into
+// reports.
+type PolicyPublished struct {
+	ADKIM string `json:"adkim"`
+	ASPF  string `json:"aspf"`
+	P     Policy `json:"p"`
+	SP    Policy `json:"sp"`
+	Pct   int    `json:"pct"`
+}
+
+type DKIMAuthResult struct {
+	Domain string `json:"domain"`
+	Result string `json:"result"`
+}
+
+type SPFAuthResult struct {
+	Domain string `json:"domain"`
+	// Scope is either "mfrom" or "helo".
+	Scope  string `json:"scope"`
+	Result string `json:"result"`
+}
+
+// Reason types for policy overrides as defined in RFC 7489 Appendix C.
+const (
+	ReasonSampledOut       = "sampled_out"
+	ReasonTrustedForwarder = "trusted_forwarder"
+)
+
+// FailureReport is the information about a single message that failed
+// authentication, used to generate the failure report (RFC 7489 Section 7.3).
+type FailureReport struct {
+	Record ReportRecord
+
+	// ruf URIs and the report interval (ri) from the policy.
+	ReportURIs []string
+	Interval   time.Duration
+
+	MailFrom string
+	Header   textproto.Header
+	// Authentication-Results field value for the message.
+	AuthResults string
+}
+
+// Reporter is implemented by modules that collect DMARC evaluation results
+// to send reports.
+type Reporter interface {
+	RecordResult(rec ReportRecord) error
+
+	// ReportFailure is called for messages that should be reported
+	// according to the ruf and fo tags of the policy.
+	ReportFailure(rep FailureReport) error
+}
+
+func newPolicyPublished(rec *Record) PolicyPublished {
+	p := PolicyPublished{
+		ADKIM: string(rec.DKIMAlignment),
+		ASPF:  string(rec.SPFAlignment),
+		P:     rec.Policy,
+		SP:    rec.SubdomainPolicy,
+		Pct:   100,
+	}
+	if p.ADKIM == "" {
+		p.ADKIM = "r"
+	}
+	if p.ASPF == "" {
+		p.ASPF = "r"
+	}
+	if p.SP == "" {
+		p.SP = p.P
+	}
+	if rec.Percent != nil {
+		p.Pct = *rec.Percent
+	}
+	return p
+}
+
+// ReportRecord returns the data needed to include the message into
+// aggregate reports. It should be called after Apply with the same authRes
+// slice and values returned by Apply.
+//
+// ok is false if there was no DMARC policy for the message or the result
+// can not be determined (required checks are disabled or temporary errors
+// happened). SourceIP is not filled.
+func (v *Verifier) ReportRecord(authRes []authres.Result, res EvalResult, disposition Policy) (rec ReportRecord, ok bool) {
+	if v.applied.record == nil {
+		return ReportRecord{}, false
+	}
+	if res.Authres.Value != authres.ResultPass && res.Authres.Value != authres.ResultFail {
+		return ReportRecord{}, false
+	}
+
+	rec = ReportRecord{
+		Time:         time.Now().UTC(),
+		HeaderFrom:   v.applied.fromDomain,
+		PolicyDomain: strings.ToLower(v.applied.policyDomain),
+		Policy:       newPolicyPublished(v.applied.record),
+		ReportURIs:   v.applied.record.ReportURIAggregate,
+		Disposition:  disposition,
+		DKIMAligned:  res.DKIMAligned,
+		SPFAligned:   res.SPFAligned,
+	}
+	if v.sampledOut {
+		rec.Reasons = append(rec.Reasons, ReasonSampledOut)
+	}
+	if v.arcSealer != "" {
+		rec.Reasons = append(rec.Reasons, ReasonTrustedForwarder)
+	}
+
+	for _, res := range authRes {
+		switch res := res.(type) {
+		case *authres.DKIMResult:
+			rec.DKIM = append(rec.DKIM, DKIMAuthResult{
+				Domain: res.Domain,
+				Result: string(res.Value),
+			})
+		case *authres.SPFResult:
+			spf := SPFAuthResult{
+				Domain: res.From,
+				Scope:  "mfrom",
+				Result: string(res.Value),
+			}
+			if spf.Domain == "" {
+				spf.Domain = res.Helo
+				spf.Scope = "helo"
+			}
+			rec.SPF = append(rec.SPF, spf)
+		}
+	}
+
+	return rec, true
+}
+
+// FailureReport checks whether the policy requests a failure report for the
+// message described by rec (as returned by ReportRecord) and returns the
+// report with the message-specific fields (MailFrom, Header, AuthResults) left
+// empty.
+func (v *Verifier) FailureReport(rec ReportRecord) (FailureReport, bool) {
+	record := v.applied.record
+	if record == nil || len(record.ReportURIFailure) == 0 {
+		return FailureReport{}, false
+	}
+
+	opts := record.FailureOptions
+	if opts == 0 {
+		opts = FailureAll
+	}
+	report := false
+	if opts&FailureAll != 0 && !rec.DKIMAligned && !rec.SPFAligned {
+		report = true
+	}
+	if opts&FailureAny != 0 && (!rec.DKIMAligned || !rec.SPFAligned) {
+		report = true
+	}
+	if opts&FailureDKIM != 0 {
+		for _, dkim := range rec.DKIM {
+			if dkim.Result == string(authres.ResultFail) {
+				report = true
+			}
+		}
+	}
+	if opts&FailureSPF != 0 {
+		for _, spf := range rec.SPF {
+			if spf.Result == string(authres.ResultFail) {
+				report = true
+			}
+		}
+	}
+	if !report {
+		return FailureReport{}, false
+	}
+
+	interval := record.ReportInterval
+	if interval == 0 {
+		interval = 24 * time.Hour
+	}
+	return FailureReport{
+		Record:     rec,
+		ReportURIs: record.ReportURIFailure,
+		Interval:   interval,
+	}, true
+}
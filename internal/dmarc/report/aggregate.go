@@ -0,0 +1,216 @@
+package report
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/internal/dmarc"
+)
+
+// Feedback is the aggregate report as defined in RFC 7489 Appendix C.
+type Feedback struct {
+	XMLName         xml.Name        `xml:"feedback"`
+	Version         string          `xml:"version"`
+	Metadata        Metadata        `xml:"report_metadata"`
+	PolicyPublished PolicyPublished `xml:"policy_published"`
+	Records         []Record        `xml:"record"`
+}
+
+type Metadata struct {
+	OrgName   string    `xml:"org_name"`
+	Email     string    `xml:"email"`
+	ReportID  string    `xml:"report_id"`
+	DateRange DateRange `xml:"date_range"`
+}
+
+type DateRange struct {
+	Begin int64 `xml:"begin"`
+	End   int64 `xml:"end"`
+}
+
+type PolicyPublished struct {
+	Domain string `xml:"domain"`
+	ADKIM  string `xml:"adkim"`
+	ASPF   string `xml:"aspf"`
+	P      string `xml:"p"`
+	SP     string `xml:"sp"`
+	Pct    int    `xml:"pct"`
+}
+
+type Record struct {
+	Row         Row         `xml:"row"`
+	Identifiers Identifiers `xml:"identifiers"`
+	AuthResults AuthResults `xml:"auth_results"`
+}
+
+type Row struct {
+	SourceIP        string          `xml:"source_ip"`
+	Count           int             `xml:"count"`
+	PolicyEvaluated PolicyEvaluated `xml:"policy_evaluated"`
+}
+
+type PolicyEvaluated struct {
+	Disposition string         `xml:"disposition"`
+	DKIM        string         `xml:"dkim"`
+	SPF         string         `xml:"spf"`
+	Reasons     []PolicyReason `xml:"reason,omitempty"`
+}
+
+type PolicyReason struct {
+	Type string `xml:"type"`
+}
+
+type Identifiers struct {
+	HeaderFrom string `xml:"header_from"`
+}
+
+type AuthResults struct {
+	DKIM []DKIMResult `xml:"dkim,omitempty"`
+	SPF  []SPFResult  `xml:"spf"`
+}
+
+type DKIMResult struct {
+	Domain string `xml:"domain"`
+	Result string `xml:"result"`
+}
+
+type SPFResult struct {
+	Domain string `xml:"domain"`
+	Scope  string `xml:"scope"`
+	Result string `xml:"result"`
+}
+
+func alignmentResult(aligned bool) string {
+	if aligned {
+		return "pass"
+	}
+	return "fail"
+}
+
+func newRecord(rec dmarc.ReportRecord) Record {
+	r := Record{
+		Row: Row{
+			SourceIP: rec.SourceIP,
+			Count:    1,
+			PolicyEvaluated: PolicyEvaluated{
+				Disposition: string(rec.Disposition),
+				DKIM:        alignmentResult(rec.DKIMAligned),
+				SPF:         alignmentResult(rec.SPFAligned),
+			},
+		},
+		Identifiers: Identifiers{
+			HeaderFrom: rec.HeaderFrom,
+		},
+	}
+	for _, reason := range rec.Reasons {
+		r.Row.PolicyEvaluated.Reasons = append(r.Row.PolicyEvaluated.Reasons, PolicyReason{Type: reason})
+	}
+	for _, dkim := range rec.DKIM {
+		r.AuthResults.DKIM = append(r.AuthResults.DKIM, DKIMResult{Domain: dkim.Domain, Result: dkim.Result})
+	}
+	for _, spf := range rec.SPF {
+		r.AuthResults.SPF = append(r.AuthResults.SPF, SPFResult{Domain: spf.Domain, Scope: spf.Scope, Result: spf.Result})
+	}
+	if len(r.AuthResults.SPF) == 0 {
+		// spf element is mandatory.
+		r.AuthResults.SPF = []SPFResult{{Domain: rec.HeaderFrom, Scope: "mfrom", Result: "none"}}
+	}
+	return r
+}
+
+// Aggregate builds the aggregate report for the policy domain. Records are
+// expected to be from the period in meta.DateRange. Identical results from
+// the same source are merged into a single row.
+//
+// Policy published is taken from the most recent record. Records for other
+// domains are ignored. nil is returned if there are no records for the
+// domain.
+func Aggregate(meta Metadata, policyDomain string, records []dmarc.ReportRecord) *Feedback {
+	var (
+		latest *dmarc.ReportRecord
+		rows   = map[string]int{}
+		fb     = &Feedback{Version: "1.0", Metadata: meta}
+	)
+	for i, rec := range records {
+		if !strings.EqualFold(rec.PolicyDomain, policyDomain) {
+			continue
+		}
+		if latest == nil || !rec.Time.Before(latest.Time) {
+			latest = &records[i]
+		}
+
+		r := newRecord(rec)
+		key := rowKey(r)
+		if idx, ok := rows[key]; ok {
+			fb.Records[idx].Row.Count++
+			continue
+		}
+		rows[key] = len(fb.Records)
+		fb.Records = append(fb.Records, r)
+	}
+	if latest == nil {
+		return nil
+	}
+
+	fb.PolicyPublished = PolicyPublished{
+		Domain: policyDomain,
+		ADKIM:  latest.Policy.ADKIM,
+		ASPF:   latest.Policy.ASPF,
+		P:      string(latest.Policy.P),
+		SP:     string(latest.Policy.SP),
+		Pct:    latest.Policy.Pct,
+	}
+	sort.SliceStable(fb.Records, func(i, j int) bool {
+		return fb.Records[i].Row.SourceIP < fb.Records[j].Row.SourceIP
+	})
+	return fb
+}
+
+func rowKey(r Record) string {
+	r.Row.Count = 0
+	blob, _ := xml.Marshal(r)
+	return string(blob)
+}
+
+// PolicyDomains returns the list of policy domains the records have been
+// collected for, sorted.
+func PolicyDomains(records []dmarc.ReportRecord) []string {
+	set := map[string]struct{}{}
+	for _, rec := range records {
+		set[strings.ToLower(rec.PolicyDomain)] = struct{}{}
+	}
+	domains := make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// LatestReportURIs returns the rua URIs published by the domain at the time
+// of the most recent evaluation.
+func LatestReportURIs(policyDomain string, records []dmarc.ReportRecord) []string {
+	var (
+		uris   []string
+		latest time.Time
+	)
+	for _, rec := range records {
+		if !strings.EqualFold(rec.PolicyDomain, policyDomain) || rec.Time.Before(latest) {
+			continue
+		}
+		latest = rec.Time
+		uris = rec.ReportURIs
+	}
+	return uris
+}
+
+// Marshal returns the XML document for the report.
+func (fb *Feedback) Marshal() ([]byte, error) {
+	blob, err := xml.MarshalIndent(fb, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), blob...), nil
+}
@@ -0,0 +1,156 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/dsoftgames/MailChat/internal/dmarc"
+)
+
+// redactedValue replaces values of the redacted header fields.
+const redactedValue = "[redacted]"
+
+func deliveryResult(disposition dmarc.Policy) string {
+	switch disposition {
+	case dmarc.PolicyNone:
+		return "delivered"
+	case dmarc.PolicyQuarantine:
+		return "spam"
+	case dmarc.PolicyReject:
+		return "reject"
+	default:
+		return "other"
+	}
+}
+
+func identityAlignment(rec dmarc.ReportRecord) string {
+	var aligned []string
+	if rec.DKIMAligned {
+		aligned = append(aligned, "dkim")
+	}
+	if rec.SPFAligned {
+		aligned = append(aligned, "spf")
+	}
+	if len(aligned) == 0 {
+		return "none"
+	}
+	return strings.Join(aligned, ",")
+}
+
+// RedactHeader returns the copy of the header with values of the listed
+// fields replaced.
+func RedactHeader(hdr textproto.Header, fields []string) textproto.Header {
+	var raw [][]byte
+	for f := hdr.Fields(); f.Next(); {
+		redacted := false
+		for _, field := range fields {
+			if strings.EqualFold(f.Key(), field) {
+				redacted = true
+				break
+			}
+		}
+		if redacted {
+			raw = append(raw, []byte(f.Key()+": "+redactedValue+"\r\n"))
+			continue
+		}
+
+		b, err := f.Raw()
+		if err != nil {
+			continue
+		}
+		raw = append(raw, b)
+	}
+
+	// Fields are iterated top to bottom, but added to the bottom.
+	res := textproto.Header{}
+	for i := len(raw) - 1; i >= 0; i-- {
+		res.AddRaw(raw[i])
+	}
+	return res
+}
+
+// WriteFailureReport writes the failure report (RFC 6591 with RFC 7489
+// extensions) for the message to w and returns the report header. Values of
+// the redact fields in the original header are not included.
+func WriteFailureReport(envelope Envelope, rep dmarc.FailureReport, redact []string, w io.Writer) (textproto.Header, error) {
+	partWriter := textproto.NewMultipartWriter(w)
+
+	hdr := textproto.Header{}
+	hdr.Add("Date", time.Now().Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	hdr.Add("Message-Id", envelope.MsgID)
+	hdr.Add("MIME-Version", "1.0")
+	hdr.Add("Content-Type", "multipart/report; report-type=feedback-report; boundary="+partWriter.Boundary())
+	hdr.Add("Auto-Submitted", "auto-generated")
+	hdr.Add("From", envelope.From)
+	hdr.Add("To", strings.Join(envelope.To, ", "))
+	hdr.Add("Subject", fmt.Sprintf("DMARC failure report for %s from %s",
+		rep.Record.HeaderFrom, rep.Record.SourceIP))
+
+	textHdr := textproto.Header{}
+	textHdr.Add("Content-Type", "text/plain; charset=utf-8")
+	textHdr.Add("Content-Transfer-Encoding", "7bit")
+	textWriter, err := partWriter.CreatePart(textHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	if _, err := fmt.Fprintf(textWriter, "This is an authentication failure report for a message\r\n"+
+		"claiming to be from %s received from %s.\r\n",
+		rep.Record.HeaderFrom, rep.Record.SourceIP); err != nil {
+		return textproto.Header{}, err
+	}
+
+	// Fields are written as is since textproto.Header changes the case of
+	// field names (Source-IP).
+	feedback := []string{
+		"Feedback-Type: auth-failure",
+		"User-Agent: MailChat",
+		"Version: 1",
+	}
+	if rep.MailFrom != "" {
+		feedback = append(feedback, "Original-Mail-From: <"+rep.MailFrom+">")
+	}
+	feedback = append(feedback,
+		"Arrival-Date: "+rep.Record.Time.Format("Mon, 2 Jan 2006 15:04:05 -0700"),
+		"Source-IP: "+rep.Record.SourceIP,
+		"Reported-Domain: "+rep.Record.HeaderFrom,
+	)
+	if rep.AuthResults != "" {
+		feedback = append(feedback, "Authentication-Results: "+rep.AuthResults)
+	}
+	feedback = append(feedback,
+		"Auth-Failure: dmarc",
+		"Delivery-Result: "+deliveryResult(rep.Record.Disposition),
+		"Identity-Alignment: "+identityAlignment(rep.Record),
+	)
+	for _, dkim := range rep.Record.DKIM {
+		feedback = append(feedback, "DKIM-Domain: "+dkim.Domain)
+	}
+
+	feedbackHdr := textproto.Header{}
+	feedbackHdr.Add("Content-Type", "message/feedback-report")
+	feedbackHdr.Add("Content-Transfer-Encoding", "7bit")
+	feedbackWriter, err := partWriter.CreatePart(feedbackHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	if _, err := io.WriteString(feedbackWriter, strings.Join(feedback, "\r\n")+"\r\n"); err != nil {
+		return textproto.Header{}, err
+	}
+
+	origHdr := textproto.Header{}
+	origHdr.Add("Content-Type", "text/rfc822-headers")
+	origHdr.Add("Content-Transfer-Encoding", "8bit")
+	origWriter, err := partWriter.CreatePart(origHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	if err := textproto.WriteHeader(origWriter, RedactHeader(rep.Header, redact)); err != nil {
+		return textproto.Header{}, err
+	}
+
+	return hdr, partWriter.Close()
+}
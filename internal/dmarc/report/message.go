@@ -0,0 +1,218 @@
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/internal/dmarc"
+)
+
+// Destination is a mailto: report URI.
+type Destination struct {
+	Address string
+	// MaxSize is the maximum report size accepted by the destination, 0 if
+	// there is no limit.
+	MaxSize int64
+}
+
+// ParseURI parses the rua or ruf URI (RFC 7489 Section 6.2). Only mailto:
+// URIs are supported.
+func ParseURI(uri string) (Destination, error) {
+	var dest Destination
+	if idx := strings.LastIndexByte(uri, '!'); idx != -1 {
+		size, err := parseSize(uri[idx+1:])
+		if err != nil {
+			return Destination{}, fmt.Errorf("dmarc: malformed size limit in %s: %w", uri, err)
+		}
+		dest.MaxSize = size
+		uri = uri[:idx]
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Destination{}, err
+	}
+	if !strings.EqualFold(u.Scheme, "mailto") {
+		return Destination{}, fmt.Errorf("dmarc: unsupported report URI scheme: %s", u.Scheme)
+	}
+	addr := u.Opaque
+	if addr == "" {
+		addr = u.Path
+	}
+	if addr, err = url.PathUnescape(addr); err != nil {
+		return Destination{}, err
+	}
+	if _, _, err := address.Split(addr); err != nil {
+		return Destination{}, fmt.Errorf("dmarc: malformed report address: %w", err)
+	}
+	dest.Address = addr
+	return dest, nil
+}
+
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		case 't', 'T':
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * mult, nil
+}
+
+// VerifyDestination checks whether the destination domain accepts reports
+// for the policy domain (RFC 7489 Section 7.1). Destinations within the
+// same organizational domain are always accepted.
+func VerifyDestination(ctx context.Context, r dmarc.Resolver, policyDomain, addr string) (bool, error) {
+	_, destDomain, err := address.Split(addr)
+	if err != nil {
+		return false, err
+	}
+	destDomain = strings.ToLower(destDomain)
+	policyDomain = strings.ToLower(policyDomain)
+
+	policyOrg, err := publicsuffix.EffectiveTLDPlusOne(policyDomain)
+	if err != nil {
+		return false, err
+	}
+	destOrg, err := publicsuffix.EffectiveTLDPlusOne(destDomain)
+	if err != nil {
+		return false, err
+	}
+	if policyOrg == destOrg {
+		return true, nil
+	}
+
+	txts, err := r.LookupTXT(ctx, dns.FQDN(policyDomain+"._report._dmarc."+destDomain))
+	if err != nil {
+		if dns.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(strings.TrimSpace(txt), "v=DMARC1") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Compress returns the gzip-compressed XML document for the report.
+func (fb *Feedback) Compress() ([]byte, error) {
+	blob, err := fb.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(blob); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Filename returns the file name for the report attachment as recommended
+// by RFC 7489 Section 7.2.1.1.
+func (fb *Feedback) Filename(receiver string) string {
+	return fmt.Sprintf("%s!%s!%d!%d.xml.gz", receiver, fb.PolicyPublished.Domain,
+		fb.Metadata.DateRange.Begin, fb.Metadata.DateRange.End)
+}
+
+// Envelope contains the header fields of the report message.
+type Envelope struct {
+	MsgID string
+	From  string
+	To    []string
+}
+
+// WriteMessage writes the message body containing the compressed report to w
+// and returns the message header.
+func WriteMessage(envelope Envelope, fb *Feedback, receiver string, compressed []byte, w io.Writer) (textproto.Header, error) {
+	partWriter := textproto.NewMultipartWriter(w)
+
+	hdr := textproto.Header{}
+	hdr.Add("Date", time.Now().Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	hdr.Add("Message-Id", envelope.MsgID)
+	hdr.Add("MIME-Version", "1.0")
+	hdr.Add("Content-Type", "multipart/mixed; boundary="+partWriter.Boundary())
+	hdr.Add("Auto-Submitted", "auto-generated")
+	hdr.Add("From", envelope.From)
+	hdr.Add("To", strings.Join(envelope.To, ", "))
+	hdr.Add("Subject", fmt.Sprintf("Report Domain: %s Submitter: %s Report-ID: <%s>",
+		fb.PolicyPublished.Domain, receiver, fb.Metadata.ReportID))
+
+	textHdr := textproto.Header{}
+	textHdr.Add("Content-Type", "text/plain; charset=utf-8")
+	textHdr.Add("Content-Transfer-Encoding", "7bit")
+	textWriter, err := partWriter.CreatePart(textHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	begin := time.Unix(fb.Metadata.DateRange.Begin, 0).UTC()
+	end := time.Unix(fb.Metadata.DateRange.End, 0).UTC()
+	if _, err := fmt.Fprintf(textWriter, "This is a DMARC aggregate report for %s\r\n"+
+		"covering the period from %s to %s.\r\n",
+		fb.PolicyPublished.Domain, begin.Format(time.RFC1123Z), end.Format(time.RFC1123Z)); err != nil {
+		return textproto.Header{}, err
+	}
+
+	filename := fb.Filename(receiver)
+	gzHdr := textproto.Header{}
+	gzHdr.Add("Content-Type", "application/gzip; name=\""+filename+"\"")
+	gzHdr.Add("Content-Transfer-Encoding", "base64")
+	gzHdr.Add("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	gzWriter, err := partWriter.CreatePart(gzHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	if err := writeBase64(gzWriter, compressed); err != nil {
+		return textproto.Header{}, err
+	}
+
+	return hdr, partWriter.Close()
+}
+
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
@@ -0,0 +1,435 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/foxcpp/go-mockdns"
+
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/internal/dmarc"
+	"github.com/dsoftgames/MailChat/internal/reportstore"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+func testRecord(t time.Time, ip, domain string, disp dmarc.Policy) dmarc.ReportRecord {
+	return dmarc.ReportRecord{
+		Time:         t,
+		SourceIP:     ip,
+		HeaderFrom:   domain,
+		PolicyDomain: domain,
+		Policy:       dmarc.PolicyPublished{ADKIM: "r", ASPF: "r", P: dmarc.PolicyReject, SP: dmarc.PolicyReject, Pct: 100},
+		ReportURIs:   []string{"mailto:rua@" + domain},
+		Disposition:  disp,
+		DKIMAligned:  disp == dmarc.PolicyNone,
+		DKIM:         []dmarc.DKIMAuthResult{{Domain: domain, Result: "pass"}},
+		SPF:          []dmarc.SPFAuthResult{{Domain: domain, Scope: "mfrom", Result: "fail"}},
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []dmarc.ReportRecord{
+		testRecord(now, "192.0.2.1", "example.org", dmarc.PolicyNone),
+		testRecord(now.Add(time.Minute), "192.0.2.1", "example.org", dmarc.PolicyNone),
+		testRecord(now, "192.0.2.2", "example.org", dmarc.PolicyReject),
+		testRecord(now, "192.0.2.1", "example.com", dmarc.PolicyNone),
+	}
+	records[1].Policy.P = dmarc.PolicyQuarantine
+
+	if domains := PolicyDomains(records); len(domains) != 2 || domains[0] != "example.com" || domains[1] != "example.org" {
+		t.Fatalf("wrong policy domains: %v", domains)
+	}
+
+	fb := Aggregate(Metadata{OrgName: "Test"}, "example.org", records)
+	if fb == nil {
+		t.Fatal("no report")
+	}
+	if fb.PolicyPublished.Domain != "example.org" || fb.PolicyPublished.P != "quarantine" {
+		t.Errorf("wrong policy published: %+v", fb.PolicyPublished)
+	}
+	if len(fb.Records) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(fb.Records))
+	}
+	if row := fb.Records[0].Row; row.SourceIP != "192.0.2.1" || row.Count != 2 ||
+		row.PolicyEvaluated.Disposition != "none" || row.PolicyEvaluated.DKIM != "pass" || row.PolicyEvaluated.SPF != "fail" {
+		t.Errorf("wrong first row: %+v", row)
+	}
+	if row := fb.Records[1].Row; row.SourceIP != "192.0.2.2" || row.Count != 1 || row.PolicyEvaluated.Disposition != "reject" {
+		t.Errorf("wrong second row: %+v", row)
+	}
+
+	blob, err := fb.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed Feedback
+	if err := xml.Unmarshal(blob, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Records[0].AuthResults.SPF[0].Scope != "mfrom" || parsed.Metadata.OrgName != "Test" {
+		t.Errorf("wrong round-trip result: %+v", parsed)
+	}
+
+	if fb := Aggregate(Metadata{}, "example.net", records); fb != nil {
+		t.Error("report built for unknown domain")
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	for uri, expected := range map[string]Destination{
+		"mailto:rua@example.org":        {Address: "rua@example.org"},
+		"mailto:rua@example.org!10m":    {Address: "rua@example.org", MaxSize: 10 << 20},
+		"MAILTO:rua%2Bd@example.org!50": {Address: "rua+d@example.org", MaxSize: 50},
+	} {
+		dest, err := ParseURI(uri)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", uri, err)
+			continue
+		}
+		if dest != expected {
+			t.Errorf("%s: expected %+v, got %+v", uri, expected, dest)
+		}
+	}
+
+	for _, uri := range []string{"https://example.org/rua", "mailto:rua@example.org!10x", "mailto:"} {
+		if _, err := ParseURI(uri); err == nil {
+			t.Errorf("%s: expected an error", uri)
+		}
+	}
+}
+
+func TestVerifyDestination(t *testing.T) {
+	r := &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"example.org._report._dmarc.reports.example.net.": {
+			TXT: []string{"v=DMARC1"},
+		},
+	}}
+	for _, c := range []struct {
+		policyDomain, addr string
+		ok                 bool
+	}{
+		{"example.org", "rua@example.org", true},
+		{"sub.example.org", "rua@example.org", true},
+		{"example.org", "rua@reports.example.net", true},
+		{"example.org", "rua@example.net", false},
+		{"example.com", "rua@reports.example.net", false},
+	} {
+		ok, err := VerifyDestination(context.Background(), r, c.policyDomain, c.addr)
+		if err != nil {
+			t.Errorf("%s -> %s: %v", c.policyDomain, c.addr, err)
+			continue
+		}
+		if ok != c.ok {
+			t.Errorf("%s -> %s: expected %v, got %v", c.policyDomain, c.addr, c.ok, ok)
+		}
+	}
+}
+
+func TestReporter(t *testing.T) {
+	tgt := &testutils.Target{}
+	store, err := reportstore.Open[dmarc.ReportRecord](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Reporter{
+		log:       testutils.Logger(t, "dmarc.reporter"),
+		hostname:  "mx.example.com",
+		orgName:   "Example",
+		email:     "noreply-dmarc@example.com",
+		interval:  24 * time.Hour,
+		retention: 72 * time.Hour,
+		target:    tgt,
+		resolver:  &mockdns.Resolver{Zones: map[string]mockdns.Zone{}},
+		store:     store,
+	}
+	defer r.Close()
+
+	now := time.Now().UTC()
+	yesterday := now.Truncate(24 * time.Hour).Add(-time.Hour)
+	for _, rec := range []dmarc.ReportRecord{
+		testRecord(yesterday, "192.0.2.1", "example.org", dmarc.PolicyNone),
+		testRecord(yesterday, "192.0.2.1", "example.org", dmarc.PolicyNone),
+		// Not reported yet, the period is not over.
+		testRecord(now, "192.0.2.1", "example.org", dmarc.PolicyNone),
+	} {
+		if err := r.RecordResult(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noRUA := testRecord(yesterday, "192.0.2.1", "example.net", dmarc.PolicyNone)
+	noRUA.ReportURIs = nil
+	if err := r.RecordResult(noRUA); err != nil {
+		t.Fatal(err)
+	}
+
+	r.report(now)
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(tgt.Messages))
+	}
+	msg := tgt.Messages[0]
+	if msg.MailFrom != "noreply-dmarc@example.com" || len(msg.RcptTo) != 1 || msg.RcptTo[0] != "rua@example.org" {
+		t.Fatalf("wrong envelope: %s -> %v", msg.MailFrom, msg.RcptTo)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Subject"), "Report Domain: example.org Submitter: example.com Report-ID: ") {
+		t.Errorf("wrong subject: %s", msg.Header.Get("Subject"))
+	}
+
+	fb := readReport(t, msg.Header, msg.Body)
+	if fb.PolicyPublished.Domain != "example.org" || len(fb.Records) != 1 || fb.Records[0].Row.Count != 2 {
+		t.Errorf("wrong report: %+v", fb)
+	}
+	if fb.Metadata.DateRange.End-fb.Metadata.DateRange.Begin != 24*60*60-1 {
+		t.Errorf("wrong date range: %+v", fb.Metadata.DateRange)
+	}
+
+	// Period is reported only once.
+	r.report(now)
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("report sent twice")
+	}
+}
+
+func TestReporter_Retry(t *testing.T) {
+	tgt := &testutils.Target{
+		BodyErr: exterrors.WithTemporary(errors.New("queue is unavailable"), true),
+	}
+	store, err := reportstore.Open[dmarc.ReportRecord](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Reporter{
+		log:       testutils.Logger(t, "dmarc.reporter"),
+		hostname:  "mx.example.com",
+		orgName:   "Example",
+		email:     "noreply-dmarc@example.com",
+		interval:  24 * time.Hour,
+		retention: 72 * time.Hour,
+		target:    tgt,
+		resolver:  &mockdns.Resolver{Zones: map[string]mockdns.Zone{}},
+		store:     store,
+	}
+	defer r.Close()
+
+	now := time.Now().UTC()
+	periodEnd := now.Truncate(24 * time.Hour)
+	for _, rec := range []dmarc.ReportRecord{
+		testRecord(periodEnd.Add(-25*time.Hour), "192.0.2.1", "example.org", dmarc.PolicyNone),
+		testRecord(periodEnd.Add(-time.Hour), "192.0.2.1", "example.org", dmarc.PolicyNone),
+	} {
+		if err := r.RecordResult(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkReportedUntil := func(expected time.Time) {
+		t.Helper()
+		reportedUntil, err := store.ReportedUntil()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reportedUntil.Equal(expected) {
+			t.Fatalf("wrong reporting state: %v, expected %v", reportedUntil, expected)
+		}
+	}
+
+	// Temporary failure, the period with records is retried.
+	r.report(now)
+	checkReportedUntil(periodEnd.Add(-48 * time.Hour))
+
+	// Permanent failure, the period is skipped.
+	tgt.BodyErr = exterrors.WithTemporary(errors.New("rejected"), false)
+	r.report(now.Add(-24 * time.Hour))
+	checkReportedUntil(periodEnd.Add(-24 * time.Hour))
+
+	tgt.BodyErr = nil
+	r.report(now)
+	checkReportedUntil(periodEnd)
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(tgt.Messages))
+	}
+	fb := readReport(t, tgt.Messages[0].Header, tgt.Messages[0].Body)
+	if fb.Metadata.DateRange.Begin != periodEnd.Add(-24*time.Hour).Unix() {
+		t.Errorf("wrong date range: %+v", fb.Metadata.DateRange)
+	}
+}
+
+func readReport(t *testing.T, hdr textproto.Header, body []byte) *Feedback {
+	t.Helper()
+
+	_, params, err := mime.ParseMediaType(hdr.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal("no report attachment:", err)
+		}
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), "application/gzip") {
+			continue
+		}
+
+		gz, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, bufio.NewReader(part)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fb := &Feedback{}
+		if err := xml.Unmarshal(blob, fb); err != nil {
+			t.Fatal(err)
+		}
+		return fb
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(
+		"From: a@example.org\r\nTo: b@example.com\r\nSubject: secret\r\nTo: c@example.com\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := textproto.WriteHeader(&buf, RedactHeader(hdr, []string{"to", "Cc"})); err != nil {
+		t.Fatal(err)
+	}
+	expected := "From: a@example.org\r\nTo: [redacted]\r\nSubject: secret\r\nTo: [redacted]\r\n\r\n"
+	if buf.String() != expected {
+		t.Errorf("wrong redacted header:\n%q\nexpected:\n%q", buf.String(), expected)
+	}
+}
+
+func TestReporter_Failure(t *testing.T) {
+	tgt := &testutils.Target{}
+	store, err := reportstore.Open[dmarc.ReportRecord](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Reporter{
+		log:            testutils.Logger(t, "dmarc.reporter"),
+		hostname:       "mx.example.com",
+		email:          "noreply-dmarc@example.com",
+		target:         tgt,
+		resolver:       &mockdns.Resolver{Zones: map[string]mockdns.Zone{}},
+		store:          store,
+		failureReports: true,
+		failureLimit:   2,
+		redactHeaders:  []string{"Subject"},
+		failures:       map[string]*failureWindow{},
+		stop:           make(chan struct{}),
+	}
+
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(
+		"From: a@example.org\r\nSubject: secret\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := dmarc.FailureReport{
+		Record:      testRecord(time.Now(), "192.0.2.1", "example.org", dmarc.PolicyReject),
+		ReportURIs:  []string{"mailto:ruf@example.org"},
+		Interval:    time.Hour,
+		MailFrom:    "a@example.org",
+		Header:      hdr,
+		AuthResults: "mx.example.com; dmarc=fail header.from=example.org",
+	}
+	for i := 0; i < 3; i++ {
+		if err := r.ReportFailure(rep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+
+	if len(tgt.Messages) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(tgt.Messages))
+	}
+	msg := tgt.Messages[0]
+	if msg.MailFrom != "" || len(msg.RcptTo) != 1 || msg.RcptTo[0] != "ruf@example.org" {
+		t.Fatalf("wrong envelope: %q -> %v", msg.MailFrom, msg.RcptTo)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["report-type"] != "feedback-report" {
+		t.Errorf("wrong report type: %v", params["report-type"])
+	}
+	body := string(msg.Body)
+	for _, field := range []string{
+		"Feedback-Type: auth-failure",
+		"Auth-Failure: dmarc",
+		"Source-IP: 192.0.2.1",
+		"Delivery-Result: reject",
+		"Identity-Alignment: none",
+		"Original-Mail-From: <a@example.org>",
+		"Subject: [redacted]",
+	} {
+		if !strings.Contains(body, field) {
+			t.Errorf("report does not contain %q", field)
+		}
+	}
+	if strings.Contains(body, "secret") {
+		t.Error("redacted field value included into the report")
+	}
+}
+
+func TestReporter_FailureClose(t *testing.T) {
+	store, err := reportstore.Open[dmarc.ReportRecord](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Reporter{
+		log:            testutils.Logger(t, "dmarc.reporter"),
+		hostname:       "mx.example.com",
+		email:          "noreply-dmarc@example.com",
+		target:         &testutils.Target{DiscardMessages: true},
+		resolver:       &mockdns.Resolver{Zones: map[string]mockdns.Zone{}},
+		store:          store,
+		failureReports: true,
+		failureLimit:   1000,
+		failures:       map[string]*failureWindow{},
+		stop:           make(chan struct{}),
+	}
+
+	rep := dmarc.FailureReport{
+		Record:     testRecord(time.Now(), "192.0.2.1", "example.org", dmarc.PolicyReject),
+		ReportURIs: []string{"mailto:ruf@example.org"},
+		Interval:   time.Hour,
+		Header:     textproto.Header{},
+	}
+
+	// Reports are submitted concurrently with Close, run with -race.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := r.ReportFailure(rep); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	r.Close()
+	<-done
+
+	if err := r.ReportFailure(rep); err != nil {
+		t.Fatal(err)
+	}
+}
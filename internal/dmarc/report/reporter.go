@@ -0,0 +1,475 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/dmarc"
+	"github.com/dsoftgames/MailChat/internal/reportstore"
+)
+
+// Reporter collects DMARC evaluation results passed to it by message
+// pipelines ('dmarc_reports' directive) and periodically sends aggregate
+// reports to the addresses listed in the rua tag of the policy.
+//
+// If enabled, failure reports are sent for individual messages to the
+// addresses listed in the ruf tag, at most failure_limit reports per policy
+// domain within the report interval (ri tag) of the domain.
+//
+// Reports are submitted using the configured delivery target, usually the
+// outbound queue.
+type Reporter struct {
+	instName string
+	log      log.Logger
+
+	hostname   string
+	orgName    string
+	email      string
+	autogenMsg string
+	interval   time.Duration
+	retention  time.Duration
+	stateDir   string
+	target     module.DeliveryTarget
+	resolver   dmarc.Resolver
+
+	failureReports bool
+	failureLimit   int
+	redactHeaders  []string
+
+	storeLock sync.Mutex
+	store     *reportstore.Store[dmarc.ReportRecord]
+
+	failuresLock sync.Mutex
+	failures     map[string]*failureWindow
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+
+	// closeLock protects closed so failure reports are not started once
+	// Close waits for the running ones.
+	closeLock sync.Mutex
+	closed    bool
+}
+
+func NewReporter(_, instName string, _, _ []string) (module.Module, error) {
+	return &Reporter{
+		instName: instName,
+		log:      log.Logger{Name: "dmarc.reporter", Debug: log.DefaultLogger.Debug},
+		resolver: dns.DefaultResolver(),
+		failures: map[string]*failureWindow{},
+	}, nil
+}
+
+func (r *Reporter) Init(cfg *config.Map) error {
+	cfg.Bool("debug", true, log.DefaultLogger.Debug, &r.log.Debug)
+	cfg.String("hostname", true, true, "", &r.hostname)
+	cfg.String("autogenerated_msg_domain", true, false, "", &r.autogenMsg)
+	cfg.String("org_name", false, false, "", &r.orgName)
+	cfg.String("email", false, false, "", &r.email)
+	cfg.Duration("interval", false, false, 24*time.Hour, &r.interval)
+	cfg.Duration("retention", false, false, 7*24*time.Hour, &r.retention)
+	cfg.String("state_dir", false, false, "", &r.stateDir)
+	cfg.Bool("failure_reports", false, false, &r.failureReports)
+	cfg.Int("failure_limit", false, false, 10, &r.failureLimit)
+	cfg.StringList("failure_redact_headers", false, false, nil, &r.redactHeaders)
+	cfg.Custom("target", false, true, nil, modconfig.DeliveryDirective, &r.target)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if r.orgName == "" {
+		r.orgName = r.hostname
+	}
+	if r.email == "" {
+		if r.autogenMsg == "" {
+			return errors.New("dmarc.reporter: email or autogenerated_msg_domain is required")
+		}
+		r.email = "noreply-dmarc@" + r.autogenMsg
+	}
+	if _, _, err := address.Split(r.email); err != nil {
+		return fmt.Errorf("dmarc.reporter: malformed email: %w", err)
+	}
+	if r.interval < time.Hour {
+		return errors.New("dmarc.reporter: interval should be at least 1h")
+	}
+	if r.failureLimit <= 0 {
+		return errors.New("dmarc.reporter: failure_limit should be positive")
+	}
+	if r.retention < r.interval {
+		return errors.New("dmarc.reporter: retention should not be shorter than interval")
+	}
+
+	if r.stateDir == "" {
+		name := r.instName
+		if name == "" {
+			name = "default"
+		}
+		r.stateDir = filepath.Join(config.StateDirectory, "dmarc_reports", name)
+	}
+	if !filepath.IsAbs(r.stateDir) {
+		r.stateDir = filepath.Join(config.StateDirectory, r.stateDir)
+	}
+
+	var err error
+	r.store, err = reportstore.Open[dmarc.ReportRecord](r.stateDir)
+	if err != nil {
+		return fmt.Errorf("dmarc.reporter: %w", err)
+	}
+
+	if module.NoRun {
+		return nil
+	}
+
+	r.stop = make(chan struct{})
+	r.stopped.Add(1)
+	go r.run()
+
+	return nil
+}
+
+func (r *Reporter) Name() string {
+	return "dmarc.reporter"
+}
+
+func (r *Reporter) InstanceName() string {
+	return r.instName
+}
+
+// RecordResult saves the evaluation result to be included in the next
+// report.
+func (r *Reporter) RecordResult(rec dmarc.ReportRecord) error {
+	if len(rec.ReportURIs) == 0 {
+		return nil
+	}
+
+	r.storeLock.Lock()
+	defer r.storeLock.Unlock()
+	return r.store.Add(rec)
+}
+
+func (r *Reporter) run() {
+	defer r.stopped.Done()
+
+	for {
+		r.report(time.Now())
+
+		next := time.Now().Truncate(r.interval).Add(r.interval)
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-t.C:
+		case <-r.stop:
+			t.Stop()
+			return
+		}
+	}
+}
+
+// report sends reports for all reporting periods that ended before now and
+// were not reported yet, then removes records older than the retention
+// window.
+func (r *Reporter) report(now time.Time) {
+	periodEnd := now.UTC().Truncate(r.interval)
+
+	r.storeLock.Lock()
+	reportedUntil, err := r.store.ReportedUntil()
+	r.storeLock.Unlock()
+	if err != nil {
+		r.log.Error("failed to read reporting state", err)
+		return
+	}
+	if oldest := periodEnd.Add(-r.retention).Truncate(r.interval); reportedUntil.Before(oldest) {
+		// Records before that are already pruned (or will be).
+		reportedUntil = oldest
+	}
+
+	for begin := reportedUntil; begin.Before(periodEnd); begin = begin.Add(r.interval) {
+		// The period is retried on the next run. Report IDs do not change, so
+		// receivers can discard reports that were already delivered.
+		if err := r.sendReports(begin, begin.Add(r.interval)); err != nil {
+			r.log.Error("failed to send reports, will retry later", err, "period_start", begin)
+			break
+		}
+
+		r.storeLock.Lock()
+		err := r.store.SetReportedUntil(begin.Add(r.interval))
+		r.storeLock.Unlock()
+		if err != nil {
+			r.log.Error("failed to save reporting state", err)
+			return
+		}
+	}
+
+	r.storeLock.Lock()
+	err = r.store.Prune(now.Add(-r.retention))
+	r.storeLock.Unlock()
+	if err != nil {
+		r.log.Error("failed to remove old records", err)
+	}
+}
+
+// sendReports sends reports for records collected during [begin, end).
+// Reports rejected permanently are skipped, an error is returned if the
+// period should be retried.
+func (r *Reporter) sendReports(begin, end time.Time) error {
+	r.storeLock.Lock()
+	records, err := r.store.Records(begin, end)
+	r.storeLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("read records: %w", err)
+	}
+
+	for _, domain := range PolicyDomains(records) {
+		meta := Metadata{
+			OrgName:  r.orgName,
+			Email:    r.email,
+			ReportID: strconv.FormatInt(begin.Unix(), 10) + "." + domain + "@" + r.hostname,
+			DateRange: DateRange{
+				Begin: begin.Unix(),
+				End:   end.Unix() - 1,
+			},
+		}
+		fb := Aggregate(meta, domain, records)
+		if fb == nil {
+			continue
+		}
+		if err := r.sendReport(fb, LatestReportURIs(domain, records)); err != nil {
+			if exterrors.IsTemporaryOrUnspec(err) {
+				return fmt.Errorf("report for %s: %w", domain, err)
+			}
+			r.log.Error("failed to send report", err, "domain", domain)
+			continue
+		}
+	}
+	return nil
+}
+
+func (r *Reporter) sendReport(fb *Feedback, uris []string) error {
+	compressed, err := fb.Compress()
+	if err != nil {
+		return exterrors.WithTemporary(err, false)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rcpts := r.destinations(ctx, fb.PolicyPublished.Domain, uris, int64(len(compressed)))
+	if len(rcpts) == 0 {
+		return nil
+	}
+
+	_, receiver, err := address.Split(r.email)
+	if err != nil {
+		return exterrors.WithTemporary(err, false)
+	}
+	msgID, err := module.GenerateMsgID()
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	hdr, err := WriteMessage(Envelope{
+		MsgID: "<" + msgID + "@" + receiver + ">",
+		From:  r.email,
+		To:    rcpts,
+	}, fb, receiver, compressed, &body)
+	if err != nil {
+		return exterrors.WithTemporary(err, false)
+	}
+
+	if err := r.deliver(ctx, msgID, r.email, hdr, body.Bytes(), rcpts); err != nil {
+		return err
+	}
+	r.log.Msg("report sent", "domain", fb.PolicyPublished.Domain, "rcpts", rcpts, "msg_id", msgID,
+		"rows", len(fb.Records))
+	return nil
+}
+
+// destinations returns addresses from the report URIs that accept reports
+// for the domain and of the given size.
+func (r *Reporter) destinations(ctx context.Context, policyDomain string, uris []string, size int64) []string {
+	var rcpts []string
+	for _, uri := range uris {
+		dest, err := ParseURI(uri)
+		if err != nil {
+			r.log.Msg("skipping report URI", "reason", err, "domain", policyDomain)
+			continue
+		}
+		if dest.MaxSize != 0 && size > dest.MaxSize {
+			r.log.Msg("report exceeds size limit", "rcpt", dest.Address, "domain", policyDomain,
+				"size", size, "limit", dest.MaxSize)
+			continue
+		}
+		ok, err := VerifyDestination(ctx, r.resolver, policyDomain, dest.Address)
+		if err != nil {
+			r.log.Error("failed to verify report destination", err, "rcpt", dest.Address)
+			continue
+		}
+		if !ok {
+			r.log.Msg("report destination does not accept reports for domain", "rcpt", dest.Address,
+				"domain", policyDomain)
+			continue
+		}
+		rcpts = append(rcpts, dest.Address)
+	}
+	return rcpts
+}
+
+func (r *Reporter) deliver(ctx context.Context, msgID, mailFrom string, hdr textproto.Header, body []byte, rcpts []string) error {
+	msgMeta := &module.MsgMetadata{
+		ID:       msgID,
+		SMTPOpts: smtp.MailOptions{},
+	}
+	delivery, err := r.target.Start(ctx, msgMeta, mailFrom)
+	if err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := delivery.AddRcpt(ctx, rcpt, smtp.RcptOptions{}); err != nil {
+			delivery.Abort(ctx)
+			return err
+		}
+	}
+	if err := delivery.Body(ctx, hdr, buffer.MemoryBuffer{Slice: body}); err != nil {
+		delivery.Abort(ctx)
+		return err
+	}
+	return delivery.Commit(ctx)
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+// allowFailure checks the failure reports rate limit for the domain.
+func (r *Reporter) allowFailure(domain string, interval time.Duration, now time.Time) bool {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	w, ok := r.failures[domain]
+	if !ok || now.Sub(w.start) >= interval {
+		if len(r.failures) >= maxFailureWindows {
+			for d, w := range r.failures {
+				if now.Sub(w.start) >= 24*time.Hour {
+					delete(r.failures, d)
+				}
+			}
+		}
+		w = &failureWindow{start: now}
+		r.failures[domain] = w
+	}
+	if w.count >= r.failureLimit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+const maxFailureWindows = 10000
+
+// ReportFailure sends the failure report for the message in background if
+// failure reports are enabled and the rate limit for the domain is not
+// exceeded.
+func (r *Reporter) ReportFailure(rep dmarc.FailureReport) error {
+	if !r.failureReports || r.stop == nil {
+		return nil
+	}
+	if !r.allowFailure(rep.Record.PolicyDomain, rep.Interval, time.Now()) {
+		r.log.DebugMsg("failure report rate limit exceeded", "domain", rep.Record.PolicyDomain)
+		return nil
+	}
+
+	r.closeLock.Lock()
+	if r.closed {
+		r.closeLock.Unlock()
+		return nil
+	}
+	r.stopped.Add(1)
+	r.closeLock.Unlock()
+
+	go func() {
+		defer r.stopped.Done()
+		if err := r.sendFailureReport(rep); err != nil {
+			r.log.Error("failed to send failure report", err, "domain", rep.Record.PolicyDomain)
+		}
+	}()
+	return nil
+}
+
+func (r *Reporter) sendFailureReport(rep dmarc.FailureReport) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, receiver, err := address.Split(r.email)
+	if err != nil {
+		return err
+	}
+	msgID, err := module.GenerateMsgID()
+	if err != nil {
+		return err
+	}
+	envelope := Envelope{
+		MsgID: "<" + msgID + "@" + receiver + ">",
+		From:  r.email,
+	}
+
+	// Generate the report once to learn its size, the final version
+	// includes recipients.
+	var body bytes.Buffer
+	if _, err := WriteFailureReport(envelope, rep, r.redactHeaders, &body); err != nil {
+		return err
+	}
+	envelope.To = r.destinations(ctx, rep.Record.PolicyDomain, rep.ReportURIs, int64(body.Len()))
+	if len(envelope.To) == 0 {
+		return nil
+	}
+	body.Reset()
+	hdr, err := WriteFailureReport(envelope, rep, r.redactHeaders, &body)
+	if err != nil {
+		return err
+	}
+
+	// Failure reports use the null sender to prevent loops.
+	if err := r.deliver(ctx, msgID, "", hdr, body.Bytes(), envelope.To); err != nil {
+		return err
+	}
+	r.log.Msg("failure report sent", "domain", rep.Record.PolicyDomain, "rcpts", envelope.To, "msg_id", msgID)
+	return nil
+}
+
+func (r *Reporter) Close() error {
+	if r.stop != nil {
+		r.closeLock.Lock()
+		r.closed = true
+		r.closeLock.Unlock()
+
+		close(r.stop)
+		r.stopped.Wait()
+	}
+	if r.store == nil {
+		return nil
+	}
+	r.storeLock.Lock()
+	defer r.storeLock.Unlock()
+	return r.store.Close()
+}
+
+func init() {
+	module.Register("dmarc.reporter", NewReporter)
+}
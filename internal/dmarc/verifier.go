@@ -28,6 +28,8 @@ import (
 	"github.com/emersion/go-message/textproto"
 	"github.com/emersion/go-msgauth/authres"
 	"github.com/emersion/go-msgauth/dmarc"
+
+	"github.com/dsoftgames/MailChat/internal/arc"
 )
 
 type verifyData struct {
@@ -57,10 +59,10 @@ type Verifier struct {
 
 	resolver Resolver
 
-	// TODO(GH #206): DMARC reporting
-	// FailureReportFunc is the callback that is called when a failure report
-	// is generated. If it is nil - failure reports generation is disabled.
-	// FailureReportFunc func(textproto.Header, io.Reader)
+	// Policy data used by the last Apply call, kept for ReportRecord.
+	applied    verifyData
+	sampledOut bool
+	arcSealer  string
 }
 
 func NewVerifier(r Resolver) *Verifier {
@@ -128,6 +130,7 @@ func (v *Verifier) FetchRecord(ctx context.Context, header textproto.Header) {
 // whether to apply a policy with the pct key.
 func (v *Verifier) Apply(authRes []authres.Result) (EvalResult, Policy) {
 	data := <-v.fetchCh
+	v.applied = data
 	if data.recordErr != nil {
 		result := authres.DMARCResult{
 			Value:  authres.ResultPermError,
@@ -161,14 +164,39 @@ func (v *Verifier) Apply(authRes []authres.Result) (EvalResult, Policy) {
 		return result, dmarc.PolicyNone
 	}
 
-	if data.record.Percent != nil && rand.Int31n(100) > int32(*data.record.Percent) {
-		return result, dmarc.PolicyNone
-	}
-
 	policy := data.record.Policy
 	if !strings.EqualFold(data.policyDomain, data.fromDomain) && data.record.SubdomainPolicy != "" {
 		policy = data.record.SubdomainPolicy
 	}
 
+	// Forwarders break SPF and often DKIM, trust their authentication
+	// results if they are vouched for by a valid ARC chain.
+	if policy != dmarc.PolicyNone {
+		if sealer := trustedARCSealer(authRes); sealer != "" {
+			v.arcSealer = sealer
+			return result, dmarc.PolicyNone
+		}
+	}
+
+	if data.record.Percent != nil && rand.Int31n(100) > int32(*data.record.Percent) {
+		v.sampledOut = policy != dmarc.PolicyNone
+		return result, dmarc.PolicyNone
+	}
+
 	return result, policy
 }
+
+// trustedARCSealer returns the domain of the trusted ARC sealer reported by
+// check.arc, if any.
+func trustedARCSealer(authRes []authres.Result) string {
+	for _, res := range authRes {
+		res, ok := res.(*authres.GenericResult)
+		if !ok || res.Method != "arc" || res.Value != authres.ResultPass {
+			continue
+		}
+		if sealer := res.Params[arc.TrustedSealerParam]; sealer != "" {
+			return sealer
+		}
+	}
+	return ""
+}
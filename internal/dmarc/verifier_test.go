@@ -25,10 +25,13 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/emersion/go-message/textproto"
 	"github.com/emersion/go-msgauth/authres"
 	"github.com/foxcpp/go-mockdns"
+
+	"github.com/dsoftgames/MailChat/internal/arc"
 )
 
 func TestDMARC(t *testing.T) {
@@ -217,3 +220,173 @@ func TestDMARC(t *testing.T) {
 		&authres.SPFResult{Value: authres.ResultNone, From: "example.org", Helo: "mx.example.org"},
 	}, PolicyQuarantine, authres.ResultFail)
 }
+
+func TestVerifier_ReportRecord(t *testing.T) {
+	v := NewVerifier(&mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"_dmarc.example.org.": {
+			TXT: []string{"v=DMARC1; p=reject; sp=quarantine; adkim=s; rua=mailto:rua@example.org"},
+		},
+	}})
+	defer v.Close()
+
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader("From: hello@sub.example.org\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []authres.Result{
+		&authres.DKIMResult{Value: authres.ResultFail, Domain: "example.com"},
+		&authres.SPFResult{Value: authres.ResultPass, From: "", Helo: "mx.example.com"},
+	}
+	v.FetchRecord(context.Background(), hdr)
+	evalRes, policy := v.Apply(results)
+	if policy != PolicyQuarantine {
+		t.Fatalf("expected quarantine policy, got %v", policy)
+	}
+
+	rec, ok := v.ReportRecord(results, evalRes, policy)
+	if !ok {
+		t.Fatal("no record returned")
+	}
+	if rec.HeaderFrom != "sub.example.org" || rec.PolicyDomain != "example.org" {
+		t.Errorf("wrong domains: %+v", rec)
+	}
+	if rec.Policy.ADKIM != "s" || rec.Policy.ASPF != "r" || rec.Policy.SP != PolicyQuarantine || rec.Policy.Pct != 100 {
+		t.Errorf("wrong published policy: %+v", rec.Policy)
+	}
+	if len(rec.ReportURIs) != 1 || rec.ReportURIs[0] != "mailto:rua@example.org" {
+		t.Errorf("wrong rua: %v", rec.ReportURIs)
+	}
+	if rec.Disposition != PolicyQuarantine || rec.DKIMAligned || rec.SPFAligned {
+		t.Errorf("wrong evaluation: %+v", rec)
+	}
+	if len(rec.DKIM) != 1 || rec.DKIM[0].Domain != "example.com" || rec.DKIM[0].Result != "fail" {
+		t.Errorf("wrong DKIM results: %+v", rec.DKIM)
+	}
+	if len(rec.SPF) != 1 || rec.SPF[0].Scope != "helo" || rec.SPF[0].Domain != "mx.example.com" {
+		t.Errorf("wrong SPF results: %+v", rec.SPF)
+	}
+}
+
+func TestVerifier_ARCOverride(t *testing.T) {
+	test := func(arcRes *authres.GenericResult, expected Policy) {
+		t.Helper()
+
+		v := NewVerifier(&mockdns.Resolver{Zones: map[string]mockdns.Zone{
+			"_dmarc.example.org.": {
+				TXT: []string{"v=DMARC1; p=reject; rua=mailto:rua@example.org"},
+			},
+		}})
+		defer v.Close()
+
+		hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader("From: hello@example.org\r\n\r\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := []authres.Result{
+			&authres.DKIMResult{Value: authres.ResultFail, Domain: "example.org"},
+			&authres.SPFResult{Value: authres.ResultPass, From: "list@example.net"},
+			arcRes,
+		}
+		v.FetchRecord(context.Background(), hdr)
+		evalRes, policy := v.Apply(results)
+		if evalRes.Authres.Value != authres.ResultFail {
+			t.Errorf("expected DMARC fail, got %v", evalRes.Authres.Value)
+		}
+		if policy != expected {
+			t.Fatalf("expected %v policy, got %v", expected, policy)
+		}
+
+		rec, ok := v.ReportRecord(results, evalRes, policy)
+		if !ok {
+			t.Fatal("no record returned")
+		}
+		overridden := len(rec.Reasons) == 1 && rec.Reasons[0] == ReasonTrustedForwarder
+		if overridden != (expected == PolicyNone) {
+			t.Errorf("wrong override reasons: %v", rec.Reasons)
+		}
+	}
+
+	test(&authres.GenericResult{
+		Method: "arc",
+		Value:  authres.ResultPass,
+		Params: map[string]string{arc.TrustedSealerParam: "example.net"},
+	}, PolicyNone)
+	test(&authres.GenericResult{
+		Method: "arc",
+		Value:  authres.ResultPass,
+		Params: map[string]string{},
+	}, PolicyReject)
+	test(&authres.GenericResult{
+		Method: "arc",
+		Value:  authres.ResultFail,
+		Params: map[string]string{arc.TrustedSealerParam: "example.net"},
+	}, PolicyReject)
+}
+
+func TestVerifier_ReportRecord_NoPolicy(t *testing.T) {
+	v := NewVerifier(&mockdns.Resolver{Zones: map[string]mockdns.Zone{}})
+	defer v.Close()
+
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader("From: hello@example.org\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.FetchRecord(context.Background(), hdr)
+	evalRes, policy := v.Apply(nil)
+	if _, ok := v.ReportRecord(nil, evalRes, policy); ok {
+		t.Fatal("record returned without a policy")
+	}
+}
+
+func TestVerifier_FailureReport(t *testing.T) {
+	test := func(record string, results []authres.Result, expected bool) {
+		t.Helper()
+
+		v := NewVerifier(&mockdns.Resolver{Zones: map[string]mockdns.Zone{
+			"_dmarc.example.org.": {TXT: []string{record}},
+		}})
+		defer v.Close()
+
+		hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader("From: hello@example.org\r\n\r\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.FetchRecord(context.Background(), hdr)
+		evalRes, policy := v.Apply(results)
+		rec, ok := v.ReportRecord(results, evalRes, policy)
+		if !ok {
+			t.Fatal("no record returned")
+		}
+
+		rep, ok := v.FailureReport(rec)
+		if ok != expected {
+			t.Fatalf("expected report: %v, got %v", expected, ok)
+		}
+		if ok && (len(rep.ReportURIs) != 1 || rep.Interval != 24*time.Hour) {
+			t.Errorf("wrong report parameters: %v, %v", rep.ReportURIs, rep.Interval)
+		}
+	}
+
+	dkimPass := &authres.DKIMResult{Value: authres.ResultPass, Domain: "example.org"}
+	dkimFail := &authres.DKIMResult{Value: authres.ResultFail, Domain: "example.org"}
+	spfPass := &authres.SPFResult{Value: authres.ResultPass, From: "example.org"}
+	spfFail := &authres.SPFResult{Value: authres.ResultFail, From: "example.org"}
+	spfOther := &authres.SPFResult{Value: authres.ResultPass, From: "example.com"}
+
+	// No ruf.
+	test("v=DMARC1; p=none", []authres.Result{dkimFail, spfFail}, false)
+
+	// fo=0 (default), report if nothing aligned.
+	test("v=DMARC1; p=none; ruf=mailto:ruf@example.org", []authres.Result{dkimFail, spfFail}, true)
+	test("v=DMARC1; p=none; ruf=mailto:ruf@example.org", []authres.Result{dkimFail, spfPass}, false)
+
+	// fo=1, report if anything is not aligned.
+	test("v=DMARC1; p=none; fo=1; ruf=mailto:ruf@example.org", []authres.Result{dkimFail, spfPass}, true)
+	test("v=DMARC1; p=none; fo=1; ruf=mailto:ruf@example.org", []authres.Result{dkimPass, spfPass}, false)
+
+	// fo=d and fo=s, report failures regardless of alignment.
+	test("v=DMARC1; p=none; fo=d; ruf=mailto:ruf@example.org", []authres.Result{dkimFail, spfPass}, true)
+	test("v=DMARC1; p=none; fo=d; ruf=mailto:ruf@example.org", []authres.Result{dkimPass, spfFail}, false)
+	test("v=DMARC1; p=none; fo=s; ruf=mailto:ruf@example.org", []authres.Result{dkimPass, spfFail}, true)
+	test("v=DMARC1; p=none; fo=s; ruf=mailto:ruf@example.org", []authres.Result{dkimFail, spfOther}, false)
+}
@@ -0,0 +1,193 @@
+// Package admin implements the endpoint serving the runtime administration
+// API, see internal/admin for the API itself.
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	tls2 "github.com/dsoftgames/MailChat/framework/config/tls"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	adminapi "github.com/dsoftgames/MailChat/internal/admin"
+	"github.com/dsoftgames/MailChat/internal/listeners"
+	"google.golang.org/grpc"
+)
+
+const modName = "admin"
+
+// Endpoint serves the admin API over Unix sockets and TCP.
+//
+// Unix sockets are accessible only to the user running the server and
+// root, connections from other users are rejected even if the socket
+// permissions are changed. TCP listeners always use TLS and require client
+// certificates signed by one of client_ca certificates.
+type Endpoint struct {
+	addrs     []string
+	log       log.Logger
+	tlsConfig *tls.Config
+	clientCAs *x509.CertPool
+
+	serv        *grpc.Server
+	listeners   []net.Listener
+	listenersWg sync.WaitGroup
+}
+
+func New(_ string, addrs []string) (module.Module, error) {
+	return &Endpoint{
+		addrs: addrs,
+		log:   log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+	}, nil
+}
+
+func (endp *Endpoint) Name() string {
+	return modName
+}
+
+func (endp *Endpoint) InstanceName() string {
+	return modName
+}
+
+func (endp *Endpoint) Init(cfg *config.Map) error {
+	var clientCAPaths []string
+	cfg.Custom("tls", true, false, nil, tls2.TLSDirective, &endp.tlsConfig)
+	cfg.StringList("client_ca", false, false, nil, &clientCAPaths)
+	cfg.Bool("debug", true, false, &endp.log.Debug)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if len(clientCAPaths) != 0 {
+		var err error
+		endp.clientCAs, err = adminapi.LoadCertPool(clientCAPaths)
+		if err != nil {
+			return fmt.Errorf("%s: %w", modName, err)
+		}
+	}
+
+	if len(endp.addrs) == 0 {
+		endp.addrs = []string{"unix://" + adminapi.SocketPath()}
+	}
+	addresses := make([]config.Endpoint, 0, len(endp.addrs))
+	for _, addr := range endp.addrs {
+		saddr, err := config.ParseEndpoint(addr)
+		if err != nil {
+			return fmt.Errorf("%s: invalid address: %s", modName, addr)
+		}
+		addresses = append(addresses, saddr)
+	}
+
+	endp.serv = grpc.NewServer(
+		grpc.MaxRecvMsgSize(adminapi.MaxMessageSize),
+		grpc.MaxSendMsgSize(adminapi.MaxMessageSize),
+		grpc.UnaryInterceptor(endp.logCall),
+	)
+	endp.serv.RegisterService(&adminapi.ServiceDesc, &adminapi.Service{
+		Module:       adminapi.ServerModule,
+		ReloadConfig: adminapi.ReloadServer,
+	})
+
+	return endp.setupListeners(addresses)
+}
+
+func (endp *Endpoint) setupListeners(addresses []config.Endpoint) error {
+	for _, addr := range addresses {
+		var l net.Listener
+		if addr.Network() == "unix" {
+			var err error
+			l, err = listeners.Listen("unix", addr.Address())
+			if err != nil {
+				return fmt.Errorf("%s: %v", modName, err)
+			}
+			if err := os.Chmod(addr.Address(), 0o600); err != nil {
+				l.Close()
+				return fmt.Errorf("%s: %v", modName, err)
+			}
+			l = peerCredListener{Listener: l, log: endp.log}
+		} else {
+			if endp.tlsConfig == nil || endp.clientCAs == nil {
+				return fmt.Errorf("%s: tls and client_ca are required for TCP endpoint %v", modName, addr)
+			}
+
+			var err error
+			l, err = listeners.Listen(addr.Network(), addr.Address())
+			if err != nil {
+				return fmt.Errorf("%s: %v", modName, err)
+			}
+			l = tls.NewListener(l, endp.mutualTLSConfig())
+		}
+		endp.log.Printf("listening on %v", addr)
+
+		endp.listeners = append(endp.listeners, l)
+
+		endp.listenersWg.Add(1)
+		go func() {
+			defer endp.listenersWg.Done()
+			if err := endp.serv.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				endp.log.Printf("failed to serve %s: %s", addr, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// mutualTLSConfig returns the configuration that requires client
+// certificates and negotiates HTTP/2 as expected by gRPC clients.
+func (endp *Endpoint) mutualTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := endp.tlsConfig
+			if endp.tlsConfig.GetConfigForClient != nil {
+				var err error
+				cfg, err = endp.tlsConfig.GetConfigForClient(hello)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if cfg == nil {
+				return nil, errors.New("admin: no TLS certificate configured")
+			}
+			cfg = cfg.Clone()
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			cfg.ClientCAs = endp.clientCAs
+			cfg.NextProtos = []string{"h2"}
+			return cfg, nil
+		},
+	}
+}
+
+func (endp *Endpoint) logCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	switch {
+	case err != nil:
+		endp.log.Error("request failed", err, "method", info.FullMethod)
+	case adminapi.ReadOnly(info.FullMethod, req):
+		endp.log.DebugMsg("request", "method", info.FullMethod)
+	default:
+		// Changes are always logged for auditing.
+		endp.log.Msg("request", "method", info.FullMethod)
+	}
+	return resp, err
+}
+
+func (endp *Endpoint) Close() error {
+	if endp.serv != nil {
+		endp.serv.Stop()
+	}
+	for _, l := range endp.listeners {
+		l.Close()
+	}
+	endp.listenersWg.Wait()
+	return nil
+}
+
+func init() {
+	module.RegisterEndpoint(modName, New)
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"github.com/dsoftgames/MailChat/framework/log"
+)
+
+var errNoPeerCred = errors.New("peer credentials are not supported on this platform")
+
+// peerCredListener closes connections from processes running as users
+// other than the server user and root.
+type peerCredListener struct {
+	net.Listener
+	log log.Logger
+}
+
+func (l peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		uid, err := peerUID(conn)
+		switch {
+		case errors.Is(err, errNoPeerCred):
+			return conn, nil
+		case err != nil:
+			l.log.Error("failed to get peer credentials", err)
+		case uid == 0 || uid == os.Getuid():
+			return conn, nil
+		default:
+			l.log.Msg("connection rejected", "uid", uid)
+		}
+		conn.Close()
+	}
+}
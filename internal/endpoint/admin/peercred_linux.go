@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// peerUID returns the UID of the process connected to the Unix socket.
+func peerUID(conn net.Conn) (int, error) {
+	if wrapped, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = wrapped.NetConn()
+	}
+	uconn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, fmt.Errorf("not a Unix socket connection: %T", conn)
+	}
+	rawConn, err := uconn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, os.NewSyscallError("getsockopt", credErr)
+	}
+	return int(cred.Uid), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package admin
+
+import "net"
+
+// peerUID is not implemented, access to the socket is restricted only by
+// the file permissions.
+func peerUID(net.Conn) (int, error) {
+	return 0, errNoPeerCred
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/dsoftgames/MailChat/framework/module"
 	"github.com/dsoftgames/MailChat/internal/auth"
 	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/listeners"
 )
 
 const modName = "dovecot_sasld"
@@ -98,7 +99,7 @@ func (endp *Endpoint) Init(cfg *config.Map) error {
 			return fmt.Errorf("%s: %v", modName, err)
 		}
 
-		l, err := net.Listen(parsed.Network(), parsed.Address())
+		l, err := listeners.Listen(parsed.Network(), parsed.Address())
 		if err != nil {
 			return fmt.Errorf("%s: %v", modName, err)
 		}
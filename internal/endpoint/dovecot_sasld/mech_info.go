@@ -19,6 +19,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package dovecotsasld
 
 import (
+	"github.com/dsoftgames/MailChat/internal/auth/sasloauth"
 	"github.com/emersion/go-sasl"
 	dovecotsasl "github.com/foxcpp/go-dovecot-sasl"
 )
@@ -30,4 +31,6 @@ var mechInfo = map[string]dovecotsasl.Mechanism{
 	sasl.Login: {
 		Plaintext: true,
 	},
+	sasloauth.OAuthBearer: {},
+	sasloauth.XOAuth2:     {},
 }
@@ -44,7 +44,9 @@ import (
 	"github.com/dsoftgames/MailChat/framework/module"
 	"github.com/dsoftgames/MailChat/internal/auth"
 	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/listeners"
 	"github.com/dsoftgames/MailChat/internal/proxy_protocol"
+	"github.com/dsoftgames/MailChat/internal/sessions"
 	"github.com/dsoftgames/MailChat/internal/updatepipe"
 )
 
@@ -56,6 +58,7 @@ type Endpoint struct {
 	Store         module.Storage
 
 	tlsConfig   *tls.Config
+	clientAuth  *tls2.ClientAuth
 	listenersWg sync.WaitGroup
 
 	saslAuth auth.SASLAuth
@@ -91,6 +94,7 @@ func (endp *Endpoint) Init(cfg *config.Map) error {
 	cfg.Bool("sasl_login", false, false, &endp.saslAuth.EnableLogin)
 	cfg.Custom("storage", false, true, nil, modconfig.StorageDirective, &endp.Store)
 	cfg.Custom("tls", true, true, nil, tls2.TLSDirective, &endp.tlsConfig)
+	cfg.Custom("tls_client_auth", false, false, nil, tls2.ClientAuthDirective, &endp.clientAuth)
 	cfg.Custom("proxy_protocol", false, false, nil, proxy_protocol.ProxyProtocolDirective, &endp.proxyProtocol)
 	cfg.Bool("insecure_auth", false, false, &insecureAuth)
 	cfg.Bool("io_debug", false, false, &ioDebug)
@@ -113,6 +117,10 @@ func (endp *Endpoint) Init(cfg *config.Map) error {
 	}
 
 	endp.saslAuth.Log.Debug = endp.Log.Debug
+	if endp.clientAuth != nil {
+		endp.tlsConfig = endp.clientAuth.Apply(endp.tlsConfig)
+		endp.saslAuth.CertIdentities = endp.clientAuth.Identities
+	}
 
 	addresses := make([]config.Endpoint, 0, len(endp.addrs))
 	for _, addr := range endp.addrs {
@@ -142,7 +150,8 @@ func (endp *Endpoint) Init(cfg *config.Map) error {
 
 	for _, mech := range endp.saslAuth.SASLMechanisms() {
 		endp.serv.EnableAuth(mech, func(c imapserver.Conn) sasl.Server {
-			return endp.saslAuth.CreateSASL(mech, c.Info().RemoteAddr, func(identity string, data auth.ContextData) error {
+			info := c.Info()
+			return endp.saslAuth.CreateSASLConn(context.Background(), mech, info.RemoteAddr, info.TLS, func(identity string, data auth.ContextData) error {
 				return endp.openAccount(c, identity)
 			})
 		})
@@ -155,11 +164,12 @@ func (endp *Endpoint) setupListeners(addresses []config.Endpoint) error {
 	for _, addr := range addresses {
 		var l net.Listener
 		var err error
-		l, err = net.Listen(addr.Network(), addr.Address())
+		l, err = listeners.Listen(addr.Network(), addr.Address())
 		if err != nil {
 			return fmt.Errorf("imap: %v", err)
 		}
 		endp.Log.Printf("listening on %v", addr)
+		l = sessions.Listener(l, "imap")
 
 		if addr.IsTLS() {
 			if endp.tlsConfig == nil {
@@ -255,12 +265,13 @@ func (endp *Endpoint) openAccount(c imapserver.Conn, identity string) error {
 	ctx := c.Context()
 	ctx.State = imap.AuthenticatedState
 	ctx.User = u
+	sessions.SetUser(c.Info().LocalAddr, c.Info().RemoteAddr, username)
 	return nil
 }
 
 func (endp *Endpoint) Login(connInfo *imap.ConnInfo, username, password string) (imapbackend.User, error) {
 	// saslAuth handles AuthMap calling.
-	err := endp.saslAuth.AuthPlain(username, password)
+	err := endp.saslAuth.AuthPlain(connInfo.RemoteAddr, username, password)
 	if err != nil {
 		endp.Log.Error("authentication failed", err, "username", username, "src_ip", connInfo.RemoteAddr)
 		return nil, imapbackend.ErrInvalidCredentials
@@ -275,7 +286,12 @@ func (endp *Endpoint) Login(connInfo *imap.ConnInfo, username, password string)
 		return nil, fmt.Errorf("internal server error")
 	}
 
-	return endp.Store.GetOrCreateIMAPAcct(storageUsername)
+	u, err := endp.Store.GetOrCreateIMAPAcct(storageUsername)
+	if err != nil {
+		return nil, err
+	}
+	sessions.SetUser(connInfo.LocalAddr, connInfo.RemoteAddr, storageUsername)
+	return u, nil
 }
 
 func (endp *Endpoint) I18NLevel() int {
@@ -294,6 +310,8 @@ func (endp *Endpoint) enableExtensions() error {
 			endp.serv.Enable(i18nlevel.NewExtension())
 		case "SORT":
 			endp.serv.Enable(sortthread.NewSortExtension())
+		case "QUOTA":
+			endp.serv.Enable(quotaExtension{})
 		}
 		if strings.HasPrefix(ext, "THREAD") {
 			endp.serv.Enable(sortthread.NewThreadExtension())
@@ -0,0 +1,153 @@
+package imap
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/emersion/go-imap"
+	imapserver "github.com/emersion/go-imap/server"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// quotaRoot is the name of the only quota root of an account. All mailboxes
+// of the account share the same storage quota.
+const quotaRoot = ""
+
+// quotaExtension implements the IMAP QUOTA extension (RFC 9208) for users
+// implementing module.QuotaUser. Quotas are read-only, SETQUOTA always fails.
+type quotaExtension struct{}
+
+func (quotaExtension) Capabilities(c imapserver.Conn) []string {
+	if c.Context().State&imap.AuthenticatedState == 0 {
+		return nil
+	}
+	return []string{"QUOTA", "QUOTA=RES-STORAGE", "QUOTA=RES-MESSAGE"}
+}
+
+func (quotaExtension) Command(name string) imapserver.HandlerFactory {
+	switch name {
+	case "GETQUOTA":
+		return func() imapserver.Handler { return &getQuota{} }
+	case "GETQUOTAROOT":
+		return func() imapserver.Handler { return &getQuotaRoot{} }
+	case "SETQUOTA":
+		return func() imapserver.Handler { return &setQuota{} }
+	}
+	return nil
+}
+
+func quotaUser(conn imapserver.Conn) (module.QuotaUser, error) {
+	if conn.Context().User == nil {
+		return nil, imapserver.ErrNotAuthenticated
+	}
+	u, ok := conn.Context().User.(module.QuotaUser)
+	if !ok {
+		return nil, errors.New("Quotas are not supported")
+	}
+	return u, nil
+}
+
+// writeQuota sends the QUOTA response for the account quota root. Storage
+// usage and limits are reported in units of 1024 octets.
+func writeQuota(conn imapserver.Conn, u module.QuotaUser) error {
+	q, err := u.Quota()
+	if err != nil {
+		return err
+	}
+
+	resources := []interface{}{}
+	if q.StorageLimit != 0 {
+		resources = append(resources,
+			imap.RawString("STORAGE"),
+			imap.RawString(strconv.FormatUint((q.Storage+1023)/1024, 10)),
+			imap.RawString(strconv.FormatUint(q.StorageLimit/1024, 10)))
+	}
+	if q.MessagesLimit != 0 {
+		resources = append(resources,
+			imap.RawString("MESSAGE"),
+			imap.RawString(strconv.FormatUint(q.Messages, 10)),
+			imap.RawString(strconv.FormatUint(q.MessagesLimit, 10)))
+	}
+	return conn.WriteResp(imap.NewUntaggedResp([]interface{}{
+		imap.RawString("QUOTA"), quotaRoot, resources,
+	}))
+}
+
+type getQuota struct {
+	root string
+}
+
+func (cmd *getQuota) Parse(fields []interface{}) error {
+	if len(fields) != 1 {
+		return errors.New("Expected one argument")
+	}
+	root, err := imap.ParseString(fields[0])
+	if err != nil {
+		return err
+	}
+	cmd.root = root
+	return nil
+}
+
+func (cmd *getQuota) Handle(conn imapserver.Conn) error {
+	u, err := quotaUser(conn)
+	if err != nil {
+		return err
+	}
+	if cmd.root != quotaRoot {
+		return errors.New("No such quota root")
+	}
+	return writeQuota(conn, u)
+}
+
+type getQuotaRoot struct {
+	mailbox string
+}
+
+func (cmd *getQuotaRoot) Parse(fields []interface{}) error {
+	if len(fields) != 1 {
+		return errors.New("Expected one argument")
+	}
+	mailbox, err := imap.ParseString(fields[0])
+	if err != nil {
+		return err
+	}
+	mailbox, err = utf7.Encoding.NewDecoder().String(mailbox)
+	if err != nil {
+		return err
+	}
+	cmd.mailbox = imap.CanonicalMailboxName(mailbox)
+	return nil
+}
+
+func (cmd *getQuotaRoot) Handle(conn imapserver.Conn) error {
+	u, err := quotaUser(conn)
+	if err != nil {
+		return err
+	}
+
+	mailbox, err := utf7.Encoding.NewEncoder().String(cmd.mailbox)
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteResp(imap.NewUntaggedResp([]interface{}{
+		imap.RawString("QUOTAROOT"), imap.FormatMailboxName(mailbox), quotaRoot,
+	})); err != nil {
+		return err
+	}
+	return writeQuota(conn, u)
+}
+
+type setQuota struct{}
+
+func (cmd *setQuota) Parse(fields []interface{}) error {
+	return nil
+}
+
+func (cmd *setQuota) Handle(conn imapserver.Conn) error {
+	if conn.Context().User == nil {
+		return imapserver.ErrNotAuthenticated
+	}
+	return errors.New("Quotas can not be changed using IMAP")
+}
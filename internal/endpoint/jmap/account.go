@@ -0,0 +1,320 @@
+package jmap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+)
+
+// account is the state of a single request for the authenticated account.
+type account struct {
+	endp     *Endpoint
+	ctx      context.Context
+	username string
+	name     string
+	id       string
+	user     backend.User
+	// conn describes the HTTP connection for the messages submitted using
+	// EmailSubmission.
+	conn *module.ConnState
+
+	createdIDs map[string]string
+	// implicit contains responses of the implicit calls made by the
+	// method, e.g. Email/set made by EmailSubmission/set.
+	implicit []invocation
+
+	mboxes []*mailboxInfo
+}
+
+type mailboxInfo struct {
+	id         string
+	name       string
+	delim      string
+	role       string
+	subscribed bool
+	status     *imap.MailboxStatus
+}
+
+func newAccount(endp *Endpoint, ctx context.Context, username, name string, u backend.User) *account {
+	return &account{
+		endp:       endp,
+		ctx:        ctx,
+		username:   username,
+		name:       name,
+		id:         accountID(name),
+		user:       u,
+		createdIDs: map[string]string{},
+	}
+}
+
+var roleAttrs = map[string]string{
+	imap.ArchiveAttr: "archive",
+	imap.DraftsAttr:  "drafts",
+	imap.JunkAttr:    "junk",
+	imap.SentAttr:    "sent",
+	imap.TrashAttr:   "trash",
+	imap.AllAttr:     "all",
+	imap.FlaggedAttr: "flagged",
+}
+
+func mailboxID(uidValidity uint32) string {
+	return fmt.Sprintf("M%08x", uidValidity)
+}
+
+func emailID(uidValidity, uid uint32) string {
+	return fmt.Sprintf("M%08xU%x", uidValidity, uid)
+}
+
+// parseEmailID parses the Email id, also accepting the blob and thread ids
+// derived from it.
+func parseEmailID(id string) (uidValidity, uid uint32, ok bool) {
+	if len(id) < 11 || id[9] != 'U' {
+		return 0, 0, false
+	}
+	switch id[0] {
+	case 'M', 'B', 'T':
+	default:
+		return 0, 0, false
+	}
+	val, err := strconv.ParseUint(id[1:9], 16, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	uidStr := id[10:]
+	if i := strings.IndexByte(uidStr, 'P'); i != -1 {
+		uidStr = uidStr[:i]
+	}
+	uidVal, err := strconv.ParseUint(uidStr, 16, 32)
+	if err != nil || uidVal == 0 {
+		return 0, 0, false
+	}
+	return uint32(val), uint32(uidVal), true
+}
+
+func blobID(emailID string) string {
+	return "B" + emailID[1:]
+}
+
+func threadID(emailID string) string {
+	return "T" + emailID[1:]
+}
+
+// resolveID replaces the creation id reference ("#id") with the id of the
+// created object.
+func (a *account) resolveID(id string) string {
+	if strings.HasPrefix(id, "#") {
+		if created, ok := a.createdIDs[id[1:]]; ok {
+			return created
+		}
+	}
+	return id
+}
+
+// mailboxes returns the account mailboxes sorted by name. The list is
+// cached for the request, invalidateMailboxes should be called after
+// changes.
+func (a *account) mailboxes() ([]*mailboxInfo, error) {
+	if a.mboxes != nil {
+		return a.mboxes, nil
+	}
+
+	infos, err := a.user.ListMailboxes(false)
+	if err != nil {
+		return nil, err
+	}
+	subscribed, err := a.user.ListMailboxes(true)
+	if err != nil {
+		return nil, err
+	}
+	subscribedNames := make(map[string]bool, len(subscribed))
+	for _, info := range subscribed {
+		subscribedNames[info.Name] = true
+	}
+
+	mboxes := make([]*mailboxInfo, 0, len(infos))
+	for _, info := range infos {
+		if hasAttr(info.Attributes, imap.NoSelectAttr) {
+			continue
+		}
+		status, err := a.user.Status(info.Name, []imap.StatusItem{
+			imap.StatusMessages, imap.StatusUnseen, imap.StatusUidNext, imap.StatusUidValidity,
+		})
+		if err != nil {
+			return nil, err
+		}
+		mbox := &mailboxInfo{
+			id:         mailboxID(status.UidValidity),
+			name:       info.Name,
+			delim:      info.Delimiter,
+			subscribed: subscribedNames[info.Name],
+			status:     status,
+		}
+		if strings.EqualFold(info.Name, imap.InboxName) {
+			mbox.role = "inbox"
+		}
+		for _, attr := range info.Attributes {
+			if role, ok := roleAttrs[attr]; ok {
+				mbox.role = role
+			}
+		}
+		mboxes = append(mboxes, mbox)
+	}
+	sort.Slice(mboxes, func(i, j int) bool {
+		return mboxes[i].name < mboxes[j].name
+	})
+
+	a.mboxes = mboxes
+	return mboxes, nil
+}
+
+func (a *account) invalidateMailboxes() {
+	a.mboxes = nil
+}
+
+func (a *account) mailboxByID(id string) (*mailboxInfo, error) {
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return nil, err
+	}
+	for _, mbox := range mboxes {
+		if mbox.id == id {
+			return mbox, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *account) mailboxByUIDValidity(uidValidity uint32) (*mailboxInfo, error) {
+	return a.mailboxByID(mailboxID(uidValidity))
+}
+
+func (a *account) mailboxByRole(role string) (*mailboxInfo, error) {
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return nil, err
+	}
+	for _, mbox := range mboxes {
+		if mbox.role == role {
+			return mbox, nil
+		}
+	}
+	return nil, nil
+}
+
+func hasAttr(attrs []string, attr string) bool {
+	for _, a := range attrs {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+func stateHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// mailboxState returns the state string of the Mailbox type.
+func (a *account) mailboxState() (string, error) {
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(mboxes))
+	for _, mbox := range mboxes {
+		parts = append(parts, fmt.Sprintf("%s/%s/%s/%v/%d/%d",
+			mbox.id, mbox.name, mbox.role, mbox.subscribed, mbox.status.Messages, mbox.status.Unseen))
+	}
+	return stateHash(parts...), nil
+}
+
+// emailState returns the state string of the Email and Thread types. Flag
+// changes do not change the mailbox status, so the counter of updates
+// reported by the storage is included as well.
+func (a *account) emailState() (string, error) {
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(mboxes)+1)
+	parts = append(parts, a.endp.push.version(a.name))
+	for _, mbox := range mboxes {
+		parts = append(parts, fmt.Sprintf("%s/%d/%d/%d",
+			mbox.id, mbox.status.UidNext, mbox.status.Messages, mbox.status.Unseen))
+	}
+	return stateHash(parts...), nil
+}
+
+// changed should be called after the account is modified.
+func (a *account) changed() {
+	a.invalidateMailboxes()
+	a.endp.push.bump(a.name)
+}
+
+var flagKeywords = map[string]string{
+	imap.SeenFlag:     "$seen",
+	imap.AnsweredFlag: "$answered",
+	imap.FlaggedFlag:  "$flagged",
+	imap.DraftFlag:    "$draft",
+}
+
+func flagsToKeywords(flags []string) map[string]bool {
+	keywords := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		if kw, ok := flagKeywords[flag]; ok {
+			keywords[kw] = true
+			continue
+		}
+		if strings.HasPrefix(flag, "\\") {
+			continue
+		}
+		keywords[strings.ToLower(flag)] = true
+	}
+	return keywords
+}
+
+func validKeyword(kw string) bool {
+	if kw == "" || len(kw) > 255 {
+		return false
+	}
+	for i := 0; i < len(kw); i++ {
+		c := kw[i]
+		if c < 0x21 || c > 0x7e || strings.IndexByte(`(){]%*"\`, c) != -1 {
+			return false
+		}
+	}
+	return true
+}
+
+func keywordToFlag(kw string) string {
+	kw = strings.ToLower(kw)
+	for flag, k := range flagKeywords {
+		if k == kw {
+			return flag
+		}
+	}
+	return kw
+}
+
+func keywordsToFlags(keywords map[string]bool) ([]string, error) {
+	flags := make([]string, 0, len(keywords))
+	for kw, set := range keywords {
+		if !validKeyword(kw) {
+			return nil, fmt.Errorf("invalid keyword: %s", kw)
+		}
+		if set {
+			flags = append(flags, keywordToFlag(kw))
+		}
+	}
+	sort.Strings(flags)
+	return flags, nil
+}
@@ -0,0 +1,363 @@
+package jmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// invocation is a method call or response, serialized as a 3-element array
+// [name, arguments, callId].
+type invocation struct {
+	Name   string
+	Args   interface{}
+	CallID string
+}
+
+func (inv invocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{inv.Name, inv.Args, inv.CallID})
+}
+
+func (inv *invocation) UnmarshalJSON(b []byte) error {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return err
+	}
+	if len(parts) != 3 {
+		return errors.New("invocation should have 3 elements")
+	}
+	if err := json.Unmarshal(parts[0], &inv.Name); err != nil {
+		return err
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(parts[1], &args); err != nil {
+		return err
+	}
+	inv.Args = args
+	return json.Unmarshal(parts[2], &inv.CallID)
+}
+
+type apiRequest struct {
+	Using       []string          `json:"using"`
+	MethodCalls []invocation      `json:"methodCalls"`
+	CreatedIDs  map[string]string `json:"createdIds,omitempty"`
+}
+
+type apiResponse struct {
+	MethodResponses []invocation      `json:"methodResponses"`
+	CreatedIDs      map[string]string `json:"createdIds,omitempty"`
+	SessionState    string            `json:"sessionState"`
+}
+
+// methodError is the method-level error (RFC 8620 Section 3.6.2).
+type methodError struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+func (e *methodError) Error() string {
+	if e.Description == "" {
+		return e.Type
+	}
+	return e.Type + ": " + e.Description
+}
+
+func errInvalidArguments(format string, args ...interface{}) error {
+	return &methodError{Type: "invalidArguments", Description: fmt.Sprintf(format, args...)}
+}
+
+var (
+	errAccountNotFound = &methodError{Type: "accountNotFound"}
+	errStateMismatch   = &methodError{Type: "stateMismatch"}
+	errRequestTooLarge = &methodError{Type: "requestTooLarge"}
+	errCannotCalculate = &methodError{Type: "cannotCalculateChanges"}
+)
+
+// setError is the per-object error of /set methods (RFC 8620 Section 5.3).
+type setError struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Properties  []string `json:"properties,omitempty"`
+}
+
+func invalidProperties(desc string, props ...string) *setError {
+	return &setError{Type: "invalidProperties", Description: desc, Properties: props}
+}
+
+type methodFunc func(a *account, args json.RawMessage) (interface{}, error)
+
+type method struct {
+	capability string
+	fn         methodFunc
+}
+
+var methods map[string]method
+
+func init() {
+	methods = map[string]method{
+		"Core/echo": {capCore, func(_ *account, args json.RawMessage) (interface{}, error) {
+			return args, nil
+		}},
+		"Mailbox/get":             {capMail, (*account).mailboxGet},
+		"Mailbox/changes":         {capMail, (*account).mailboxChanges},
+		"Mailbox/query":           {capMail, (*account).mailboxQuery},
+		"Mailbox/set":             {capMail, (*account).mailboxSet},
+		"Mailbox/queryChanges":    {capMail, (*account).queryChanges},
+		"Email/get":               {capMail, (*account).emailGet},
+		"Email/changes":           {capMail, (*account).emailChanges},
+		"Email/query":             {capMail, (*account).emailQuery},
+		"Email/queryChanges":      {capMail, (*account).queryChanges},
+		"Email/set":               {capMail, (*account).emailSet},
+		"Email/import":            {capMail, (*account).emailImport},
+		"Thread/get":              {capMail, (*account).threadGet},
+		"Thread/changes":          {capMail, (*account).threadChanges},
+		"Identity/get":            {capSubmission, (*account).identityGet},
+		"EmailSubmission/get":     {capSubmission, (*account).submissionGet},
+		"EmailSubmission/set":     {capSubmission, (*account).submissionSet},
+		"EmailSubmission/changes": {capSubmission, (*account).submissionChanges},
+	}
+}
+
+var knownCapabilities = map[string]bool{
+	capCore:       true,
+	capMail:       true,
+	capSubmission: true,
+}
+
+func (endp *Endpoint) serveAPI(w http.ResponseWriter, r *http.Request, acct *account) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, endp.maxRequestSize+1))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:notRequest", err.Error())
+		return
+	}
+	if int64(len(body)) > endp.maxRequestSize {
+		writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:limit", "maxSizeRequest")
+		return
+	}
+
+	var req apiRequest
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err := dec.Decode(&req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:notJSON", err.Error())
+			return
+		}
+		writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:notRequest", err.Error())
+		return
+	}
+	if req.Using == nil || req.MethodCalls == nil {
+		writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:notRequest", "using and methodCalls are required")
+		return
+	}
+	using := make(map[string]bool, len(req.Using))
+	for _, c := range req.Using {
+		if !knownCapabilities[c] {
+			writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:unknownCapability", c)
+			return
+		}
+		using[c] = true
+	}
+	if len(req.MethodCalls) > endp.maxCalls {
+		writeProblem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:limit", "maxCallsInRequest")
+		return
+	}
+
+	if req.CreatedIDs != nil {
+		acct.createdIDs = req.CreatedIDs
+	}
+
+	resp := apiResponse{
+		MethodResponses: make([]invocation, 0, len(req.MethodCalls)),
+		SessionState:    "0",
+	}
+	for _, call := range req.MethodCalls {
+		resp.MethodResponses = append(resp.MethodResponses, acct.call(call, using, resp.MethodResponses)...)
+	}
+	if req.CreatedIDs != nil {
+		resp.CreatedIDs = acct.createdIDs
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// call executes the method call and returns its responses.
+func (a *account) call(call invocation, using map[string]bool, prev []invocation) []invocation {
+	errResp := func(err error) []invocation {
+		var mErr *methodError
+		if !errors.As(err, &mErr) {
+			a.endp.log.Error("method failed", err, "method", call.Name, "username", a.name)
+			mErr = &methodError{Type: "serverFail"}
+		}
+		return []invocation{{Name: "error", Args: mErr, CallID: call.CallID}}
+	}
+
+	m, ok := methods[call.Name]
+	if !ok || !using[m.capability] {
+		return errResp(&methodError{Type: "unknownMethod"})
+	}
+
+	args, err := resolveReferences(call.Args.(map[string]json.RawMessage), prev)
+	if err != nil {
+		return errResp(err)
+	}
+	if rawID, ok := args["accountId"]; ok && call.Name != "Core/echo" {
+		var id string
+		if err := json.Unmarshal(rawID, &id); err != nil || id != a.id {
+			return errResp(errAccountNotFound)
+		}
+	}
+	argsBlob, err := json.Marshal(args)
+	if err != nil {
+		return errResp(err)
+	}
+
+	a.implicit = nil
+	result, err := m.fn(a, argsBlob)
+	if err != nil {
+		return errResp(err)
+	}
+	resps := []invocation{{Name: call.Name, Args: result, CallID: call.CallID}}
+	for _, imp := range a.implicit {
+		imp.CallID = call.CallID
+		resps = append(resps, imp)
+	}
+	return resps
+}
+
+// resolveReferences replaces "#name" arguments with values from previous
+// responses (RFC 8620 Section 3.7).
+func resolveReferences(args map[string]json.RawMessage, prev []invocation) (map[string]json.RawMessage, error) {
+	invalidRef := func(desc string) error {
+		return &methodError{Type: "invalidResultReference", Description: desc}
+	}
+
+	resolved := make(map[string]json.RawMessage, len(args))
+	for key, val := range args {
+		if !strings.HasPrefix(key, "#") {
+			resolved[key] = val
+			continue
+		}
+		name := key[1:]
+		if _, ok := args[name]; ok {
+			return nil, errInvalidArguments("both %s and %s are present", name, key)
+		}
+
+		var ref struct {
+			ResultOf string `json:"resultOf"`
+			Name     string `json:"name"`
+			Path     string `json:"path"`
+		}
+		if err := json.Unmarshal(val, &ref); err != nil {
+			return nil, invalidRef(err.Error())
+		}
+
+		var source interface{}
+		found := false
+		for _, resp := range prev {
+			if resp.CallID != ref.ResultOf || resp.Name != ref.Name {
+				continue
+			}
+			blob, err := json.Marshal(resp.Args)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(blob, &source); err != nil {
+				return nil, err
+			}
+			found = true
+			break
+		}
+		if !found {
+			return nil, invalidRef("no response for " + ref.ResultOf)
+		}
+
+		value, err := evalPointer(source, ref.Path)
+		if err != nil {
+			return nil, invalidRef(err.Error())
+		}
+		blob, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = blob
+	}
+	return resolved, nil
+}
+
+// evalPointer evaluates the JSON pointer with "*" extension for arrays.
+func evalPointer(value interface{}, path string) (interface{}, error) {
+	if path == "" || path == "/" {
+		return value, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("path should start with /")
+	}
+	token, rest, _ := strings.Cut(path[1:], "/")
+	if rest != "" {
+		rest = "/" + rest
+	}
+	token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[token]
+		if !ok {
+			return nil, fmt.Errorf("no %s property", token)
+		}
+		return evalPointer(next, rest)
+	case []interface{}:
+		if token == "*" {
+			result := []interface{}{}
+			for _, item := range v {
+				itemValue, err := evalPointer(item, rest)
+				if err != nil {
+					return nil, err
+				}
+				if arr, ok := itemValue.([]interface{}); ok {
+					result = append(result, arr...)
+				} else {
+					result = append(result, itemValue)
+				}
+			}
+			return result, nil
+		}
+		idx, err := strconv.Atoi(token)
+		if err != nil || idx < 0 || idx >= len(v) {
+			return nil, fmt.Errorf("invalid array index: %s", token)
+		}
+		return evalPointer(v[idx], rest)
+	}
+	return nil, fmt.Errorf("can not evaluate %s", path)
+}
+
+// decodeArgs decodes the method arguments, unknown arguments are rejected.
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errInvalidArguments("%v", err)
+	}
+	return nil
+}
+
+// filterProperties returns the object with only the requested properties,
+// id is always included.
+func filterProperties(obj map[string]interface{}, props []string) map[string]interface{} {
+	if props == nil {
+		return obj
+	}
+	filtered := make(map[string]interface{}, len(props)+1)
+	filtered["id"] = obj["id"]
+	for _, p := range props {
+		if v, ok := obj[p]; ok {
+			filtered[p] = v
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,211 @@
+package jmap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+)
+
+const uploadCleanupInterval = time.Hour
+
+func (endp *Endpoint) initUploads() error {
+	if !filepath.IsAbs(endp.uploadDir) {
+		endp.uploadDir = filepath.Join(config.StateDirectory, endp.uploadDir)
+	}
+	if err := os.MkdirAll(endp.uploadDir, 0o700); err != nil {
+		return err
+	}
+	endp.stopCleanup = make(chan struct{})
+	go endp.cleanupUploads()
+	return nil
+}
+
+// cleanupUploads removes uploaded blobs older than upload_ttl.
+func (endp *Endpoint) cleanupUploads() {
+	t := time.NewTicker(uploadCleanupInterval)
+	defer t.Stop()
+	for {
+		endp.removeExpiredUploads()
+		select {
+		case <-t.C:
+		case <-endp.stopCleanup:
+			return
+		}
+	}
+}
+
+func (endp *Endpoint) removeExpiredUploads() {
+	expired := time.Now().Add(-endp.uploadTTL)
+	err := filepath.Walk(endp.uploadDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.ModTime().After(expired) {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		endp.log.Error("failed to remove expired uploads", err)
+	}
+}
+
+func validBlobID(id string) bool {
+	if id == "" || len(id) > 255 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *account) uploadPath(blobID string) string {
+	return filepath.Join(a.endp.uploadDir, a.id, blobID)
+}
+
+func (endp *Endpoint) serveUpload(w http.ResponseWriter, r *http.Request, acct *account) {
+	if r.PathValue("accountId") != acct.id {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, endp.maxUploadSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read the request body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > endp.maxUploadSize {
+		writeProblem(w, http.StatusRequestEntityTooLarge, "urn:ietf:params:jmap:error:limit", "maxSizeUpload")
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	rnd := make([]byte, 12)
+	if _, err := rand.Read(rnd); err != nil {
+		endp.log.Error("failed to generate blob id", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	blobID := "G" + hex.EncodeToString(rnd)
+
+	path := acct.uploadPath(blobID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		endp.log.Error("failed to store upload", err, "username", acct.name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(path+".type", []byte(contentType), 0o600); err != nil {
+		endp.log.Error("failed to store upload", err, "username", acct.name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		endp.log.Error("failed to store upload", err, "username", acct.name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"accountId": acct.id,
+		"blobId":    blobID,
+		"type":      contentType,
+		"size":      len(body),
+	})
+}
+
+// readBlob returns the contents of the blob. nil is returned if the blob
+// does not exist.
+func (a *account) readBlob(id string) (data []byte, mediaType string, err error) {
+	if !validBlobID(id) {
+		return nil, "", nil
+	}
+
+	if strings.HasPrefix(id, "G") {
+		path := a.uploadPath(id)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, "", nil
+			}
+			return nil, "", err
+		}
+		mediaType, err := os.ReadFile(path + ".type")
+		if err != nil {
+			mediaType = []byte("application/octet-stream")
+		}
+		return data, string(mediaType), nil
+	}
+
+	if !strings.HasPrefix(id, "B") {
+		return nil, "", nil
+	}
+	mbox, uid, err := a.findEmail(id)
+	if err != nil || mbox == nil {
+		return nil, "", err
+	}
+	body, err := a.readMessage(mbox, uid)
+	if err != nil || body == nil {
+		return nil, "", err
+	}
+
+	_, partID, isPart := strings.Cut(id, "P")
+	if !isPart {
+		return body, "message/rfc822", nil
+	}
+	parsed, err := parseEmail(bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	part := parsed.root.findPart(partID)
+	if part == nil {
+		return nil, "", nil
+	}
+	return part.body, part.mediaType, nil
+}
+
+func (endp *Endpoint) serveDownload(w http.ResponseWriter, r *http.Request, acct *account) {
+	if r.PathValue("accountId") != acct.id {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	data, mediaType, err := acct.readBlob(r.PathValue("blobId"))
+	if err != nil {
+		endp.log.Error("failed to read blob", err, "username", acct.name, "blob_id", r.PathValue("blobId"))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if data == nil {
+		http.Error(w, "Blob not found", http.StatusNotFound)
+		return
+	}
+
+	if accept := r.URL.Query().Get("accept"); accept != "" {
+		mediaType = accept
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": r.PathValue("name"),
+	}))
+	w.Header().Set("Cache-Control", "private, immutable, max-age=31536000")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
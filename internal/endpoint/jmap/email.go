@@ -0,0 +1,1004 @@
+package jmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	netmail "net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+)
+
+var defaultEmailProperties = []string{
+	"id", "blobId", "threadId", "mailboxIds", "keywords", "size",
+	"receivedAt", "messageId", "inReplyTo", "references", "sender", "from",
+	"to", "cc", "bcc", "replyTo", "subject", "sentAt", "hasAttachment",
+	"preview", "bodyValues", "textBody", "htmlBody", "attachments",
+}
+
+var defaultBodyProperties = []string{
+	"partId", "blobId", "size", "name", "type", "charset", "disposition",
+	"cid", "language", "location",
+}
+
+// emailMetaProperties can be returned without reading the message.
+var emailMetaProperties = map[string]bool{
+	"id": true, "blobId": true, "threadId": true, "mailboxIds": true,
+	"keywords": true, "size": true, "receivedAt": true,
+}
+
+// emailHeaderProperties can be returned by reading the message header only.
+// header:* properties are handled separately.
+var emailHeaderProperties = map[string]bool{
+	"headers": true, "messageId": true, "inReplyTo": true, "references": true,
+	"sender": true, "from": true, "to": true, "cc": true, "bcc": true,
+	"replyTo": true, "subject": true, "sentAt": true,
+}
+
+// emailBodyProperties require the full message to be parsed.
+var emailBodyProperties = map[string]bool{
+	"bodyStructure": true, "bodyValues": true, "textBody": true,
+	"htmlBody": true, "attachments": true, "hasAttachment": true, "preview": true,
+}
+
+var bodyPartProperties = map[string]bool{
+	"partId": true, "blobId": true, "size": true, "headers": true, "name": true,
+	"type": true, "charset": true, "disposition": true, "cid": true,
+	"language": true, "location": true, "subParts": true,
+}
+
+// convenienceHeaders maps Email properties to the parsed header forms.
+var convenienceHeaders = map[string]string{
+	"messageId":  "header:Message-ID:asMessageIds",
+	"inReplyTo":  "header:In-Reply-To:asMessageIds",
+	"references": "header:References:asMessageIds",
+	"sender":     "header:Sender:asAddresses",
+	"from":       "header:From:asAddresses",
+	"to":         "header:To:asAddresses",
+	"cc":         "header:Cc:asAddresses",
+	"bcc":        "header:Bcc:asAddresses",
+	"replyTo":    "header:Reply-To:asAddresses",
+	"subject":    "header:Subject:asText",
+	"sentAt":     "header:Date:asDate",
+}
+
+const maxPartDepth = 16
+
+// bodyPart is the parsed MIME part (RFC 8621 Section 4.1.4).
+type bodyPart struct {
+	partID      string
+	header      textproto.Header
+	size        int
+	mediaType   string
+	charset     string
+	name        string
+	disposition string
+	subParts    []*bodyPart
+
+	body            []byte
+	encodingProblem bool
+}
+
+type parsedEmail struct {
+	header textproto.Header
+	root   *bodyPart
+
+	textBody    []*bodyPart
+	htmlBody    []*bodyPart
+	attachments []*bodyPart
+}
+
+func parseEmail(r io.Reader) (*parsedEmail, error) {
+	e, err := message.Read(r)
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return nil, err
+	}
+	root := parsePart(e, "", err != nil, 0)
+
+	parsed := &parsedEmail{
+		header:      e.Header.Header,
+		root:        root,
+		textBody:    []*bodyPart{},
+		htmlBody:    []*bodyPart{},
+		attachments: []*bodyPart{},
+	}
+	parseStructure([]*bodyPart{root}, "mixed", false, &parsed.htmlBody, &parsed.textBody, &parsed.attachments)
+	return parsed, nil
+}
+
+func childPartID(parent string, i int) string {
+	if parent == "" {
+		return strconv.Itoa(i)
+	}
+	return parent + "." + strconv.Itoa(i)
+}
+
+func parsePart(e *message.Entity, partID string, encodingProblem bool, depth int) *bodyPart {
+	p := &bodyPart{
+		header:          e.Header.Header,
+		encodingProblem: encodingProblem,
+	}
+	mediaType, params, err := e.Header.ContentType()
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	p.mediaType = strings.ToLower(mediaType)
+	if strings.HasPrefix(p.mediaType, "text/") {
+		p.charset = "us-ascii"
+		if cs := params["charset"]; cs != "" {
+			p.charset = cs
+		}
+	}
+	if disp, _, err := e.Header.ContentDisposition(); err == nil {
+		p.disposition = strings.ToLower(disp)
+	}
+	attHdr := mail.AttachmentHeader{Header: e.Header}
+	if name, err := attHdr.Filename(); err == nil {
+		p.name = name
+	}
+
+	if mr := e.MultipartReader(); mr != nil && depth < maxPartDepth {
+		for i := 1; ; i++ {
+			sub, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+				p.encodingProblem = true
+				break
+			}
+			p.subParts = append(p.subParts, parsePart(sub, childPartID(partID, i), err != nil, depth+1))
+		}
+		return p
+	}
+
+	if partID == "" {
+		partID = "1"
+	}
+	p.partID = partID
+	body, err := io.ReadAll(e.Body)
+	if err != nil {
+		p.encodingProblem = true
+	}
+	p.body = body
+	p.size = len(body)
+	return p
+}
+
+func (p *bodyPart) isMultipart() bool {
+	return p.partID == ""
+}
+
+func isInlineMediaType(t string) bool {
+	return strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "audio/") || strings.HasPrefix(t, "video/")
+}
+
+// parseStructure implements the algorithm from RFC 8621 Section 4.1.4 that
+// determines textBody, htmlBody and attachments. nil slice pointers have
+// the meaning of null in the reference algorithm.
+func parseStructure(parts []*bodyPart, multipartType string, inAlternative bool, htmlBody, textBody, attachments *[]*bodyPart) {
+	textLength, htmlLength := -1, -1
+	if textBody != nil {
+		textLength = len(*textBody)
+	}
+	if htmlBody != nil {
+		htmlLength = len(*htmlBody)
+	}
+
+	for i, part := range parts {
+		if part.isMultipart() {
+			subType := strings.TrimPrefix(part.mediaType, "multipart/")
+			parseStructure(part.subParts, subType, inAlternative || subType == "alternative", htmlBody, textBody, attachments)
+			continue
+		}
+
+		isInline := part.disposition != "attachment" &&
+			(part.mediaType == "text/plain" || part.mediaType == "text/html" || isInlineMediaType(part.mediaType)) &&
+			(i == 0 || (multipartType != "related" && (isInlineMediaType(part.mediaType) || part.name == "")))
+		if !isInline {
+			*attachments = append(*attachments, part)
+			continue
+		}
+
+		if multipartType == "alternative" {
+			switch part.mediaType {
+			case "text/plain":
+				if textBody != nil {
+					*textBody = append(*textBody, part)
+				}
+			case "text/html":
+				if htmlBody != nil {
+					*htmlBody = append(*htmlBody, part)
+				}
+			default:
+				*attachments = append(*attachments, part)
+			}
+			continue
+		}
+
+		if inAlternative {
+			if part.mediaType == "text/plain" {
+				htmlBody = nil
+			}
+			if part.mediaType == "text/html" {
+				textBody = nil
+			}
+		}
+		if textBody != nil {
+			*textBody = append(*textBody, part)
+		}
+		if htmlBody != nil {
+			*htmlBody = append(*htmlBody, part)
+		}
+		if (textBody == nil || htmlBody == nil) && isInlineMediaType(part.mediaType) {
+			*attachments = append(*attachments, part)
+		}
+	}
+
+	if multipartType == "alternative" && textBody != nil && htmlBody != nil {
+		if textLength == len(*textBody) && htmlLength != len(*htmlBody) {
+			*textBody = append(*textBody, (*htmlBody)[htmlLength:]...)
+		}
+		if htmlLength == len(*htmlBody) && textLength != len(*textBody) {
+			*htmlBody = append(*htmlBody, (*textBody)[textLength:]...)
+		}
+	}
+}
+
+// findPart returns the leaf part with the specified id.
+func (p *bodyPart) findPart(partID string) *bodyPart {
+	if p.partID == partID {
+		return p
+	}
+	for _, sub := range p.subParts {
+		if found := sub.findPart(partID); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func optString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (p *bodyPart) object(emailID string, props []string) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(props))
+	for _, prop := range props {
+		switch prop {
+		case "partId":
+			obj[prop] = optString(p.partID)
+		case "blobId":
+			if p.isMultipart() {
+				obj[prop] = nil
+			} else {
+				obj[prop] = blobID(emailID) + "P" + p.partID
+			}
+		case "size":
+			obj[prop] = p.size
+		case "headers":
+			obj[prop] = rawHeaders(p.header)
+		case "name":
+			obj[prop] = optString(p.name)
+		case "type":
+			obj[prop] = p.mediaType
+		case "charset":
+			obj[prop] = optString(p.charset)
+		case "disposition":
+			obj[prop] = optString(p.disposition)
+		case "cid":
+			cid := strings.TrimSpace(p.header.Get("Content-Id"))
+			obj[prop] = optString(strings.TrimSuffix(strings.TrimPrefix(cid, "<"), ">"))
+		case "language":
+			var langs []string
+			for _, l := range strings.Split(p.header.Get("Content-Language"), ",") {
+				if l = strings.TrimSpace(l); l != "" {
+					langs = append(langs, l)
+				}
+			}
+			obj[prop] = langs
+		case "location":
+			obj[prop] = optString(strings.TrimSpace(p.header.Get("Content-Location")))
+		case "subParts":
+			if !p.isMultipart() {
+				obj[prop] = nil
+				continue
+			}
+			subParts := make([]interface{}, 0, len(p.subParts))
+			for _, sub := range p.subParts {
+				subObj, err := sub.object(emailID, props)
+				if err != nil {
+					return nil, err
+				}
+				subParts = append(subParts, subObj)
+			}
+			obj[prop] = subParts
+		default:
+			val, err := headerProperty(p.header, prop)
+			if err != nil {
+				return nil, err
+			}
+			obj[prop] = val
+		}
+	}
+	return obj, nil
+}
+
+func partList(parts []*bodyPart, emailID string, props []string) ([]interface{}, error) {
+	list := make([]interface{}, 0, len(parts))
+	for _, p := range parts {
+		obj, err := p.object(emailID, props)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+type bodyValue struct {
+	Value             string `json:"value"`
+	IsEncodingProblem bool   `json:"isEncodingProblem"`
+	IsTruncated       bool   `json:"isTruncated"`
+}
+
+func (p *bodyPart) value(maxBytes int) bodyValue {
+	val := bodyValue{IsEncodingProblem: p.encodingProblem}
+	body := p.body
+	if !utf8.Valid(body) {
+		val.IsEncodingProblem = true
+		body = bytes.ToValidUTF8(body, []byte("�"))
+	}
+	if maxBytes > 0 && len(body) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:cut]
+		val.IsTruncated = true
+	}
+	val.Value = string(body)
+	return val
+}
+
+const maxPreviewLength = 256
+
+// htmlToText is a rough conversion used to build the preview.
+func htmlToText(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			b.WriteByte(' ')
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(b.String())
+}
+
+func (e *parsedEmail) preview() string {
+	var text string
+	for _, p := range e.textBody {
+		if p.mediaType == "text/plain" {
+			text = string(p.body)
+			break
+		}
+	}
+	if text == "" {
+		for _, p := range e.htmlBody {
+			if p.mediaType == "text/html" {
+				text = htmlToText(string(p.body))
+				break
+			}
+		}
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) > maxPreviewLength {
+		text = string([]rune(text)[:maxPreviewLength])
+	}
+	return strings.ToValidUTF8(text, "�")
+}
+
+// rawHeaders returns the list of all header fields with raw values.
+func rawHeaders(h textproto.Header) []interface{} {
+	list := make([]interface{}, 0, h.Len())
+	fields := h.Fields()
+	for fields.Next() {
+		raw, err := fields.Raw()
+		if err != nil {
+			continue
+		}
+		name, value, _ := strings.Cut(string(raw), ":")
+		list = append(list, map[string]interface{}{
+			"name":  name,
+			"value": strings.TrimSuffix(value, "\r\n"),
+		})
+	}
+	return list
+}
+
+var headerForms = map[string]bool{
+	"asRaw": true, "asText": true, "asAddresses": true, "asGroupedAddresses": true,
+	"asMessageIds": true, "asDate": true, "asURLs": true,
+}
+
+// parseHeaderProperty parses the "header:{name}[:{form}][:all]" property.
+func parseHeaderProperty(prop string) (name, form string, all bool, ok bool) {
+	if !strings.HasPrefix(prop, "header:") {
+		return "", "", false, false
+	}
+	parts := strings.Split(prop[len("header:"):], ":")
+	name, parts = parts[0], parts[1:]
+	if name == "" {
+		return "", "", false, false
+	}
+	form = "asRaw"
+	if len(parts) > 0 && parts[len(parts)-1] == "all" {
+		all = true
+		parts = parts[:len(parts)-1]
+	}
+	switch len(parts) {
+	case 0:
+	case 1:
+		form = parts[0]
+	default:
+		return "", "", false, false
+	}
+	if !headerForms[form] {
+		return "", "", false, false
+	}
+	return name, form, all, true
+}
+
+func headerProperty(h textproto.Header, prop string) (interface{}, error) {
+	if conv, ok := convenienceHeaders[prop]; ok {
+		prop = conv
+	}
+	name, form, all, ok := parseHeaderProperty(prop)
+	if !ok {
+		return nil, errInvalidArguments("unknown property: %s", prop)
+	}
+
+	var values []interface{}
+	fields := h.FieldsByKey(name)
+	for fields.Next() {
+		raw, err := fields.Raw()
+		if err != nil {
+			continue
+		}
+		_, value, _ := strings.Cut(string(raw), ":")
+		values = append(values, parseHeaderValue(strings.TrimSuffix(value, "\r\n"), form))
+	}
+
+	if all {
+		if values == nil {
+			return []interface{}{}, nil
+		}
+		return values, nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values[len(values)-1], nil
+}
+
+func unfold(value string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\n", "").Replace(value))
+}
+
+func parseHeaderValue(raw, form string) interface{} {
+	switch form {
+	case "asText":
+		dec := mime.WordDecoder{CharsetReader: message.CharsetReader}
+		text, err := dec.DecodeHeader(unfold(raw))
+		if err != nil {
+			return unfold(raw)
+		}
+		return text
+	case "asAddresses", "asGroupedAddresses":
+		addrs, err := mail.ParseAddressList(unfold(raw))
+		if err != nil {
+			addrs = nil
+		}
+		list := make([]interface{}, 0, len(addrs))
+		for _, addr := range addrs {
+			list = append(list, map[string]interface{}{
+				"name":  optString(addr.Name),
+				"email": addr.Address,
+			})
+		}
+		if form == "asGroupedAddresses" {
+			return []interface{}{map[string]interface{}{"name": nil, "addresses": list}}
+		}
+		return list
+	case "asMessageIds":
+		h := mail.Header{}
+		h.Set("Message-Id", unfold(raw))
+		ids, err := h.MsgIDList("Message-Id")
+		if err != nil || len(ids) == 0 {
+			return nil
+		}
+		return ids
+	case "asDate":
+		t, err := netmail.ParseDate(unfold(raw))
+		if err != nil {
+			return nil
+		}
+		return t.Format(time.RFC3339)
+	case "asURLs":
+		var urls []string
+		for _, item := range strings.Split(unfold(raw), ",") {
+			item = strings.TrimSpace(item)
+			if strings.HasPrefix(item, "<") && strings.HasSuffix(item, ">") {
+				urls = append(urls, item[1:len(item)-1])
+			}
+		}
+		if urls == nil {
+			return nil
+		}
+		return urls
+	}
+	return raw
+}
+
+// findEmail returns the mailbox containing the Email. nil is returned if the
+// id is not valid or the mailbox does not exist.
+func (a *account) findEmail(id string) (*mailboxInfo, uint32, error) {
+	uidValidity, uid, ok := parseEmailID(id)
+	if !ok {
+		return nil, 0, nil
+	}
+	mbox, err := a.mailboxByUIDValidity(uidValidity)
+	return mbox, uid, err
+}
+
+// fetch returns the messages with the specified UIDs. Non-existent messages
+// are silently skipped.
+func (a *account) fetch(mbox *mailboxInfo, uids []uint32, items []imap.FetchItem) ([]*imap.Message, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	_, m, err := a.user.GetMailbox(mbox.name, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	seq := new(imap.SeqSet)
+	seq.AddNum(uids...)
+	ch := make(chan *imap.Message, 16)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.ListMessages(true, seq, items, ch)
+	}()
+	msgs := make([]*imap.Message, 0, len(uids))
+	for msg := range ch {
+		msgs = append(msgs, msg)
+	}
+	return msgs, <-errCh
+}
+
+var (
+	fullSection   = &imap.BodySectionName{Peek: true}
+	headerSection = &imap.BodySectionName{
+		BodyPartName: imap.BodyPartName{Specifier: imap.HeaderSpecifier},
+		Peek:         true,
+	}
+	metaItems = []imap.FetchItem{imap.FetchUid, imap.FetchFlags, imap.FetchInternalDate, imap.FetchRFC822Size}
+)
+
+// messageBody returns the fetched body section. go-imap's GetBody can't be
+// used since the storage returns sections with the PEEK flag.
+func messageBody(msg *imap.Message, section *imap.BodySectionName) imap.Literal {
+	for s, body := range msg.Body {
+		if s.BodyPartName.Equal(&section.BodyPartName) {
+			if body == nil {
+				return bytes.NewReader(nil)
+			}
+			return body
+		}
+	}
+	return nil
+}
+
+// readMessage returns the full message body.
+func (a *account) readMessage(mbox *mailboxInfo, uid uint32) ([]byte, error) {
+	msgs, err := a.fetch(mbox, []uint32{uid}, []imap.FetchItem{imap.FetchUid, fullSection.FetchItem()})
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	lit := messageBody(msgs[0], fullSection)
+	if lit == nil {
+		return nil, errors.New("jmap: missing message body")
+	}
+	return io.ReadAll(lit)
+}
+
+type emailGetArgs struct {
+	AccountID           string    `json:"accountId"`
+	IDs                 *[]string `json:"ids"`
+	Properties          *[]string `json:"properties"`
+	BodyProperties      *[]string `json:"bodyProperties"`
+	FetchTextBodyValues bool      `json:"fetchTextBodyValues"`
+	FetchHTMLBodyValues bool      `json:"fetchHTMLBodyValues"`
+	FetchAllBodyValues  bool      `json:"fetchAllBodyValues"`
+	MaxBodyValueBytes   int       `json:"maxBodyValueBytes"`
+}
+
+func (a *account) emailGet(rawArgs json.RawMessage) (interface{}, error) {
+	var args emailGetArgs
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+
+	props := defaultEmailProperties
+	if args.Properties != nil {
+		props = *args.Properties
+	}
+	bodyProps := defaultBodyProperties
+	if args.BodyProperties != nil {
+		bodyProps = *args.BodyProperties
+	}
+	needHeader, needBody := false, false
+	for _, prop := range props {
+		switch {
+		case emailMetaProperties[prop]:
+		case emailHeaderProperties[prop]:
+			needHeader = true
+		case emailBodyProperties[prop]:
+			needBody = true
+		default:
+			if _, _, _, ok := parseHeaderProperty(prop); !ok {
+				return nil, errInvalidArguments("unknown property: %s", prop)
+			}
+			needHeader = true
+		}
+	}
+	for _, prop := range bodyProps {
+		if _, _, _, ok := parseHeaderProperty(prop); !ok && !bodyPartProperties[prop] {
+			return nil, errInvalidArguments("unknown body property: %s", prop)
+		}
+	}
+
+	state, err := a.emailState()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	if args.IDs != nil {
+		ids = *args.IDs
+	} else {
+		ids, err = a.allEmailIDs()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+
+	items := metaItems
+	section := (*imap.BodySectionName)(nil)
+	switch {
+	case needBody:
+		section = fullSection
+	case needHeader:
+		section = headerSection
+	}
+	if section != nil {
+		items = append(append([]imap.FetchItem{}, metaItems...), section.FetchItem())
+	}
+
+	found := make(map[string]map[string]interface{}, len(ids))
+	err = a.forEachEmail(ids, items, func(mbox *mailboxInfo, msg *imap.Message) error {
+		id := emailID(mbox.status.UidValidity, msg.Uid)
+		obj, err := a.emailObject(mbox, msg, section, props, bodyProps, &args)
+		if err != nil {
+			return err
+		}
+		found[id] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]interface{}, 0, len(found))
+	notFound := []string{}
+	for _, id := range ids {
+		if obj, ok := found[a.resolveID(id)]; ok {
+			list = append(list, obj)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+	return map[string]interface{}{
+		"accountId": a.id,
+		"state":     state,
+		"list":      list,
+		"notFound":  notFound,
+	}, nil
+}
+
+// forEachEmail fetches the Emails with the specified ids grouped by mailbox.
+func (a *account) forEachEmail(ids []string, items []imap.FetchItem, fn func(*mailboxInfo, *imap.Message) error) error {
+	byMbox := map[*mailboxInfo][]uint32{}
+	for _, id := range ids {
+		mbox, uid, err := a.findEmail(a.resolveID(id))
+		if err != nil {
+			return err
+		}
+		if mbox == nil {
+			continue
+		}
+		byMbox[mbox] = append(byMbox[mbox], uid)
+	}
+
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return err
+	}
+	for _, mbox := range mboxes {
+		uids, ok := byMbox[mbox]
+		if !ok {
+			continue
+		}
+		msgs, err := a.fetch(mbox, uids, items)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if err := fn(mbox, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *account) allEmailIDs() ([]string, error) {
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, mbox := range mboxes {
+		uids, err := a.search(mbox, imap.NewSearchCriteria())
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range uids {
+			ids = append(ids, emailID(mbox.status.UidValidity, uid))
+		}
+		if len(ids) > a.endp.maxObjects {
+			return nil, errRequestTooLarge
+		}
+	}
+	return ids, nil
+}
+
+func (a *account) search(mbox *mailboxInfo, criteria *imap.SearchCriteria) ([]uint32, error) {
+	_, m, err := a.user.GetMailbox(mbox.name, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	uids, err := m.SearchMessages(true, criteria)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+func (a *account) emailObject(mbox *mailboxInfo, msg *imap.Message, section *imap.BodySectionName, props, bodyProps []string, args *emailGetArgs) (map[string]interface{}, error) {
+	id := emailID(mbox.status.UidValidity, msg.Uid)
+
+	var (
+		header textproto.Header
+		parsed *parsedEmail
+	)
+	if section != nil {
+		lit := messageBody(msg, section)
+		if lit == nil {
+			return nil, errors.New("jmap: missing message body")
+		}
+		if section == fullSection {
+			var err error
+			parsed, err = parseEmail(lit)
+			if err != nil {
+				return nil, err
+			}
+			header = parsed.header
+		} else {
+			var err error
+			header, err = textproto.ReadHeader(bufio.NewReader(lit))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	obj := make(map[string]interface{}, len(props)+1)
+	obj["id"] = id
+	for _, prop := range props {
+		var err error
+		switch prop {
+		case "id":
+		case "blobId":
+			obj[prop] = blobID(id)
+		case "threadId":
+			obj[prop] = threadID(id)
+		case "mailboxIds":
+			obj[prop] = map[string]bool{mbox.id: true}
+		case "keywords":
+			obj[prop] = flagsToKeywords(msg.Flags)
+		case "size":
+			obj[prop] = msg.Size
+		case "receivedAt":
+			obj[prop] = msg.InternalDate.UTC().Format(time.RFC3339)
+		case "headers":
+			obj[prop] = rawHeaders(header)
+		case "bodyStructure":
+			obj[prop], err = parsed.root.object(id, append(append([]string{}, bodyProps...), "subParts"))
+		case "textBody":
+			obj[prop], err = partList(parsed.textBody, id, bodyProps)
+		case "htmlBody":
+			obj[prop], err = partList(parsed.htmlBody, id, bodyProps)
+		case "attachments":
+			obj[prop], err = partList(parsed.attachments, id, bodyProps)
+		case "hasAttachment":
+			obj[prop] = len(parsed.attachments) != 0
+		case "preview":
+			obj[prop] = parsed.preview()
+		case "bodyValues":
+			values := map[string]bodyValue{}
+			var parts []*bodyPart
+			if args.FetchTextBodyValues || args.FetchAllBodyValues {
+				parts = append(parts, parsed.textBody...)
+			}
+			if args.FetchHTMLBodyValues || args.FetchAllBodyValues {
+				parts = append(parts, parsed.htmlBody...)
+			}
+			for _, p := range parts {
+				if strings.HasPrefix(p.mediaType, "text/") {
+					values[p.partID] = p.value(args.MaxBodyValueBytes)
+				}
+			}
+			obj[prop] = values
+		default:
+			obj[prop], err = headerProperty(header, prop)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func (a *account) emailChanges(rawArgs json.RawMessage) (interface{}, error) {
+	return a.typeChanges(rawArgs, a.emailState)
+}
+
+func (a *account) threadChanges(rawArgs json.RawMessage) (interface{}, error) {
+	return a.typeChanges(rawArgs, a.emailState)
+}
+
+// typeChanges implements /changes methods. Changes are not recorded, so
+// only the request with the current state can be answered.
+func (a *account) typeChanges(rawArgs json.RawMessage, state func() (string, error)) (interface{}, error) {
+	var args struct {
+		AccountID  string `json:"accountId"`
+		SinceState string `json:"sinceState"`
+		MaxChanges *int   `json:"maxChanges"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	if args.MaxChanges != nil && *args.MaxChanges <= 0 {
+		return nil, errInvalidArguments("maxChanges should be positive")
+	}
+	current, err := state()
+	if err != nil {
+		return nil, err
+	}
+	if args.SinceState != current {
+		return nil, errCannotCalculate
+	}
+	return map[string]interface{}{
+		"accountId":      a.id,
+		"oldState":       args.SinceState,
+		"newState":       current,
+		"hasMoreChanges": false,
+		"created":        []string{},
+		"updated":        []string{},
+		"destroyed":      []string{},
+	}, nil
+}
+
+func (a *account) threadGet(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID  string    `json:"accountId"`
+		IDs        *[]string `json:"ids"`
+		Properties *[]string `json:"properties"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	if args.IDs == nil {
+		ids, err := a.allEmailIDs()
+		if err != nil {
+			return nil, err
+		}
+		threadIDs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			threadIDs = append(threadIDs, threadID(id))
+		}
+		args.IDs = &threadIDs
+	}
+	if len(*args.IDs) > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+	state, err := a.emailState()
+	if err != nil {
+		return nil, err
+	}
+
+	emailIDs := make([]string, 0, len(*args.IDs))
+	for _, id := range *args.IDs {
+		if strings.HasPrefix(id, "T") {
+			emailIDs = append(emailIDs, "M"+id[1:])
+		}
+	}
+	existing := map[string]bool{}
+	err = a.forEachEmail(emailIDs, []imap.FetchItem{imap.FetchUid}, func(mbox *mailboxInfo, msg *imap.Message) error {
+		existing[threadID(emailID(mbox.status.UidValidity, msg.Uid))] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := []interface{}{}
+	notFound := []string{}
+	for _, id := range *args.IDs {
+		if !existing[id] {
+			notFound = append(notFound, id)
+			continue
+		}
+		list = append(list, map[string]interface{}{
+			"id":       id,
+			"emailIds": []string{"M" + id[1:]},
+		})
+	}
+	return map[string]interface{}{
+		"accountId": a.id,
+		"state":     state,
+		"list":      list,
+		"notFound":  notFound,
+	}, nil
+}
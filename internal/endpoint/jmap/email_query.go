@@ -0,0 +1,471 @@
+package jmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+var emailSortProperties = []string{"receivedAt", "size", "from", "to", "subject", "sentAt", "hasKeyword"}
+
+var (
+	errUnsupportedFilter = &methodError{Type: "unsupportedFilter"}
+	errUnsupportedSort   = &methodError{Type: "unsupportedSort"}
+	errAnchorNotFound    = &methodError{Type: "anchorNotFound"}
+)
+
+type filterOperator struct {
+	Operator   string            `json:"operator"`
+	Conditions []json.RawMessage `json:"conditions"`
+}
+
+type emailFilterCondition struct {
+	InMailbox               string   `json:"inMailbox"`
+	InMailboxOtherThan      []string `json:"inMailboxOtherThan"`
+	Before                  string   `json:"before"`
+	After                   string   `json:"after"`
+	MinSize                 *uint32  `json:"minSize"`
+	MaxSize                 *uint32  `json:"maxSize"`
+	AllInThreadHaveKeyword  string   `json:"allInThreadHaveKeyword"`
+	SomeInThreadHaveKeyword string   `json:"someInThreadHaveKeyword"`
+	NoneInThreadHaveKeyword string   `json:"noneInThreadHaveKeyword"`
+	HasKeyword              string   `json:"hasKeyword"`
+	NotKeyword              string   `json:"notKeyword"`
+	HasAttachment           *bool    `json:"hasAttachment"`
+	Text                    string   `json:"text"`
+	From                    string   `json:"from"`
+	To                      string   `json:"to"`
+	Cc                      string   `json:"cc"`
+	Bcc                     string   `json:"bcc"`
+	Subject                 string   `json:"subject"`
+	Body                    string   `json:"body"`
+	Header                  []string `json:"header"`
+}
+
+// searchFilter is the filter translated into IMAP SEARCH criteria for a
+// single mailbox.
+type searchFilter struct {
+	// never is set if the filter can't match any message in the mailbox.
+	never bool
+	// always is set if the filter matches all messages in the mailbox.
+	always   bool
+	criteria *imap.SearchCriteria
+}
+
+func isOperator(raw json.RawMessage) bool {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return false
+	}
+	_, ok := probe["operator"]
+	return ok
+}
+
+func (a *account) compileFilter(raw json.RawMessage, mbox *mailboxInfo) (searchFilter, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return searchFilter{always: true, criteria: imap.NewSearchCriteria()}, nil
+	}
+	if isOperator(raw) {
+		var op filterOperator
+		if err := json.Unmarshal(raw, &op); err != nil {
+			return searchFilter{}, errUnsupportedFilter
+		}
+		subs := make([]searchFilter, 0, len(op.Conditions))
+		for _, cond := range op.Conditions {
+			sub, err := a.compileFilter(cond, mbox)
+			if err != nil {
+				return searchFilter{}, err
+			}
+			subs = append(subs, sub)
+		}
+		return combineFilters(op.Operator, subs)
+	}
+
+	var cond emailFilterCondition
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cond); err != nil {
+		return searchFilter{}, errUnsupportedFilter
+	}
+	return cond.compile(mbox)
+}
+
+func combineFilters(operator string, subs []searchFilter) (searchFilter, error) {
+	var effective []*imap.SearchCriteria
+	switch operator {
+	case "AND":
+		for _, sub := range subs {
+			if sub.never {
+				return searchFilter{never: true}, nil
+			}
+			if !sub.always {
+				effective = append(effective, sub.criteria)
+			}
+		}
+		if len(effective) == 0 {
+			return searchFilter{always: true, criteria: imap.NewSearchCriteria()}, nil
+		}
+		if len(effective) == 1 {
+			return searchFilter{criteria: effective[0]}, nil
+		}
+		// AND is expressed as NOT (NOT a) (NOT b) ...
+		c := imap.NewSearchCriteria()
+		for _, e := range effective {
+			c.Not = append(c.Not, &imap.SearchCriteria{Not: []*imap.SearchCriteria{e}})
+		}
+		return searchFilter{criteria: c}, nil
+	case "OR":
+		for _, sub := range subs {
+			if sub.always {
+				return searchFilter{always: true, criteria: imap.NewSearchCriteria()}, nil
+			}
+			if !sub.never {
+				effective = append(effective, sub.criteria)
+			}
+		}
+		if len(effective) == 0 {
+			return searchFilter{never: true}, nil
+		}
+		c := effective[len(effective)-1]
+		for i := len(effective) - 2; i >= 0; i-- {
+			c = &imap.SearchCriteria{Or: [][2]*imap.SearchCriteria{{effective[i], c}}}
+		}
+		return searchFilter{criteria: c}, nil
+	case "NOT":
+		for _, sub := range subs {
+			if sub.always {
+				return searchFilter{never: true}, nil
+			}
+			if !sub.never {
+				effective = append(effective, sub.criteria)
+			}
+		}
+		if len(effective) == 0 {
+			return searchFilter{always: true, criteria: imap.NewSearchCriteria()}, nil
+		}
+		return searchFilter{criteria: &imap.SearchCriteria{Not: effective}}, nil
+	}
+	return searchFilter{}, errUnsupportedFilter
+}
+
+func parseUTCDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errUnsupportedFilter
+	}
+	return t, nil
+}
+
+func (cond *emailFilterCondition) compile(mbox *mailboxInfo) (searchFilter, error) {
+	c := imap.NewSearchCriteria()
+	restricted := false
+
+	if cond.InMailbox != "" && cond.InMailbox != mbox.id {
+		return searchFilter{never: true}, nil
+	}
+	for _, id := range cond.InMailboxOtherThan {
+		if id == mbox.id {
+			return searchFilter{never: true}, nil
+		}
+	}
+	if cond.HasAttachment != nil {
+		return searchFilter{}, errUnsupportedFilter
+	}
+	if cond.Before != "" {
+		t, err := parseUTCDate(cond.Before)
+		if err != nil {
+			return searchFilter{}, err
+		}
+		c.Before = t
+		restricted = true
+	}
+	if cond.After != "" {
+		t, err := parseUTCDate(cond.After)
+		if err != nil {
+			return searchFilter{}, err
+		}
+		c.Since = t
+		restricted = true
+	}
+	if cond.MinSize != nil && *cond.MinSize > 0 {
+		c.Larger = *cond.MinSize - 1
+		restricted = true
+	}
+	if cond.MaxSize != nil {
+		if *cond.MaxSize == 0 {
+			return searchFilter{never: true}, nil
+		}
+		c.Smaller = *cond.MaxSize
+		restricted = true
+	}
+	// Each Email is its own Thread, so thread keyword conditions are the
+	// same as the Email ones.
+	for _, kw := range []string{cond.HasKeyword, cond.AllInThreadHaveKeyword, cond.SomeInThreadHaveKeyword} {
+		if kw == "" {
+			continue
+		}
+		if !validKeyword(kw) {
+			return searchFilter{}, errUnsupportedFilter
+		}
+		c.WithFlags = append(c.WithFlags, keywordToFlag(kw))
+		restricted = true
+	}
+	for _, kw := range []string{cond.NotKeyword, cond.NoneInThreadHaveKeyword} {
+		if kw == "" {
+			continue
+		}
+		if !validKeyword(kw) {
+			return searchFilter{}, errUnsupportedFilter
+		}
+		c.WithoutFlags = append(c.WithoutFlags, keywordToFlag(kw))
+		restricted = true
+	}
+	if cond.Text != "" {
+		c.Text = append(c.Text, cond.Text)
+		restricted = true
+	}
+	if cond.Body != "" {
+		c.Body = append(c.Body, cond.Body)
+		restricted = true
+	}
+	for name, value := range map[string]string{
+		"From": cond.From, "To": cond.To, "Cc": cond.Cc, "Bcc": cond.Bcc, "Subject": cond.Subject,
+	} {
+		if value != "" {
+			c.Header.Add(name, value)
+			restricted = true
+		}
+	}
+	if cond.Header != nil {
+		switch len(cond.Header) {
+		case 1:
+			c.Header.Add(cond.Header[0], "")
+		case 2:
+			c.Header.Add(cond.Header[0], cond.Header[1])
+		default:
+			return searchFilter{}, errUnsupportedFilter
+		}
+		restricted = true
+	}
+
+	return searchFilter{always: !restricted, criteria: c}, nil
+}
+
+type comparator struct {
+	Property    string `json:"property"`
+	IsAscending *bool  `json:"isAscending"`
+	Keyword     string `json:"keyword"`
+	Collation   string `json:"collation"`
+}
+
+type queryEntry struct {
+	id  string
+	msg *imap.Message
+}
+
+func envelopeAddr(addrs []*imap.Address) string {
+	if len(addrs) == 0 {
+		return ""
+	}
+	if addrs[0].PersonalName != "" {
+		return strings.ToLower(addrs[0].PersonalName)
+	}
+	return strings.ToLower(addrs[0].Address())
+}
+
+func compareEntries(a, b *queryEntry, c comparator) int {
+	var res int
+	switch c.Property {
+	case "receivedAt":
+		res = a.msg.InternalDate.Compare(b.msg.InternalDate)
+	case "size":
+		res = compareUint(a.msg.Size, b.msg.Size)
+	case "from":
+		res = strings.Compare(envelopeAddr(a.msg.Envelope.From), envelopeAddr(b.msg.Envelope.From))
+	case "to":
+		res = strings.Compare(envelopeAddr(a.msg.Envelope.To), envelopeAddr(b.msg.Envelope.To))
+	case "subject":
+		res = strings.Compare(strings.ToLower(a.msg.Envelope.Subject), strings.ToLower(b.msg.Envelope.Subject))
+	case "sentAt":
+		res = a.msg.Envelope.Date.Compare(b.msg.Envelope.Date)
+	case "hasKeyword":
+		flag := keywordToFlag(c.Keyword)
+		res = compareBool(hasAttr(a.msg.Flags, flag), hasAttr(b.msg.Flags, flag))
+	}
+	if c.IsAscending != nil && !*c.IsAscending {
+		res = -res
+	}
+	return res
+}
+
+func compareUint(a, b uint32) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func (a *account) emailQuery(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID       string          `json:"accountId"`
+		Filter          json.RawMessage `json:"filter"`
+		Sort            []comparator    `json:"sort"`
+		Position        int             `json:"position"`
+		Anchor          *string         `json:"anchor"`
+		AnchorOffset    int             `json:"anchorOffset"`
+		Limit           *int            `json:"limit"`
+		CalculateTotal  bool            `json:"calculateTotal"`
+		CollapseThreads bool            `json:"collapseThreads"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+
+	needEnvelope := false
+	for _, c := range args.Sort {
+		switch c.Property {
+		case "receivedAt", "size":
+		case "from", "to", "subject", "sentAt":
+			needEnvelope = true
+		case "hasKeyword":
+			if !validKeyword(c.Keyword) {
+				return nil, errUnsupportedSort
+			}
+		default:
+			return nil, errUnsupportedSort
+		}
+		if c.Collation != "" && c.Collation != "i;ascii-casemap" {
+			return nil, errUnsupportedSort
+		}
+	}
+	if len(args.Sort) == 0 {
+		descending := false
+		args.Sort = []comparator{{Property: "receivedAt", IsAscending: &descending}}
+	}
+	if args.Limit != nil && *args.Limit < 0 {
+		return nil, errInvalidArguments("limit should not be negative")
+	}
+
+	state, err := a.emailState()
+	if err != nil {
+		return nil, err
+	}
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	items := metaItems
+	if needEnvelope {
+		items = append(append([]imap.FetchItem{}, metaItems...), imap.FetchEnvelope)
+	}
+	var entries []*queryEntry
+	for _, mbox := range mboxes {
+		filter, err := a.compileFilter(args.Filter, mbox)
+		if err != nil {
+			return nil, err
+		}
+		if filter.never {
+			continue
+		}
+		uids, err := a.search(mbox, filter.criteria)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := a.fetch(mbox, uids, items)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if needEnvelope && msg.Envelope == nil {
+				msg.Envelope = &imap.Envelope{}
+			}
+			entries = append(entries, &queryEntry{id: emailID(mbox.status.UidValidity, msg.Uid), msg: msg})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		for _, c := range args.Sort {
+			if res := compareEntries(entries[i], entries[j], c); res != 0 {
+				return res < 0
+			}
+		}
+		return entries[i].id < entries[j].id
+	})
+
+	position := args.Position
+	if args.Anchor != nil {
+		idx := -1
+		for i, e := range entries {
+			if e.id == *args.Anchor {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, errAnchorNotFound
+		}
+		position = idx + args.AnchorOffset
+	} else if position < 0 {
+		position += len(entries)
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > len(entries) {
+		position = len(entries)
+	}
+
+	limit := a.endp.maxObjects
+	limitCapped := true
+	if args.Limit != nil && *args.Limit <= limit {
+		limit = *args.Limit
+		limitCapped = false
+	}
+	end := position + limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+	ids := make([]string, 0, end-position)
+	for _, e := range entries[position:end] {
+		ids = append(ids, e.id)
+	}
+
+	resp := map[string]interface{}{
+		"accountId":           a.id,
+		"queryState":          state,
+		"canCalculateChanges": false,
+		"position":            position,
+		"ids":                 ids,
+	}
+	if args.CalculateTotal {
+		resp["total"] = len(entries)
+	}
+	if limitCapped && args.Limit != nil {
+		resp["limit"] = limit
+	}
+	return resp, nil
+}
+
+// queryChanges implements /queryChanges methods. Query results are not
+// recorded, so clients have to repeat the query.
+func (a *account) queryChanges(json.RawMessage) (interface{}, error) {
+	return nil, errCannotCalculate
+}
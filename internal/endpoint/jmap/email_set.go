@@ -0,0 +1,887 @@
+package jmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+)
+
+// moveMailbox and delMailbox are implemented by the storage mailboxes.
+type moveMailbox interface {
+	MoveMessages(uid bool, seqset *imap.SeqSet, dest string) error
+}
+
+type delMailbox interface {
+	DelMessages(uid bool, seqset *imap.SeqSet) error
+}
+
+func (a *account) emailSet(rawArgs json.RawMessage) (interface{}, error) {
+	var args setArgs
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	return a.setEmails(&args)
+}
+
+func (a *account) setEmails(args *setArgs) (*setResponse, error) {
+	if args.count() > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+	oldState, err := a.emailState()
+	if err != nil {
+		return nil, err
+	}
+	if args.IfInState != nil && *args.IfInState != oldState {
+		return nil, errStateMismatch
+	}
+	resp := &setResponse{AccountID: a.id, OldState: oldState}
+
+	for _, cid := range sortedKeys(args.Create) {
+		obj, err := a.createEmail(args.Create[cid])
+		if err != nil {
+			resp.notCreated(cid, a.serverFail(err))
+			continue
+		}
+		a.createdIDs[cid] = obj["id"].(string)
+		resp.created(cid, obj)
+	}
+	for _, id := range sortedKeys(args.Update) {
+		if err := a.updateEmail(a.resolveID(id), args.Update[id]); err != nil {
+			resp.notUpdated(id, a.serverFail(err))
+			continue
+		}
+		resp.updated(id, nil)
+	}
+	for _, id := range args.Destroy {
+		if err := a.destroyEmail(a.resolveID(id)); err != nil {
+			resp.notDestroyed(id, a.serverFail(err))
+			continue
+		}
+		resp.destroyed(id)
+	}
+
+	if resp.Created != nil || resp.Updated != nil || resp.Destroyed != nil {
+		a.changed()
+	}
+	resp.NewState, err = a.emailState()
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// unescapePointer decodes the JSON pointer path component.
+func unescapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
+
+// decodeMailboxIDs decodes the mailboxIds value and returns the only
+// mailbox set.
+func (a *account) decodeMailboxIDs(raw json.RawMessage) (*mailboxInfo, error) {
+	var ids map[string]bool
+	if err := json.Unmarshal(raw, &ids); err != nil {
+		return nil, invalidProperties(err.Error(), "mailboxIds")
+	}
+	return a.singleMailbox(ids)
+}
+
+func (a *account) singleMailbox(ids map[string]bool) (*mailboxInfo, error) {
+	var target *mailboxInfo
+	for id, set := range ids {
+		if !set {
+			return nil, invalidProperties("mailboxIds values should be true", "mailboxIds")
+		}
+		if target != nil {
+			return nil, &setError{Type: "tooManyMailboxes", Description: "Email can belong to only one mailbox"}
+		}
+		mbox, err := a.mailboxByID(a.resolveID(id))
+		if err != nil {
+			return nil, err
+		}
+		if mbox == nil {
+			return nil, invalidProperties("mailbox does not exist", "mailboxIds")
+		}
+		target = mbox
+	}
+	if target == nil {
+		return nil, invalidProperties("Email should belong to a mailbox", "mailboxIds")
+	}
+	return target, nil
+}
+
+func decodeKeywords(raw json.RawMessage) (map[string]bool, error) {
+	var keywords map[string]bool
+	if err := json.Unmarshal(raw, &keywords); err != nil {
+		return nil, invalidProperties(err.Error(), "keywords")
+	}
+	normalized := make(map[string]bool, len(keywords))
+	for kw, set := range keywords {
+		if !set || !validKeyword(kw) {
+			return nil, invalidProperties("invalid keyword: "+kw, "keywords")
+		}
+		normalized[strings.ToLower(kw)] = true
+	}
+	return normalized, nil
+}
+
+func (a *account) updateEmail(id string, patch map[string]json.RawMessage) error {
+	mbox, uid, err := a.findEmail(id)
+	if err != nil {
+		return err
+	}
+	if mbox == nil {
+		return errNotFound
+	}
+	msgs, err := a.fetch(mbox, []uint32{uid}, metaItems)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return errNotFound
+	}
+
+	current := flagsToKeywords(msgs[0].Flags)
+	keywords := flagsToKeywords(msgs[0].Flags)
+	mboxIDs := map[string]bool{mbox.id: true}
+	for prop, val := range patch {
+		switch {
+		case prop == "keywords":
+			keywords, err = decodeKeywords(val)
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(prop, "keywords/"):
+			kw := strings.ToLower(unescapePointer(prop[len("keywords/"):]))
+			var set *bool
+			if err := json.Unmarshal(val, &set); err != nil || !validKeyword(kw) || (set != nil && !*set) {
+				return invalidProperties("invalid keyword patch", prop)
+			}
+			if set != nil {
+				keywords[kw] = true
+			} else {
+				delete(keywords, kw)
+			}
+		case prop == "mailboxIds":
+			mboxIDs = nil
+			if err := json.Unmarshal(val, &mboxIDs); err != nil {
+				return invalidProperties(err.Error(), prop)
+			}
+		case strings.HasPrefix(prop, "mailboxIds/"):
+			mboxID := a.resolveID(unescapePointer(prop[len("mailboxIds/"):]))
+			var set *bool
+			if err := json.Unmarshal(val, &set); err != nil || (set != nil && !*set) {
+				return invalidProperties("invalid mailboxIds patch", prop)
+			}
+			if set != nil {
+				mboxIDs[mboxID] = true
+			} else {
+				delete(mboxIDs, mboxID)
+			}
+		default:
+			return invalidProperties("unknown or immutable property", prop)
+		}
+	}
+	target, err := a.singleMailbox(mboxIDs)
+	if err != nil {
+		return err
+	}
+
+	var add, remove []string
+	for kw := range keywords {
+		if !current[kw] {
+			add = append(add, keywordToFlag(kw))
+		}
+	}
+	for kw := range current {
+		if !keywords[kw] {
+			remove = append(remove, keywordToFlag(kw))
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	if len(add) == 0 && len(remove) == 0 && target == mbox {
+		return nil
+	}
+
+	_, m, err := a.user.GetMailbox(mbox.name, false, nil)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	seq := new(imap.SeqSet)
+	seq.AddNum(uid)
+	if len(add) != 0 {
+		if err := m.UpdateMessagesFlags(true, seq, imap.AddFlags, true, add); err != nil {
+			return err
+		}
+	}
+	if len(remove) != 0 {
+		if err := m.UpdateMessagesFlags(true, seq, imap.RemoveFlags, true, remove); err != nil {
+			return err
+		}
+	}
+	if target != mbox {
+		mm, ok := m.(moveMailbox)
+		if !ok {
+			return errors.New("jmap: storage does not support moving messages")
+		}
+		if err := mm.MoveMessages(true, seq, target.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *account) destroyEmail(id string) error {
+	mbox, uid, err := a.findEmail(id)
+	if err != nil {
+		return err
+	}
+	if mbox == nil {
+		return errNotFound
+	}
+	msgs, err := a.fetch(mbox, []uint32{uid}, []imap.FetchItem{imap.FetchUid})
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return errNotFound
+	}
+
+	_, m, err := a.user.GetMailbox(mbox.name, false, nil)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	dm, ok := m.(delMailbox)
+	if !ok {
+		return errors.New("jmap: storage does not support deleting messages")
+	}
+	seq := new(imap.SeqSet)
+	seq.AddNum(uid)
+	return dm.DelMessages(true, seq)
+}
+
+// appendMessage stores the message in the mailbox and returns the created
+// Email object.
+func (a *account) appendMessage(mbox *mailboxInfo, keywords map[string]bool, receivedAt time.Time, body []byte) (map[string]interface{}, error) {
+	flags, err := keywordsToFlags(keywords)
+	if err != nil {
+		return nil, invalidProperties(err.Error(), "keywords")
+	}
+
+	// The storage does not report the UID of the appended message. Messages
+	// appended later have greater UIDs, so the new message is the first one
+	// above the previous UIDNEXT that matches.
+	status, err := a.user.Status(mbox.name, []imap.StatusItem{imap.StatusUidNext})
+	if err != nil {
+		return nil, err
+	}
+	if err := a.user.CreateMessage(mbox.name, flags, receivedAt, bytes.NewReader(body), nil); err != nil {
+		var statusErr *imap.ErrStatusResp
+		if errors.As(err, &statusErr) && statusErr.Resp.Code == "OVERQUOTA" {
+			return nil, &setError{Type: "overQuota"}
+		}
+		return nil, err
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(status.UidNext, 0)
+	criteria.Larger = uint32(len(body)) - 1
+	criteria.Smaller = uint32(len(body)) + 1
+	uids, err := a.search(mbox, criteria)
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) == 0 {
+		return nil, errors.New("jmap: appended message not found")
+	}
+
+	id := emailID(mbox.status.UidValidity, uids[0])
+	return map[string]interface{}{
+		"id":       id,
+		"blobId":   blobID(id),
+		"threadId": threadID(id),
+		"size":     len(body),
+	}, nil
+}
+
+func (a *account) emailImport(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID string  `json:"accountId"`
+		IfInState *string `json:"ifInState"`
+		Emails    map[string]struct {
+			BlobID     string          `json:"blobId"`
+			MailboxIDs map[string]bool `json:"mailboxIds"`
+			Keywords   map[string]bool `json:"keywords"`
+			ReceivedAt *time.Time      `json:"receivedAt"`
+		} `json:"emails"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	if len(args.Emails) > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+	oldState, err := a.emailState()
+	if err != nil {
+		return nil, err
+	}
+	if args.IfInState != nil && *args.IfInState != oldState {
+		return nil, errStateMismatch
+	}
+	resp := &setResponse{AccountID: a.id, OldState: oldState}
+
+	for _, cid := range sortedKeys(args.Emails) {
+		req := args.Emails[cid]
+		obj, err := func() (map[string]interface{}, error) {
+			mbox, err := a.singleMailbox(req.MailboxIDs)
+			if err != nil {
+				return nil, err
+			}
+			body, _, err := a.readBlob(a.resolveID(req.BlobID))
+			if err != nil {
+				return nil, err
+			}
+			if body == nil {
+				return nil, &setError{Type: "blobNotFound", Description: "blob does not exist"}
+			}
+			if _, err := message.Read(bytes.NewReader(body)); err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+				return nil, &setError{Type: "invalidEmail", Description: err.Error()}
+			}
+			receivedAt := time.Now()
+			if req.ReceivedAt != nil {
+				receivedAt = *req.ReceivedAt
+			}
+			return a.appendMessage(mbox, req.Keywords, receivedAt, body)
+		}()
+		if err != nil {
+			resp.notCreated(cid, a.serverFail(err))
+			continue
+		}
+		a.createdIDs[cid] = obj["id"].(string)
+		resp.created(cid, obj)
+	}
+
+	if resp.Created != nil {
+		a.changed()
+	}
+	resp.NewState, err = a.emailState()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"accountId":  resp.AccountID,
+		"oldState":   resp.OldState,
+		"newState":   resp.NewState,
+		"created":    resp.Created,
+		"notCreated": resp.NotCreated,
+	}, nil
+}
+
+// createEmail composes the message from the Email object (RFC 8621 Section
+// 4.6) and stores it.
+func (a *account) createEmail(props map[string]json.RawMessage) (map[string]interface{}, error) {
+	var (
+		mbox       *mailboxInfo
+		keywords   map[string]bool
+		receivedAt = time.Now()
+		err        error
+	)
+	if raw, ok := props["mailboxIds"]; ok {
+		mbox, err = a.decodeMailboxIDs(raw)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, invalidProperties("mailboxIds is required", "mailboxIds")
+	}
+	if raw, ok := props["keywords"]; ok {
+		keywords, err = decodeKeywords(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if raw, ok := props["receivedAt"]; ok {
+		if err := json.Unmarshal(raw, &receivedAt); err != nil {
+			return nil, invalidProperties(err.Error(), "receivedAt")
+		}
+	}
+
+	body, err := a.composeEmail(props)
+	if err != nil {
+		return nil, err
+	}
+	return a.appendMessage(mbox, keywords, receivedAt, body)
+}
+
+type emailAddress struct {
+	Name  *string `json:"name"`
+	Email string  `json:"email"`
+}
+
+func toMailAddresses(addrs []emailAddress) []*mail.Address {
+	list := make([]*mail.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		a := &mail.Address{Address: addr.Email}
+		if addr.Name != nil {
+			a.Name = *addr.Name
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// setHeaderValue sets the header field using the value in the specified
+// parsed form.
+func setHeaderValue(h *mail.Header, name, form string, raw json.RawMessage) error {
+	switch form {
+	case "asRaw":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		h.AddRaw([]byte(name + ":" + v + "\r\n"))
+	case "asText":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		h.Add(name, mimeEncode(v))
+	case "asAddresses":
+		var addrs []emailAddress
+		if err := json.Unmarshal(raw, &addrs); err != nil {
+			return err
+		}
+		tmp := mail.Header{}
+		tmp.SetAddressList(name, toMailAddresses(addrs))
+		h.Add(name, tmp.Get(name))
+	case "asMessageIds":
+		var ids []string
+		if err := json.Unmarshal(raw, &ids); err != nil {
+			return err
+		}
+		tmp := mail.Header{}
+		tmp.SetMsgIDList(name, ids)
+		h.Add(name, tmp.Get(name))
+	case "asDate":
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return err
+		}
+		h.Add(name, t.Format(time.RFC1123Z))
+	case "asURLs":
+		var urls []string
+		if err := json.Unmarshal(raw, &urls); err != nil {
+			return err
+		}
+		for i, u := range urls {
+			urls[i] = "<" + u + ">"
+		}
+		h.Add(name, strings.Join(urls, ", "))
+	default:
+		return fmt.Errorf("unsupported header form: %s", form)
+	}
+	return nil
+}
+
+func mimeEncode(s string) string {
+	tmp := message.Header{}
+	tmp.SetText("X", s)
+	return tmp.Get("X")
+}
+
+// contentHeaders are the header fields that should be set using body part
+// properties.
+var contentHeaders = map[string]bool{
+	"Content-Type": true, "Content-Transfer-Encoding": true, "Content-Disposition": true,
+	"Content-Id": true, "Content-Language": true, "Content-Location": true,
+}
+
+// applyHeaderProps adds header:* and headers properties to the header.
+func applyHeaderProps(h *mail.Header, props map[string]json.RawMessage, allowContent bool) (handled map[string]bool, err error) {
+	handled = map[string]bool{}
+	for prop, raw := range props {
+		if conv, ok := convenienceHeaders[prop]; ok {
+			name, form, _, _ := parseHeaderProperty(conv)
+			var null interface{}
+			if json.Unmarshal(raw, &null) == nil && null == nil {
+				handled[prop] = true
+				continue
+			}
+			if err := setHeaderValue(h, name, form, raw); err != nil {
+				return nil, invalidProperties(err.Error(), prop)
+			}
+			handled[prop] = true
+			continue
+		}
+		if prop == "headers" {
+			var fields []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			}
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return nil, invalidProperties(err.Error(), prop)
+			}
+			for _, f := range fields {
+				if !allowContent && contentHeaders[textprotoCanonical(f.Name)] {
+					return nil, invalidProperties("Content-* headers should be set on body parts", prop)
+				}
+				h.AddRaw([]byte(f.Name + ":" + f.Value + "\r\n"))
+			}
+			handled[prop] = true
+			continue
+		}
+		name, form, all, ok := parseHeaderProperty(prop)
+		if !ok {
+			continue
+		}
+		if !allowContent && contentHeaders[textprotoCanonical(name)] {
+			return nil, invalidProperties("Content-* headers should be set on body parts", prop)
+		}
+		if all {
+			var values []json.RawMessage
+			if err := json.Unmarshal(raw, &values); err != nil {
+				return nil, invalidProperties(err.Error(), prop)
+			}
+			for _, v := range values {
+				if err := setHeaderValue(h, name, form, v); err != nil {
+					return nil, invalidProperties(err.Error(), prop)
+				}
+			}
+		} else if err := setHeaderValue(h, name, form, raw); err != nil {
+			return nil, invalidProperties(err.Error(), prop)
+		}
+		handled[prop] = true
+	}
+	return handled, nil
+}
+
+func textprotoCanonical(name string) string {
+	h := message.Header{}
+	h.Set(name, "x")
+	fields := h.Fields()
+	fields.Next()
+	return fields.Key()
+}
+
+// composePart is the body part being composed.
+type composePart struct {
+	header   message.Header
+	body     []byte
+	subParts []*composePart
+}
+
+type partSpec struct {
+	PartID      *string           `json:"partId"`
+	BlobID      *string           `json:"blobId"`
+	Size        *int              `json:"size"`
+	Name        *string           `json:"name"`
+	Type        *string           `json:"type"`
+	Charset     *string           `json:"charset"`
+	Disposition *string           `json:"disposition"`
+	Cid         *string           `json:"cid"`
+	Language    []string          `json:"language"`
+	Location    *string           `json:"location"`
+	SubParts    []json.RawMessage `json:"subParts"`
+}
+
+type composer struct {
+	a          *account
+	bodyValues map[string]bodyValue
+}
+
+func (c *composer) part(raw json.RawMessage, prop string) (*composePart, error) {
+	var spec partSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, invalidProperties(err.Error(), prop)
+	}
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &props); err != nil {
+		return nil, invalidProperties(err.Error(), prop)
+	}
+
+	p := &composePart{}
+	var h mail.Header
+	if _, err := applyHeaderProps(&h, props, false); err != nil {
+		return nil, err
+	}
+	for prop := range props {
+		if _, _, _, ok := parseHeaderProperty(prop); ok || prop == "headers" {
+			continue
+		}
+		if !bodyPartProperties[prop] {
+			return nil, invalidProperties("unknown body part property", prop)
+		}
+	}
+	p.header = h.Header
+
+	mediaType := ""
+	if spec.Type != nil {
+		mediaType = strings.ToLower(*spec.Type)
+	}
+	params := map[string]string{}
+
+	switch {
+	case spec.SubParts != nil:
+		if mediaType == "" {
+			mediaType = "multipart/mixed"
+		}
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			return nil, invalidProperties("subParts require multipart type", prop)
+		}
+		for _, sub := range spec.SubParts {
+			subPart, err := c.part(sub, prop)
+			if err != nil {
+				return nil, err
+			}
+			p.subParts = append(p.subParts, subPart)
+		}
+	case spec.PartID != nil:
+		val, ok := c.bodyValues[*spec.PartID]
+		if !ok {
+			return nil, invalidProperties("partId does not reference bodyValues", prop)
+		}
+		if mediaType == "" {
+			mediaType = "text/plain"
+		}
+		if !strings.HasPrefix(mediaType, "text/") {
+			return nil, invalidProperties("partId can be used only for text parts", prop)
+		}
+		if spec.Charset != nil && !strings.EqualFold(*spec.Charset, "utf-8") {
+			return nil, invalidProperties("charset should not be set for text parts", prop)
+		}
+		params["charset"] = "utf-8"
+		p.body = []byte(val.Value)
+		p.header.Set("Content-Transfer-Encoding", "quoted-printable")
+	case spec.BlobID != nil:
+		data, blobType, err := c.a.readBlob(c.a.resolveID(*spec.BlobID))
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return nil, &setError{Type: "blobNotFound", Description: "blob does not exist", Properties: []string{prop}}
+		}
+		if mediaType == "" {
+			mediaType, _, _ = strings.Cut(blobType, ";")
+		}
+		if spec.Charset != nil {
+			params["charset"] = *spec.Charset
+		}
+		p.body = data
+		p.header.Set("Content-Transfer-Encoding", "base64")
+	default:
+		return nil, invalidProperties("body part should have partId, blobId or subParts", prop)
+	}
+
+	if spec.Name != nil && *spec.Name != "" {
+		params["name"] = *spec.Name
+	}
+	p.header.SetContentType(mediaType, params)
+	disposition := ""
+	if spec.Disposition != nil {
+		disposition = *spec.Disposition
+	}
+	if spec.Name != nil && *spec.Name != "" && disposition == "" {
+		disposition = "attachment"
+	}
+	if disposition != "" {
+		dispParams := map[string]string{}
+		if spec.Name != nil && *spec.Name != "" {
+			dispParams["filename"] = *spec.Name
+		}
+		p.header.SetContentDisposition(disposition, dispParams)
+	}
+	if spec.Cid != nil {
+		p.header.Set("Content-Id", "<"+*spec.Cid+">")
+	}
+	if len(spec.Language) != 0 {
+		p.header.Set("Content-Language", strings.Join(spec.Language, ", "))
+	}
+	if spec.Location != nil {
+		p.header.Set("Content-Location", *spec.Location)
+	}
+	return p, nil
+}
+
+func multipart(subType string, parts ...*composePart) *composePart {
+	p := &composePart{subParts: parts}
+	p.header.SetContentType("multipart/"+subType, map[string]string{})
+	return p
+}
+
+// bodyFromLists builds the body structure from textBody, htmlBody and
+// attachments (RFC 8621 Section 4.6).
+func (c *composer) bodyFromLists(props map[string]json.RawMessage) (*composePart, error) {
+	single := func(prop, mediaType string) (*composePart, error) {
+		raw, ok := props[prop]
+		if !ok {
+			return nil, nil
+		}
+		var parts []json.RawMessage
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return nil, invalidProperties(err.Error(), prop)
+		}
+		if len(parts) == 0 {
+			return nil, nil
+		}
+		if len(parts) != 1 {
+			return nil, invalidProperties("only one part is allowed", prop)
+		}
+		p, err := c.part(parts[0], prop)
+		if err != nil {
+			return nil, err
+		}
+		if ct, _, _ := p.header.ContentType(); ct != mediaType {
+			return nil, invalidProperties("part should have "+mediaType+" type", prop)
+		}
+		return p, nil
+	}
+
+	text, err := single("textBody", "text/plain")
+	if err != nil {
+		return nil, err
+	}
+	html, err := single("htmlBody", "text/html")
+	if err != nil {
+		return nil, err
+	}
+
+	var body *composePart
+	switch {
+	case text != nil && html != nil:
+		body = multipart("alternative", text, html)
+	case text != nil:
+		body = text
+	case html != nil:
+		body = html
+	default:
+		body = &composePart{}
+		body.header.SetContentType("text/plain", map[string]string{"charset": "utf-8"})
+	}
+
+	var inline, attached []*composePart
+	if raw, ok := props["attachments"]; ok {
+		var parts []json.RawMessage
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return nil, invalidProperties(err.Error(), "attachments")
+		}
+		for _, raw := range parts {
+			p, err := c.part(raw, "attachments")
+			if err != nil {
+				return nil, err
+			}
+			disp, _, _ := p.header.ContentDisposition()
+			if disp == "inline" && p.header.Get("Content-Id") != "" {
+				inline = append(inline, p)
+			} else {
+				attached = append(attached, p)
+			}
+		}
+	}
+	if len(inline) != 0 {
+		body = multipart("related", append([]*composePart{body}, inline...)...)
+	}
+	if len(attached) != 0 {
+		body = multipart("mixed", append([]*composePart{body}, attached...)...)
+	}
+	return body, nil
+}
+
+func writeComposePart(w *message.Writer, p *composePart) error {
+	if p.subParts == nil {
+		if _, err := w.Write(p.body); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+	for _, sub := range p.subParts {
+		pw, err := w.CreatePart(sub.header)
+		if err != nil {
+			return err
+		}
+		if err := writeComposePart(pw, sub); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+var emailCreateProperties = map[string]bool{
+	"mailboxIds": true, "keywords": true, "receivedAt": true, "bodyValues": true,
+	"bodyStructure": true, "textBody": true, "htmlBody": true, "attachments": true,
+}
+
+func (a *account) composeEmail(props map[string]json.RawMessage) ([]byte, error) {
+	var h mail.Header
+	handled, err := applyHeaderProps(&h, props, false)
+	if err != nil {
+		return nil, err
+	}
+	for prop := range props {
+		if !handled[prop] && !emailCreateProperties[prop] {
+			return nil, invalidProperties("unknown or server-set property", prop)
+		}
+	}
+
+	c := &composer{a: a}
+	if raw, ok := props["bodyValues"]; ok {
+		if err := json.Unmarshal(raw, &c.bodyValues); err != nil {
+			return nil, invalidProperties(err.Error(), "bodyValues")
+		}
+		for partID, val := range c.bodyValues {
+			if val.IsEncodingProblem || val.IsTruncated {
+				return nil, invalidProperties("bodyValues should not be truncated or have encoding problems", "bodyValues/"+partID)
+			}
+		}
+	}
+
+	var body *composePart
+	if raw, ok := props["bodyStructure"]; ok {
+		for _, prop := range []string{"textBody", "htmlBody", "attachments"} {
+			if _, ok := props[prop]; ok {
+				return nil, invalidProperties("bodyStructure can not be used with "+prop, "bodyStructure", prop)
+			}
+		}
+		body, err = c.part(raw, "bodyStructure")
+	} else {
+		body, err = c.bodyFromLists(props)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !h.Has("Date") {
+		h.SetDate(time.Now())
+	}
+	if !h.Has("Message-Id") {
+		if err := h.GenerateMessageIDWithHostname(a.endp.hostname); err != nil {
+			return nil, err
+		}
+	}
+	fields := body.header.Fields()
+	for fields.Next() {
+		h.Set(fields.Key(), fields.Value())
+	}
+
+	var buf bytes.Buffer
+	w, err := message.CreateWriter(&buf, h.Header)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeComposePart(w, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,407 @@
+package jmap
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdlog "log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	tls2 "github.com/dsoftgames/MailChat/framework/config/tls"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/limits"
+	"github.com/dsoftgames/MailChat/internal/listeners"
+	"github.com/dsoftgames/MailChat/internal/msgpipeline"
+	"github.com/dsoftgames/MailChat/internal/updatepipe"
+	_ "github.com/emersion/go-message/charset"
+)
+
+const modName = "jmap"
+
+const (
+	capCore       = "urn:ietf:params:jmap:core"
+	capMail       = "urn:ietf:params:jmap:mail"
+	capSubmission = "urn:ietf:params:jmap:submission"
+)
+
+// Endpoint implements JMAP (RFC 8620) with Mail and Submission
+// capabilities (RFC 8621) on top of the IMAP storage backend.
+//
+// Since the storage keeps each message in exactly one mailbox, each Email
+// belongs to exactly one Mailbox and is its own Thread. Moving the Email to
+// another Mailbox changes its id.
+type Endpoint struct {
+	addrs []string
+	log   log.Logger
+
+	saslAuth     auth.SASLAuth
+	store        module.Storage
+	tlsConfig    *tls.Config
+	insecureAuth bool
+	target       module.DeliveryTarget
+	limits       *limits.Group
+	hostname     string
+
+	maxUploadSize  int64
+	maxRequestSize int64
+	maxCalls       int
+	maxObjects     int
+	uploadDir      string
+	uploadTTL      time.Duration
+	stopCleanup    chan struct{}
+
+	storageNormalize authz.NormalizeFunc
+	storageMap       module.Table
+
+	push *pushHub
+
+	serv        http.Server
+	listenersWg sync.WaitGroup
+}
+
+func New(_ string, addrs []string) (module.Module, error) {
+	return &Endpoint{
+		addrs: addrs,
+		log:   log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+		saslAuth: auth.SASLAuth{
+			Log: log.Logger{Name: modName + "/sasl"},
+		},
+		push: newPushHub(),
+	}, nil
+}
+
+func (endp *Endpoint) Name() string {
+	return modName
+}
+
+func (endp *Endpoint) InstanceName() string {
+	return modName
+}
+
+func (endp *Endpoint) Init(cfg *config.Map) error {
+	cfg.Callback("auth", func(m *config.Map, node config.Node) error {
+		return endp.saslAuth.AddProvider(m, node)
+	})
+	cfg.Custom("storage", false, true, nil, modconfig.StorageDirective, &endp.store)
+	cfg.Custom("tls", true, false, nil, tls2.TLSDirective, &endp.tlsConfig)
+	cfg.Bool("insecure_auth", false, false, &endp.insecureAuth)
+	cfg.String("hostname", true, true, "", &endp.hostname)
+	cfg.Bool("debug", true, false, &endp.log.Debug)
+	cfg.DataSize("max_upload_size", false, false, 32*1024*1024, &endp.maxUploadSize)
+	cfg.DataSize("max_request_size", false, false, 10*1024*1024, &endp.maxRequestSize)
+	cfg.Int("max_calls_in_request", false, false, 32, &endp.maxCalls)
+	cfg.Int("max_objects", false, false, 500, &endp.maxObjects)
+	cfg.String("upload_dir", false, false, "jmap_uploads", &endp.uploadDir)
+	cfg.Duration("upload_ttl", false, false, 24*time.Hour, &endp.uploadTTL)
+	config.EnumMapped(cfg, "storage_map_normalize", false, false, authz.NormalizeFuncs, authz.NormalizeAuto,
+		&endp.storageNormalize)
+	modconfig.Table(cfg, "storage_map", false, false, nil, &endp.storageMap)
+	config.EnumMapped(cfg, "auth_map_normalize", true, false, authz.NormalizeFuncs, authz.NormalizeAuto,
+		&endp.saslAuth.AuthNormalize)
+	modconfig.Table(cfg, "auth_map", true, false, nil, &endp.saslAuth.AuthMap)
+	cfg.Custom("limits", false, false, func() (interface{}, error) {
+		return &limits.Group{}, nil
+	}, func(cfg *config.Map, n config.Node) (interface{}, error) {
+		var g *limits.Group
+		if err := modconfig.GroupFromNode("limits", n.Args, n, cfg.Globals, &g); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}, &endp.limits)
+
+	// Remaining directives configure the pipeline used for EmailSubmission,
+	// same as for the submission endpoint.
+	cfg.AllowUnknown()
+	unknown, err := cfg.Process()
+	if err != nil {
+		return err
+	}
+
+	endp.saslAuth.Log.Debug = endp.log.Debug
+	if endp.maxCalls <= 0 || endp.maxObjects <= 0 {
+		return fmt.Errorf("%s: max_calls_in_request and max_objects should be positive", modName)
+	}
+	if err := endp.initUploads(); err != nil {
+		return err
+	}
+
+	pipeline, err := msgpipeline.New(cfg.Globals, unknown)
+	if err != nil {
+		return fmt.Errorf("%s: %w", modName, err)
+	}
+	pipeline.Hostname = endp.hostname
+	pipeline.Log = log.Logger{Name: modName + "/pipeline", Debug: endp.log.Debug}
+	pipeline.FirstPipeline = true
+	endp.target = pipeline
+
+	if updBe, ok := endp.store.(updatepipe.Backend); ok {
+		if err := updBe.EnableUpdatePipe(updatepipe.ModeReplicate); err != nil {
+			endp.log.Error("failed to initialize updates pipe", err)
+		}
+	}
+	if notifier, ok := endp.store.(updatepipe.Notifier); ok {
+		notifier.NotifyUpdates(endp.push.update)
+	} else {
+		endp.log.Println("storage does not report updates, push notifications are limited")
+	}
+
+	endp.serv.Handler = endp.handler()
+	endp.serv.ErrorLog = stdlog.New(endp.log.DebugWriter(), "", 0)
+
+	for _, a := range endp.addrs {
+		addr, err := config.ParseEndpoint(a)
+		if err != nil {
+			return fmt.Errorf("%s: malformed endpoint: %v", modName, err)
+		}
+		l, err := listeners.Listen(addr.Network(), addr.Address())
+		if err != nil {
+			return fmt.Errorf("%s: %v", modName, err)
+		}
+		if addr.IsTLS() {
+			if endp.tlsConfig == nil {
+				return fmt.Errorf("%s: can't bind on TLS endpoint without TLS configuration", modName)
+			}
+			l = tls.NewListener(l, endp.tlsConfig)
+		}
+		endp.log.Printf("listening on %v", addr)
+
+		endp.listenersWg.Add(1)
+		go func() {
+			defer endp.listenersWg.Done()
+			if err := endp.serv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				endp.log.Error("serve failed", err, "endpoint", a)
+			}
+		}()
+	}
+
+	if endp.tlsConfig == nil {
+		endp.log.Println("TLS is disabled, this is insecure configuration and should be used only for testing!")
+	} else if endp.insecureAuth {
+		endp.log.Println("authentication over unencrypted connections is allowed, this is insecure configuration and should be used only for testing!")
+	}
+
+	return nil
+}
+
+func (endp *Endpoint) authAllowed(tlsActive bool) bool {
+	return tlsActive || endp.insecureAuth || endp.tlsConfig == nil
+}
+
+func (endp *Endpoint) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/jmap", endp.authenticated(endp.serveSession))
+	mux.HandleFunc("GET /jmap/session", endp.authenticated(endp.serveSession))
+	mux.HandleFunc("POST /jmap/api", endp.authenticated(endp.serveAPI))
+	mux.HandleFunc("GET /jmap/download/{accountId}/{blobId}/{name}", endp.authenticated(endp.serveDownload))
+	mux.HandleFunc("POST /jmap/upload/{accountId}/", endp.authenticated(endp.serveUpload))
+	mux.HandleFunc("GET /jmap/eventsource", endp.authenticated(endp.serveEventSource))
+	return mux
+}
+
+func (endp *Endpoint) Close() error {
+	endp.push.close()
+	if endp.stopCleanup != nil {
+		close(endp.stopCleanup)
+	}
+	if err := endp.serv.Close(); err != nil {
+		return err
+	}
+	endp.listenersWg.Wait()
+	return nil
+}
+
+func (endp *Endpoint) usernameForStorage(ctx context.Context, saslUsername string) (string, error) {
+	saslUsername, err := endp.storageNormalize(saslUsername)
+	if err != nil {
+		return "", err
+	}
+
+	if endp.storageMap == nil {
+		return saslUsername, nil
+	}
+
+	mapped, ok, err := endp.storageMap.Lookup(ctx, saslUsername)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", auth.ErrInvalidAuthCred
+	}
+
+	if saslUsername != mapped {
+		endp.log.DebugMsg("using mapped username for storage", "username", saslUsername, "mapped_username", mapped)
+	}
+
+	return mapped, nil
+}
+
+// accountID returns the JMAP id of the storage account. Account names may
+// contain characters not allowed in ids, so a hash is used.
+func accountID(accountName string) string {
+	sum := sha256.Sum256([]byte(accountName))
+	return "A" + hex.EncodeToString(sum[:10])
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, acct *account)
+
+// remoteAddr returns the client address of the request, nil if it can't be
+// parsed.
+func remoteAddr(r *http.Request) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return addr
+}
+
+// authenticated checks the HTTP Basic credentials and opens the storage
+// account for the request.
+func (endp *Endpoint) authenticated(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="JMAP", charset="UTF-8"`)
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		if !endp.authAllowed(r.TLS != nil) {
+			http.Error(w, "TLS is required for authentication", http.StatusForbidden)
+			return
+		}
+		if err := endp.saslAuth.AuthPlain(remoteAddr(r), username, password); err != nil {
+			endp.log.Error("authentication failed", err, "username", username, "src_ip", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Basic realm="JMAP", charset="UTF-8"`)
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+
+		accountName, err := endp.usernameForStorage(r.Context(), username)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidAuthCred) {
+				http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			endp.log.Error("failed to determine storage account name", err, "username", username)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		u, err := endp.store.GetOrCreateIMAPAcct(accountName)
+		if err != nil {
+			endp.log.Error("failed to open storage account", err, "username", accountName)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		defer u.Logout()
+
+		acct := newAccount(endp, r.Context(), username, accountName, u)
+		acct.conn = &module.ConnState{
+			Proto:        "HTTP",
+			AuthUser:     username,
+			AuthPassword: password,
+		}
+		if r.TLS != nil {
+			acct.conn.Proto = "HTTPS"
+			acct.conn.TLS = *r.TLS
+		}
+		if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+			acct.conn.RemoteAddr = addr
+		}
+		h(w, r, acct)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeProblem sends the request-level error (RFC 8620 Section 3.6.1).
+func writeProblem(w http.ResponseWriter, status int, typ, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":   typ,
+		"status": status,
+		"detail": detail,
+	})
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (endp *Endpoint) serveSession(w http.ResponseWriter, r *http.Request, acct *account) {
+	base := baseURL(r)
+	id := acct.id
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			capCore: map[string]interface{}{
+				"maxSizeUpload":         endp.maxUploadSize,
+				"maxConcurrentUpload":   4,
+				"maxSizeRequest":        endp.maxRequestSize,
+				"maxConcurrentRequests": 4,
+				"maxCallsInRequest":     endp.maxCalls,
+				"maxObjectsInGet":       endp.maxObjects,
+				"maxObjectsInSet":       endp.maxObjects,
+				"collationAlgorithms":   []string{"i;ascii-casemap"},
+			},
+			capMail:       map[string]interface{}{},
+			capSubmission: map[string]interface{}{},
+		},
+		"accounts": map[string]interface{}{
+			id: map[string]interface{}{
+				"name":       acct.name,
+				"isPersonal": true,
+				"isReadOnly": false,
+				"accountCapabilities": map[string]interface{}{
+					capMail: map[string]interface{}{
+						"maxMailboxesPerEmail":       1,
+						"maxMailboxDepth":            nil,
+						"maxSizeMailboxName":         255,
+						"maxSizeAttachmentsPerEmail": endp.maxUploadSize,
+						"emailQuerySortOptions":      emailSortProperties,
+						"mayCreateTopLevelMailbox":   true,
+					},
+					capSubmission: map[string]interface{}{
+						"maxDelayedSend":       0,
+						"submissionExtensions": map[string]interface{}{},
+					},
+				},
+			},
+		},
+		"primaryAccounts": map[string]interface{}{
+			capMail:       id,
+			capSubmission: id,
+		},
+		"username":       acct.username,
+		"apiUrl":         base + "/jmap/api",
+		"downloadUrl":    base + "/jmap/download/{accountId}/{blobId}/{name}?accept={type}",
+		"uploadUrl":      base + "/jmap/upload/{accountId}/",
+		"eventSourceUrl": base + "/jmap/eventsource?types={types}&closeafter={closeafter}&ping={ping}",
+		"state":          "0",
+	})
+}
+
+func init() {
+	module.RegisterEndpoint(modName, New)
+}
@@ -0,0 +1,538 @@
+//go:build !nosqlite3 && cgo
+// +build !nosqlite3,cgo
+
+package jmap
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/limits"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	imapbackend "github.com/emersion/go-imap/backend"
+	imapsql "github.com/foxcpp/go-imap-sql"
+)
+
+type mockAuth map[string]string
+
+func (a mockAuth) AuthPlain(username, password string) error {
+	if pass, ok := a[username]; ok && pass == password {
+		return nil
+	}
+	return auth.ErrInvalidAuthCred
+}
+
+// sqlStorage exposes go-imap-sql backend as module.Storage.
+type sqlStorage struct {
+	*imapsql.Backend
+}
+
+func (s sqlStorage) GetOrCreateIMAPAcct(username string) (imapbackend.User, error) {
+	return s.GetOrCreateUser(username)
+}
+
+func (s sqlStorage) GetIMAPAcct(username string) (imapbackend.User, error) {
+	return s.GetUser(username)
+}
+
+func (s sqlStorage) IMAPExtensions() []string {
+	return nil
+}
+
+func setupEndpoint(t *testing.T) (*Endpoint, *testutils.Target, *httptest.Server) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "messages"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := imapsql.New("sqlite3", filepath.Join(dir, "imapsql.db"), &imapsql.FSStore{Root: filepath.Join(dir, "messages")}, imapsql.Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tgt := &testutils.Target{}
+	endp := &Endpoint{
+		log: log.Logger{Name: modName, Debug: testing.Verbose()},
+		saslAuth: auth.SASLAuth{
+			Log:   log.Logger{Name: modName + "/sasl"},
+			Plain: []module.PlainAuth{mockAuth{"bob@example.org": "secret"}},
+		},
+		store:            module.Storage(sqlStorage{db}),
+		target:           tgt,
+		limits:           &limits.Group{},
+		hostname:         "mx.example.org",
+		maxUploadSize:    1024 * 1024,
+		maxRequestSize:   1024 * 1024,
+		maxCalls:         16,
+		maxObjects:       100,
+		uploadDir:        filepath.Join(dir, "uploads"),
+		storageNormalize: authz.NormalizeAuto,
+		push:             newPushHub(),
+	}
+	if err := endp.initUploads(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(endp.handler())
+	t.Cleanup(func() {
+		srv.Close()
+		endp.Close()
+	})
+	return endp, tgt, srv
+}
+
+type response struct {
+	name string
+	args map[string]interface{}
+}
+
+func call(t *testing.T, srv *httptest.Server, calls ...[]interface{}) []response {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"using":       []string{capCore, capMail, capSubmission},
+		"methodCalls": calls,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", srv.URL+"/jmap/api", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("bob@example.org", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("API request failed: %s: %s", resp.Status, b)
+	}
+
+	var apiResp struct {
+		MethodResponses [][]json.RawMessage `json:"methodResponses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		t.Fatal(err)
+	}
+	resps := make([]response, 0, len(apiResp.MethodResponses))
+	for _, r := range apiResp.MethodResponses {
+		var res response
+		if err := json.Unmarshal(r[0], &res.name); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(r[1], &res.args); err != nil {
+			t.Fatal(err)
+		}
+		if res.name == "error" {
+			t.Fatalf("method call failed: %v", res.args)
+		}
+		resps = append(resps, res)
+	}
+	return resps
+}
+
+func mailboxIDByRole(t *testing.T, srv *httptest.Server, role string) string {
+	t.Helper()
+	resps := call(t, srv, []interface{}{"Mailbox/query", map[string]interface{}{
+		"accountId": accountID("bob@example.org"),
+		"filter":    map[string]interface{}{"role": role},
+	}, "0"})
+	ids := resps[0].args["ids"].([]interface{})
+	if len(ids) != 1 {
+		t.Fatalf("expected one %s mailbox, got %v", role, ids)
+	}
+	return ids[0].(string)
+}
+
+func TestInsecureAuth(t *testing.T) {
+	endp, _, srv := setupEndpoint(t)
+	endp.tlsConfig = &tls.Config{}
+
+	check := func(url string, client *http.Client, expected int) {
+		t.Helper()
+		req, err := http.NewRequest("GET", url+"/jmap/session", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("bob@example.org", "secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Fatalf("expected %d, got %s", expected, resp.Status)
+		}
+	}
+
+	check(srv.URL, http.DefaultClient, http.StatusForbidden)
+
+	tlsSrv := httptest.NewTLSServer(endp.handler())
+	defer tlsSrv.Close()
+	check(tlsSrv.URL, tlsSrv.Client(), http.StatusOK)
+
+	endp.insecureAuth = true
+	check(srv.URL, http.DefaultClient, http.StatusOK)
+}
+
+func TestSession(t *testing.T) {
+	_, _, srv := setupEndpoint(t)
+
+	resp, err := http.Get(srv.URL + "/.well-known/jmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/.well-known/jmap", nil)
+	req.SetBasicAuth("bob@example.org", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var session struct {
+		Accounts        map[string]interface{} `json:"accounts"`
+		PrimaryAccounts map[string]string      `json:"primaryAccounts"`
+		APIURL          string                 `json:"apiUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		t.Fatal(err)
+	}
+	acctID := accountID("bob@example.org")
+	if _, ok := session.Accounts[acctID]; !ok || session.PrimaryAccounts[capMail] != acctID {
+		t.Fatalf("unexpected session: %+v", session)
+	}
+	if session.APIURL != srv.URL+"/jmap/api" {
+		t.Fatalf("unexpected apiUrl: %s", session.APIURL)
+	}
+}
+
+func TestEmails(t *testing.T) {
+	_, _, srv := setupEndpoint(t)
+	acctID := accountID("bob@example.org")
+	inbox := mailboxIDByRole(t, srv, "inbox")
+
+	resps := call(t, srv,
+		[]interface{}{"Mailbox/set", map[string]interface{}{
+			"accountId": acctID,
+			"create":    map[string]interface{}{"a": map[string]interface{}{"name": "Archive"}},
+		}, "0"},
+		[]interface{}{"Email/set", map[string]interface{}{
+			"accountId": acctID,
+			"create": map[string]interface{}{"m": map[string]interface{}{
+				"mailboxIds": map[string]bool{inbox: true},
+				"from":       []map[string]string{{"email": "alice@example.org"}},
+				"to":         []map[string]string{{"email": "bob@example.org"}},
+				"subject":    "Hello",
+				"bodyValues": map[string]interface{}{"1": map[string]string{"value": "Hi Bob!"}},
+				"textBody":   []map[string]string{{"partId": "1", "type": "text/plain"}},
+			}},
+		}, "1"},
+	)
+	archive := resps[0].args["created"].(map[string]interface{})["a"].(map[string]interface{})["id"].(string)
+	emailID := resps[1].args["created"].(map[string]interface{})["m"].(map[string]interface{})["id"].(string)
+
+	resps = call(t, srv,
+		[]interface{}{"Email/query", map[string]interface{}{
+			"accountId": acctID,
+			"filter":    map[string]interface{}{"inMailbox": inbox, "subject": "Hello"},
+		}, "0"},
+		[]interface{}{"Email/get", map[string]interface{}{
+			"accountId":           acctID,
+			"#ids":                map[string]string{"resultOf": "0", "name": "Email/query", "path": "/ids"},
+			"properties":          []string{"subject", "from", "keywords", "textBody", "bodyValues", "preview"},
+			"fetchTextBodyValues": true,
+		}, "1"},
+	)
+	ids := resps[0].args["ids"].([]interface{})
+	if len(ids) != 1 || ids[0] != emailID {
+		t.Fatalf("unexpected query result: %v", ids)
+	}
+	email := resps[1].args["list"].([]interface{})[0].(map[string]interface{})
+	if email["subject"] != "Hello" || email["preview"] != "Hi Bob!" {
+		t.Fatalf("unexpected email: %v", email)
+	}
+	from := email["from"].([]interface{})[0].(map[string]interface{})
+	if from["email"] != "alice@example.org" {
+		t.Fatalf("unexpected from: %v", from)
+	}
+	textBody := email["textBody"].([]interface{})[0].(map[string]interface{})
+	value := email["bodyValues"].(map[string]interface{})[textBody["partId"].(string)].(map[string]interface{})
+	if value["value"] != "Hi Bob!" {
+		t.Fatalf("unexpected body value: %v", value)
+	}
+
+	resps = call(t, srv,
+		[]interface{}{"Email/set", map[string]interface{}{
+			"accountId": acctID,
+			"update": map[string]interface{}{emailID: map[string]interface{}{
+				"keywords/$seen": true,
+				"mailboxIds":     map[string]bool{archive: true},
+			}},
+		}, "0"},
+		[]interface{}{"Email/query", map[string]interface{}{
+			"accountId": acctID,
+			"filter": map[string]interface{}{"operator": "AND", "conditions": []interface{}{
+				map[string]interface{}{"inMailbox": archive},
+				map[string]interface{}{"hasKeyword": "$seen"},
+			}},
+		}, "1"},
+		[]interface{}{"Email/query", map[string]interface{}{
+			"accountId": acctID,
+			"filter":    map[string]interface{}{"inMailbox": inbox},
+		}, "2"},
+	)
+	if updated, _ := resps[0].args["updated"].(map[string]interface{}); updated == nil {
+		t.Fatalf("email was not updated: %v", resps[0].args)
+	}
+	if ids := resps[1].args["ids"].([]interface{}); len(ids) != 1 {
+		t.Fatalf("expected the moved email in Archive, got %v", ids)
+	}
+	if ids := resps[2].args["ids"].([]interface{}); len(ids) != 0 {
+		t.Fatalf("expected empty INBOX, got %v", ids)
+	}
+
+	movedID := call(t, srv, []interface{}{"Email/query", map[string]interface{}{
+		"accountId": acctID,
+		"filter":    map[string]interface{}{"inMailbox": archive},
+	}, "0"})[0].args["ids"].([]interface{})[0].(string)
+	resps = call(t, srv,
+		[]interface{}{"Email/set", map[string]interface{}{
+			"accountId": acctID,
+			"destroy":   []string{movedID},
+		}, "0"},
+		[]interface{}{"Mailbox/set", map[string]interface{}{
+			"accountId": acctID,
+			"destroy":   []string{archive},
+		}, "1"},
+	)
+	if d := resps[0].args["destroyed"].([]interface{}); len(d) != 1 {
+		t.Fatalf("email was not destroyed: %v", resps[0].args)
+	}
+	if d, _ := resps[1].args["destroyed"].([]interface{}); len(d) != 1 {
+		t.Fatalf("mailbox was not destroyed: %v", resps[1].args)
+	}
+}
+
+func TestSubmission(t *testing.T) {
+	_, tgt, srv := setupEndpoint(t)
+	acctID := accountID("bob@example.org")
+	inbox := mailboxIDByRole(t, srv, "inbox")
+
+	create := func(from string) map[string]interface{} {
+		return map[string]interface{}{
+			"mailboxIds": map[string]bool{inbox: true},
+			"keywords":   map[string]bool{"$draft": true},
+			"from":       []map[string]string{{"email": from}},
+			"to":         []map[string]string{{"email": "alice@example.org"}},
+			"bcc":        []map[string]string{{"email": "carol@example.org"}},
+			"subject":    "Report",
+			"bodyValues": map[string]interface{}{"1": map[string]string{"value": "See attached."}},
+			"textBody":   []map[string]string{{"partId": "1", "type": "text/plain"}},
+		}
+	}
+	resps := call(t, srv,
+		[]interface{}{"Email/set", map[string]interface{}{
+			"accountId": acctID,
+			"create": map[string]interface{}{
+				"ok":     create("bob@example.org"),
+				"forged": create("mallory@example.org"),
+			},
+		}, "0"},
+		[]interface{}{"EmailSubmission/set", map[string]interface{}{
+			"accountId": acctID,
+			"create": map[string]interface{}{
+				"s1": map[string]string{"identityId": "I" + acctID[1:], "emailId": "#ok"},
+				"s2": map[string]string{"identityId": "I" + acctID[1:], "emailId": "#forged"},
+			},
+			"onSuccessUpdateEmail": map[string]interface{}{
+				"#s1": map[string]interface{}{"keywords/$draft": nil},
+			},
+		}, "1"},
+	)
+	if len(resps) != 3 || resps[2].name != "Email/set" {
+		t.Fatalf("expected implicit Email/set response, got %v", resps)
+	}
+	if _, ok := resps[1].args["created"].(map[string]interface{})["s1"]; !ok {
+		t.Fatalf("submission failed: %v", resps[1].args)
+	}
+	notCreated := resps[1].args["notCreated"].(map[string]interface{})
+	if notCreated["s2"].(map[string]interface{})["type"] != "forbiddenFrom" {
+		t.Fatalf("expected forbiddenFrom, got %v", notCreated)
+	}
+
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("expected one delivered message, got %d", len(tgt.Messages))
+	}
+	msg := tgt.Messages[0]
+	if msg.MailFrom != "bob@example.org" || len(msg.RcptTo) != 2 {
+		t.Fatalf("unexpected envelope: %s %v", msg.MailFrom, msg.RcptTo)
+	}
+	if msg.Header.Has("Bcc") {
+		t.Fatal("Bcc header should be removed")
+	}
+	if msg.MsgMeta.Conn == nil || msg.MsgMeta.Conn.AuthUser != "bob@example.org" {
+		t.Fatal("message should be submitted as the authenticated user")
+	}
+}
+
+func TestSubmission_UserLimits(t *testing.T) {
+	endp, tgt, srv := setupEndpoint(t)
+	endp.limits = &limits.Group{}
+	err := endp.limits.Init(config.NewMap(nil, config.Node{
+		Children: []config.Node{
+			{Name: "user", Args: []string{"messages", "1", "1h"}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acctID := accountID("bob@example.org")
+	inbox := mailboxIDByRole(t, srv, "inbox")
+
+	submit := func() map[string]interface{} {
+		resps := call(t, srv,
+			[]interface{}{"Email/set", map[string]interface{}{
+				"accountId": acctID,
+				"create": map[string]interface{}{
+					"m": map[string]interface{}{
+						"mailboxIds": map[string]bool{inbox: true},
+						"from":       []map[string]string{{"email": "bob@example.org"}},
+						"to":         []map[string]string{{"email": "alice@example.org"}},
+						"subject":    "Report",
+						"bodyValues": map[string]interface{}{"1": map[string]string{"value": "See attached."}},
+						"textBody":   []map[string]string{{"partId": "1", "type": "text/plain"}},
+					},
+				},
+			}, "0"},
+			[]interface{}{"EmailSubmission/set", map[string]interface{}{
+				"accountId": acctID,
+				"create": map[string]interface{}{
+					"s": map[string]string{"identityId": "I" + acctID[1:], "emailId": "#m"},
+				},
+			}, "1"},
+		)
+		return resps[1].args
+	}
+
+	if res := submit(); res["created"] == nil {
+		t.Fatalf("submission failed: %v", res)
+	}
+	res := submit()
+	notCreated, _ := res["notCreated"].(map[string]interface{})
+	if notCreated == nil || notCreated["s"].(map[string]interface{})["type"] != "forbiddenToSend" {
+		t.Fatalf("expected forbiddenToSend, got %v", res)
+	}
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("expected one delivered message, got %d", len(tgt.Messages))
+	}
+}
+
+func TestUploadImport(t *testing.T) {
+	_, _, srv := setupEndpoint(t)
+	acctID := accountID("bob@example.org")
+	inbox := mailboxIDByRole(t, srv, "inbox")
+
+	raw := strings.Join([]string{
+		"From: alice@example.org",
+		"To: bob@example.org",
+		"Subject: Photos",
+		"Content-Type: multipart/mixed; boundary=b1",
+		"",
+		"--b1",
+		"Content-Type: multipart/alternative; boundary=b2",
+		"",
+		"--b2",
+		"Content-Type: text/plain",
+		"",
+		"plain text",
+		"--b2",
+		"Content-Type: text/html",
+		"",
+		"<p>html text</p>",
+		"--b2--",
+		"--b1",
+		"Content-Type: image/png",
+		"Content-Disposition: attachment; filename=photo.png",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"iVBORw0KGgo=",
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	req, _ := http.NewRequest("POST", srv.URL+"/jmap/upload/"+acctID+"/", strings.NewReader(raw))
+	req.SetBasicAuth("bob@example.org", "secret")
+	req.Header.Set("Content-Type", "message/rfc822")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var upload struct {
+		BlobID string `json:"blobId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resps := call(t, srv,
+		[]interface{}{"Email/import", map[string]interface{}{
+			"accountId": acctID,
+			"emails": map[string]interface{}{"i": map[string]interface{}{
+				"blobId":     upload.BlobID,
+				"mailboxIds": map[string]bool{inbox: true},
+			}},
+		}, "0"},
+		[]interface{}{"Email/get", map[string]interface{}{
+			"accountId":  acctID,
+			"ids":        []string{"#i"},
+			"properties": []string{"textBody", "htmlBody", "attachments", "hasAttachment"},
+		}, "1"},
+	)
+	email := resps[1].args["list"].([]interface{})[0].(map[string]interface{})
+	if len(email["textBody"].([]interface{})) != 1 || len(email["htmlBody"].([]interface{})) != 1 {
+		t.Fatalf("unexpected body parts: %v", email)
+	}
+	attachments := email["attachments"].([]interface{})
+	if len(attachments) != 1 || email["hasAttachment"] != true {
+		t.Fatalf("unexpected attachments: %v", attachments)
+	}
+	att := attachments[0].(map[string]interface{})
+	if att["name"] != "photo.png" || att["type"] != "image/png" {
+		t.Fatalf("unexpected attachment: %v", att)
+	}
+
+	req, _ = http.NewRequest("GET", srv.URL+"/jmap/download/"+acctID+"/"+att["blobId"].(string)+"/photo.png", nil)
+	req.SetBasicAuth("bob@example.org", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "\x89PNG\r\n\x1a\n" {
+		t.Fatalf("unexpected download: %s %q", resp.Status, data)
+	}
+}
@@ -0,0 +1,737 @@
+package jmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+)
+
+var defaultMailboxProperties = []string{
+	"id", "name", "parentId", "role", "sortOrder", "totalEmails", "unreadEmails",
+	"totalThreads", "unreadThreads", "myRights", "isSubscribed",
+}
+
+var roleToAttr = map[string]string{}
+
+func init() {
+	for attr, role := range roleAttrs {
+		roleToAttr[role] = attr
+	}
+}
+
+// specialUseUser is implemented by storage accounts that can create
+// mailboxes with SPECIAL-USE attributes.
+type specialUseUser interface {
+	CreateMailboxSpecial(name, specialUseAttr string) error
+}
+
+func (a *account) delimiter() string {
+	mboxes, err := a.mailboxes()
+	if err == nil {
+		for _, mbox := range mboxes {
+			if mbox.delim != "" {
+				return mbox.delim
+			}
+		}
+	}
+	return "."
+}
+
+// parent returns the parent mailbox and the name relative to it.
+func (a *account) parent(mbox *mailboxInfo) (*mailboxInfo, string) {
+	if mbox.delim == "" {
+		return nil, mbox.name
+	}
+	idx := strings.LastIndex(mbox.name, mbox.delim)
+	if idx == -1 {
+		return nil, mbox.name
+	}
+	mboxes, _ := a.mailboxes()
+	for _, p := range mboxes {
+		if p.name == mbox.name[:idx] {
+			return p, mbox.name[idx+len(mbox.delim):]
+		}
+	}
+	return nil, mbox.name
+}
+
+func (a *account) mailboxObject(mbox *mailboxInfo, props []string) map[string]interface{} {
+	parent, name := a.parent(mbox)
+	isInbox := mbox.role == "inbox"
+
+	obj := make(map[string]interface{}, len(props)+1)
+	obj["id"] = mbox.id
+	for _, prop := range props {
+		switch prop {
+		case "name":
+			obj[prop] = name
+		case "parentId":
+			if parent != nil {
+				obj[prop] = parent.id
+			} else {
+				obj[prop] = nil
+			}
+		case "role":
+			obj[prop] = optString(mbox.role)
+		case "sortOrder":
+			obj[prop] = 0
+		case "totalEmails", "totalThreads":
+			obj[prop] = mbox.status.Messages
+		case "unreadEmails", "unreadThreads":
+			obj[prop] = mbox.status.Unseen
+		case "myRights":
+			obj[prop] = map[string]bool{
+				"mayReadItems":   true,
+				"mayAddItems":    true,
+				"mayRemoveItems": true,
+				"maySetSeen":     true,
+				"maySetKeywords": true,
+				"mayCreateChild": true,
+				"mayRename":      !isInbox,
+				"mayDelete":      !isInbox,
+				"maySubmit":      true,
+			}
+		case "isSubscribed":
+			obj[prop] = mbox.subscribed
+		}
+	}
+	return obj
+}
+
+func (a *account) mailboxGet(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID  string    `json:"accountId"`
+		IDs        *[]string `json:"ids"`
+		Properties *[]string `json:"properties"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	props := defaultMailboxProperties
+	if args.Properties != nil {
+		props = *args.Properties
+		known := map[string]bool{}
+		for _, p := range defaultMailboxProperties {
+			known[p] = true
+		}
+		for _, p := range props {
+			if !known[p] {
+				return nil, errInvalidArguments("unknown property: %s", p)
+			}
+		}
+	}
+	if args.IDs != nil && len(*args.IDs) > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+
+	state, err := a.mailboxState()
+	if err != nil {
+		return nil, err
+	}
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []interface{}{}
+	notFound := []string{}
+	if args.IDs == nil {
+		for _, mbox := range mboxes {
+			list = append(list, a.mailboxObject(mbox, props))
+		}
+	} else {
+		for _, id := range *args.IDs {
+			mbox, err := a.mailboxByID(a.resolveID(id))
+			if err != nil {
+				return nil, err
+			}
+			if mbox == nil {
+				notFound = append(notFound, id)
+				continue
+			}
+			list = append(list, a.mailboxObject(mbox, props))
+		}
+	}
+
+	return map[string]interface{}{
+		"accountId": a.id,
+		"state":     state,
+		"list":      list,
+		"notFound":  notFound,
+	}, nil
+}
+
+func (a *account) mailboxChanges(rawArgs json.RawMessage) (interface{}, error) {
+	resp, err := a.typeChanges(rawArgs, a.mailboxState)
+	if err != nil {
+		return nil, err
+	}
+	resp.(map[string]interface{})["updatedProperties"] = nil
+	return resp, nil
+}
+
+type mailboxFilterCondition struct {
+	ParentID     *json.RawMessage `json:"parentId"`
+	Name         *string          `json:"name"`
+	Role         *json.RawMessage `json:"role"`
+	HasAnyRole   *bool            `json:"hasAnyRole"`
+	IsSubscribed *bool            `json:"isSubscribed"`
+}
+
+// optionalID decodes the value that is either an id or null.
+func optionalID(raw json.RawMessage) (string, error) {
+	var id *string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return "", errUnsupportedFilter
+	}
+	if id == nil {
+		return "", nil
+	}
+	return *id, nil
+}
+
+func (a *account) matchMailbox(raw json.RawMessage, mbox *mailboxInfo) (bool, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return true, nil
+	}
+	if isOperator(raw) {
+		var op filterOperator
+		if err := json.Unmarshal(raw, &op); err != nil {
+			return false, errUnsupportedFilter
+		}
+		matches := 0
+		for _, cond := range op.Conditions {
+			ok, err := a.matchMailbox(cond, mbox)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matches++
+			}
+		}
+		switch op.Operator {
+		case "AND":
+			return matches == len(op.Conditions), nil
+		case "OR":
+			return matches != 0, nil
+		case "NOT":
+			return matches == 0, nil
+		}
+		return false, errUnsupportedFilter
+	}
+
+	var cond mailboxFilterCondition
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cond); err != nil {
+		return false, errUnsupportedFilter
+	}
+
+	parent, name := a.parent(mbox)
+	if cond.ParentID != nil {
+		parentID, err := optionalID(*cond.ParentID)
+		if err != nil {
+			return false, err
+		}
+		if (parent == nil && parentID != "") || (parent != nil && parent.id != parentID) {
+			return false, nil
+		}
+	}
+	if cond.Name != nil && !strings.Contains(strings.ToLower(name), strings.ToLower(*cond.Name)) {
+		return false, nil
+	}
+	if cond.Role != nil {
+		role, err := optionalID(*cond.Role)
+		if err != nil {
+			return false, err
+		}
+		if role != mbox.role {
+			return false, nil
+		}
+	}
+	if cond.HasAnyRole != nil && *cond.HasAnyRole != (mbox.role != "") {
+		return false, nil
+	}
+	if cond.IsSubscribed != nil && *cond.IsSubscribed != mbox.subscribed {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (a *account) mailboxQuery(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID      string          `json:"accountId"`
+		Filter         json.RawMessage `json:"filter"`
+		Sort           []comparator    `json:"sort"`
+		Position       int             `json:"position"`
+		Anchor         *string         `json:"anchor"`
+		AnchorOffset   int             `json:"anchorOffset"`
+		Limit          *int            `json:"limit"`
+		CalculateTotal bool            `json:"calculateTotal"`
+
+		SortAsTree   bool `json:"sortAsTree"`
+		FilterAsTree bool `json:"filterAsTree"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	for _, c := range args.Sort {
+		if c.Property != "name" && c.Property != "sortOrder" {
+			return nil, errUnsupportedSort
+		}
+	}
+	if args.Limit != nil && *args.Limit < 0 {
+		return nil, errInvalidArguments("limit should not be negative")
+	}
+
+	state, err := a.mailboxState()
+	if err != nil {
+		return nil, err
+	}
+	mboxes, err := a.mailboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*mailboxInfo
+	for _, mbox := range mboxes {
+		ok, err := a.matchMailbox(args.Filter, mbox)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, mbox)
+		}
+	}
+	// Mailboxes are already sorted by full name, which also sorts them as
+	// a tree. sortOrder is always 0.
+	for _, c := range args.Sort {
+		if c.Property == "name" && c.IsAscending != nil && !*c.IsAscending {
+			sort.SliceStable(matched, func(i, j int) bool {
+				return matched[i].name > matched[j].name
+			})
+		}
+	}
+
+	position := args.Position
+	if args.Anchor != nil {
+		position = -1
+		for i, mbox := range matched {
+			if mbox.id == *args.Anchor {
+				position = i + args.AnchorOffset
+				break
+			}
+		}
+		if position == -1 {
+			return nil, errAnchorNotFound
+		}
+	} else if position < 0 {
+		position += len(matched)
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > len(matched) {
+		position = len(matched)
+	}
+	end := len(matched)
+	if args.Limit != nil && position+*args.Limit < end {
+		end = position + *args.Limit
+	}
+
+	ids := make([]string, 0, end-position)
+	for _, mbox := range matched[position:end] {
+		ids = append(ids, mbox.id)
+	}
+	resp := map[string]interface{}{
+		"accountId":           a.id,
+		"queryState":          state,
+		"canCalculateChanges": false,
+		"position":            position,
+		"ids":                 ids,
+	}
+	if args.CalculateTotal {
+		resp["total"] = len(matched)
+	}
+	return resp, nil
+}
+
+// setResponse is the response of /set methods.
+type setResponse struct {
+	AccountID    string                 `json:"accountId"`
+	OldState     string                 `json:"oldState"`
+	NewState     string                 `json:"newState"`
+	Created      map[string]interface{} `json:"created"`
+	Updated      map[string]interface{} `json:"updated"`
+	Destroyed    []string               `json:"destroyed"`
+	NotCreated   map[string]*setError   `json:"notCreated"`
+	NotUpdated   map[string]*setError   `json:"notUpdated"`
+	NotDestroyed map[string]*setError   `json:"notDestroyed"`
+}
+
+func (resp *setResponse) created(cid string, obj interface{}) {
+	if resp.Created == nil {
+		resp.Created = map[string]interface{}{}
+	}
+	resp.Created[cid] = obj
+}
+
+func (resp *setResponse) notCreated(cid string, err *setError) {
+	if resp.NotCreated == nil {
+		resp.NotCreated = map[string]*setError{}
+	}
+	resp.NotCreated[cid] = err
+}
+
+func (resp *setResponse) updated(id string, obj interface{}) {
+	if resp.Updated == nil {
+		resp.Updated = map[string]interface{}{}
+	}
+	resp.Updated[id] = obj
+}
+
+func (resp *setResponse) notUpdated(id string, err *setError) {
+	if resp.NotUpdated == nil {
+		resp.NotUpdated = map[string]*setError{}
+	}
+	resp.NotUpdated[id] = err
+}
+
+func (resp *setResponse) destroyed(id string) {
+	resp.Destroyed = append(resp.Destroyed, id)
+}
+
+func (resp *setResponse) notDestroyed(id string, err *setError) {
+	if resp.NotDestroyed == nil {
+		resp.NotDestroyed = map[string]*setError{}
+	}
+	resp.NotDestroyed[id] = err
+}
+
+// serverFail converts the storage error into the per-object error.
+func (a *account) serverFail(err error) *setError {
+	if setErr, ok := err.(*setError); ok {
+		return setErr
+	}
+	a.endp.log.Error("storage operation failed", err, "username", a.name)
+	return &setError{Type: "serverFail"}
+}
+
+func (e *setError) Error() string {
+	return e.Type + ": " + e.Description
+}
+
+var (
+	errNotFound  = &setError{Type: "notFound"}
+	errForbidden = &setError{Type: "forbidden"}
+)
+
+type setArgs struct {
+	AccountID string                                `json:"accountId"`
+	IfInState *string                               `json:"ifInState"`
+	Create    map[string]map[string]json.RawMessage `json:"create"`
+	Update    map[string]map[string]json.RawMessage `json:"update"`
+	Destroy   []string                              `json:"destroy"`
+}
+
+func (args *setArgs) count() int {
+	return len(args.Create) + len(args.Update) + len(args.Destroy)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (a *account) validMailboxName(name string) *setError {
+	if name == "" || utf8.RuneCountInString(name) > 255 || strings.Contains(name, a.delimiter()) {
+		return invalidProperties("invalid mailbox name", "name")
+	}
+	return nil
+}
+
+func (a *account) mailboxSet(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		setArgs
+		OnDestroyRemoveEmails bool `json:"onDestroyRemoveEmails"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	if args.count() > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+
+	oldState, err := a.mailboxState()
+	if err != nil {
+		return nil, err
+	}
+	if args.IfInState != nil && *args.IfInState != oldState {
+		return nil, errStateMismatch
+	}
+	resp := &setResponse{AccountID: a.id, OldState: oldState}
+
+	for _, cid := range sortedKeys(args.Create) {
+		id, err := a.createMailbox(args.Create[cid])
+		if err != nil {
+			resp.notCreated(cid, a.serverFail(err))
+			continue
+		}
+		a.createdIDs[cid] = id
+		resp.created(cid, map[string]interface{}{
+			"id": id, "sortOrder": 0, "totalEmails": 0, "unreadEmails": 0,
+			"totalThreads": 0, "unreadThreads": 0,
+		})
+	}
+	for _, id := range sortedKeys(args.Update) {
+		if err := a.updateMailbox(a.resolveID(id), args.Update[id]); err != nil {
+			resp.notUpdated(id, a.serverFail(err))
+			continue
+		}
+		resp.updated(id, nil)
+	}
+	for _, id := range args.Destroy {
+		if err := a.destroyMailbox(a.resolveID(id), args.OnDestroyRemoveEmails); err != nil {
+			resp.notDestroyed(id, a.serverFail(err))
+			continue
+		}
+		resp.destroyed(id)
+	}
+
+	if resp.Created != nil || resp.Updated != nil || resp.Destroyed != nil {
+		a.changed()
+	}
+	resp.NewState, err = a.mailboxState()
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// mailboxPath returns the full name of the mailbox with the specified
+// parent.
+func (a *account) mailboxPath(rawParentID json.RawMessage, name string) (string, error) {
+	if rawParentID == nil {
+		return name, nil
+	}
+	parentID, err := optionalID(rawParentID)
+	if err != nil {
+		return "", invalidProperties("invalid parentId", "parentId")
+	}
+	if parentID == "" {
+		return name, nil
+	}
+	parent, err := a.mailboxByID(a.resolveID(parentID))
+	if err != nil {
+		return "", err
+	}
+	if parent == nil {
+		return "", invalidProperties("parent mailbox does not exist", "parentId")
+	}
+	return parent.name + a.delimiter() + name, nil
+}
+
+func (a *account) createMailbox(props map[string]json.RawMessage) (string, error) {
+	var (
+		name       string
+		role       *string
+		subscribed bool
+	)
+	for prop, val := range props {
+		var err error
+		switch prop {
+		case "name":
+			err = json.Unmarshal(val, &name)
+		case "role":
+			err = json.Unmarshal(val, &role)
+		case "isSubscribed":
+			err = json.Unmarshal(val, &subscribed)
+		case "parentId":
+		case "sortOrder":
+			var order int
+			if err = json.Unmarshal(val, &order); err == nil && order != 0 {
+				return "", invalidProperties("sortOrder is not supported", prop)
+			}
+		default:
+			return "", invalidProperties("unknown or server-set property", prop)
+		}
+		if err != nil {
+			return "", invalidProperties(err.Error(), prop)
+		}
+	}
+	if setErr := a.validMailboxName(name); setErr != nil {
+		return "", setErr
+	}
+	fullName, err := a.mailboxPath(props["parentId"], name)
+	if err != nil {
+		return "", err
+	}
+
+	if role != nil && *role != "" {
+		attr, ok := roleToAttr[*role]
+		if !ok {
+			return "", invalidProperties("unsupported role", "role")
+		}
+		existing, err := a.mailboxByRole(*role)
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			return "", invalidProperties("role is already assigned to another mailbox", "role")
+		}
+		suu, ok := a.user.(specialUseUser)
+		if !ok {
+			return "", invalidProperties("storage does not support mailbox roles", "role")
+		}
+		err = suu.CreateMailboxSpecial(fullName, attr)
+		if err != nil {
+			return "", a.mailboxError(err)
+		}
+	} else if err := a.user.CreateMailbox(fullName); err != nil {
+		return "", a.mailboxError(err)
+	}
+	if subscribed {
+		if err := a.user.SetSubscribed(fullName, true); err != nil {
+			return "", err
+		}
+	}
+
+	a.invalidateMailboxes()
+	status, err := a.user.Status(fullName, []imap.StatusItem{imap.StatusUidValidity})
+	if err != nil {
+		return "", err
+	}
+	return mailboxID(status.UidValidity), nil
+}
+
+func (a *account) mailboxError(err error) error {
+	if errors.Is(err, backend.ErrMailboxAlreadyExists) {
+		return invalidProperties("mailbox with the same name already exists", "name")
+	}
+	return err
+}
+
+func (a *account) updateMailbox(id string, patch map[string]json.RawMessage) error {
+	mbox, err := a.mailboxByID(id)
+	if err != nil {
+		return err
+	}
+	if mbox == nil {
+		return errNotFound
+	}
+	parent, name := a.parent(mbox)
+
+	rename := false
+	var rawParentID json.RawMessage
+	if parent != nil {
+		rawParentID, _ = json.Marshal(parent.id)
+	}
+	for prop, val := range patch {
+		var err error
+		switch prop {
+		case "name":
+			var newName string
+			err = json.Unmarshal(val, &newName)
+			if err == nil && newName != name {
+				name = newName
+				rename = true
+			}
+		case "parentId":
+			rawParentID = val
+			rename = true
+		case "isSubscribed":
+			var subscribed bool
+			if err = json.Unmarshal(val, &subscribed); err == nil && subscribed != mbox.subscribed {
+				err = a.user.SetSubscribed(mbox.name, subscribed)
+			}
+		case "role":
+			var role *string
+			if err = json.Unmarshal(val, &role); err == nil && (role == nil && mbox.role != "" || role != nil && *role != mbox.role) {
+				return invalidProperties("role can not be changed", prop)
+			}
+		case "sortOrder":
+			var order int
+			if err = json.Unmarshal(val, &order); err == nil && order != 0 {
+				return invalidProperties("sortOrder is not supported", prop)
+			}
+		default:
+			return invalidProperties("unknown or server-set property", prop)
+		}
+		if err != nil {
+			if _, ok := err.(*setError); ok {
+				return err
+			}
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				return invalidProperties(err.Error(), prop)
+			}
+			return err
+		}
+	}
+
+	if !rename {
+		return nil
+	}
+	if mbox.role == "inbox" {
+		return errForbidden
+	}
+	if setErr := a.validMailboxName(name); setErr != nil {
+		return setErr
+	}
+	newPath, err := a.mailboxPath(rawParentID, name)
+	if err != nil {
+		return err
+	}
+	if newPath == mbox.name {
+		return nil
+	}
+	if strings.HasPrefix(newPath, mbox.name+a.delimiter()) {
+		return invalidProperties("mailbox can not be moved into its child", "parentId")
+	}
+	if err := a.user.RenameMailbox(mbox.name, newPath); err != nil {
+		return a.mailboxError(err)
+	}
+	a.invalidateMailboxes()
+	return nil
+}
+
+func (a *account) destroyMailbox(id string, removeEmails bool) error {
+	mbox, err := a.mailboxByID(id)
+	if err != nil {
+		return err
+	}
+	if mbox == nil {
+		return errNotFound
+	}
+	if mbox.role == "inbox" {
+		return errForbidden
+	}
+
+	infos, err := a.user.ListMailboxes(false)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name, mbox.name+a.delimiter()) {
+			return &setError{Type: "mailboxHasChild"}
+		}
+	}
+	if mbox.status.Messages != 0 && !removeEmails {
+		return &setError{Type: "mailboxHasEmail"}
+	}
+
+	if err := a.user.DeleteMailbox(mbox.name); err != nil {
+		return err
+	}
+	a.invalidateMailboxes()
+	return nil
+}
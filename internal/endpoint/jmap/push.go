@@ -0,0 +1,208 @@
+package jmap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mess "github.com/foxcpp/go-imap-mess"
+)
+
+// pushHub tracks changes of accounts and wakes up EventSource connections
+// when changes happen.
+type pushHub struct {
+	lock sync.Mutex
+	// nonce makes states from different server runs different since
+	// counters are not persisted.
+	nonce    string
+	versions map[string]uint64
+	subs     map[string]map[chan struct{}]struct{}
+	closed   chan struct{}
+}
+
+func newPushHub() *pushHub {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return &pushHub{
+		nonce:    hex.EncodeToString(nonce),
+		versions: map[string]uint64{},
+		subs:     map[string]map[chan struct{}]struct{}{},
+		closed:   make(chan struct{}),
+	}
+}
+
+// update is called by the storage for each update.
+func (h *pushHub) update(username string, _ mess.Update) {
+	h.bump(username)
+}
+
+func (h *pushHub) bump(username string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.versions[username]++
+	for ch := range h.subs[username] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *pushHub) version(username string) string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.nonce + "." + strconv.FormatUint(h.versions[username], 10)
+}
+
+func (h *pushHub) subscribe(username string) chan struct{} {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	ch := make(chan struct{}, 1)
+	if h.subs[username] == nil {
+		h.subs[username] = map[chan struct{}]struct{}{}
+	}
+	h.subs[username][ch] = struct{}{}
+	return ch
+}
+
+func (h *pushHub) unsubscribe(username string, ch chan struct{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.subs[username], ch)
+	if len(h.subs[username]) == 0 {
+		delete(h.subs, username)
+	}
+}
+
+func (h *pushHub) close() {
+	close(h.closed)
+}
+
+// pushTypes are the data types reported in StateChange objects.
+var pushTypes = []string{"Mailbox", "Email", "Thread"}
+
+func (a *account) states() (map[string]string, error) {
+	a.invalidateMailboxes()
+	mboxState, err := a.mailboxState()
+	if err != nil {
+		return nil, err
+	}
+	emailState, err := a.emailState()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"Mailbox": mboxState,
+		"Email":   emailState,
+		"Thread":  emailState,
+	}, nil
+}
+
+// serveEventSource implements the push using event-source (RFC 8620
+// Section 7.3).
+func (endp *Endpoint) serveEventSource(w http.ResponseWriter, r *http.Request, acct *account) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	types := map[string]bool{}
+	typesParam := r.URL.Query().Get("types")
+	for _, t := range pushTypes {
+		types[t] = typesParam == "" || typesParam == "*"
+	}
+	if typesParam != "" && typesParam != "*" {
+		for _, t := range strings.Split(typesParam, ",") {
+			if _, ok := types[t]; ok {
+				types[t] = true
+			}
+		}
+	}
+	closeAfterState := r.URL.Query().Get("closeafter") == "state"
+	var ping time.Duration
+	if p := r.URL.Query().Get("ping"); p != "" {
+		secs, err := strconv.Atoi(p)
+		if err != nil || secs < 0 {
+			http.Error(w, "Invalid ping value", http.StatusBadRequest)
+			return
+		}
+		// Don't let clients request excessively frequent pings.
+		if secs != 0 && secs < 10 {
+			secs = 10
+		}
+		ping = time.Duration(secs) * time.Second
+	}
+
+	ch := endp.push.subscribe(acct.name)
+	defer endp.push.unsubscribe(acct.name, ch)
+
+	last, err := acct.states()
+	if err != nil {
+		endp.log.Error("failed to compute state", err, "username", acct.name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var pingC <-chan time.Time
+	if ping != 0 {
+		t := time.NewTicker(ping)
+		defer t.Stop()
+		pingC = t.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-endp.push.closed:
+			return
+		case <-pingC:
+			fmt.Fprintf(w, "event: ping\ndata: {\"interval\":%d}\n\n", int(ping.Seconds()))
+			flusher.Flush()
+		case <-ch:
+			current, err := acct.states()
+			if err != nil {
+				endp.log.Error("failed to compute state", err, "username", acct.name)
+				return
+			}
+			changed := map[string]string{}
+			for _, t := range pushTypes {
+				if types[t] && current[t] != last[t] {
+					changed[t] = current[t]
+				}
+			}
+			last = current
+			if len(changed) == 0 {
+				continue
+			}
+
+			data, err := json.Marshal(map[string]interface{}{
+				"@type":   "StateChange",
+				"changed": map[string]interface{}{acct.id: changed},
+			})
+			if err != nil {
+				endp.log.Error("failed to serialize state change", err)
+				return
+			}
+			fmt.Fprintf(w, "event: state\ndata: %s\n\n", data)
+			flusher.Flush()
+			if closeAfterState {
+				return
+			}
+		}
+	}
+}
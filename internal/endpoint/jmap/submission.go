@@ -0,0 +1,329 @@
+package jmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+)
+
+// identityEmail returns the address the account is allowed to send from.
+func (a *account) identityEmail() string {
+	if strings.Contains(a.username, "@") {
+		return a.username
+	}
+	return a.name
+}
+
+func (a *account) identityID() string {
+	return "I" + a.id[1:]
+}
+
+func (a *account) identityGet(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID  string    `json:"accountId"`
+		IDs        *[]string `json:"ids"`
+		Properties *[]string `json:"properties"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+
+	identity := map[string]interface{}{
+		"id":            a.identityID(),
+		"name":          "",
+		"email":         a.identityEmail(),
+		"replyTo":       nil,
+		"bcc":           nil,
+		"textSignature": "",
+		"htmlSignature": "",
+		"mayDelete":     false,
+	}
+	if args.Properties != nil {
+		identity = filterProperties(identity, *args.Properties)
+	}
+
+	list := []interface{}{}
+	notFound := []string{}
+	if args.IDs == nil {
+		list = append(list, identity)
+	} else {
+		for _, id := range *args.IDs {
+			if id == a.identityID() {
+				list = append(list, identity)
+			} else {
+				notFound = append(notFound, id)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"accountId": a.id,
+		"state":     "0",
+		"list":      list,
+		"notFound":  notFound,
+	}, nil
+}
+
+// EmailSubmission objects are not stored after the message is handed to
+// the pipeline, so there is nothing to return.
+func (a *account) submissionGet(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		AccountID  string    `json:"accountId"`
+		IDs        *[]string `json:"ids"`
+		Properties *[]string `json:"properties"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	notFound := []string{}
+	if args.IDs != nil {
+		notFound = append(notFound, *args.IDs...)
+	}
+	return map[string]interface{}{
+		"accountId": a.id,
+		"state":     "0",
+		"list":      []interface{}{},
+		"notFound":  notFound,
+	}, nil
+}
+
+func (a *account) submissionChanges(rawArgs json.RawMessage) (interface{}, error) {
+	return a.typeChanges(rawArgs, func() (string, error) { return "0", nil })
+}
+
+type submissionEnvelope struct {
+	MailFrom struct {
+		Email string `json:"email"`
+	} `json:"mailFrom"`
+	RcptTo []struct {
+		Email string `json:"email"`
+	} `json:"rcptTo"`
+}
+
+func (a *account) submissionSet(rawArgs json.RawMessage) (interface{}, error) {
+	var args struct {
+		setArgs
+		OnSuccessUpdateEmail  map[string]map[string]json.RawMessage `json:"onSuccessUpdateEmail"`
+		OnSuccessDestroyEmail []string                              `json:"onSuccessDestroyEmail"`
+	}
+	if err := decodeArgs(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	if args.count() > a.endp.maxObjects {
+		return nil, errRequestTooLarge
+	}
+	if args.IfInState != nil && *args.IfInState != "0" {
+		return nil, errStateMismatch
+	}
+	resp := &setResponse{AccountID: a.id, OldState: "0", NewState: "0"}
+
+	// Submission id or creation reference -> submitted Email id.
+	submitted := map[string]string{}
+	for _, cid := range sortedKeys(args.Create) {
+		sub, emailID, err := a.submit(args.Create[cid])
+		if err != nil {
+			resp.notCreated(cid, a.serverFail(err))
+			continue
+		}
+		id := sub["id"].(string)
+		a.createdIDs[cid] = id
+		submitted["#"+cid] = emailID
+		submitted[id] = emailID
+		resp.created(cid, sub)
+	}
+	for _, id := range sortedKeys(args.Update) {
+		resp.notUpdated(id, errNotFound)
+	}
+	for _, id := range args.Destroy {
+		resp.notDestroyed(id, errNotFound)
+	}
+
+	emailArgs := &setArgs{Update: map[string]map[string]json.RawMessage{}}
+	for ref, patch := range args.OnSuccessUpdateEmail {
+		if emailID, ok := submitted[ref]; ok {
+			emailArgs.Update[emailID] = patch
+		}
+	}
+	for _, ref := range args.OnSuccessDestroyEmail {
+		if emailID, ok := submitted[ref]; ok {
+			emailArgs.Destroy = append(emailArgs.Destroy, emailID)
+		}
+	}
+	if len(emailArgs.Update) != 0 || len(emailArgs.Destroy) != 0 {
+		emailResp, err := a.setEmails(emailArgs)
+		if err != nil {
+			return nil, err
+		}
+		a.implicit = append(a.implicit, invocation{Name: "Email/set", Args: emailResp})
+	}
+
+	return resp, nil
+}
+
+func parseAddressList(h mail.Header, key string) []string {
+	addrs, err := h.AddressList(key)
+	if err != nil {
+		return nil
+	}
+	list := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		list = append(list, addr.Address)
+	}
+	return list
+}
+
+// submit sends the Email through the submission pipeline.
+func (a *account) submit(props map[string]json.RawMessage) (map[string]interface{}, string, error) {
+	var req struct {
+		IdentityID string              `json:"identityId"`
+		EmailID    string              `json:"emailId"`
+		Envelope   *submissionEnvelope `json:"envelope"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(mustMarshal(props)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, "", invalidProperties(err.Error())
+	}
+	if req.IdentityID != a.identityID() {
+		return nil, "", invalidProperties("identity does not exist", "identityId")
+	}
+	emailID := a.resolveID(req.EmailID)
+
+	mbox, uid, err := a.findEmail(emailID)
+	if err != nil {
+		return nil, "", err
+	}
+	if mbox == nil {
+		return nil, "", invalidProperties("email does not exist", "emailId")
+	}
+	body, err := a.readMessage(mbox, uid)
+	if err != nil {
+		return nil, "", err
+	}
+	if body == nil {
+		return nil, "", invalidProperties("email does not exist", "emailId")
+	}
+
+	br := bufio.NewReader(bytes.NewReader(body))
+	hdr, err := textproto.ReadHeader(br)
+	if err != nil {
+		return nil, "", &setError{Type: "invalidEmail", Description: err.Error()}
+	}
+	msgBody, err := io.ReadAll(br)
+	if err != nil {
+		return nil, "", err
+	}
+	mailHdr := mail.Header{}
+	mailHdr.Header.Header = hdr
+
+	identity := a.identityEmail()
+	for _, from := range parseAddressList(mailHdr, "From") {
+		if !strings.EqualFold(from, identity) {
+			return nil, "", &setError{Type: "forbiddenFrom", Description: "From address is not allowed for the identity"}
+		}
+	}
+
+	var (
+		mailFrom string
+		rcpts    []string
+	)
+	if req.Envelope != nil {
+		mailFrom = req.Envelope.MailFrom.Email
+		for _, rcpt := range req.Envelope.RcptTo {
+			rcpts = append(rcpts, rcpt.Email)
+		}
+	} else {
+		if sender := parseAddressList(mailHdr, "Sender"); len(sender) != 0 {
+			mailFrom = sender[0]
+		} else if from := parseAddressList(mailHdr, "From"); len(from) != 0 {
+			mailFrom = from[0]
+		}
+		seen := map[string]bool{}
+		for _, key := range []string{"To", "Cc", "Bcc"} {
+			for _, rcpt := range parseAddressList(mailHdr, key) {
+				if !seen[strings.ToLower(rcpt)] {
+					seen[strings.ToLower(rcpt)] = true
+					rcpts = append(rcpts, rcpt)
+				}
+			}
+		}
+	}
+	if !strings.EqualFold(mailFrom, identity) {
+		return nil, "", &setError{Type: "forbiddenMailFrom", Description: "envelope sender is not allowed for the identity"}
+	}
+	if len(rcpts) == 0 {
+		return nil, "", &setError{Type: "noRecipients"}
+	}
+	hdr.Del("Bcc")
+
+	msgID, err := module.GenerateMsgID()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := a.deliver(msgID, mailFrom, rcpts, hdr, msgBody); err != nil {
+		var smtpErr *exterrors.SMTPError
+		if errors.As(err, &smtpErr) {
+			a.endp.log.Msg("submission rejected", "msg_id", msgID, "username", a.username, "reason", smtpErr.Message)
+			return nil, "", &setError{Type: "forbiddenToSend", Description: smtpErr.Message}
+		}
+		return nil, "", err
+	}
+	a.endp.log.Msg("message submitted", "msg_id", msgID, "username", a.username, "sender", mailFrom)
+
+	return map[string]interface{}{
+		"id":         "S" + msgID,
+		"threadId":   threadID(emailID),
+		"sendAt":     time.Now().UTC().Format(time.RFC3339),
+		"undoStatus": "final",
+	}, emailID, nil
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (a *account) deliver(msgID, mailFrom string, rcpts []string, hdr textproto.Header, body []byte) error {
+	msgMeta := &module.MsgMetadata{
+		ID:       msgID,
+		SMTPOpts: smtp.MailOptions{},
+		Conn:     a.conn,
+	}
+	// Submissions are subject to the same per-user limits as messages sent
+	// over the submission endpoint.
+	if err := a.endp.limits.TakeUserMsg(a.ctx, a.username); err != nil {
+		return err
+	}
+	delivery, err := a.endp.target.Start(a.ctx, msgMeta, mailFrom)
+	if err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := a.endp.limits.TakeUserRcpt(a.ctx, a.username, rcpt); err != nil {
+			_ = delivery.Abort(a.ctx)
+			return err
+		}
+		if err := delivery.AddRcpt(a.ctx, rcpt, smtp.RcptOptions{}); err != nil {
+			_ = delivery.Abort(a.ctx)
+			return err
+		}
+	}
+	if err := delivery.Body(a.ctx, hdr, buffer.MemoryBuffer{Slice: body}); err != nil {
+		_ = delivery.Abort(a.ctx)
+		return err
+	}
+	return delivery.Commit(a.ctx)
+}
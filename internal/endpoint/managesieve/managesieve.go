@@ -0,0 +1,257 @@
+package managesieve
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	tls2 "github.com/dsoftgames/MailChat/framework/config/tls"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/listeners"
+	"github.com/dsoftgames/MailChat/internal/proxy_protocol"
+	"github.com/dsoftgames/MailChat/internal/sieve"
+)
+
+const modName = "managesieve"
+
+// Endpoint implements the ManageSieve protocol (RFC 5804) used by mail
+// clients to manage Sieve scripts executed by imap.filter.sieve.
+//
+// Scripts are kept in the same storage as used by imap.filter.sieve
+// (scripts_dir or scripts_table directives), both modules should be
+// configured with the same values.
+type Endpoint struct {
+	addrs         []string
+	log           log.Logger
+	saslAuth      auth.SASLAuth
+	tlsConfig     *tls.Config
+	proxyProtocol *proxy_protocol.ProxyProtocol
+	insecureAuth  bool
+	idleTimeout   time.Duration
+
+	store         sieve.Storage
+	maxScripts    int
+	maxScriptSize int64
+
+	storageNormalize authz.NormalizeFunc
+	storageMap       module.Table
+
+	listeners   []net.Listener
+	listenersWg sync.WaitGroup
+
+	connsLock sync.Mutex
+	conns     map[net.Conn]struct{}
+}
+
+func New(_ string, addrs []string) (module.Module, error) {
+	return &Endpoint{
+		addrs: addrs,
+		log:   log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+		saslAuth: auth.SASLAuth{
+			Log: log.Logger{Name: modName + "/sasl"},
+		},
+		conns: map[net.Conn]struct{}{},
+	}, nil
+}
+
+func (endp *Endpoint) Name() string {
+	return modName
+}
+
+func (endp *Endpoint) InstanceName() string {
+	return modName
+}
+
+func (endp *Endpoint) Init(cfg *config.Map) error {
+	var (
+		scriptsDir   string
+		scriptsTable module.Table
+	)
+
+	cfg.Callback("auth", func(m *config.Map, node config.Node) error {
+		return endp.saslAuth.AddProvider(m, node)
+	})
+	cfg.Bool("sasl_login", false, false, &endp.saslAuth.EnableLogin)
+	cfg.Custom("tls", true, true, nil, tls2.TLSDirective, &endp.tlsConfig)
+	cfg.Custom("proxy_protocol", false, false, nil, proxy_protocol.ProxyProtocolDirective, &endp.proxyProtocol)
+	cfg.Bool("insecure_auth", false, false, &endp.insecureAuth)
+	cfg.Bool("debug", true, false, &endp.log.Debug)
+	cfg.Duration("idle_timeout", false, false, 30*time.Minute, &endp.idleTimeout)
+	cfg.String("scripts_dir", false, false, "", &scriptsDir)
+	modconfig.Table(cfg, "scripts_table", false, false, nil, &scriptsTable)
+	cfg.Int("max_scripts", false, false, 16, &endp.maxScripts)
+	cfg.DataSize("max_script_size", false, false, 64*1024, &endp.maxScriptSize)
+	config.EnumMapped(cfg, "storage_map_normalize", false, false, authz.NormalizeFuncs, authz.NormalizeAuto,
+		&endp.storageNormalize)
+	modconfig.Table(cfg, "storage_map", false, false, nil, &endp.storageMap)
+	config.EnumMapped(cfg, "auth_map_normalize", true, false, authz.NormalizeFuncs, authz.NormalizeAuto,
+		&endp.saslAuth.AuthNormalize)
+	modconfig.Table(cfg, "auth_map", true, false, nil, &endp.saslAuth.AuthMap)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	endp.saslAuth.Log.Debug = endp.log.Debug
+	if endp.maxScripts <= 0 {
+		return fmt.Errorf("%s: max_scripts should be positive", modName)
+	}
+	if endp.maxScriptSize <= 0 {
+		return fmt.Errorf("%s: max_script_size should be positive", modName)
+	}
+
+	var err error
+	endp.store, err = sieve.OpenStorage(scriptsDir, scriptsTable)
+	if err != nil {
+		return fmt.Errorf("%s: %w", modName, err)
+	}
+
+	addresses := make([]config.Endpoint, 0, len(endp.addrs))
+	for _, addr := range endp.addrs {
+		saddr, err := config.ParseEndpoint(addr)
+		if err != nil {
+			return fmt.Errorf("%s: invalid address: %s", modName, addr)
+		}
+		addresses = append(addresses, saddr)
+	}
+
+	return endp.setupListeners(addresses)
+}
+
+func (endp *Endpoint) setupListeners(addresses []config.Endpoint) error {
+	for _, addr := range addresses {
+		l, err := listeners.Listen(addr.Network(), addr.Address())
+		if err != nil {
+			return fmt.Errorf("%s: %v", modName, err)
+		}
+		endp.log.Printf("listening on %v", addr)
+
+		if addr.IsTLS() {
+			if endp.tlsConfig == nil {
+				return fmt.Errorf("%s: can't bind on TLS endpoint without TLS configuration", modName)
+			}
+			l = tls.NewListener(l, endp.tlsConfig)
+		}
+		if endp.proxyProtocol != nil {
+			l = proxy_protocol.NewListener(l, endp.proxyProtocol, endp.log)
+		}
+
+		endp.listeners = append(endp.listeners, l)
+		endp.listenersWg.Add(1)
+		go func() {
+			defer endp.listenersWg.Done()
+			endp.serve(l, addr.IsTLS())
+		}()
+	}
+
+	if endp.tlsConfig == nil {
+		endp.log.Println("TLS is disabled, this is insecure configuration and should be used only for testing!")
+	} else if endp.insecureAuth {
+		endp.log.Println("authentication over unencrypted connections is allowed, this is insecure configuration and should be used only for testing!")
+	}
+
+	return nil
+}
+
+func (endp *Endpoint) serve(l net.Listener, implicitTLS bool) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				endp.log.Printf("failed to accept connection on %v: %v", l.Addr(), err)
+			}
+			return
+		}
+
+		endp.connsLock.Lock()
+		endp.conns[c] = struct{}{}
+		endp.connsLock.Unlock()
+
+		endp.listenersWg.Add(1)
+		go func() {
+			defer endp.listenersWg.Done()
+			defer func() {
+				endp.connsLock.Lock()
+				delete(endp.conns, c)
+				endp.connsLock.Unlock()
+			}()
+			newSession(endp, c, implicitTLS).run()
+		}()
+	}
+}
+
+func (endp *Endpoint) usernameForStorage(ctx context.Context, saslUsername string) (string, error) {
+	saslUsername, err := endp.storageNormalize(saslUsername)
+	if err != nil {
+		return "", err
+	}
+
+	if endp.storageMap == nil {
+		return saslUsername, nil
+	}
+
+	mapped, ok, err := endp.storageMap.Lookup(ctx, saslUsername)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", auth.ErrInvalidAuthCred
+	}
+
+	if saslUsername != mapped {
+		endp.log.DebugMsg("using mapped username for storage", "username", saslUsername, "mapped_username", mapped)
+	}
+
+	return mapped, nil
+}
+
+func (endp *Endpoint) Close() error {
+	for _, l := range endp.listeners {
+		l.Close()
+	}
+	endp.connsLock.Lock()
+	for c := range endp.conns {
+		c.Close()
+	}
+	endp.connsLock.Unlock()
+	endp.listenersWg.Wait()
+	return nil
+}
+
+func init() {
+	module.RegisterEndpoint(modName, New)
+}
+
+// capabilities returns the capability list sent to the client.
+func (endp *Endpoint) capabilities(tlsActive bool) [][2]string {
+	caps := [][2]string{
+		{"IMPLEMENTATION", "MailChat"},
+	}
+	if endp.authAllowed(tlsActive) {
+		caps = append(caps, [2]string{"SASL", strings.Join(endp.saslAuth.SASLMechanisms(), " ")})
+	} else {
+		caps = append(caps, [2]string{"SASL", ""})
+	}
+	caps = append(caps, [2]string{"SIEVE", strings.Join(sieve.Extensions, " ")})
+	if endp.tlsConfig != nil && !tlsActive {
+		caps = append(caps, [2]string{"STARTTLS", ""})
+	}
+	caps = append(caps,
+		[2]string{"MAXREDIRECTS", fmt.Sprint(sieve.MaxRedirects)},
+		[2]string{"VERSION", "1.0"},
+	)
+	return caps
+}
+
+func (endp *Endpoint) authAllowed(tlsActive bool) bool {
+	return tlsActive || endp.insecureAuth || endp.tlsConfig == nil
+}
@@ -0,0 +1,218 @@
+package managesieve
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/sieve"
+)
+
+type mockAuth map[string]string
+
+func (a mockAuth) AuthPlain(username, password string) error {
+	if pass, ok := a[username]; ok && pass == password {
+		return nil
+	}
+	return auth.ErrInvalidAuthCred
+}
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func (c *client) send(line string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// response reads lines until the OK, NO or BYE response and returns all of
+// them.
+func (c *client) response() []string {
+	c.t.Helper()
+	var lines []string
+	for {
+		line, err := c.br.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if strings.HasPrefix(line, "OK") || strings.HasPrefix(line, "NO") || strings.HasPrefix(line, "BYE") {
+			return lines
+		}
+	}
+}
+
+func (c *client) cmd(line, expectPrefix string) []string {
+	c.t.Helper()
+	c.send(line)
+	resp := c.response()
+	if last := resp[len(resp)-1]; !strings.HasPrefix(last, expectPrefix) {
+		c.t.Fatalf("%s: expected %q, got %q", line, expectPrefix, resp)
+	}
+	return resp
+}
+
+func setupEndpoint(t *testing.T) (*Endpoint, *client) {
+	return setupEndpointWith(t, nil)
+}
+
+// setupEndpointWith is setupEndpoint that allows to change the endpoint
+// configuration before it starts.
+func setupEndpointWith(t *testing.T, configure func(*Endpoint)) (*Endpoint, *client) {
+	endp := &Endpoint{
+		log: log.Logger{Name: modName, Debug: testing.Verbose()},
+		saslAuth: auth.SASLAuth{
+			Log:   log.Logger{Name: modName + "/sasl"},
+			Plain: []module.PlainAuth{mockAuth{"bob@example.org": "secret"}},
+		},
+		idleTimeout:      time.Minute,
+		store:            sieve.NewDirStore(t.TempDir()),
+		maxScripts:       2,
+		maxScriptSize:    1024,
+		storageNormalize: authz.NormalizeAuto,
+		conns:            map[net.Conn]struct{}{},
+	}
+	if configure != nil {
+		configure(endp)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endp.listeners = append(endp.listeners, l)
+	endp.listenersWg.Add(1)
+	go func() {
+		defer endp.listenersWg.Done()
+		endp.serve(l, false)
+	}()
+	t.Cleanup(func() { endp.Close() })
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &client{t: t, conn: conn, br: bufio.NewReader(conn)}
+	greeting := c.response()
+	if !strings.HasPrefix(greeting[len(greeting)-1], "OK") {
+		t.Fatalf("unexpected greeting: %q", greeting)
+	}
+	return endp, c
+}
+
+func plainResp(user, pass string) string {
+	return base64.StdEncoding.EncodeToString([]byte("\x00" + user + "\x00" + pass))
+}
+
+func TestSession_Auth(t *testing.T) {
+	_, c := setupEndpoint(t)
+
+	c.cmd(`LISTSCRIPTS`, "NO")
+	c.cmd(`AUTHENTICATE "CRAM-MD5"`, "NO")
+	c.cmd(`AUTHENTICATE "PLAIN" "`+plainResp("bob@example.org", "wrong")+`"`, "NO")
+
+	// Initial response is sent as a separate line.
+	c.send(`AUTHENTICATE "PLAIN"`)
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "\"\"\r\n" {
+		t.Fatalf("unexpected challenge: %q", line)
+	}
+	c.cmd(`"`+plainResp("bob@example.org", "secret")+`"`, "OK")
+
+	c.cmd(`LISTSCRIPTS`, "OK")
+	c.cmd(`UNAUTHENTICATE`, "OK")
+	c.cmd(`LISTSCRIPTS`, "NO")
+	c.cmd(`LOGOUT`, "OK")
+}
+
+func TestSession_LiteralBeforeAuth(t *testing.T) {
+	_, c := setupEndpointWith(t, func(endp *Endpoint) {
+		endp.maxScriptSize = 1024 * 1024
+	})
+	if err := c.conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The literal is not read before authentication.
+	c.cmd(`AUTHENTICATE "PLAIN" {100000+}`, "BYE")
+
+	_, c = setupEndpointWith(t, func(endp *Endpoint) {
+		endp.maxScriptSize = 1024 * 1024
+	})
+	c.cmd(`AUTHENTICATE "PLAIN" "`+plainResp("bob@example.org", "secret")+`"`, "OK")
+	c.cmd(`PUTSCRIPT "big" {100000+}`+"\r\n"+strings.Repeat("#", 100000), "OK")
+}
+
+func TestSession_Scripts(t *testing.T) {
+	endp, c := setupEndpoint(t)
+	c.cmd(`AUTHENTICATE "PLAIN" "`+plainResp("bob@example.org", "secret")+`"`, "OK")
+
+	script := "require \"fileinto\";\r\nfileinto \"Lists\";\r\n"
+	c.cmd(`PUTSCRIPT "main" {`+strconv.Itoa(len(script))+`+}`+"\r\n"+script, "OK")
+	c.cmd(`PUTSCRIPT "broken" "fileinto \"x\";"`, "NO")
+	c.cmd(`CHECKSCRIPT "keep;"`, "OK")
+	c.cmd(`PUTSCRIPT "../main" "keep;"`, "NO")
+
+	resp := c.cmd(`LISTSCRIPTS`, "OK")
+	if len(resp) != 2 || resp[0] != `"main"` {
+		t.Fatalf("unexpected LISTSCRIPTS response: %q", resp)
+	}
+
+	c.cmd(`SETACTIVE "missing"`, "NO (NONEXISTENT)")
+	c.cmd(`SETACTIVE "main"`, "OK")
+	resp = c.cmd(`LISTSCRIPTS`, "OK")
+	if len(resp) != 2 || resp[0] != `"main" ACTIVE` {
+		t.Fatalf("unexpected LISTSCRIPTS response: %q", resp)
+	}
+	name, src, err := endp.store.Active("bob@example.org")
+	if err != nil || name != "main" || src != script {
+		t.Fatalf("wrong active script: %q %q %v", name, src, err)
+	}
+
+	resp = c.cmd(`GETSCRIPT "main"`, "OK")
+	if got := strings.Join(resp[1:len(resp)-1], "\r\n"); got != script {
+		t.Fatalf("unexpected GETSCRIPT response: %q", resp)
+	}
+
+	c.cmd(`HAVESPACE "second" 100`, "OK")
+	c.cmd(`HAVESPACE "second" 100000`, "NO (QUOTA/MAXSIZE)")
+	c.cmd(`PUTSCRIPT "second" "keep;"`, "OK")
+	c.cmd(`PUTSCRIPT "third" "keep;"`, "NO (QUOTA/MAXSCRIPTS)")
+	c.cmd(`PUTSCRIPT "second" "discard;"`, "OK")
+	c.cmd(`PUTSCRIPT "third" {2000+}`+"\r\n"+strings.Repeat("#", 2000), "NO (QUOTA/MAXSIZE)")
+
+	c.cmd(`RENAMESCRIPT "second" "main"`, "NO (ALREADYEXISTS)")
+	c.cmd(`RENAMESCRIPT "second" "third"`, "OK")
+	c.cmd(`DELETESCRIPT "main"`, "NO (ACTIVE)")
+	c.cmd(`DELETESCRIPT "second"`, "NO (NONEXISTENT)")
+	c.cmd(`DELETESCRIPT "third"`, "OK")
+
+	c.cmd(`SETACTIVE ""`, "OK")
+	c.cmd(`DELETESCRIPT "main"`, "OK")
+	resp = c.cmd(`LISTSCRIPTS`, "OK")
+	if len(resp) != 1 {
+		t.Fatalf("unexpected LISTSCRIPTS response: %q", resp)
+	}
+
+	c.cmd(`NOOP "tag"`, `OK (TAG "tag")`)
+	c.cmd(`FOO`, "NO")
+}
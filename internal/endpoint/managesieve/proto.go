@@ -0,0 +1,195 @@
+package managesieve
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const maxLineLen = 8192
+
+var errSyntax = errors.New("syntax error")
+
+// errTooLong is returned if a literal exceeds the limit, the literal is
+// consumed.
+type errTooLong struct {
+	size int64
+}
+
+func (e errTooLong) Error() string {
+	return fmt.Sprintf("literal is too big: %d", e.size)
+}
+
+// argument is a command argument, quoted strings and literals are strings,
+// everything else is an atom.
+type argument struct {
+	value    string
+	isString bool
+}
+
+// readLine reads a command line with string arguments (RFC 5804 Section 4).
+// Literals longer than maxLiteral are skipped and errTooLong is returned. If
+// maxDiscard is exceeded as well, the connection should be closed.
+func readLine(br *bufio.Reader, maxLiteral, maxDiscard int64) ([]argument, error) {
+	var (
+		args    []argument
+		lineLen int
+		tooLong *errTooLong
+	)
+
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		lineLen++
+		if lineLen > maxLineLen {
+			return nil, errors.New("line is too long")
+		}
+
+		switch {
+		case c == '\r':
+			c, err := br.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c != '\n' {
+				return nil, errSyntax
+			}
+			if tooLong != nil {
+				return nil, *tooLong
+			}
+			return args, nil
+		case c == '\n':
+			if tooLong != nil {
+				return nil, *tooLong
+			}
+			return args, nil
+		case c == ' ':
+		case c == '"':
+			s, n, err := readQuoted(br)
+			if err != nil {
+				return nil, err
+			}
+			lineLen += n
+			args = append(args, argument{value: s, isString: true})
+		case c == '{':
+			s, err := readLiteral(br, maxLiteral, maxDiscard)
+			if err != nil {
+				var tl errTooLong
+				if errors.As(err, &tl) {
+					tooLong = &tl
+					continue
+				}
+				return nil, err
+			}
+			args = append(args, argument{value: s, isString: true})
+		default:
+			var b strings.Builder
+			b.WriteByte(c)
+			for {
+				c, err := br.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				if c == ' ' || c == '\r' || c == '\n' || c == '"' || c == '{' {
+					if err := br.UnreadByte(); err != nil {
+						return nil, err
+					}
+					break
+				}
+				lineLen++
+				if lineLen > maxLineLen {
+					return nil, errors.New("line is too long")
+				}
+				b.WriteByte(c)
+			}
+			args = append(args, argument{value: b.String()})
+		}
+	}
+}
+
+func readQuoted(br *bufio.Reader) (string, int, error) {
+	var b strings.Builder
+	for n := 0; n < maxLineLen; n++ {
+		c, err := br.ReadByte()
+		if err != nil {
+			return "", n, err
+		}
+		switch c {
+		case '"':
+			return b.String(), n, nil
+		case '\\':
+			c, err = br.ReadByte()
+			if err != nil {
+				return "", n, err
+			}
+			if c != '"' && c != '\\' {
+				return "", n, errSyntax
+			}
+		case '\r', '\n':
+			return "", n, errSyntax
+		}
+		b.WriteByte(c)
+	}
+	return "", maxLineLen, errors.New("line is too long")
+}
+
+// readLiteral reads the "{N+}" or "{N}" literal, the opening brace is
+// already consumed. Continuation requests are not used by the protocol, so
+// both forms are read the same way.
+func readLiteral(br *bufio.Reader, maxLiteral, maxDiscard int64) (string, error) {
+	spec, err := br.ReadString('}')
+	if err != nil {
+		return "", err
+	}
+	spec = strings.TrimSuffix(strings.TrimSuffix(spec, "}"), "+")
+	size, err := strconv.ParseInt(spec, 10, 64)
+	if err != nil || size < 0 {
+		return "", errSyntax
+	}
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if line != "\r\n" && line != "\n" {
+		return "", errSyntax
+	}
+
+	if size > maxLiteral {
+		if size > maxDiscard {
+			return "", fmt.Errorf("literal is too big: %d", size)
+		}
+		if _, err := io.CopyN(io.Discard, br, size); err != nil {
+			return "", err
+		}
+		return "", errTooLong{size: size}
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// quote formats the string for the response using the quoted form if
+// possible.
+func quote(s string) string {
+	if len(s) > 1024 || strings.ContainsAny(s, "\r\n\x00") {
+		return "{" + strconv.Itoa(len(s)) + "}\r\n" + s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
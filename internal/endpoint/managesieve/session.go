@@ -0,0 +1,510 @@
+package managesieve
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/sieve"
+)
+
+// maxBadCommands is the number of failed commands after which the
+// connection is closed.
+const maxBadCommands = 10
+
+type session struct {
+	endp *Endpoint
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	log  log.Logger
+
+	tls     bool
+	account string
+
+	badCommands int
+}
+
+func newSession(endp *Endpoint, conn net.Conn, implicitTLS bool) *session {
+	s := &session{
+		endp: endp,
+		conn: conn,
+		tls:  implicitTLS,
+		log:  endp.log,
+	}
+	s.br = bufio.NewReader(conn)
+	s.bw = bufio.NewWriter(conn)
+	return s
+}
+
+func (s *session) writeLine(parts ...string) {
+	s.bw.WriteString(strings.Join(parts, " "))
+	s.bw.WriteString("\r\n")
+}
+
+func (s *session) respond(status, code, msg string) {
+	parts := []string{status}
+	if code != "" {
+		parts = append(parts, "("+code+")")
+	}
+	if msg != "" {
+		parts = append(parts, quote(msg))
+	}
+	s.writeLine(parts...)
+}
+
+func (s *session) ok(msg string) {
+	s.respond("OK", "", msg)
+}
+
+func (s *session) no(code, msg string) {
+	s.badCommands++
+	s.respond("NO", code, msg)
+}
+
+func (s *session) writeCapabilities() {
+	for _, c := range s.endp.capabilities(s.tls) {
+		if c[0] == "STARTTLS" {
+			s.writeLine(quote(c[0]))
+			continue
+		}
+		s.writeLine(quote(c[0]), quote(c[1]))
+	}
+}
+
+func (s *session) run() {
+	defer s.conn.Close()
+
+	s.writeCapabilities()
+	s.ok("MailChat ManageSieve ready")
+
+	for {
+		if err := s.bw.Flush(); err != nil {
+			return
+		}
+		if s.badCommands >= maxBadCommands {
+			s.respond("BYE", "", "Too many errors")
+			s.bw.Flush()
+			return
+		}
+
+		if err := s.conn.SetReadDeadline(time.Now().Add(s.endp.idleTimeout)); err != nil {
+			return
+		}
+		// Only authenticated clients can send scripts, commands before that
+		// are limited to a single line.
+		maxLiteral, maxDiscard := int64(maxLineLen), int64(maxLineLen)
+		if s.account != "" {
+			maxLiteral, maxDiscard = s.endp.maxScriptSize, 4*s.endp.maxScriptSize
+		}
+		args, err := readLine(s.br, maxLiteral, maxDiscard)
+		if err != nil {
+			var tl errTooLong
+			if errors.As(err, &tl) {
+				s.no("QUOTA/MAXSIZE", "Script is too big")
+				continue
+			}
+			if errors.Is(err, errSyntax) {
+				s.no("", "Syntax error")
+				continue
+			}
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.log.DebugMsg("connection error", "reason", err, "src_ip", s.conn.RemoteAddr())
+				s.respond("BYE", "", "Connection error")
+				s.bw.Flush()
+			}
+			return
+		}
+		if len(args) == 0 || args[0].isString {
+			s.no("", "Syntax error")
+			continue
+		}
+
+		if !s.handle(strings.ToUpper(args[0].value), args[1:]) {
+			s.bw.Flush()
+			return
+		}
+	}
+}
+
+// handle executes the command and returns false if the connection should be
+// closed.
+func (s *session) handle(cmd string, args []argument) bool {
+	strArgs := make([]string, 0, len(args))
+	for _, a := range args {
+		strArgs = append(strArgs, a.value)
+	}
+
+	switch cmd {
+	case "CAPABILITY":
+		s.writeCapabilities()
+		s.ok("")
+		return true
+	case "NOOP":
+		if len(strArgs) == 1 {
+			s.respond("OK", "TAG "+quote(strArgs[0]), "Done")
+		} else {
+			s.ok("Done")
+		}
+		return true
+	case "LOGOUT":
+		s.ok("Logout completed")
+		return false
+	case "STARTTLS":
+		return s.startTLS()
+	case "AUTHENTICATE":
+		return s.authenticate(args)
+	case "UNAUTHENTICATE":
+		if s.account == "" {
+			s.no("", "Not authenticated")
+			return true
+		}
+		s.account = ""
+		s.ok("")
+		return true
+	}
+
+	if s.account == "" {
+		switch cmd {
+		case "LISTSCRIPTS", "GETSCRIPT", "PUTSCRIPT", "CHECKSCRIPT", "SETACTIVE", "DELETESCRIPT",
+			"RENAMESCRIPT", "HAVESPACE":
+			s.no("", "Authenticate first")
+		default:
+			s.no("", "Unknown command")
+		}
+		return true
+	}
+
+	switch cmd {
+	case "LISTSCRIPTS":
+		if !s.expectArgs(args, 0) {
+			return true
+		}
+		s.listScripts()
+	case "GETSCRIPT":
+		if !s.expectArgs(args, 1) {
+			return true
+		}
+		s.getScript(strArgs[0])
+	case "PUTSCRIPT":
+		if !s.expectArgs(args, 2) {
+			return true
+		}
+		s.putScript(strArgs[0], strArgs[1])
+	case "CHECKSCRIPT":
+		if !s.expectArgs(args, 1) {
+			return true
+		}
+		if s.checkScript(strArgs[0]) {
+			s.ok("")
+		}
+	case "SETACTIVE":
+		if !s.expectArgs(args, 1) {
+			return true
+		}
+		s.setActive(strArgs[0])
+	case "DELETESCRIPT":
+		if !s.expectArgs(args, 1) {
+			return true
+		}
+		s.deleteScript(strArgs[0])
+	case "RENAMESCRIPT":
+		if !s.expectArgs(args, 2) {
+			return true
+		}
+		s.renameScript(strArgs[0], strArgs[1])
+	case "HAVESPACE":
+		if len(args) != 2 || !args[0].isString || args[1].isString {
+			s.no("", "Syntax error")
+			return true
+		}
+		size, err := strconv.ParseInt(args[1].value, 10, 64)
+		if err != nil {
+			s.no("", "Syntax error")
+			return true
+		}
+		if s.checkSpace(args[0].value, size) {
+			s.ok("")
+		}
+	default:
+		s.no("", "Unknown command")
+	}
+	return true
+}
+
+func (s *session) expectArgs(args []argument, n int) bool {
+	if len(args) != n {
+		s.no("", "Syntax error")
+		return false
+	}
+	for _, a := range args {
+		if !a.isString {
+			s.no("", "Syntax error")
+			return false
+		}
+	}
+	return true
+}
+
+func (s *session) startTLS() bool {
+	if s.tls || s.endp.tlsConfig == nil {
+		s.no("", "TLS is not available")
+		return true
+	}
+	s.ok("Begin TLS negotiation now")
+	if err := s.bw.Flush(); err != nil {
+		return false
+	}
+
+	tlsConn := tls.Server(s.conn, s.endp.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		s.log.Error("TLS handshake failed", err, "src_ip", s.conn.RemoteAddr())
+		return false
+	}
+	s.conn = tlsConn
+	s.br = bufio.NewReader(tlsConn)
+	s.bw = bufio.NewWriter(tlsConn)
+	s.tls = true
+
+	s.writeCapabilities()
+	s.ok("")
+	return true
+}
+
+func (s *session) authenticate(args []argument) bool {
+	if s.account != "" {
+		s.no("", "Already authenticated")
+		return true
+	}
+	if !s.endp.authAllowed(s.tls) {
+		s.no("ENCRYPT-NEEDED", "Use STARTTLS first")
+		return true
+	}
+	if len(args) == 0 || len(args) > 2 || !args[0].isString {
+		s.no("", "Syntax error")
+		return true
+	}
+
+	mech := strings.ToUpper(args[0].value)
+	supported := false
+	for _, m := range s.endp.saslAuth.SASLMechanisms() {
+		if m == mech {
+			supported = true
+		}
+	}
+	if !supported {
+		s.no("", "Unsupported authentication mechanism")
+		return true
+	}
+
+	var account string
+	srv := s.endp.saslAuth.CreateSASL(mech, s.conn.RemoteAddr(), func(identity string, _ auth.ContextData) error {
+		var err error
+		account, err = s.endp.usernameForStorage(context.TODO(), identity)
+		return err
+	})
+
+	var response []byte
+	if len(args) == 2 {
+		var err error
+		response, err = base64.StdEncoding.DecodeString(args[1].value)
+		if err != nil {
+			s.no("", "Malformed initial response")
+			return true
+		}
+	}
+
+	for {
+		challenge, done, err := srv.Next(response)
+		if err != nil {
+			s.log.DebugMsg("authentication failed", "reason", err, "src_ip", s.conn.RemoteAddr())
+			s.no("", "Authentication failed")
+			return true
+		}
+		if done {
+			break
+		}
+
+		s.writeLine(quote(base64.StdEncoding.EncodeToString(challenge)))
+		if err := s.bw.Flush(); err != nil {
+			return false
+		}
+		args, err := readLine(s.br, maxLineLen, maxLineLen)
+		if err != nil {
+			return false
+		}
+		if len(args) != 1 || !args[0].isString {
+			s.no("", "Syntax error")
+			return true
+		}
+		if args[0].value == "*" {
+			s.no("", "Authentication aborted")
+			return true
+		}
+		response, err = base64.StdEncoding.DecodeString(args[0].value)
+		if err != nil {
+			s.no("", "Malformed response")
+			return true
+		}
+	}
+
+	if account == "" {
+		s.no("", "Authentication failed")
+		return true
+	}
+	s.account = account
+	s.log = log.Logger{Name: s.endp.log.Name, Debug: s.endp.log.Debug, Fields: map[string]interface{}{
+		"account": account,
+	}}
+	s.log.DebugMsg("authenticated", "src_ip", s.conn.RemoteAddr())
+	s.ok("Logged in")
+	return true
+}
+
+// storeErr reports the storage error to the client.
+func (s *session) storeErr(err error) {
+	switch {
+	case errors.Is(err, sieve.ErrNoScript):
+		s.no("NONEXISTENT", "There is no script by that name")
+	case errors.Is(err, sieve.ErrActiveScript):
+		s.no("ACTIVE", "You may not delete an active script")
+	case errors.Is(err, sieve.ErrScriptExists):
+		s.no("ALREADYEXISTS", "Script with that name already exists")
+	default:
+		s.log.Error("storage error", err)
+		s.no("TRYLATER", "Internal server error")
+	}
+}
+
+func (s *session) validName(name string) bool {
+	if err := sieve.ValidName(name); err != nil {
+		s.no("", "Invalid script name")
+		return false
+	}
+	return true
+}
+
+func (s *session) listScripts() {
+	scripts, err := s.endp.store.List(s.account)
+	if err != nil {
+		s.storeErr(err)
+		return
+	}
+	for _, script := range scripts {
+		if script.Active {
+			s.writeLine(quote(script.Name), "ACTIVE")
+		} else {
+			s.writeLine(quote(script.Name))
+		}
+	}
+	s.ok("Listscripts completed")
+}
+
+func (s *session) getScript(name string) {
+	if !s.validName(name) {
+		return
+	}
+	src, err := s.endp.store.Get(s.account, name)
+	if err != nil {
+		s.storeErr(err)
+		return
+	}
+	s.writeLine("{" + strconv.Itoa(len(src)) + "}\r\n" + src)
+	s.ok("Getscript completed")
+}
+
+func (s *session) checkScript(src string) bool {
+	if !utf8.ValidString(src) {
+		s.no("", "Script is not valid UTF-8")
+		return false
+	}
+	if _, err := sieve.Compile(src); err != nil {
+		s.no("", strings.TrimPrefix(err.Error(), "sieve: "))
+		return false
+	}
+	return true
+}
+
+// checkSpace checks whether the script fits the account limits.
+func (s *session) checkSpace(name string, size int64) bool {
+	if !s.validName(name) {
+		return false
+	}
+	if size > s.endp.maxScriptSize {
+		s.no("QUOTA/MAXSIZE", "Script is too big")
+		return false
+	}
+
+	scripts, err := s.endp.store.List(s.account)
+	if err != nil {
+		s.storeErr(err)
+		return false
+	}
+	for _, script := range scripts {
+		if script.Name == name {
+			return true
+		}
+	}
+	if len(scripts) >= s.endp.maxScripts {
+		s.no("QUOTA/MAXSCRIPTS", fmt.Sprintf("At most %d scripts are allowed", s.endp.maxScripts))
+		return false
+	}
+	return true
+}
+
+func (s *session) putScript(name, src string) {
+	if !s.checkSpace(name, int64(len(src))) || !s.checkScript(src) {
+		return
+	}
+	if err := s.endp.store.Put(s.account, name, src); err != nil {
+		s.storeErr(err)
+		return
+	}
+	s.log.DebugMsg("script uploaded", "script", name)
+	s.ok("")
+}
+
+func (s *session) setActive(name string) {
+	if name != "" && !s.validName(name) {
+		return
+	}
+	if err := s.endp.store.SetActive(s.account, name); err != nil {
+		s.storeErr(err)
+		return
+	}
+	s.log.DebugMsg("active script changed", "script", name)
+	s.ok("")
+}
+
+func (s *session) deleteScript(name string) {
+	if !s.validName(name) {
+		return
+	}
+	if err := s.endp.store.Delete(s.account, name); err != nil {
+		s.storeErr(err)
+		return
+	}
+	s.ok("")
+}
+
+func (s *session) renameScript(oldName, newName string) {
+	if !s.validName(oldName) || !s.validName(newName) {
+		return
+	}
+	if err := s.endp.store.Rename(s.account, oldName, newName); err != nil {
+		s.storeErr(err)
+		return
+	}
+	s.ok("")
+}
@@ -21,7 +21,6 @@ package openmetrics
 import (
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"sync"
 
@@ -29,6 +28,7 @@ import (
 	"github.com/dsoftgames/MailChat/framework/config"
 	"github.com/dsoftgames/MailChat/framework/log"
 	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/listeners"
 )
 
 const modName = "openmetrics"
@@ -67,7 +67,7 @@ func (e *Endpoint) Init(cfg *config.Map) error {
 		if endp.IsTLS() {
 			return fmt.Errorf("%s: TLS is not supported yet", modName)
 		}
-		l, err := net.Listen(endp.Network(), endp.Address())
+		l, err := listeners.Listen(endp.Network(), endp.Address())
 		if err != nil {
 			return fmt.Errorf("%s: %v", modName, err)
 		}
@@ -0,0 +1,156 @@
+package pop3
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+)
+
+type message struct {
+	uid       uint32
+	size      int
+	deleted   bool
+	retrieved bool
+}
+
+// maildrop is the snapshot of the account INBOX taken at login. Messages
+// delivered during the session are not visible until the next one.
+type maildrop struct {
+	user        backend.User
+	mbox        backend.Mailbox
+	uidValidity uint32
+	msgs        []message
+}
+
+func openMaildrop(store module.Storage, account string, mode deleteMode) (*maildrop, error) {
+	u, err := store.GetOrCreateIMAPAcct(account)
+	if err != nil {
+		return nil, err
+	}
+	status, err := u.Status(imap.InboxName, []imap.StatusItem{imap.StatusUidValidity})
+	if err != nil {
+		u.Logout() //nolint:errcheck
+		return nil, err
+	}
+	_, mbox, err := u.GetMailbox(imap.InboxName, false, nil)
+	if err != nil {
+		u.Logout() //nolint:errcheck
+		return nil, err
+	}
+
+	md := &maildrop{
+		user:        u,
+		mbox:        mbox,
+		uidValidity: status.UidValidity,
+	}
+
+	var seq imap.SeqSet
+	seq.AddRange(1, 0)
+	ch := make(chan *imap.Message, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- mbox.ListMessages(true, &seq, []imap.FetchItem{imap.FetchUid, imap.FetchRFC822Size, imap.FetchFlags}, ch)
+	}()
+	for msg := range ch {
+		if mode == deleteNever && hasFlag(msg.Flags, deletedFlag) {
+			continue
+		}
+		md.msgs = append(md.msgs, message{uid: msg.Uid, size: int(msg.Size)})
+	}
+	if err := <-done; err != nil {
+		md.close()
+		return nil, err
+	}
+	return md, nil
+}
+
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// uidl returns the unique-id of the message. It stays the same across
+// sessions as long as the mailbox UIDVALIDITY does not change.
+func (md *maildrop) uidl(msg message) string {
+	return fmt.Sprintf("%08x%08x", md.uidValidity, msg.uid)
+}
+
+func (md *maildrop) read(msg message) ([]byte, error) {
+	var seq imap.SeqSet
+	seq.AddNum(msg.uid)
+	section := &imap.BodySectionName{Peek: true}
+	ch := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- md.mbox.ListMessages(true, &seq, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, ch)
+	}()
+
+	var (
+		body    []byte
+		readErr error
+		found   bool
+	)
+	for fetched := range ch {
+		for _, lit := range fetched.Body {
+			body, readErr = io.ReadAll(lit)
+			found = true
+		}
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("message %d is missing from the mailbox", msg.uid)
+	}
+	return body, readErr
+}
+
+// markSeen sets the \Seen flag on the retrieved message so IMAP clients
+// do not show it as new.
+func (md *maildrop) markSeen(msg message) error {
+	var seq imap.SeqSet
+	seq.AddNum(msg.uid)
+	return md.mbox.UpdateMessagesFlags(true, &seq, imap.AddFlags, true, []string{imap.SeenFlag})
+}
+
+// update removes messages as requested by the client and the configured
+// deleteMode. It returns the number of removed messages.
+func (md *maildrop) update(mode deleteMode) (int, error) {
+	var seq imap.SeqSet
+	count := 0
+	for _, msg := range md.msgs {
+		if msg.deleted || (mode == deleteRetrieved && msg.retrieved) {
+			seq.AddNum(msg.uid)
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	if mode == deleteNever {
+		return count, md.mbox.UpdateMessagesFlags(true, &seq, imap.AddFlags, true, []string{deletedFlag})
+	}
+
+	if delMbox, ok := md.mbox.(interface {
+		DelMessages(uid bool, seqset *imap.SeqSet) error
+	}); ok {
+		return count, delMbox.DelMessages(true, &seq)
+	}
+	if err := md.mbox.UpdateMessagesFlags(true, &seq, imap.AddFlags, true, []string{imap.DeletedFlag}); err != nil {
+		return 0, err
+	}
+	return count, md.mbox.Expunge()
+}
+
+func (md *maildrop) close() {
+	md.mbox.Close()  //nolint:errcheck
+	md.user.Logout() //nolint:errcheck
+}
@@ -0,0 +1,287 @@
+package pop3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	tls2 "github.com/dsoftgames/MailChat/framework/config/tls"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/limits"
+	"github.com/dsoftgames/MailChat/internal/listeners"
+	"github.com/dsoftgames/MailChat/internal/proxy_protocol"
+)
+
+const modName = "pop3"
+
+// deleteMode controls which messages are removed from the server at the
+// end of the session.
+type deleteMode int
+
+const (
+	// deleteMarked removes messages marked using DELE.
+	deleteMarked deleteMode = iota
+	// deleteRetrieved also removes messages downloaded using RETR.
+	deleteRetrieved
+	// deleteNever keeps messages on the server. Messages marked using DELE
+	// are flagged with deletedFlag and are not shown in later sessions.
+	deleteNever
+)
+
+// deletedFlag is set on messages deleted by POP3 clients if messages are
+// kept on the server.
+const deletedFlag = "$POP3Deleted"
+
+// Endpoint implements the POP3 protocol (RFC 1939) for the INBOX of
+// storage accounts.
+type Endpoint struct {
+	addrs         []string
+	log           log.Logger
+	saslAuth      auth.SASLAuth
+	tlsConfig     *tls.Config
+	proxyProtocol *proxy_protocol.ProxyProtocol
+	limits        *limits.Group
+	insecureAuth  bool
+	idleTimeout   time.Duration
+	deleteMode    deleteMode
+
+	store module.Storage
+
+	storageNormalize authz.NormalizeFunc
+	storageMap       module.Table
+
+	listeners   []net.Listener
+	listenersWg sync.WaitGroup
+
+	connsLock sync.Mutex
+	conns     map[net.Conn]struct{}
+
+	// Accounts with open sessions, POP3 requires exclusive access to the
+	// maildrop.
+	lockedLock sync.Mutex
+	locked     map[string]struct{}
+}
+
+func New(_ string, addrs []string) (module.Module, error) {
+	return &Endpoint{
+		addrs: addrs,
+		log:   log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+		saslAuth: auth.SASLAuth{
+			Log: log.Logger{Name: modName + "/sasl"},
+		},
+		conns:  map[net.Conn]struct{}{},
+		locked: map[string]struct{}{},
+	}, nil
+}
+
+func (endp *Endpoint) Name() string {
+	return modName
+}
+
+func (endp *Endpoint) InstanceName() string {
+	return modName
+}
+
+func (endp *Endpoint) Init(cfg *config.Map) error {
+	cfg.Callback("auth", func(m *config.Map, node config.Node) error {
+		return endp.saslAuth.AddProvider(m, node)
+	})
+	cfg.Bool("sasl_login", false, false, &endp.saslAuth.EnableLogin)
+	cfg.Custom("storage", false, true, nil, modconfig.StorageDirective, &endp.store)
+	cfg.Custom("tls", true, true, nil, tls2.TLSDirective, &endp.tlsConfig)
+	cfg.Custom("proxy_protocol", false, false, nil, proxy_protocol.ProxyProtocolDirective, &endp.proxyProtocol)
+	cfg.Custom("limits", false, false, func() (interface{}, error) {
+		return &limits.Group{}, nil
+	}, func(cfg *config.Map, n config.Node) (interface{}, error) {
+		var g *limits.Group
+		if err := modconfig.GroupFromNode("limits", n.Args, n, cfg.Globals, &g); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}, &endp.limits)
+	cfg.Bool("insecure_auth", false, false, &endp.insecureAuth)
+	cfg.Bool("debug", true, false, &endp.log.Debug)
+	cfg.Duration("idle_timeout", false, false, 10*time.Minute, &endp.idleTimeout)
+	config.EnumMapped(cfg, "delete_messages", false, false, map[string]deleteMode{
+		"dele":      deleteMarked,
+		"retrieved": deleteRetrieved,
+		"never":     deleteNever,
+	}, deleteMarked, &endp.deleteMode)
+	config.EnumMapped(cfg, "storage_map_normalize", false, false, authz.NormalizeFuncs, authz.NormalizeAuto,
+		&endp.storageNormalize)
+	modconfig.Table(cfg, "storage_map", false, false, nil, &endp.storageMap)
+	config.EnumMapped(cfg, "auth_map_normalize", true, false, authz.NormalizeFuncs, authz.NormalizeAuto,
+		&endp.saslAuth.AuthNormalize)
+	modconfig.Table(cfg, "auth_map", true, false, nil, &endp.saslAuth.AuthMap)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	endp.saslAuth.Log.Debug = endp.log.Debug
+	if endp.idleTimeout < 10*time.Minute {
+		// RFC 1939 Section 3.
+		return fmt.Errorf("%s: idle_timeout should be at least 10 minutes", modName)
+	}
+
+	addresses := make([]config.Endpoint, 0, len(endp.addrs))
+	for _, addr := range endp.addrs {
+		saddr, err := config.ParseEndpoint(addr)
+		if err != nil {
+			return fmt.Errorf("%s: invalid address: %s", modName, addr)
+		}
+		addresses = append(addresses, saddr)
+	}
+
+	return endp.setupListeners(addresses)
+}
+
+func (endp *Endpoint) setupListeners(addresses []config.Endpoint) error {
+	for _, addr := range addresses {
+		l, err := listeners.Listen(addr.Network(), addr.Address())
+		if err != nil {
+			return fmt.Errorf("%s: %v", modName, err)
+		}
+		endp.log.Printf("listening on %v", addr)
+
+		if addr.IsTLS() {
+			if endp.tlsConfig == nil {
+				return fmt.Errorf("%s: can't bind on TLS endpoint without TLS configuration", modName)
+			}
+			l = tls.NewListener(l, endp.tlsConfig)
+		}
+		if endp.proxyProtocol != nil {
+			l = proxy_protocol.NewListener(l, endp.proxyProtocol, endp.log)
+		}
+
+		endp.listeners = append(endp.listeners, l)
+		endp.listenersWg.Add(1)
+		go func() {
+			defer endp.listenersWg.Done()
+			endp.serve(l, addr.IsTLS())
+		}()
+	}
+
+	if endp.tlsConfig == nil {
+		endp.log.Println("TLS is disabled, this is insecure configuration and should be used only for testing!")
+	} else if endp.insecureAuth {
+		endp.log.Println("authentication over unencrypted connections is allowed, this is insecure configuration and should be used only for testing!")
+	}
+
+	return nil
+}
+
+func remoteIP(c net.Conn) net.IP {
+	if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return net.IPv4zero
+}
+
+func (endp *Endpoint) serve(l net.Listener, implicitTLS bool) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				endp.log.Printf("failed to accept connection on %v: %v", l.Addr(), err)
+			}
+			return
+		}
+
+		endp.connsLock.Lock()
+		endp.conns[c] = struct{}{}
+		endp.connsLock.Unlock()
+
+		endp.listenersWg.Add(1)
+		go func() {
+			defer endp.listenersWg.Done()
+			defer func() {
+				endp.connsLock.Lock()
+				delete(endp.conns, c)
+				endp.connsLock.Unlock()
+			}()
+
+			ip := remoteIP(c)
+			if err := endp.limits.TakeMsg(context.Background(), ip, ""); err != nil {
+				endp.log.DebugMsg("connection rejected by limits", "reason", err, "src_ip", c.RemoteAddr())
+				_, _ = c.Write([]byte("-ERR [SYS/TEMP] Too many connections, try again later\r\n"))
+				c.Close()
+				return
+			}
+			defer endp.limits.ReleaseMsg(ip, "")
+
+			newSession(endp, c, implicitTLS).run()
+		}()
+	}
+}
+
+func (endp *Endpoint) usernameForStorage(ctx context.Context, saslUsername string) (string, error) {
+	saslUsername, err := endp.storageNormalize(saslUsername)
+	if err != nil {
+		return "", err
+	}
+
+	if endp.storageMap == nil {
+		return saslUsername, nil
+	}
+
+	mapped, ok, err := endp.storageMap.Lookup(ctx, saslUsername)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", auth.ErrInvalidAuthCred
+	}
+
+	if saslUsername != mapped {
+		endp.log.DebugMsg("using mapped username for storage", "username", saslUsername, "mapped_username", mapped)
+	}
+
+	return mapped, nil
+}
+
+// lock acquires the exclusive access to the account maildrop.
+func (endp *Endpoint) lock(account string) bool {
+	endp.lockedLock.Lock()
+	defer endp.lockedLock.Unlock()
+	if _, ok := endp.locked[account]; ok {
+		return false
+	}
+	endp.locked[account] = struct{}{}
+	return true
+}
+
+func (endp *Endpoint) unlock(account string) {
+	endp.lockedLock.Lock()
+	defer endp.lockedLock.Unlock()
+	delete(endp.locked, account)
+}
+
+func (endp *Endpoint) authAllowed(tlsActive bool) bool {
+	return tlsActive || endp.insecureAuth || endp.tlsConfig == nil
+}
+
+func (endp *Endpoint) Close() error {
+	for _, l := range endp.listeners {
+		l.Close()
+	}
+	endp.connsLock.Lock()
+	for c := range endp.conns {
+		c.Close()
+	}
+	endp.connsLock.Unlock()
+	endp.listenersWg.Wait()
+	return nil
+}
+
+func init() {
+	module.RegisterEndpoint(modName, New)
+}
@@ -0,0 +1,290 @@
+//go:build !nosqlite3 && cgo
+// +build !nosqlite3,cgo
+
+package pop3
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/dsoftgames/MailChat/internal/limits"
+	"github.com/emersion/go-imap"
+	imapbackend "github.com/emersion/go-imap/backend"
+	imapsql "github.com/foxcpp/go-imap-sql"
+)
+
+type mockAuth map[string]string
+
+func (a mockAuth) AuthPlain(username, password string) error {
+	if pass, ok := a[username]; ok && pass == password {
+		return nil
+	}
+	return auth.ErrInvalidAuthCred
+}
+
+// sqlStorage exposes go-imap-sql backend as module.Storage.
+type sqlStorage struct {
+	*imapsql.Backend
+}
+
+func (s sqlStorage) GetOrCreateIMAPAcct(username string) (imapbackend.User, error) {
+	return s.GetOrCreateUser(username)
+}
+
+func (s sqlStorage) GetIMAPAcct(username string) (imapbackend.User, error) {
+	return s.GetUser(username)
+}
+
+func (s sqlStorage) IMAPExtensions() []string {
+	return nil
+}
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func (c *client) readLine() string {
+	c.t.Helper()
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+func (c *client) cmd(line, expectPrefix string) string {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+	resp := c.readLine()
+	if !strings.HasPrefix(resp, expectPrefix) {
+		c.t.Fatalf("%s: expected %q, got %q", line, expectPrefix, resp)
+	}
+	return resp
+}
+
+// multiline executes the command and returns the lines of the multi-line
+// response with the byte-stuffing kept intact.
+func (c *client) multiline(line string) []string {
+	c.t.Helper()
+	c.cmd(line, "+OK")
+	var lines []string
+	for {
+		l := c.readLine()
+		if l == "." {
+			return lines
+		}
+		lines = append(lines, l)
+	}
+}
+
+func (c *client) login() {
+	c.t.Helper()
+	c.cmd("USER bob@example.org", "+OK")
+	c.cmd("PASS secret", "+OK")
+}
+
+func setupEndpoint(t *testing.T) (*Endpoint, func() *client) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "messages"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := imapsql.New("sqlite3", filepath.Join(dir, "imapsql.db"), &imapsql.FSStore{Root: filepath.Join(dir, "messages")}, imapsql.Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	endp := &Endpoint{
+		log: log.Logger{Name: modName, Debug: testing.Verbose()},
+		saslAuth: auth.SASLAuth{
+			Log:   log.Logger{Name: modName + "/sasl"},
+			Plain: []module.PlainAuth{mockAuth{"bob@example.org": "secret"}},
+		},
+		limits:           &limits.Group{},
+		idleTimeout:      time.Minute,
+		store:            module.Storage(sqlStorage{db}),
+		storageNormalize: authz.NormalizeAuto,
+		conns:            map[net.Conn]struct{}{},
+		locked:           map[string]struct{}{},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endp.listeners = append(endp.listeners, l)
+	endp.listenersWg.Add(1)
+	go func() {
+		defer endp.listenersWg.Done()
+		endp.serve(l, false)
+	}()
+	t.Cleanup(func() { endp.Close() })
+
+	return endp, func() *client {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		c := &client{t: t, conn: conn, br: bufio.NewReader(conn)}
+		if greeting := c.readLine(); !strings.HasPrefix(greeting, "+OK") {
+			t.Fatalf("unexpected greeting: %q", greeting)
+		}
+		return c
+	}
+}
+
+func deliver(t *testing.T, endp *Endpoint, msgs ...string) {
+	t.Helper()
+	u, err := endp.store.GetOrCreateIMAPAcct("bob@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Logout()
+	for _, msg := range msgs {
+		if err := u.CreateMessage(imap.InboxName, nil, time.Now(), strings.NewReader(msg), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func inboxCount(t *testing.T, endp *Endpoint) uint32 {
+	t.Helper()
+	u, err := endp.store.GetOrCreateIMAPAcct("bob@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Logout()
+	status, err := u.Status(imap.InboxName, []imap.StatusItem{imap.StatusMessages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status.Messages
+}
+
+const (
+	testMsg1 = "Subject: first\r\n\r\nline one\r\n.hidden dot\r\nline three\r\n"
+	testMsg2 = "Subject: second\r\n\r\nhello\r\n"
+)
+
+func TestSession_Auth(t *testing.T) {
+	_, dial := setupEndpoint(t)
+	c := dial()
+
+	capa := c.multiline("CAPA")
+	if !strings.Contains(strings.Join(capa, "\n"), "SASL PLAIN") {
+		t.Errorf("SASL PLAIN is not advertised: %q", capa)
+	}
+
+	c.cmd("STAT", "-ERR")
+	c.cmd("PASS secret", "-ERR")
+	c.cmd("USER bob@example.org", "+OK")
+	c.cmd("PASS wrong", "-ERR [AUTH]")
+	c.cmd("AUTH CRAM-MD5", "-ERR")
+	c.cmd("AUTH PLAIN", "+ ")
+	c.cmd("*", "-ERR")
+	c.cmd("AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00bob@example.org\x00secret")), "+OK")
+	c.cmd("STAT", "+OK 0 0")
+
+	// The maildrop is locked by the first session.
+	c2 := dial()
+	c2.cmd("USER bob@example.org", "+OK")
+	c2.cmd("PASS secret", "-ERR [IN-USE]")
+
+	c.cmd("QUIT", "+OK")
+	c.br.ReadString('\n') // wait for the connection to be closed
+	c2.login()
+}
+
+func TestSession_Retrieve(t *testing.T) {
+	endp, dial := setupEndpoint(t)
+	deliver(t, endp, testMsg1, testMsg2)
+
+	c := dial()
+	c.login()
+	c.cmd("STAT", "+OK 2 "+strconv.Itoa(len(testMsg1)+len(testMsg2)))
+	list := c.multiline("LIST")
+	if len(list) != 2 || list[0] != "1 "+strconv.Itoa(len(testMsg1)) {
+		t.Fatalf("wrong LIST: %q", list)
+	}
+	uidl := c.multiline("UIDL")
+	if len(uidl) != 2 {
+		t.Fatalf("wrong UIDL: %q", uidl)
+	}
+
+	body := c.multiline("RETR 1")
+	expected := []string{"Subject: first", "", "line one", "..hidden dot", "line three"}
+	if strings.Join(body, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("wrong RETR: %q", body)
+	}
+	top := c.multiline("TOP 2 0")
+	if strings.Join(top, "\n") != "Subject: second\n" {
+		t.Fatalf("wrong TOP: %q", top)
+	}
+
+	c.cmd("DELE 1", "+OK")
+	c.cmd("RETR 1", "-ERR")
+	c.cmd("STAT", "+OK 1 "+strconv.Itoa(len(testMsg2)))
+	c.cmd("RSET", "+OK")
+	c.cmd("STAT", "+OK 2")
+	c.cmd("DELE 1", "+OK")
+	c.cmd("QUIT", "+OK")
+	c.br.ReadString('\n')
+
+	if n := inboxCount(t, endp); n != 1 {
+		t.Fatalf("expected 1 message left, got %d", n)
+	}
+
+	c = dial()
+	c.login()
+	if uidl2 := c.multiline("UIDL"); len(uidl2) != 1 || strings.Fields(uidl2[0])[1] != strings.Fields(uidl[1])[1] {
+		t.Fatalf("UIDL changed between sessions: %q, was %q", uidl2, uidl)
+	}
+}
+
+func TestSession_DeleteModes(t *testing.T) {
+	endp, dial := setupEndpoint(t)
+	deliver(t, endp, testMsg1, testMsg2)
+
+	endp.deleteMode = deleteRetrieved
+	c := dial()
+	c.login()
+	c.multiline("RETR 1")
+	c.cmd("QUIT", "+OK")
+	c.br.ReadString('\n')
+	if n := inboxCount(t, endp); n != 1 {
+		t.Fatalf("retrieved message is not removed, %d messages left", n)
+	}
+
+	endp.deleteMode = deleteNever
+	c = dial()
+	c.login()
+	c.cmd("DELE 1", "+OK")
+	c.cmd("QUIT", "+OK")
+	c.br.ReadString('\n')
+	if n := inboxCount(t, endp); n != 1 {
+		t.Fatalf("message is removed with delete_messages never, %d messages left", n)
+	}
+
+	c = dial()
+	c.login()
+	c.cmd("STAT", "+OK 0 0")
+}
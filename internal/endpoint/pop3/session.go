@@ -0,0 +1,566 @@
+package pop3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/internal/auth"
+)
+
+const (
+	// maxBadCommands is the number of failed commands after which the
+	// connection is closed.
+	maxBadCommands = 10
+
+	// maxLineLen is the maximum length of a command line. RFC 2449 limits
+	// commands to 255 octets, but SASL responses may be longer.
+	maxLineLen = 8192
+)
+
+var errLineTooLong = errors.New("pop3: line too long")
+
+type session struct {
+	endp *Endpoint
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	log  log.Logger
+
+	tls  bool
+	user string
+
+	account string
+	drop    *maildrop
+
+	badCommands int
+}
+
+func newSession(endp *Endpoint, conn net.Conn, implicitTLS bool) *session {
+	s := &session{
+		endp: endp,
+		conn: conn,
+		tls:  implicitTLS,
+		log:  endp.log,
+	}
+	s.br = bufio.NewReader(conn)
+	s.bw = bufio.NewWriter(conn)
+	return s
+}
+
+func (s *session) writeLine(line string) {
+	s.bw.WriteString(line)
+	s.bw.WriteString("\r\n")
+}
+
+func (s *session) ok(msg string) {
+	if msg == "" {
+		s.writeLine("+OK")
+		return
+	}
+	s.writeLine("+OK " + msg)
+}
+
+func (s *session) err(code, msg string) {
+	s.badCommands++
+	if code != "" {
+		msg = "[" + code + "] " + msg
+	}
+	s.writeLine("-ERR " + msg)
+}
+
+// writeMultiline writes the body of a multi-line response applying the
+// byte-stuffing and the termination octet.
+func (s *session) writeMultiline(data []byte) {
+	for len(data) != 0 {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) != 0 && line[0] == '.' {
+			s.bw.WriteByte('.')
+		}
+		s.bw.Write(line)
+		s.bw.WriteString("\r\n")
+	}
+	s.writeLine(".")
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLen {
+			return "", errLineTooLong
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+func (s *session) run() {
+	defer s.conn.Close()
+	defer func() {
+		if s.drop != nil {
+			s.drop.close()
+			s.endp.unlock(s.account)
+		}
+	}()
+
+	s.ok("MailChat POP3 ready")
+
+	for {
+		if err := s.bw.Flush(); err != nil {
+			return
+		}
+		if s.badCommands >= maxBadCommands {
+			s.writeLine("-ERR Too many errors")
+			s.bw.Flush()
+			return
+		}
+
+		if err := s.conn.SetReadDeadline(time.Now().Add(s.endp.idleTimeout)); err != nil {
+			return
+		}
+		line, err := readLine(s.br)
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				s.writeLine("-ERR Line too long")
+				s.bw.Flush()
+				return
+			}
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.log.DebugMsg("connection error", "reason", err, "src_ip", s.conn.RemoteAddr())
+			}
+			return
+		}
+
+		cmd, args := line, []string(nil)
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			cmd, args = line[:i], strings.Fields(line[i+1:])
+		}
+		if !s.handle(strings.ToUpper(cmd), args) {
+			s.bw.Flush()
+			return
+		}
+	}
+}
+
+// handle executes the command and returns false if the connection should be
+// closed.
+func (s *session) handle(cmd string, args []string) bool {
+	switch cmd {
+	case "CAPA":
+		s.capa()
+		return true
+	case "NOOP":
+		if s.drop == nil {
+			s.err("", "Authenticate first")
+			return true
+		}
+		s.ok("")
+		return true
+	case "QUIT":
+		return s.quit()
+	}
+
+	if s.drop == nil {
+		switch cmd {
+		case "STLS":
+			return s.startTLS()
+		case "USER":
+			s.userCmd(args)
+		case "PASS":
+			s.pass(args)
+		case "AUTH":
+			return s.authenticate(args)
+		case "STAT", "LIST", "RETR", "TOP", "UIDL", "DELE", "RSET":
+			s.err("", "Authenticate first")
+		default:
+			s.err("", "Unknown command")
+		}
+		return true
+	}
+
+	switch cmd {
+	case "STAT":
+		if len(args) != 0 {
+			s.err("", "Syntax error")
+			return true
+		}
+		count, size := 0, 0
+		for _, msg := range s.drop.msgs {
+			if !msg.deleted {
+				count++
+				size += msg.size
+			}
+		}
+		s.ok(strconv.Itoa(count) + " " + strconv.Itoa(size))
+	case "LIST":
+		s.list(args, func(msg message) string {
+			return strconv.Itoa(msg.size)
+		})
+	case "UIDL":
+		s.list(args, func(msg message) string {
+			return s.drop.uidl(msg)
+		})
+	case "RETR":
+		if len(args) != 1 {
+			s.err("", "Syntax error")
+			return true
+		}
+		s.retrieve(args[0], -1)
+	case "TOP":
+		if len(args) != 2 {
+			s.err("", "Syntax error")
+			return true
+		}
+		lines, err := strconv.Atoi(args[1])
+		if err != nil || lines < 0 {
+			s.err("", "Invalid number of lines")
+			return true
+		}
+		s.retrieve(args[0], lines)
+	case "DELE":
+		if len(args) != 1 {
+			s.err("", "Syntax error")
+			return true
+		}
+		i, ok := s.message(args[0])
+		if !ok {
+			return true
+		}
+		s.drop.msgs[i].deleted = true
+		s.ok("Message deleted")
+	case "RSET":
+		for i := range s.drop.msgs {
+			s.drop.msgs[i].deleted = false
+		}
+		s.ok("")
+	case "STLS", "USER", "PASS", "AUTH":
+		s.err("", "Already authenticated")
+	default:
+		s.err("", "Unknown command")
+	}
+	return true
+}
+
+func (s *session) capa() {
+	s.ok("Capability list follows")
+	s.writeLine("TOP")
+	s.writeLine("UIDL")
+	s.writeLine("RESP-CODES")
+	s.writeLine("AUTH-RESP-CODE")
+	s.writeLine("PIPELINING")
+	if s.endp.deleteMode == deleteRetrieved {
+		s.writeLine("EXPIRE 0")
+	} else {
+		s.writeLine("EXPIRE NEVER")
+	}
+	s.writeLine("IMPLEMENTATION MailChat")
+	if s.drop == nil {
+		if !s.tls && s.endp.tlsConfig != nil {
+			s.writeLine("STLS")
+		}
+		if s.endp.authAllowed(s.tls) {
+			s.writeLine("USER")
+			if mechs := s.endp.saslAuth.SASLMechanisms(); len(mechs) != 0 {
+				s.writeLine("SASL " + strings.Join(mechs, " "))
+			}
+		}
+	}
+	s.writeLine(".")
+}
+
+// message parses the message number and returns the index of the
+// message. It reports the error to the client if the message does not
+// exist or is deleted.
+func (s *session) message(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(s.drop.msgs) {
+		s.err("", "No such message")
+		return 0, false
+	}
+	if s.drop.msgs[n-1].deleted {
+		s.err("", "Message is deleted")
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func (s *session) list(args []string, value func(msg message) string) {
+	switch len(args) {
+	case 0:
+		s.ok("")
+		for i, msg := range s.drop.msgs {
+			if msg.deleted {
+				continue
+			}
+			s.writeLine(strconv.Itoa(i+1) + " " + value(msg))
+		}
+		s.writeLine(".")
+	case 1:
+		i, ok := s.message(args[0])
+		if !ok {
+			return
+		}
+		s.ok(strconv.Itoa(i+1) + " " + value(s.drop.msgs[i]))
+	default:
+		s.err("", "Syntax error")
+	}
+}
+
+// retrieve sends the message to the client. If lines is not negative, only
+// the header and the first lines of the body are sent.
+func (s *session) retrieve(arg string, lines int) {
+	i, ok := s.message(arg)
+	if !ok {
+		return
+	}
+	msg := s.drop.msgs[i]
+
+	body, err := s.drop.read(msg)
+	if err != nil {
+		s.log.Error("failed to read message", err, "uid", msg.uid)
+		s.err("SYS/TEMP", "Internal server error")
+		return
+	}
+
+	if lines >= 0 {
+		body = topLines(body, lines)
+	} else {
+		if err := s.drop.markSeen(msg); err != nil {
+			s.log.Error("failed to set \\Seen flag", err, "uid", msg.uid)
+		}
+		s.drop.msgs[i].retrieved = true
+	}
+
+	s.ok("Message follows")
+	s.writeMultiline(body)
+}
+
+// topLines returns the message header and the first n lines of the body.
+func topLines(body []byte, n int) []byte {
+	end := len(body)
+	hdrEnd := -1
+	if i := bytes.Index(body, []byte("\r\n\r\n")); i != -1 {
+		hdrEnd = i + 4
+	}
+	if i := bytes.Index(body, []byte("\n\n")); i != -1 && (hdrEnd == -1 || i+2 < hdrEnd) {
+		hdrEnd = i + 2
+	}
+	if hdrEnd == -1 {
+		return body
+	}
+
+	pos := hdrEnd
+	for ; n > 0 && pos < end; n-- {
+		i := bytes.IndexByte(body[pos:], '\n')
+		if i == -1 {
+			pos = end
+			break
+		}
+		pos += i + 1
+	}
+	return body[:pos]
+}
+
+func (s *session) startTLS() bool {
+	if s.tls || s.endp.tlsConfig == nil {
+		s.err("", "TLS is not available")
+		return true
+	}
+	s.ok("Begin TLS negotiation now")
+	if err := s.bw.Flush(); err != nil {
+		return false
+	}
+
+	tlsConn := tls.Server(s.conn, s.endp.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		s.log.Error("TLS handshake failed", err, "src_ip", s.conn.RemoteAddr())
+		return false
+	}
+	s.conn = tlsConn
+	s.br = bufio.NewReader(tlsConn)
+	s.bw = bufio.NewWriter(tlsConn)
+	s.tls = true
+	s.user = ""
+	return true
+}
+
+func (s *session) userCmd(args []string) {
+	if !s.endp.authAllowed(s.tls) {
+		s.err("AUTH", "Use STLS first")
+		return
+	}
+	if len(args) != 1 {
+		s.err("", "Syntax error")
+		return
+	}
+	s.user = args[0]
+	s.ok("")
+}
+
+func (s *session) pass(args []string) {
+	if s.user == "" {
+		s.err("", "Send USER first")
+		return
+	}
+	user := s.user
+	s.user = ""
+	if len(args) == 0 {
+		s.err("", "Syntax error")
+		return
+	}
+
+	// The password may contain spaces.
+	password := strings.Join(args, " ")
+	if err := s.endp.saslAuth.AuthPlain(s.conn.RemoteAddr(), user, password); err != nil {
+		s.log.DebugMsg("authentication failed", "reason", err, "username", user, "src_ip", s.conn.RemoteAddr())
+		s.err("AUTH", "Authentication failed")
+		return
+	}
+	account, err := s.endp.usernameForStorage(context.TODO(), user)
+	if err != nil {
+		s.log.DebugMsg("authentication failed", "reason", err, "username", user, "src_ip", s.conn.RemoteAddr())
+		s.err("AUTH", "Authentication failed")
+		return
+	}
+	s.login(account)
+}
+
+func (s *session) authenticate(args []string) bool {
+	if !s.endp.authAllowed(s.tls) {
+		s.err("AUTH", "Use STLS first")
+		return true
+	}
+	if len(args) == 0 || len(args) > 2 {
+		s.err("", "Syntax error")
+		return true
+	}
+
+	mech := strings.ToUpper(args[0])
+	supported := false
+	for _, m := range s.endp.saslAuth.SASLMechanisms() {
+		if m == mech {
+			supported = true
+		}
+	}
+	if !supported {
+		s.err("", "Unsupported authentication mechanism")
+		return true
+	}
+
+	var account string
+	srv := s.endp.saslAuth.CreateSASL(mech, s.conn.RemoteAddr(), func(identity string, _ auth.ContextData) error {
+		var err error
+		account, err = s.endp.usernameForStorage(context.TODO(), identity)
+		return err
+	})
+
+	var response []byte
+	if len(args) == 2 && args[1] != "=" {
+		var err error
+		response, err = base64.StdEncoding.DecodeString(args[1])
+		if err != nil {
+			s.err("", "Malformed initial response")
+			return true
+		}
+	}
+
+	for {
+		challenge, done, err := srv.Next(response)
+		if err != nil {
+			s.log.DebugMsg("authentication failed", "reason", err, "src_ip", s.conn.RemoteAddr())
+			s.err("AUTH", "Authentication failed")
+			return true
+		}
+		if done {
+			break
+		}
+
+		s.writeLine("+ " + base64.StdEncoding.EncodeToString(challenge))
+		if err := s.bw.Flush(); err != nil {
+			return false
+		}
+		line, err := readLine(s.br)
+		if err != nil {
+			return false
+		}
+		if line == "*" {
+			s.err("", "Authentication aborted")
+			return true
+		}
+		response, err = base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			s.err("", "Malformed response")
+			return true
+		}
+	}
+
+	if account == "" {
+		s.err("AUTH", "Authentication failed")
+		return true
+	}
+	s.login(account)
+	return true
+}
+
+// login opens the maildrop after successful authentication and switches
+// the session to the TRANSACTION state.
+func (s *session) login(account string) {
+	if !s.endp.lock(account) {
+		s.err("IN-USE", "Mailbox is already in use")
+		return
+	}
+
+	drop, err := openMaildrop(s.endp.store, account, s.endp.deleteMode)
+	if err != nil {
+		s.endp.unlock(account)
+		s.log.Error("failed to open maildrop", err, "account", account)
+		s.err("SYS/TEMP", "Internal server error")
+		return
+	}
+
+	s.account = account
+	s.drop = drop
+	s.log = log.Logger{Name: s.endp.log.Name, Debug: s.endp.log.Debug, Fields: map[string]interface{}{
+		"account": account,
+	}}
+	s.log.DebugMsg("authenticated", "src_ip", s.conn.RemoteAddr(), "messages", len(drop.msgs))
+	s.ok("Logged in")
+}
+
+func (s *session) quit() bool {
+	if s.drop == nil {
+		s.ok("Bye")
+		return false
+	}
+
+	removed, err := s.drop.update(s.endp.deleteMode)
+	if err != nil {
+		s.log.Error("failed to remove messages", err)
+		s.err("SYS/TEMP", "Failed to remove some messages")
+		return false
+	}
+	s.log.DebugMsg("session closed", "removed", removed)
+	s.ok("Bye")
+	return false
+}
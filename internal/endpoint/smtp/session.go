@@ -21,6 +21,7 @@ package smtp
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -40,6 +41,7 @@ import (
 	"github.com/dsoftgames/MailChat/framework/log"
 	"github.com/dsoftgames/MailChat/framework/module"
 	"github.com/dsoftgames/MailChat/internal/auth"
+	"github.com/dsoftgames/MailChat/internal/sessions"
 )
 
 func limitReader(r io.Reader, n int64, err error) *limitedReader {
@@ -103,9 +105,15 @@ func (s *Session) AuthMechanisms() []string {
 }
 
 func (s *Session) Auth(mech string) (sasl.Server, error) {
-	return s.endp.saslAuth.CreateSASL(mech, s.connState.RemoteAddr, func(identity string, data auth.ContextData) error {
+	var tlsState *tls.ConnectionState
+	if s.connState.TLS.HandshakeComplete {
+		tlsState = &s.connState.TLS
+	}
+
+	return s.endp.saslAuth.CreateSASLConn(s.sessionCtx, mech, s.connState.RemoteAddr, tlsState, func(identity string, data auth.ContextData) error {
 		s.connState.AuthUser = identity
 		s.connState.AuthPassword = data.Password
+		sessions.SetUser(s.connState.LocalAddr, s.connState.RemoteAddr, identity)
 		return nil
 	}), nil
 }
@@ -165,7 +173,7 @@ func (s *Session) AuthPlain(username, password string) error {
 	}
 
 	// saslAuth will handle AuthMap and AuthNormalize.
-	err := s.endp.saslAuth.AuthPlain(username, password)
+	err := s.endp.saslAuth.AuthPlain(s.connState.RemoteAddr, username, password)
 	if err != nil {
 		s.endp.Log.Error("authentication failed", err, "username", username, "src_ip", s.connState.RemoteAddr)
 
@@ -188,6 +196,7 @@ func (s *Session) AuthPlain(username, password string) error {
 
 	s.connState.AuthUser = username
 	s.connState.AuthPassword = password
+	sessions.SetUser(s.connState.LocalAddr, s.connState.RemoteAddr, username)
 
 	return nil
 }
@@ -263,6 +272,12 @@ func (s *Session) startDelivery(ctx context.Context, from string, opts smtp.Mail
 	if err := s.endp.limits.TakeMsg(context.Background(), remoteIP.IP, domain); err != nil {
 		return "", err
 	}
+	if s.connState.AuthUser != "" {
+		if err := s.endp.limits.TakeUserMsg(ctx, s.connState.AuthUser); err != nil {
+			s.endp.limits.ReleaseMsg(remoteIP.IP, domain)
+			return "", err
+		}
+	}
 
 	s.msgCtx, s.msgTask = trace.NewTask(ctx, "Incoming Message")
 
@@ -406,6 +421,12 @@ func (s *Session) rcpt(ctx context.Context, to string, opts *smtp.RcptOptions) e
 		}
 	}
 
+	if s.connState.AuthUser != "" {
+		if err := s.endp.limits.TakeUserRcpt(ctx, s.connState.AuthUser, cleanTo); err != nil {
+			return err
+		}
+	}
+
 	return s.delivery.AddRcpt(ctx, cleanTo, *opts)
 }
 
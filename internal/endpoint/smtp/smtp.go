@@ -45,8 +45,10 @@ import (
 	"github.com/dsoftgames/MailChat/internal/auth"
 	"github.com/dsoftgames/MailChat/internal/authz"
 	"github.com/dsoftgames/MailChat/internal/limits"
+	"github.com/dsoftgames/MailChat/internal/listeners"
 	"github.com/dsoftgames/MailChat/internal/msgpipeline"
 	"github.com/dsoftgames/MailChat/internal/proxy_protocol"
+	"github.com/dsoftgames/MailChat/internal/sessions"
 )
 
 type Endpoint struct {
@@ -56,6 +58,7 @@ type Endpoint struct {
 	addrs         []string
 	listeners     []net.Listener
 	proxyProtocol *proxy_protocol.ProxyProtocol
+	clientAuth    *tls2.ClientAuth
 	pipeline      *msgpipeline.MsgPipeline
 	resolver      dns.Resolver
 	limits        *limits.Group
@@ -264,6 +267,7 @@ func (endp *Endpoint) setConfig(cfg *config.Map) error {
 		return autoBufferMode(1*1024*1024 /* 1 MiB */, path), nil
 	}, bufferModeDirective, &endp.buffer)
 	cfg.Custom("tls", true, endp.name != "lmtp", nil, tls2.TLSDirective, &endp.serv.TLSConfig)
+	cfg.Custom("tls_client_auth", false, false, nil, tls2.ClientAuthDirective, &endp.clientAuth)
 	cfg.Custom("proxy_protocol", false, false, nil, proxy_protocol.ProxyProtocolDirective, &endp.proxyProtocol)
 	cfg.Bool("insecure_auth", endp.name == "lmtp", false, &endp.serv.AllowInsecureAuth)
 	cfg.Int("smtp_max_line_length", false, false, 4000, &endp.serv.MaxLineLength)
@@ -287,6 +291,13 @@ func (endp *Endpoint) setConfig(cfg *config.Map) error {
 	}
 
 	endp.saslAuth.Log.Debug = endp.Log.Debug
+	if endp.clientAuth != nil {
+		if !endp.submission {
+			return fmt.Errorf("%s: tls_client_auth can be used only with submission endpoint", endp.name)
+		}
+		endp.serv.TLSConfig = endp.clientAuth.Apply(endp.serv.TLSConfig)
+		endp.saslAuth.CertIdentities = endp.clientAuth.Identities
+	}
 
 	// INTERNATIONALIZATION: See RFC 6531 Section 3.3.
 	endp.serv.Domain, err = idna.ToASCII(hostname)
@@ -322,11 +333,12 @@ func (endp *Endpoint) setupListeners(addresses []config.Endpoint) error {
 	for _, addr := range addresses {
 		var l net.Listener
 		var err error
-		l, err = net.Listen(addr.Network(), addr.Address())
+		l, err = listeners.Listen(addr.Network(), addr.Address())
 		if err != nil {
 			return fmt.Errorf("%s: %w", endp.name, err)
 		}
 		endp.Log.Printf("listening on %v", addr)
+		l = sessions.Listener(l, endp.name)
 
 		if addr.IsTLS() {
 			if endp.serv.TLSConfig == nil {
@@ -0,0 +1,419 @@
+package sieve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/sieve"
+)
+
+const sendTimeout = time.Minute
+
+// responder sends messages generated by redirect, reject and vacation
+// actions.
+type responder struct {
+	f           *Filter
+	log         log.Logger
+	accountName string
+	rcptTo      string
+	msgMeta     *module.MsgMetadata
+	hdr         textproto.Header
+	body        buffer.Buffer
+}
+
+func (r *responder) send(mailFrom string, rcpts []string, hdr textproto.Header, body buffer.Buffer) error {
+	if r.f.target == nil {
+		return errors.New("sieve: target is not configured, can not send messages")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	msgID, err := module.GenerateMsgID()
+	if err != nil {
+		return err
+	}
+	msgMeta := &module.MsgMetadata{
+		ID:       msgID,
+		SMTPOpts: smtp.MailOptions{},
+	}
+	delivery, err := r.f.target.Start(ctx, msgMeta, mailFrom)
+	if err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := delivery.AddRcpt(ctx, rcpt, smtp.RcptOptions{}); err != nil {
+			delivery.Abort(ctx)
+			return err
+		}
+	}
+	if err := delivery.Body(ctx, hdr, body); err != nil {
+		delivery.Abort(ctx)
+		return err
+	}
+	return delivery.Commit(ctx)
+}
+
+func (r *responder) msgID() (string, error) {
+	id, err := module.GenerateMsgID()
+	if err != nil {
+		return "", err
+	}
+	return "<" + id + "@" + r.f.autogenMsg + ">", nil
+}
+
+// redirectTargets returns the redirect addresses the message did not go
+// through yet. The message listing the address in Resent-To, Resent-From or
+// Delivered-To was already redirected to or from it, sending it there again
+// would create a loop.
+func (r *responder) redirectTargets(rcpts []string) []string {
+	seen := map[string]struct{}{}
+	for _, field := range []string{"Resent-To", "Resent-From", "Delivered-To"} {
+		for fields := r.hdr.FieldsByKey(field); fields.Next(); {
+			addrs, err := mail.ParseAddressList(fields.Value())
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if norm, err := address.ForLookup(addr.Address); err == nil {
+					seen[norm] = struct{}{}
+				}
+			}
+		}
+	}
+
+	targets := make([]string, 0, len(rcpts))
+	for _, rcpt := range rcpts {
+		if norm, err := address.ForLookup(rcpt); err == nil {
+			if _, ok := seen[norm]; ok {
+				r.log.Msg("not redirecting the message, loop detected", "rcpt", r.rcptTo, "to", rcpt)
+				continue
+			}
+		}
+		targets = append(targets, rcpt)
+	}
+	return targets
+}
+
+// redirect sends the message unchanged to the addresses, keeping the
+// original envelope sender.
+func (r *responder) redirect(rcpts []string) error {
+	hdr := r.hdr.Copy()
+	hdr.Add("Resent-To", strings.Join(rcpts, ", "))
+	hdr.Add("Resent-From", r.rcptTo)
+	hdr.Add("Resent-Date", time.Now().Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	return r.send(r.msgMeta.OriginalFrom, rcpts, hdr, r.body)
+}
+
+// reject sends a message disposition notification for the rejected message
+// (RFC 5429 Section 2.1.1).
+func (r *responder) reject(reason string) error {
+	if r.msgMeta.OriginalFrom == "" {
+		r.log.Msg("not sending rejection for a message with null sender", "rcpt", r.rcptTo)
+		return nil
+	}
+
+	msgID, err := r.msgID()
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	partWriter := textproto.NewMultipartWriter(&body)
+
+	hdr := textproto.Header{}
+	hdr.Add("Date", time.Now().Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	hdr.Add("Message-Id", msgID)
+	hdr.Add("MIME-Version", "1.0")
+	hdr.Add("Content-Type", "multipart/report; report-type=disposition-notification; boundary="+partWriter.Boundary())
+	hdr.Add("Auto-Submitted", "auto-replied (rejected)")
+	hdr.Add("From", r.rcptTo)
+	hdr.Add("To", r.msgMeta.OriginalFrom)
+	hdr.Add("Subject", mime.QEncoding.Encode("utf-8", "Rejected: "+r.subject()))
+
+	textHdr := textproto.Header{}
+	textHdr.Add("Content-Type", "text/plain; charset=utf-8")
+	textHdr.Add("Content-Transfer-Encoding", "quoted-printable")
+	textWriter, err := partWriter.CreatePart(textHdr)
+	if err != nil {
+		return err
+	}
+	qpWriter := quotedprintable.NewWriter(textWriter)
+	if _, err := fmt.Fprintf(qpWriter, "Your message to %s was automatically rejected:\r\n\r\n%s\r\n",
+		r.rcptTo, reason); err != nil {
+		return err
+	}
+	if err := qpWriter.Close(); err != nil {
+		return err
+	}
+
+	mdn := []string{
+		"Reporting-UA: " + r.f.hostname + "; MailChat",
+		"Final-Recipient: rfc822; " + r.rcptTo,
+	}
+	if origID := r.hdr.Get("Message-Id"); origID != "" {
+		mdn = append(mdn, "Original-Message-ID: "+origID)
+	}
+	mdn = append(mdn, "Disposition: automatic-action/MDN-sent-automatically; deleted")
+
+	mdnHdr := textproto.Header{}
+	mdnHdr.Add("Content-Type", "message/disposition-notification")
+	mdnHdr.Add("Content-Transfer-Encoding", "7bit")
+	mdnWriter, err := partWriter.CreatePart(mdnHdr)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mdnWriter, strings.Join(mdn, "\r\n")+"\r\n"); err != nil {
+		return err
+	}
+
+	origHdr := textproto.Header{}
+	origHdr.Add("Content-Type", "text/rfc822-headers")
+	origHdr.Add("Content-Transfer-Encoding", "8bit")
+	origWriter, err := partWriter.CreatePart(origHdr)
+	if err != nil {
+		return err
+	}
+	if err := textproto.WriteHeader(origWriter, r.hdr); err != nil {
+		return err
+	}
+	if err := partWriter.Close(); err != nil {
+		return err
+	}
+
+	// Null sender prevents loops.
+	return r.send("", []string{r.msgMeta.OriginalFrom}, hdr, buffer.MemoryBuffer{Slice: body.Bytes()})
+}
+
+func (r *responder) subject() string {
+	subject := strings.TrimSpace(r.hdr.Get("Subject"))
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	return subject
+}
+
+// shouldRespond implements the checks of RFC 5230 Section 4.5 and RFC 3834
+// on whether an automatic response can be sent for the message.
+func (r *responder) shouldRespond(v *sieve.Vacation) (bool, string) {
+	sender := r.msgMeta.OriginalFrom
+	if sender == "" {
+		return false, "null sender"
+	}
+	localPart, _, err := address.Split(sender)
+	if err != nil {
+		return false, "malformed sender"
+	}
+	localPart = strings.ToLower(localPart)
+	if localPart == "mailer-daemon" || localPart == "listserv" || localPart == "majordomo" ||
+		strings.HasPrefix(localPart, "owner-") || strings.Contains(localPart, "-request") {
+		return false, "sender is a mailing list or a mailer"
+	}
+
+	if autoSubmitted := r.hdr.Get("Auto-Submitted"); autoSubmitted != "" &&
+		!strings.EqualFold(strings.TrimSpace(autoSubmitted), "no") {
+		return false, "message is auto-submitted"
+	}
+	for _, field := range []string{"List-Id", "List-Post", "List-Unsubscribe", "List-Help"} {
+		if r.hdr.Has(field) {
+			return false, "message is from a mailing list"
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(r.hdr.Get("Precedence"))) {
+	case "bulk", "list", "junk":
+		return false, "message is bulk mail"
+	}
+
+	own := map[string]struct{}{}
+	for _, addr := range append([]string{r.rcptTo, r.accountName, v.From}, v.Addresses...) {
+		if parsed, err := mail.ParseAddress(addr); err == nil {
+			addr = parsed.Address
+		}
+		if norm, err := address.ForLookup(addr); err == nil {
+			own[norm] = struct{}{}
+		}
+	}
+	if norm, err := address.ForLookup(sender); err == nil {
+		if _, ok := own[norm]; ok {
+			return false, "message is from the account itself"
+		}
+	}
+
+	h := mail.Header{Header: message.Header{Header: r.hdr}}
+	for _, field := range []string{"To", "Cc", "Bcc", "Resent-To", "Resent-Cc", "Resent-Bcc"} {
+		addrs, err := h.AddressList(field)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if norm, err := address.ForLookup(addr.Address); err == nil {
+				if _, ok := own[norm]; ok {
+					return true, ""
+				}
+			}
+		}
+	}
+	return false, "account address is not in the message header"
+}
+
+// vacation sends the vacation response unless it was already sent to the
+// sender within the response interval.
+func (r *responder) vacation(v *sieve.Vacation) error {
+	if ok, reason := r.shouldRespond(v); !ok {
+		r.log.DebugMsg("not sending vacation response", "rcpt", r.rcptTo, "reason", reason)
+		return nil
+	}
+
+	sender := r.msgMeta.OriginalFrom
+	now := time.Now()
+	respond, err := r.f.trackVacation(r.accountName, v.Handle, sender, time.Duration(v.Days)*24*time.Hour, now)
+	if err != nil {
+		return err
+	}
+	if !respond {
+		r.log.DebugMsg("vacation response was already sent", "rcpt", r.rcptTo, "sender", sender)
+		return nil
+	}
+
+	hdr, body, err := r.vacationMsg(v, now)
+	if err != nil {
+		return err
+	}
+	if err := r.send("", []string{sender}, hdr, buffer.MemoryBuffer{Slice: body}); err != nil {
+		return err
+	}
+	r.log.Msg("vacation response sent", "rcpt", r.rcptTo, "sender", sender)
+	return nil
+}
+
+func (r *responder) vacationMsg(v *sieve.Vacation, now time.Time) (textproto.Header, []byte, error) {
+	msgID, err := r.msgID()
+	if err != nil {
+		return textproto.Header{}, nil, err
+	}
+
+	from := v.From
+	if from == "" {
+		from = r.rcptTo
+	}
+	subject := v.Subject
+	if subject == "" {
+		subject = "Auto: " + r.subject()
+	}
+
+	var (
+		bodyHdr textproto.Header
+		body    bytes.Buffer
+	)
+	if v.MIME {
+		// The reason is a MIME entity, its header is merged into the
+		// message header.
+		br := bufio.NewReader(strings.NewReader(v.Reason))
+		bodyHdr, err = textproto.ReadHeader(br)
+		if err != nil {
+			return textproto.Header{}, nil, fmt.Errorf("sieve: malformed MIME vacation reason: %w", err)
+		}
+		if _, err := body.ReadFrom(br); err != nil {
+			return textproto.Header{}, nil, err
+		}
+	} else {
+		bodyHdr.Add("Content-Transfer-Encoding", "quoted-printable")
+		bodyHdr.Add("Content-Type", "text/plain; charset=utf-8")
+		qpWriter := quotedprintable.NewWriter(&body)
+		if _, err := io.WriteString(qpWriter, v.Reason); err != nil {
+			return textproto.Header{}, nil, err
+		}
+		if err := qpWriter.Close(); err != nil {
+			return textproto.Header{}, nil, err
+		}
+	}
+
+	hdr := textproto.Header{}
+	for field := bodyHdr.Fields(); field.Next(); {
+		hdr.AddRaw([]byte(field.Key() + ": " + field.Value() + "\r\n"))
+	}
+	hdr.Add("MIME-Version", "1.0")
+	if origID := r.hdr.Get("Message-Id"); origID != "" {
+		refs := strings.TrimSpace(r.hdr.Get("References"))
+		if refs == "" {
+			refs = strings.TrimSpace(r.hdr.Get("In-Reply-To"))
+		}
+		hdr.Add("References", strings.TrimSpace(refs+" "+origID))
+		hdr.Add("In-Reply-To", origID)
+	}
+	hdr.Add("Auto-Submitted", "auto-replied (vacation)")
+	hdr.Add("Date", now.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	hdr.Add("Message-Id", msgID)
+	hdr.Add("Subject", mime.QEncoding.Encode("utf-8", subject))
+	hdr.Add("To", r.msgMeta.OriginalFrom)
+	hdr.Add("From", from)
+	return hdr, body.Bytes(), nil
+}
+
+// trackVacation records the response to the sender and reports whether it
+// should be sent. Responses are tracked per account in a JSON file in
+// vacation_dir.
+func (f *Filter) trackVacation(accountName, handle, sender string, interval time.Duration, now time.Time) (bool, error) {
+	if err := sieve.ValidName(accountName); err != nil {
+		return false, err
+	}
+	path := filepath.Join(f.vacationDir, accountName+".json")
+
+	f.vacationLock.Lock()
+	defer f.vacationLock.Unlock()
+
+	sent := map[string]time.Time{}
+	blob, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(blob, &sent); err != nil {
+			f.log.Error("malformed vacation state, resetting", err, "account", accountName)
+			sent = map[string]time.Time{}
+		}
+	}
+
+	key := sha256.Sum256([]byte(handle + "\x00" + strings.ToLower(sender)))
+	keyHex := hex.EncodeToString(key[:])
+	if last, ok := sent[keyHex]; ok && now.Sub(last) < interval {
+		return false, nil
+	}
+
+	sent[keyHex] = now
+	for k, t := range sent {
+		if now.Sub(t) > sieve.MaxVacationDays*24*time.Hour {
+			delete(sent, k)
+		}
+	}
+	blob, err = json.Marshal(sent)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, blob, 0o600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
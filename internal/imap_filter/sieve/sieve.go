@@ -0,0 +1,220 @@
+package sieve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/sieve"
+	"github.com/dsoftgames/MailChat/internal/target"
+)
+
+const modName = "imap.filter.sieve"
+
+// Filter executes the active Sieve script of the account for each delivered
+// message.
+//
+// The message can be stored only into one mailbox, if the script requests
+// several copies only the first one is stored. Since the message is already
+// accepted at that point, discard and reject store the message into
+// discard_folder marked as \Deleted. Any script error causes the message to
+// be delivered to INBOX.
+type Filter struct {
+	instName string
+	log      log.Logger
+
+	store         sieve.Storage
+	vacationDir   string
+	target        module.DeliveryTarget
+	hostname      string
+	autogenMsg    string
+	discardFolder string
+
+	cacheLock sync.Mutex
+	cache     map[string]*cachedScript
+
+	vacationLock sync.Mutex
+}
+
+type cachedScript struct {
+	hash   [sha256.Size]byte
+	script *sieve.Script
+	err    error
+}
+
+func New(_, instName string, _, inlineArgs []string) (module.Module, error) {
+	if len(inlineArgs) != 0 {
+		return nil, errors.New("sieve: inline arguments are not used")
+	}
+	return &Filter{
+		instName: instName,
+		log:      log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+		cache:    map[string]*cachedScript{},
+	}, nil
+}
+
+func (f *Filter) Name() string {
+	return modName
+}
+
+func (f *Filter) InstanceName() string {
+	return f.instName
+}
+
+func (f *Filter) Init(cfg *config.Map) error {
+	var (
+		scriptsDir   string
+		scriptsTable module.Table
+	)
+
+	cfg.Bool("debug", true, log.DefaultLogger.Debug, &f.log.Debug)
+	cfg.String("hostname", true, true, "", &f.hostname)
+	cfg.String("autogenerated_msg_domain", true, false, "", &f.autogenMsg)
+	cfg.String("scripts_dir", false, false, "", &scriptsDir)
+	modconfig.Table(cfg, "scripts_table", false, false, nil, &scriptsTable)
+	cfg.String("vacation_dir", false, false, "sieve_vacation", &f.vacationDir)
+	cfg.String("discard_folder", false, false, "Trash", &f.discardFolder)
+	cfg.Custom("target", false, false, nil, modconfig.DeliveryDirective, &f.target)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	var err error
+	f.store, err = sieve.OpenStorage(scriptsDir, scriptsTable)
+	if err != nil {
+		return fmt.Errorf("%s: %w", modName, err)
+	}
+	if !filepath.IsAbs(f.vacationDir) {
+		f.vacationDir = filepath.Join(config.StateDirectory, f.vacationDir)
+	}
+	if err := os.MkdirAll(f.vacationDir, 0o700); err != nil {
+		return fmt.Errorf("%s: %w", modName, err)
+	}
+	if f.autogenMsg == "" {
+		f.autogenMsg = f.hostname
+	}
+
+	return nil
+}
+
+// script returns the compiled active script of the account, nil if there is
+// none. Compiled scripts are cached until the script is changed.
+func (f *Filter) script(accountName string) (*sieve.Script, error) {
+	_, src, err := f.store.Active(accountName)
+	if err != nil {
+		if errors.Is(err, sieve.ErrNoActiveScript) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(src))
+
+	f.cacheLock.Lock()
+	defer f.cacheLock.Unlock()
+
+	if cached, ok := f.cache[accountName]; ok && cached.hash == hash {
+		return cached.script, cached.err
+	}
+	script, err := sieve.Compile(src)
+	f.cache[accountName] = &cachedScript{
+		hash:   hash,
+		script: script,
+		err:    err,
+	}
+	return script, err
+}
+
+func (f *Filter) IMAPFilter(accountName string, rcptTo string, msgMeta *module.MsgMetadata, hdr textproto.Header, body buffer.Buffer) (folder string, flags []string, err error) {
+	script, err := f.script(accountName)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", modName, err)
+	}
+	if script == nil {
+		return "", nil, nil
+	}
+
+	var hdrBuf bytes.Buffer
+	if err := textproto.WriteHeader(&hdrBuf, hdr); err != nil {
+		return "", nil, err
+	}
+	res, err := script.Execute(sieve.Message{
+		Header:       hdr,
+		Size:         hdrBuf.Len() + body.Len(),
+		EnvelopeFrom: msgMeta.OriginalFrom,
+		EnvelopeTo:   rcptTo,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	dlog := target.DeliveryLogger(f.log, msgMeta)
+	resp := responder{
+		f:           f,
+		log:         dlog,
+		accountName: accountName,
+		rcptTo:      rcptTo,
+		msgMeta:     msgMeta,
+		hdr:         hdr,
+		body:        body,
+	}
+
+	looped := false
+	if len(res.Redirects) != 0 {
+		rcpts := resp.redirectTargets(res.Redirects)
+		looped = len(rcpts) == 0
+		if !looped {
+			// Failed redirect results in the message being stored into
+			// INBOX, so it is not lost.
+			if err := resp.redirect(rcpts); err != nil {
+				return "", nil, err
+			}
+			dlog.Msg("message redirected", "rcpt", rcptTo, "to", rcpts)
+		}
+	}
+	if res.Rejected {
+		if err := resp.reject(res.RejectReason); err != nil {
+			return "", nil, err
+		}
+		dlog.Msg("message rejected", "rcpt", rcptTo)
+	}
+	if res.Vacation != nil {
+		if err := resp.vacation(res.Vacation); err != nil {
+			dlog.Error("failed to send vacation response", err, "rcpt", rcptTo)
+		}
+	}
+
+	if len(res.Deliveries) == 0 {
+		if looped {
+			// Keep the message that was not redirected anywhere, so it is
+			// not lost.
+			return "", nil, nil
+		}
+		dlog.DebugMsg("message discarded", "rcpt", rcptTo, "folder", f.discardFolder)
+		return f.discardFolder, []string{"\\Seen", "\\Deleted"}, nil
+	}
+	if len(res.Deliveries) > 1 {
+		dlog.Msg("only one copy of the message can be stored, ignoring other mailboxes",
+			"rcpt", rcptTo, "folder", res.Deliveries[0].Mailbox)
+	}
+	delivery := res.Deliveries[0]
+	dlog.DebugMsg("sieve result", "rcpt", rcptTo, "folder", delivery.Mailbox, "flags", delivery.Flags)
+	if delivery.Mailbox == sieve.InboxMailbox {
+		return "", delivery.Flags, nil
+	}
+	return delivery.Mailbox, delivery.Flags, nil
+}
+
+func init() {
+	module.Register(modName, New)
+}
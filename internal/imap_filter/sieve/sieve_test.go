@@ -0,0 +1,210 @@
+package sieve
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+const testMsg = "From: Alice <alice@example.org>\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: Hello\r\n" +
+	"Message-Id: <1@example.org>\r\n" +
+	"\r\n"
+
+func testFilter(t *testing.T) (*Filter, *testutils.Target) {
+	t.Helper()
+
+	mod, err := New("", "test", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := mod.(*Filter)
+	f.log = testutils.Logger(t, modName)
+	err = f.Init(config.NewMap(map[string]interface{}{"hostname": "mx.example.com"}, config.Node{
+		Children: []config.Node{
+			{Name: "scripts_dir", Args: []string{t.TempDir()}},
+			{Name: "vacation_dir", Args: []string{t.TempDir()}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt := &testutils.Target{}
+	f.target = tgt
+	return f, tgt
+}
+
+func putScript(t *testing.T, f *Filter, src string) {
+	t.Helper()
+	if err := f.store.Put("bob@example.com", "main", src); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.store.SetActive("bob@example.com", "main"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func filter(t *testing.T, f *Filter, mailFrom string) (string, []string, error) {
+	t.Helper()
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(testMsg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.IMAPFilter("bob@example.com", "bob@example.com", &module.MsgMetadata{
+		ID:           "test",
+		OriginalFrom: mailFrom,
+	}, hdr, buffer.MemoryBuffer{Slice: []byte("Hello!\r\n")})
+}
+
+func TestFilter(t *testing.T) {
+	f, _ := testFilter(t)
+
+	folder, flags, err := filter(t, f, "alice@example.org")
+	if err != nil || folder != "" || len(flags) != 0 {
+		t.Fatalf("wrong result without a script: %q %v %v", folder, flags, err)
+	}
+
+	putScript(t, f, `require ["fileinto", "imap4flags"];
+		if address :domain "from" "example.org" { fileinto :flags "\\Flagged" "Work"; }`)
+	folder, flags, err = filter(t, f, "alice@example.org")
+	if err != nil || folder != "Work" || len(flags) != 1 || flags[0] != "\\Flagged" {
+		t.Fatalf("wrong result: %q %v %v", folder, flags, err)
+	}
+
+	// Changed script is recompiled.
+	putScript(t, f, `discard;`)
+	folder, flags, err = filter(t, f, "alice@example.org")
+	if err != nil || folder != "Trash" || len(flags) != 2 {
+		t.Fatalf("wrong result for discard: %q %v %v", folder, flags, err)
+	}
+}
+
+func TestFilter_BrokenScript(t *testing.T) {
+	f, _ := testFilter(t)
+	putScript(t, f, `keep;`)
+
+	if err := f.store.Put("bob@example.com", "main", `fileinto "Work";`); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := filter(t, f, "alice@example.org"); err == nil {
+		t.Fatal("expected error for a broken script")
+	}
+}
+
+func TestFilter_Vacation(t *testing.T) {
+	f, tgt := testFilter(t)
+	putScript(t, f, `require "vacation"; vacation :days 3 "I'm away.";`)
+
+	folder, _, err := filter(t, f, "alice@example.org")
+	if err != nil || folder != "" {
+		t.Fatalf("wrong result: %q %v", folder, err)
+	}
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("expected 1 response, got %d", len(tgt.Messages))
+	}
+	msg := tgt.Messages[0]
+	if msg.MailFrom != "" || len(msg.RcptTo) != 1 || msg.RcptTo[0] != "alice@example.org" {
+		t.Errorf("wrong envelope: %q %v", msg.MailFrom, msg.RcptTo)
+	}
+	if msg.Header.Get("Subject") != "Auto: Hello" || msg.Header.Get("In-Reply-To") != "<1@example.org>" ||
+		!strings.HasPrefix(msg.Header.Get("Auto-Submitted"), "auto-replied") {
+		t.Errorf("wrong response header: %v", msg.Header)
+	}
+	if string(msg.Body) != "I'm away." {
+		t.Errorf("wrong response body: %q", msg.Body)
+	}
+
+	// Only one response within the interval.
+	if _, _, err := filter(t, f, "alice@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	// No responses to the null sender.
+	if _, _, err := filter(t, f, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(tgt.Messages) != 1 {
+		t.Errorf("expected 1 response, got %d", len(tgt.Messages))
+	}
+}
+
+func TestFilter_RedirectReject(t *testing.T) {
+	f, tgt := testFilter(t)
+	putScript(t, f, `redirect "carol@example.net";`)
+
+	folder, flags, err := filter(t, f, "alice@example.org")
+	if err != nil || folder != "Trash" || len(flags) != 2 {
+		t.Fatalf("wrong result: %q %v %v", folder, flags, err)
+	}
+	if len(tgt.Messages) != 1 || tgt.Messages[0].MailFrom != "alice@example.org" ||
+		tgt.Messages[0].RcptTo[0] != "carol@example.net" {
+		t.Fatalf("wrong redirected message: %+v", tgt.Messages)
+	}
+
+	putScript(t, f, `require "reject"; reject "Not interested.";`)
+	if _, _, err := filter(t, f, "alice@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tgt.Messages) != 2 {
+		t.Fatalf("expected a rejection, got %d messages", len(tgt.Messages))
+	}
+	mdn := tgt.Messages[1]
+	if mdn.MailFrom != "" || !strings.Contains(mdn.Header.Get("Content-Type"), "disposition-notification") ||
+		!strings.Contains(string(mdn.Body), "Not interested.") {
+		t.Errorf("wrong rejection: %v\n%s", mdn.Header, mdn.Body)
+	}
+
+	// Message is kept if the redirect fails.
+	f.target = nil
+	putScript(t, f, `redirect "carol@example.net";`)
+	if _, _, err := filter(t, f, "alice@example.org"); err == nil {
+		t.Error("expected error without target")
+	}
+}
+
+func TestFilter_RedirectLoop(t *testing.T) {
+	f, tgt := testFilter(t)
+	putScript(t, f, `redirect "carol@example.net"; redirect "dave@example.net";`)
+
+	// The message was redirected to bob@example.com by carol@example.net.
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(
+		"Resent-To: bob@example.com\r\n" +
+			"Resent-From: Carol <Carol@Example.net>\r\n" +
+			testMsg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, flags, err := f.IMAPFilter("bob@example.com", "bob@example.com", &module.MsgMetadata{
+		ID:           "test",
+		OriginalFrom: "alice@example.org",
+	}, hdr, buffer.MemoryBuffer{Slice: []byte("Hello!\r\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgt.Messages) != 1 || len(tgt.Messages[0].RcptTo) != 1 || tgt.Messages[0].RcptTo[0] != "dave@example.net" {
+		t.Fatalf("wrong redirected message: %+v", tgt.Messages)
+	}
+	if folder != "Trash" || len(flags) != 2 {
+		t.Fatalf("wrong result: %q %v", folder, flags)
+	}
+
+	// The message is kept if no redirects are left.
+	putScript(t, f, `redirect "carol@example.net";`)
+	folder, flags, err = f.IMAPFilter("bob@example.com", "bob@example.com", &module.MsgMetadata{
+		ID:           "test",
+		OriginalFrom: "alice@example.org",
+	}, hdr, buffer.MemoryBuffer{Slice: []byte("Hello!\r\n")})
+	if err != nil || folder != "" || len(flags) != 0 {
+		t.Fatalf("wrong result: %q %v %v", folder, flags, err)
+	}
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("looping message is redirected: %+v", tgt.Messages)
+	}
+}
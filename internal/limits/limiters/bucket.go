@@ -37,11 +37,13 @@ import (
 // A BucksetSet without a New function assigned is no-op: Take and TakeContext
 // always succeed and Release does nothing.
 type BucketSet struct {
-	// New function is used to construct underlying L instances.
+	// New function is used to construct underlying L instances. It gets
+	// the key of the bucket, e.g. to construct limiters with the state
+	// shared between server instances.
 	//
 	// It is safe to change it only when BucketSet is not used by any
 	// goroutine.
-	New func() L
+	New func(key string) L
 
 	// Time after which bucket is considered stale and can be removed from the
 	// set. For safe use with Rate limiter, it should be at least as twice as
@@ -57,7 +59,7 @@ type BucketSet struct {
 	}
 }
 
-func NewBucketSet(new_ func() L, reapInterval time.Duration, maxBuckets int) *BucketSet {
+func NewBucketSet(new_ func(key string) L, reapInterval time.Duration, maxBuckets int) *BucketSet {
 	return &BucketSet{
 		New:          new_,
 		ReapInterval: reapInterval,
@@ -109,7 +111,7 @@ func (r *BucketSet) take(key string) L {
 			r       L
 			lastUse time.Time
 		}{
-			r:       r.New(),
+			r:       r.New(key),
 			lastUse: time.Now(),
 		}
 		bucket = r.m[key]
@@ -0,0 +1,91 @@
+package limiters
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is the Store that keeps counters in process memory. It is used
+// for limits that need the Store interface when no shared store is
+// configured.
+type MemoryStore struct {
+	lck       sync.Mutex
+	counters  map[string]*memCounter
+	lastSweep time.Time
+}
+
+type memCounter struct {
+	value   int
+	expires time.Time
+}
+
+// memSweepInterval is the minimal interval between removals of expired
+// counters.
+const memSweepInterval = 1 * time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*memCounter)}
+}
+
+// incr increments the counter and returns it. The expired counter is reset
+// and gets the new expiry time. If refresh is true, the expiry time is
+// updated for the existing counter too.
+func (s *MemoryStore) incr(key string, ttl time.Duration, refresh bool) *memCounter {
+	now := time.Now()
+	if now.Sub(s.lastSweep) > memSweepInterval {
+		for k, c := range s.counters {
+			if !c.expires.After(now) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !c.expires.After(now) {
+		c = &memCounter{expires: now.Add(ttl)}
+		s.counters[key] = c
+	} else if refresh {
+		c.expires = now.Add(ttl)
+	}
+	c.value++
+	return c
+}
+
+func (s *MemoryStore) TakeRate(_ context.Context, key string, burst int, period time.Duration) (time.Duration, error) {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	c := s.incr(key, period, false)
+	if c.value <= burst {
+		return 0, nil
+	}
+	return time.Until(c.expires), nil
+}
+
+func (s *MemoryStore) Acquire(_ context.Context, key string, max int, ttl time.Duration) (bool, error) {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	c := s.incr(key, ttl, true)
+	if c.value <= max {
+		return true, nil
+	}
+	c.value--
+	return false, nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	if c, ok := s.counters[key]; ok && c.value > 0 {
+		c.value--
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
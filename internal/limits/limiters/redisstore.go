@@ -0,0 +1,258 @@
+package limiters
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisStore is the Store that keeps counters in the server speaking the
+// Redis protocol (RESP), such as Redis, Valkey or KeyDB.
+//
+// Only INCR, DECR and PEXPIRE commands are used, so any compatible server
+// works.
+type RedisStore struct {
+	Address  string
+	Password string
+	DB       int
+
+	// MaxIdle is the maximum number of idle connections kept open.
+	MaxIdle int
+
+	lck    sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+// redisDialTimeout is the timeout for connecting and authenticating.
+const redisDialTimeout = 5 * time.Second
+
+var errRedisClosed = errors.New("limiters: Redis store is closed")
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "limiters: redis: " + string(e)
+}
+
+type redisConn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (s *RedisStore) TakeRate(ctx context.Context, key string, burst int, period time.Duration) (time.Duration, error) {
+	now := time.Now()
+	window := now.UnixNano() / int64(period)
+	windowKey := key + ":" + strconv.FormatInt(window, 10)
+
+	replies, err := s.do(ctx,
+		[]string{"INCR", windowKey},
+		[]string{"PEXPIRE", windowKey, strconv.FormatInt((2 * period).Milliseconds(), 10)},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if replies[0] <= int64(burst) {
+		return 0, nil
+	}
+	return time.Unix(0, (window+1)*int64(period)).Sub(now), nil
+}
+
+func (s *RedisStore) Acquire(ctx context.Context, key string, max int, ttl time.Duration) (bool, error) {
+	replies, err := s.do(ctx,
+		[]string{"INCR", key},
+		[]string{"PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10)},
+	)
+	if err != nil {
+		return false, err
+	}
+	if replies[0] <= int64(max) {
+		return true, nil
+	}
+	return false, s.Release(ctx, key)
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	replies, err := s.do(ctx, []string{"DECR", key})
+	if err != nil {
+		return err
+	}
+	if replies[0] < 0 {
+		// The counter expired while the resource was in use.
+		_, err = s.do(ctx, []string{"INCR", key})
+	}
+	return err
+}
+
+// do sends the commands in a single round trip and returns their integer
+// replies.
+func (s *RedisStore) do(ctx context.Context, cmds ...[]string) ([]int64, error) {
+	conn, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.c.SetDeadline(deadline)
+	} else {
+		conn.c.SetDeadline(time.Time{})
+	}
+
+	replies := make([]int64, 0, len(cmds))
+	for _, cmd := range cmds {
+		conn.writeCommand(cmd)
+	}
+	if err := conn.w.Flush(); err != nil {
+		conn.c.Close()
+		return nil, err
+	}
+	// Error replies do not break the connection, all replies are read to
+	// keep it usable.
+	var cmdErr error
+	for range cmds {
+		reply, err := conn.readInt()
+		if err != nil {
+			if _, ok := err.(redisError); !ok {
+				conn.c.Close()
+				return nil, err
+			}
+			if cmdErr == nil {
+				cmdErr = err
+			}
+		}
+		replies = append(replies, reply)
+	}
+
+	s.put(conn)
+	if cmdErr != nil {
+		return nil, cmdErr
+	}
+	return replies, nil
+}
+
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	s.lck.Lock()
+	if s.closed {
+		s.lck.Unlock()
+		return nil, errRedisClosed
+	}
+	if len(s.idle) != 0 {
+		conn := s.idle[len(s.idle)-1]
+		s.idle = s.idle[:len(s.idle)-1]
+		s.lck.Unlock()
+		return conn, nil
+	}
+	s.lck.Unlock()
+
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	c, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	c.SetDeadline(time.Now().Add(redisDialTimeout))
+
+	var setup [][]string
+	if s.Password != "" {
+		setup = append(setup, []string{"AUTH", s.Password})
+	}
+	if s.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.DB)})
+	}
+	for _, cmd := range setup {
+		conn.writeCommand(cmd)
+	}
+	if err := conn.w.Flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	for range setup {
+		if err := conn.readOK(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (s *RedisStore) put(conn *redisConn) {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	maxIdle := s.MaxIdle
+	if maxIdle == 0 {
+		maxIdle = 8
+	}
+	if s.closed || len(s.idle) >= maxIdle {
+		conn.c.Close()
+		return
+	}
+	s.idle = append(s.idle, conn)
+}
+
+func (s *RedisStore) Close() error {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	s.closed = true
+	for _, conn := range s.idle {
+		conn.c.Close()
+	}
+	s.idle = nil
+	return nil
+}
+
+func (c *redisConn) writeCommand(args []string) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("limiters: redis: malformed reply: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+func (c *redisConn) readInt() (int64, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return 0, err
+	}
+	switch line[0] {
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '-':
+		return 0, redisError(line[1:])
+	default:
+		return 0, fmt.Errorf("limiters: redis: unexpected reply: %q", line)
+	}
+}
+
+func (c *redisConn) readOK() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	switch line[0] {
+	case '+':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	default:
+		return fmt.Errorf("limiters: redis: unexpected reply: %q", line)
+	}
+}
@@ -0,0 +1,197 @@
+package limiters
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps the state of limiters shared between multiple server
+// instances.
+type Store interface {
+	// TakeRate takes a token from the bucket that gets burst tokens every
+	// period. If the bucket is empty, the time until it is refilled is
+	// returned.
+	TakeRate(ctx context.Context, key string, burst int, period time.Duration) (time.Duration, error)
+
+	// Acquire increments the counter if it is lower than max. The counter
+	// is reset if it is not acquired for ttl so counters left by stopped
+	// instances do not block the resource forever.
+	Acquire(ctx context.Context, key string, max int, ttl time.Duration) (bool, error)
+
+	// Release decrements the counter incremented by Acquire.
+	Release(ctx context.Context, key string) error
+
+	Close() error
+}
+
+const (
+	// storeTimeout is the timeout for Store operations that are not bound
+	// to a context.
+	storeTimeout = 5 * time.Second
+
+	// maxPollInterval is the maximum interval between Acquire calls for a
+	// busy SharedSemaphore.
+	maxPollInterval = 1 * time.Second
+)
+
+// storeCallTimeout limits each Store call made on behalf of TakeContext, so
+// an unresponsive store results in Fallback being used instead of blocking
+// the caller until its own deadline.
+var storeCallTimeout = 1 * time.Second
+
+// SharedRate is the rate limiter similar to Rate that uses the state in the
+// Store.
+//
+// Fallback is used instead of the store if it fails, so the limits are
+// applied by each instance separately while the store is not available.
+//
+// If Burst = 0, all methods are no-op and always succeed.
+type SharedRate struct {
+	Store    Store
+	Key      string
+	Burst    int
+	Period   time.Duration
+	Fallback L
+
+	// Report, if set, is called with the result of each Store operation.
+	Report func(error)
+}
+
+func (r *SharedRate) Take() bool {
+	return r.TakeContext(context.Background()) == nil
+}
+
+func (r *SharedRate) TakeContext(ctx context.Context) error {
+	if r.Burst == 0 {
+		return nil
+	}
+
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, storeCallTimeout)
+		wait, err := r.Store.TakeRate(callCtx, r.Key, r.Burst, r.Period)
+		cancel()
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report(r.Report, err)
+		if err != nil {
+			return r.Fallback.TakeContext(ctx)
+		}
+		if wait <= 0 {
+			return nil
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *SharedRate) Release() {
+}
+
+func (r *SharedRate) Close() {
+	r.Fallback.Close()
+}
+
+// SharedSemaphore is the concurrency limiter similar to Semaphore that uses
+// the state in the Store.
+//
+// Fallback is used instead of the store if it fails, same as for
+// SharedRate.
+//
+// If Max is negative or zero, all methods are no-op.
+type SharedSemaphore struct {
+	Store    Store
+	Key      string
+	Max      int
+	TTL      time.Duration
+	Fallback L
+
+	// Report, if set, is called with the result of each Store operation.
+	Report func(error)
+
+	// fallbackTaken is the amount of Take calls served by Fallback that are
+	// not released yet.
+	lck           sync.Mutex
+	fallbackTaken int
+}
+
+func (s *SharedSemaphore) Take() bool {
+	return s.TakeContext(context.Background()) == nil
+}
+
+func (s *SharedSemaphore) TakeContext(ctx context.Context) error {
+	if s.Max <= 0 {
+		return nil
+	}
+
+	poll := 50 * time.Millisecond
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, storeCallTimeout)
+		ok, err := s.Store.Acquire(callCtx, s.Key, s.Max, s.TTL)
+		cancel()
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report(s.Report, err)
+		if err != nil {
+			if err := s.Fallback.TakeContext(ctx); err != nil {
+				return err
+			}
+			s.lck.Lock()
+			s.fallbackTaken++
+			s.lck.Unlock()
+			return nil
+		}
+		if ok {
+			return nil
+		}
+
+		t := time.NewTimer(poll)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		if poll *= 2; poll > maxPollInterval {
+			poll = maxPollInterval
+		}
+	}
+}
+
+func (s *SharedSemaphore) Release() {
+	if s.Max <= 0 {
+		return
+	}
+
+	s.lck.Lock()
+	if s.fallbackTaken > 0 {
+		s.fallbackTaken--
+		s.lck.Unlock()
+		s.Fallback.Release()
+		return
+	}
+	s.lck.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	// If the counter can't be decremented, it is reset after TTL.
+	report(s.Report, s.Store.Release(ctx, s.Key))
+}
+
+func (s *SharedSemaphore) Close() {
+	s.Fallback.Close()
+}
+
+func report(f func(error), err error) {
+	if f != nil {
+		f(err)
+	}
+}
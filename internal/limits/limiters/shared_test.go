@@ -0,0 +1,276 @@
+package limiters
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redisStandIn is a minimal server implementing the subset of Redis
+// commands used by RedisStore.
+type redisStandIn struct {
+	l net.Listener
+
+	lck      sync.Mutex
+	counters map[string]int64
+}
+
+func newRedisStandIn(t *testing.T) *redisStandIn {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &redisStandIn{l: l, counters: make(map[string]int64)}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(c)
+		}
+	}()
+	return srv
+}
+
+func (srv *redisStandIn) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var l int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &l); err != nil {
+				return
+			}
+			buf := make([]byte, l+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:l])
+		}
+
+		srv.lck.Lock()
+		var reply string
+		switch args[0] {
+		case "INCR":
+			srv.counters[args[1]]++
+			reply = ":" + strconv.FormatInt(srv.counters[args[1]], 10)
+		case "DECR":
+			srv.counters[args[1]]--
+			reply = ":" + strconv.FormatInt(srv.counters[args[1]], 10)
+		case "PEXPIRE":
+			reply = ":1"
+		case "AUTH":
+			if args[1] == "secret" {
+				reply = "+OK"
+			} else {
+				reply = "-WRONGPASS invalid password"
+			}
+		default:
+			reply = "-ERR unknown command"
+		}
+		srv.lck.Unlock()
+
+		if _, err := c.Write([]byte(reply + "\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		wait, err := store.TakeRate(ctx, "rate", 3, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != 0 {
+			t.Fatal("rate limited before the burst is used")
+		}
+	}
+	wait, err := store.TakeRate(ctx, "rate", 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > time.Hour {
+		t.Fatal("unexpected wait time:", wait)
+	}
+
+	for i := 0; i < 2; i++ {
+		ok, err := store.Acquire(ctx, "conc", 2, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("failed to acquire below the limit")
+		}
+	}
+	if ok, err := store.Acquire(ctx, "conc", 2, time.Hour); err != nil || ok {
+		t.Fatal("acquired above the limit:", ok, err)
+	}
+	if err := store.Release(ctx, "conc"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Acquire(ctx, "conc", 2, time.Hour); err != nil || !ok {
+		t.Fatal("failed to acquire after release:", ok, err)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	srv := newRedisStandIn(t)
+	store := &RedisStore{Address: srv.l.Addr().String(), Password: "secret"}
+	defer store.Close()
+	testStore(t, store)
+
+	wrongPass := &RedisStore{Address: srv.l.Addr().String(), Password: "wrong"}
+	defer wrongPass.Close()
+	if _, err := wrongPass.Acquire(context.Background(), "conc", 1, time.Hour); err == nil {
+		t.Fatal("no error for the wrong password")
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	if !hasDriver("sqlite3") {
+		t.Skip("sqlite3 driver is not available")
+	}
+	store, err := NewSQLStore("sqlite3", filepath.Join(t.TempDir(), "limits.db"), "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testStore(t, store)
+}
+
+func hasDriver(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+type failingStore struct{}
+
+var errStoreDown = errors.New("store is down")
+
+func (failingStore) TakeRate(context.Context, string, int, time.Duration) (time.Duration, error) {
+	return 0, errStoreDown
+}
+
+func (failingStore) Acquire(context.Context, string, int, time.Duration) (bool, error) {
+	return false, errStoreDown
+}
+
+func (failingStore) Release(context.Context, string) error {
+	return errStoreDown
+}
+
+func (failingStore) Close() error {
+	return nil
+}
+
+func TestSharedSemaphore_Fallback(t *testing.T) {
+	var reported []error
+	s := &SharedSemaphore{
+		Store:    failingStore{},
+		Key:      "conc",
+		Max:      1,
+		TTL:      time.Hour,
+		Fallback: NewSemaphore(1),
+		Report:   func(err error) { reported = append(reported, err) },
+	}
+
+	if !s.Take() {
+		t.Fatal("Take failed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.TakeContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("fallback limit is not applied:", err)
+	}
+	s.Release()
+	if !s.Take() {
+		t.Fatal("Take after Release failed")
+	}
+	if len(reported) == 0 || !errors.Is(reported[0], errStoreDown) {
+		t.Fatal("store error is not reported")
+	}
+}
+
+// hangingStore never responds and returns only once the context is done.
+type hangingStore struct{}
+
+func (hangingStore) TakeRate(ctx context.Context, _ string, _ int, _ time.Duration) (time.Duration, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func (hangingStore) Acquire(ctx context.Context, _ string, _ int, _ time.Duration) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (hangingStore) Release(ctx context.Context, _ string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (hangingStore) Close() error {
+	return nil
+}
+
+func TestShared_HangingStore(t *testing.T) {
+	defer func(timeout time.Duration) { storeCallTimeout = timeout }(storeCallTimeout)
+	storeCallTimeout = 50 * time.Millisecond
+
+	var reported []error
+	r := &SharedRate{
+		Store:    hangingStore{},
+		Key:      "rate",
+		Burst:    1,
+		Period:   time.Hour,
+		Fallback: NewRate(1, time.Hour),
+		Report:   func(err error) { reported = append(reported, err) },
+	}
+	defer r.Close()
+	s := &SharedSemaphore{
+		Store:    hangingStore{},
+		Key:      "conc",
+		Max:      1,
+		TTL:      time.Hour,
+		Fallback: NewSemaphore(1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.TakeContext(ctx); err != nil {
+		t.Fatal("SharedRate: fallback is not used:", err)
+	}
+	if err := s.TakeContext(ctx); err != nil {
+		t.Fatal("SharedSemaphore: fallback is not used:", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("store calls are not limited by the timeout")
+	}
+	if len(reported) == 0 || !errors.Is(reported[0], context.DeadlineExceeded) {
+		t.Fatal("store error is not reported")
+	}
+}
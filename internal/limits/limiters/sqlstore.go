@@ -0,0 +1,164 @@
+package limiters
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLStore is the Store that keeps counters in the SQL database table.
+//
+// Supported drivers are postgres and sqlite3, the driver should be
+// registered by the caller.
+type SQLStore struct {
+	db *sql.DB
+
+	takeRate *sql.Stmt
+	acquire  *sql.Stmt
+	release  *sql.Stmt
+	cleanup  *sql.Stmt
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+// sqlCleanupInterval is the interval between removals of expired counters.
+const sqlCleanupInterval = 1 * time.Minute
+
+func NewSQLStore(driver, dsn, table string) (*SQLStore, error) {
+	if driver != "postgres" && driver != "sqlite3" {
+		return nil, fmt.Errorf("limiters: unsupported SQL driver: %s", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// Avoid "database is locked" errors from concurrent writes.
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &SQLStore{db: db, stop: make(chan struct{})}
+	if err := s.prepare(driver, table); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s.done.Add(1)
+	go s.cleanupLoop()
+	return s, nil
+}
+
+func (s *SQLStore) prepare(driver, table string) error {
+	// Timestamps are Unix time in milliseconds.
+	_, err := s.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		key TEXT PRIMARY KEY NOT NULL,
+		value BIGINT NOT NULL,
+		expires BIGINT NOT NULL
+	)`, table))
+	if err != nil {
+		return fmt.Errorf("limiters: create table: %w", err)
+	}
+
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		// The counter of the expired window is reset, otherwise it is
+		// incremented keeping the window end.
+		{&s.takeRate, `INSERT INTO %[1]s(key, value, expires) VALUES($1, 1, $2)
+			ON CONFLICT(key) DO UPDATE SET
+				value = CASE WHEN %[1]s.expires <= $3 THEN 1 ELSE %[1]s.value + 1 END,
+				expires = CASE WHEN %[1]s.expires <= $3 THEN excluded.expires ELSE %[1]s.expires END
+			RETURNING value, expires`},
+		{&s.acquire, `INSERT INTO %[1]s(key, value, expires) VALUES($1, 1, $2)
+			ON CONFLICT(key) DO UPDATE SET
+				value = CASE WHEN %[1]s.expires <= $3 THEN 1 ELSE %[1]s.value + 1 END,
+				expires = excluded.expires
+			RETURNING value, expires`},
+		{&s.release, `UPDATE %[1]s SET value = value - 1 WHERE key = $1 AND value > 0`},
+		{&s.cleanup, `DELETE FROM %[1]s WHERE expires <= $1`},
+	}
+	for _, q := range queries {
+		query := fmt.Sprintf(q.query, table)
+		if driver == "sqlite3" {
+			// SQLite uses ?NNN for numbered parameters.
+			query = strings.ReplaceAll(query, "$", "?")
+		}
+		stmt, err := s.db.Prepare(query)
+		if err != nil {
+			return fmt.Errorf("limiters: prepare query: %w", err)
+		}
+		*q.stmt = stmt
+	}
+	return nil
+}
+
+func (s *SQLStore) upsert(ctx context.Context, stmt *sql.Stmt, key string, ttl time.Duration) (int, time.Time, error) {
+	now := time.Now()
+	var (
+		value   int
+		expires int64
+	)
+	err := stmt.QueryRowContext(ctx, key, now.Add(ttl).UnixMilli(), now.UnixMilli()).Scan(&value, &expires)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return value, time.UnixMilli(expires), nil
+}
+
+func (s *SQLStore) TakeRate(ctx context.Context, key string, burst int, period time.Duration) (time.Duration, error) {
+	value, expires, err := s.upsert(ctx, s.takeRate, key, period)
+	if err != nil {
+		return 0, err
+	}
+	if value <= burst {
+		return 0, nil
+	}
+	return time.Until(expires), nil
+}
+
+func (s *SQLStore) Acquire(ctx context.Context, key string, max int, ttl time.Duration) (bool, error) {
+	value, _, err := s.upsert(ctx, s.acquire, key, ttl)
+	if err != nil {
+		return false, err
+	}
+	if value <= max {
+		return true, nil
+	}
+	return false, s.Release(ctx, key)
+}
+
+func (s *SQLStore) Release(ctx context.Context, key string) error {
+	_, err := s.release.ExecContext(ctx, key)
+	return err
+}
+
+func (s *SQLStore) cleanupLoop() {
+	defer s.done.Done()
+
+	t := time.NewTicker(sqlCleanupInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			// Errors are not important, the next run will try again.
+			_, _ = s.cleanup.Exec(time.Now().UnixMilli())
+		}
+	}
+}
+
+func (s *SQLStore) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.done.Wait()
+	})
+	return s.db.Close()
+}
@@ -30,50 +30,117 @@ import (
 	"context"
 	"net"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/log"
 	"github.com/dsoftgames/MailChat/framework/module"
 	"github.com/dsoftgames/MailChat/internal/limits/limiters"
 )
 
 type Group struct {
 	instName string
+	log      log.Logger
 
 	global limiters.MultiLimit
 	ip     *limiters.BucketSet // BucketSet of MultiLimit
 	source *limiters.BucketSet // BucketSet of MultiLimit
 	dest   *limiters.BucketSet // BucketSet of MultiLimit
+
+	store      limiters.Store
+	keyPrefix  string
+	storeError atomic.Bool
+
+	// user is nil if no per-user limits are configured.
+	user *userLimits
+	// userStore is the store if configured, in-memory store otherwise.
+	userStore limiters.Store
 }
 
 func New(_, instName string, _, _ []string) (module.Module, error) {
 	return &Group{
 		instName: instName,
+		log:      log.Logger{Name: "limits"},
 	}, nil
 }
 
+// limitCtor creates the limiter for the bucket with the key.
+type limitCtor func(key string) limiters.L
+
 func (g *Group) Init(cfg *config.Map) error {
 	var (
 		globalL []limiters.L
-		ipL     []func() limiters.L
-		sourceL []func() limiters.L
-		destL   []func() limiters.L
+		ipL     []limitCtor
+		sourceL []limitCtor
+		destL   []limitCtor
 	)
 
+	// The store should be known before limiters are created.
+	for _, child := range cfg.Block.Children {
+		if child.Name != "store" {
+			continue
+		}
+		if g.store != nil {
+			return config.NodeErr(child, "duplicate store directive")
+		}
+		var err error
+		g.store, g.keyPrefix, err = storeFromNode(cfg.Globals, child)
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		user         = &userLimits{}
+		userExceeded bool
+	)
 	for _, child := range cfg.Block.Children {
+		switch child.Name {
+		case "store":
+			continue
+		case "user":
+			l, err := parseUserLimit(child, child.Args)
+			if err != nil {
+				return err
+			}
+			user.limits = append(user.limits, l)
+			continue
+		case "user_overrides":
+			tbl, err := modconfig.TableDirective(cfg, child)
+			if err != nil {
+				return err
+			}
+			user.overrides = tbl.(module.Table)
+			continue
+		case "local_domains":
+			user.localDomains = make(map[string]struct{}, len(child.Args))
+			for _, domain := range child.Args {
+				user.localDomains[strings.ToLower(domain)] = struct{}{}
+			}
+			continue
+		case "on_user_exceeded":
+			userExceeded = true
+			if err := user.parseExceededBlock(cfg.Globals, child); err != nil {
+				return err
+			}
+			continue
+		}
 		if len(child.Args) < 1 {
 			return config.NodeErr(child, "at least two arguments are required")
 		}
 
 		var (
-			ctor func() limiters.L
+			ctor limitCtor
 			err  error
 		)
 		switch kind := child.Args[0]; kind {
 		case "rate":
-			ctor, err = rateCtor(child, child.Args[1:])
+			ctor, err = g.rateCtor(child, child.Args[1:])
 		case "concurrency":
-			ctor, err = concurrencyCtor(child, child.Args[1:])
+			ctor, err = g.concurrencyCtor(child, child.Args[1:])
 		default:
 			return config.NodeErr(child, "unknown limit kind: %v", kind)
 		}
@@ -83,7 +150,7 @@ func (g *Group) Init(cfg *config.Map) error {
 
 		switch scope := child.Name; scope {
 		case "all":
-			globalL = append(globalL, ctor())
+			globalL = append(globalL, ctor(""))
 		case "ip":
 			ipL = append(ipL, ctor)
 		case "source":
@@ -95,41 +162,64 @@ func (g *Group) Init(cfg *config.Map) error {
 		}
 	}
 
+	switch {
+	case len(user.limits) != 0:
+		g.user = user
+		g.userStore = g.store
+		if g.userStore == nil {
+			g.userStore = limiters.NewMemoryStore()
+		}
+	case user.overrides != nil || user.localDomains != nil || userExceeded:
+		return config.NodeErr(cfg.Block, "user_overrides, local_domains and on_user_exceeded require user limits")
+	}
+
 	// 20010 is slightly higher than the default max. recipients count in
 	// endpoint/smtp.
 	g.global = limiters.MultiLimit{Wrapped: globalL}
 	if len(ipL) != 0 {
-		g.ip = limiters.NewBucketSet(func() limiters.L {
-			l := make([]limiters.L, 0, len(ipL))
-			for _, ctor := range ipL {
-				l = append(l, ctor())
-			}
-			return &limiters.MultiLimit{Wrapped: l}
-		}, 1*time.Minute, 20010)
+		g.ip = limiters.NewBucketSet(multiCtor(ipL), 1*time.Minute, 20010)
 	}
 	if len(sourceL) != 0 {
-		g.source = limiters.NewBucketSet(func() limiters.L {
-			l := make([]limiters.L, 0, len(sourceL))
-			for _, ctor := range sourceL {
-				l = append(l, ctor())
-			}
-			return &limiters.MultiLimit{Wrapped: l}
-		}, 1*time.Minute, 20010)
+		g.source = limiters.NewBucketSet(multiCtor(sourceL), 1*time.Minute, 20010)
 	}
 	if len(destL) != 0 {
-		g.dest = limiters.NewBucketSet(func() limiters.L {
-			l := make([]limiters.L, 0, len(sourceL))
-			for _, ctor := range sourceL {
-				l = append(l, ctor())
-			}
-			return &limiters.MultiLimit{Wrapped: l}
-		}, 1*time.Minute, 20010)
+		g.dest = limiters.NewBucketSet(multiCtor(destL), 1*time.Minute, 20010)
 	}
 
 	return nil
 }
 
-func rateCtor(node config.Node, args []string) (func() limiters.L, error) {
+func multiCtor(ctors []limitCtor) func(key string) limiters.L {
+	return func(key string) limiters.L {
+		l := make([]limiters.L, 0, len(ctors))
+		for _, ctor := range ctors {
+			l = append(l, ctor(key))
+		}
+		return &limiters.MultiLimit{Wrapped: l}
+	}
+}
+
+// storeKey returns the key of the bucket in the shared store. Limits are
+// identified by their definition so changed limits do not use the state of
+// old ones.
+func (g *Group) storeKey(node config.Node, key string) string {
+	return g.keyPrefix + node.Name + "/" + strings.Join(node.Args, " ") + "/" + key
+}
+
+// reportStore logs changes of the store availability.
+func (g *Group) reportStore(err error) {
+	if err != nil {
+		if !g.storeError.Swap(true) {
+			g.log.Error("store is not available, limits are applied by this instance only", err)
+		}
+		return
+	}
+	if g.storeError.Swap(false) {
+		g.log.Msg("store is available again")
+	}
+}
+
+func (g *Group) rateCtor(node config.Node, args []string) (limitCtor, error) {
 	period := 1 * time.Second
 	burst := 0
 
@@ -153,12 +243,24 @@ func rateCtor(node config.Node, args []string) (func() limiters.L, error) {
 		return nil, config.NodeErr(node, "too many arguments")
 	}
 
-	return func() limiters.L {
-		return limiters.NewRate(burst, period)
+	if g.store == nil {
+		return func(string) limiters.L {
+			return limiters.NewRate(burst, period)
+		}, nil
+	}
+	return func(key string) limiters.L {
+		return &limiters.SharedRate{
+			Store:    g.store,
+			Key:      g.storeKey(node, key),
+			Burst:    burst,
+			Period:   period,
+			Fallback: limiters.NewRate(burst, period),
+			Report:   g.reportStore,
+		}
 	}, nil
 }
 
-func concurrencyCtor(node config.Node, args []string) (func() limiters.L, error) {
+func (g *Group) concurrencyCtor(node config.Node, args []string) (limitCtor, error) {
 	if len(args) != 1 {
 		return nil, config.NodeErr(node, "max concurrency value is needed")
 	}
@@ -166,8 +268,21 @@ func concurrencyCtor(node config.Node, args []string) (func() limiters.L, error)
 	if err != nil {
 		return nil, config.NodeErr(node, "%v", err)
 	}
-	return func() limiters.L {
-		return limiters.NewSemaphore(max)
+
+	if g.store == nil {
+		return func(string) limiters.L {
+			return limiters.NewSemaphore(max)
+		}, nil
+	}
+	return func(key string) limiters.L {
+		return &limiters.SharedSemaphore{
+			Store:    g.store,
+			Key:      g.storeKey(node, key),
+			Max:      max,
+			TTL:      concurrencyTTL,
+			Fallback: limiters.NewSemaphore(max),
+			Report:   g.reportStore,
+		}
 	}, nil
 }
 
@@ -188,7 +303,9 @@ func (g *Group) TakeMsg(ctx context.Context, addr net.IP, sourceDomain string) e
 	if g.source != nil {
 		if err := g.source.TakeContext(ctx, sourceDomain); err != nil {
 			g.global.Release()
-			g.ip.Release(addr.String())
+			if g.ip != nil {
+				g.ip.Release(addr.String())
+			}
 			return err
 		}
 	}
@@ -221,6 +338,13 @@ func (g *Group) ReleaseDest(domain string) {
 	g.dest.Release(domain)
 }
 
+func (g *Group) Close() error {
+	if g.store != nil {
+		return g.store.Close()
+	}
+	return nil
+}
+
 func (g *Group) Name() string {
 	return "limits"
 }
@@ -0,0 +1,6 @@
+//go:build !nosqlite3 && cgo
+// +build !nosqlite3,cgo
+
+package limits
+
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,74 @@
+package limits
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/internal/limits/limiters"
+	_ "github.com/lib/pq"
+)
+
+// concurrencyTTL is the time after which concurrency counters in the shared
+// store are reset if they are not used. It limits the effect of counters left
+// by stopped server instances.
+const concurrencyTTL = 15 * time.Minute
+
+// storeFromNode creates the shared store from the store directive:
+//
+//	store sql {
+//	    driver postgres
+//	    dsn ...
+//	}
+//	store redis {
+//	    address 127.0.0.1:6379
+//	}
+//
+// It returns the store and the prefix of its keys.
+func storeFromNode(globals map[string]interface{}, node config.Node) (limiters.Store, string, error) {
+	if len(node.Args) != 1 {
+		return nil, "", config.NodeErr(node, "store type is required: sql or redis")
+	}
+
+	var (
+		store     limiters.Store
+		keyPrefix string
+	)
+	cfg := config.NewMap(globals, node)
+	cfg.String("key_prefix", false, false, "limits/", &keyPrefix)
+
+	switch node.Args[0] {
+	case "sql":
+		var (
+			driver    string
+			dsnParts  []string
+			tableName string
+		)
+		cfg.String("driver", false, true, "", &driver)
+		cfg.StringList("dsn", false, true, nil, &dsnParts)
+		cfg.String("table_name", false, false, "limits", &tableName)
+		if _, err := cfg.Process(); err != nil {
+			return nil, "", err
+		}
+
+		sqlStore, err := limiters.NewSQLStore(driver, strings.Join(dsnParts, " "), tableName)
+		if err != nil {
+			return nil, "", config.NodeErr(node, "%v", err)
+		}
+		store = sqlStore
+	case "redis":
+		redisStore := &limiters.RedisStore{}
+		cfg.String("address", false, true, "", &redisStore.Address)
+		cfg.String("password", false, false, "", &redisStore.Password)
+		cfg.Int("db", false, false, 0, &redisStore.DB)
+		cfg.Int("max_idle_conns", false, false, 8, &redisStore.MaxIdle)
+		if _, err := cfg.Process(); err != nil {
+			return nil, "", err
+		}
+		store = redisStore
+	default:
+		return nil, "", config.NodeErr(node, "unknown store type: %v", node.Args[0])
+	}
+
+	return store, keyPrefix, nil
+}
@@ -0,0 +1,373 @@
+package limits
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/authz"
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+)
+
+// Kinds of per-user limits.
+const (
+	userMessages         = "messages"
+	userRecipients       = "recipients"
+	userUniqueRecipients = "unique_recipients"
+)
+
+// userLimit is the limit of messages or recipients sent by the authenticated
+// user in the period.
+type userLimit struct {
+	kind   string
+	max    int
+	period time.Duration
+}
+
+func (l userLimit) String() string {
+	return l.kind + " " + strconv.Itoa(l.max) + " " + l.period.String()
+}
+
+// userLimits are the limits applied to the messages sent by authenticated
+// users.
+type userLimits struct {
+	limits       []userLimit
+	overrides    module.Table
+	localDomains map[string]struct{}
+
+	// suspend, if set, gets the users that exceeded limits. Messages of
+	// these users are rejected until they are removed from the table.
+	suspend     module.MutableTable
+	alertTo     []string
+	alertTarget module.DeliveryTarget
+	alertFrom   string
+	hostname    string
+}
+
+func parseUserLimit(node config.Node, args []string) (userLimit, error) {
+	if len(args) != 3 {
+		return userLimit{}, config.NodeErr(node, "expected: <kind> <max> <period>")
+	}
+
+	l := userLimit{kind: args[0]}
+	switch l.kind {
+	case userMessages, userRecipients, userUniqueRecipients:
+	default:
+		return userLimit{}, config.NodeErr(node, "unknown user limit kind: %v", l.kind)
+	}
+
+	var err error
+	l.max, err = strconv.Atoi(args[1])
+	if err != nil {
+		return userLimit{}, config.NodeErr(node, "%v", err)
+	}
+	l.period, err = time.ParseDuration(args[2])
+	if err != nil {
+		return userLimit{}, config.NodeErr(node, "%v", err)
+	}
+	if l.period <= 0 {
+		return userLimit{}, config.NodeErr(node, "period should be positive")
+	}
+	return l, nil
+}
+
+// parseOverride parses the value of the user_overrides table, it is the list
+// of limits separated by semicolons, e.g. "messages 1000 24h; recipients
+// 5000 24h". The limit with max 0 is not applied.
+func parseOverride(val string) ([]userLimit, error) {
+	var limits []userLimit
+	for _, part := range strings.Split(val, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		l, err := parseUserLimit(config.Node{Name: "user_overrides"}, fields)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	return limits, nil
+}
+
+func (u *userLimits) parseExceededBlock(globals map[string]interface{}, node config.Node) error {
+	var suspend module.Table
+
+	cfg := config.NewMap(globals, node)
+	modconfig.Table(cfg, "suspend", false, false, nil, &suspend)
+	cfg.StringList("alert", false, false, nil, &u.alertTo)
+	cfg.Custom("alert_target", false, false, nil, modconfig.DeliveryDirective, &u.alertTarget)
+	cfg.String("hostname", true, false, "", &u.hostname)
+	cfg.String("alert_from", false, false, "", &u.alertFrom)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if suspend != nil {
+		mtbl, ok := suspend.(module.MutableTable)
+		if !ok {
+			return config.NodeErr(node, "suspend table should be a mutable table")
+		}
+		u.suspend = mtbl
+	}
+	if len(u.alertTo) != 0 {
+		if u.alertTarget == nil {
+			return config.NodeErr(node, "alert_target is required to send alerts")
+		}
+		if u.alertFrom == "" {
+			if u.hostname == "" {
+				return config.NodeErr(node, "alert_from or hostname is required to send alerts")
+			}
+			u.alertFrom = "MAILER-DAEMON@" + u.hostname
+		}
+	}
+	return nil
+}
+
+// normalizeUser returns the username used as the key for limits, overrides
+// and the suspend table, so case variants of the same username share them.
+func normalizeUser(user string) string {
+	norm, err := authz.NormalizeAuto(user)
+	if err != nil {
+		return strings.ToLower(user)
+	}
+	return norm
+}
+
+func (g *Group) userKey(l userLimit, user string) string {
+	return g.keyPrefix + "user/" + l.kind + "/" + l.period.String() + "/" + user
+}
+
+func (g *Group) limitsFor(ctx context.Context, user string) []userLimit {
+	if g.user.overrides == nil {
+		return g.user.limits
+	}
+
+	val, ok, err := g.user.overrides.Lookup(ctx, user)
+	if err != nil {
+		g.log.Error("user_overrides lookup failed, using default limits", err, "user", user)
+		return g.user.limits
+	}
+	if !ok {
+		return g.user.limits
+	}
+	overrides, err := parseOverride(val)
+	if err != nil {
+		g.log.Error("malformed user_overrides entry, using default limits", err, "user", user)
+		return g.user.limits
+	}
+
+	// Overrides replace default limits of the same kind.
+	overridden := make(map[string]bool)
+	limits := make([]userLimit, 0, len(g.user.limits)+len(overrides))
+	for _, l := range overrides {
+		overridden[l.kind] = true
+		if l.max != 0 {
+			limits = append(limits, l)
+		}
+	}
+	for _, l := range g.user.limits {
+		if !overridden[l.kind] {
+			limits = append(limits, l)
+		}
+	}
+	return limits
+}
+
+var errSuspended = &exterrors.SMTPError{
+	Code:         554,
+	EnhancedCode: exterrors.EnhancedCode{5, 7, 1},
+	Message:      "Sending is suspended for this account, contact the postmaster",
+}
+
+func exceededErr(l userLimit) error {
+	return &exterrors.SMTPError{
+		Code:         451,
+		EnhancedCode: exterrors.EnhancedCode{4, 7, 1},
+		Message:      "Sending limit exceeded, try again later",
+		Misc: map[string]interface{}{
+			"limit": l.String(),
+		},
+	}
+}
+
+// TakeUserMsg accounts the message sent by the authenticated user. It
+// returns an error if the user exceeded the limit of messages or sending is
+// suspended for the user.
+func (g *Group) TakeUserMsg(ctx context.Context, user string) error {
+	if g.user == nil {
+		return nil
+	}
+	user = normalizeUser(user)
+
+	if g.user.suspend != nil {
+		_, suspended, err := g.user.suspend.Lookup(ctx, user)
+		if err != nil {
+			g.log.Error("suspend table lookup failed", err, "user", user)
+		} else if suspended {
+			return errSuspended
+		}
+	}
+
+	for _, l := range g.limitsFor(ctx, user) {
+		if l.kind != userMessages {
+			continue
+		}
+		if err := g.takeUser(ctx, l, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TakeUserRcpt accounts the recipient of the message sent by the
+// authenticated user. It returns an error if the user exceeded the limit of
+// recipients.
+func (g *Group) TakeUserRcpt(ctx context.Context, user, rcpt string) error {
+	if g.user == nil {
+		return nil
+	}
+	user = normalizeUser(user)
+
+	for _, l := range g.limitsFor(ctx, user) {
+		switch l.kind {
+		case userRecipients:
+			if err := g.takeUser(ctx, l, user); err != nil {
+				return err
+			}
+		case userUniqueRecipients:
+			if err := g.takeUniqueRcpt(ctx, l, user, rcpt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Group) takeUser(ctx context.Context, l userLimit, user string) error {
+	wait, err := g.userStore.TakeRate(ctx, g.userKey(l, user), l.max, l.period)
+	g.reportStore(err)
+	if err != nil || wait == 0 {
+		// Limits are not applied while the store is not available.
+		return nil
+	}
+	return g.userExceeded(ctx, l, user)
+}
+
+func (g *Group) takeUniqueRcpt(ctx context.Context, l userLimit, user, rcpt string) error {
+	_, domain, err := address.Split(rcpt)
+	if err != nil {
+		return nil
+	}
+	if _, ok := g.user.localDomains[strings.ToLower(domain)]; ok {
+		return nil
+	}
+
+	// The recipient is marked as seen for the period, the counter is
+	// incremented only for recipients that are not seen yet.
+	seenKey := g.userKey(l, user) + "/" + strings.ToLower(rcpt)
+	isNew, err := g.userStore.Acquire(ctx, seenKey, 1, l.period)
+	g.reportStore(err)
+	if err != nil || !isNew {
+		return nil
+	}
+
+	if err := g.takeUser(ctx, l, user); err != nil {
+		// Keep the recipient counted as new on retry.
+		g.reportStore(g.userStore.Release(ctx, seenKey))
+		return err
+	}
+	return nil
+}
+
+// userExceeded suspends the user and alerts the postmaster if configured
+// and returns the error for the client.
+func (g *Group) userExceeded(ctx context.Context, l userLimit, user string) error {
+	// Alert and log only once per the limit period.
+	wait, err := g.userStore.TakeRate(ctx, g.userKey(l, user)+"/exceeded", 1, l.period)
+	if err == nil && wait != 0 {
+		if g.user.suspend != nil {
+			return errSuspended
+		}
+		return exceededErr(l)
+	}
+
+	g.log.Msg("user exceeded sending limit", "user", user, "limit", l.String())
+
+	suspended := false
+	if g.user.suspend != nil {
+		val := strconv.FormatInt(time.Now().Unix(), 10) + " " + l.String()
+		if err := g.user.suspend.SetKey(user, val); err != nil {
+			g.log.Error("failed to suspend user", err, "user", user)
+		} else {
+			suspended = true
+			g.log.Msg("sending suspended", "user", user)
+		}
+	}
+
+	if len(g.user.alertTo) != 0 {
+		if err := g.sendAlert(ctx, l, user, suspended); err != nil {
+			g.log.Error("failed to send alert", err, "user", user)
+		}
+	}
+
+	if suspended {
+		return errSuspended
+	}
+	return exceededErr(l)
+}
+
+func (g *Group) sendAlert(ctx context.Context, l userLimit, user string, suspended bool) error {
+	msgID, err := module.GenerateMsgID()
+	if err != nil {
+		return err
+	}
+	_, fromDomain, err := address.Split(g.user.alertFrom)
+	if err != nil {
+		return err
+	}
+
+	hdr := textproto.Header{}
+	hdr.Add("Date", time.Now().Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	hdr.Add("Message-Id", "<"+msgID+"@"+fromDomain+">")
+	hdr.Add("From", g.user.alertFrom)
+	hdr.Add("To", strings.Join(g.user.alertTo, ", "))
+	hdr.Add("Subject", "Sending limit exceeded by "+user)
+	hdr.Add("Auto-Submitted", "auto-generated")
+	hdr.Add("MIME-Version", "1.0")
+	hdr.Add("Content-Type", "text/plain; charset=utf-8")
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "User %s exceeded the sending limit: %s.\r\n", user, l)
+	if suspended {
+		body.WriteString("\r\nSending is suspended for the account. Remove the user from the\r\n" +
+			"suspend table to allow sending again.\r\n")
+	}
+
+	msgMeta := &module.MsgMetadata{ID: msgID}
+	delivery, err := g.user.alertTarget.Start(ctx, msgMeta, g.user.alertFrom)
+	if err != nil {
+		return err
+	}
+	for _, rcpt := range g.user.alertTo {
+		if err := delivery.AddRcpt(ctx, rcpt, smtp.RcptOptions{}); err != nil {
+			delivery.Abort(ctx)
+			return err
+		}
+	}
+	if err := delivery.Body(ctx, hdr, buffer.MemoryBuffer{Slice: body.Bytes()}); err != nil {
+		delivery.Abort(ctx)
+		return err
+	}
+	return delivery.Commit(ctx)
+}
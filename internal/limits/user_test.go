@@ -0,0 +1,184 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/internal/limits/limiters"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+type memTable map[string]string
+
+func (t memTable) Lookup(_ context.Context, key string) (string, bool, error) {
+	val, ok := t[key]
+	return val, ok, nil
+}
+
+func (t memTable) Keys() ([]string, error) {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (t memTable) SetKey(key, val string) error {
+	t[key] = val
+	return nil
+}
+
+func (t memTable) RemoveKey(key string) error {
+	delete(t, key)
+	return nil
+}
+
+func testGroup(t *testing.T, limits ...userLimit) *Group {
+	return &Group{
+		log: testutils.Logger(t, "limits"),
+		user: &userLimits{
+			limits:       limits,
+			localDomains: map[string]struct{}{"example.org": {}},
+		},
+		userStore: limiters.NewMemoryStore(),
+	}
+}
+
+func smtpCode(err error) int {
+	var smtpErr *exterrors.SMTPError
+	if !errors.As(err, &smtpErr) {
+		return 0
+	}
+	return smtpErr.Code
+}
+
+func TestUserLimits_Messages(t *testing.T) {
+	g := testGroup(t, userLimit{kind: userMessages, max: 2, period: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := g.TakeUserMsg(ctx, "foo@example.org"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.TakeUserMsg(ctx, "foo@example.org"); smtpCode(err) != 451 {
+		t.Fatal("expected temporary error, got", err)
+	}
+	if err := g.TakeUserMsg(ctx, "bar@example.org"); err != nil {
+		t.Fatal("limit of other user is applied:", err)
+	}
+
+	// Override replaces the default limit.
+	g.user.overrides = memTable{"bar@example.org": "messages 0 1h; recipients 1 1h"}
+	for i := 0; i < 5; i++ {
+		if err := g.TakeUserMsg(ctx, "bar@example.org"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.TakeUserRcpt(ctx, "bar@example.org", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.TakeUserRcpt(ctx, "bar@example.org", "b@example.com"); smtpCode(err) != 451 {
+		t.Fatal("override limit is not applied:", err)
+	}
+}
+
+func TestUserLimits_UniqueRecipients(t *testing.T) {
+	g := testGroup(t, userLimit{kind: userUniqueRecipients, max: 2, period: time.Hour})
+	ctx := context.Background()
+
+	for _, rcpt := range []string{"a@example.com", "b@example.com", "a@example.com", "local@example.org"} {
+		if err := g.TakeUserRcpt(ctx, "foo@example.org", rcpt); err != nil {
+			t.Fatal(rcpt, err)
+		}
+	}
+	if err := g.TakeUserRcpt(ctx, "foo@example.org", "c@example.com"); smtpCode(err) != 451 {
+		t.Fatal("expected temporary error, got", err)
+	}
+	// Rejected recipient is still new on retry.
+	if err := g.TakeUserRcpt(ctx, "foo@example.org", "c@example.com"); smtpCode(err) != 451 {
+		t.Fatal("expected temporary error on retry, got", err)
+	}
+}
+
+func TestUserLimits_Suspend(t *testing.T) {
+	g := testGroup(t, userLimit{kind: userMessages, max: 1, period: time.Hour})
+	suspend := memTable{}
+	tgt := &testutils.Target{}
+	g.user.suspend = suspend
+	g.user.alertTo = []string{"postmaster@example.org"}
+	g.user.alertFrom = "MAILER-DAEMON@mx.example.org"
+	g.user.alertTarget = tgt
+	ctx := context.Background()
+
+	if err := g.TakeUserMsg(ctx, "foo@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := g.TakeUserMsg(ctx, "foo@example.org"); smtpCode(err) != 554 {
+			t.Fatal("expected suspension error, got", err)
+		}
+	}
+	if _, ok := suspend["foo@example.org"]; !ok {
+		t.Fatal("user is not added to the suspend table")
+	}
+	if len(tgt.Messages) != 1 {
+		t.Fatal("expected one alert, got", len(tgt.Messages))
+	}
+	if rcpts := tgt.Messages[0].RcptTo; len(rcpts) != 1 || rcpts[0] != "postmaster@example.org" {
+		t.Fatal("wrong alert recipients:", rcpts)
+	}
+}
+
+func TestUserLimits_CaseVariants(t *testing.T) {
+	g := testGroup(t, userLimit{kind: userMessages, max: 1, period: time.Hour})
+	suspend := memTable{}
+	g.user.suspend = suspend
+	ctx := context.Background()
+
+	if err := g.TakeUserMsg(ctx, "Foo@Example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.TakeUserMsg(ctx, "foo@example.org"); smtpCode(err) != 554 {
+		t.Fatal("limit is not shared by case variants, got", err)
+	}
+	if _, ok := suspend["foo@example.org"]; !ok {
+		t.Fatal("normalized username is not added to the suspend table:", suspend)
+	}
+	if err := g.TakeUserMsg(ctx, "FOO@example.org"); smtpCode(err) != 554 {
+		t.Fatal("suspension is not applied to case variants, got", err)
+	}
+}
+
+func TestUserLimits_Config(t *testing.T) {
+	g := &Group{}
+	err := g.Init(config.NewMap(nil, config.Node{
+		Children: []config.Node{
+			{Name: "user", Args: []string{"messages", "100", "1h"}},
+			{Name: "user", Args: []string{"unique_recipients", "50", "24h"}},
+			{Name: "local_domains", Args: []string{"Example.org"}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.user.limits) != 2 {
+		t.Fatal("wrong limits:", g.user.limits)
+	}
+	if _, ok := g.user.localDomains["example.org"]; !ok {
+		t.Fatal("local domain is not normalized")
+	}
+
+	err = (&Group{}).Init(config.NewMap(nil, config.Node{
+		Children: []config.Node{
+			{Name: "local_domains", Args: []string{"example.org"}},
+		},
+	}))
+	if err == nil {
+		t.Fatal("no error for local_domains without user limits")
+	}
+}
@@ -0,0 +1,298 @@
+// Package listeners shares listening sockets between the endpoints created
+// from different versions of the configuration.
+//
+// Endpoints use Listen instead of net.Listen. Each listener belongs to a
+// Generation, the configuration reload starts a new generation before
+// initializing new endpoints so they get their own listeners for the
+// sockets of the running endpoints instead of failing with "address
+// already in use". After the reload, new connections are accepted only by
+// the listeners of the new generation, sockets it does not use are closed
+// and the old endpoints can be closed once their connections are finished.
+package listeners
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+)
+
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+// socket is the underlying listener shared by Listeners.
+type socket struct {
+	key string
+	l   net.Listener
+
+	// users are listeners using the socket, in the order of creation.
+	users []*Listener
+}
+
+// Generation is the set of listeners created by the endpoints of one
+// configuration version.
+type Generation struct {
+	listeners []*Listener
+	active    bool
+	conns     sync.WaitGroup
+}
+
+var (
+	lck     sync.Mutex
+	sockets = make(map[string]*socket)
+	active  = &Generation{active: true}
+	target  = active
+
+	// activated is signalled when the active generation changes or a
+	// socket is closed.
+	activated = sync.NewCond(&lck)
+)
+
+// Listen returns the listener for the address, opening the socket if no
+// listeners of other generations use it.
+//
+// Stale Unix sockets left by the previous server process are removed.
+func Listen(network, address string) (net.Listener, error) {
+	lck.Lock()
+	defer lck.Unlock()
+
+	key := network + "://" + address
+	sock := sockets[key]
+	if sock == nil {
+		if network == "unix" {
+			os.Remove(address)
+		}
+		l, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		sock = &socket{key: key, l: l}
+		sockets[key] = sock
+		go sock.acceptLoop()
+	}
+
+	l := &Listener{
+		sock:     sock,
+		gen:      target,
+		accepted: make(chan accepted),
+		closed:   make(chan struct{}),
+	}
+	sock.users = append(sock.users, l)
+	target.listeners = append(target.listeners, l)
+	return l, nil
+}
+
+// Begin starts a new generation. Listen adds listeners to it until Commit
+// or Abort is called.
+//
+// Connections are not accepted by the listeners of the new generation
+// until it is committed.
+func Begin() *Generation {
+	lck.Lock()
+	defer lck.Unlock()
+	target = &Generation{}
+	return target
+}
+
+// Commit makes the generation accept new connections and returns the
+// previous active generation. Listeners of the previous generation stop
+// accepting connections, sockets not used by the generation, e.g. for the
+// addresses removed from the configuration, are closed.
+func (g *Generation) Commit() *Generation {
+	lck.Lock()
+	defer lck.Unlock()
+
+	prev := active
+	prev.active = false
+	g.active = true
+	active = g
+	target = g
+
+	for key, sock := range sockets {
+		if !sock.usedBy(g) {
+			delete(sockets, key)
+			sock.l.Close()
+		}
+	}
+
+	activated.Broadcast()
+	return prev
+}
+
+// Abort closes the listeners of the uncommitted generation.
+func (g *Generation) Abort() {
+	lck.Lock()
+	target = active
+	ls := g.listeners
+	lck.Unlock()
+
+	for _, l := range ls {
+		l.Close()
+	}
+}
+
+// Wait waits until the connections accepted by the listeners of the
+// generation are closed or ctx is done.
+func (g *Generation) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (sock *socket) usedBy(g *Generation) bool {
+	for _, l := range sock.users {
+		if l.gen == g {
+			return true
+		}
+	}
+	return false
+}
+
+// receiver returns the newest listener of the active generation using the
+// socket. If the socket is used only by an uncommitted generation, it waits
+// for the commit. nil is returned if the socket is closed.
+func (sock *socket) receiver() *Listener {
+	lck.Lock()
+	defer lck.Unlock()
+
+	for sockets[sock.key] == sock {
+		for i := len(sock.users) - 1; i >= 0; i-- {
+			l := sock.users[i]
+			if l.gen.active {
+				l.gen.conns.Add(1)
+				return l
+			}
+		}
+		activated.Wait()
+	}
+	return nil
+}
+
+func (sock *socket) acceptLoop() {
+	for {
+		conn, err := sock.l.Accept()
+		if err != nil {
+			lck.Lock()
+			closed := sockets[sock.key] != sock
+			users := append([]*Listener(nil), sock.users...)
+			lck.Unlock()
+			if closed {
+				return
+			}
+
+			// Report the error to the active listeners, endpoints
+			// decide whether it is temporary.
+			for _, l := range users {
+				if !l.gen.active {
+					continue
+				}
+				select {
+				case l.accepted <- accepted{err: err}:
+				case <-l.closed:
+				}
+			}
+			continue
+		}
+
+		sock.dispatch(conn)
+	}
+}
+
+func (sock *socket) dispatch(conn net.Conn) {
+	for {
+		l := sock.receiver()
+		if l == nil {
+			// The socket is being closed.
+			conn.Close()
+			return
+		}
+
+		select {
+		case l.accepted <- accepted{conn: &trackedConn{Conn: conn, gen: l.gen}}:
+			return
+		case <-l.closed:
+			l.gen.conns.Done()
+		}
+	}
+}
+
+// Listener is the net.Listener returned by Listen.
+//
+// After its generation is stopped, Accept blocks until Close is called so
+// the endpoint keeps running until it is closed.
+type Listener struct {
+	sock      *socket
+	gen       *Generation
+	accepted  chan accepted
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case a := <-l.accepted:
+		return a.conn, a.err
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener and the underlying socket if it is not used by
+// other listeners.
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		lck.Lock()
+		defer lck.Unlock()
+
+		users := l.sock.users[:0:0]
+		for _, u := range l.sock.users {
+			if u != l {
+				users = append(users, u)
+			}
+		}
+		l.sock.users = users
+
+		// The socket might be closed by Commit already.
+		if len(users) == 0 && sockets[l.sock.key] == l.sock {
+			delete(sockets, l.sock.key)
+			err = l.sock.l.Close()
+			activated.Broadcast()
+		}
+	})
+	return err
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.sock.l.Addr()
+}
+
+type trackedConn struct {
+	net.Conn
+	gen       *Generation
+	closeOnce sync.Once
+}
+
+// NetConn returns the underlying connection, e.g. to get peer credentials
+// of Unix socket connections.
+func (c *trackedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(c.gen.conns.Done)
+	return c.Conn.Close()
+}
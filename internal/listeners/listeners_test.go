@@ -0,0 +1,199 @@
+package listeners
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func acceptOne(t *testing.T, l net.Listener) <-chan net.Conn {
+	t.Helper()
+	ch := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- c
+	}()
+	return ch
+}
+
+func dial(t *testing.T, addr net.Addr) net.Conn {
+	t.Helper()
+	c, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// freeAddr returns the address to listen on. Listen shares sockets by the
+// address string so it can't be used with the port 0.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestGeneration_Handover(t *testing.T) {
+	oldL, err := Listen("tcp", freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := oldL.Addr()
+
+	// Connection accepted before the reload.
+	oldAccepted := acceptOne(t, oldL)
+	dial(t, addr)
+	oldConn := <-oldAccepted
+
+	gen := Begin()
+	newL, err := Listen("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newL.Addr().String() != addr.String() {
+		t.Fatal("new listener has a different address:", newL.Addr())
+	}
+
+	// Connections are accepted by the old generation until the commit.
+	oldAccepted = acceptOne(t, oldL)
+	newAccepted := acceptOne(t, newL)
+	dial(t, addr)
+	select {
+	case c := <-oldAccepted:
+		c.Close()
+	case <-newAccepted:
+		t.Fatal("connection accepted by the uncommitted generation")
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not accepted")
+	}
+
+	prev := gen.Commit()
+	oldAccepted = acceptOne(t, oldL)
+
+	dial(t, addr)
+	select {
+	case c := <-newAccepted:
+		defer c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not accepted by the new generation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := prev.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Wait returned before the connection was closed:", err)
+	}
+
+	oldConn.Close()
+	if err := prev.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the old listener stops its Accept but keeps the socket open.
+	oldL.Close()
+	if _, ok := <-oldAccepted; ok {
+		t.Fatal("old listener accepted a connection after the commit")
+	}
+	newAccepted = acceptOne(t, newL)
+	dial(t, addr)
+	select {
+	case c := <-newAccepted:
+		c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not accepted after the old listener is closed")
+	}
+
+	newL.Close()
+	if _, err := net.Dial("tcp", addr.String()); err == nil {
+		t.Fatal("socket is still open after all listeners are closed")
+	}
+}
+
+func TestGeneration_Abort(t *testing.T) {
+	gen := Begin()
+	l, err := Listen("tcp", freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr()
+
+	gen.Abort()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatal("Accept after Abort returned", err)
+	}
+	if _, err := net.Dial("tcp", addr.String()); err == nil {
+		t.Fatal("socket is still open after Abort")
+	}
+
+	// Listeners are added to the active generation after Abort.
+	l, err = Listen("tcp", freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := acceptOne(t, l)
+	dial(t, l.Addr())
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not accepted")
+	}
+}
+
+func TestGeneration_RemovedAddress(t *testing.T) {
+	oldL, err := Listen("tcp", freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer oldL.Close()
+	addr := oldL.Addr()
+
+	oldAccepted := acceptOne(t, oldL)
+	dial(t, addr)
+	oldConn := <-oldAccepted
+
+	// The address is not used by the new generation.
+	prev := Begin().Commit()
+	if _, err := net.Dial("tcp", addr.String()); err == nil {
+		t.Fatal("socket of the removed address is still open")
+	}
+
+	// Accepted connections are still drained.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := prev.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Wait returned before the connection was closed:", err)
+	}
+	oldConn.Close()
+	if err := prev.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The address can be used again.
+	l, err := Listen("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	oldL.Close()
+	accepted := acceptOne(t, l)
+	dial(t, addr)
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not accepted")
+	}
+}
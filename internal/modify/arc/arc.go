@@ -0,0 +1,253 @@
+package arc
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"path/filepath"
+	"runtime/trace"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-msgauth/authres"
+	"golang.org/x/net/idna"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/arc"
+	"github.com/dsoftgames/MailChat/internal/modify/dkim"
+	"github.com/dsoftgames/MailChat/internal/target"
+)
+
+var signDefault = []string{
+	"From",
+	"Sender",
+	"Reply-To",
+	"To",
+	"Cc",
+	"Subject",
+	"Date",
+	"Message-Id",
+	"In-Reply-To",
+	"References",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+	"DKIM-Signature",
+	"List-Id",
+	"List-Unsubscribe",
+	"List-Post",
+}
+
+// Modifier adds an ARC set (RFC 8617) to messages passing through, so
+// receivers can see results of authentication checks done before the message
+// was modified by forwarding.
+//
+// ARC-Authentication-Results is populated from the Authentication-Results
+// field added by this server (authserv_id) and the chain status from the
+// check.arc result in it. Keys are handled the same way as for modify.dkim
+// and use the same DNS record format.
+type Modifier struct {
+	instName string
+
+	domain     string
+	selector   string
+	signer     crypto.Signer
+	signFields []string
+	authServID string
+	resolver   arc.Resolver
+
+	log log.Logger
+}
+
+func New(_, instName string, _, inlineArgs []string) (module.Module, error) {
+	m := &Modifier{
+		instName: instName,
+		log:      log.Logger{Name: "modify.arc"},
+		resolver: dns.DefaultResolver(),
+	}
+
+	switch len(inlineArgs) {
+	case 0:
+	case 2:
+		m.domain = inlineArgs[0]
+		m.selector = inlineArgs[1]
+	default:
+		return nil, errors.New("modify.arc: domain and selector are expected as arguments")
+	}
+	return m, nil
+}
+
+func (m *Modifier) Name() string {
+	return "modify.arc"
+}
+
+func (m *Modifier) InstanceName() string {
+	return m.instName
+}
+
+func (m *Modifier) Init(cfg *config.Map) error {
+	var (
+		hostname        string
+		keyPathTemplate string
+		newKeyAlgo      string
+	)
+
+	cfg.Bool("debug", true, false, &m.log.Debug)
+	cfg.String("hostname", true, false, "", &hostname)
+	cfg.String("domain", false, false, m.domain, &m.domain)
+	cfg.String("selector", false, false, m.selector, &m.selector)
+	cfg.String("key_path", false, false, "dkim_keys/{domain}_{selector}.key", &keyPathTemplate)
+	cfg.Enum("newkey_algo", false, false,
+		[]string{"rsa4096", "rsa2048", "ed25519"}, "rsa2048", &newKeyAlgo)
+	cfg.StringList("sign_fields", false, false, signDefault, &m.signFields)
+	cfg.String("authserv_id", false, false, "", &m.authServID)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if m.domain == "" {
+		return errors.New("modify.arc: domain is not specified")
+	}
+	if m.selector == "" {
+		return errors.New("modify.arc: selector is not specified")
+	}
+	if m.authServID == "" {
+		m.authServID = hostname
+	}
+	if m.authServID == "" {
+		return errors.New("modify.arc: authserv_id or hostname is required")
+	}
+
+	var err error
+	m.domain, err = idna.ToASCII(m.domain)
+	if err != nil {
+		return err
+	}
+	m.selector, err = idna.ToASCII(m.selector)
+	if err != nil {
+		return err
+	}
+
+	keyValues := strings.NewReplacer("{domain}", m.domain, "{selector}", m.selector)
+	keyPath := keyValues.Replace(keyPathTemplate)
+
+	signer, newKey, err := dkim.LoadOrGenerateKey(m.log, keyPath, newKeyAlgo)
+	if err != nil {
+		return err
+	}
+	if newKey {
+		dnsPath := keyPath + ".dns"
+		if filepath.Ext(keyPath) == ".key" {
+			dnsPath = keyPath[:len(keyPath)-4] + ".dns"
+		}
+		m.log.Printf("generated a new %s keypair, private key is in %s, TXT record with public key is in %s,\n"+
+			"put its contents into TXT record for %s._domainkey.%s to make sealing work",
+			newKeyAlgo, keyPath, dnsPath, m.selector, m.domain)
+	}
+	m.signer = signer
+
+	return nil
+}
+
+type state struct {
+	m    *Modifier
+	meta *module.MsgMetadata
+	log  log.Logger
+}
+
+func (m *Modifier) ModStateForMsg(ctx context.Context, msgMeta *module.MsgMetadata) (module.ModifierState, error) {
+	return &state{
+		m:    m,
+		meta: msgMeta,
+		log:  target.DeliveryLogger(m.log, msgMeta),
+	}, nil
+}
+
+func (s *state) RewriteSender(ctx context.Context, mailFrom string) (string, error) {
+	return mailFrom, nil
+}
+
+func (s *state) RewriteRcpt(ctx context.Context, rcptTo string) ([]string, error) {
+	return []string{rcptTo}, nil
+}
+
+// ownResults returns the results part of the Authentication-Results field
+// added by this server and the ARC chain status reported in it.
+func (s *state) ownResults(h *textproto.Header) (string, arc.Status, bool) {
+	for field := h.FieldsByKey("Authentication-Results"); field.Next(); {
+		id, results, err := authres.Parse(field.Value())
+		if err != nil || !strings.EqualFold(id, s.m.authServID) {
+			continue
+		}
+
+		cv := arc.Status("")
+		for _, res := range results {
+			if res, ok := res.(*authres.GenericResult); ok && res.Method == "arc" {
+				cv = arc.Status(res.Value)
+			}
+		}
+		_, formatted, _ := strings.Cut(field.Value(), ";")
+		return strings.TrimSpace(formatted), cv, true
+	}
+	return "", "", false
+}
+
+func (s *state) RewriteBody(ctx context.Context, h *textproto.Header, body buffer.Buffer) error {
+	defer trace.StartRegion(ctx, "modify.arc/RewriteBody").End()
+
+	authResults, cv, _ := s.ownResults(h)
+	if cv == "" && h.Has("ARC-Seal") {
+		// check.arc is not used for the message, the message is not modified
+		// yet so the chain can be validated now.
+		r, err := body.Open()
+		if err != nil {
+			return exterrors.WithFields(err, map[string]interface{}{"modifier": "modify.arc"})
+		}
+		chain, err := arc.Verify(ctx, s.m.resolver, *h, r)
+		r.Close()
+		if err != nil {
+			return exterrors.WithFields(err, map[string]interface{}{"modifier": "modify.arc"})
+		}
+		cv = chain.Status
+	}
+
+	r, err := body.Open()
+	if err != nil {
+		return exterrors.WithFields(err, map[string]interface{}{"modifier": "modify.arc"})
+	}
+	defer r.Close()
+
+	err = arc.Seal(h, r, cv, arc.SealOptions{
+		Domain:      s.m.domain,
+		Selector:    s.m.selector,
+		Signer:      s.m.signer,
+		AuthServID:  s.m.authServID,
+		AuthResults: authResults,
+		HeaderKeys:  s.m.signFields,
+		Time:        time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, arc.ErrNoSeal) {
+			s.log.DebugMsg("not sealing the message", "reason", err)
+			return nil
+		}
+		return exterrors.WithFields(err, map[string]interface{}{"modifier": "modify.arc"})
+	}
+
+	s.log.DebugMsg("sealed", "domain", s.m.domain, "cv", cv)
+	return nil
+}
+
+func (s *state) Close() error {
+	return nil
+}
+
+func init() {
+	module.Register("modify.arc", New)
+}
@@ -0,0 +1,126 @@
+package arc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/foxcpp/go-mockdns"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/arc"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+func newTestModifier(t *testing.T, dir string) *Modifier {
+	mod, err := New("", "test", nil, []string{"example.net", "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mod.(*Modifier)
+	m.log = testutils.Logger(t, m.Name())
+
+	err = m.Init(config.NewMap(nil, config.Node{
+		Children: []config.Node{
+			{
+				Name: "authserv_id",
+				Args: []string{"mx.example.net"},
+			},
+			{
+				Name: "key_path",
+				Args: []string{filepath.Join(dir, "{domain}.key")},
+			},
+			{
+				Name: "newkey_algo",
+				Args: []string{"ed25519"},
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func sealTestMsg(t *testing.T, m *Modifier, hdr *textproto.Header, body []byte) {
+	t.Helper()
+
+	state, err := m.ModStateForMsg(context.Background(), &module.MsgMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.RewriteBody(context.Background(), hdr, buffer.MemoryBuffer{Slice: body}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestModifier(t, dir)
+
+	dnsRecord, err := os.ReadFile(filepath.Join(dir, "example.net.dns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.resolver = &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"default._domainkey.example.net.": {TXT: []string{string(dnsRecord)}},
+	}}
+
+	hdr := textproto.Header{}
+	hdr.Add("From", "<alice@example.org>")
+	hdr.Add("To", "<list@example.net>")
+	hdr.Add("Subject", "Hello")
+	hdr.Add("Authentication-Results", "mx.example.net; arc=none; dmarc=pass header.from=example.org")
+	hdr.Add("Authentication-Results", "mx.example.com; dmarc=fail header.from=example.org")
+	body := []byte("Hello!\r\n")
+	sealTestMsg(t, m, &hdr, body)
+
+	chain, err := arc.Verify(context.Background(), m.resolver, hdr, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Status != arc.StatusPass {
+		t.Fatalf("expected pass, got %s (%s)", chain.Status, chain.Reason)
+	}
+	if latest := chain.Latest(); latest.Domain != "example.net" || latest.Instance != 1 ||
+		latest.AuthResults != "mx.example.net; arc=none; dmarc=pass header.from=example.org" {
+		t.Errorf("wrong ARC set: %+v", latest)
+	}
+
+	// Without check.arc results the modifier validates the chain itself,
+	// the modified message fails validation.
+	hdr.Del("Authentication-Results")
+	hdr.Set("Subject", "[list] Hello")
+	sealTestMsg(t, m, &hdr, body)
+	chain, err = arc.Verify(context.Background(), m.resolver, hdr, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Status != arc.StatusFail || len(chain.Sets) != 2 || chain.Latest().ChainStatus != arc.StatusFail {
+		t.Fatalf("expected cv=fail chain with 2 sets, got %s %+v", chain.Status, chain.Sets)
+	}
+
+	// Failed chain is not extended.
+	sealTestMsg(t, m, &hdr, body)
+	if n := len(hdr.Values("ARC-Seal")); n != 2 {
+		t.Errorf("expected 2 ARC-Seal fields, got %d", n)
+	}
+}
+
+func TestSeal_NoResults(t *testing.T) {
+	m := newTestModifier(t, t.TempDir())
+
+	hdr := textproto.Header{}
+	hdr.Add("From", "<alice@example.org>")
+	sealTestMsg(t, m, &hdr, []byte("Hello!\r\n"))
+
+	if aar := hdr.Get("ARC-Authentication-Results"); !strings.HasSuffix(aar, "mx.example.net; none") {
+		t.Errorf("wrong ARC-Authentication-Results: %s", aar)
+	}
+}
@@ -31,13 +31,25 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/dsoftgames/MailChat/framework/log"
 )
 
 func (m *Modifier) loadOrGenerateKey(keyPath, newKeyAlgo string) (pkey crypto.Signer, newKey bool, err error) {
+	return LoadOrGenerateKey(m.log, keyPath, newKeyAlgo)
+}
+
+// LoadOrGenerateKey loads the private key from keyPath or generates a new
+// one using newKeyAlgo if the file does not exist. The TXT record with the
+// public key is written next to the new key file.
+//
+// It is also used by other modules that sign using keys published as DKIM
+// key records, errors are prefixed with the logger name.
+func LoadOrGenerateKey(logger log.Logger, keyPath, newKeyAlgo string) (pkey crypto.Signer, newKey bool, err error) {
 	f, err := os.Open(keyPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			pkey, err = m.generateAndWrite(keyPath, newKeyAlgo)
+			pkey, err = generateAndWrite(logger, keyPath, newKeyAlgo)
 			return pkey, true, err
 		}
 		return nil, false, err
@@ -51,7 +63,7 @@ func (m *Modifier) loadOrGenerateKey(keyPath, newKeyAlgo string) (pkey crypto.Si
 
 	block, _ := pem.Decode(pemBlob)
 	if block == nil {
-		return nil, false, fmt.Errorf("modify.dkim: %s: invalid PEM block", keyPath)
+		return nil, false, fmt.Errorf("%s: %s: invalid PEM block", logger.Name, keyPath)
 	}
 
 	var key interface{}
@@ -59,20 +71,20 @@ func (m *Modifier) loadOrGenerateKey(keyPath, newKeyAlgo string) (pkey crypto.Si
 	case "PRIVATE KEY": // RFC 5208 aka PKCS #8
 		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
 		if err != nil {
-			return nil, false, fmt.Errorf("modify.dkim: %s: %w", keyPath, err)
+			return nil, false, fmt.Errorf("%s: %s: %w", logger.Name, keyPath, err)
 		}
 	case "RSA PRIVATE KEY": // RFC 3447 aka PKCS #1
 		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
 		if err != nil {
-			return nil, false, fmt.Errorf("modify.dkim: %s: %w", keyPath, err)
+			return nil, false, fmt.Errorf("%s: %s: %w", logger.Name, keyPath, err)
 		}
 	case "EC PRIVATE KEY": // RFC 5915
 		key, err = x509.ParseECPrivateKey(block.Bytes)
 		if err != nil {
-			return nil, false, fmt.Errorf("modify.dkim: %s: %w", keyPath, err)
+			return nil, false, fmt.Errorf("%s: %s: %w", logger.Name, keyPath, err)
 		}
 	default:
-		return nil, false, fmt.Errorf("modify.dkim: %s: not a private key or unsupported format", keyPath)
+		return nil, false, fmt.Errorf("%s: %s: not a private key or unsupported format", logger.Name, keyPath)
 	}
 
 	switch key := key.(type) {
@@ -85,18 +97,18 @@ func (m *Modifier) loadOrGenerateKey(keyPath, newKeyAlgo string) (pkey crypto.Si
 	case ed25519.PrivateKey:
 		return key, false, nil
 	case *ecdsa.PublicKey:
-		return nil, false, fmt.Errorf("modify.dkim: %s: ECDSA keys are not supported", keyPath)
+		return nil, false, fmt.Errorf("%s: %s: ECDSA keys are not supported", logger.Name, keyPath)
 	default:
-		return nil, false, fmt.Errorf("modify.dkim: %s: unknown key type: %T", keyPath, key)
+		return nil, false, fmt.Errorf("%s: %s: unknown key type: %T", logger.Name, keyPath, key)
 	}
 }
 
-func (m *Modifier) generateAndWrite(keyPath, newKeyAlgo string) (crypto.Signer, error) {
+func generateAndWrite(logger log.Logger, keyPath, newKeyAlgo string) (crypto.Signer, error) {
 	wrapErr := func(err error) error {
-		return fmt.Errorf("modify.dkim: generate %s: %w", keyPath, err)
+		return fmt.Errorf("%s: generate %s: %w", logger.Name, keyPath, err)
 	}
 
-	m.log.Printf("generating a new %s keypair...", newKeyAlgo)
+	logger.Printf("generating a new %s keypair...", newKeyAlgo)
 
 	var (
 		pkey     crypto.Signer
@@ -118,6 +118,14 @@ func (gs groupState) RewriteBody(ctx context.Context, h *textproto.Header, body
 	return nil
 }
 
+func (gs groupState) Committed(ctx context.Context) {
+	for _, state := range gs.states {
+		if committer, ok := state.(module.ModifierStateCommitter); ok {
+			committer.Committed(ctx)
+		}
+	}
+}
+
 func (gs groupState) Close() error {
 	// We still try close all state objects to minimize
 	// resource leaks when Close fails for one object..
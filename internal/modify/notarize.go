@@ -0,0 +1,288 @@
+package modify
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/notary"
+)
+
+// anchorTimeout limits the time spent on a batch, including the wait for
+// the transaction to be included in a block.
+const anchorTimeout = 2 * time.Minute
+
+// Notarizer hashes accepted messages, periodically builds a Merkle tree
+// over the collected hashes and anchors its root on chain using a
+// module.MerkleAnchor (e.g. blockchain.cosmos).
+//
+// Message hash is recorded in the X-MailChat-Notary header field, inclusion
+// proofs are kept in the state directory and can be retrieved using
+// 'MailChat notary proof'.
+//
+// The modifier should be the last one to change the message header or body,
+// changes done after it break the hash.
+//
+// Pending hashes and batches are kept in the state directory, Close does not
+// wait for them to be anchored, they are anchored after the next start.
+type Notarizer struct {
+	instName string
+	log      log.Logger
+
+	chain     module.MerkleAnchor
+	interval  time.Duration
+	maxBatch  int
+	addHeader bool
+	stateDir  string
+
+	storeLock sync.Mutex
+	store     *notary.Store
+	pending   int
+
+	flushCh chan struct{}
+	stop    context.CancelFunc
+	stopped sync.WaitGroup
+}
+
+func NewNotarizer(_, instName string, _, _ []string) (module.Module, error) {
+	return &Notarizer{
+		instName: instName,
+		log:      log.Logger{Name: "modify.notarize", Debug: log.DefaultLogger.Debug},
+	}, nil
+}
+
+func (n *Notarizer) Init(cfg *config.Map) error {
+	cfg.Bool("debug", true, log.DefaultLogger.Debug, &n.log.Debug)
+	cfg.Custom("chain", false, true, nil, func(m *config.Map, node config.Node) (interface{}, error) {
+		var chain module.MerkleAnchor
+		if err := modconfig.ModuleFromNode("blockchain", node.Args, node, m.Globals, &chain); err != nil {
+			return nil, err
+		}
+		return chain, nil
+	}, &n.chain)
+	cfg.Duration("interval", false, false, 10*time.Minute, &n.interval)
+	cfg.Int("max_batch", false, false, 10000, &n.maxBatch)
+	cfg.Bool("add_header", false, true, &n.addHeader)
+	cfg.String("state_dir", false, false, "", &n.stateDir)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if n.stateDir == "" {
+		name := n.instName
+		if name == "" {
+			name = "default"
+		}
+		n.stateDir = filepath.Join(config.StateDirectory, "notary", name)
+	}
+	if !filepath.IsAbs(n.stateDir) {
+		n.stateDir = filepath.Join(config.StateDirectory, n.stateDir)
+	}
+
+	var err error
+	n.store, err = notary.OpenStore(n.stateDir)
+	if err != nil {
+		return fmt.Errorf("modify.notarize: %w", err)
+	}
+	pending, err := n.store.Pending()
+	if err != nil {
+		return fmt.Errorf("modify.notarize: %w", err)
+	}
+	n.pending = len(pending)
+
+	if module.NoRun {
+		return nil
+	}
+
+	var ctx context.Context
+	ctx, n.stop = context.WithCancel(context.Background())
+	n.flushCh = make(chan struct{}, 1)
+	n.stopped.Add(1)
+	go n.run(ctx)
+
+	return nil
+}
+
+func (n *Notarizer) Name() string {
+	return "modify.notarize"
+}
+
+func (n *Notarizer) InstanceName() string {
+	return n.instName
+}
+
+// Proof returns the inclusion proof for the hex-encoded message hash.
+func (n *Notarizer) Proof(msgHash string) (*notary.Proof, error) {
+	return n.store.Proof(msgHash)
+}
+
+func (n *Notarizer) run(ctx context.Context) {
+	defer n.stopped.Done()
+
+	t := time.NewTicker(n.interval)
+	defer t.Stop()
+
+	// Anchor batches left from the previous run.
+	n.anchor(ctx)
+
+	for {
+		select {
+		case <-t.C:
+		case <-n.flushCh:
+		case <-ctx.Done():
+			return
+		}
+		n.anchor(ctx)
+	}
+}
+
+// anchor seals the pending hashes into a batch and submits all batches that
+// are not anchored yet. Failed batches are skipped and retried on the next
+// call.
+func (n *Notarizer) anchor(ctx context.Context) {
+	n.storeLock.Lock()
+	batch, err := n.store.SealBatch()
+	if err == nil {
+		n.pending = 0
+	}
+	n.storeLock.Unlock()
+	if err != nil {
+		n.log.Error("failed to seal batch", err)
+		return
+	}
+	if batch != nil {
+		n.log.DebugMsg("batch sealed", "root", batch.Root, "leaves", len(batch.Leaves))
+	}
+
+	batches, err := n.store.Batches()
+	if err != nil {
+		n.log.Error("failed to list batches", err)
+		return
+	}
+	for _, b := range batches {
+		if ctx.Err() != nil {
+			// Stopping, the rest is anchored after the next start.
+			return
+		}
+
+		anchorCtx, cancel := context.WithTimeout(ctx, anchorTimeout)
+		txHash, err := n.chain.AnchorRoot(anchorCtx, b.Root, uint64(len(b.Leaves)))
+		cancel()
+		if errors.Is(err, module.ErrRootAnchored) {
+			// Anchored by a previous attempt that did not save proofs.
+			err = nil
+		}
+		if err != nil {
+			n.log.Error("failed to anchor batch, will retry later", err, "root", b.Root)
+			continue
+		}
+
+		n.storeLock.Lock()
+		err = n.store.MarkAnchored(b, txHash)
+		n.storeLock.Unlock()
+		if err != nil {
+			n.log.Error("failed to save proofs", err, "root", b.Root)
+			continue
+		}
+		n.log.Msg("batch anchored", "root", b.Root, "leaves", len(b.Leaves), "tx", txHash)
+	}
+}
+
+func (n *Notarizer) add(msgHash []byte) error {
+	n.storeLock.Lock()
+	defer n.storeLock.Unlock()
+
+	if err := n.store.AddPending(msgHash); err != nil {
+		return err
+	}
+	n.pending++
+
+	if n.pending >= n.maxBatch && n.flushCh != nil {
+		select {
+		case n.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (n *Notarizer) Close() error {
+	if n.stop != nil {
+		// Interrupts the running anchor, the batch is anchored after the
+		// next start, see module.ErrRootAnchored.
+		n.stop()
+		n.stopped.Wait()
+	}
+	if n.store == nil {
+		return nil
+	}
+	return n.store.Close()
+}
+
+// notarizeState computes the message hash in RewriteBody, the hash is
+// recorded once the message is accepted, see module.ModifierStateCommitter.
+type notarizeState struct {
+	n       *Notarizer
+	msgHash []byte
+}
+
+func (n *Notarizer) ModStateForMsg(ctx context.Context, msgMeta *module.MsgMetadata) (module.ModifierState, error) {
+	return &notarizeState{n: n}, nil
+}
+
+func (s *notarizeState) RewriteSender(ctx context.Context, mailFrom string) (string, error) {
+	return mailFrom, nil
+}
+
+func (s *notarizeState) RewriteRcpt(ctx context.Context, rcptTo string) ([]string, error) {
+	return []string{rcptTo}, nil
+}
+
+func (s *notarizeState) RewriteBody(ctx context.Context, h *textproto.Header, body buffer.Buffer) error {
+	r, err := body.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	s.msgHash, err = notary.HashMessage(*h, r)
+	if err != nil {
+		return err
+	}
+
+	if s.n.addHeader {
+		h.Add(notary.HeaderField, notary.FormatHeaderValue(s.msgHash))
+	}
+	return nil
+}
+
+func (s *notarizeState) Committed(ctx context.Context) {
+	if s.msgHash == nil {
+		return
+	}
+	if err := s.n.add(s.msgHash); err != nil {
+		// The message is accepted already, it is not notarized.
+		s.n.log.Error("failed to record message hash", err, "hash", hex.EncodeToString(s.msgHash))
+		return
+	}
+	s.n.log.DebugMsg("message hash recorded", "hash", hex.EncodeToString(s.msgHash))
+}
+
+func (s *notarizeState) Close() error {
+	return nil
+}
+
+func init() {
+	module.Register("modify.notarize", NewNotarizer)
+}
@@ -0,0 +1,269 @@
+package modify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/notary"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+type testAnchor struct {
+	roots     map[string]uint64
+	fail      bool
+	failRoots map[string]bool
+}
+
+func (a *testAnchor) AnchorRoot(_ context.Context, root string, leafCount uint64) (string, error) {
+	if a.fail || a.failRoots[root] {
+		return "", errors.New("chain unavailable")
+	}
+	if _, ok := a.roots[root]; ok {
+		return "", module.ErrRootAnchored
+	}
+	a.roots[root] = leafCount
+	return "TX" + root[:8], nil
+}
+
+// slowAnchor blocks AnchorRoot until the context is done.
+type slowAnchor struct {
+	started chan struct{}
+}
+
+func (a *slowAnchor) AnchorRoot(ctx context.Context, _ string, _ uint64) (string, error) {
+	select {
+	case a.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestNotarize(t *testing.T) {
+	store, err := notary.OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &testAnchor{roots: map[string]uint64{}, fail: true}
+	n := &Notarizer{
+		log:       testutils.Logger(t, "modify.notarize"),
+		chain:     chain,
+		maxBatch:  100,
+		addHeader: true,
+		store:     store,
+	}
+	defer n.Close()
+
+	var stored []string
+	for _, subject := range []string{"First", "Second", "Third"} {
+		h := textproto.Header{}
+		h.Add("From", "<a@example.org>")
+		h.Add("Subject", subject)
+		body := buffer.MemoryBuffer{Slice: []byte("Hello!\r\n")}
+
+		state, err := n.ModStateForMsg(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := state.RewriteBody(context.Background(), &h, body); err != nil {
+			t.Fatal(err)
+		}
+		state.(module.ModifierStateCommitter).Committed(context.Background())
+
+		var msg bytes.Buffer
+		if err := textproto.WriteHeader(&msg, h); err != nil {
+			t.Fatal(err)
+		}
+		msg.Write(body.Slice)
+		stored = append(stored, msg.String())
+	}
+
+	// Batch is kept until the chain is available.
+	n.anchor(context.Background())
+	if len(chain.roots) != 0 {
+		t.Fatal("unexpected anchors:", chain.roots)
+	}
+	chain.fail = false
+	n.anchor(context.Background())
+	if len(chain.roots) != 1 {
+		t.Fatal("expected one anchor, got", chain.roots)
+	}
+
+	for _, msg := range stored {
+		computed, recorded, err := notary.HashNotarized(strings.NewReader(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(computed, recorded) {
+			t.Fatal("hash mismatch")
+		}
+
+		p, err := n.Proof(hex.EncodeToString(recorded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Verify(computed); err != nil {
+			t.Fatal(err)
+		}
+		if chain.roots[p.Root] != 3 {
+			t.Fatal("proof root is not anchored:", p.Root)
+		}
+	}
+}
+
+func TestNotarize_NotCommitted(t *testing.T) {
+	store, err := notary.OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &Notarizer{
+		log:       testutils.Logger(t, "modify.notarize"),
+		chain:     &testAnchor{roots: map[string]uint64{}},
+		maxBatch:  100,
+		addHeader: true,
+		store:     store,
+	}
+	defer n.Close()
+
+	h := textproto.Header{}
+	h.Add("From", "<a@example.org>")
+	state, err := n.ModStateForMsg(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.RewriteBody(context.Background(), &h, buffer.MemoryBuffer{Slice: []byte("Hello!\r\n")}); err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+
+	// The message is aborted, its hash should not be anchored.
+	pending, err := store.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatal("hash of the aborted message is recorded")
+	}
+}
+
+func TestNotarize_FailedBatch(t *testing.T) {
+	store, err := notary.OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &testAnchor{roots: map[string]uint64{}, fail: true, failRoots: map[string]bool{}}
+	n := &Notarizer{
+		log:      testutils.Logger(t, "modify.notarize"),
+		chain:    chain,
+		maxBatch: 100,
+		store:    store,
+	}
+	defer n.Close()
+
+	first := sha256.Sum256([]byte("first"))
+	second := sha256.Sum256([]byte("second"))
+
+	if err := n.add(first[:]); err != nil {
+		t.Fatal(err)
+	}
+	n.anchor(context.Background())
+	batches, err := store.Batches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 {
+		t.Fatal("expected one batch, got", len(batches))
+	}
+	firstRoot := batches[0].Root
+
+	// The failing batch does not block the next one.
+	chain.fail = false
+	chain.failRoots[firstRoot] = true
+	if err := n.add(second[:]); err != nil {
+		t.Fatal(err)
+	}
+	n.anchor(context.Background())
+	if _, err := n.Proof(hex.EncodeToString(second[:])); err != nil {
+		t.Fatal("second batch is not anchored:", err)
+	}
+	if _, err := n.Proof(hex.EncodeToString(first[:])); !errors.Is(err, notary.ErrNoProof) {
+		t.Fatal("expected ErrNoProof for the failed batch, got", err)
+	}
+
+	// The root is anchored by an attempt that did not report the result.
+	delete(chain.failRoots, firstRoot)
+	chain.roots[firstRoot] = 1
+	n.anchor(context.Background())
+	if _, err := n.Proof(hex.EncodeToString(first[:])); err != nil {
+		t.Fatal("already anchored batch is not marked as anchored:", err)
+	}
+	batches, err = store.Batches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 0 {
+		t.Fatal("unexpected batches left:", len(batches))
+	}
+}
+
+func TestNotarize_CloseDuringAnchor(t *testing.T) {
+	dir := t.TempDir()
+	store, err := notary.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &slowAnchor{started: make(chan struct{}, 1)}
+	n := &Notarizer{
+		log:      testutils.Logger(t, "modify.notarize"),
+		chain:    chain,
+		interval: time.Hour,
+		maxBatch: 100,
+		store:    store,
+		flushCh:  make(chan struct{}, 1),
+	}
+	msgHash := sha256.Sum256([]byte("message"))
+	if err := n.add(msgHash[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	var ctx context.Context
+	ctx, n.stop = context.WithCancel(context.Background())
+	n.stopped.Add(1)
+	go n.run(ctx)
+	<-chain.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- n.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waits for the anchor")
+	}
+
+	// The batch is kept to be anchored after the next start.
+	store, err = notary.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	batches, err := store.Batches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || len(batches[0].Leaves) != 1 {
+		t.Fatalf("wrong batches: %+v", batches)
+	}
+}
@@ -20,6 +20,7 @@ package msgpipeline
 
 import (
 	"context"
+	"net"
 	"runtime/debug"
 	"sync"
 
@@ -48,6 +49,7 @@ type checkRunner struct {
 	doDMARC       bool
 	didDMARCFetch bool
 	dmarcVerify   *dmarc.Verifier
+	dmarcReporter dmarc.Reporter
 
 	log log.Logger
 
@@ -296,6 +298,7 @@ func (cr *checkRunner) applyResults(hostname string, header *textproto.Header) e
 	if cr.doDMARC {
 		dmarcRes, policy := cr.dmarcVerify.Apply(cr.mergedRes.AuthResult)
 		cr.mergedRes.AuthResult = append(cr.mergedRes.AuthResult, &dmarcRes.Authres)
+		cr.reportDMARC(hostname, *header, dmarcRes, policy)
 		switch policy {
 		case dmarc.PolicyReject:
 			code := 550
@@ -341,6 +344,37 @@ func (cr *checkRunner) applyResults(hostname string, header *textproto.Header) e
 	return nil
 }
 
+// reportDMARC passes the DMARC evaluation result to the reporter, if any.
+func (cr *checkRunner) reportDMARC(hostname string, header textproto.Header, res dmarc.EvalResult, policy dmarc.Policy) {
+	if cr.dmarcReporter == nil || cr.msgMeta.Conn == nil {
+		return
+	}
+	ip, ok := cr.msgMeta.Conn.RemoteAddr.(*net.TCPAddr)
+	if !ok {
+		return
+	}
+
+	rec, ok := cr.dmarcVerify.ReportRecord(cr.mergedRes.AuthResult, res, policy)
+	if !ok {
+		return
+	}
+	rec.SourceIP = ip.IP.String()
+	if err := cr.dmarcReporter.RecordResult(rec); err != nil {
+		cr.log.Error("failed to record DMARC result", err)
+	}
+
+	failure, ok := cr.dmarcVerify.FailureReport(rec)
+	if !ok {
+		return
+	}
+	failure.MailFrom = cr.mailFrom
+	failure.Header = header.Copy()
+	failure.AuthResults = authres.Format(hostname, cr.mergedRes.AuthResult)
+	if err := cr.dmarcReporter.ReportFailure(failure); err != nil {
+		cr.log.Error("failed to generate DMARC failure report", err)
+	}
+}
+
 func (cr *checkRunner) close() {
 	cr.dmarcVerify.Close()
 	for _, state := range cr.states {
@@ -29,6 +29,7 @@ import (
 	"github.com/dsoftgames/MailChat/framework/dns"
 	"github.com/dsoftgames/MailChat/framework/exterrors"
 	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/dmarc"
 	"github.com/dsoftgames/MailChat/internal/modify"
 )
 
@@ -44,6 +45,7 @@ type msgpipelineCfg struct {
 	perSource       map[string]sourceBlock
 	defaultSource   sourceBlock
 	doDMARC         bool
+	dmarcReporter   dmarc.Reporter
 }
 
 func parseMsgPipelineRootCfg(globals map[string]interface{}, nodes []config.Node) (msgpipelineCfg, error) {
@@ -129,6 +131,10 @@ func parseMsgPipelineRootCfg(globals map[string]interface{}, nodes []config.Node
 			case 0:
 				cfg.doDMARC = true
 			}
+		case "dmarc_reports":
+			if err := modconfig.ModuleFromNode("dmarc", node.Args, node, globals, &cfg.dmarcReporter); err != nil {
+				return msgpipelineCfg{}, err
+			}
 		case "deliver_to", "reroute", "destination_in", "destination", "default_destination", "reject":
 			othersRaw = append(othersRaw, node)
 		default:
@@ -383,6 +389,19 @@ func parseChecksGroup(globals map[string]interface{}, node config.Node) ([]modul
 }
 
 func parseModifiersGroup(globals map[string]interface{}, node config.Node) (modify.Group, error) {
+	// Reference to a top-level modifier that is not a group, e.g.
+	// 'modify &notary'.
+	if len(node.Args) == 1 && strings.HasPrefix(node.Args[0], "&") {
+		var mod module.Modifier
+		if err := modconfig.ModuleFromNode("modify", node.Args, node, globals, &mod); err != nil {
+			return modify.Group{}, err
+		}
+		if mg, ok := mod.(*modify.Group); ok {
+			return *mg, nil
+		}
+		return modify.Group{Modifiers: []module.Modifier{mod}}, nil
+	}
+
 	// Module object is *modify.Group, not modify.Group.
 	var mg *modify.Group
 	err := modconfig.GroupFromNode("modifiers", node.Args, node, globals, &mg)
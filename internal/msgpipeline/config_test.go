@@ -25,6 +25,8 @@ import (
 
 	parser "github.com/dsoftgames/MailChat/framework/cfgparser"
 	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
 )
 
 func policyError(code int) error {
@@ -438,3 +440,31 @@ func TestMsgPipelineCfg_RcptChecks_Multiple(t *testing.T) {
 		t.Fatalf("wrong amount of test_check's in rcpt checks: %d", len(parsed.defaultSource.perRcpt["example.org"].checks))
 	}
 }
+
+func TestMsgPipelineCfg_ModifierReference(t *testing.T) {
+	mod := &testutils.Modifier{InstName: "test_modifier_ref"}
+	module.RegisterInstance(mod, nil)
+	module.MarkInitialized("test_modifier_ref")
+
+	str := `
+		modify &test_modifier_ref
+		default_destination {
+			modify &test_modifier_ref
+			reject 500
+		}
+	`
+
+	cfg, _ := parser.Read(strings.NewReader(str), "literal")
+	parsed, err := parseMsgPipelineRootCfg(nil, cfg)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	if len(parsed.globalModifiers.Modifiers) != 1 || parsed.globalModifiers.Modifiers[0] != mod {
+		t.Fatalf("wrong global modifiers: %v", parsed.globalModifiers.Modifiers)
+	}
+	rcptMods := parsed.defaultSource.defaultRcpt.modifiers.Modifiers
+	if len(rcptMods) != 1 || rcptMods[0] != mod {
+		t.Fatalf("wrong destination modifiers: %v", rcptMods)
+	}
+}
@@ -19,6 +19,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package msgpipeline
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -707,3 +708,67 @@ func TestMsgPipeline_RcptModifier_Errors(t *testing.T) {
 			mod.UnclosedStates, globalMod.UnclosedStates, sourceMod.UnclosedStates)
 	}
 }
+
+// committerModifier counts messages reported as accepted using
+// module.ModifierStateCommitter.
+type committerModifier struct {
+	testutils.Modifier
+	committed *int
+}
+
+type committerState struct {
+	module.ModifierState
+	committed *int
+}
+
+func (m committerModifier) ModStateForMsg(ctx context.Context, msgMeta *module.MsgMetadata) (module.ModifierState, error) {
+	state, err := m.Modifier.ModStateForMsg(ctx, msgMeta)
+	if err != nil {
+		return nil, err
+	}
+	return committerState{ModifierState: state, committed: m.committed}, nil
+}
+
+func (s committerState) Committed(context.Context) {
+	*s.committed++
+}
+
+func TestMsgPipeline_ModifierCommitted(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		target    testutils.Target
+		committed int
+	}{
+		{name: "commit", committed: 1},
+		{name: "body error", target: testutils.Target{BodyErr: errors.New("body failed")}},
+		{name: "commit error", target: testutils.Target{CommitErr: errors.New("commit failed")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			committed := 0
+			modifier := committerModifier{
+				Modifier:  testutils.Modifier{InstName: "test_modifier"},
+				committed: &committed,
+			}
+			d := MsgPipeline{
+				msgpipelineCfg: msgpipelineCfg{
+					globalModifiers: modify.Group{
+						Modifiers: []module.Modifier{modifier},
+					},
+					perSource: map[string]sourceBlock{},
+					defaultSource: sourceBlock{
+						perRcpt: map[string]*rcptBlock{},
+						defaultRcpt: &rcptBlock{
+							targets: []module.DeliveryTarget{&tc.target},
+						},
+					},
+				},
+				Log: testutils.Logger(t, "msgpipeline"),
+			}
+
+			testutils.DoTestDeliveryErr(t, &d, "sender@example.com", []string{"rcpt@example.com"})
+			if committed != tc.committed {
+				t.Fatalf("Committed called %d times, want %d", committed, tc.committed)
+			}
+		})
+	}
+}
@@ -127,6 +127,7 @@ func (d *MsgPipeline) Start(ctx context.Context, msgMeta *module.MsgMetadata, ma
 	}
 	dd.checkRunner = newCheckRunner(msgMeta, dd.log, d.Resolver)
 	dd.checkRunner.doDMARC = d.doDMARC
+	dd.checkRunner.dmarcReporter = d.dmarcReporter
 
 	if msgMeta.OriginalRcpts == nil {
 		msgMeta.OriginalRcpts = map[string]string{}
@@ -502,7 +503,7 @@ func (dd *msgpipelineDelivery) BodyNonAtomic(ctx context.Context, c module.Statu
 }
 
 func (dd msgpipelineDelivery) Commit(ctx context.Context) error {
-	dd.close()
+	defer dd.close()
 
 	for _, delivery := range dd.deliveries {
 		if err := delivery.Commit(ctx); err != nil {
@@ -510,9 +511,25 @@ func (dd msgpipelineDelivery) Commit(ctx context.Context) error {
 			return err
 		}
 	}
+
+	dd.committed(ctx)
 	return nil
 }
 
+// committed notifies modifiers that the message is accepted, see
+// module.ModifierStateCommitter.
+func (dd *msgpipelineDelivery) committed(ctx context.Context) {
+	states := []module.ModifierState{dd.globalModifiersState, dd.sourceModifiersState}
+	for _, modifiers := range dd.rcptModifiersState {
+		states = append(states, modifiers)
+	}
+	for _, state := range states {
+		if committer, ok := state.(module.ModifierStateCommitter); ok {
+			committer.Committed(ctx)
+		}
+	}
+}
+
 func (dd *msgpipelineDelivery) close() {
 	dd.checkRunner.close()
 
@@ -0,0 +1,125 @@
+package notary
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+// HeaderField is the header field added to notarized messages. It carries
+// the hash of the message as it was at the time of acceptance.
+//
+// Header fields above it (including the field itself) are not covered by
+// the hash since they are prepended later.
+const HeaderField = "X-MailChat-Notary"
+
+var ErrNoHeader = errors.New("notary: message has no " + HeaderField + " field")
+
+// crlfWriter converts bare LF line endings to CRLF so the hash does not
+// depend on the line endings used by the program that saved the message.
+type crlfWriter struct {
+	w      io.Writer
+	lastCR bool
+}
+
+func (c *crlfWriter) Write(b []byte) (int, error) {
+	start := 0
+	for i, ch := range b {
+		if ch != '\n' {
+			continue
+		}
+		prevCR := c.lastCR
+		if i > 0 {
+			prevCR = b[i-1] == '\r'
+		}
+		if prevCR {
+			continue
+		}
+		if _, err := c.w.Write(b[start:i]); err != nil {
+			return 0, err
+		}
+		if _, err := c.w.Write([]byte{'\r', '\n'}); err != nil {
+			return 0, err
+		}
+		start = i + 1
+	}
+	if _, err := c.w.Write(b[start:]); err != nil {
+		return 0, err
+	}
+	if len(b) != 0 {
+		c.lastCR = b[len(b)-1] == '\r'
+	}
+	return len(b), nil
+}
+
+// HashMessage computes the SHA-256 hash of the message header and body with
+// line endings normalized to CRLF.
+func HashMessage(h textproto.Header, body io.Reader) ([]byte, error) {
+	hash := sha256.New()
+	w := &crlfWriter{w: hash}
+	if err := textproto.WriteHeader(w, h); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// FormatHeaderValue returns the HeaderField value for the message hash.
+func FormatHeaderValue(msgHash []byte) string {
+	return "sha256=" + hex.EncodeToString(msgHash)
+}
+
+// ParseHeaderValue extracts the message hash from the HeaderField value.
+func ParseHeaderValue(v string) ([]byte, error) {
+	alg, value, ok := strings.Cut(strings.TrimSpace(v), "=")
+	if !ok || !strings.EqualFold(alg, "sha256") {
+		return nil, fmt.Errorf("notary: malformed %s value: %q", HeaderField, v)
+	}
+	msgHash, err := hex.DecodeString(value)
+	if err != nil || len(msgHash) != sha256.Size {
+		return nil, fmt.Errorf("notary: malformed %s value: %q", HeaderField, v)
+	}
+	return msgHash, nil
+}
+
+// HashNotarized reads a notarized message and recomputes its hash the way
+// it was computed on acceptance: the topmost HeaderField and all fields
+// above it are removed. Both the recomputed hash and the hash recorded in the
+// header are returned.
+func HashNotarized(r io.Reader) (computed, recorded []byte, err error) {
+	br := bufio.NewReader(r)
+	h, err := textproto.ReadHeader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := h.Fields()
+	for fields.Next() {
+		key, value := fields.Key(), fields.Value()
+		fields.Del()
+		if strings.EqualFold(key, HeaderField) {
+			recorded, err = ParseHeaderValue(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			break
+		}
+	}
+	if recorded == nil {
+		return nil, nil, ErrNoHeader
+	}
+
+	computed, err = HashMessage(h, br)
+	if err != nil {
+		return nil, nil, err
+	}
+	return computed, recorded, nil
+}
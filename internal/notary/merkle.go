@@ -0,0 +1,112 @@
+// Package notary implements message notarization: messages are hashed on
+// acceptance, hashes are batched into Merkle trees and tree roots are
+// anchored on chain. Per-message inclusion proofs allow to show that a
+// message existed at the time its batch was anchored.
+package notary
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Tree hashing follows RFC 9162, Section 2.1. Leaves are message hashes,
+// leaf and interior nodes use distinct prefixes to prevent second preimage
+// attacks.
+
+func leafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Tree is a Merkle tree built over a fixed list of leaves.
+type Tree struct {
+	// levels[0] contains leaf hashes, the last level contains the root.
+	levels [][][]byte
+}
+
+// NewTree builds the tree over leaves. At least one leaf is required.
+func NewTree(leaves [][]byte) *Tree {
+	level := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		level[i] = leafHash(l)
+	}
+
+	t := &Tree{levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, nodeHash(level[i], level[i+1]))
+		}
+		// Unpaired node is promoted as is, this yields the same root as the
+		// recursive definition from RFC 9162.
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// Size returns the number of leaves in the tree.
+func (t *Tree) Size() int {
+	return len(t.levels[0])
+}
+
+// Root returns the tree root hash.
+func (t *Tree) Root() []byte {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Path returns the inclusion proof (audit path) for the leaf at index.
+func (t *Tree) Path(index int) [][]byte {
+	var path [][]byte
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			path = append(path, level[sibling])
+		}
+		index >>= 1
+	}
+	return path
+}
+
+// VerifyPath checks that leaf is included at index in the tree of size
+// leaves with the specified root, using the algorithm from RFC 9162,
+// Section 2.1.3.2.
+func VerifyPath(leaf []byte, index, size uint64, path [][]byte, root []byte) bool {
+	if index >= size {
+		return false
+	}
+
+	fn, sn := index, size-1
+	r := leafHash(leaf)
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(r, root)
+}
@@ -0,0 +1,164 @@
+package notary
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		h := sha256.Sum256([]byte{byte(i), byte(i >> 8)})
+		leaves[i] = h[:]
+	}
+	return leaves
+}
+
+// recursiveRoot is the MTH definition from RFC 9162, Section 2.1.1.
+func recursiveRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leafHash(leaves[0])
+	}
+	k := 1
+	for k<<1 < len(leaves) {
+		k <<= 1
+	}
+	return nodeHash(recursiveRoot(leaves[:k]), recursiveRoot(leaves[k:]))
+}
+
+func TestTree(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		leaves := testLeaves(n)
+		tree := NewTree(leaves)
+		if !bytes.Equal(tree.Root(), recursiveRoot(leaves)) {
+			t.Fatalf("n=%d: root mismatch", n)
+		}
+
+		for i := range leaves {
+			path := tree.Path(i)
+			if !VerifyPath(leaves[i], uint64(i), uint64(n), path, tree.Root()) {
+				t.Fatalf("n=%d, i=%d: valid path rejected", n, i)
+			}
+			if VerifyPath(leaves[(i+1)%n], uint64(i), uint64(n), path, tree.Root()) && n > 1 {
+				t.Fatalf("n=%d, i=%d: path accepted for a wrong leaf", n, i)
+			}
+			if VerifyPath(leaves[i], uint64((i+1)%n), uint64(n), path, tree.Root()) && n > 1 {
+				t.Fatalf("n=%d, i=%d: path accepted for a wrong index", n, i)
+			}
+		}
+	}
+}
+
+func TestHashNotarized(t *testing.T) {
+	msg := "From: <a@example.org>\r\n" +
+		"Subject: Test\r\n" +
+		"\r\n" +
+		"Hello!\r\n"
+
+	hdr, err := textproto.ReadHeader(bufioReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := msg[strings.Index(msg, "\r\n\r\n")+4:]
+	msgHash, err := HashMessage(hdr, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields prepended after notarization are ignored as well as line
+	// endings conversion done by the mail client.
+	stored := "DKIM-Signature: v=1; dummy\r\n" +
+		HeaderField + ": " + FormatHeaderValue(msgHash) + "\r\n" + msg
+	stored = strings.ReplaceAll(stored, "\r\n", "\n")
+
+	computed, recorded, err := HashNotarized(strings.NewReader(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(computed, msgHash) || !bytes.Equal(recorded, msgHash) {
+		t.Fatalf("hash mismatch: %x, %x, %x", computed, recorded, msgHash)
+	}
+
+	tampered := strings.Replace(stored, "Hello!", "Hello?", 1)
+	computed, _, err = HashNotarized(strings.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(computed, msgHash) {
+		t.Fatal("hash of tampered message matches")
+	}
+
+	if _, _, err := HashNotarized(strings.NewReader(msg)); !errors.Is(err, ErrNoHeader) {
+		t.Fatal("expected ErrNoHeader, got", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	leaves := testLeaves(5)
+	for _, l := range leaves {
+		if err := s.AddPending(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := s.SealBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == nil || len(b.Leaves) != 5 {
+		t.Fatal("unexpected batch:", b)
+	}
+	if b, err := s.SealBatch(); err != nil || b != nil {
+		t.Fatal("expected no batch, got", b, err)
+	}
+
+	msgHash := hex.EncodeToString(leaves[3])
+	if _, err := s.Proof(msgHash); !errors.Is(err, ErrNoProof) {
+		t.Fatal("expected ErrNoProof, got", err)
+	}
+
+	batches, err := s.Batches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || batches[0].Root != b.Root {
+		t.Fatal("unexpected batches:", batches)
+	}
+	if err := s.MarkAnchored(batches[0], "ABCD"); err != nil {
+		t.Fatal(err)
+	}
+	if batches, err := s.Batches(); err != nil || len(batches) != 0 {
+		t.Fatal("batch is not removed:", batches, err)
+	}
+
+	p, err := s.Proof(msgHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TxHash != "ABCD" || p.Root != b.Root {
+		t.Fatal("unexpected proof:", p)
+	}
+	if err := p.Verify(leaves[3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Verify(leaves[2]); err == nil {
+		t.Fatal("proof accepted for a wrong message")
+	}
+}
+
+func bufioReader(s string) *bufio.Reader {
+	return bufio.NewReader(strings.NewReader(s))
+}
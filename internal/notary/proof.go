@@ -0,0 +1,54 @@
+package notary
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Proof is the inclusion proof of a message hash in an anchored batch.
+type Proof struct {
+	// MessageHash is the hex-encoded message hash, see HashMessage.
+	MessageHash string `json:"message_hash"`
+	// Index is the position of the message hash in the batch.
+	Index uint64 `json:"index"`
+	// TreeSize is the amount of message hashes in the batch.
+	TreeSize uint64 `json:"tree_size"`
+	// Path is the hex-encoded audit path.
+	Path []string `json:"path"`
+	// Root is the hex-encoded Merkle root anchored on chain.
+	Root string `json:"root"`
+	// TxHash is the hash of the transaction that anchored the root.
+	TxHash string `json:"tx_hash,omitempty"`
+}
+
+// Verify checks that the proof is valid for the message hash. It does not
+// check that Root is actually anchored.
+func (p *Proof) Verify(msgHash []byte) error {
+	recorded, err := hex.DecodeString(p.MessageHash)
+	if err != nil {
+		return fmt.Errorf("notary: malformed proof: %w", err)
+	}
+	if !bytes.Equal(recorded, msgHash) {
+		return errors.New("notary: proof is for a different message")
+	}
+
+	root, err := hex.DecodeString(p.Root)
+	if err != nil {
+		return fmt.Errorf("notary: malformed proof: %w", err)
+	}
+	path := make([][]byte, 0, len(p.Path))
+	for _, node := range p.Path {
+		raw, err := hex.DecodeString(node)
+		if err != nil {
+			return fmt.Errorf("notary: malformed proof: %w", err)
+		}
+		path = append(path, raw)
+	}
+
+	if !VerifyPath(msgHash, p.Index, p.TreeSize, path, root) {
+		return errors.New("notary: inclusion proof does not match the root")
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package notary
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Batch is a sealed set of message hashes waiting to be anchored.
+type Batch struct {
+	Root    string    `json:"root"`
+	Leaves  []string  `json:"leaves"`
+	Created time.Time `json:"created"`
+}
+
+// Store keeps notarization state in a directory:
+//
+//	pending          - hex-encoded hashes of messages not yet in a batch
+//	batches/ROOT     - sealed batches not yet anchored
+//	proofs/XX/HASH   - inclusion proofs for anchored messages
+//
+// Store is not safe for concurrent use.
+type Store struct {
+	dir     string
+	pending *os.File
+}
+
+// OpenStore opens or creates the store in the directory.
+func OpenStore(dir string) (*Store, error) {
+	for _, sub := range []string{"batches", "proofs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	pending, err := os.OpenFile(filepath.Join(dir, "pending"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, pending: pending}, nil
+}
+
+// AddPending records the message hash to be included in the next batch.
+func (s *Store) AddPending(msgHash []byte) error {
+	if _, err := s.pending.WriteString(hex.EncodeToString(msgHash) + "\n"); err != nil {
+		return err
+	}
+	return s.pending.Sync()
+}
+
+// Pending returns hashes recorded using AddPending since the last
+// SealBatch.
+func (s *Store) Pending() ([][]byte, error) {
+	if _, err := s.pending.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	var hashes [][]byte
+	scanner := bufio.NewScanner(s.pending)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		msgHash, err := hex.DecodeString(line)
+		if err != nil {
+			// Partially written line, message was not accepted.
+			continue
+		}
+		hashes = append(hashes, msgHash)
+	}
+	return hashes, scanner.Err()
+}
+
+// SealBatch builds a batch over all pending hashes and clears the pending
+// list. It returns nil if there are no pending hashes.
+func (s *Store) SealBatch() (*Batch, error) {
+	hashes, err := s.Pending()
+	if err != nil {
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	b := &Batch{
+		Root:    hex.EncodeToString(NewTree(hashes).Root()),
+		Leaves:  make([]string, 0, len(hashes)),
+		Created: time.Now().UTC(),
+	}
+	for _, h := range hashes {
+		b.Leaves = append(b.Leaves, hex.EncodeToString(h))
+	}
+	if err := writeJSON(filepath.Join(s.dir, "batches", b.Root), b); err != nil {
+		return nil, err
+	}
+
+	if err := s.pending.Truncate(0); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Batches returns all sealed batches that are not anchored yet, oldest
+// first.
+func (s *Store) Batches() ([]*Batch, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "batches"))
+	if err != nil {
+		return nil, err
+	}
+	batches := make([]*Batch, 0, len(entries))
+	for _, ent := range entries {
+		if strings.HasSuffix(ent.Name(), ".tmp") {
+			continue
+		}
+		b := &Batch{}
+		if err := readJSON(filepath.Join(s.dir, "batches", ent.Name()), b); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].Created.Before(batches[j].Created)
+	})
+	return batches, nil
+}
+
+// MarkAnchored writes inclusion proofs for all messages in the batch and
+// removes the batch from the list of batches to anchor.
+func (s *Store) MarkAnchored(b *Batch, txHash string) error {
+	leaves := make([][]byte, 0, len(b.Leaves))
+	for _, l := range b.Leaves {
+		raw, err := hex.DecodeString(l)
+		if err != nil {
+			return fmt.Errorf("notary: malformed batch %s: %w", b.Root, err)
+		}
+		leaves = append(leaves, raw)
+	}
+
+	tree := NewTree(leaves)
+	for i, l := range b.Leaves {
+		p := Proof{
+			MessageHash: l,
+			Index:       uint64(i),
+			TreeSize:    uint64(tree.Size()),
+			Path:        make([]string, 0, 32),
+			Root:        b.Root,
+			TxHash:      txHash,
+		}
+		for _, node := range tree.Path(i) {
+			p.Path = append(p.Path, hex.EncodeToString(node))
+		}
+		if err := os.MkdirAll(filepath.Join(s.dir, "proofs", l[:2]), 0o700); err != nil {
+			return err
+		}
+		if err := writeJSON(s.proofPath(l), &p); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(filepath.Join(s.dir, "batches", b.Root))
+}
+
+func (s *Store) proofPath(msgHash string) string {
+	return filepath.Join(s.dir, "proofs", msgHash[:2], msgHash)
+}
+
+// ErrNoProof is returned by Store.Proof if the message is not anchored
+// (yet).
+var ErrNoProof = errors.New("notary: no proof for the message")
+
+// Proof returns the inclusion proof for the hex-encoded message hash.
+func (s *Store) Proof(msgHash string) (*Proof, error) {
+	msgHash = strings.ToLower(msgHash)
+	if raw, err := hex.DecodeString(msgHash); err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("notary: malformed message hash: %q", msgHash)
+	}
+
+	p := &Proof{}
+	if err := readJSON(s.proofPath(msgHash), p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoProof
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) Close() error {
+	return s.pending.Close()
+}
+
+func writeJSON(path string, v interface{}) error {
+	blob, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", blob, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func readJSON(path string, v interface{}) error {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, v)
+}
@@ -0,0 +1,154 @@
+// Package reportstore implements the storage for the data collected for
+// periodic reports, such as DMARC aggregate reports and SMTP TLS reports.
+package reportstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// Record is implemented by values kept in the Store.
+type Record interface {
+	// RecordTime returns the time the record was collected at.
+	RecordTime() time.Time
+}
+
+// Store keeps records in a directory:
+//
+//	records/YYYY-MM-DD  - JSON-encoded records for the UTC day, one per line
+//	reported_until      - end of the last reporting period
+//
+// Store is not safe for concurrent use.
+type Store[T Record] struct {
+	dir string
+
+	day     string
+	dayFile *os.File
+}
+
+// Open opens or creates the store in the directory.
+func Open[T Record](dir string) (*Store[T], error) {
+	if err := os.MkdirAll(filepath.Join(dir, "records"), 0o700); err != nil {
+		return nil, err
+	}
+	return &Store[T]{dir: dir}, nil
+}
+
+// Add appends the record to the file for the day it was collected at.
+func (s *Store[T]) Add(rec T) error {
+	day := rec.RecordTime().UTC().Format(dayLayout)
+	if s.day != day {
+		if s.dayFile != nil {
+			s.dayFile.Close()
+			s.dayFile = nil
+		}
+		f, err := os.OpenFile(filepath.Join(s.dir, "records", day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		s.day = day
+		s.dayFile = f
+	}
+
+	blob, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.dayFile.Write(append(blob, '\n'))
+	return err
+}
+
+// Records returns all records with time in the [begin, end) range.
+func (s *Store[T]) Records(begin, end time.Time) ([]T, error) {
+	var records []T
+	for day := begin.UTC().Truncate(24 * time.Hour); day.Before(end); day = day.Add(24 * time.Hour) {
+		f, err := os.Open(filepath.Join(s.dir, "records", day.Format(dayLayout)))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+		for scanner.Scan() {
+			var rec T
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				// Partially written line.
+				continue
+			}
+			if t := rec.RecordTime(); t.Before(begin) || !t.Before(end) {
+				continue
+			}
+			records = append(records, rec)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// Prune removes records for days that ended before the specified time.
+func (s *Store[T]) Prune(before time.Time) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "records"))
+	if err != nil {
+		return err
+	}
+	for _, ent := range entries {
+		day, err := time.Parse(dayLayout, ent.Name())
+		if err != nil {
+			continue
+		}
+		if day.Add(24 * time.Hour).After(before) {
+			continue
+		}
+		if ent.Name() == s.day {
+			s.dayFile.Close()
+			s.dayFile = nil
+			s.day = ""
+		}
+		if err := os.Remove(filepath.Join(s.dir, "records", ent.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportedUntil returns the end of the last reporting period. Zero time is
+// returned if no reports were sent yet.
+func (s *Store[T]) ReportedUntil() (time.Time, error) {
+	blob, err := os.ReadFile(filepath.Join(s.dir, "reported_until"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(blob)))
+}
+
+func (s *Store[T]) SetReportedUntil(t time.Time) error {
+	path := filepath.Join(s.dir, "reported_until")
+	if err := os.WriteFile(path+".tmp", []byte(t.UTC().Format(time.RFC3339)+"\n"), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *Store[T]) Close() error {
+	if s.dayFile == nil {
+		return nil
+	}
+	return s.dayFile.Close()
+}
@@ -0,0 +1,59 @@
+package reportstore
+
+import (
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	Time  time.Time
+	Value string
+}
+
+func (r testRecord) RecordTime() time.Time {
+	return r.Time
+}
+
+func TestStore(t *testing.T) {
+	s, err := Open[testRecord](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	day := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, ts := range []time.Time{day.Add(-time.Hour), day.Add(time.Hour), day.Add(25 * time.Hour)} {
+		if err := s.Add(testRecord{Time: ts, Value: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := s.Records(day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Time.Equal(day.Add(time.Hour)) {
+		t.Fatalf("wrong records: %+v", records)
+	}
+
+	if err := s.Prune(day.Add(24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	records, err = s.Records(day.Add(-24*time.Hour), day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Time.Equal(day.Add(25*time.Hour)) {
+		t.Fatalf("wrong records after prune: %+v", records)
+	}
+
+	if until, err := s.ReportedUntil(); err != nil || !until.IsZero() {
+		t.Fatalf("unexpected reporting state: %v, %v", until, err)
+	}
+	if err := s.SetReportedUntil(day); err != nil {
+		t.Fatal(err)
+	}
+	if until, err := s.ReportedUntil(); err != nil || !until.Equal(day) {
+		t.Fatalf("unexpected reporting state: %v, %v", until, err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package sessions keeps track of client connections accepted by the
+// endpoints so they can be inspected and closed using the admin API.
+package sessions
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Session is the snapshot of the information about a client connection.
+type Session struct {
+	ID         uint64
+	Endpoint   string
+	RemoteAddr string
+	LocalAddr  string
+	Started    time.Time
+
+	// User is the authenticated username, empty until the client
+	// authenticates.
+	User string
+}
+
+type conn struct {
+	net.Conn
+	info      Session
+	closeOnce sync.Once
+}
+
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		registryLock.Lock()
+		delete(registry, c.info.ID)
+		registryLock.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+var (
+	lastID       atomic.Uint64
+	registryLock sync.Mutex
+	registry     = map[uint64]*conn{}
+)
+
+type listener struct {
+	net.Listener
+	endpoint string
+}
+
+func (l listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &conn{
+		Conn: c,
+		info: Session{
+			ID:         lastID.Add(1),
+			Endpoint:   l.endpoint,
+			RemoteAddr: c.RemoteAddr().String(),
+			LocalAddr:  c.LocalAddr().String(),
+			Started:    time.Now(),
+		},
+	}
+	registryLock.Lock()
+	registry[tracked.info.ID] = tracked
+	registryLock.Unlock()
+	return tracked, nil
+}
+
+// Listener returns the listener that registers accepted connections until
+// they are closed.
+//
+// It should wrap the listener returned by net.Listen, before TLS or PROXY
+// protocol wrappers are applied, so the connection type seen by the
+// protocol implementation is not changed. Consequently, the addresses of
+// connections received via a proxy are the addresses of the proxy.
+func Listener(l net.Listener, endpoint string) net.Listener {
+	return listener{Listener: l, endpoint: endpoint}
+}
+
+// SetUser records the authenticated username for the connection with the
+// specified addresses.
+func SetUser(localAddr, remoteAddr net.Addr, user string) {
+	if localAddr == nil || remoteAddr == nil {
+		return
+	}
+	local, remote := localAddr.String(), remoteAddr.String()
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for _, c := range registry {
+		if c.info.LocalAddr == local && c.info.RemoteAddr == remote {
+			c.info.User = user
+			return
+		}
+	}
+}
+
+// List returns all open connections ordered by ID.
+func List() []Session {
+	registryLock.Lock()
+	list := make([]Session, 0, len(registry))
+	for _, c := range registry {
+		list = append(list, c.info)
+	}
+	registryLock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Kick closes the connections matching the filter and returns the number
+// of closed connections.
+func Kick(match func(Session) bool) int {
+	var matched []*conn
+	registryLock.Lock()
+	for _, c := range registry {
+		if match(c.info) {
+			matched = append(matched, c)
+		}
+	}
+	registryLock.Unlock()
+
+	for _, c := range matched {
+		c.Close()
+	}
+	return len(matched)
+}
@@ -0,0 +1,641 @@
+package sieve
+
+import (
+	"strings"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+)
+
+// Extensions lists capabilities supported by the interpreter, in the form
+// used by the require command.
+var Extensions = []string{
+	"comparator-i;ascii-casemap",
+	"comparator-i;octet",
+	"envelope",
+	"fileinto",
+	"imap4flags",
+	"reject",
+	"vacation",
+	"variables",
+}
+
+var supportedExts = func() map[string]bool {
+	exts := make(map[string]bool, len(Extensions))
+	for _, ext := range Extensions {
+		exts[ext] = true
+	}
+	return exts
+}()
+
+// Script is a compiled Sieve script.
+//
+// It is immutable and can be executed concurrently.
+type Script struct {
+	cmds []command
+	exts map[string]bool
+}
+
+// Compile parses and validates the script.
+func Compile(src string) (*Script, error) {
+	nodes, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := compiler{exts: map[string]bool{}}
+	cmds, err := c.block(nodes, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{cmds: cmds, exts: c.exts}, nil
+}
+
+type compiler struct {
+	exts map[string]bool
+}
+
+func (c *compiler) require(n *node, ext string) error {
+	if !c.exts[ext] {
+		return errorf(n.line, "%s requires %q extension", n.name, ext)
+	}
+	return nil
+}
+
+type tagSpec struct {
+	// group is set for mutually exclusive tags.
+	group string
+	// value is true if the tag is followed by a value of valueKind.
+	value     bool
+	valueKind argKind
+}
+
+var (
+	matchTags = map[string]tagSpec{
+		"is":         {group: "match type"},
+		"contains":   {group: "match type"},
+		"matches":    {group: "match type"},
+		"comparator": {value: true, valueKind: argStrings},
+	}
+	addressPartTags = map[string]tagSpec{
+		"all":       {group: "address part"},
+		"localpart": {group: "address part"},
+		"domain":    {group: "address part"},
+	}
+)
+
+func mergeTags(sets ...map[string]tagSpec) map[string]tagSpec {
+	res := map[string]tagSpec{}
+	for _, set := range sets {
+		for k, v := range set {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// args splits arguments of the command into tagged and positional ones.
+// Tagged arguments are stored with their value if they have one.
+func (c *compiler) args(n *node, tags map[string]tagSpec, positional ...argKind) (map[string]arg, []arg, error) {
+	tagged := map[string]arg{}
+	groups := map[string]string{}
+
+	i := 0
+	for ; i < len(n.args) && n.args[i].kind == argTag; i++ {
+		tag, line := n.args[i].tag, n.args[i].line
+		spec, ok := tags[tag]
+		if !ok {
+			return nil, nil, errorf(line, "unknown tagged argument :%s for %s", tag, n.name)
+		}
+		if _, ok := tagged[tag]; ok {
+			return nil, nil, errorf(line, "duplicate :%s for %s", tag, n.name)
+		}
+		if spec.group != "" {
+			if other, ok := groups[spec.group]; ok {
+				return nil, nil, errorf(line, "only one %s can be used, got :%s and :%s", spec.group, other, tag)
+			}
+			groups[spec.group] = tag
+		}
+
+		value := n.args[i]
+		if spec.value {
+			i++
+			if i >= len(n.args) || n.args[i].kind != spec.valueKind {
+				return nil, nil, errorf(line, "missing value for :%s", tag)
+			}
+			value = n.args[i]
+			if value.kind == argStrings && value.list && tag != "addresses" && tag != "flags" {
+				return nil, nil, errorf(line, ":%s expects a single string", tag)
+			}
+		}
+		tagged[tag] = value
+	}
+
+	pos := n.args[i:]
+	if len(pos) != len(positional) {
+		return nil, nil, errorf(n.line, "wrong number of arguments for %s", n.name)
+	}
+	for j, a := range pos {
+		if a.kind != positional[j] {
+			return nil, nil, errorf(a.line, "wrong argument type for %s", n.name)
+		}
+	}
+	return tagged, pos, nil
+}
+
+func (c *compiler) noTests(n *node) error {
+	if len(n.tests) != 0 {
+		return errorf(n.line, "%s does not accept tests", n.name)
+	}
+	return nil
+}
+
+func (c *compiler) block(nodes []*node, topLevel bool) ([]command, error) {
+	var cmds []command
+	requireAllowed := topLevel
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		if n.name != "require" {
+			requireAllowed = false
+		}
+		if n.block != nil && n.name != "if" && n.name != "elsif" && n.name != "else" {
+			return nil, errorf(n.line, "%s does not accept a block", n.name)
+		}
+
+		switch n.name {
+		case "require":
+			if !requireAllowed {
+				return nil, errorf(n.line, "require is allowed only at the beginning of the script")
+			}
+			_, pos, err := c.args(n, nil, argStrings)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.noTests(n); err != nil {
+				return nil, err
+			}
+			for _, ext := range pos[0].strs {
+				ext = strings.ToLower(ext)
+				if !supportedExts[ext] {
+					return nil, errorf(n.line, "unsupported extension %q", ext)
+				}
+				c.exts[ext] = true
+			}
+		case "if":
+			cmd := &ifCmd{}
+			for {
+				cond, block, err := c.conditional(nodes[i])
+				if err != nil {
+					return nil, err
+				}
+				cmd.conds = append(cmd.conds, cond)
+				cmd.blocks = append(cmd.blocks, block)
+				if cond == nil || i+1 >= len(nodes) || (nodes[i+1].name != "elsif" && nodes[i+1].name != "else") {
+					break
+				}
+				i++
+			}
+			cmds = append(cmds, cmd)
+		case "elsif", "else":
+			return nil, errorf(n.line, "%s without if", n.name)
+		default:
+			cmd, err := c.action(n)
+			if err != nil {
+				return nil, err
+			}
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds, nil
+}
+
+// conditional compiles a branch of if command. The returned test is nil for
+// else.
+func (c *compiler) conditional(n *node) (test, []command, error) {
+	if n.block == nil {
+		return nil, nil, errorf(n.line, "%s requires a block", n.name)
+	}
+	if len(n.args) != 0 {
+		return nil, nil, errorf(n.line, "%s does not accept arguments", n.name)
+	}
+
+	var cond test
+	if n.name == "else" {
+		if err := c.noTests(n); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if len(n.tests) != 1 {
+			return nil, nil, errorf(n.line, "%s requires exactly one test", n.name)
+		}
+		var err error
+		cond, err = c.test(n.tests[0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	block, err := c.block(n.block, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cond, block, nil
+}
+
+func (c *compiler) flagsArg(n *node, tagged map[string]arg) ([]string, bool, error) {
+	a, ok := tagged["flags"]
+	if !ok {
+		return nil, false, nil
+	}
+	if err := c.require(n, "imap4flags"); err != nil {
+		return nil, false, err
+	}
+	return a.strs, true, nil
+}
+
+func (c *compiler) action(n *node) (command, error) {
+	if err := c.noTests(n); err != nil {
+		return nil, err
+	}
+
+	switch n.name {
+	case "stop":
+		if _, _, err := c.args(n, nil); err != nil {
+			return nil, err
+		}
+		return stopCmd{}, nil
+	case "keep":
+		tagged, _, err := c.args(n, map[string]tagSpec{"flags": {value: true, valueKind: argStrings}})
+		if err != nil {
+			return nil, err
+		}
+		flags, hasFlags, err := c.flagsArg(n, tagged)
+		if err != nil {
+			return nil, err
+		}
+		return keepCmd{flags: flags, hasFlags: hasFlags}, nil
+	case "discard":
+		if _, _, err := c.args(n, nil); err != nil {
+			return nil, err
+		}
+		return discardCmd{}, nil
+	case "fileinto":
+		if err := c.require(n, "fileinto"); err != nil {
+			return nil, err
+		}
+		tagged, pos, err := c.args(n, map[string]tagSpec{"flags": {value: true, valueKind: argStrings}}, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		if pos[0].list {
+			return nil, errorf(n.line, "fileinto expects a single mailbox name")
+		}
+		flags, hasFlags, err := c.flagsArg(n, tagged)
+		if err != nil {
+			return nil, err
+		}
+		return fileintoCmd{mailbox: pos[0].strs[0], flags: flags, hasFlags: hasFlags}, nil
+	case "redirect":
+		_, pos, err := c.args(n, nil, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		if pos[0].list {
+			return nil, errorf(n.line, "redirect expects a single address")
+		}
+		addr := pos[0].strs[0]
+		if !c.exts["variables"] || !strings.Contains(addr, "${") {
+			if _, _, err := address.Split(addr); err != nil {
+				return nil, errorf(n.line, "malformed redirect address: %v", err)
+			}
+		}
+		return redirectCmd{addr: addr}, nil
+	case "reject":
+		if err := c.require(n, "reject"); err != nil {
+			return nil, err
+		}
+		_, pos, err := c.args(n, nil, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		if pos[0].list {
+			return nil, errorf(n.line, "reject expects a single string")
+		}
+		return rejectCmd{reason: pos[0].strs[0]}, nil
+	case "vacation":
+		return c.vacation(n)
+	case "set":
+		return c.set(n)
+	case "setflag", "addflag", "removeflag":
+		if err := c.require(n, "imap4flags"); err != nil {
+			return nil, err
+		}
+		cmd := flagCmd{op: n.name}
+		switch len(n.args) {
+		case 1:
+		case 2:
+			if n.args[0].kind != argStrings || n.args[0].list {
+				return nil, errorf(n.line, "%s expects a variable name", n.name)
+			}
+			cmd.variable = strings.ToLower(n.args[0].strs[0])
+			if !isIdentifier(cmd.variable) {
+				return nil, errorf(n.line, "invalid variable name %q", cmd.variable)
+			}
+		default:
+			return nil, errorf(n.line, "wrong number of arguments for %s", n.name)
+		}
+		flags := n.args[len(n.args)-1]
+		if flags.kind != argStrings {
+			return nil, errorf(n.line, "%s expects a list of flags", n.name)
+		}
+		cmd.flags = flags.strs
+		return cmd, nil
+	}
+	return nil, errorf(n.line, "unknown command %s", n.name)
+}
+
+func (c *compiler) vacation(n *node) (command, error) {
+	if err := c.require(n, "vacation"); err != nil {
+		return nil, err
+	}
+	tagged, pos, err := c.args(n, map[string]tagSpec{
+		"days":      {value: true, valueKind: argNumber},
+		"subject":   {value: true, valueKind: argStrings},
+		"from":      {value: true, valueKind: argStrings},
+		"addresses": {value: true, valueKind: argStrings},
+		"mime":      {},
+		"handle":    {value: true, valueKind: argStrings},
+	}, argStrings)
+	if err != nil {
+		return nil, err
+	}
+	if pos[0].list {
+		return nil, errorf(n.line, "vacation expects a single reason string")
+	}
+
+	cmd := vacationCmd{days: DefaultVacationDays, reason: pos[0].strs[0]}
+	if a, ok := tagged["days"]; ok {
+		cmd.days = int(a.num)
+		if a.num < 1 {
+			cmd.days = 1
+		}
+		if a.num > MaxVacationDays {
+			cmd.days = MaxVacationDays
+		}
+	}
+	if a, ok := tagged["subject"]; ok {
+		cmd.subject = a.strs[0]
+	}
+	if a, ok := tagged["from"]; ok {
+		cmd.from = a.strs[0]
+	}
+	if a, ok := tagged["addresses"]; ok {
+		cmd.addresses = a.strs
+	}
+	if a, ok := tagged["handle"]; ok {
+		cmd.handle = a.strs[0]
+		cmd.hasHandle = true
+	}
+	_, cmd.mime = tagged["mime"]
+	return cmd, nil
+}
+
+// Precedence of set modifiers as defined in RFC 5229 Section 4.
+var setModifiers = map[string]int{
+	"lower":         40,
+	"upper":         40,
+	"lowerfirst":    30,
+	"upperfirst":    30,
+	"quotewildcard": 20,
+	"length":        10,
+}
+
+func (c *compiler) set(n *node) (command, error) {
+	if err := c.require(n, "variables"); err != nil {
+		return nil, err
+	}
+
+	cmd := setCmd{}
+	seen := map[int]string{}
+	i := 0
+	for ; i < len(n.args) && n.args[i].kind == argTag; i++ {
+		mod := n.args[i].tag
+		prec, ok := setModifiers[mod]
+		if !ok {
+			return nil, errorf(n.line, "unknown modifier :%s for set", mod)
+		}
+		if other, ok := seen[prec]; ok {
+			return nil, errorf(n.line, "modifiers :%s and :%s can not be used together", other, mod)
+		}
+		seen[prec] = mod
+		cmd.modifiers = append(cmd.modifiers, mod)
+	}
+	// Apply modifiers with higher precedence first.
+	for j := 1; j < len(cmd.modifiers); j++ {
+		for k := j; k > 0 && setModifiers[cmd.modifiers[k]] > setModifiers[cmd.modifiers[k-1]]; k-- {
+			cmd.modifiers[k], cmd.modifiers[k-1] = cmd.modifiers[k-1], cmd.modifiers[k]
+		}
+	}
+
+	pos := n.args[i:]
+	if len(pos) != 2 || pos[0].kind != argStrings || pos[1].kind != argStrings || pos[0].list || pos[1].list {
+		return nil, errorf(n.line, "set expects variable name and value")
+	}
+	cmd.name = strings.ToLower(pos[0].strs[0])
+	if !isIdentifier(cmd.name) {
+		return nil, errorf(n.line, "invalid variable name %q", cmd.name)
+	}
+	cmd.value = pos[1].strs[0]
+	return cmd, nil
+}
+
+func (c *compiler) matchSpec(n *node, tagged map[string]arg) (matchSpec, error) {
+	spec := matchSpec{typ: "is", comparator: "i;ascii-casemap"}
+	for _, typ := range []string{"is", "contains", "matches"} {
+		if _, ok := tagged[typ]; ok {
+			spec.typ = typ
+		}
+	}
+	if a, ok := tagged["comparator"]; ok {
+		spec.comparator = strings.ToLower(a.strs[0])
+		switch spec.comparator {
+		case "i;ascii-casemap", "i;octet":
+		default:
+			return matchSpec{}, errorf(n.line, "unsupported comparator %q", spec.comparator)
+		}
+	}
+	spec.captures = spec.typ == "matches" && c.exts["variables"]
+	return spec, nil
+}
+
+func addressPart(tagged map[string]arg) string {
+	for _, part := range []string{"localpart", "domain"} {
+		if _, ok := tagged[part]; ok {
+			return part
+		}
+	}
+	return "all"
+}
+
+func (c *compiler) test(n *node) (test, error) {
+	if n.block != nil {
+		return nil, errorf(n.line, "unexpected block")
+	}
+
+	switch n.name {
+	case "true", "false":
+		if _, _, err := c.args(n, nil); err != nil {
+			return nil, err
+		}
+		if err := c.noTests(n); err != nil {
+			return nil, err
+		}
+		return constTest(n.name == "true"), nil
+	case "not":
+		if len(n.args) != 0 || len(n.tests) != 1 {
+			return nil, errorf(n.line, "not expects a single test")
+		}
+		inner, err := c.test(n.tests[0])
+		if err != nil {
+			return nil, err
+		}
+		return notTest{inner}, nil
+	case "allof", "anyof":
+		if len(n.args) != 0 || len(n.tests) == 0 {
+			return nil, errorf(n.line, "%s expects a list of tests", n.name)
+		}
+		t := listTest{all: n.name == "allof"}
+		for _, inner := range n.tests {
+			compiled, err := c.test(inner)
+			if err != nil {
+				return nil, err
+			}
+			t.tests = append(t.tests, compiled)
+		}
+		return t, nil
+	}
+
+	if err := c.noTests(n); err != nil {
+		return nil, err
+	}
+
+	switch n.name {
+	case "address", "envelope":
+		if n.name == "envelope" {
+			if err := c.require(n, "envelope"); err != nil {
+				return nil, err
+			}
+		}
+		tagged, pos, err := c.args(n, mergeTags(matchTags, addressPartTags), argStrings, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		match, err := c.matchSpec(n, tagged)
+		if err != nil {
+			return nil, err
+		}
+		if n.name == "envelope" {
+			for _, part := range pos[0].strs {
+				switch strings.ToLower(part) {
+				case "from", "to":
+				default:
+					return nil, errorf(n.line, "unsupported envelope part %q", part)
+				}
+			}
+		}
+		return addressTest{
+			envelope: n.name == "envelope",
+			fields:   pos[0].strs,
+			keys:     pos[1].strs,
+			part:     addressPart(tagged),
+			match:    match,
+		}, nil
+	case "header":
+		tagged, pos, err := c.args(n, matchTags, argStrings, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		match, err := c.matchSpec(n, tagged)
+		if err != nil {
+			return nil, err
+		}
+		return headerTest{fields: pos[0].strs, keys: pos[1].strs, match: match}, nil
+	case "string":
+		if err := c.require(n, "variables"); err != nil {
+			return nil, err
+		}
+		tagged, pos, err := c.args(n, matchTags, argStrings, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		match, err := c.matchSpec(n, tagged)
+		if err != nil {
+			return nil, err
+		}
+		return stringTest{sources: pos[0].strs, keys: pos[1].strs, match: match}, nil
+	case "exists":
+		_, pos, err := c.args(n, nil, argStrings)
+		if err != nil {
+			return nil, err
+		}
+		return existsTest{fields: pos[0].strs}, nil
+	case "size":
+		tagged, pos, err := c.args(n, map[string]tagSpec{
+			"over":  {group: "comparison"},
+			"under": {group: "comparison"},
+		}, argNumber)
+		if err != nil {
+			return nil, err
+		}
+		_, over := tagged["over"]
+		_, under := tagged["under"]
+		if !over && !under {
+			return nil, errorf(n.line, "size requires :over or :under")
+		}
+		return sizeTest{over: over, limit: pos[0].num}, nil
+	case "hasflag":
+		if err := c.require(n, "imap4flags"); err != nil {
+			return nil, err
+		}
+		var (
+			tagged map[string]arg
+			pos    []arg
+			err    error
+		)
+		// The variable list is optional.
+		tagged, pos, err = c.args(n, matchTags, argStrings)
+		if err != nil {
+			tagged, pos, err = c.args(n, matchTags, argStrings, argStrings)
+			if err != nil {
+				return nil, err
+			}
+		}
+		match, err := c.matchSpec(n, tagged)
+		if err != nil {
+			return nil, err
+		}
+		t := hasflagTest{match: match, flags: pos[len(pos)-1].strs}
+		if len(pos) == 2 {
+			for _, v := range pos[0].strs {
+				v = strings.ToLower(v)
+				if !isIdentifier(v) {
+					return nil, errorf(n.line, "invalid variable name %q", v)
+				}
+				t.variables = append(t.variables, v)
+			}
+		}
+		return t, nil
+	}
+	return nil, errorf(n.line, "unknown test %s", n.name)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || !isAlpha(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isAlpha(s[i]) && !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
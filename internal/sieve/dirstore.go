@@ -0,0 +1,237 @@
+package sieve
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirStore keeps scripts of each account in a directory named after the
+// account:
+//
+//	<dir>/<account>/<script name>.sieve
+//	<dir>/<account>/.active
+//
+// The .active file contains the name of the active script.
+type DirStore struct {
+	dir string
+}
+
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// ScriptExt is the file extension of stored scripts.
+const ScriptExt = ".sieve"
+
+const activeFile = ".active"
+
+func (s *DirStore) accountDir(account string) (string, error) {
+	if err := ValidName(account); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, account), nil
+}
+
+func (s *DirStore) scriptPath(account, name string) (string, error) {
+	dir, err := s.accountDir(account)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+ScriptExt), nil
+}
+
+func (s *DirStore) activeName(account string) (string, error) {
+	dir, err := s.accountDir(account)
+	if err != nil {
+		return "", err
+	}
+	nameBlob, err := os.ReadFile(filepath.Join(dir, activeFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(nameBlob)), nil
+}
+
+func (s *DirStore) List(account string) ([]ScriptInfo, error) {
+	dir, err := s.accountDir(account)
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.activeName(account)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var scripts []ScriptInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ScriptExt) {
+			continue
+		}
+		name = strings.TrimSuffix(name, ScriptExt)
+		scripts = append(scripts, ScriptInfo{Name: name, Active: name == active})
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts, nil
+}
+
+func (s *DirStore) Get(account, name string) (string, error) {
+	path, err := s.scriptPath(account, name)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNoScript
+		}
+		return "", err
+	}
+	return string(src), nil
+}
+
+func (s *DirStore) Put(account, name, src string) error {
+	path, err := s.scriptPath(account, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFile(path, []byte(src))
+}
+
+func (s *DirStore) Delete(account, name string) error {
+	path, err := s.scriptPath(account, name)
+	if err != nil {
+		return err
+	}
+	active, err := s.activeName(account)
+	if err != nil {
+		return err
+	}
+	if active == name {
+		return ErrActiveScript
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoScript
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *DirStore) Rename(account, oldName, newName string) error {
+	oldPath, err := s.scriptPath(account, oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := s.scriptPath(account, newName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(oldPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoScript
+		}
+		return err
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return ErrScriptExists
+	}
+	active, err := s.activeName(account)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if active == oldName {
+		return s.SetActive(account, newName)
+	}
+	return nil
+}
+
+func (s *DirStore) SetActive(account, name string) error {
+	dir, err := s.accountDir(account)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		err := os.Remove(filepath.Join(dir, activeFile))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	path, err := s.scriptPath(account, name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoScript
+		}
+		return err
+	}
+	return writeFile(filepath.Join(dir, activeFile), []byte(name+"\n"))
+}
+
+func (s *DirStore) Active(account string) (name, src string, err error) {
+	name, err = s.activeName(account)
+	if err != nil {
+		return "", "", err
+	}
+	if name == "" {
+		return "", "", ErrNoActiveScript
+	}
+	src, err = s.Get(account, name)
+	if err != nil {
+		if errors.Is(err, ErrNoScript) {
+			return "", "", ErrNoActiveScript
+		}
+		return "", "", err
+	}
+	return name, src, nil
+}
+
+// writeFile replaces the file contents atomically.
+func writeFile(path string, blob []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(blob); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
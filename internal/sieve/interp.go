@@ -0,0 +1,585 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+)
+
+const (
+	// DefaultVacationDays is the vacation response interval used if :days
+	// is not specified.
+	DefaultVacationDays = 7
+	// MaxVacationDays is the upper limit for :days.
+	MaxVacationDays = 90
+	// MaxRedirects is the maximum number of redirect actions per message.
+	MaxRedirects = 4
+
+	// InboxMailbox is the mailbox used by keep.
+	InboxMailbox = "INBOX"
+)
+
+// Message is the message the script is executed for.
+type Message struct {
+	Header textproto.Header
+	Size   int
+	// EnvelopeFrom is the envelope sender, empty for null sender.
+	EnvelopeFrom string
+	// EnvelopeTo is the envelope recipient the message is delivered to.
+	EnvelopeTo string
+}
+
+// Delivery is a copy of the message to be stored.
+type Delivery struct {
+	Mailbox string
+	Flags   []string
+}
+
+// Vacation is the auto-reply requested by the vacation action. Checks on
+// whether the response should be actually sent are left to the caller.
+type Vacation struct {
+	Days      int
+	Subject   string
+	From      string
+	Addresses []string
+	MIME      bool
+	// Handle identifies the vacation action for response tracking.
+	Handle string
+	Reason string
+}
+
+// Result contains actions to be taken as the result of script execution.
+type Result struct {
+	// Deliveries lists mailboxes the message should be stored into, in order
+	// of execution. If it is empty, the message is discarded.
+	Deliveries []Delivery
+	Redirects  []string
+
+	Rejected     bool
+	RejectReason string
+
+	Vacation *Vacation
+}
+
+var errStop = errors.New("stop")
+
+type runtime struct {
+	script *Script
+	msg    *Message
+
+	vars      map[string]string
+	matchVars []string
+	flags     []string
+
+	implicitKeep bool
+	res          Result
+}
+
+// Execute runs the script for the message.
+//
+// Errors are returned only for runtime errors such as conflicting actions,
+// in that case the message should be delivered as if no script was present.
+func (s *Script) Execute(msg Message) (*Result, error) {
+	r := &runtime{
+		script:       s,
+		msg:          &msg,
+		vars:         map[string]string{},
+		implicitKeep: true,
+	}
+	if err := r.run(s.cmds); err != nil && err != errStop {
+		return nil, err
+	}
+	if r.implicitKeep {
+		r.deliver(InboxMailbox, r.flags)
+	}
+	return &r.res, nil
+}
+
+func (r *runtime) run(cmds []command) error {
+	for _, cmd := range cmds {
+		if err := cmd.exec(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *runtime) deliver(mailbox string, flags []string) {
+	for _, d := range r.res.Deliveries {
+		if d.Mailbox == mailbox {
+			return
+		}
+	}
+	r.res.Deliveries = append(r.res.Deliveries, Delivery{
+		Mailbox: mailbox,
+		Flags:   append([]string(nil), flags...),
+	})
+}
+
+// checkReject enforces incompatibility of reject with actions that deliver
+// or respond to the message (RFC 5429 Section 2.1).
+func (r *runtime) checkReject(action string) error {
+	if action == "reject" {
+		if len(r.res.Deliveries) != 0 || len(r.res.Redirects) != 0 || r.res.Vacation != nil {
+			return errors.New("sieve: reject can not be used together with keep, fileinto, redirect or vacation")
+		}
+		return nil
+	}
+	if r.res.Rejected {
+		return fmt.Errorf("sieve: %s can not be used together with reject", action)
+	}
+	return nil
+}
+
+type command interface {
+	exec(r *runtime) error
+}
+
+type test interface {
+	eval(r *runtime) bool
+}
+
+type ifCmd struct {
+	// conds contains nil for the else branch.
+	conds  []test
+	blocks [][]command
+}
+
+func (c *ifCmd) exec(r *runtime) error {
+	for i, cond := range c.conds {
+		if cond == nil || cond.eval(r) {
+			return r.run(c.blocks[i])
+		}
+	}
+	return nil
+}
+
+type stopCmd struct{}
+
+func (stopCmd) exec(*runtime) error {
+	return errStop
+}
+
+type keepCmd struct {
+	flags    []string
+	hasFlags bool
+}
+
+func (c keepCmd) exec(r *runtime) error {
+	if err := r.checkReject("keep"); err != nil {
+		return err
+	}
+	flags := r.flags
+	if c.hasFlags {
+		flags = r.expandFlags(c.flags)
+	}
+	r.deliver(InboxMailbox, flags)
+	r.implicitKeep = false
+	return nil
+}
+
+type discardCmd struct{}
+
+func (discardCmd) exec(r *runtime) error {
+	r.implicitKeep = false
+	return nil
+}
+
+type fileintoCmd struct {
+	mailbox  string
+	flags    []string
+	hasFlags bool
+}
+
+func (c fileintoCmd) exec(r *runtime) error {
+	if err := r.checkReject("fileinto"); err != nil {
+		return err
+	}
+	mailbox := r.expand(c.mailbox)
+	if mailbox == "" || !utf8.ValidString(mailbox) {
+		return fmt.Errorf("sieve: invalid mailbox name %q", mailbox)
+	}
+	flags := r.flags
+	if c.hasFlags {
+		flags = r.expandFlags(c.flags)
+	}
+	r.deliver(mailbox, flags)
+	r.implicitKeep = false
+	return nil
+}
+
+type redirectCmd struct {
+	addr string
+}
+
+func (c redirectCmd) exec(r *runtime) error {
+	if err := r.checkReject("redirect"); err != nil {
+		return err
+	}
+	addr := r.expand(c.addr)
+	if _, err := mail.ParseAddress("<" + addr + ">"); err != nil {
+		return fmt.Errorf("sieve: malformed redirect address %q", addr)
+	}
+	for _, existing := range r.res.Redirects {
+		if strings.EqualFold(existing, addr) {
+			r.implicitKeep = false
+			return nil
+		}
+	}
+	if len(r.res.Redirects) >= MaxRedirects {
+		return errors.New("sieve: too many redirects")
+	}
+	r.res.Redirects = append(r.res.Redirects, addr)
+	r.implicitKeep = false
+	return nil
+}
+
+type rejectCmd struct {
+	reason string
+}
+
+func (c rejectCmd) exec(r *runtime) error {
+	if err := r.checkReject("reject"); err != nil {
+		return err
+	}
+	r.res.Rejected = true
+	r.res.RejectReason = r.expand(c.reason)
+	r.implicitKeep = false
+	return nil
+}
+
+type vacationCmd struct {
+	days      int
+	subject   string
+	from      string
+	addresses []string
+	mime      bool
+	handle    string
+	hasHandle bool
+	reason    string
+}
+
+func (c vacationCmd) exec(r *runtime) error {
+	if err := r.checkReject("vacation"); err != nil {
+		return err
+	}
+	if r.res.Vacation != nil {
+		return errors.New("sieve: vacation can be used only once")
+	}
+
+	v := &Vacation{
+		Days:    c.days,
+		Subject: r.expand(c.subject),
+		From:    r.expand(c.from),
+		MIME:    c.mime,
+		Reason:  r.expand(c.reason),
+	}
+	for _, addr := range c.addresses {
+		v.Addresses = append(v.Addresses, r.expand(addr))
+	}
+	// RFC 5230 Section 4.2, the handle defaults to the unexpanded
+	// arguments so that changes to them reset response tracking.
+	if c.hasHandle {
+		v.Handle = r.expand(c.handle)
+	} else {
+		v.Handle = c.subject + "\x00" + c.from + "\x00" + c.reason
+	}
+	r.res.Vacation = v
+	return nil
+}
+
+type setCmd struct {
+	modifiers []string
+	name      string
+	value     string
+}
+
+func (c setCmd) exec(r *runtime) error {
+	value := r.expand(c.value)
+	for _, mod := range c.modifiers {
+		switch mod {
+		case "lower":
+			value = strings.ToLower(value)
+		case "upper":
+			value = strings.ToUpper(value)
+		case "lowerfirst", "upperfirst":
+			first, size := utf8.DecodeRuneInString(value)
+			if size == 0 {
+				break
+			}
+			if mod == "lowerfirst" {
+				first = unicode.ToLower(first)
+			} else {
+				first = unicode.ToUpper(first)
+			}
+			value = string(first) + value[size:]
+		case "quotewildcard":
+			value = quoteWildcard(value)
+		case "length":
+			value = fmt.Sprint(utf8.RuneCountInString(value))
+		}
+	}
+	r.vars[c.name] = value
+	return nil
+}
+
+type flagCmd struct {
+	op       string
+	variable string
+	flags    []string
+}
+
+func (c flagCmd) exec(r *runtime) error {
+	var current []string
+	if c.variable == "" {
+		current = r.flags
+	} else {
+		current = strings.Fields(r.vars[c.variable])
+	}
+
+	flags := r.expandFlags(c.flags)
+	switch c.op {
+	case "setflag":
+		current = flags
+	case "addflag":
+		current = addFlags(current, flags)
+	case "removeflag":
+		current = removeFlags(current, flags)
+	}
+
+	if c.variable == "" {
+		r.flags = current
+	} else {
+		r.vars[c.variable] = strings.Join(current, " ")
+	}
+	return nil
+}
+
+// expandFlags expands variables and splits the space-separated flag lists
+// (RFC 5232 Section 3).
+func (r *runtime) expandFlags(lists []string) []string {
+	return addFlags(nil, r.splitFlags(lists))
+}
+
+func (r *runtime) splitFlags(lists []string) []string {
+	var flags []string
+	for _, list := range lists {
+		flags = append(flags, strings.Fields(r.expand(list))...)
+	}
+	return flags
+}
+
+func addFlags(current, flags []string) []string {
+	res := append([]string(nil), current...)
+outer:
+	for _, flag := range flags {
+		for _, existing := range res {
+			if strings.EqualFold(existing, flag) {
+				continue outer
+			}
+		}
+		res = append(res, flag)
+	}
+	return res
+}
+
+func removeFlags(current, flags []string) []string {
+	var res []string
+outer:
+	for _, existing := range current {
+		for _, flag := range flags {
+			if strings.EqualFold(existing, flag) {
+				continue outer
+			}
+		}
+		res = append(res, existing)
+	}
+	return res
+}
+
+type constTest bool
+
+func (t constTest) eval(*runtime) bool {
+	return bool(t)
+}
+
+type notTest struct {
+	inner test
+}
+
+func (t notTest) eval(r *runtime) bool {
+	return !t.inner.eval(r)
+}
+
+type listTest struct {
+	all   bool
+	tests []test
+}
+
+func (t listTest) eval(r *runtime) bool {
+	for _, inner := range t.tests {
+		if inner.eval(r) != t.all {
+			return !t.all
+		}
+	}
+	return t.all
+}
+
+var wordDecoder = mime.WordDecoder{CharsetReader: charset.Reader}
+
+// headerValues returns decoded values of the header field.
+func (r *runtime) headerValues(name string) []string {
+	var values []string
+	for field := r.msg.Header.FieldsByKey(r.expand(name)); field.Next(); {
+		value := strings.TrimSpace(field.Value())
+		if decoded, err := wordDecoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+type headerTest struct {
+	fields []string
+	keys   []string
+	match  matchSpec
+}
+
+func (t headerTest) eval(r *runtime) bool {
+	for _, name := range t.fields {
+		if r.matchAny(t.match, r.headerValues(name), t.keys) {
+			return true
+		}
+	}
+	return false
+}
+
+type addressTest struct {
+	envelope bool
+	fields   []string
+	keys     []string
+	part     string
+	match    matchSpec
+}
+
+func addressPartOf(addr, part string) string {
+	switch part {
+	case "localpart":
+		if idx := strings.LastIndexByte(addr, '@'); idx != -1 {
+			return addr[:idx]
+		}
+		return addr
+	case "domain":
+		if idx := strings.LastIndexByte(addr, '@'); idx != -1 {
+			return addr[idx+1:]
+		}
+		return ""
+	}
+	return addr
+}
+
+func (t addressTest) addresses(r *runtime, name string) []string {
+	if t.envelope {
+		switch strings.ToLower(r.expand(name)) {
+		case "from":
+			return []string{r.msg.EnvelopeFrom}
+		case "to":
+			return []string{r.msg.EnvelopeTo}
+		}
+		return nil
+	}
+
+	var addrs []string
+	for field := r.msg.Header.FieldsByKey(r.expand(name)); field.Next(); {
+		list, err := mail.ParseAddressList(field.Value())
+		if err != nil {
+			// Use the value as is, so at least :contains and :matches tests
+			// can work for malformed fields.
+			addrs = append(addrs, strings.TrimSpace(field.Value()))
+			continue
+		}
+		for _, addr := range list {
+			addrs = append(addrs, addr.Address)
+		}
+	}
+	return addrs
+}
+
+func (t addressTest) eval(r *runtime) bool {
+	for _, name := range t.fields {
+		addrs := t.addresses(r, name)
+		values := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			values = append(values, addressPartOf(addr, t.part))
+		}
+		if r.matchAny(t.match, values, t.keys) {
+			return true
+		}
+	}
+	return false
+}
+
+type existsTest struct {
+	fields []string
+}
+
+func (t existsTest) eval(r *runtime) bool {
+	for _, name := range t.fields {
+		if !r.msg.Header.Has(r.expand(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+type sizeTest struct {
+	over  bool
+	limit int64
+}
+
+func (t sizeTest) eval(r *runtime) bool {
+	if t.over {
+		return int64(r.msg.Size) > t.limit
+	}
+	return int64(r.msg.Size) < t.limit
+}
+
+type stringTest struct {
+	sources []string
+	keys    []string
+	match   matchSpec
+}
+
+func (t stringTest) eval(r *runtime) bool {
+	values := make([]string, 0, len(t.sources))
+	for _, src := range t.sources {
+		values = append(values, r.expand(src))
+	}
+	return r.matchAny(t.match, values, t.keys)
+}
+
+type hasflagTest struct {
+	variables []string
+	flags     []string
+	match     matchSpec
+}
+
+func (t hasflagTest) eval(r *runtime) bool {
+	var current []string
+	if len(t.variables) == 0 {
+		current = r.flags
+	} else {
+		for _, v := range t.variables {
+			current = append(current, strings.Fields(r.vars[v])...)
+		}
+	}
+	return r.matchAny(t.match, current, r.splitFlags(t.flags))
+}
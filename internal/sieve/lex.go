@@ -0,0 +1,251 @@
+package sieve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokTag
+	tokNumber
+	tokString
+	tokLBracket
+	tokRBracket
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+	tokComma
+	tokSemicolon
+)
+
+type token struct {
+	kind tokenKind
+	line int
+	text string
+	num  int64
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of script"
+	case tokIdent:
+		return t.text
+	case tokTag:
+		return ":" + t.text
+	case tokNumber:
+		return strconv.FormatInt(t.num, 10)
+	case tokString:
+		return "string"
+	}
+	return t.text
+}
+
+// Error is a syntax or semantic error in the script.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("sieve: line %d: %s", e.Line, e.Msg)
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return &Error{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// lexer splits the script into tokens as defined in RFC 5228 Section 8.1.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end == -1 {
+				l.pos = len(l.src)
+			} else {
+				l.pos += end
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end == -1 {
+				return errorf(l.line, "unterminated comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += 2 + end + 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case c == '"':
+		return l.quoted()
+	case c == ':':
+		l.pos++
+		if l.pos >= len(l.src) || !isAlpha(l.src[l.pos]) {
+			return token{}, errorf(l.line, "malformed tag")
+		}
+		ident := l.ident()
+		return token{kind: tokTag, line: l.line, text: strings.ToLower(ident)}, nil
+	case isAlpha(c):
+		ident := l.ident()
+		if strings.EqualFold(ident, "text") && l.pos < len(l.src) && l.src[l.pos] == ':' {
+			l.pos++
+			return l.multiline()
+		}
+		return token{kind: tokIdent, line: l.line, text: strings.ToLower(ident)}, nil
+	case isDigit(c):
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		num, err := strconv.ParseInt(l.src[start:l.pos], 10, 64)
+		if err != nil {
+			return token{}, errorf(l.line, "malformed number: %v", err)
+		}
+		if l.pos < len(l.src) {
+			var mult int64
+			switch l.src[l.pos] {
+			case 'K', 'k':
+				mult = 1 << 10
+			case 'M', 'm':
+				mult = 1 << 20
+			case 'G', 'g':
+				mult = 1 << 30
+			}
+			if mult != 0 {
+				l.pos++
+				num *= mult
+			}
+		}
+		return token{kind: tokNumber, line: l.line, num: num}, nil
+	}
+
+	l.pos++
+	kind, ok := map[byte]tokenKind{
+		'[': tokLBracket,
+		']': tokRBracket,
+		'(': tokLParen,
+		')': tokRParen,
+		'{': tokLBrace,
+		'}': tokRBrace,
+		',': tokComma,
+		';': tokSemicolon,
+	}[c]
+	if !ok {
+		return token{}, errorf(l.line, "unexpected character %q", c)
+	}
+	return token{kind: kind, line: l.line, text: string(c)}, nil
+}
+
+func (l *lexer) ident() string {
+	start := l.pos
+	for l.pos < len(l.src) && (isAlpha(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+func (l *lexer) quoted() (token, error) {
+	line := l.line
+	l.pos++ // opening quote
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokString, line: line, text: b.String()}, nil
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.src) {
+				return token{}, errorf(line, "unterminated string")
+			}
+			c = l.src[l.pos]
+		case '\n':
+			l.line++
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return token{}, errorf(line, "unterminated string")
+}
+
+// multiline reads the "text:" string, the "text:" prefix is already consumed.
+func (l *lexer) multiline() (token, error) {
+	line := l.line
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '#' {
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos >= len(l.src) || l.src[l.pos] != '\n' {
+		return token{}, errorf(line, "expected line break after text:")
+	}
+	l.pos++
+	l.line++
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end == -1 {
+			break
+		}
+		textLine := strings.TrimSuffix(l.src[l.pos:l.pos+end], "\r")
+		l.pos += end + 1
+		l.line++
+
+		if textLine == "." {
+			return token{kind: tokString, line: line, text: b.String()}, nil
+		}
+		// Dot-stuffing.
+		textLine = strings.TrimPrefix(textLine, ".")
+		b.WriteString(textLine)
+		b.WriteString("\r\n")
+	}
+	return token{}, errorf(line, "unterminated multi-line string")
+}
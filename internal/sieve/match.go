@@ -0,0 +1,237 @@
+package sieve
+
+import (
+	"strings"
+)
+
+type matchSpec struct {
+	typ        string
+	comparator string
+	// captures is set if :matches wildcards should be stored into match
+	// variables.
+	captures bool
+}
+
+// maxMatchLen limits the length of values matched using :matches to keep
+// the backtracking bounded.
+const maxMatchLen = 4096
+
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, s)
+}
+
+// match compares the value against the key. For :matches it also returns
+// the strings corresponding to each wildcard.
+func (m matchSpec) match(value, key string) (bool, []string) {
+	cmpValue, cmpKey := value, key
+	if m.comparator == "i;ascii-casemap" {
+		cmpValue, cmpKey = asciiLower(value), asciiLower(key)
+	}
+
+	switch m.typ {
+	case "contains":
+		return strings.Contains(cmpValue, cmpKey), nil
+	case "matches":
+		if len(cmpValue) > maxMatchLen {
+			cmpValue = cmpValue[:maxMatchLen]
+			value = value[:maxMatchLen]
+		}
+		g := globMatcher{pattern: cmpKey, value: cmpValue, failed: map[[2]int]bool{}}
+		if !g.match(0, 0) {
+			return false, nil
+		}
+		captures := make([]string, 0, len(g.spans)+1)
+		captures = append(captures, value)
+		for i := len(g.spans) - 1; i >= 0; i-- {
+			captures = append(captures, value[g.spans[i][0]:g.spans[i][1]])
+		}
+		return true, captures
+	}
+	return cmpValue == cmpKey, nil
+}
+
+// globMatcher implements :matches wildcards ("*" and "?", "\" escapes
+// them). Wildcards match greedily, as required by RFC 5229 for match
+// variables.
+type globMatcher struct {
+	pattern string
+	value   string
+	// spans of the matched wildcards in reverse order.
+	spans  [][2]int
+	failed map[[2]int]bool
+}
+
+func (g *globMatcher) match(pi, vi int) bool {
+	if g.failed[[2]int{pi, vi}] {
+		return false
+	}
+	ok := g.matchAt(pi, vi)
+	if !ok {
+		g.failed[[2]int{pi, vi}] = true
+	}
+	return ok
+}
+
+func (g *globMatcher) matchAt(pi, vi int) bool {
+	if pi == len(g.pattern) {
+		return vi == len(g.value)
+	}
+
+	switch c := g.pattern[pi]; c {
+	case '*':
+		for end := len(g.value); end >= vi; end-- {
+			if g.match(pi+1, end) {
+				g.spans = append(g.spans, [2]int{vi, end})
+				return true
+			}
+		}
+		return false
+	case '?':
+		if vi == len(g.value) {
+			return false
+		}
+		size := utf8Len(g.value[vi])
+		if vi+size > len(g.value) {
+			size = 1
+		}
+		if g.match(pi+1, vi+size) {
+			g.spans = append(g.spans, [2]int{vi, vi + size})
+			return true
+		}
+		return false
+	case '\\':
+		if pi+1 < len(g.pattern) {
+			pi++
+			c = g.pattern[pi]
+		}
+		fallthrough
+	default:
+		if vi == len(g.value) || g.value[vi] != c {
+			return false
+		}
+		return g.match(pi+1, vi+1)
+	}
+}
+
+// utf8Len returns the length of the UTF-8 sequence starting with b, "?"
+// matches a single character rather than an octet.
+func utf8Len(b byte) int {
+	switch {
+	case b >= 0xF0:
+		return 4
+	case b >= 0xE0:
+		return 3
+	case b >= 0xC0:
+		return 2
+	}
+	return 1
+}
+
+// quoteWildcard escapes characters that have a special meaning in :matches
+// patterns.
+func quoteWildcard(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// expand replaces variable references in s (RFC 5229 Section 3).
+// References to unknown variables are replaced with an empty string,
+// malformed references are kept as is.
+func (r *runtime) expand(s string) string {
+	if !r.script.exts["variables"] || !strings.Contains(s, "${") {
+		return s
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		name := s[start+2 : start+end]
+
+		value, ok := r.variable(name)
+		if !ok {
+			// Not a variable reference, the next "${" can start inside it.
+			b.WriteString(s[:start+2])
+			s = s[start+2:]
+			continue
+		}
+		b.WriteString(s[:start])
+		b.WriteString(value)
+		s = s[start+end+1:]
+	}
+}
+
+// variable returns the value of the referenced variable and whether the name
+// is a valid reference.
+func (r *runtime) variable(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	if isDigit(name[0]) {
+		for i := 0; i < len(name); i++ {
+			if !isDigit(name[i]) {
+				return "", false
+			}
+		}
+		idx := 0
+		for i := 0; i < len(name) && idx <= maxMatchVars; i++ {
+			idx = idx*10 + int(name[i]-'0')
+		}
+		if idx < len(r.matchVars) {
+			return r.matchVars[idx], true
+		}
+		return "", true
+	}
+	name = strings.ToLower(name)
+	if !isIdentifier(name) {
+		return "", false
+	}
+	return r.vars[name], true
+}
+
+const maxMatchVars = 9
+
+func (r *runtime) setMatchVars(captures []string) {
+	if len(captures) > maxMatchVars+1 {
+		captures = captures[:maxMatchVars+1]
+	}
+	r.matchVars = captures
+}
+
+// matchAny checks the values against the keys and updates match variables
+// on success.
+func (r *runtime) matchAny(m matchSpec, values, keys []string) bool {
+	for _, value := range values {
+		for _, key := range keys {
+			ok, captures := m.match(value, r.expand(key))
+			if !ok {
+				continue
+			}
+			if m.captures {
+				r.setMatchVars(captures)
+			}
+			return true
+		}
+	}
+	return false
+}
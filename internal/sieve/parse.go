@@ -0,0 +1,215 @@
+package sieve
+
+type argKind int
+
+const (
+	argTag argKind = iota
+	argNumber
+	argStrings
+)
+
+type arg struct {
+	kind argKind
+	line int
+	tag  string
+	num  int64
+	strs []string
+	// list is true if the string argument was written as a list.
+	list bool
+}
+
+// node is a command or a test in the parsed script.
+type node struct {
+	name  string
+	line  int
+	args  []arg
+	tests []*node
+	// block is non-nil for commands followed by a block.
+	block []*node
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+	// depth limits nesting to protect against stack exhaustion.
+	depth int
+}
+
+const maxNesting = 64
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	if p.tok.kind != kind {
+		return errorf(p.tok.line, "expected %s, got %v", what, p.tok)
+	}
+	return p.advance()
+}
+
+// parse reads the whole script and returns the top-level commands.
+func parse(src string) ([]*node, error) {
+	p := parser{lex: &lexer{src: src, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	cmds, err := p.commands()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, errorf(p.tok.line, "unexpected %v", p.tok)
+	}
+	return cmds, nil
+}
+
+func (p *parser) commands() ([]*node, error) {
+	cmds := []*node{}
+	for p.tok.kind == tokIdent {
+		cmd, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func (p *parser) command() (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNesting {
+		return nil, errorf(p.tok.line, "too deeply nested")
+	}
+
+	cmd := &node{name: p.tok.text, line: p.tok.line}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.arguments(cmd); err != nil {
+		return nil, err
+	}
+
+	switch p.tok.kind {
+	case tokSemicolon:
+		return cmd, p.advance()
+	case tokLBrace:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		block, err := p.commands()
+		if err != nil {
+			return nil, err
+		}
+		cmd.block = block
+		return cmd, p.expect(tokRBrace, "}")
+	}
+	return nil, errorf(p.tok.line, "expected ; or block after %s, got %v", cmd.name, p.tok)
+}
+
+func (p *parser) arguments(n *node) error {
+	for {
+		switch p.tok.kind {
+		case tokTag:
+			n.args = append(n.args, arg{kind: argTag, line: p.tok.line, tag: p.tok.text})
+		case tokNumber:
+			n.args = append(n.args, arg{kind: argNumber, line: p.tok.line, num: p.tok.num})
+		case tokString:
+			n.args = append(n.args, arg{kind: argStrings, line: p.tok.line, strs: []string{p.tok.text}})
+		case tokLBracket:
+			a, err := p.stringList()
+			if err != nil {
+				return err
+			}
+			n.args = append(n.args, a)
+			continue
+		case tokIdent:
+			test, err := p.test()
+			if err != nil {
+				return err
+			}
+			n.tests = []*node{test}
+			return nil
+		case tokLParen:
+			tests, err := p.testList()
+			if err != nil {
+				return err
+			}
+			n.tests = tests
+			return nil
+		default:
+			return nil
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) stringList() (arg, error) {
+	a := arg{kind: argStrings, line: p.tok.line, list: true}
+	for {
+		if err := p.advance(); err != nil {
+			return arg{}, err
+		}
+		if p.tok.kind != tokString {
+			return arg{}, errorf(p.tok.line, "expected string in string list, got %v", p.tok)
+		}
+		a.strs = append(a.strs, p.tok.text)
+		if err := p.advance(); err != nil {
+			return arg{}, err
+		}
+		switch p.tok.kind {
+		case tokComma:
+			continue
+		case tokRBracket:
+			return a, p.advance()
+		}
+		return arg{}, errorf(p.tok.line, "expected , or ] in string list, got %v", p.tok)
+	}
+}
+
+func (p *parser) test() (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNesting {
+		return nil, errorf(p.tok.line, "too deeply nested")
+	}
+
+	test := &node{name: p.tok.text, line: p.tok.line}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return test, p.arguments(test)
+}
+
+func (p *parser) testList() ([]*node, error) {
+	var tests []*node
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokIdent {
+			return nil, errorf(p.tok.line, "expected test, got %v", p.tok)
+		}
+		test, err := p.test()
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+
+		switch p.tok.kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return tests, p.advance()
+		}
+		return nil, errorf(p.tok.line, "expected , or ) in test list, got %v", p.tok)
+	}
+}
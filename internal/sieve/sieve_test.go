@@ -0,0 +1,222 @@
+package sieve
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+const testMsg = "From: Alice <alice@example.org>\r\n" +
+	"To: bob@example.com, \"Carol\" <carol@EXAMPLE.net>\r\n" +
+	"Subject: =?utf-8?q?Re:_=E2=82=AC_invoice?=\r\n" +
+	"List-Id: <dev.lists.example.org>\r\n" +
+	"X-Spam-Score: 7.5\r\n" +
+	"\r\n"
+
+func testHeader(t *testing.T) textproto.Header {
+	t.Helper()
+	hdr, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(testMsg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hdr
+}
+
+func execute(t *testing.T, src string) *Result {
+	t.Helper()
+	script, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := script.Execute(Message{
+		Header:       testHeader(t),
+		Size:         2000,
+		EnvelopeFrom: "bounces@lists.example.org",
+		EnvelopeTo:   "bob+dev@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestExecute(t *testing.T) {
+	test := func(src string, expected []Delivery) {
+		t.Helper()
+		res := execute(t, src)
+		if !reflect.DeepEqual(res.Deliveries, expected) {
+			t.Errorf("wrong deliveries for\n%s\ngot %+v, expected %+v", src, res.Deliveries, expected)
+		}
+	}
+	inbox := []Delivery{{Mailbox: InboxMailbox}}
+	lists := []Delivery{{Mailbox: "Lists"}}
+
+	test(``, inbox)
+	test(`keep;`, inbox)
+	test(`discard;`, nil)
+	test(`require "fileinto"; fileinto "Lists";`, lists)
+	test(`require "fileinto"; fileinto "Lists"; fileinto "Lists"; keep;`,
+		append(lists, inbox...))
+	test(`require "fileinto";
+		if header :contains "list-id" "lists.example.org" { fileinto "Lists"; stop; }
+		discard;`, lists)
+	test(`require "fileinto";
+		if header :is "Subject" "Re: € invoice" { fileinto "Lists"; }`, lists)
+	test(`require "fileinto";
+		if address :domain :is "to" "example.net" { fileinto "Lists"; }`, lists)
+	test(`require "fileinto";
+		if address :localpart :is ["cc", "to"] "carol" { fileinto "Lists"; }`, lists)
+	test(`require "fileinto";
+		if address :comparator "i;octet" :domain :is "to" "example.net" { fileinto "Lists"; }`, inbox)
+	test(`require ["envelope", "fileinto"];
+		if envelope :matches "to" "bob+*@example.com" { fileinto "Lists"; }`, lists)
+	test(`require "fileinto";
+		if anyof (size :under 1K, not exists ["From", "List-Id"]) { fileinto "Lists"; }`, inbox)
+	test(`require "fileinto";
+		if allof (size :over 1K, exists "List-Id", true) { fileinto "Lists"; }`, lists)
+	test(`require "fileinto";
+		if header :matches "subject" "re:*" { fileinto "Lists"; }
+		elsif true { discard; }
+		else { keep; }`, lists)
+	test(`if false { discard; } elsif header :contains "X-Spam-Score" "7" { discard; } else { keep; }`, nil)
+}
+
+func TestExecute_Flags(t *testing.T) {
+	res := execute(t, `require ["imap4flags", "fileinto"];
+		setflag "\\Seen";
+		addflag ["$Label1 \\Flagged", "\\seen"];
+		if hasflag :contains "label" { removeflag "\\Flagged"; }
+		fileinto :flags "\\Answered" "Lists";`)
+	expected := []Delivery{{Mailbox: "Lists", Flags: []string{"\\Answered"}}}
+	if !reflect.DeepEqual(res.Deliveries, expected) {
+		t.Errorf("got %+v, expected %+v", res.Deliveries, expected)
+	}
+
+	// Implicit keep uses the internal variable.
+	res = execute(t, `require "imap4flags"; addflag "\\Seen $Work"; removeflag "$work";`)
+	expected = []Delivery{{Mailbox: InboxMailbox, Flags: []string{"\\Seen"}}}
+	if !reflect.DeepEqual(res.Deliveries, expected) {
+		t.Errorf("got %+v, expected %+v", res.Deliveries, expected)
+	}
+}
+
+func TestExecute_Variables(t *testing.T) {
+	res := execute(t, `require ["fileinto", "variables", "envelope"];
+		if envelope :matches "to" "*+*@*" {
+			set :upperfirst "folder" "${2}";
+		}
+		set "list" "${folder}/${unknown}x";
+		set :length "len" "${list}";
+		if string :is "${len}" "5" {
+			fileinto "Lists/${list}";
+		}`)
+	expected := []Delivery{{Mailbox: "Lists/Dev/x"}}
+	if !reflect.DeepEqual(res.Deliveries, expected) {
+		t.Errorf("got %+v, expected %+v", res.Deliveries, expected)
+	}
+
+	res = execute(t, `require ["fileinto", "variables"];
+		if header :matches "Subject" "*: * *" {
+			fileinto "${0}|${1}|${2}|${3}|${4}|${}";
+		}`)
+	if len(res.Deliveries) != 1 || res.Deliveries[0].Mailbox != "Re: € invoice|Re|€|invoice||${}" {
+		t.Errorf("wrong match variables: %+v", res.Deliveries)
+	}
+
+	res = execute(t, `require ["fileinto", "variables"];
+		set :lower :quotewildcard "v" "A*B?";
+		if string :matches "a*b?" "${v}" { fileinto "${v}"; }`)
+	if len(res.Deliveries) != 1 || res.Deliveries[0].Mailbox != `a\*b\?` {
+		t.Errorf("wrong set modifiers result: %+v", res.Deliveries)
+	}
+}
+
+func TestExecute_Actions(t *testing.T) {
+	res := execute(t, `redirect "alice@example.net"; redirect "ALICE@example.net";`)
+	if len(res.Deliveries) != 0 || !reflect.DeepEqual(res.Redirects, []string{"alice@example.net"}) {
+		t.Errorf("wrong redirect result: %+v", res)
+	}
+
+	res = execute(t, `require "reject"; reject text:
+Go away.
+..
+.
+;`)
+	if !res.Rejected || res.RejectReason != "Go away.\r\n.\r\n" || len(res.Deliveries) != 0 {
+		t.Errorf("wrong reject result: %+v", res)
+	}
+
+	res = execute(t, `require ["vacation", "variables"];
+		set "s" "Away";
+		vacation :days 0 :subject "${s}" :addresses ["bob@example.com"] "I'm away";`)
+	v := res.Vacation
+	if v == nil || v.Days != 1 || v.Subject != "Away" || v.Reason != "I'm away" || len(res.Deliveries) != 1 {
+		t.Errorf("wrong vacation result: %+v %+v", res, v)
+	}
+
+	script, err := Compile(`require ["reject", "fileinto"]; fileinto "Lists"; reject "no";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := script.Execute(Message{}); err == nil {
+		t.Error("reject with fileinto should fail")
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, src := range []string{
+		`fileinto "Lists";`,
+		`require "fileinto"; require "bogus";`,
+		`keep; require "fileinto";`,
+		`if true { require "fileinto"; }`,
+		`keep`,
+		`if true keep;`,
+		`else { keep; }`,
+		`discard "x";`,
+		`if header :is :contains "a" "b" { keep; }`,
+		`if header :comparator "i;unknown" "a" "b" { keep; }`,
+		`if size 100 { keep; }`,
+		`if envelope "to" "b" { keep; }`,
+		`require "envelope"; if envelope "auth" "b" { keep; }`,
+		`redirect "not an address";`,
+		`require "variables"; set "1a" "b";`,
+		`require "variables"; set :lower :upper "a" "b";`,
+		`keep; /* unterminated`,
+		`keep :flags "\\Seen";`,
+		`stop; }`,
+		`if header "a" ["b" "c"] { keep; }`,
+		`bogus;`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	test := func(typ, comparator, value, key string, expected bool, captures ...string) {
+		t.Helper()
+		ok, caps := matchSpec{typ: typ, comparator: comparator}.match(value, key)
+		if ok != expected {
+			t.Errorf("%s %s %q %q = %v, expected %v", typ, comparator, value, key, ok, expected)
+		}
+		if len(captures) != 0 && !reflect.DeepEqual(caps, captures) {
+			t.Errorf("%s %q %q captures = %q, expected %q", typ, value, key, caps, captures)
+		}
+	}
+
+	test("is", "i;ascii-casemap", "Hello", "hELLO", true)
+	test("is", "i;octet", "Hello", "hELLO", false)
+	test("contains", "i;ascii-casemap", "Hello World", "O W", true)
+	test("matches", "i;ascii-casemap", "", "*", true)
+	test("matches", "i;ascii-casemap", "abc", "a?c", true, "abc", "b")
+	test("matches", "i;ascii-casemap", "€x", "?x", true, "€x", "€")
+	test("matches", "i;ascii-casemap", "a*b", `a\*b`, true)
+	test("matches", "i;ascii-casemap", "axb", `a\*b`, false)
+	test("matches", "i;ascii-casemap", "x.y.z", "*.*", true, "x.y.z", "x.y", "z")
+	test("matches", "i;octet", "ABC", "a*", false)
+	test("matches", "i;ascii-casemap", strings.Repeat("a", 100), strings.Repeat("*a", 50)+"b", false)
+}
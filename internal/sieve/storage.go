@@ -0,0 +1,84 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+)
+
+var (
+	// ErrNoActiveScript is returned by Storage.Active if the account has no
+	// active script.
+	ErrNoActiveScript = errors.New("sieve: no active script")
+	ErrNoScript       = errors.New("sieve: script does not exist")
+	ErrScriptExists   = errors.New("sieve: script already exists")
+	ErrActiveScript   = errors.New("sieve: script is active")
+)
+
+// ScriptInfo describes a stored script.
+type ScriptInfo struct {
+	Name   string
+	Active bool
+}
+
+// Storage keeps scripts of each account. At most one script of the account
+// is active, it is executed for incoming messages.
+//
+// Implementations do not validate script contents.
+type Storage interface {
+	List(account string) ([]ScriptInfo, error)
+	Get(account, name string) (string, error)
+	Put(account, name, src string) error
+	Delete(account, name string) error
+	Rename(account, oldName, newName string) error
+	// SetActive makes the script active, empty name deactivates the active
+	// script.
+	SetActive(account, name string) error
+	// Active returns the name and the contents of the active script.
+	Active(account string) (name, src string, err error)
+}
+
+// ValidName checks whether the name can be used as an account or script
+// name.
+func ValidName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\") || !utf8.ValidString(name) {
+		return fmt.Errorf("sieve: invalid name: %q", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("sieve: invalid name: %q", name)
+		}
+	}
+	return nil
+}
+
+// OpenStorage returns the storage configured using scripts_table and
+// scripts_dir directives. The table should implement module.MutableTable.
+// Relative directory paths are interpreted relative to the state directory.
+func OpenStorage(dir string, tbl module.Table) (Storage, error) {
+	if tbl != nil {
+		mtbl, ok := tbl.(module.MutableTable)
+		if !ok {
+			return nil, errors.New("sieve: scripts_table should be a mutable table")
+		}
+		return NewTableStore(mtbl), nil
+	}
+
+	if dir == "" {
+		dir = "sieve"
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(config.StateDirectory, dir)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return NewDirStore(dir), nil
+}
@@ -0,0 +1,107 @@
+package sieve
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type memTable map[string]string
+
+func (t memTable) Lookup(_ context.Context, key string) (string, bool, error) {
+	v, ok := t[key]
+	return v, ok, nil
+}
+
+func (t memTable) Keys() ([]string, error) {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (t memTable) RemoveKey(key string) error {
+	delete(t, key)
+	return nil
+}
+
+func (t memTable) SetKey(key, value string) error {
+	t[key] = value
+	return nil
+}
+
+func testStorage(t *testing.T, s Storage) {
+	const acct = "bob@example.com"
+
+	if _, _, err := s.Active(acct); !errors.Is(err, ErrNoActiveScript) {
+		t.Fatalf("expected ErrNoActiveScript, got %v", err)
+	}
+	if err := s.Put(acct, "main", "keep;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(acct, "vacation", "discard;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("alice@example.com", "other", "keep;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(acct, "../escape", "keep;"); err == nil {
+		t.Error("invalid name is accepted")
+	}
+	if err := s.SetActive(acct, "missing"); !errors.Is(err, ErrNoScript) {
+		t.Errorf("expected ErrNoScript, got %v", err)
+	}
+	if err := s.SetActive(acct, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.List(acct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScriptInfo{{Name: "main", Active: true}, {Name: "vacation"}}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("got %+v, expected %+v", list, expected)
+	}
+
+	if err := s.Delete(acct, "main"); !errors.Is(err, ErrActiveScript) {
+		t.Errorf("expected ErrActiveScript, got %v", err)
+	}
+	if err := s.Rename(acct, "main", "vacation"); !errors.Is(err, ErrScriptExists) {
+		t.Errorf("expected ErrScriptExists, got %v", err)
+	}
+	if err := s.Rename(acct, "main", "filters"); err != nil {
+		t.Fatal(err)
+	}
+	name, src, err := s.Active(acct)
+	if err != nil || name != "filters" || src != "keep;" {
+		t.Errorf("wrong active script after rename: %q %q %v", name, src, err)
+	}
+
+	if err := s.SetActive(acct, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(acct, "filters"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(acct, "filters"); !errors.Is(err, ErrNoScript) {
+		t.Errorf("expected ErrNoScript, got %v", err)
+	}
+	list, err = s.List(acct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []ScriptInfo{{Name: "vacation"}}) {
+		t.Errorf("wrong list after delete: %+v", list)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	testStorage(t, NewDirStore(t.TempDir()))
+}
+
+func TestTableStore(t *testing.T) {
+	testStorage(t, NewTableStore(memTable{}))
+}
@@ -0,0 +1,195 @@
+package sieve
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+)
+
+// TableStore keeps scripts in a mutable table. Scripts are stored under
+// "<account>/<script name>" keys, the name of the active script is stored
+// under "<account>/.active".
+type TableStore struct {
+	tbl module.MutableTable
+	// lock serializes multi-key updates.
+	lock sync.Mutex
+}
+
+func NewTableStore(tbl module.MutableTable) *TableStore {
+	return &TableStore{tbl: tbl}
+}
+
+func (s *TableStore) key(account, name string) (string, error) {
+	if err := ValidName(account); err != nil {
+		return "", err
+	}
+	if name != activeFile {
+		if err := ValidName(name); err != nil {
+			return "", err
+		}
+	}
+	return account + "/" + name, nil
+}
+
+func (s *TableStore) lookup(account, name string) (string, bool, error) {
+	key, err := s.key(account, name)
+	if err != nil {
+		return "", false, err
+	}
+	return s.tbl.Lookup(context.TODO(), key)
+}
+
+func (s *TableStore) List(account string) ([]ScriptInfo, error) {
+	if err := ValidName(account); err != nil {
+		return nil, err
+	}
+	active, _, err := s.lookup(account, activeFile)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.tbl.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := account + "/"
+	var scripts []ScriptInfo
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == activeFile {
+			continue
+		}
+		scripts = append(scripts, ScriptInfo{Name: name, Active: name == active})
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts, nil
+}
+
+func (s *TableStore) Get(account, name string) (string, error) {
+	src, ok, err := s.lookup(account, name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrNoScript
+	}
+	return src, nil
+}
+
+func (s *TableStore) Put(account, name, src string) error {
+	key, err := s.key(account, name)
+	if err != nil {
+		return err
+	}
+	return s.tbl.SetKey(key, src)
+}
+
+func (s *TableStore) Delete(account, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key, err := s.key(account, name)
+	if err != nil {
+		return err
+	}
+	active, _, err := s.lookup(account, activeFile)
+	if err != nil {
+		return err
+	}
+	if active == name {
+		return ErrActiveScript
+	}
+	_, ok, err := s.tbl.Lookup(context.TODO(), key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoScript
+	}
+	return s.tbl.RemoveKey(key)
+}
+
+func (s *TableStore) Rename(account, oldName, newName string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	oldKey, err := s.key(account, oldName)
+	if err != nil {
+		return err
+	}
+	newKey, err := s.key(account, newName)
+	if err != nil {
+		return err
+	}
+
+	src, ok, err := s.tbl.Lookup(context.TODO(), oldKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoScript
+	}
+	if _, ok, err := s.tbl.Lookup(context.TODO(), newKey); err != nil {
+		return err
+	} else if ok {
+		return ErrScriptExists
+	}
+	active, _, err := s.lookup(account, activeFile)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tbl.SetKey(newKey, src); err != nil {
+		return err
+	}
+	if active == oldName {
+		if err := s.tbl.SetKey(account+"/"+activeFile, newName); err != nil {
+			return err
+		}
+	}
+	return s.tbl.RemoveKey(oldKey)
+}
+
+func (s *TableStore) SetActive(account, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	activeKey, err := s.key(account, activeFile)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		if _, ok, err := s.tbl.Lookup(context.TODO(), activeKey); err != nil || !ok {
+			return err
+		}
+		return s.tbl.RemoveKey(activeKey)
+	}
+
+	if _, ok, err := s.lookup(account, name); err != nil {
+		return err
+	} else if !ok {
+		return ErrNoScript
+	}
+	return s.tbl.SetKey(activeKey, name)
+}
+
+func (s *TableStore) Active(account string) (name, src string, err error) {
+	name, ok, err := s.lookup(account, activeFile)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok || name == "" {
+		return "", "", ErrNoActiveScript
+	}
+	src, ok, err = s.lookup(account, name)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return "", "", ErrNoActiveScript
+	}
+	return name, src, nil
+}
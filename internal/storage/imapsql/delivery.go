@@ -35,6 +35,8 @@ import (
 
 type addedRcpt struct {
 	rcptTo string
+	// aliases are other RCPT TO addresses of the same account.
+	aliases []string
 }
 type delivery struct {
 	store    *Storage
@@ -67,17 +69,24 @@ func (d *delivery) AddRcpt(ctx context.Context, rcptTo string, _ smtp.RcptOption
 		return userDoesNotExist(err)
 	}
 
-	if _, ok := d.addedRcpts[accountName]; ok {
+	if data, ok := d.addedRcpts[accountName]; ok {
+		data.aliases = append(data.aliases, rcptTo)
+		d.addedRcpts[accountName] = data
 		return nil
 	}
 
-	// This header is added to the message only for that recipient.
-	// go-imap-sql does certain optimizations to store the message
-	// with small amount of per-recipient data in a efficient way.
-	userHeader := textproto.Header{}
-	userHeader.Add("Delivered-To", accountName)
+	// Message size is known only if the client used the SIZE parameter,
+	// otherwise the message is rejected only if the mailbox is already full.
+	// The actual size is checked in Body.
+	var size uint64
+	if d.msgMeta.SMTPOpts.Size > 0 {
+		size = uint64(d.msgMeta.SMTPOpts.Size)
+	}
+	if err := d.store.checkQuota(ctx, accountName, size, 1); err != nil {
+		return quotaSMTPError(err)
+	}
 
-	if err := d.d.AddRcpt(accountName, userHeader); err != nil {
+	if err := d.addRcpt(accountName); err != nil {
 		if err == imapsql.ErrUserDoesntExists || err == backend.ErrNoSuchMailbox {
 			return userDoesNotExist(err)
 		}
@@ -99,9 +108,81 @@ func (d *delivery) AddRcpt(ctx context.Context, rcptTo string, _ smtp.RcptOption
 	return nil
 }
 
+func (d *delivery) addRcpt(accountName string) error {
+	// This header is added to the message only for that recipient.
+	// go-imap-sql does certain optimizations to store the message
+	// with small amount of per-recipient data in a efficient way.
+	userHeader := textproto.Header{}
+	userHeader.Add("Delivered-To", accountName)
+
+	return d.d.AddRcpt(accountName, userHeader)
+}
+
 func (d *delivery) Body(ctx context.Context, header textproto.Header, body buffer.Buffer) error {
 	defer trace.StartRegion(ctx, "sql/Body").End()
 
+	// The SIZE parameter is optional and is not required to match the
+	// message. The atomic delivery can't succeed only for some recipients
+	// so the message is rejected for all of them, BodyNonAtomic rejects
+	// it only for recipients over quota.
+	for rcpt := range d.addedRcpts {
+		if err := d.store.checkQuota(ctx, rcpt, uint64(body.Len()), 1); err != nil {
+			return quotaSMTPError(err)
+		}
+	}
+
+	return d.body(header, body)
+}
+
+func (d *delivery) BodyNonAtomic(ctx context.Context, c module.StatusCollector, header textproto.Header, body buffer.Buffer) {
+	defer trace.StartRegion(ctx, "sql/BodyNonAtomic").End()
+
+	setStatus := func(data addedRcpt, err error) {
+		c.SetStatus(data.rcptTo, err)
+		for _, alias := range data.aliases {
+			c.SetStatus(alias, err)
+		}
+	}
+
+	overQuota := false
+	for rcpt, data := range d.addedRcpts {
+		if err := d.store.checkQuota(ctx, rcpt, uint64(body.Len()), 1); err != nil {
+			setStatus(data, quotaSMTPError(err))
+			delete(d.addedRcpts, rcpt)
+			overQuota = true
+		}
+	}
+	if len(d.addedRcpts) == 0 {
+		return
+	}
+
+	var err error
+	if overQuota {
+		// go-imap-sql does not allow removing recipients, so the delivery
+		// is reset and started again for recipients within quota.
+		err = d.restart()
+	}
+	if err == nil {
+		err = d.body(header, body)
+	}
+	for _, data := range d.addedRcpts {
+		setStatus(data, err)
+	}
+}
+
+func (d *delivery) restart() error {
+	if err := d.d.Abort(); err != nil {
+		return err
+	}
+	for rcpt := range d.addedRcpts {
+		if err := d.addRcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *delivery) body(header textproto.Header, body buffer.Buffer) error {
 	if !d.msgMeta.Quarantine && d.store.filters != nil {
 		for rcpt, rcptData := range d.addedRcpts {
 			folder, flags, err := d.store.filters.IMAPFilter(rcpt, rcptData.rcptTo, d.msgMeta, header, body)
@@ -152,7 +233,13 @@ func (d *delivery) Abort(ctx context.Context) error {
 func (d *delivery) Commit(ctx context.Context) error {
 	defer trace.StartRegion(ctx, "sql/Commit").End()
 
-	return d.d.Commit()
+	if err := d.d.Commit(); err != nil {
+		return err
+	}
+	for rcpt := range d.addedRcpts {
+		d.store.indexNew(rcpt)
+	}
+	return nil
 }
 
 func (store *Storage) Start(ctx context.Context, msgMeta *module.MsgMetadata, mailFrom string) (module.Delivery, error) {
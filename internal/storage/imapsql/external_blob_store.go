@@ -30,6 +30,9 @@ func (w WriteExtBlob) Read(p []byte) (n int, err error) {
 
 type ExtBlobStore struct {
 	Base module.BlobStore
+
+	// onDelete is called with the keys of deleted blobs.
+	onDelete func(keys []string)
 }
 
 func (e ExtBlobStore) Create(key string, objSize int64) (imapsql.ExtStoreObj, error) {
@@ -57,6 +60,9 @@ func (e ExtBlobStore) Open(key string) (imapsql.ExtStoreObj, error) {
 }
 
 func (e ExtBlobStore) Delete(keys []string) error {
+	if e.onDelete != nil {
+		e.onDelete(keys)
+	}
 	err := e.Base.Delete(context.TODO(), keys)
 	if err != nil {
 		return imapsql.ExternalError{
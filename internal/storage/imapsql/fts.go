@@ -0,0 +1,604 @@
+package imapsql
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/emersion/go-imap"
+	sortthread "github.com/emersion/go-imap-sortthread"
+	"github.com/emersion/go-imap/backend"
+	imapsql "github.com/foxcpp/go-imap-sql"
+)
+
+// ftsIndex is the full-text index of message bodies used to answer IMAP
+// SEARCH BODY and TEXT queries without reading the messages.
+//
+// The index is kept in the storage database so it is shared by all
+// server instances using it. Terms are stored per message body key
+// (extBodyKey), copies of the message share its index entries.
+// mailchat_fts_mboxes tracks the last indexed UID of each mailbox, newer
+// messages are indexed after delivery and APPEND and before searching
+// the mailbox, so the index catches up if indexing failed earlier.
+//
+// Search terms are matched as prefixes of the indexed words, which is less
+// strict than substring matching required by RFC 3501 but is what users
+// normally expect from the full-text search.
+type ftsIndex struct {
+	store   *Storage
+	maxSize int64
+
+	deletedLock sync.Mutex
+	deleted     []string
+}
+
+func (idx *ftsIndex) init() error {
+	db := idx.store.Back.DB
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS mailchat_fts_docs (
+		extKey VARCHAR(255) PRIMARY KEY NOT NULL,
+		userId BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("imapsql: create fts docs table: %w", err)
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS mailchat_fts_docs_user
+		ON mailchat_fts_docs(userId)`)
+	if err != nil {
+		return fmt.Errorf("imapsql: create fts docs index: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS mailchat_fts_terms (
+		term VARCHAR(255) NOT NULL,
+		field INTEGER NOT NULL,
+		extKey VARCHAR(255) NOT NULL,
+		PRIMARY KEY (term, field, extKey)
+	)`)
+	if err != nil {
+		return fmt.Errorf("imapsql: create fts terms table: %w", err)
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS mailchat_fts_terms_key
+		ON mailchat_fts_terms(extKey)`)
+	if err != nil {
+		return fmt.Errorf("imapsql: create fts terms index: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS mailchat_fts_mboxes (
+		mboxId BIGINT PRIMARY KEY NOT NULL,
+		lastUid BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("imapsql: create fts mailboxes table: %w", err)
+	}
+	return nil
+}
+
+// indexMessage adds the message to the index unless it is already
+// indexed.
+func (idx *ftsIndex) indexMessage(userID uint64, key string, r io.Reader) error {
+	terms, err := extractTerms(r, idx.maxSize)
+	if err != nil {
+		// Index what was extracted so the message is not retried on each
+		// search.
+		idx.store.Log.Error("failed to parse message for indexing", err, "key", key)
+	}
+
+	store := idx.store
+	tx, err := store.Back.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.Exec(store.rebind(`INSERT INTO mailchat_fts_docs(extKey, userId) VALUES (?, ?)
+		ON CONFLICT (extKey) DO NOTHING`), key, userID)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return err
+	}
+
+	stmt, err := tx.Prepare(store.rebind(`INSERT INTO mailchat_fts_terms(term, field, extKey) VALUES (?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for t := range terms {
+		if _, err := stmt.Exec(t.term, t.field, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type messageLister interface {
+	ListMessages(uid bool, seqset *imap.SeqSet, items []imap.FetchItem, ch chan<- *imap.Message) error
+}
+
+// indexMailbox indexes messages added to the mailbox since the last call.
+func (idx *ftsIndex) indexMailbox(userID, mboxID uint64, mbox messageLister) error {
+	store := idx.store
+
+	var lastUID uint32
+	err := store.Back.DB.QueryRow(store.rebind(`SELECT lastUid FROM mailchat_fts_mboxes WHERE mboxId = ?`), mboxID).Scan(&lastUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	rows, err := store.Back.DB.Query(store.rebind(`SELECT msgs.msgId, msgs.extBodyKey, mailchat_fts_docs.extKey IS NOT NULL
+		FROM msgs LEFT JOIN mailchat_fts_docs ON mailchat_fts_docs.extKey = msgs.extBodyKey
+		WHERE msgs.mboxId = ? AND msgs.msgId > ? AND msgs.extBodyKey IS NOT NULL`), mboxID, lastUID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		maxUID = lastUID
+		keys   = map[uint32]string{}
+	)
+	for rows.Next() {
+		var (
+			uid     uint32
+			key     string
+			indexed bool
+		)
+		if err := rows.Scan(&uid, &key, &indexed); err != nil {
+			return err
+		}
+		if uid > maxUID {
+			maxUID = uid
+		}
+		if !indexed {
+			keys[uid] = key
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if len(keys) != 0 {
+		if err := idx.indexMessages(userID, mbox, keys); err != nil {
+			return err
+		}
+	}
+	if maxUID == lastUID {
+		return nil
+	}
+
+	_, err = store.Back.DB.Exec(store.rebind(`INSERT INTO mailchat_fts_mboxes(mboxId, lastUid) VALUES (?, ?)
+		ON CONFLICT (mboxId) DO UPDATE SET lastUid = excluded.lastUid
+		WHERE excluded.lastUid > mailchat_fts_mboxes.lastUid`), mboxID, maxUID)
+	return err
+}
+
+func (idx *ftsIndex) indexMessages(userID uint64, mbox messageLister, keys map[uint32]string) error {
+	// Messages are read one by one and indexed after ListMessages returns
+	// so the index is not written to while the query is running.
+	for _, uid := range sortedUIDs(keys) {
+		body, err := readMessage(mbox, uid)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			continue
+		}
+		if err := idx.indexMessage(userID, keys[uid], bytes.NewReader(body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedUIDs(keys map[uint32]string) []uint32 {
+	uids := make([]uint32, 0, len(keys))
+	for uid := range keys {
+		uids = append(uids, uid)
+	}
+	slices.Sort(uids)
+	return uids
+}
+
+// readMessage returns the full message, nil if it does not exist.
+func readMessage(mbox messageLister, uid uint32) ([]byte, error) {
+	var seq imap.SeqSet
+	seq.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+	ch := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- mbox.ListMessages(true, &seq, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, ch)
+	}()
+
+	var (
+		body    []byte
+		readErr error
+	)
+	for msg := range ch {
+		for _, lit := range msg.Body {
+			body, readErr = io.ReadAll(lit)
+		}
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return body, readErr
+}
+
+// indexAccount indexes new messages in all mailboxes of the account.
+func (idx *ftsIndex) indexAccount(accountName string) error {
+	store := idx.store
+	u, err := store.Back.GetUser(accountName)
+	if err != nil {
+		return err
+	}
+	defer u.Logout() //nolint:errcheck
+	sqlUser := u.(*imapsql.User)
+
+	rows, err := store.Back.DB.Query(store.rebind(`SELECT mboxes.id, mboxes.name FROM mboxes
+		LEFT JOIN mailchat_fts_mboxes ON mailchat_fts_mboxes.mboxId = mboxes.id
+		WHERE mboxes.uid = ? AND mboxes.uidnext - 1 > COALESCE(mailchat_fts_mboxes.lastUid, 0)`), sqlUser.ID())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	mboxes := map[uint64]string{}
+	for rows.Next() {
+		var (
+			id   uint64
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		mboxes[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, name := range mboxes {
+		_, mbox, err := sqlUser.GetMailbox(name, true, nil)
+		if err != nil {
+			if errors.Is(err, backend.ErrNoSuchMailbox) {
+				continue
+			}
+			return err
+		}
+		err = idx.indexMailbox(sqlUser.ID(), id, mbox)
+		mbox.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// rebuild removes the index of the account and indexes all its messages
+// again.
+func (idx *ftsIndex) rebuild(accountName string) error {
+	store := idx.store
+	u, err := store.Back.GetUser(accountName)
+	if err != nil {
+		return err
+	}
+	userID := u.(*imapsql.User).ID()
+	_ = u.Logout()
+
+	tx, err := store.Back.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	for _, query := range []string{
+		`DELETE FROM mailchat_fts_terms WHERE extKey IN (SELECT extKey FROM mailchat_fts_docs WHERE userId = ?)`,
+		`DELETE FROM mailchat_fts_docs WHERE userId = ?`,
+		`DELETE FROM mailchat_fts_mboxes WHERE mboxId IN (SELECT id FROM mboxes WHERE uid = ?)`,
+	} {
+		if _, err := tx.Exec(store.rebind(query), userID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := idx.dropMailboxes(); err != nil {
+		return err
+	}
+
+	return idx.indexAccount(accountName)
+}
+
+// removed records body keys deleted from the storage. The index entries
+// are removed by flush as the keys can be deleted while the storage
+// transaction is still open.
+func (idx *ftsIndex) removed(keys []string) {
+	idx.deletedLock.Lock()
+	defer idx.deletedLock.Unlock()
+	idx.deleted = append(idx.deleted, keys...)
+}
+
+// flush removes the index entries of deleted messages.
+func (idx *ftsIndex) flush() error {
+	idx.deletedLock.Lock()
+	keys := idx.deleted
+	idx.deleted = nil
+	idx.deletedLock.Unlock()
+
+	const batch = 500
+	store := idx.store
+	for len(keys) != 0 {
+		n := min(len(keys), batch)
+		args := make([]interface{}, n)
+		for i, key := range keys[:n] {
+			args[i] = key
+		}
+		in := strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+		for _, table := range []string{"mailchat_fts_terms", "mailchat_fts_docs"} {
+			if _, err := store.Back.DB.Exec(store.rebind(`DELETE FROM `+table+` WHERE extKey IN (`+in+`)`), args...); err != nil {
+				idx.removed(keys)
+				return err
+			}
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// dropMailboxes removes the index state of deleted mailboxes.
+func (idx *ftsIndex) dropMailboxes() error {
+	_, err := idx.store.Back.DB.Exec(`DELETE FROM mailchat_fts_mboxes
+		WHERE NOT EXISTS (SELECT 1 FROM mboxes WHERE mboxes.id = mailchat_fts_mboxes.mboxId)`)
+	return err
+}
+
+// termCond returns the SQL condition matching the terms that start with
+// prefix.
+func (idx *ftsIndex) termCond(prefix string) (string, []interface{}) {
+	if idx.store.driver == "postgres" {
+		return `term LIKE ?`, []interface{}{prefix + "%"}
+	}
+	// Range query can use the primary key index in SQLite. Valid UTF-8
+	// strings never contain 0xFF byte.
+	return `term >= ? AND term < ?`, []interface{}{prefix, prefix + "\xff"}
+}
+
+// match returns UIDs of messages in the mailbox that contain all words
+// from body (in the message body) and text (in header or body). nil is
+// returned if there are no words to match.
+func (idx *ftsIndex) match(mboxID uint64, body, text []string) (*imap.SeqSet, error) {
+	query := `SELECT msgId FROM msgs WHERE mboxId = ?`
+	args := []interface{}{mboxID}
+	seen := map[ftsTerm]bool{}
+
+	addTerms := func(values []string, field int) {
+		for _, val := range values {
+			tokenize(val, func(term string) {
+				t := ftsTerm{term: term, field: field}
+				if seen[t] {
+					return
+				}
+				seen[t] = true
+
+				cond, condArgs := idx.termCond(term)
+				query += ` AND extBodyKey IN (SELECT extKey FROM mailchat_fts_terms WHERE ` + cond
+				args = append(args, condArgs...)
+				if field == fieldBody {
+					query += ` AND field = ?`
+					args = append(args, fieldBody)
+				}
+				query += `)`
+			})
+		}
+	}
+	addTerms(body, fieldBody)
+	// -1 matches any field.
+	addTerms(text, -1)
+	if len(seen) == 0 {
+		return nil, nil
+	}
+
+	rows, err := idx.store.Back.DB.Query(idx.store.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	set := &imap.SeqSet{}
+	for rows.Next() {
+		var uid uint32
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		set.AddNum(uid)
+	}
+	return set, rows.Err()
+}
+
+func needsIndex(c *imap.SearchCriteria) bool {
+	if c == nil {
+		return false
+	}
+	if len(c.Body) != 0 || len(c.Text) != 0 {
+		return true
+	}
+	for _, not := range c.Not {
+		if needsIndex(not) {
+			return true
+		}
+	}
+	for _, or := range c.Or {
+		if needsIndex(or[0]) || needsIndex(or[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrite replaces BODY and TEXT keys in the criteria with the set of UIDs
+// matched by the index.
+func (idx *ftsIndex) rewrite(mboxID uint64, c *imap.SearchCriteria) (*imap.SearchCriteria, error) {
+	if !needsIndex(c) {
+		return c, nil
+	}
+
+	res := *c
+	res.Body, res.Text = nil, nil
+	res.Not = make([]*imap.SearchCriteria, 0, len(c.Not))
+	res.Or = make([][2]*imap.SearchCriteria, 0, len(c.Or))
+
+	for _, not := range c.Not {
+		rewritten, err := idx.rewrite(mboxID, not)
+		if err != nil {
+			return nil, err
+		}
+		res.Not = append(res.Not, rewritten)
+	}
+	for _, or := range c.Or {
+		left, err := idx.rewrite(mboxID, or[0])
+		if err != nil {
+			return nil, err
+		}
+		right, err := idx.rewrite(mboxID, or[1])
+		if err != nil {
+			return nil, err
+		}
+		res.Or = append(res.Or, [2]*imap.SearchCriteria{left, right})
+	}
+
+	uids, err := idx.match(mboxID, c.Body, c.Text)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case uids == nil:
+	case res.Uid == nil:
+		res.Uid = uids
+	default:
+		res.Not = append(res.Not, &imap.SearchCriteria{
+			Not: []*imap.SearchCriteria{{Uid: uids}},
+		})
+	}
+	return &res, nil
+}
+
+// ftsMailbox answers searches using the full-text index.
+type ftsMailbox struct {
+	quotaMailbox
+	user *imapsql.User
+	idx  *ftsIndex
+}
+
+func (m ftsMailbox) mailboxID() (uint64, error) {
+	store := m.idx.store
+	var id uint64
+	err := store.Back.DB.QueryRow(store.rebind(`SELECT id FROM mboxes WHERE uid = ? AND name = ?`),
+		m.user.ID(), m.Name()).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, backend.ErrNoSuchMailbox
+	}
+	return id, err
+}
+
+func (m ftsMailbox) criteria(c *imap.SearchCriteria) (*imap.SearchCriteria, error) {
+	if !needsIndex(c) {
+		return c, nil
+	}
+	id, err := m.mailboxID()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.idx.indexMailbox(m.user.ID(), id, m.Mailbox); err != nil {
+		m.idx.store.Log.Error("failed to index mailbox", err, "username", m.user.Username(), "mailbox", m.Name())
+	}
+	rewritten, err := m.idx.rewrite(id, c)
+	if err != nil {
+		m.idx.store.Log.Error("full-text search failed", err, "username", m.user.Username(), "mailbox", m.Name())
+		return nil, errors.New("internal server error")
+	}
+	return rewritten, nil
+}
+
+func (m ftsMailbox) SearchMessages(uid bool, c *imap.SearchCriteria) ([]uint32, error) {
+	c, err := m.criteria(c)
+	if err != nil {
+		return nil, err
+	}
+	return m.Mailbox.SearchMessages(uid, c)
+}
+
+func (m ftsMailbox) Sort(uid bool, sortCrit []sortthread.SortCriterion, c *imap.SearchCriteria) ([]uint32, error) {
+	c, err := m.criteria(c)
+	if err != nil {
+		return nil, err
+	}
+	return m.Mailbox.Sort(uid, sortCrit, c)
+}
+
+func (m ftsMailbox) Thread(uid bool, threading sortthread.ThreadAlgorithm, c *imap.SearchCriteria) ([]*sortthread.Thread, error) {
+	c, err := m.criteria(c)
+	if err != nil {
+		return nil, err
+	}
+	return m.Mailbox.Thread(uid, threading, c)
+}
+
+func (m ftsMailbox) flush() {
+	if err := m.idx.flush(); err != nil {
+		m.idx.store.Log.Error("failed to remove deleted messages from index", err, "username", m.user.Username())
+	}
+}
+
+func (m ftsMailbox) Expunge() error {
+	defer m.flush()
+	return m.Mailbox.Expunge()
+}
+
+func (m ftsMailbox) DelMessages(uid bool, seqset *imap.SeqSet) error {
+	defer m.flush()
+	return m.Mailbox.DelMessages(uid, seqset)
+}
+
+// indexNew indexes messages delivered to the accounts. Failures are only
+// logged, messages are indexed before searching anyway.
+func (store *Storage) indexNew(accounts ...string) {
+	if store.fts == nil {
+		return
+	}
+	for _, acct := range accounts {
+		if err := store.fts.indexAccount(acct); err != nil {
+			store.Log.Error("failed to index new messages", err, "username", acct)
+		}
+	}
+}
+
+func (store *Storage) ftsRemoved(keys []string) {
+	if store.fts != nil {
+		store.fts.removed(keys)
+	}
+}
+
+// ftsDropDeleted removes the index entries of deleted messages and
+// mailboxes.
+func (store *Storage) ftsDropDeleted() {
+	if store.fts == nil {
+		return
+	}
+	if err := store.fts.flush(); err != nil {
+		store.Log.Error("failed to remove deleted messages from index", err)
+	}
+	if err := store.fts.dropMailboxes(); err != nil {
+		store.Log.Error("failed to remove deleted mailboxes from index", err)
+	}
+}
+
+func (store *Storage) RebuildIndex(accountName string) error {
+	if store.fts == nil {
+		return errors.New("imapsql: full_text_search is disabled")
+	}
+	return store.fts.rebuild(accountName)
+}
@@ -0,0 +1,183 @@
+//go:build !nosqlite3 && cgo
+// +build !nosqlite3,cgo
+
+package imapsql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/storage/blob/fs"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	"github.com/emersion/go-imap"
+	imapsql "github.com/foxcpp/go-imap-sql"
+)
+
+func ftsTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "messages"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := fs.New("storage.blob.fs", "", nil, []string{filepath.Join(dir, "messages")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &Storage{
+		Log:    testutils.Logger(t, "imapsql"),
+		driver: "sqlite3",
+		quotas: quotaCache{entries: map[string]quotaEntry{}},
+		deliveryNormalize: func(_ context.Context, s string) (string, error) {
+			return s, nil
+		},
+		authNormalize: func(_ context.Context, s string) (string, error) {
+			return s, nil
+		},
+	}
+	store.Back, err = imapsql.New("sqlite3", filepath.Join(dir, "imapsql.db"),
+		ExtBlobStore{Base: blobs.(module.BlobStore), onDelete: store.ftsRemoved}, imapsql.Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	store.quotaOverrides = sqlQuotas{store: store}
+	if err := (sqlQuotas{store: store}).init(); err != nil {
+		t.Fatal(err)
+	}
+	store.fts = &ftsIndex{store: store, maxSize: 1024 * 1024}
+	if err := store.fts.init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateIMAPAcct("user@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+const ftsTestMsg = "From: <alice@example.org>\r\n" +
+	"Subject: Lunch plans\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Caf=C3=A9 meeting tomorrow\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p><b>Quarterly</b>&nbsp;report</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv\r\n" +
+	"Content-Disposition: attachment; filename=data.csv\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"cmV2ZW51ZSwxMjM0\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"\r\n" +
+	"secretword\r\n" +
+	"--outer--\r\n"
+
+func TestStorage_FullTextSearch(t *testing.T) {
+	store := ftsTestStorage(t)
+
+	testutils.DoTestDelivery(t, store, "sender@example.org", []string{"user@example.org"})
+
+	u, err := store.GetOrCreateIMAPAcct("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.CreateMessage(imap.InboxName, nil, time.Now(), strings.NewReader(ftsTestMsg), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, mbox, err := u.GetMailbox(imap.InboxName, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbox.Close()
+
+	check := func(c *imap.SearchCriteria, expected ...uint32) {
+		t.Helper()
+		uids, err := mbox.SearchMessages(true, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uids) == 0 && len(expected) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(uids, expected) {
+			t.Errorf("wrong search result for %+v: %v, expected %v", c, uids, expected)
+		}
+	}
+	body := func(s ...string) *imap.SearchCriteria {
+		return &imap.SearchCriteria{Body: s}
+	}
+
+	check(body("foobar"), 1)
+	check(body("CAFÉ"), 2)
+	check(body("quart"), 2)
+	check(body("meeting tomorrow"), 2)
+	check(body("revenue"), 2)
+	check(body("secretword"))
+	check(body("lunch"))
+	check(&imap.SearchCriteria{Text: []string{"lunch"}}, 2)
+	check(&imap.SearchCriteria{Not: []*imap.SearchCriteria{body("foobar")}}, 2)
+	check(&imap.SearchCriteria{Or: [][2]*imap.SearchCriteria{{body("foobar"), body("report")}}}, 1, 2)
+	uidSet, _ := imap.ParseSeqSet("1")
+	check(&imap.SearchCriteria{Uid: uidSet, Body: []string{"cafe", "café"}})
+	check(&imap.SearchCriteria{Uid: uidSet, Body: []string{"foo"}}, 1)
+
+	seq, _ := imap.ParseSeqSet("2")
+	delMbox, ok := mbox.(interface {
+		DelMessages(uid bool, seqset *imap.SeqSet) error
+	})
+	if !ok {
+		t.Fatal("mailbox does not support message removal")
+	}
+	if err := delMbox.DelMessages(true, seq); err != nil {
+		t.Fatal(err)
+	}
+
+	var docs, terms int
+	if err := store.Back.DB.QueryRow(`SELECT COUNT(*) FROM mailchat_fts_docs`).Scan(&docs); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Back.DB.QueryRow(`SELECT COUNT(*) FROM mailchat_fts_terms WHERE term = 'revenue'`).Scan(&terms); err != nil {
+		t.Fatal(err)
+	}
+	if docs != 1 || terms != 0 {
+		t.Fatalf("expunged message is not removed from index: %d docs, %d terms", docs, terms)
+	}
+
+	if err := store.RebuildIndex("user@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	check(body("foobar"), 1)
+}
+
+func TestTokenize(t *testing.T) {
+	var terms []string
+	tokenize("Hello, Wörld! 你好 x_123 "+strings.Repeat("a", 70), func(term string) {
+		terms = append(terms, term)
+	})
+	expected := []string{"hello", "wörld", "你", "好", "x", "123", strings.Repeat("a", maxTermLen)}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Fatalf("wrong terms: %q", terms)
+	}
+}
@@ -0,0 +1,193 @@
+package imapsql
+
+import (
+	"errors"
+	"html"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset"
+)
+
+const (
+	// maxTermLen is the maximum length of an indexed term in characters,
+	// longer words are truncated. Search terms are matched as prefixes so
+	// truncated words can still be found.
+	maxTermLen = 64
+
+	// maxPartDepth limits the nesting of multipart and message/rfc822 parts.
+	maxPartDepth = 16
+)
+
+const (
+	fieldHeader = 0
+	fieldBody   = 1
+)
+
+type ftsTerm struct {
+	term  string
+	field int
+}
+
+// isCJK reports whether r belongs to a script that does not separate
+// words using spaces. Such characters are indexed individually.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func truncateTerm(term string) string {
+	if utf8.RuneCountInString(term) <= maxTermLen {
+		return term
+	}
+	n := 0
+	for i := range term {
+		if n == maxTermLen {
+			return term[:i]
+		}
+		n++
+	}
+	return term
+}
+
+// tokenize splits s into lower-case terms. Terms are sequences of letters
+// and digits, CJK characters are separate terms.
+func tokenize(s string, fn func(term string)) {
+	start := -1
+	flush := func(end int) {
+		if start != -1 {
+			fn(truncateTerm(strings.ToLower(s[start:end])))
+			start = -1
+		}
+	}
+	for i, r := range s {
+		switch {
+		case isCJK(r):
+			flush(i)
+			fn(string(r))
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if start == -1 {
+				start = i
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(s))
+}
+
+func stripHTML(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			b.WriteByte(' ')
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(b.String())
+}
+
+// textExtractor collects the terms of a message. Header field values are
+// indexed as fieldHeader, the contents of text parts (including text
+// attachments) and of attached messages as fieldBody.
+type textExtractor struct {
+	remaining int64
+	terms     map[ftsTerm]struct{}
+}
+
+func (x *textExtractor) add(field int, text string) {
+	tokenize(text, func(term string) {
+		x.terms[ftsTerm{term: term, field: field}] = struct{}{}
+	})
+}
+
+func (x *textExtractor) header(field int, h message.Header) {
+	fields := h.Fields()
+	for fields.Next() {
+		text, err := fields.Text()
+		if err != nil {
+			text = fields.Value()
+		}
+		x.add(field, text)
+	}
+}
+
+func (x *textExtractor) entity(ent *message.Entity, depth int) error {
+	if depth > maxPartDepth || x.remaining <= 0 {
+		return nil
+	}
+
+	if mr := ent.MultipartReader(); mr != nil {
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil && (part == nil || !isCharsetError(err)) {
+				return err
+			}
+			if err := x.entity(part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	mediaType, _, err := ent.Header.ContentType()
+	if err != nil || mediaType == "" {
+		mediaType = "text/plain"
+	}
+	switch {
+	case mediaType == "message/rfc822" || mediaType == "message/global":
+		nested, err := message.Read(ent.Body)
+		if err != nil && !isCharsetError(err) {
+			// Not a valid message, index nothing instead of failing the
+			// whole message.
+			return nil
+		}
+		x.header(fieldBody, nested.Header)
+		return x.entity(nested, depth+1)
+	case strings.HasPrefix(mediaType, "text/"):
+		text, err := io.ReadAll(io.LimitReader(ent.Body, x.remaining))
+		if err != nil {
+			return nil
+		}
+		x.remaining -= int64(len(text))
+		if mediaType == "text/html" {
+			x.add(fieldBody, stripHTML(string(text)))
+		} else {
+			x.add(fieldBody, string(text))
+		}
+	}
+	return nil
+}
+
+func isCharsetError(err error) bool {
+	return message.IsUnknownCharset(err) || message.IsUnknownEncoding(err)
+}
+
+// extractTerms parses the message and returns the set of its terms. At
+// most maxSize bytes of text are indexed.
+func extractTerms(r io.Reader, maxSize int64) (map[ftsTerm]struct{}, error) {
+	ent, err := message.Read(r)
+	if err != nil && !isCharsetError(err) {
+		return nil, err
+	}
+
+	x := textExtractor{
+		remaining: maxSize,
+		terms:     make(map[ftsTerm]struct{}),
+	}
+	x.header(fieldHeader, ent.Header)
+	if err := x.entity(ent, 0); err != nil {
+		return x.terms, err
+	}
+	return x.terms, nil
+}
@@ -35,6 +35,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/emersion/go-imap"
 	sortthread "github.com/emersion/go-imap-sortthread"
@@ -76,6 +77,16 @@ type Storage struct {
 	deliveryNormalize func(context.Context, string) (string, error)
 	authMap           module.Table
 	authNormalize     func(context.Context, string) (string, error)
+
+	quotaSource    module.MailboxQuotas
+	quotaOverrides quotaOverrides
+	quotaDefaults  module.Quota
+	quotaTTL       time.Duration
+	quotas         quotaCache
+
+	notifier updateNotifier
+
+	fts *ftsIndex
 }
 
 func (store *Storage) Name() string {
@@ -113,6 +124,13 @@ func (store *Storage) Init(cfg *config.Map) error {
 		deliveryNormalize string
 
 		blobStore module.BlobStore
+
+		quotaTable    module.Table
+		quotaStorage  int64
+		quotaMessages int
+
+		ftsEnabled bool
+		ftsMaxSize int64
 	)
 
 	opts := imapsql.Opts{}
@@ -156,6 +174,19 @@ func (store *Storage) Init(cfg *config.Map) error {
 		return nil, nil
 	}, modconfig.TableDirective, &store.deliveryMap)
 	cfg.String("delivery_normalize", false, false, "precis_casefold_email", &deliveryNormalize)
+	cfg.Custom("quota", false, false, func() (interface{}, error) {
+		return nil, nil
+	}, func(m *config.Map, node config.Node) (interface{}, error) {
+		var quotas module.MailboxQuotas
+		err := modconfig.ModuleFromNode("blockchain", node.Args, node, m.Globals, &quotas)
+		return quotas, err
+	}, &store.quotaSource)
+	cfg.Duration("quota_cache_ttl", false, false, time.Minute, &store.quotaTTL)
+	cfg.DataSize("default_quota_storage", false, false, 0, &quotaStorage)
+	cfg.Int("default_quota_messages", false, false, 0, &quotaMessages)
+	modconfig.Table(cfg, "quota_table", false, false, nil, &quotaTable)
+	cfg.Bool("full_text_search", false, false, &ftsEnabled)
+	cfg.DataSize("full_text_max_size", false, false, 1024*1024, &ftsMaxSize)
 
 	if _, err := cfg.Process(); err != nil {
 		return err
@@ -264,15 +295,44 @@ func (store *Storage) Init(cfg *config.Map) error {
 		}
 	}
 
-	store.Back, err = imapsql.New(driver, dsnStr, ExtBlobStore{Base: blobStore}, opts)
+	store.driver = driver
+	store.dsn = dsn
+	if err := module.CheckExclusive(cfg.Globals, store); err != nil {
+		return fmt.Errorf("imapsql: %w", err)
+	}
+
+	store.Back, err = imapsql.New(driver, dsnStr, ExtBlobStore{Base: blobStore, onDelete: store.ftsRemoved}, opts)
 	if err != nil {
 		return fmt.Errorf("imapsql: %s", err)
 	}
 
 	store.Log.Debugln("go-imap-sql version", imapsql.VersionStr)
 
-	store.driver = driver
-	store.dsn = dsn
+	store.quotas.entries = make(map[string]quotaEntry)
+	if quotaMessages < 0 {
+		return errors.New("imapsql: default_quota_messages must not be negative")
+	}
+	store.quotaDefaults = module.Quota{
+		StorageLimit:  uint64(quotaStorage),
+		MessagesLimit: uint64(quotaMessages),
+	}
+
+	if quotaTable != nil {
+		store.quotaOverrides = tableQuotas{tbl: quotaTable}
+	} else {
+		sqlQuotas := sqlQuotas{store: store}
+		if err := sqlQuotas.init(); err != nil {
+			return err
+		}
+		store.quotaOverrides = sqlQuotas
+	}
+
+	if ftsEnabled {
+		store.fts = &ftsIndex{store: store, maxSize: ftsMaxSize}
+		if err := store.fts.init(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -351,6 +411,7 @@ func (store *Storage) EnableUpdatePipe(mode updatepipe.BackendMode) error {
 			case u := <-inbound:
 				store.Log.DebugMsg("external update received", "type", u.Type, "key", u.Key)
 				store.Back.UpdateManager().ExternalUpdate(u)
+				store.notifyUpdate(u)
 			case u, ok := <-outbound:
 				if !ok {
 					return
@@ -359,6 +420,7 @@ func (store *Storage) EnableUpdatePipe(mode updatepipe.BackendMode) error {
 				if err := store.updPipe.Push(u); err != nil {
 					store.Log.Error("IMAP update pipe push failed", err)
 				}
+				store.notifyUpdate(u)
 			}
 		}
 	}()
@@ -371,7 +433,7 @@ func (store *Storage) I18NLevel() int {
 }
 
 func (store *Storage) IMAPExtensions() []string {
-	return []string{"APPENDLIMIT", "MOVE", "CHILDREN", "SPECIAL-USE", "I18NLEVEL=1", "SORT", "THREAD=ORDEREDSUBJECT"}
+	return []string{"APPENDLIMIT", "MOVE", "CHILDREN", "SPECIAL-USE", "I18NLEVEL=1", "SORT", "THREAD=ORDEREDSUBJECT", "QUOTA"}
 }
 
 func (store *Storage) CreateMessageLimit() *uint32 {
@@ -384,7 +446,7 @@ func (store *Storage) GetOrCreateIMAPAcct(username string) (backend.User, error)
 		return nil, backend.ErrInvalidCredentials
 	}
 
-	return store.Back.GetOrCreateUser(accountName)
+	return store.wrapUser(store.Back.GetOrCreateUser(accountName))
 }
 
 func (store *Storage) Lookup(ctx context.Context, key string) (string, bool, error) {
@@ -407,7 +469,25 @@ func (store *Storage) Lookup(ctx context.Context, key string) (string, bool, err
 	return "", true, nil
 }
 
+func (store *Storage) CheckHealth(ctx context.Context) error {
+	return store.Back.DB.PingContext(ctx)
+}
+
+// ExclusiveResource implements module.Exclusive. Two instances using the
+// same database would conflict on the update pipe and the search index.
+func (store *Storage) ExclusiveResource() string {
+	dsn := strings.Join(store.dsn, " ")
+	if store.driver == "sqlite3" || store.driver == "sqlite" {
+		return store.driver + " database " + dsn
+	}
+	// DSN might contain the password.
+	id := sha1.Sum([]byte(dsn))
+	return fmt.Sprintf("%s database (DSN SHA-1 %x)", store.driver, id[:8])
+}
+
 func (store *Storage) Close() error {
+	store.ftsDropDeleted()
+
 	// Stop backend from generating new updates.
 	store.Back.Close()
 
@@ -34,7 +34,11 @@ func (store *Storage) CreateIMAPAcct(accountName string) error {
 }
 
 func (store *Storage) DeleteIMAPAcct(accountName string) error {
-	return store.Back.DeleteUser(accountName)
+	if err := store.Back.DeleteUser(accountName); err != nil {
+		return err
+	}
+	store.ftsDropDeleted()
+	return nil
 }
 
 func (store *Storage) GetIMAPAcct(accountName string) (backend.User, error) {
@@ -0,0 +1,81 @@
+package imapsql
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/dsoftgames/MailChat/internal/updatepipe"
+	mess "github.com/foxcpp/go-imap-mess"
+)
+
+// maxMboxOwners is the size of the mailbox owners cache after which it is
+// cleared.
+const maxMboxOwners = 10000
+
+// updateNotifier dispatches updates to the functions registered using
+// NotifyUpdates.
+type updateNotifier struct {
+	lock   sync.Mutex
+	funcs  []func(username string, upd mess.Update)
+	owners map[uint64]string
+}
+
+var _ updatepipe.Notifier = &Storage{}
+
+func (store *Storage) NotifyUpdates(fn func(username string, upd mess.Update)) {
+	store.notifier.lock.Lock()
+	defer store.notifier.lock.Unlock()
+	store.notifier.funcs = append(store.notifier.funcs, fn)
+}
+
+// mboxOwner returns the name of the account owning the mailbox. Owners are
+// cached since the mailbox can be already deleted when the update for it is
+// processed.
+func (store *Storage) mboxOwner(mboxID uint64) (string, error) {
+	store.notifier.lock.Lock()
+	username, ok := store.notifier.owners[mboxID]
+	store.notifier.lock.Unlock()
+	if ok {
+		return username, nil
+	}
+
+	query := `SELECT users.username FROM mboxes
+		INNER JOIN users ON users.id = mboxes.uid
+		WHERE mboxes.id = ?`
+	if store.driver == "postgres" {
+		query = strings.Replace(query, "?", "$1", 1)
+	}
+	if err := store.Back.DB.QueryRow(query, mboxID).Scan(&username); err != nil {
+		return "", err
+	}
+
+	store.notifier.lock.Lock()
+	if store.notifier.owners == nil || len(store.notifier.owners) >= maxMboxOwners {
+		store.notifier.owners = make(map[uint64]string)
+	}
+	store.notifier.owners[mboxID] = username
+	store.notifier.lock.Unlock()
+	return username, nil
+}
+
+func (store *Storage) notifyUpdate(upd mess.Update) {
+	store.notifier.lock.Lock()
+	funcs := store.notifier.funcs
+	store.notifier.lock.Unlock()
+	if len(funcs) == 0 {
+		return
+	}
+
+	mboxID, ok := upd.Key.(uint64)
+	if !ok {
+		return
+	}
+	username, err := store.mboxOwner(mboxID)
+	if err != nil {
+		store.Log.DebugMsg("failed to find mailbox owner for update", "mbox_id", mboxID, "reason", err)
+		return
+	}
+	for _, fn := range funcs {
+		fn(username, upd)
+	}
+}
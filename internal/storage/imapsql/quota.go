@@ -0,0 +1,360 @@
+package imapsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/module"
+)
+
+var (
+	errOverQuota = errors.New("imapsql: mailbox is over quota")
+	// errQuotaTooBig is returned if the message alone does not fit into
+	// the storage quota, so retrying the delivery is pointless.
+	errQuotaTooBig = errors.New("imapsql: message is bigger than the mailbox quota")
+)
+
+type quotaEntry struct {
+	limits  module.Quota
+	fetched time.Time
+}
+
+// quotaCache keeps the resolved account limits.
+type quotaCache struct {
+	lock    sync.Mutex
+	entries map[string]quotaEntry
+}
+
+// quotaOverrides keeps limits set for individual accounts and domains.
+// Domain limits use "@domain" as the name.
+type quotaOverrides interface {
+	Get(ctx context.Context, name string) (module.QuotaLimits, error)
+	Set(name string, limits module.QuotaLimits) error
+}
+
+func applyLimits(q *module.Quota, l module.QuotaLimits) {
+	if l.Storage != nil {
+		q.StorageLimit = *l.Storage
+	}
+	if l.Messages != nil {
+		q.MessagesLimit = *l.Messages
+	}
+}
+
+// domainQuotaName returns the name used for domain limits of the account,
+// empty string if the account name has no domain.
+func domainQuotaName(accountName string) string {
+	at := strings.LastIndexByte(accountName, '@')
+	if at == -1 || at == len(accountName)-1 {
+		return ""
+	}
+	return accountName[at:]
+}
+
+// resolveLimits computes the effective limits of the account. Account
+// limits take precedence over the quota purchased on chain, which in turn
+// takes precedence over domain limits and the configured defaults.
+func (store *Storage) resolveLimits(ctx context.Context, accountName string) (module.Quota, error) {
+	limits := module.Quota{
+		StorageLimit:  store.quotaDefaults.StorageLimit,
+		MessagesLimit: store.quotaDefaults.MessagesLimit,
+	}
+
+	if domain := domainQuotaName(accountName); domain != "" {
+		domainLimits, err := store.quotaOverrides.Get(ctx, domain)
+		if err != nil {
+			return module.Quota{}, err
+		}
+		applyLimits(&limits, domainLimits)
+	}
+
+	if store.quotaSource != nil {
+		limit, err := store.quotaSource.MailboxQuota(ctx, accountName)
+		if err != nil {
+			return module.Quota{}, err
+		}
+		// Zero means the chain has no quota for the mailbox (e.g. the free
+		// quota is not set), not that the mailbox is unlimited.
+		if limit != 0 {
+			limits.StorageLimit = limit
+		}
+	}
+
+	acctLimits, err := store.quotaOverrides.Get(ctx, accountName)
+	if err != nil {
+		return module.Quota{}, err
+	}
+	applyLimits(&limits, acctLimits)
+
+	return limits, nil
+}
+
+// quotaLimits returns the limits of the account. Limits are cached for
+// quota_cache_ttl. If the lookup fails, the cached value is used regardless
+// of its age.
+func (store *Storage) quotaLimits(ctx context.Context, accountName string) (module.Quota, error) {
+	store.quotas.lock.Lock()
+	entry, ok := store.quotas.entries[accountName]
+	store.quotas.lock.Unlock()
+	if ok && time.Since(entry.fetched) < store.quotaTTL {
+		return entry.limits, nil
+	}
+
+	limits, err := store.resolveLimits(ctx, accountName)
+	if err != nil {
+		if ok {
+			store.Log.Error("quota lookup failed, using cached value", err, "username", accountName)
+			return entry.limits, nil
+		}
+		return module.Quota{}, err
+	}
+
+	store.quotas.lock.Lock()
+	store.quotas.entries[accountName] = quotaEntry{limits: limits, fetched: time.Now()}
+	store.quotas.lock.Unlock()
+	return limits, nil
+}
+
+// accountQuota returns the limits and the current usage of the account.
+// Usage is not queried if the account has no limits.
+func (store *Storage) accountQuota(ctx context.Context, accountName string, withUsage bool) (module.Quota, error) {
+	q, err := store.quotaLimits(ctx, accountName)
+	if err != nil {
+		return module.Quota{}, err
+	}
+	if !withUsage && q.StorageLimit == 0 && q.MessagesLimit == 0 {
+		return q, nil
+	}
+
+	query := `SELECT COALESCE(SUM(msgs.bodyLen), 0), COUNT(*) FROM msgs
+		INNER JOIN mboxes ON mboxes.id = msgs.mboxId
+		INNER JOIN users ON users.id = mboxes.uid
+		WHERE users.username = ?`
+	var storage, messages int64
+	if err := store.Back.DB.QueryRow(store.rebind(query), accountName).Scan(&storage, &messages); err != nil {
+		return module.Quota{}, fmt.Errorf("imapsql: quota usage: %w", err)
+	}
+	q.Storage = uint64(storage)
+	q.Messages = uint64(messages)
+	return q, nil
+}
+
+// checkQuota returns errOverQuota if storing count messages of the
+// specified total size would exceed the account quota. If size is unknown
+// (0), it checks whether the account is already full.
+func (store *Storage) checkQuota(ctx context.Context, accountName string, size, count uint64) error {
+	q, err := store.accountQuota(ctx, accountName, false)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case q.StorageLimit != 0 && size > q.StorageLimit:
+		err = errQuotaTooBig
+	case q.StorageLimit != 0 && (q.Storage+size > q.StorageLimit || (size == 0 && q.Storage >= q.StorageLimit)):
+		err = errOverQuota
+	case q.MessagesLimit != 0 && q.Messages+count > q.MessagesLimit:
+		err = errOverQuota
+	default:
+		return nil
+	}
+
+	store.Log.Msg("mailbox is over quota", "username", accountName,
+		"usage", q.Storage, "size", size, "limit", q.StorageLimit,
+		"messages", q.Messages, "count", count, "messages_limit", q.MessagesLimit)
+	return err
+}
+
+func quotaSMTPError(err error) error {
+	switch {
+	case errors.Is(err, errQuotaTooBig):
+		return &exterrors.SMTPError{
+			Code:         552,
+			EnhancedCode: exterrors.EnhancedCode{5, 2, 2},
+			Message:      "Message is bigger than the mailbox quota",
+			TargetName:   "imapsql",
+			Err:          err,
+		}
+	case errors.Is(err, errOverQuota):
+		return &exterrors.SMTPError{
+			Code:         452,
+			EnhancedCode: exterrors.EnhancedCode{4, 2, 2},
+			Message:      "Mailbox is over quota",
+			TargetName:   "imapsql",
+			Err:          err,
+		}
+	}
+	return &exterrors.SMTPError{
+		Code:         451,
+		EnhancedCode: exterrors.EnhancedCode{4, 3, 0},
+		Message:      "Internal server error, try again later",
+		TargetName:   "imapsql",
+		Err:          err,
+	}
+}
+
+// rebind replaces ? placeholders with the ones used by the driver.
+func (store *Storage) rebind(query string) string {
+	if store.driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// sqlQuotas keeps limits in the storage database.
+type sqlQuotas struct {
+	store *Storage
+}
+
+func (q sqlQuotas) init() error {
+	_, err := q.store.Back.DB.Exec(`CREATE TABLE IF NOT EXISTS mailchat_quotas (
+		name VARCHAR(255) PRIMARY KEY NOT NULL,
+		storage_limit BIGINT,
+		msgs_limit BIGINT
+	)`)
+	if err != nil {
+		return fmt.Errorf("imapsql: create quotas table: %w", err)
+	}
+	return nil
+}
+
+func (q sqlQuotas) Get(_ context.Context, name string) (module.QuotaLimits, error) {
+	var storage, messages sql.NullInt64
+	err := q.store.Back.DB.QueryRow(q.store.rebind(`SELECT storage_limit, msgs_limit
+		FROM mailchat_quotas WHERE name = ?`), name).Scan(&storage, &messages)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return module.QuotaLimits{}, nil
+		}
+		return module.QuotaLimits{}, fmt.Errorf("imapsql: quota lookup: %w", err)
+	}
+
+	var limits module.QuotaLimits
+	if storage.Valid {
+		val := uint64(storage.Int64)
+		limits.Storage = &val
+	}
+	if messages.Valid {
+		val := uint64(messages.Int64)
+		limits.Messages = &val
+	}
+	return limits, nil
+}
+
+func (q sqlQuotas) Set(name string, limits module.QuotaLimits) error {
+	if limits.Storage == nil && limits.Messages == nil {
+		_, err := q.store.Back.DB.Exec(q.store.rebind(`DELETE FROM mailchat_quotas WHERE name = ?`), name)
+		return err
+	}
+
+	var storage, messages sql.NullInt64
+	if limits.Storage != nil {
+		storage = sql.NullInt64{Int64: int64(*limits.Storage), Valid: true}
+	}
+	if limits.Messages != nil {
+		messages = sql.NullInt64{Int64: int64(*limits.Messages), Valid: true}
+	}
+	_, err := q.store.Back.DB.Exec(q.store.rebind(`INSERT INTO mailchat_quotas(name, storage_limit, msgs_limit)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET storage_limit = excluded.storage_limit, msgs_limit = excluded.msgs_limit`),
+		name, storage, messages)
+	return err
+}
+
+// tableQuotas reads limits from a table. Values are space-separated
+// key=value pairs, e.g. "storage=1G messages=10000".
+type tableQuotas struct {
+	tbl module.Table
+}
+
+func parseQuotaLimits(s string) (module.QuotaLimits, error) {
+	var limits module.QuotaLimits
+	for _, field := range strings.Fields(s) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return module.QuotaLimits{}, fmt.Errorf("malformed quota value: %s", field)
+		}
+		switch key {
+		case "storage":
+			size, err := config.ParseDataSize(value)
+			if err != nil {
+				return module.QuotaLimits{}, fmt.Errorf("malformed storage limit: %w", err)
+			}
+			val := uint64(size)
+			limits.Storage = &val
+		case "messages":
+			val, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return module.QuotaLimits{}, fmt.Errorf("malformed messages limit: %w", err)
+			}
+			limits.Messages = &val
+		default:
+			return module.QuotaLimits{}, fmt.Errorf("unknown quota resource: %s", key)
+		}
+	}
+	return limits, nil
+}
+
+func formatQuotaLimits(limits module.QuotaLimits) string {
+	var fields []string
+	if limits.Storage != nil {
+		fields = append(fields, "storage="+strconv.FormatUint(*limits.Storage, 10)+"B")
+	}
+	if limits.Messages != nil {
+		fields = append(fields, "messages="+strconv.FormatUint(*limits.Messages, 10))
+	}
+	return strings.Join(fields, " ")
+}
+
+func (q tableQuotas) Get(ctx context.Context, name string) (module.QuotaLimits, error) {
+	val, ok, err := q.tbl.Lookup(ctx, name)
+	if err != nil || !ok {
+		return module.QuotaLimits{}, err
+	}
+	limits, err := parseQuotaLimits(val)
+	if err != nil {
+		return module.QuotaLimits{}, fmt.Errorf("imapsql: quota_table: %s: %w", name, err)
+	}
+	return limits, nil
+}
+
+func (q tableQuotas) Set(name string, limits module.QuotaLimits) error {
+	mtbl, ok := q.tbl.(module.MutableTable)
+	if !ok {
+		return errors.New("imapsql: quota_table is not mutable")
+	}
+	if limits.Storage == nil && limits.Messages == nil {
+		return mtbl.RemoveKey(name)
+	}
+	return mtbl.SetKey(name, formatQuotaLimits(limits))
+}
+
+func (store *Storage) QuotaLimits(name string) (module.QuotaLimits, error) {
+	return store.quotaOverrides.Get(context.TODO(), name)
+}
+
+func (store *Storage) SetQuotaLimits(name string, limits module.QuotaLimits) error {
+	return store.quotaOverrides.Set(name, limits)
+}
+
+func (store *Storage) AccountQuota(accountName string) (module.Quota, error) {
+	return store.accountQuota(context.TODO(), accountName, true)
+}
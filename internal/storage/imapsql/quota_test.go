@@ -0,0 +1,417 @@
+//go:build !nosqlite3 && cgo
+// +build !nosqlite3,cgo
+
+package imapsql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+	imapsql "github.com/foxcpp/go-imap-sql"
+)
+
+type staticQuotas map[string]uint64
+
+func (q staticQuotas) MailboxQuota(_ context.Context, mailbox string) (uint64, error) {
+	limit, ok := q[mailbox]
+	if !ok {
+		return 0, errors.New("unknown mailbox")
+	}
+	return limit, nil
+}
+
+func quotaTestStorage(t *testing.T, quotas staticQuotas) *Storage {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "messages"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := imapsql.New("sqlite3", filepath.Join(dir, "imapsql.db"), &imapsql.FSStore{Root: filepath.Join(dir, "messages")}, imapsql.Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := &Storage{
+		Back:        db,
+		Log:         testutils.Logger(t, "imapsql"),
+		driver:      "sqlite3",
+		quotaSource: quotas,
+		quotaTTL:    time.Minute,
+		quotas:      quotaCache{entries: map[string]quotaEntry{}},
+		deliveryNormalize: func(_ context.Context, s string) (string, error) {
+			return s, nil
+		},
+		authNormalize: func(_ context.Context, s string) (string, error) {
+			return s, nil
+		},
+	}
+	if quotas == nil {
+		store.quotaSource = nil
+	}
+	sqlQuotas := sqlQuotas{store: store}
+	if err := sqlQuotas.init(); err != nil {
+		t.Fatal(err)
+	}
+	store.quotaOverrides = sqlQuotas
+	if err := store.CreateIMAPAcct("user@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func checkSMTPCode(t *testing.T, err error, code int) {
+	t.Helper()
+	var smtpErr *exterrors.SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("expected SMTP error, got %v", err)
+	}
+	if smtpErr.Code != code {
+		t.Fatalf("wrong SMTP code: %d (%v)", smtpErr.Code, err)
+	}
+}
+
+func TestStorage_QuotaDelivery(t *testing.T) {
+	store := quotaTestStorage(t, staticQuotas{"user@example.org": 150})
+
+	testutils.DoTestDelivery(t, store, "sender@example.org", []string{"user@example.org"})
+
+	// Declared size does not fit.
+	_, err := testutils.DoTestDeliveryErrMeta(t, store, "sender@example.org", []string{"user@example.org"}, &module.MsgMetadata{
+		SMTPOpts: smtp.MailOptions{Size: 100},
+	})
+	checkSMTPCode(t, err, 452)
+
+	// Size is unknown, the message is accepted until the mailbox is full.
+	testutils.DoTestDelivery(t, store, "sender@example.org", []string{"user@example.org"})
+	_, err = testutils.DoTestDeliveryErr(t, store, "sender@example.org", []string{"user@example.org"})
+	checkSMTPCode(t, err, 452)
+
+	// Message does not fit even into the empty mailbox.
+	_, err = testutils.DoTestDeliveryErrMeta(t, store, "sender@example.org", []string{"user@example.org"}, &module.MsgMetadata{
+		SMTPOpts: smtp.MailOptions{Size: 200},
+	})
+	checkSMTPCode(t, err, 552)
+
+	// Quota source failures are temporary errors.
+	_, err = testutils.DoTestDeliveryErr(t, store, "sender@example.org", []string{"unknown@example.org"})
+	checkSMTPCode(t, err, 451)
+}
+
+func TestStorage_QuotaAppend(t *testing.T) {
+	store := quotaTestStorage(t, staticQuotas{"user@example.org": 100})
+
+	u, err := store.GetOrCreateIMAPAcct("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qu, ok := u.(module.QuotaUser)
+	if !ok {
+		t.Fatal("user does not implement module.QuotaUser")
+	}
+
+	msg := []byte("Subject: test\r\n\r\n" + string(bytes.Repeat([]byte("a"), 50)) + "\r\n")
+	if err := u.CreateMessage(imap.InboxName, nil, time.Now(), bytes.NewReader(msg), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := qu.Quota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Storage != uint64(len(msg)) || q.StorageLimit != 100 || q.Messages != 1 {
+		t.Fatalf("wrong quota: %+v", q)
+	}
+
+	err = u.CreateMessage(imap.InboxName, nil, time.Now(), bytes.NewReader(msg), nil)
+	var statusErr *imap.ErrStatusResp
+	if !errors.As(err, &statusErr) || statusErr.Resp.Code != "OVERQUOTA" {
+		t.Fatalf("expected OVERQUOTA response, got %v", err)
+	}
+}
+
+func TestStorage_QuotaDeliveryNoSize(t *testing.T) {
+	store := quotaTestStorage(t, staticQuotas{"user@example.org": 150})
+
+	// Size is unknown at RCPT TO, the message is rejected once received.
+	delivery, err := store.Start(context.Background(), &module.MsgMetadata{ID: "test"}, "sender@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := delivery.AddRcpt(context.Background(), "user@example.org", smtp.RcptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	hdr := textproto.Header{}
+	hdr.Add("Subject", "test")
+	err = delivery.Body(context.Background(), hdr, buffer.MemoryBuffer{Slice: bytes.Repeat([]byte("a"), 200)})
+	checkSMTPCode(t, err, 552)
+	if err := delivery.Abort(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := store.AccountQuota("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Storage != 0 || q.Messages != 0 {
+		t.Fatalf("rejected message is stored: %+v", q)
+	}
+}
+
+type rcptStatuses map[string]error
+
+func (s rcptStatuses) SetStatus(rcptTo string, err error) {
+	s[rcptTo] = err
+}
+
+func TestStorage_QuotaDeliveryPartial(t *testing.T) {
+	store := quotaTestStorage(t, staticQuotas{"user@example.org": 20, "other@example.org": 1000})
+	if err := store.CreateIMAPAcct("other@example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := store.GetOrCreateIMAPAcct("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.CreateMessage(imap.InboxName, nil, time.Now(), bytes.NewReader([]byte("Subject: x\r\n\r\nabc")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the recipient over quota is rejected.
+	statuses := rcptStatuses{}
+	testutils.DoTestDeliveryNonAtomic(t, statuses, store, "sender@example.org", []string{"user@example.org", "other@example.org"})
+	checkSMTPCode(t, statuses["user@example.org"], 452)
+	if err, ok := statuses["other@example.org"]; !ok || err != nil {
+		t.Fatalf("expected successful delivery to other@example.org, got %v", statuses)
+	}
+
+	for rcpt, messages := range map[string]uint64{"user@example.org": 1, "other@example.org": 1} {
+		q, err := store.AccountQuota(rcpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Messages != messages {
+			t.Fatalf("wrong amount of messages for %s: %+v", rcpt, q)
+		}
+	}
+}
+
+func TestStorage_QuotaCopy(t *testing.T) {
+	msg := []byte("Subject: test\r\n\r\n" + string(bytes.Repeat([]byte("a"), 50)) + "\r\n")
+	quotas := staticQuotas{"user@example.org": uint64(2*len(msg) - 1)}
+	store := quotaTestStorage(t, quotas)
+	store.quotaTTL = 0
+
+	u, err := store.GetOrCreateIMAPAcct("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.CreateMessage(imap.InboxName, nil, time.Now(), bytes.NewReader(msg), nil); err != nil {
+		t.Fatal(err)
+	}
+	_, mbox, err := u.GetMailbox(imap.InboxName, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqset, _ := imap.ParseSeqSet("1:*")
+
+	err = mbox.CopyMessages(false, seqset, imap.InboxName)
+	var statusErr *imap.ErrStatusResp
+	if !errors.As(err, &statusErr) || statusErr.Resp.Code != "OVERQUOTA" {
+		t.Fatalf("expected OVERQUOTA response, got %v", err)
+	}
+
+	quotas["user@example.org"] = uint64(2 * len(msg))
+	if err := mbox.CopyMessages(false, seqset, imap.InboxName); err != nil {
+		t.Fatal(err)
+	}
+	q, err := u.(module.QuotaUser).Quota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Storage != uint64(2*len(msg)) || q.Messages != 2 {
+		t.Fatalf("wrong quota: %+v", q)
+	}
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func TestStorage_QuotaLimits(t *testing.T) {
+	store := quotaTestStorage(t, nil)
+	store.quotaTTL = 0
+	store.quotaDefaults = module.Quota{StorageLimit: 1000, MessagesLimit: 10}
+
+	check := func(expected module.Quota) {
+		t.Helper()
+		q, err := store.AccountQuota("user@example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != expected {
+			t.Fatalf("wrong quota: %+v, expected %+v", q, expected)
+		}
+	}
+
+	check(module.Quota{StorageLimit: 1000, MessagesLimit: 10})
+
+	if err := store.SetQuotaLimits("@example.org", module.QuotaLimits{Storage: uint64Ptr(2000)}); err != nil {
+		t.Fatal(err)
+	}
+	check(module.Quota{StorageLimit: 2000, MessagesLimit: 10})
+
+	if err := store.SetQuotaLimits("user@example.org", module.QuotaLimits{Messages: uint64Ptr(1)}); err != nil {
+		t.Fatal(err)
+	}
+	check(module.Quota{StorageLimit: 2000, MessagesLimit: 1})
+
+	// Update replaces both limits.
+	if err := store.SetQuotaLimits("user@example.org", module.QuotaLimits{Storage: uint64Ptr(0), Messages: uint64Ptr(1)}); err != nil {
+		t.Fatal(err)
+	}
+	check(module.Quota{StorageLimit: 0, MessagesLimit: 1})
+
+	testutils.DoTestDelivery(t, store, "sender@example.org", []string{"user@example.org"})
+	_, err := testutils.DoTestDeliveryErr(t, store, "sender@example.org", []string{"user@example.org"})
+	checkSMTPCode(t, err, 452)
+
+	limits, err := store.QuotaLimits("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.Storage == nil || *limits.Storage != 0 || limits.Messages == nil || *limits.Messages != 1 {
+		t.Fatalf("wrong limits: %+v", limits)
+	}
+
+	if err := store.SetQuotaLimits("user@example.org", module.QuotaLimits{}); err != nil {
+		t.Fatal(err)
+	}
+	limits, err = store.QuotaLimits("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.Storage != nil || limits.Messages != nil {
+		t.Fatalf("limits are not removed: %+v", limits)
+	}
+}
+
+func TestStorage_QuotaChainZero(t *testing.T) {
+	quotas := staticQuotas{"user@example.org": 0}
+	store := quotaTestStorage(t, quotas)
+	store.quotaTTL = 0
+	store.quotaDefaults = module.Quota{StorageLimit: 1000}
+
+	// Zero chain quota does not make the mailbox unlimited.
+	q, err := store.AccountQuota("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.StorageLimit != 1000 {
+		t.Fatalf("wrong quota: %+v", q)
+	}
+
+	quotas["user@example.org"] = 5000
+	q, err = store.AccountQuota("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.StorageLimit != 5000 {
+		t.Fatalf("chain quota is not applied: %+v", q)
+	}
+}
+
+func TestStorage_QuotaLimitsNoSize(t *testing.T) {
+	store := quotaTestStorage(t, nil)
+	store.quotaDefaults = module.Quota{StorageLimit: 100}
+
+	delivery, err := store.Start(context.Background(), &module.MsgMetadata{ID: "test"}, "sender@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := delivery.AddRcpt(context.Background(), "user@example.org", smtp.RcptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err = delivery.Body(context.Background(), textproto.Header{}, buffer.MemoryBuffer{Slice: bytes.Repeat([]byte("a"), 200)})
+	checkSMTPCode(t, err, 552)
+	if err := delivery.Abort(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorage_QuotaLimitsCopy(t *testing.T) {
+	store := quotaTestStorage(t, nil)
+	store.quotaTTL = 0
+	if err := store.SetQuotaLimits("@example.org", module.QuotaLimits{Messages: uint64Ptr(3)}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := store.GetOrCreateIMAPAcct("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("Subject: test\r\n\r\nHello!\r\n")
+	for i := 0; i < 2; i++ {
+		if err := u.CreateMessage(imap.InboxName, nil, time.Now(), bytes.NewReader(msg), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, mbox, err := u.GetMailbox(imap.InboxName, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both messages do not fit.
+	all, _ := imap.ParseSeqSet("1:*")
+	err = mbox.CopyMessages(false, all, imap.InboxName)
+	var statusErr *imap.ErrStatusResp
+	if !errors.As(err, &statusErr) || statusErr.Resp.Code != "OVERQUOTA" {
+		t.Fatalf("expected OVERQUOTA response, got %v", err)
+	}
+
+	first, _ := imap.ParseSeqSet("1")
+	if err := mbox.CopyMessages(false, first, imap.InboxName); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := store.AccountQuota("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Messages != 3 || q.MessagesLimit != 3 {
+		t.Fatalf("wrong quota: %+v", q)
+	}
+}
+
+func TestParseQuotaLimits(t *testing.T) {
+	limits, err := parseQuotaLimits("storage=1M messages=100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *limits.Storage != 1024*1024 || *limits.Messages != 100 {
+		t.Fatalf("wrong limits: %+v", limits)
+	}
+	if formatted := formatQuotaLimits(limits); formatted != "storage=1048576B messages=100" {
+		t.Fatalf("wrong formatted value: %s", formatted)
+	}
+
+	for _, bad := range []string{"storage", "storage=1X", "messages=-1", "disk=1G"} {
+		if _, err := parseQuotaLimits(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package imapsql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	imapsql "github.com/foxcpp/go-imap-sql"
+)
+
+// storageUser enforces the account quota on APPEND and COPY and reports it
+// for the IMAP QUOTA extension. If full-text search is enabled, it also
+// keeps the index up to date and uses it for searches.
+type storageUser struct {
+	*imapsql.User
+	store *Storage
+}
+
+var _ module.QuotaUser = storageUser{}
+
+// quotaIMAPError converts the checkQuota error into the IMAP response.
+func (store *Storage) quotaIMAPError(username string, err error) error {
+	if errors.Is(err, errOverQuota) || errors.Is(err, errQuotaTooBig) {
+		return &imap.ErrStatusResp{Resp: &imap.StatusResp{
+			Type: imap.StatusRespNo,
+			Code: "OVERQUOTA",
+			Info: "Mailbox is over quota",
+		}}
+	}
+	store.Log.Error("quota check failed", err, "username", username)
+	return errors.New("internal server error")
+}
+
+func (u storageUser) CreateMessage(mbox string, flags []string, date time.Time, body imap.Literal, selected backend.Mailbox) error {
+	if err := u.store.checkQuota(context.TODO(), u.Username(), uint64(body.Len()), 1); err != nil {
+		return u.store.quotaIMAPError(u.Username(), err)
+	}
+	if err := u.User.CreateMessage(mbox, flags, date, body, selected); err != nil {
+		return err
+	}
+	u.store.indexNew(u.Username())
+	return nil
+}
+
+func (u storageUser) Quota() (module.Quota, error) {
+	return u.store.accountQuota(context.TODO(), u.Username(), true)
+}
+
+func (u storageUser) GetMailbox(name string, readOnly bool, conn backend.Conn) (*imap.MailboxStatus, backend.Mailbox, error) {
+	status, mbox, err := u.User.GetMailbox(name, readOnly, conn)
+	if err != nil {
+		return status, mbox, err
+	}
+	sqlMbox, ok := mbox.(*imapsql.Mailbox)
+	if !ok {
+		return status, mbox, nil
+	}
+	qMbox := quotaMailbox{Mailbox: sqlMbox, store: u.store, username: u.Username()}
+	if u.store.fts == nil {
+		return status, qMbox, nil
+	}
+	return status, ftsMailbox{quotaMailbox: qMbox, user: u.User, idx: u.store.fts}, nil
+}
+
+func (u storageUser) DeleteMailbox(name string) error {
+	if err := u.User.DeleteMailbox(name); err != nil {
+		return err
+	}
+	u.store.ftsDropDeleted()
+	return nil
+}
+
+// quotaMailbox enforces the account quota on COPY. MOVE is not checked
+// since moved messages are removed from the source mailbox and the account
+// usage does not change.
+type quotaMailbox struct {
+	*imapsql.Mailbox
+	store    *Storage
+	username string
+}
+
+// copySize returns the total size and the number of the messages in
+// seqset.
+func (m quotaMailbox) copySize(uid bool, seqset *imap.SeqSet) (size, count uint64, err error) {
+	ch := make(chan *imap.Message, 16)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.Mailbox.ListMessages(uid, seqset, []imap.FetchItem{imap.FetchRFC822Size}, ch)
+	}()
+
+	for msg := range ch {
+		size += uint64(msg.Size)
+		count++
+	}
+	return size, count, <-errCh
+}
+
+func (m quotaMailbox) CopyMessages(uid bool, seqset *imap.SeqSet, dest string) error {
+	size, count, err := m.copySize(uid, seqset)
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		if err := m.store.checkQuota(context.TODO(), m.username, size, count); err != nil {
+			return m.store.quotaIMAPError(m.username, err)
+		}
+	}
+	return m.Mailbox.CopyMessages(uid, seqset, dest)
+}
+
+// wrapUser returns the IMAP user that enforces quotas and uses the
+// full-text index.
+func (store *Storage) wrapUser(u backend.User, err error) (backend.User, error) {
+	if err != nil {
+		return u, err
+	}
+	sqlUser, ok := u.(*imapsql.User)
+	if !ok {
+		return u, nil
+	}
+	return storageUser{User: sqlUser, store: store}, nil
+}
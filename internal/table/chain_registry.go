@@ -0,0 +1,286 @@
+package table
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	tls2 "github.com/dsoftgames/MailChat/framework/config/tls"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/chainnode"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+// relaysByDomainPath is the REST gateway route of the x/mailchat
+// Query/RelaysByDomain RPC.
+const relaysByDomainPath = "/dsoftgames/MailChat/mailchat/v1/relays_by_domain/"
+
+// ChainRegistry is a table that resolves mail domains to relays registered
+// in the x/mailchat module of the MailChat chain.
+//
+// Lookup returns the hostname of the first relay serving the domain,
+// LookupMulti returns hostnames of all of them. Full registry entries
+// (including pinned TLS keys) are available via the module.RelayRegistry
+// interface.
+//
+// If the mail server runs inside the chain node process, the keeper is
+// queried directly and api_url is ignored.
+type ChainRegistry struct {
+	modName  string
+	instName string
+	log      log.Logger
+
+	apiURL    string
+	cacheTTL  time.Duration
+	cacheSize int
+	client    *http.Client
+
+	// fetch is used to query the registry on cache miss. It is replaced in
+	// tests.
+	fetch func(ctx context.Context, domain string) ([]module.MailRelay, error)
+
+	// cache keeps the results of recent lookups, cacheLRU orders them from
+	// the most recently used one. Domains are often controlled by remote
+	// senders, so the amount of entries is limited.
+	cacheLck sync.Mutex
+	cache    map[string]*list.Element
+	cacheLRU *list.List
+}
+
+type registryCacheEntry struct {
+	domain  string
+	relays  []module.MailRelay
+	expires time.Time
+}
+
+func NewChainRegistry(modName, instName string, _, inlineArgs []string) (module.Module, error) {
+	r := &ChainRegistry{
+		modName:  modName,
+		instName: instName,
+		log:      log.Logger{Name: modName, Debug: log.DefaultLogger.Debug},
+		apiURL:   "http://127.0.0.1:1317",
+		cache:    map[string]*list.Element{},
+		cacheLRU: list.New(),
+	}
+
+	switch len(inlineArgs) {
+	case 1:
+		r.apiURL = inlineArgs[0]
+	case 0:
+	default:
+		return nil, fmt.Errorf("%s: unexpected amount of inline arguments", modName)
+	}
+
+	return r, nil
+}
+
+func (r *ChainRegistry) Init(cfg *config.Map) error {
+	var (
+		tlsConfig tls.Config
+		timeout   time.Duration
+	)
+	cfg.Bool("debug", true, false, &r.log.Debug)
+	cfg.String("api_url", false, false, r.apiURL, &r.apiURL)
+	cfg.Duration("cache_ttl", false, false, time.Minute, &r.cacheTTL)
+	cfg.Int("cache_size", false, false, 10000, &r.cacheSize)
+	cfg.Duration("timeout", false, false, 10*time.Second, &timeout)
+	cfg.Custom("tls_client", true, false, func() (interface{}, error) {
+		return tls.Config{}, nil
+	}, tls2.TLSClientBlock, &tlsConfig)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	r.apiURL = strings.TrimSuffix(r.apiURL, "/")
+	r.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tlsConfig,
+		},
+	}
+	r.fetch = r.fetchREST
+	if node := chainnode.Local(); node != nil {
+		r.log.Debugln("using in-process chain node")
+		r.fetch = func(ctx context.Context, domain string) ([]module.MailRelay, error) {
+			return r.fetchLocal(ctx, node, domain)
+		}
+	}
+
+	return nil
+}
+
+func (r *ChainRegistry) Name() string {
+	return r.modName
+}
+
+func (r *ChainRegistry) InstanceName() string {
+	return r.instName
+}
+
+func (r *ChainRegistry) fetchREST(ctx context.Context, domain string) ([]module.MailRelay, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.apiURL+relaysByDomainPath+url.PathEscape(domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.modName, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected response status: %s", r.modName, resp.Status)
+	}
+
+	var body struct {
+		Relays []struct {
+			Hostname       string   `json:"hostname"`
+			Operator       string   `json:"operator"`
+			TLSFingerprint string   `json:"tls_fingerprint"`
+			Domains        []string `json:"domains"`
+		} `json:"relays"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s: malformed response: %w", r.modName, err)
+	}
+
+	relays := make([]module.MailRelay, 0, len(body.Relays))
+	for _, rel := range body.Relays {
+		relays = append(relays, module.MailRelay{
+			Hostname:       rel.Hostname,
+			TLSFingerprint: rel.TLSFingerprint,
+			Domains:        rel.Domains,
+			Operator:       rel.Operator,
+		})
+	}
+	return relays, nil
+}
+
+func (r *ChainRegistry) fetchLocal(ctx context.Context, node *chainnode.Node, domain string) ([]module.MailRelay, error) {
+	qctx, err := node.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.modName, err)
+	}
+	resp, err := node.Mailchat.RelaysByDomain(qctx, &types.QueryRelaysByDomainRequest{Domain: domain})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.modName, err)
+	}
+
+	relays := make([]module.MailRelay, 0, len(resp.Relays))
+	for _, rel := range resp.Relays {
+		relays = append(relays, module.MailRelay{
+			Hostname:       rel.Hostname,
+			TLSFingerprint: rel.TlsFingerprint,
+			Domains:        rel.Domains,
+			Operator:       rel.Operator,
+		})
+	}
+	return relays, nil
+}
+
+// LookupRelays implements module.RelayRegistry.
+func (r *ChainRegistry) LookupRelays(ctx context.Context, domain string) ([]module.MailRelay, error) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	if relays, ok := r.cached(domain); ok {
+		return relays, nil
+	}
+
+	relays, err := r.fetch(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	r.log.DebugMsg("registry lookup", "domain", domain, "relays", len(relays))
+
+	r.store(domain, relays)
+
+	return relays, nil
+}
+
+func (r *ChainRegistry) cached(domain string) ([]module.MailRelay, bool) {
+	r.cacheLck.Lock()
+	defer r.cacheLck.Unlock()
+
+	el, ok := r.cache[domain]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(registryCacheEntry)
+	if !time.Now().Before(entry.expires) {
+		r.cacheLRU.Remove(el)
+		delete(r.cache, domain)
+		return nil, false
+	}
+	r.cacheLRU.MoveToFront(el)
+	return entry.relays, true
+}
+
+func (r *ChainRegistry) store(domain string, relays []module.MailRelay) {
+	if r.cacheSize <= 0 {
+		return
+	}
+
+	r.cacheLck.Lock()
+	defer r.cacheLck.Unlock()
+
+	now := time.Now()
+	entry := registryCacheEntry{
+		domain:  domain,
+		relays:  relays,
+		expires: now.Add(r.cacheTTL),
+	}
+	if el, ok := r.cache[domain]; ok {
+		el.Value = entry
+		r.cacheLRU.MoveToFront(el)
+	} else {
+		r.cache[domain] = r.cacheLRU.PushFront(entry)
+	}
+
+	// Drop least recently used entries that are expired or over the limit.
+	for el := r.cacheLRU.Back(); el != nil; el = r.cacheLRU.Back() {
+		old := el.Value.(registryCacheEntry)
+		if r.cacheLRU.Len() <= r.cacheSize && now.Before(old.expires) {
+			break
+		}
+		r.cacheLRU.Remove(el)
+		delete(r.cache, old.domain)
+	}
+}
+
+func (r *ChainRegistry) Lookup(ctx context.Context, domain string) (string, bool, error) {
+	relays, err := r.LookupRelays(ctx, domain)
+	if err != nil {
+		return "", false, err
+	}
+	if len(relays) == 0 {
+		return "", false, nil
+	}
+	return relays[0].Hostname, true, nil
+}
+
+func (r *ChainRegistry) LookupMulti(ctx context.Context, domain string) ([]string, error) {
+	relays, err := r.LookupRelays(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, 0, len(relays))
+	for _, rel := range relays {
+		hosts = append(hosts, rel.Hostname)
+	}
+	return hosts, nil
+}
+
+func init() {
+	module.Register("table.chain_registry", NewChainRegistry)
+}
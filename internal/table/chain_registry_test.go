@@ -0,0 +1,170 @@
+package table
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/chainnode"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func TestChainRegistry(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case relaysByDomainPath + "example.org":
+			w.Write([]byte(`{"relays":[
+				{"hostname":"mx1.example.org","operator":"mc1abc","tls_fingerprint":"aabb","domains":["example.org"]},
+				{"hostname":"mx2.example.org","operator":"mc1abc","tls_fingerprint":"ccdd","domains":["example.org"]}
+			]}`))
+		case relaysByDomainPath + "example.com":
+			w.Write([]byte(`{"relays":[]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	mod, err := NewChainRegistry("table.chain_registry", "", nil, []string{srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mod.(*ChainRegistry)
+	if err := r.Init(config.NewMap(nil, config.Node{})); err != nil {
+		t.Fatal(err)
+	}
+
+	relays, err := r.LookupRelays(context.Background(), "Example.ORG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []module.MailRelay{
+		{Hostname: "mx1.example.org", TLSFingerprint: "aabb", Domains: []string{"example.org"}, Operator: "mc1abc"},
+		{Hostname: "mx2.example.org", TLSFingerprint: "ccdd", Domains: []string{"example.org"}, Operator: "mc1abc"},
+	}
+	if !reflect.DeepEqual(relays, want) {
+		t.Errorf("wrong relays\n want %+v\n got %+v", want, relays)
+	}
+
+	hosts, err := r.LookupMulti(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hosts, []string{"mx1.example.org", "mx2.example.org"}) {
+		t.Errorf("wrong hosts: %v", hosts)
+	}
+	if requests != 1 {
+		t.Errorf("expected cached result to be used, got %d requests", requests)
+	}
+
+	_, ok, err := r.Lookup(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected no relays for example.com")
+	}
+
+	if _, err := r.LookupRelays(context.Background(), "example.net"); err == nil {
+		t.Error("expected error for failed query")
+	}
+}
+
+type localRelayQuerier struct {
+	*types.UnimplementedQueryServer
+}
+
+func (localRelayQuerier) RelaysByDomain(_ context.Context, req *types.QueryRelaysByDomainRequest) (*types.QueryRelaysByDomainResponse, error) {
+	if req.Domain != "example.org" {
+		return &types.QueryRelaysByDomainResponse{}, nil
+	}
+	return &types.QueryRelaysByDomainResponse{Relays: []types.MailRelay{
+		{Hostname: "mx1.example.org", Operator: "mc1abc", TlsFingerprint: "aabb", Domains: []string{"example.org"}},
+	}}, nil
+}
+
+func TestChainRegistry_LocalNode(t *testing.T) {
+	chainnode.Set(&chainnode.Node{
+		Mailchat: localRelayQuerier{},
+		QueryContext: func(ctx context.Context) (context.Context, error) {
+			return ctx, nil
+		},
+	})
+	defer chainnode.Set(nil)
+
+	// api_url is not used if the node runs in the same process.
+	mod, err := NewChainRegistry("table.chain_registry", "", nil, []string{"http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mod.(*ChainRegistry)
+	if err := r.Init(config.NewMap(nil, config.Node{})); err != nil {
+		t.Fatal(err)
+	}
+
+	relays, err := r.LookupRelays(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []module.MailRelay{
+		{Hostname: "mx1.example.org", TLSFingerprint: "aabb", Domains: []string{"example.org"}, Operator: "mc1abc"},
+	}
+	if !reflect.DeepEqual(relays, want) {
+		t.Errorf("wrong relays\n want %+v\n got %+v", want, relays)
+	}
+}
+
+func TestChainRegistry_CacheLimit(t *testing.T) {
+	mod, err := NewChainRegistry("table.chain_registry", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mod.(*ChainRegistry)
+	if err := r.Init(config.NewMap(nil, config.Node{
+		Children: []config.Node{{Name: "cache_size", Args: []string{"2"}}},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	requests := map[string]int{}
+	r.fetch = func(_ context.Context, domain string) ([]module.MailRelay, error) {
+		requests[domain]++
+		return nil, nil
+	}
+
+	for _, domain := range []string{"a.example", "b.example", "a.example", "c.example", "a.example", "b.example"} {
+		if _, err := r.LookupRelays(context.Background(), domain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(r.cache) != 2 || r.cacheLRU.Len() != 2 {
+		t.Fatalf("cache is not limited: %d entries", len(r.cache))
+	}
+	// b.example is evicted as the least recently used one when c.example
+	// is added, a.example stays cached.
+	if !reflect.DeepEqual(requests, map[string]int{"a.example": 1, "b.example": 2, "c.example": 1}) {
+		t.Fatalf("wrong requests: %v", requests)
+	}
+
+	// Expired entries are removed.
+	r.cacheTTL = time.Millisecond
+	for _, domain := range []string{"e.example", "f.example"} {
+		if _, err := r.LookupRelays(context.Background(), domain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	r.cacheTTL = time.Minute
+	if _, err := r.LookupRelays(context.Background(), "d.example"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.cache) != 1 {
+		t.Fatalf("expired entries are kept: %d entries", len(r.cache))
+	}
+}
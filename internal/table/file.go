@@ -101,7 +101,7 @@ func (f *File) Init(cfg *config.Map) error {
 	}
 
 	go f.reloader()
-	hooks.AddHook(hooks.EventReload, func() {
+	hooks.AddOwnedHook(f, hooks.EventReload, func() {
 		f.forceReload <- struct{}{}
 	})
 
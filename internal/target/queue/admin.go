@@ -0,0 +1,698 @@
+package queue
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+)
+
+// The queue accepts management commands from the MailChat queue
+// subcommands over a Unix socket in the runtime directory. Commands are
+// executed by the running server so the delivery schedule kept in memory
+// stays consistent with the spool.
+//
+// The protocol is a sequence of JSON-encoded adminRequest objects, each
+// answered with adminResponse.
+
+var (
+	ErrNoMessage = errors.New("queue: no such message")
+	ErrInFlight  = errors.New("queue: message is being delivered now, try again later")
+)
+
+// MessageInfo describes a message in the queue.
+type MessageInfo struct {
+	ID           string
+	From         string
+	To           []string
+	Size         int64
+	Tries        int
+	FirstAttempt time.Time
+	LastAttempt  time.Time
+	// Zero if the message is held, is being delivered or the server is not
+	// running.
+	NextAttempt time.Time `json:",omitempty"`
+	Held        bool      `json:",omitempty"`
+
+	// Last delivery errors for recipients.
+	RcptErrs map[string]*smtp.SMTPError `json:",omitempty"`
+	// Message header, set only by Show.
+	Header string `json:",omitempty"`
+}
+
+type adminRequest struct {
+	Op     string
+	ID     string `json:",omitempty"`
+	Domain string `json:",omitempty"`
+	Sender string `json:",omitempty"`
+}
+
+type adminResponse struct {
+	Error    string        `json:",omitempty"`
+	Messages []MessageInfo `json:",omitempty"`
+	Count    int           `json:",omitempty"`
+}
+
+// SocketPath returns the path of the management socket of the queue
+// stored at location.
+func SocketPath(location string) string {
+	if abs, err := filepath.Abs(location); err == nil {
+		location = abs
+	}
+	id := sha1.Sum([]byte(location))
+	return filepath.Join(config.RuntimeDirectory, "queue-"+hex.EncodeToString(id[:])+".sock")
+}
+
+// Location returns the spool directory of the queue.
+func (q *Queue) Location() string {
+	return q.location
+}
+
+func (q *Queue) listenAdmin() error {
+	path := SocketPath(q.location)
+	// Left over from the previous run.
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+	// The socket is removed by closeAdmin if it is still ours.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		os.Remove(path)
+		return fmt.Errorf("queue: %w", err)
+	}
+	q.adminSocket, err = os.Stat(path)
+	if err != nil {
+		l.Close()
+		os.Remove(path)
+		return fmt.Errorf("queue: %w", err)
+	}
+	q.adminListener = l
+	q.Log.DebugMsg("listening for management commands", "path", path)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go q.serveAdmin(conn)
+		}
+	}()
+	return nil
+}
+
+func (q *Queue) closeAdmin() {
+	if q.adminListener == nil {
+		return
+	}
+	q.adminListener.Close()
+
+	// The socket might be replaced by another server process using the
+	// same location.
+	path := SocketPath(q.location)
+	if info, err := os.Stat(path); err == nil && os.SameFile(info, q.adminSocket) {
+		os.Remove(path)
+	}
+}
+
+func (q *Queue) serveAdmin(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req adminRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		resp := q.handleAdmin(req)
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (q *Queue) handleAdmin(req adminRequest) adminResponse {
+	var (
+		resp adminResponse
+		err  error
+	)
+	resp.Messages, resp.Count, err = q.Manage(req.Op, req.ID, req.Domain, req.Sender)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// Manage executes the management command. It is used by the queue socket
+// and the admin API.
+//
+// Commands are list, show, retry, hold, release, delete and bounce that
+// take the message ID and purge that takes the recipient domain or the
+// sender. The number of removed messages is returned for purge.
+func (q *Queue) Manage(op, id, domain, sender string) ([]MessageInfo, int, error) {
+	q.adminLock.Lock()
+	defer q.adminLock.Unlock()
+
+	if q.wheel == nil {
+		return nil, 0, errors.New("queue: not running")
+	}
+
+	var (
+		msgs  []MessageInfo
+		count int
+		err   error
+	)
+	switch op {
+	case "list":
+		msgs, err = q.listMessages()
+	case "show":
+		var info MessageInfo
+		info, err = q.ReadMessage(id)
+		if err == nil {
+			msgs = []MessageInfo{info}
+			q.setNextAttempt(msgs)
+		}
+	case "retry":
+		err = q.retryMessage(id)
+	case "hold":
+		err = q.holdMessage(id)
+	case "release":
+		err = q.releaseMessage(id)
+	case "delete":
+		err = q.deleteMessage(id)
+	case "bounce":
+		err = q.bounceMessage(id)
+	case "purge":
+		count, err = q.purge(domain, sender)
+	default:
+		err = fmt.Errorf("queue: unknown command: %s", op)
+	}
+	if err != nil {
+		q.Log.Error("management command failed", err, "op", op, "msg_id", id)
+	} else if op != "list" && op != "show" {
+		q.Log.Msg("management command", "op", op, "msg_id", id,
+			"domain", domain, "sender", sender)
+	}
+	return msgs, count, err
+}
+
+func (q *Queue) listMessages() ([]MessageInfo, error) {
+	msgs, err := q.ReadSpool()
+	if err != nil {
+		return nil, err
+	}
+	q.setNextAttempt(msgs)
+	return msgs, nil
+}
+
+// setNextAttempt sets the next attempt time from the delivery schedule. The
+// SQL spool keeps the schedule with messages so it is already set.
+func (q *Queue) setNextAttempt(msgs []MessageInfo) {
+	if q.spool != nil {
+		return
+	}
+
+	next := make(map[string]time.Time)
+	for _, slot := range q.wheel.Slots() {
+		next[slot.Value.(queueSlot).ID] = slot.Time
+	}
+	for i := range msgs {
+		t, ok := next[msgs[i].ID]
+		if !ok {
+			continue
+		}
+		if t.IsZero() {
+			// New messages are scheduled for immediate delivery.
+			t = time.Now()
+		}
+		msgs[i].NextAttempt = t
+	}
+}
+
+// claim takes the message out of the delivery schedule so it can be
+// modified. The returned slot is nil for held messages, they are not
+// scheduled. Messages that are being delivered cannot be claimed.
+func (q *Queue) claim(id string) (*QueueMetadata, *TimeSlot, error) {
+	if !validID(id) {
+		return nil, nil, ErrNoMessage
+	}
+
+	if q.spool != nil {
+		meta, next, err := q.spool.claim(id)
+		if err != nil || next.IsZero() {
+			return meta, nil, err
+		}
+		return meta, &TimeSlot{Time: next}, nil
+	}
+
+	removed := q.wheel.Remove(func(slot TimeSlot) bool {
+		return slot.Value.(queueSlot).ID == id
+	})
+
+	// Read meta-data after the message is removed from the schedule
+	// so it is not updated by a delivery attempt concurrently.
+	meta, err := q.readMessageMeta(id)
+	if err != nil {
+		if len(removed) != 0 {
+			q.unclaim(id, &removed[0])
+		}
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNoMessage
+		}
+		return nil, nil, err
+	}
+
+	if len(removed) != 0 {
+		return meta, &removed[0], nil
+	}
+	if meta.Held {
+		return meta, nil, nil
+	}
+	return nil, nil, ErrInFlight
+}
+
+// unclaim returns the message claimed using claim to the delivery
+// schedule. If slot is nil, the message is held and is not scheduled.
+func (q *Queue) unclaim(id string, slot *TimeSlot) {
+	if slot == nil {
+		if q.spool != nil {
+			q.spool.releaseLease(id)
+		}
+		return
+	}
+	q.schedule(id, slot.Time)
+}
+
+func (q *Queue) retryMessage(id string) error {
+	// Held messages are not scheduled, so there is no slot to return.
+	meta, _, err := q.claim(id)
+	if err != nil {
+		return err
+	}
+	if meta.Held {
+		q.unclaim(id, nil)
+		return errors.New("queue: message is held, release it instead")
+	}
+	q.unclaim(id, &TimeSlot{Time: time.Now()})
+	return nil
+}
+
+func (q *Queue) holdMessage(id string) error {
+	meta, slot, err := q.claim(id)
+	if err != nil {
+		return err
+	}
+	if meta.Held {
+		q.unclaim(id, nil)
+		return nil
+	}
+	meta.Held = true
+	if err := q.updateMetadata(meta); err != nil {
+		q.unclaim(id, slot)
+		return err
+	}
+	q.unclaim(id, nil)
+	return nil
+}
+
+func (q *Queue) releaseMessage(id string) error {
+	meta, slot, err := q.claim(id)
+	if err != nil {
+		return err
+	}
+	if !meta.Held {
+		q.unclaim(id, slot)
+		return errors.New("queue: message is not held")
+	}
+	meta.Held = false
+	if err := q.updateMetadata(meta); err != nil {
+		q.unclaim(id, nil)
+		return err
+	}
+	q.unclaim(id, &TimeSlot{Time: time.Now()})
+	return nil
+}
+
+func (q *Queue) deleteMessage(id string) error {
+	meta, _, err := q.claim(id)
+	if err != nil {
+		return err
+	}
+	q.removeMessage(meta.MsgMeta)
+	return nil
+}
+
+func (q *Queue) bounceMessage(id string) error {
+	if q.dsnPipeline == nil {
+		return errors.New("queue: bounce {} is not configured")
+	}
+
+	meta, slot, err := q.claim(id)
+	if err != nil {
+		return err
+	}
+	_, header, _, err := q.openMessage(id)
+	if err != nil {
+		q.unclaim(id, slot)
+		return err
+	}
+
+	if meta.RcptErrs == nil {
+		meta.RcptErrs = make(map[string]*smtp.SMTPError)
+	}
+	for _, rcpt := range meta.To {
+		meta.RcptErrs[rcpt] = &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 0, 0},
+			Message:      "Delivery cancelled by the server administrator",
+		}
+	}
+	q.emitDSN(meta, header, meta.To)
+	q.removeMessage(meta.MsgMeta)
+	return nil
+}
+
+// purge removes recipients at the domain and messages from the sender.
+// Messages without recipients left are removed from the queue. It returns
+// the number of removed messages.
+func (q *Queue) purge(domain, sender string) (int, error) {
+	if domain == "" && sender == "" {
+		return 0, errors.New("queue: domain or sender is required")
+	}
+
+	msgs, err := q.ReadSpool()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, info := range msgs {
+		if !matchSender(info.From, sender) && !anyRcptAt(info.To, domain) {
+			continue
+		}
+
+		meta, slot, err := q.claim(info.ID)
+		if err != nil {
+			if errors.Is(err, ErrInFlight) {
+				q.Log.Msg("skipping message being delivered", "msg_id", info.ID)
+				continue
+			}
+			if errors.Is(err, ErrNoMessage) {
+				continue
+			}
+			return removed, err
+		}
+
+		if !matchSender(meta.From, sender) {
+			meta.To = filterRcpts(meta.To, domain)
+		} else {
+			meta.To = nil
+		}
+		if len(meta.To) == 0 {
+			q.removeMessage(meta.MsgMeta)
+			removed++
+			continue
+		}
+
+		err = q.updateMetadata(meta)
+		q.unclaim(info.ID, slot)
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func matchSender(from, sender string) bool {
+	return sender != "" && strings.EqualFold(from, sender)
+}
+
+func rcptAt(rcpt, domain string) bool {
+	_, rcptDomain, err := address.Split(rcpt)
+	return err == nil && strings.EqualFold(rcptDomain, domain)
+}
+
+func anyRcptAt(rcpts []string, domain string) bool {
+	if domain == "" {
+		return false
+	}
+	for _, rcpt := range rcpts {
+		if rcptAt(rcpt, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func filterRcpts(rcpts []string, domain string) []string {
+	if domain == "" {
+		return rcpts
+	}
+	res := rcpts[:0]
+	for _, rcpt := range rcpts {
+		if !rcptAt(rcpt, domain) {
+			res = append(res, rcpt)
+		}
+	}
+	return res
+}
+
+// validID reports whether id can be a message ID, it is used as a part
+// of file names.
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && id != "." && id != ".."
+}
+
+// ReadSpool reads information about all queued messages. It does not
+// require the server to be running.
+func (q *Queue) ReadSpool() ([]MessageInfo, error) {
+	if q.spool != nil {
+		return q.spool.messages()
+	}
+	return ReadSpool(q.location)
+}
+
+// ReadMessage reads information about the message including its header.
+// It does not require the server to be running.
+func (q *Queue) ReadMessage(id string) (MessageInfo, error) {
+	if q.spool == nil {
+		return ReadMessage(q.location, id)
+	}
+
+	info, err := q.spool.message(id)
+	if err != nil {
+		return MessageInfo{}, err
+	}
+	hdr, err := q.spool.readHeader(id)
+	if err != nil {
+		return MessageInfo{}, err
+	}
+	var b strings.Builder
+	if err := textproto.WriteHeader(&b, hdr); err != nil {
+		return MessageInfo{}, err
+	}
+	info.Header = b.String()
+	return info, nil
+}
+
+// ReadSpool reads information about all messages stored at the queue
+// location. It does not require the server to be running.
+func ReadSpool(location string) ([]MessageInfo, error) {
+	entries, err := os.ReadDir(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []MessageInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".meta") {
+			continue
+		}
+		info, err := readMessageInfo(location, strings.TrimSuffix(entry.Name(), ".meta"))
+		if err != nil {
+			// Removed concurrently or broken, readDiskQueue reports these.
+			continue
+		}
+		msgs = append(msgs, info)
+	}
+
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].FirstAttempt.Before(msgs[j].FirstAttempt)
+	})
+	return msgs, nil
+}
+
+// ReadMessage reads information about the message including its header.
+// It does not require the server to be running.
+func ReadMessage(location, id string) (MessageInfo, error) {
+	if !validID(id) {
+		return MessageInfo{}, ErrNoMessage
+	}
+	info, err := readMessageInfo(location, id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return MessageInfo{}, ErrNoMessage
+		}
+		return MessageInfo{}, err
+	}
+
+	f, err := os.Open(filepath.Join(location, id+".header"))
+	if err != nil {
+		return MessageInfo{}, err
+	}
+	defer f.Close()
+	hdr, err := textproto.ReadHeader(bufio.NewReader(f))
+	if err != nil {
+		return MessageInfo{}, err
+	}
+	var b strings.Builder
+	if err := textproto.WriteHeader(&b, hdr); err != nil {
+		return MessageInfo{}, err
+	}
+	info.Header = b.String()
+
+	return info, nil
+}
+
+func readMessageInfo(location, id string) (MessageInfo, error) {
+	q := Queue{location: location}
+	meta, err := q.readMessageMeta(id)
+	if err != nil {
+		return MessageInfo{}, err
+	}
+	info := newMessageInfo(id, meta)
+	if stat, err := os.Stat(filepath.Join(location, id+".body")); err == nil {
+		info.Size = stat.Size()
+	}
+	return info, nil
+}
+
+func newMessageInfo(id string, meta *QueueMetadata) MessageInfo {
+	info := MessageInfo{
+		ID:           id,
+		From:         meta.From,
+		To:           meta.To,
+		FirstAttempt: meta.FirstAttempt,
+		LastAttempt:  meta.LastAttempt,
+		Held:         meta.Held,
+		RcptErrs:     meta.RcptErrs,
+	}
+	for _, count := range meta.TriesCount {
+		if count > info.Tries {
+			info.Tries = count
+		}
+	}
+	return info
+}
+
+// AdminClient sends management commands to the queue of the running
+// server.
+type AdminClient struct {
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+// DialAdmin connects to the queue stored at location. It fails if the
+// server is not running.
+func DialAdmin(location string) (*AdminClient, error) {
+	conn, err := net.Dial("unix", SocketPath(location))
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClient{
+		conn: conn,
+		dec:  json.NewDecoder(bufio.NewReader(conn)),
+		enc:  json.NewEncoder(conn),
+	}, nil
+}
+
+func (c *AdminClient) do(req adminRequest) (adminResponse, error) {
+	if err := c.enc.Encode(req); err != nil {
+		return adminResponse{}, err
+	}
+	var resp adminResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return adminResponse{}, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func (c *AdminClient) List() ([]MessageInfo, error) {
+	resp, err := c.do(adminRequest{Op: "list"})
+	return resp.Messages, err
+}
+
+func (c *AdminClient) Show(id string) (MessageInfo, error) {
+	resp, err := c.do(adminRequest{Op: "show", ID: id})
+	if err != nil {
+		return MessageInfo{}, err
+	}
+	if len(resp.Messages) != 1 {
+		return MessageInfo{}, ErrNoMessage
+	}
+	return resp.Messages[0], nil
+}
+
+// Retry schedules the message for immediate delivery.
+func (c *AdminClient) Retry(id string) error {
+	_, err := c.do(adminRequest{Op: "retry", ID: id})
+	return err
+}
+
+// Hold stops delivery attempts for the message until Release is called.
+func (c *AdminClient) Hold(id string) error {
+	_, err := c.do(adminRequest{Op: "hold", ID: id})
+	return err
+}
+
+// Release schedules the held message for immediate delivery.
+func (c *AdminClient) Release(id string) error {
+	_, err := c.do(adminRequest{Op: "release", ID: id})
+	return err
+}
+
+// Delete removes the message from the queue without notifying the sender.
+func (c *AdminClient) Delete(id string) error {
+	_, err := c.do(adminRequest{Op: "delete", ID: id})
+	return err
+}
+
+// Bounce removes the message from the queue and sends the failure DSN to
+// the sender.
+func (c *AdminClient) Bounce(id string) error {
+	_, err := c.do(adminRequest{Op: "bounce", ID: id})
+	return err
+}
+
+// Purge removes recipients at domain and messages from sender. It returns
+// the number of messages removed.
+func (c *AdminClient) Purge(domain, sender string) (int, error) {
+	resp, err := c.do(adminRequest{Op: "purge", Domain: domain, Sender: sender})
+	return resp.Count, err
+}
+
+func (c *AdminClient) Close() error {
+	return c.conn.Close()
+}
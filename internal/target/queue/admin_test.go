@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+// newDeferredQueue returns the queue with a message that failed the first
+// delivery attempt and is scheduled for the next one in an hour.
+func newDeferredQueue(t *testing.T, dt *unreliableTarget, to []string) (*Queue, string) {
+	t.Helper()
+
+	dt.bodyFailures = []error{exterrors.WithTemporary(errors.New("you shall not pass"), true)}
+	dt.aborted = make(chan testutils.Msg, 10)
+	dt.committed = make(chan testutils.Msg, 10)
+	q := newTestQueue(t, dt)
+	q.initialRetryTime = time.Hour
+	t.Cleanup(func() { cleanQueue(t, q) })
+
+	id := testutils.DoTestDelivery(t, q, "tester@example.com", to)
+	readMsgChanTimeout(t, dt.aborted, 5*time.Second)
+
+	// Wait for the message to be rescheduled.
+	for i := 0; len(q.wheel.Slots()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("message is not rescheduled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return q, id
+}
+
+func TestQueueAdmin_HoldRelease(t *testing.T) {
+	config.RuntimeDirectory = t.TempDir()
+
+	dt := unreliableTarget{}
+	q, id := newDeferredQueue(t, &dt, []string{"tester1@example.org"})
+	if err := q.listenAdmin(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := DialAdmin(q.location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	msgs, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != id || msgs[0].Tries != 1 || msgs[0].NextAttempt.Before(time.Now().Add(30*time.Minute)) {
+		t.Fatalf("wrong list: %+v", msgs)
+	}
+
+	if info, err := c.Show(id); err != nil || info.NextAttempt.IsZero() {
+		t.Fatalf("wrong message info: %+v (%v)", info, err)
+	}
+
+	if err := c.Hold(id); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.wheel.Slots()) != 0 {
+		t.Fatal("held message is still scheduled")
+	}
+	info, err := c.Show(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Held || !info.NextAttempt.IsZero() || info.Header == "" {
+		t.Fatalf("wrong message info: %+v", info)
+	}
+	if err := c.Retry(id); err == nil {
+		t.Fatal("retry for held message succeeded")
+	}
+	if err := c.Hold("nonexistent"); err == nil {
+		t.Fatal("hold for nonexistent message succeeded")
+	}
+
+	if err := c.Release(id); err != nil {
+		t.Fatal(err)
+	}
+	msg := readMsgChanTimeout(t, dt.committed, 5*time.Second)
+	testutils.CheckMsgID(t, msg, "tester@example.com", []string{"tester1@example.org"}, "")
+}
+
+func TestQueueAdmin_PurgeDelete(t *testing.T) {
+	dt := unreliableTarget{}
+	q, id := newDeferredQueue(t, &dt, []string{"tester1@example.org", "tester2@example.com"})
+
+	if resp := q.handleAdmin(adminRequest{Op: "purge", Domain: "EXAMPLE.COM"}); resp.Error != "" || resp.Count != 0 {
+		t.Fatalf("purge failed: %+v", resp)
+	}
+	info, err := ReadMessage(q.location, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.To, []string{"tester1@example.org"}) {
+		t.Fatalf("wrong recipients after purge: %v", info.To)
+	}
+	if len(q.wheel.Slots()) != 1 {
+		t.Fatal("message is not scheduled after purge")
+	}
+
+	if resp := q.handleAdmin(adminRequest{Op: "delete", ID: id}); resp.Error != "" {
+		t.Fatalf("delete failed: %+v", resp)
+	}
+	checkQueueDir(t, q, []string{})
+	if len(q.wheel.Slots()) != 0 {
+		t.Fatal("deleted message is still scheduled")
+	}
+}
+
+func TestQueueAdmin_SocketReplaced(t *testing.T) {
+	config.RuntimeDirectory = t.TempDir()
+
+	dt := unreliableTarget{}
+	q1 := newTestQueue(t, &dt)
+	if err := q1.listenAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	q2 := newTestQueueDir(t, &dt, q1.location)
+	defer cleanQueue(t, q2)
+	if err := q2.listenAdmin(); err != nil {
+		t.Fatal(err)
+	}
+
+	// q1 should not remove the socket of q2.
+	cleanQueue(t, q1)
+	c, err := DialAdmin(q2.location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.List(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueue_CheckExclusive(t *testing.T) {
+	dt := unreliableTarget{}
+	q1 := newTestQueue(t, &dt)
+	defer cleanQueue(t, q1)
+
+	// q1 is defined inline in global directives.
+	reg := module.NewRegistry()
+	reg.AddInline(reg, q1)
+	globals := reg.BuildGlobals(nil, reg)
+
+	mod, _ := NewQueue("", "queue2", nil, nil)
+	q2 := mod.(*Queue)
+	q2.location = q1.location
+	if err := module.CheckExclusive(globals, q2); err == nil {
+		t.Error("expected an error for the same location")
+	}
+
+	q2.location = t.TempDir()
+	if err := module.CheckExclusive(globals, q2); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -29,6 +29,10 @@ Implementation summary follows.
 All scheduled deliveries are attempted to the configured DeliveryTarget.
 All metadata is preserved on disk.
 
+Alternatively, the queue can be kept in the SQL database with message bodies
+in the blob store, see sqlspool.go. Then multiple server instances share the
+queue and the delivery schedule is kept in the database too.
+
 Failure status is determined on per-recipient basis:
   - Delivery.Start fail handled as a failure for all recipients.
   - Delivery.AddRcpt fail handled as a failure for the corresponding recipient.
@@ -64,6 +68,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -153,6 +158,19 @@ type Queue struct {
 	// Buffered channel used to restrict count of deliveries attempted
 	// in parallel.
 	deliverySemaphore chan struct{}
+
+	// Serializes queue management operations, see admin.go.
+	adminLock     sync.Mutex
+	adminListener net.Listener
+	// adminSocket is the socket file created by listenAdmin.
+	adminSocket os.FileInfo
+
+	// If not nil, messages are stored in the SQL database instead of the
+	// location directory. The wheel is used only to dispatch messages
+	// claimed for immediate delivery then.
+	spool    *sqlSpool
+	pollStop chan struct{}
+	pollDone chan struct{}
 }
 
 type QueueMetadata struct {
@@ -176,6 +194,10 @@ type QueueMetadata struct {
 
 	FirstAttempt time.Time
 	LastAttempt  time.Time
+
+	// Held messages are not scheduled for delivery until released using
+	// the queue management commands.
+	Held bool `json:",omitempty"`
 }
 
 type queueSlot struct {
@@ -219,6 +241,7 @@ func (q *Queue) Init(cfg *config.Map) error {
 	cfg.Custom("bounce", false, false, nil, func(m *config.Map, node config.Node) (interface{}, error) {
 		return msgpipeline.New(m.Globals, node.Children)
 	}, &q.dsnPipeline)
+	cfg.Custom("spool", false, false, nil, spoolDirective, &q.spool)
 	if _, err := cfg.Process(); err != nil {
 		return err
 	}
@@ -243,14 +266,30 @@ func (q *Queue) Init(cfg *config.Map) error {
 		return err
 	}
 
-	return q.start(maxParallelism)
+	if err := module.CheckExclusive(cfg.Globals, q); err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+
+	if module.NoRun {
+		return nil
+	}
+
+	if err := q.start(maxParallelism); err != nil {
+		return err
+	}
+	return q.listenAdmin()
 }
 
 func (q *Queue) start(maxParallelism int) error {
 	q.wheel = NewTimeWheel(q.dispatch)
 	q.deliverySemaphore = make(chan struct{}, maxParallelism)
 
-	if err := q.readDiskQueue(); err != nil {
+	if q.spool != nil {
+		q.spool.start(q.Log)
+		q.pollStop = make(chan struct{})
+		q.pollDone = make(chan struct{})
+		go q.pollSpool()
+	} else if err := q.readDiskQueue(); err != nil {
 		return err
 	}
 
@@ -259,10 +298,42 @@ func (q *Queue) start(maxParallelism int) error {
 	return nil
 }
 
+// CheckHealth verifies that the spool directory or database is still
+// accessible.
+func (q *Queue) CheckHealth(ctx context.Context) error {
+	if q.spool != nil {
+		return q.spool.db.PingContext(ctx)
+	}
+	_, err := os.Stat(q.location)
+	return err
+}
+
+// ExclusiveResource implements module.Exclusive. Two instances using the
+// same spool directory would deliver the messages twice.
+func (q *Queue) ExclusiveResource() string {
+	location := q.location
+	if abs, err := filepath.Abs(location); err == nil {
+		location = abs
+	}
+	return "spool directory " + location
+}
+
 func (q *Queue) Close() error {
+	if q.wheel == nil {
+		// Not started, see module.NoRun.
+		return nil
+	}
+	if q.spool != nil {
+		close(q.pollStop)
+		<-q.pollDone
+	}
+	q.closeAdmin()
 	q.wheel.Close()
 	q.deliveryWg.Wait()
 
+	if q.spool != nil {
+		return q.spool.Close()
+	}
 	return nil
 }
 
@@ -272,8 +343,17 @@ func (q *Queue) Close() error {
 // Further attempts to deliver (due to a timewheel) it will fail due to
 // non-existent meta-data file.
 //
+// The SQL spool has no files, the message is held instead.
+//
 // No error handling is done since this function is called from panic handler.
 func (q *Queue) discardBroken(id string) {
+	if q.spool != nil {
+		if err := q.spool.holdBroken(id); err != nil {
+			log.Printf("can't mark the queue message as broken: %v", err)
+		}
+		return
+	}
+
 	err := os.Rename(filepath.Join(q.location, id+".meta"), filepath.Join(q.location, id+".meta_broken"))
 	if err != nil {
 		// Note: Global logger is used in case there is something wrong with Queue.Log.
@@ -316,6 +396,12 @@ func (q *Queue) dispatch(value TimeSlot) {
 			meta, hdr, body, err = q.openMessage(slot.ID)
 			if err != nil {
 				q.Log.Error("read message", err, slot.ID)
+				// Otherwise the lease is renewed while the instance is
+				// running and no one retries the message. It is already
+				// removed if blobs are missing.
+				if q.spool != nil && !errors.Is(err, module.ErrNoSuchBlob) {
+					q.spool.releaseLease(slot.ID)
+				}
 				return
 			}
 			if meta == nil {
@@ -425,14 +511,14 @@ func (q *Queue) tryDelivery(meta *QueueMetadata, header textproto.Header, body b
 	}
 	// No recipients to try, either all failed or all succeeded.
 	if len(newRcpts) == 0 {
-		q.removeFromDisk(meta.MsgMeta)
+		q.removeMessage(meta.MsgMeta)
 		return
 	}
 
 	meta.To = newRcpts
 	meta.LastAttempt = time.Now()
 
-	if err := q.updateMetadataOnDisk(meta); err != nil {
+	if err := q.updateMetadata(meta); err != nil {
 		dl.Error("meta-data update", err)
 	}
 
@@ -447,15 +533,22 @@ func (q *Queue) tryDelivery(meta *QueueMetadata, header textproto.Header, body b
 		"next_try_delay", time.Until(nextTryTime),
 		"rcpts", meta.To)
 
-	q.wheel.Add(nextTryTime, queueSlot{
-		ID: meta.MsgMeta.ID,
+	// Do not keep (meta-)data in memory to reduce usage. At this point,
+	// it is safe on disk and next try will reread it.
+	q.schedule(meta.MsgMeta.ID, nextTryTime)
+}
 
-		// Do not keep (meta-)data in memory to reduce usage.  At this point,
-		// it is safe on disk and next try will reread it.
-		Meta: nil,
-		Hdr:  nil,
-		Body: nil,
-	})
+// schedule adds the message stored in the spool to the delivery schedule.
+// For the SQL spool, the lease on the message is released so any instance
+// can deliver it.
+func (q *Queue) schedule(id string, t time.Time) {
+	if q.spool != nil {
+		if err := q.spool.schedule(id, t); err != nil {
+			q.Log.Error("failed to schedule message", err, "msg_id", id)
+		}
+		return
+	}
+	q.wheel.Add(t, queueSlot{ID: id})
 }
 
 func (q *Queue) deliver(meta *QueueMetadata, header textproto.Header, body buffer.Buffer) partialError {
@@ -582,7 +675,7 @@ func (qd *queueDelivery) Abort(ctx context.Context) error {
 	defer trace.StartRegion(ctx, "queue/Abort").End()
 
 	if qd.body != nil {
-		qd.q.removeFromDisk(qd.meta.MsgMeta)
+		qd.q.removeMessage(qd.meta.MsgMeta)
 	}
 	return nil
 }
@@ -616,6 +709,16 @@ func (q *Queue) Start(ctx context.Context, msgMeta *module.MsgMetadata, mailFrom
 	return &queueDelivery{q: q, meta: meta}, nil
 }
 
+// removeMessage removes the message from the spool.
+func (q *Queue) removeMessage(msgMeta *module.MsgMetadata) {
+	if q.spool != nil {
+		q.spool.removeMessage(msgMeta.ID)
+		target.DeliveryLogger(q.Log, msgMeta).Debugf("removed message from spool")
+		return
+	}
+	q.removeFromDisk(msgMeta)
+}
+
 func (q *Queue) removeFromDisk(msgMeta *module.MsgMetadata) {
 	id := msgMeta.ID
 	dl := target.DeliveryLogger(q.Log, msgMeta)
@@ -685,6 +788,11 @@ func (q *Queue) readDiskQueue() error {
 			continue
 		}
 
+		if meta.Held {
+			q.Log.Debugf("not scheduling held message (msg ID = %s)", id)
+			continue
+		}
+
 		smallestTriesCount := 999999
 		for _, count := range meta.TriesCount {
 			if smallestTriesCount > count {
@@ -714,6 +822,10 @@ func (q *Queue) readDiskQueue() error {
 }
 
 func (q *Queue) storeNewMessage(meta *QueueMetadata, header textproto.Header, body buffer.Buffer) (buffer.Buffer, error) {
+	if q.spool != nil {
+		return q.spool.store(meta, header, body)
+	}
+
 	id := meta.MsgMeta.ID
 
 	headerPath := filepath.Join(q.location, id+".header")
@@ -765,6 +877,15 @@ func (q *Queue) storeNewMessage(meta *QueueMetadata, header textproto.Header, bo
 	return buffer.FileBuffer{Path: bodyPath, LenHint: body.Len()}, nil
 }
 
+// updateMetadata saves the meta-data of the message claimed for delivery or
+// modification.
+func (q *Queue) updateMetadata(meta *QueueMetadata) error {
+	if q.spool != nil {
+		return q.spool.updateMeta(meta)
+	}
+	return q.updateMetadataOnDisk(meta)
+}
+
 func (q *Queue) updateMetadataOnDisk(meta *QueueMetadata) error {
 	metaPath := filepath.Join(q.location, meta.MsgMeta.ID+".meta")
 
@@ -842,6 +963,10 @@ func (q *Queue) tryRemoveDanglingFile(name string) {
 }
 
 func (q *Queue) openMessage(id string) (*QueueMetadata, textproto.Header, buffer.Buffer, error) {
+	if q.spool != nil {
+		return q.spool.open(id)
+	}
+
 	meta, err := q.readMessageMeta(id)
 	if err != nil {
 		return nil, textproto.Header{}, nil, err
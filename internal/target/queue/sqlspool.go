@@ -0,0 +1,611 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/emersion/go-message/textproto"
+	_ "github.com/lib/pq"
+)
+
+// sqlSpool keeps the queue in the SQL database that can be shared by
+// multiple server instances. Meta-data is stored in the table, message
+// header and body are stored in the blob store.
+//
+// A message is delivered or modified only by the instance holding the lease
+// on its row. Leases are renewed while the instance is running, so messages
+// claimed by a crashed instance are picked up by others once their leases
+// expire.
+//
+// Supported drivers are postgres and sqlite3. Timestamps are Unix time in
+// milliseconds.
+type sqlSpool struct {
+	db    *sql.DB
+	blobs module.BlobStore
+	log   log.Logger
+
+	// owner identifies leases taken by this instance.
+	owner        string
+	lease        time.Duration
+	pollInterval time.Duration
+
+	insert     *sql.Stmt
+	claimDue   *sql.Stmt
+	claimID    *sql.Stmt
+	renew      *sql.Stmt
+	update     *sql.Stmt
+	reschedule *sql.Stmt
+	release    *sql.Stmt
+	releaseAll *sql.Stmt
+	hold       *sql.Stmt
+	remove     *sql.Stmt
+	list       *sql.Stmt
+	get        *sql.Stmt
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// spoolDirective reads the spool block:
+//
+//	spool sql {
+//	    driver postgres
+//	    dsn "host=db.example.org dbname=mailchat"
+//	    msg_store fs /var/lib/mailchat/queue
+//	}
+//
+// The returned value is *sqlSpool.
+func spoolDirective(m *config.Map, node config.Node) (interface{}, error) {
+	if len(node.Args) != 1 || node.Args[0] != "sql" {
+		return nil, config.NodeErr(node, "spool type is required: sql")
+	}
+
+	var (
+		driver       string
+		dsnParts     []string
+		tableName    string
+		blobs        module.BlobStore
+		lease        time.Duration
+		pollInterval time.Duration
+	)
+	cfg := config.NewMap(m.Globals, node)
+	cfg.String("driver", false, true, "", &driver)
+	cfg.StringList("dsn", false, true, nil, &dsnParts)
+	cfg.String("table_name", false, false, "queue", &tableName)
+	cfg.Custom("msg_store", false, true, nil, func(m *config.Map, node config.Node) (interface{}, error) {
+		var store module.BlobStore
+		err := modconfig.ModuleFromNode("storage.blob", node.Args,
+			node, m.Globals, &store)
+		return store, err
+	}, &blobs)
+	cfg.Duration("lease", false, false, 5*time.Minute, &lease)
+	cfg.Duration("poll_interval", false, false, 15*time.Second, &pollInterval)
+	if _, err := cfg.Process(); err != nil {
+		return nil, err
+	}
+
+	s, err := newSQLSpool(driver, strings.Join(dsnParts, " "), tableName, blobs)
+	if err != nil {
+		return nil, config.NodeErr(node, "%v", err)
+	}
+	s.lease = lease
+	s.pollInterval = pollInterval
+	return s, nil
+}
+
+func newSQLSpool(driver, dsn, table string, blobs module.BlobStore) (*sqlSpool, error) {
+	if driver != "postgres" && driver != "sqlite3" {
+		return nil, fmt.Errorf("queue: unsupported SQL driver: %s", driver)
+	}
+
+	owner, err := leaseOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// Avoid "database is locked" errors from concurrent writes.
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &sqlSpool{
+		db:           db,
+		blobs:        blobs,
+		owner:        owner,
+		lease:        5 * time.Minute,
+		pollInterval: 15 * time.Second,
+		stop:         make(chan struct{}),
+	}
+	if err := s.prepare(driver, table); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// leaseOwner returns the identifier unique for the spool instance. The host
+// name is included to simplify debugging.
+func leaseOwner() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	rnd := make([]byte, 8)
+	if _, err := rand.Read(rnd); err != nil {
+		return "", err
+	}
+	return hostname + "/" + hex.EncodeToString(rnd), nil
+}
+
+func (s *sqlSpool) prepare(driver, table string) error {
+	_, err := s.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id TEXT PRIMARY KEY NOT NULL,
+		meta TEXT NOT NULL,
+		body_size BIGINT NOT NULL,
+		first_attempt BIGINT NOT NULL,
+		next_attempt BIGINT NOT NULL,
+		held INTEGER NOT NULL DEFAULT 0,
+		lease_owner TEXT NOT NULL DEFAULT '',
+		lease_until BIGINT NOT NULL DEFAULT 0
+	)`, table))
+	if err != nil {
+		return fmt.Errorf("queue: create table: %w", err)
+	}
+	_, err = s.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_next_attempt ON %[1]s(next_attempt)`, table))
+	if err != nil {
+		return fmt.Errorf("queue: create index: %w", err)
+	}
+
+	// Rows locked by other instances are skipped instead of waiting for
+	// them. SQLite serializes writes so it does not need that.
+	skipLocked := ""
+	if driver == "postgres" {
+		skipLocked = " FOR UPDATE SKIP LOCKED"
+	}
+
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.insert, `INSERT INTO %[1]s(id, meta, body_size, first_attempt, next_attempt, lease_owner, lease_until)
+			VALUES($1, $2, $3, $4, $4, $5, $6)`},
+		// Expired leases are taken over, these are messages of crashed
+		// instances.
+		{&s.claimDue, `UPDATE %[1]s SET lease_owner = $1, lease_until = $2
+			WHERE id IN (SELECT id FROM %[1]s
+				WHERE held = 0 AND next_attempt <= $3 AND lease_until <= $3
+				ORDER BY next_attempt LIMIT $4` + skipLocked + `)
+			RETURNING id`},
+		{&s.claimID, `UPDATE %[1]s SET lease_owner = $1, lease_until = $2
+			WHERE id = $3 AND lease_until <= $4
+			RETURNING meta, held, next_attempt`},
+		{&s.renew, `UPDATE %[1]s SET lease_until = $1 WHERE lease_owner = $2`},
+		{&s.update, `UPDATE %[1]s SET meta = $1, held = $2 WHERE id = $3 AND lease_owner = $4`},
+		{&s.reschedule, `UPDATE %[1]s SET next_attempt = $1, lease_owner = '', lease_until = 0
+			WHERE id = $2 AND lease_owner = $3`},
+		{&s.release, `UPDATE %[1]s SET lease_owner = '', lease_until = 0 WHERE id = $1 AND lease_owner = $2`},
+		{&s.releaseAll, `UPDATE %[1]s SET lease_owner = '', lease_until = 0 WHERE lease_owner = $1`},
+		{&s.hold, `UPDATE %[1]s SET held = 1, lease_owner = '', lease_until = 0 WHERE id = $1 AND lease_owner = $2`},
+		{&s.remove, `DELETE FROM %[1]s WHERE id = $1 AND lease_owner = $2`},
+		{&s.list, `SELECT id, meta, body_size, next_attempt, held, lease_until FROM %[1]s ORDER BY first_attempt`},
+		{&s.get, `SELECT id, meta, body_size, next_attempt, held, lease_until FROM %[1]s WHERE id = $1`},
+	}
+	for _, q := range queries {
+		query := fmt.Sprintf(q.query, table)
+		if driver == "sqlite3" {
+			// SQLite uses ?NNN for numbered parameters.
+			query = strings.ReplaceAll(query, "$", "?")
+		}
+		stmt, err := s.db.Prepare(query)
+		if err != nil {
+			return fmt.Errorf("queue: prepare query: %w", err)
+		}
+		*q.stmt = stmt
+	}
+	return nil
+}
+
+// start begins renewal of the leases held by this instance.
+func (s *sqlSpool) start(log log.Logger) {
+	s.log = log
+	s.done.Add(1)
+	go s.renewLoop()
+}
+
+func (s *sqlSpool) renewLoop() {
+	defer s.done.Done()
+
+	t := time.NewTicker(s.lease / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			if _, err := s.renew.Exec(time.Now().Add(s.lease).UnixMilli(), s.owner); err != nil {
+				s.log.Error("failed to renew leases", err)
+			}
+		}
+	}
+}
+
+// Close releases all leases held by this instance, they should not be in use
+// anymore.
+func (s *sqlSpool) Close() error {
+	close(s.stop)
+	s.done.Wait()
+
+	if _, err := s.releaseAll.Exec(s.owner); err != nil {
+		s.log.Error("failed to release leases", err)
+	}
+	return s.db.Close()
+}
+
+func headerKey(id string) string {
+	return id + ".header"
+}
+
+func bodyKey(id string) string {
+	return id + ".body"
+}
+
+func (s *sqlSpool) putBlob(key string, size int64, r io.Reader) error {
+	blob, err := s.blobs.Create(context.Background(), key, size)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	if _, err := io.Copy(blob, r); err != nil {
+		return err
+	}
+	return blob.Sync()
+}
+
+// store saves the new message. It is leased by this instance and due for
+// delivery immediately.
+func (s *sqlSpool) store(meta *QueueMetadata, header textproto.Header, body buffer.Buffer) (buffer.Buffer, error) {
+	id := meta.MsgMeta.ID
+
+	var hdrBlob bytes.Buffer
+	if err := textproto.WriteHeader(&hdrBlob, header); err != nil {
+		return nil, err
+	}
+	if err := s.putBlob(headerKey(id), int64(hdrBlob.Len()), &hdrBlob); err != nil {
+		return nil, err
+	}
+
+	bodyReader, err := body.Open()
+	if err != nil {
+		s.deleteBlobs(id)
+		return nil, err
+	}
+	defer bodyReader.Close()
+	if err := s.putBlob(bodyKey(id), int64(body.Len()), bodyReader); err != nil {
+		s.deleteBlobs(id)
+		return nil, err
+	}
+
+	metaBlob, err := marshalMeta(meta)
+	if err != nil {
+		s.deleteBlobs(id)
+		return nil, err
+	}
+	now := time.Now()
+	_, err = s.insert.Exec(id, metaBlob, body.Len(), now.UnixMilli(), s.owner, now.Add(s.lease).UnixMilli())
+	if err != nil {
+		s.deleteBlobs(id)
+		return nil, err
+	}
+
+	return blobBuffer{store: s.blobs, key: bodyKey(id), size: body.Len()}, nil
+}
+
+func marshalMeta(meta *QueueMetadata) (string, error) {
+	metaCopy := *meta
+	metaCopy.MsgMeta = meta.MsgMeta.DeepCopy()
+	metaCopy.MsgMeta.Conn = nil
+
+	blob, err := json.Marshal(metaCopy)
+	return string(blob), err
+}
+
+func unmarshalMeta(blob string, held bool) (*QueueMetadata, error) {
+	meta := &QueueMetadata{MsgMeta: &module.MsgMetadata{}}
+	if err := json.Unmarshal([]byte(blob), meta); err != nil {
+		return nil, err
+	}
+	// The column is authoritative, broken messages are held without
+	// updating the meta-data.
+	meta.Held = held
+	return meta, nil
+}
+
+func (s *sqlSpool) deleteBlobs(id string) {
+	if err := s.blobs.Delete(context.Background(), []string{headerKey(id), bodyKey(id)}); err != nil {
+		s.log.Error("failed to remove message blobs", err, "msg_id", id)
+	}
+}
+
+// claimDueMessages leases at most limit messages due for delivery and
+// returns their IDs.
+func (s *sqlSpool) claimDueMessages(limit int) ([]string, error) {
+	now := time.Now()
+	rows, err := s.claimDue.Query(s.owner, now.Add(s.lease).UnixMilli(), now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// claim leases the message so it can be modified. The next attempt time is
+// returned for messages that are not held.
+func (s *sqlSpool) claim(id string) (*QueueMetadata, time.Time, error) {
+	now := time.Now()
+	var (
+		metaBlob    string
+		held        bool
+		nextAttempt int64
+	)
+	err := s.claimID.QueryRow(s.owner, now.Add(s.lease).UnixMilli(), id, now.UnixMilli()).
+		Scan(&metaBlob, &held, &nextAttempt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, time.Time{}, err
+		}
+		// Either there is no such message or it is leased by someone.
+		if _, err := s.message(id); err != nil {
+			return nil, time.Time{}, err
+		}
+		return nil, time.Time{}, ErrInFlight
+	}
+
+	meta, err := unmarshalMeta(metaBlob, held)
+	if err != nil {
+		s.releaseLease(id)
+		return nil, time.Time{}, err
+	}
+	if held {
+		return meta, time.Time{}, nil
+	}
+	return meta, time.UnixMilli(nextAttempt), nil
+}
+
+// open reads the leased message. The message is removed if its blobs are
+// missing.
+func (s *sqlSpool) open(id string) (*QueueMetadata, textproto.Header, buffer.Buffer, error) {
+	var (
+		metaBlob    string
+		bodySize    int
+		nextAttempt int64
+		held        bool
+		leaseUntil  int64
+	)
+	err := s.get.QueryRow(id).Scan(&id, &metaBlob, &bodySize, &nextAttempt, &held, &leaseUntil)
+	if err != nil {
+		return nil, textproto.Header{}, nil, err
+	}
+	meta, err := unmarshalMeta(metaBlob, held)
+	if err != nil {
+		return nil, textproto.Header{}, nil, err
+	}
+
+	header, err := s.readHeader(id)
+	if err != nil {
+		if errors.Is(err, module.ErrNoSuchBlob) {
+			s.log.Msg("message blobs are missing, removing it", "msg_id", id)
+			s.removeMessage(id)
+		}
+		return nil, textproto.Header{}, nil, err
+	}
+
+	return meta, header, blobBuffer{store: s.blobs, key: bodyKey(id), size: bodySize}, nil
+}
+
+func (s *sqlSpool) readHeader(id string) (textproto.Header, error) {
+	r, err := s.blobs.Open(context.Background(), headerKey(id))
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	defer r.Close()
+	return textproto.ReadHeader(bufio.NewReader(r))
+}
+
+// checkLease reports whether the statement affected the row, it does not
+// if the lease was lost.
+func checkLease(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("queue: lease on the message is lost")
+	}
+	return nil
+}
+
+// updateMeta saves the meta-data of the leased message.
+func (s *sqlSpool) updateMeta(meta *QueueMetadata) error {
+	metaBlob, err := marshalMeta(meta)
+	if err != nil {
+		return err
+	}
+	held := 0
+	if meta.Held {
+		held = 1
+	}
+	return checkLease(s.update.Exec(metaBlob, held, meta.MsgMeta.ID, s.owner))
+}
+
+// schedule releases the lease on the message making it due for delivery at
+// t by any instance.
+func (s *sqlSpool) schedule(id string, t time.Time) error {
+	return checkLease(s.reschedule.Exec(t.UnixMilli(), id, s.owner))
+}
+
+// releaseLease releases the lease on the message without changing its
+// schedule.
+func (s *sqlSpool) releaseLease(id string) {
+	if err := checkLease(s.release.Exec(id, s.owner)); err != nil {
+		s.log.Error("failed to release lease", err, "msg_id", id)
+	}
+}
+
+// holdBroken holds the leased message so it is not delivered until the
+// administrator looks at it.
+func (s *sqlSpool) holdBroken(id string) error {
+	return checkLease(s.hold.Exec(id, s.owner))
+}
+
+// removeMessage removes the leased message. Blobs are kept if the lease is
+// lost, the message is in use by another instance then.
+func (s *sqlSpool) removeMessage(id string) {
+	if err := checkLease(s.remove.Exec(id, s.owner)); err != nil {
+		s.log.Error("failed to remove message", err, "msg_id", id)
+		return
+	}
+	s.deleteBlobs(id)
+}
+
+func (s *sqlSpool) scanInfo(row interface{ Scan(...interface{}) error }) (MessageInfo, error) {
+	var (
+		id          string
+		metaBlob    string
+		bodySize    int64
+		nextAttempt int64
+		held        bool
+		leaseUntil  int64
+	)
+	if err := row.Scan(&id, &metaBlob, &bodySize, &nextAttempt, &held, &leaseUntil); err != nil {
+		return MessageInfo{}, err
+	}
+	meta, err := unmarshalMeta(metaBlob, held)
+	if err != nil {
+		return MessageInfo{}, err
+	}
+
+	info := newMessageInfo(id, meta)
+	info.Size = bodySize
+	// Leased messages are being delivered or modified.
+	if !held && leaseUntil <= time.Now().UnixMilli() {
+		info.NextAttempt = time.UnixMilli(nextAttempt)
+	}
+	return info, nil
+}
+
+// messages returns information about all messages in the spool.
+func (s *sqlSpool) messages() ([]MessageInfo, error) {
+	rows, err := s.list.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []MessageInfo
+	for rows.Next() {
+		info, err := s.scanInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, info)
+	}
+	return msgs, rows.Err()
+}
+
+// message returns information about the message including its header.
+func (s *sqlSpool) message(id string) (MessageInfo, error) {
+	info, err := s.scanInfo(s.get.QueryRow(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MessageInfo{}, ErrNoMessage
+		}
+		return MessageInfo{}, err
+	}
+	return info, nil
+}
+
+// blobBuffer is the buffer.Buffer reading the message body from the blob
+// store.
+type blobBuffer struct {
+	store module.BlobStore
+	key   string
+	size  int
+}
+
+func (b blobBuffer) Open() (io.ReadCloser, error) {
+	return b.store.Open(context.Background(), b.key)
+}
+
+func (b blobBuffer) Len() int {
+	return b.size
+}
+
+func (b blobBuffer) Remove() error {
+	return b.store.Delete(context.Background(), []string{b.key})
+}
+
+// pollSpool claims messages due for delivery including those left by
+// crashed instances.
+func (q *Queue) pollSpool() {
+	defer close(q.pollDone)
+
+	timer := time.NewTimer(q.postInitDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-q.pollStop:
+			return
+		case <-timer.C:
+		}
+
+		// Messages are not claimed if they can't be delivered now, other
+		// instances can take them. Deliveries waiting for the semaphore are
+		// not accounted, so this is an estimate.
+		if free := cap(q.deliverySemaphore) - len(q.deliverySemaphore); free > 0 {
+			ids, err := q.spool.claimDueMessages(free)
+			if err != nil {
+				q.Log.Error("failed to claim messages", err)
+			}
+			for _, id := range ids {
+				q.Log.Debugf("claimed message for delivery (msg ID = %s)", id)
+				q.wheel.Add(time.Time{}, queueSlot{ID: id})
+			}
+		}
+
+		timer.Reset(q.spool.pollInterval)
+	}
+}
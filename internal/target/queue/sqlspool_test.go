@@ -0,0 +1,235 @@
+//go:build !nosqlite3 && cgo
+// +build !nosqlite3,cgo
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/storage/blob/fs"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+// newTestSpool returns the SQL spool stored in dir. Spools created for the
+// same dir share the queue like different server instances.
+func newTestSpool(t *testing.T, dir string) *sqlSpool {
+	t.Helper()
+
+	blobDir := filepath.Join(dir, "blobs")
+	if err := os.MkdirAll(blobDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := fs.New("storage.blob.fs", "", nil, []string{blobDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSQLSpool("sqlite3", filepath.Join(dir, "queue.db")+"?_busy_timeout=5000",
+		"queue", blobs.(module.BlobStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.pollInterval = 10 * time.Millisecond
+	s.log = log.Logger{Out: log.NopOutput{}}
+	return s
+}
+
+func newTestSQLQueue(t *testing.T, target module.DeliveryTarget, s *sqlSpool) *Queue {
+	mod, _ := NewQueue("", "queue", nil, nil)
+	q := mod.(*Queue)
+	q.initialRetryTime = 0
+	q.retryTimeScale = 1
+	q.postInitDelay = 0
+	q.maxTries = 5
+	q.location = t.TempDir()
+	q.Target = target
+	q.spool = s
+	if testing.Verbose() {
+		q.Log = testutils.Logger(t, "queue")
+	} else {
+		q.Log = log.Logger{Out: log.NopOutput{}}
+	}
+	if err := q.start(1); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// checkSpool checks the spool stored in dir after the queue using it is
+// closed.
+func checkSpool(t *testing.T, dir string, expectedIDs []string) {
+	t.Helper()
+
+	s := newTestSpool(t, dir)
+	defer s.db.Close()
+	msgs, err := s.messages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != len(expectedIDs) {
+		t.Fatalf("expected %d messages in the spool, got %+v", len(expectedIDs), msgs)
+	}
+	for i, id := range expectedIDs {
+		if msgs[i].ID != id {
+			t.Errorf("expected message %s, got %s", id, msgs[i].ID)
+		}
+	}
+
+	if len(expectedIDs) == 0 {
+		blobs, err := os.ReadDir(filepath.Join(dir, "blobs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blobs) != 0 {
+			t.Errorf("message blobs are not removed: %v", blobs)
+		}
+	}
+}
+
+func TestSQLSpool_TemporaryFail(t *testing.T) {
+	t.Parallel()
+
+	dt := unreliableTarget{
+		bodyFailuresPartial: []map[string]error{
+			{
+				"tester2@example.org": exterrors.WithTemporary(errors.New("go away"), true),
+			},
+		},
+		committed: make(chan testutils.Msg, 10),
+	}
+	dir := t.TempDir()
+	q := newTestSQLQueue(t, &dt, newTestSpool(t, dir))
+
+	testutils.DoTestDelivery(t, q, "tester@example.com", []string{"tester1@example.org", "tester2@example.org"})
+
+	msg := readMsgChanTimeout(t, dt.committed, 5*time.Second)
+	testutils.CheckMsgID(t, msg, "tester@example.com", []string{"tester1@example.org", "tester2@example.org"}, "")
+
+	// The retry is claimed from the database by the poller.
+	msg = readMsgChanTimeout(t, dt.committed, 5*time.Second)
+	testutils.CheckMsgID(t, msg, "tester@example.com", []string{"tester2@example.org"}, "")
+
+	cleanQueue(t, q)
+	checkSpool(t, dir, []string{})
+}
+
+func TestSQLSpool_Reclaim(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	dt := unreliableTarget{committed: make(chan testutils.Msg, 10)}
+
+	// The message is stored by the instance that crashes before delivering
+	// it.
+	crashed := newTestSpool(t, dir)
+	crashed.lease = time.Second
+	defer crashed.db.Close()
+	crashedQ := &Queue{spool: crashed, Log: crashed.log}
+	id := testutils.DoTestDelivery(t, &abortlessQueue{crashedQ}, "tester@example.com", []string{"tester1@example.org"})
+
+	q := newTestSQLQueue(t, &dt, newTestSpool(t, dir))
+	defer cleanQueue(t, q)
+
+	// Not claimed while the lease is valid.
+	select {
+	case <-dt.committed:
+		t.Fatal("message delivered while leased by another instance")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if resp := q.handleAdmin(adminRequest{Op: "hold", ID: id}); resp.Error != ErrInFlight.Error() {
+		t.Fatalf("hold for leased message: %+v", resp)
+	}
+
+	msg := readMsgChanTimeout(t, dt.committed, 5*time.Second)
+	testutils.CheckMsgID(t, msg, "tester@example.com", []string{"tester1@example.org"}, "")
+}
+
+// abortlessQueue stores the message but does not deliver it on Commit.
+type abortlessQueue struct {
+	*Queue
+}
+
+func (aq *abortlessQueue) Start(ctx context.Context, msgMeta *module.MsgMetadata, mailFrom string) (module.Delivery, error) {
+	d, err := aq.Queue.Start(ctx, msgMeta, mailFrom)
+	if err != nil {
+		return nil, err
+	}
+	return &abortlessDelivery{d.(*queueDelivery)}, nil
+}
+
+type abortlessDelivery struct {
+	*queueDelivery
+}
+
+func (d *abortlessDelivery) Commit(ctx context.Context) error {
+	return nil
+}
+
+func TestSQLSpool_HoldRelease(t *testing.T) {
+	t.Parallel()
+
+	dt := unreliableTarget{
+		bodyFailures: []error{exterrors.WithTemporary(errors.New("you shall not pass"), true)},
+		aborted:      make(chan testutils.Msg, 10),
+		committed:    make(chan testutils.Msg, 10),
+	}
+	q := newTestSQLQueue(t, &dt, newTestSpool(t, t.TempDir()))
+	q.initialRetryTime = time.Hour
+	defer cleanQueue(t, q)
+
+	id := testutils.DoTestDelivery(t, q, "tester@example.com", []string{"tester1@example.org"})
+	readMsgChanTimeout(t, dt.aborted, 5*time.Second)
+
+	// Wait for the message to be rescheduled.
+	var info MessageInfo
+	for i := 0; info.NextAttempt.IsZero(); i++ {
+		if i == 100 {
+			t.Fatal("message is not rescheduled")
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var err error
+		info, err = q.ReadMessage(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if info.Tries != 1 || info.NextAttempt.Before(time.Now().Add(30*time.Minute)) || info.Header == "" {
+		t.Fatalf("wrong message info: %+v", info)
+	}
+
+	if resp := q.handleAdmin(adminRequest{Op: "hold", ID: id}); resp.Error != "" {
+		t.Fatalf("hold failed: %+v", resp)
+	}
+	info, err := q.ReadMessage(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Held || !info.NextAttempt.IsZero() {
+		t.Fatalf("wrong message info: %+v", info)
+	}
+
+	if resp := q.handleAdmin(adminRequest{Op: "release", ID: id}); resp.Error != "" {
+		t.Fatalf("release failed: %+v", resp)
+	}
+	msg := readMsgChanTimeout(t, dt.committed, 5*time.Second)
+	testutils.CheckMsgID(t, msg, "tester@example.com", []string{"tester1@example.org"}, "")
+
+	// Wait for the delivered message to be removed.
+	for i := 0; ; i++ {
+		if _, err := q.ReadMessage(id); errors.Is(err, ErrNoMessage) {
+			break
+		}
+		if i == 100 {
+			t.Fatal("delivered message is not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
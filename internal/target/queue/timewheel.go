@@ -70,6 +70,36 @@ func (tw *TimeWheel) Add(target time.Time, value interface{}) {
 	tw.updateNotify <- target
 }
 
+// Remove removes all slots matching the predicate and returns them. Removed
+// slots are never dispatched.
+func (tw *TimeWheel) Remove(match func(TimeSlot) bool) []TimeSlot {
+	tw.slotsLock.Lock()
+	defer tw.slotsLock.Unlock()
+
+	var removed []TimeSlot
+	for e := tw.slots.Front(); e != nil; {
+		next := e.Next()
+		if slot := e.Value.(TimeSlot); match(slot) {
+			tw.slots.Remove(e)
+			removed = append(removed, slot)
+		}
+		e = next
+	}
+	return removed
+}
+
+// Slots returns the copy of all scheduled slots.
+func (tw *TimeWheel) Slots() []TimeSlot {
+	tw.slotsLock.Lock()
+	defer tw.slotsLock.Unlock()
+
+	slots := make([]TimeSlot, 0, tw.slots.Len())
+	for e := tw.slots.Front(); e != nil; e = e.Next() {
+		slots = append(slots, e.Value.(TimeSlot))
+	}
+	return slots
+}
+
 func (tw *TimeWheel) Close() {
 	atomic.StoreUint32(&tw.stopped, 1)
 
@@ -101,7 +131,8 @@ func (tw *TimeWheel) tick() {
 			}
 		}
 		tw.slotsLock.Unlock()
-		// Only this goroutine removes elements from TimeWheel so we can be safe using closestSlot.
+		// Elements can be removed by Remove concurrently, so closestEl is
+		// checked again before dispatching.
 
 		// Queue is empty. Just wait until update.
 		if closestEl == nil {
@@ -121,10 +152,21 @@ func (tw *TimeWheel) tick() {
 			select {
 			case <-timer.C:
 				tw.slotsLock.Lock()
-				tw.slots.Remove(closestEl)
+				present := false
+				for e := tw.slots.Front(); e != nil; e = e.Next() {
+					if e == closestEl {
+						present = true
+						break
+					}
+				}
+				if present {
+					tw.slots.Remove(closestEl)
+				}
 				tw.slotsLock.Unlock()
 
-				tw.dispatch(closestSlot)
+				if present {
+					tw.dispatch(closestSlot)
+				}
 
 				break selectloop
 			case newTarget := <-tw.updateNotify:
@@ -161,6 +161,7 @@ func (rd *remoteDelivery) attemptMX(ctx context.Context, conn *mxConn, record *n
 	for _, p := range rd.policies {
 		policyLevel, err := p.CheckMX(connCtx, mxLevel, conn.domain, record.Host, conn.dnssecOk)
 		if err != nil {
+			rd.reportTLSSession(connCtx, conn, record.Host, nil, nil)
 			return err
 		}
 		if policyLevel > mxLevel {
@@ -184,6 +185,7 @@ func (rd *remoteDelivery) attemptMX(ctx context.Context, conn *mxConn, record *n
 	for _, p := range rd.policies {
 		policyLevel, err := p.CheckConn(connCtx, mxLevel, tlsLevel, conn.domain, record.Host, tlsState)
 		if err != nil {
+			rd.reportTLSSession(connCtx, conn, record.Host, &tlsState, tlsErr)
 			conn.Close()
 			return exterrors.WithFields(err, map[string]interface{}{"tls_err": tlsErr})
 		}
@@ -192,6 +194,8 @@ func (rd *remoteDelivery) attemptMX(ctx context.Context, conn *mxConn, record *n
 		}
 	}
 
+	rd.reportTLSSession(connCtx, conn, record.Host, &tlsState, tlsErr)
+
 	conn.mxLevel = mxLevel
 	conn.tlsLevel = tlsLevel
 
@@ -355,12 +359,45 @@ func (rd *remoteDelivery) newConn(ctx context.Context, domain string) (*mxConn,
 	return &conn, nil
 }
 
+// discoverMX returns next hops supplied by policies implementing
+// module.DeliveryMXDiscovery, if any.
+func (rd *remoteDelivery) discoverMX(ctx context.Context, domain string) ([]*net.MX, error) {
+	for _, p := range rd.policies {
+		discovery, ok := p.(module.DeliveryMXDiscovery)
+		if !ok {
+			continue
+		}
+		discovered, err := discovery.DiscoverMX(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		if len(discovered) != 0 {
+			rd.Log.DebugMsg("using discovered MXs", "domain", domain, "count", len(discovered))
+			return discovered, nil
+		}
+	}
+	return nil, nil
+}
+
 func (rd *remoteDelivery) lookupMX(ctx context.Context, domain string) (dnssecOk bool, records []*net.MX, err error) {
 	if rd.rt.extResolver != nil {
 		dnssecOk, records, err = rd.rt.extResolver.AuthLookupMX(context.Background(), domain)
 	} else {
 		records, err = rd.rt.resolver.LookupMX(ctx, dns.FQDN(domain))
 	}
+
+	// Discovered next hops are used only if the domain has no MX records so
+	// they can't override the ones published by the domain owner.
+	if (err == nil && len(records) == 0) || dns.IsNotFound(err) {
+		discovered, discoverErr := rd.discoverMX(ctx, domain)
+		if discoverErr != nil {
+			return false, nil, discoverErr
+		}
+		if len(discovered) != 0 {
+			return false, discovered, nil
+		}
+	}
+
 	if err != nil {
 		reason, misc := exterrors.UnwrapDNSErr(err)
 		return false, nil, &exterrors.SMTPError{
@@ -73,6 +73,7 @@ func (pg *PolicyGroup) Init(cfg *config.Map) error {
 		"sts_preload",
 		"dane",
 		"dnssec",
+		"relay_registry",
 		// localPolicy should be the last one, since it considers levels defined by
 		// other policies.
 		"local_policy",
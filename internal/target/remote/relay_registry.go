@@ -0,0 +1,292 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/exterrors"
+	"github.com/dsoftgames/MailChat/framework/future"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/target"
+)
+
+type (
+	// relayRegistryPolicy resolves next hops using a module.RelayRegistry
+	// (e.g. table.chain_registry) for domains without DNS MX records and pins
+	// the TLS key of the relay to the one published in the registry.
+	//
+	// Anyone can register a relay for any domain, so registry entries are
+	// used only if the domain authorizes the relay operator in DNS, see
+	// relayProofPrefix. The MX is considered authenticated by the registry
+	// (module.MX_REGISTRY) only if the authorization is DNSSEC-signed.
+	relayRegistryPolicy struct {
+		registry    module.RelayRegistry
+		resolver    dns.Resolver
+		extResolver *dns.ExtResolver
+		log         log.Logger
+		instName    string
+	}
+	relayRegistryDelivery struct {
+		c         *relayRegistryPolicy
+		relaysFut *future.Future
+		log       log.Logger
+	}
+
+	// registryRelays are the relays authorized by the domain.
+	registryRelays struct {
+		relays []module.MailRelay
+		// authenticated is true if the authorization is DNSSEC-signed.
+		authenticated bool
+	}
+)
+
+func NewRelayRegistryPolicy(_, instName string, _, _ []string) (module.Module, error) {
+	return &relayRegistryPolicy{
+		instName: instName,
+		resolver: dns.DefaultResolver(),
+		log:      log.Logger{Name: "mx_auth.relay_registry", Debug: log.DefaultLogger.Debug},
+	}, nil
+}
+
+func (c *relayRegistryPolicy) Name() string {
+	return "mx_auth.relay_registry"
+}
+
+func (c *relayRegistryPolicy) InstanceName() string {
+	return c.instName
+}
+
+func (c *relayRegistryPolicy) Weight() int {
+	return 20
+}
+
+func (c *relayRegistryPolicy) Init(cfg *config.Map) error {
+	var err error
+	c.extResolver, err = dns.NewExtResolver()
+	if err != nil {
+		c.log.Error("registry relays are not authenticated: unable to init EDNS resolver", err)
+	}
+
+	cfg.Bool("debug", true, log.DefaultLogger.Debug, &c.log.Debug)
+	cfg.Custom("registry", false, true, nil, func(m *config.Map, node config.Node) (interface{}, error) {
+		var registry module.RelayRegistry
+		if err := modconfig.ModuleFromNode("table", node.Args, node, m.Globals, &registry); err != nil {
+			return nil, err
+		}
+		return registry, nil
+	}, &c.registry)
+	_, err = cfg.Process()
+	return err
+}
+
+// relayProofPrefix is the prefix of the TXT record the domain owner
+// publishes to authorize relay operators:
+//
+//	_mailchat-relay.example.org. TXT "v=MCRELAY1; operator=mailchat1..."
+//
+// Multiple records or operator fields can be used to authorize several
+// operators.
+const relayProofPrefix = "_mailchat-relay."
+
+// authorizedOperators returns the operators the domain authorizes to relay
+// its mail and whether the authorization is DNSSEC-signed.
+func (c *relayRegistryPolicy) authorizedOperators(ctx context.Context, domain string) (map[string]bool, bool, error) {
+	var (
+		ad   bool
+		txts []string
+		err  error
+	)
+	if c.extResolver != nil {
+		ad, txts, err = c.extResolver.AuthLookupTXT(ctx, relayProofPrefix+domain)
+	} else {
+		txts, err = c.resolver.LookupTXT(ctx, dns.FQDN(relayProofPrefix+domain))
+	}
+	if err != nil {
+		if dns.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	operators := make(map[string]bool)
+	for _, txt := range txts {
+		fields := strings.Split(txt, ";")
+		if strings.TrimSpace(fields[0]) != "v=MCRELAY1" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if ok && strings.TrimSpace(key) == "operator" {
+				operators[strings.TrimSpace(value)] = true
+			}
+		}
+	}
+	return operators, ad, nil
+}
+
+// lookupRelays returns registry entries for the domain with operators
+// authorized by the domain.
+func (c *relayRegistryPolicy) lookupRelays(ctx context.Context, domain string) (registryRelays, error) {
+	relays, err := c.registry.LookupRelays(ctx, domain)
+	if err != nil || len(relays) == 0 {
+		return registryRelays{}, err
+	}
+
+	operators, ad, err := c.authorizedOperators(ctx, domain)
+	if err != nil {
+		return registryRelays{}, err
+	}
+	authorized := make([]module.MailRelay, 0, len(relays))
+	for _, relay := range relays {
+		if relay.Operator == "" || !operators[relay.Operator] {
+			c.log.Msg("ignoring relay not authorized by the domain", "relay", relay.Hostname,
+				"operator", relay.Operator, "domain", domain)
+			continue
+		}
+		authorized = append(authorized, relay)
+	}
+	return registryRelays{relays: authorized, authenticated: ad}, nil
+}
+
+func (c *relayRegistryPolicy) Start(msgMeta *module.MsgMetadata) module.DeliveryMXAuthPolicy {
+	return &relayRegistryDelivery{
+		c:   c,
+		log: target.DeliveryLogger(c.log, msgMeta),
+	}
+}
+
+func (c *relayRegistryPolicy) Close() error {
+	return nil
+}
+
+func (c *relayRegistryDelivery) PrepareDomain(ctx context.Context, domain string) {
+	c.relaysFut = future.New()
+	go func() {
+		c.relaysFut.Set(c.c.lookupRelays(ctx, domain))
+	}()
+}
+
+func (c *relayRegistryDelivery) PrepareConn(ctx context.Context, mx string) {}
+
+func (c *relayRegistryDelivery) relays(ctx context.Context) (registryRelays, error) {
+	if c.relaysFut == nil {
+		return registryRelays{}, nil
+	}
+	relaysI, err := c.relaysFut.GetContext(ctx)
+	if err != nil {
+		return registryRelays{}, err
+	}
+	return relaysI.(registryRelays), nil
+}
+
+// relayFor returns the registry entry matching the MX hostname, if any, and
+// whether the domain authorization of the relay is DNSSEC-signed.
+func (c *relayRegistryDelivery) relayFor(ctx context.Context, mx string) (module.MailRelay, bool, bool) {
+	relays, err := c.relays(ctx)
+	if err != nil {
+		return module.MailRelay{}, false, false
+	}
+	mx = strings.ToLower(strings.TrimSuffix(mx, "."))
+	for _, relay := range relays.relays {
+		if strings.ToLower(strings.TrimSuffix(relay.Hostname, ".")) == mx {
+			return relay, relays.authenticated, true
+		}
+	}
+	return module.MailRelay{}, false, false
+}
+
+func (c *relayRegistryDelivery) DiscoverMX(ctx context.Context, domain string) ([]*net.MX, error) {
+	relays, err := c.relays(ctx)
+	if err != nil {
+		// Registry lookup errors are considered temporary so messages are
+		// not bounced while the chain node is unreachable.
+		return nil, &exterrors.SMTPError{
+			Code:         451,
+			EnhancedCode: exterrors.EnhancedCode{4, 4, 3},
+			Message:      "Relay registry lookup failed",
+			TargetName:   "remote",
+			Err:          err,
+			Misc: map[string]interface{}{
+				"domain": domain,
+			},
+		}
+	}
+
+	records := make([]*net.MX, 0, len(relays.relays))
+	for i, relay := range relays.relays {
+		records = append(records, &net.MX{
+			Host: dns.FQDN(relay.Hostname),
+			Pref: uint16(i),
+		})
+	}
+	return records, nil
+}
+
+func (c *relayRegistryDelivery) CheckMX(ctx context.Context, mxLevel module.MXLevel, domain, mx string, dnssec bool) (module.MXLevel, error) {
+	_, authenticated, ok := c.relayFor(ctx, mx)
+	if !ok {
+		return module.MXNone, nil
+	}
+	if !authenticated {
+		// Authorization that is not DNSSEC-signed can be spoofed, so it
+		// does not satisfy min_mx_level.
+		c.log.DebugMsg("relay authorization is not DNSSEC-signed", "remote_server", mx, "domain", domain)
+		return module.MXNone, nil
+	}
+	return module.MX_REGISTRY, nil
+}
+
+func (c *relayRegistryDelivery) CheckConn(ctx context.Context, mxLevel module.MXLevel, tlsLevel module.TLSLevel, domain, mx string, tlsState tls.ConnectionState) (module.TLSLevel, error) {
+	relay, _, ok := c.relayFor(ctx, mx)
+	if !ok || relay.TLSFingerprint == "" {
+		return module.TLSNone, nil
+	}
+
+	if !tlsState.HandshakeComplete || len(tlsState.PeerCertificates) == 0 {
+		return module.TLSNone, &exterrors.SMTPError{
+			Code:         451,
+			EnhancedCode: exterrors.EnhancedCode{4, 7, 1},
+			Message:      "TLS is required but unavailable or failed (relay registry)",
+			TargetName:   "remote",
+			Misc: map[string]interface{}{
+				"remote_server": mx,
+			},
+		}
+	}
+
+	spkiHash := sha256.Sum256(tlsState.PeerCertificates[0].RawSubjectPublicKeyInfo)
+	if !strings.EqualFold(hex.EncodeToString(spkiHash[:]), relay.TLSFingerprint) {
+		c.log.Msg("relay TLS key does not match the registry", "remote_server", mx, "domain", domain)
+		return module.TLSNone, &exterrors.SMTPError{
+			Code:         451,
+			EnhancedCode: exterrors.EnhancedCode{4, 7, 5},
+			Message:      "Relay TLS key does not match the registry",
+			TargetName:   "remote",
+			Misc: map[string]interface{}{
+				"remote_server": mx,
+			},
+		}
+	}
+
+	// Key is pinned by the registry entry, PKIX verification is not necessary.
+	return module.TLSAuthenticated, nil
+}
+
+func (c *relayRegistryDelivery) Reset(msgMeta *module.MsgMetadata) {
+	c.relaysFut = nil
+	if msgMeta != nil {
+		c.log = target.DeliveryLogger(c.c.log, msgMeta)
+	}
+}
+
+func init() {
+	module.Register("mx_auth.relay_registry", NewRelayRegistryPolicy)
+}
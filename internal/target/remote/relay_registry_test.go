@@ -0,0 +1,333 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	"github.com/foxcpp/go-mockdns"
+)
+
+type staticRegistry map[string][]module.MailRelay
+
+func (r staticRegistry) LookupRelays(_ context.Context, domain string) ([]module.MailRelay, error) {
+	return r[domain], nil
+}
+
+func testRelayRegistryPolicy(t *testing.T, zones map[string]mockdns.Zone, registry module.RelayRegistry) *relayRegistryPolicy {
+	return &relayRegistryPolicy{
+		registry: registry,
+		resolver: &mockdns.Resolver{Zones: zones},
+		log:      testutils.Logger(t, "remote/relay_registry"),
+	}
+}
+
+// testRelayRegistryPolicyAD is testRelayRegistryPolicy that looks up the
+// relay authorization using the EDNS resolver so that zones with AD set
+// are considered DNSSEC-signed.
+func testRelayRegistryPolicyAD(t *testing.T, zones map[string]mockdns.Zone, registry module.RelayRegistry) (*mockdns.Server, *relayRegistryPolicy) {
+	dnsSrv, err := mockdns.NewServerWithLogger(zones, testutils.Logger(t, "mockdns"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := dnsSrv.LocalAddr().(*net.UDPAddr)
+
+	extResolver, err := dns.NewExtResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	extResolver.Cfg.Servers = []string{addr.IP.String()}
+	extResolver.Cfg.Port = strconv.Itoa(addr.Port)
+
+	c := testRelayRegistryPolicy(t, zones, registry)
+	c.extResolver = extResolver
+	return dnsSrv, c
+}
+
+const testRelayOperator = "mailchat1operator"
+
+// testRelayProof is the zone authorizing testRelayOperator to relay mail
+// for example.invalid.
+var testRelayProof = mockdns.Zone{
+	TXT: []string{"v=MCRELAY1; operator=" + testRelayOperator},
+}
+
+// SHA-256 hash of the testutils server certificate SubjectPublicKeyInfo.
+const testServerSPKIHash = "a9b5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf"
+
+func TestRemoteDelivery_RelayRegistry(t *testing.T) {
+	_, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	// No MX records, next hop comes from the registry only.
+	zones := map[string]mockdns.Zone{
+		"mx.chain.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": {
+			AD:  true,
+			TXT: testRelayProof.TXT,
+		},
+	}
+
+	dnsSrv, policy := testRelayRegistryPolicyAD(t, zones, staticRegistry{
+		"example.invalid": {{
+			Hostname:       "mx.chain.invalid",
+			TLSFingerprint: testServerSPKIHash,
+			Domains:        []string{"example.invalid"},
+			Operator:       testRelayOperator,
+		}},
+	})
+	defer dnsSrv.Close()
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		policy,
+		&localPolicy{
+			// Both are established by the registry, the server certificate is
+			// not trusted by PKIX.
+			minTLSLevel: module.TLSAuthenticated,
+			minMXLevel:  module.MX_REGISTRY,
+		},
+	})
+	defer tgt.Close()
+
+	testutils.DoTestDelivery(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	be.CheckMsg(t, 0, "test@example.com", []string{"test@example.invalid"})
+}
+
+func TestRemoteDelivery_RelayRegistry_MinMXDNSSEC(t *testing.T) {
+	_, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	zones := map[string]mockdns.Zone{
+		"mx.chain.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": {
+			AD:  true,
+			TXT: testRelayProof.TXT,
+		},
+	}
+
+	dnsSrv, policy := testRelayRegistryPolicyAD(t, zones, staticRegistry{
+		"example.invalid": {{
+			Hostname:       "mx.chain.invalid",
+			TLSFingerprint: testServerSPKIHash,
+			Domains:        []string{"example.invalid"},
+			Operator:       testRelayOperator,
+		}},
+	})
+	defer dnsSrv.Close()
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		policy,
+		&localPolicy{minMXLevel: module.MX_DNSSEC},
+	})
+	defer tgt.Close()
+
+	testutils.DoTestDelivery(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	be.CheckMsg(t, 0, "test@example.com", []string{"test@example.invalid"})
+}
+
+func TestRemoteDelivery_RelayRegistry_MinMXDNSSEC_Unsigned(t *testing.T) {
+	_, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	// The authorization is not DNSSEC-signed and could be spoofed, so the
+	// relay does not satisfy min_mx_level dnssec.
+	zones := map[string]mockdns.Zone{
+		"mx.chain.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": testRelayProof,
+	}
+
+	dnsSrv, policy := testRelayRegistryPolicyAD(t, zones, staticRegistry{
+		"example.invalid": {{
+			Hostname:       "mx.chain.invalid",
+			TLSFingerprint: testServerSPKIHash,
+			Domains:        []string{"example.invalid"},
+			Operator:       testRelayOperator,
+		}},
+	})
+	defer dnsSrv.Close()
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		policy,
+		&localPolicy{minMXLevel: module.MX_DNSSEC},
+	})
+	defer tgt.Close()
+
+	_, err := testutils.DoTestDeliveryErr(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	if err == nil {
+		t.Error("Expected an error, got none")
+	}
+	if be.MailFromCounter != 0 {
+		t.Fatal("MAIL FROM issued but should not")
+	}
+}
+
+func TestRemoteDelivery_RelayRegistry_KeyMismatch(t *testing.T) {
+	_, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	zones := map[string]mockdns.Zone{
+		"mx.chain.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": testRelayProof,
+	}
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		testRelayRegistryPolicy(t, zones, staticRegistry{
+			"example.invalid": {{
+				Hostname:       "mx.chain.invalid",
+				TLSFingerprint: "ffb5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf",
+				Domains:        []string{"example.invalid"},
+				Operator:       testRelayOperator,
+			}},
+		}),
+	})
+	defer tgt.Close()
+
+	_, err := testutils.DoTestDeliveryErr(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	if err == nil {
+		t.Error("Expected an error, got none")
+	}
+	if be.MailFromCounter != 0 {
+		t.Fatal("MAIL FROM issued but should not")
+	}
+}
+
+func TestRemoteDelivery_RelayRegistry_NoTLS(t *testing.T) {
+	be, srv := testutils.SMTPServer(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	zones := map[string]mockdns.Zone{
+		"mx.chain.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": testRelayProof,
+	}
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		testRelayRegistryPolicy(t, zones, staticRegistry{
+			"example.invalid": {{
+				Hostname:       "mx.chain.invalid",
+				TLSFingerprint: testServerSPKIHash,
+				Domains:        []string{"example.invalid"},
+				Operator:       testRelayOperator,
+			}},
+		}),
+	})
+	defer tgt.Close()
+
+	_, err := testutils.DoTestDeliveryErr(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	if err == nil {
+		t.Error("Expected an error, got none")
+	}
+	if be.MailFromCounter != 0 {
+		t.Fatal("MAIL FROM issued but should not")
+	}
+}
+
+func TestRemoteDelivery_RelayRegistry_FallbackMX(t *testing.T) {
+	be, srv := testutils.SMTPServer(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	zones := map[string]mockdns.Zone{
+		"example.invalid.": {
+			MX: []net.MX{{Host: "mx.example.invalid.", Pref: 10}},
+		},
+		"mx.example.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+	}
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		testRelayRegistryPolicy(t, zones, staticRegistry{}),
+	})
+	defer tgt.Close()
+
+	testutils.DoTestDelivery(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	be.CheckMsg(t, 0, "test@example.com", []string{"test@example.invalid"})
+}
+
+func TestRemoteDelivery_RelayRegistry_Unauthorized(t *testing.T) {
+	_, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	// The domain authorizes another operator.
+	zones := map[string]mockdns.Zone{
+		"mx.chain.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": {
+			TXT: []string{"v=MCRELAY1; operator=mailchat1other"},
+		},
+	}
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		testRelayRegistryPolicy(t, zones, staticRegistry{
+			"example.invalid": {{
+				Hostname:       "mx.chain.invalid",
+				TLSFingerprint: testServerSPKIHash,
+				Domains:        []string{"example.invalid"},
+				Operator:       testRelayOperator,
+			}},
+		}),
+	})
+	defer tgt.Close()
+
+	_, err := testutils.DoTestDeliveryErr(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	if err == nil {
+		t.Error("Expected an error, got none")
+	}
+	if be.MailFromCounter != 0 {
+		t.Fatal("MAIL FROM issued but should not")
+	}
+}
+
+func TestRemoteDelivery_RelayRegistry_NoOverride(t *testing.T) {
+	be, srv := testutils.SMTPServer(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	// The registry entry would pin the TLS key that the MX does not have,
+	// delivery fails if it is used.
+	zones := map[string]mockdns.Zone{
+		"example.invalid.": {
+			MX: []net.MX{{Host: "mx.example.invalid.", Pref: 10}},
+		},
+		"mx.example.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+		"_mailchat-relay.example.invalid.": testRelayProof,
+	}
+
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		testRelayRegistryPolicy(t, zones, staticRegistry{
+			"example.invalid": {{
+				Hostname:       "mx.chain.invalid",
+				TLSFingerprint: testServerSPKIHash,
+				Domains:        []string{"example.invalid"},
+				Operator:       testRelayOperator,
+			}},
+		}),
+	})
+	defer tgt.Close()
+
+	testutils.DoTestDelivery(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	be.CheckMsg(t, 0, "test@example.com", []string{"test@example.invalid"})
+}
@@ -46,6 +46,7 @@ import (
 	"github.com/dsoftgames/MailChat/internal/limits"
 	"github.com/dsoftgames/MailChat/internal/smtpconn/pool"
 	"github.com/dsoftgames/MailChat/internal/target"
+	"github.com/dsoftgames/MailChat/internal/tlsrpt"
 	"github.com/emersion/go-message/textproto"
 	"github.com/emersion/go-smtp"
 	"golang.org/x/net/idna"
@@ -80,6 +81,8 @@ type Target struct {
 
 	smtpPort string // SMTP port for outbound connections
 
+	tlsReports tlsrpt.Recorder
+
 	Log log.Logger
 
 	connectTimeout    time.Duration
@@ -145,6 +148,13 @@ func (rt *Target) Init(cfg *config.Map) error {
 	cfg.Duration("command_timeout", false, false, 5*time.Minute, &rt.commandTimeout)
 	cfg.Duration("submission_timeout", false, false, 5*time.Minute, &rt.submissionTimeout)
 	cfg.String("smtp_port", false, false, "25", &rt.smtpPort)
+	cfg.Custom("tls_reports", false, false, nil, func(cfg *config.Map, n config.Node) (interface{}, error) {
+		var rec tlsrpt.Recorder
+		if err := modconfig.ModuleFromNode("tlsrpt", n.Args, n, cfg.Globals, &rec); err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}, &rt.tlsReports)
 
 	poolCfg := pool.Config{
 		MaxKeys:             5000,
@@ -22,8 +22,11 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"os"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/foxcpp/go-mtasts"
@@ -34,6 +37,7 @@ import (
 	"github.com/dsoftgames/MailChat/framework/log"
 	"github.com/dsoftgames/MailChat/framework/module"
 	"github.com/dsoftgames/MailChat/internal/target"
+	"github.com/dsoftgames/MailChat/internal/tlsrpt"
 )
 
 type (
@@ -49,6 +53,9 @@ type (
 		domain    string
 		policyFut *future.Future
 		log       log.Logger
+
+		// Failure detected for the last MX, for TLS reports.
+		failure *tlsrpt.Failure
 	}
 )
 
@@ -168,6 +175,8 @@ func (c *mtastsDelivery) PrepareDomain(ctx context.Context, domain string) {
 func (c *mtastsDelivery) PrepareConn(ctx context.Context, mx string) {}
 
 func (c *mtastsDelivery) CheckMX(ctx context.Context, mxLevel module.MXLevel, domain, mx string, dnssec bool) (module.MXLevel, error) {
+	c.failure = nil
+
 	policyI, err := c.policyFut.GetContext(ctx)
 	if err != nil {
 		c.log.DebugMsg("MTA-STS error", "err", err)
@@ -176,6 +185,10 @@ func (c *mtastsDelivery) CheckMX(ctx context.Context, mxLevel module.MXLevel, do
 	policy := policyI.(*mtasts.Policy)
 
 	if !policy.Match(mx) {
+		c.failure = &tlsrpt.Failure{
+			Type: tlsrpt.ResultValidationFailure,
+			Info: "MX does not match the MTA-STS policy",
+		}
 		if policy.Mode == mtasts.ModeEnforce {
 			return module.MXNone, &exterrors.SMTPError{
 				Code:         550,
@@ -197,6 +210,13 @@ func (c *mtastsDelivery) CheckConn(ctx context.Context, mxLevel module.MXLevel,
 	}
 	policy := policyI.(*mtasts.Policy)
 
+	switch {
+	case !tlsState.HandshakeComplete:
+		c.failure = &tlsrpt.Failure{Type: tlsrpt.ResultSTARTTLSNotSupported}
+	case tlsState.VerifiedChains == nil:
+		c.failure = &tlsrpt.Failure{Type: tlsrpt.ResultCertNotTrusted}
+	}
+
 	if policy.Mode != mtasts.ModeEnforce {
 		return module.TLSNone, nil
 	}
@@ -224,8 +244,34 @@ func (c *mtastsDelivery) CheckConn(ctx context.Context, mxLevel module.MXLevel,
 	return module.TLSNone, nil
 }
 
+func (c *mtastsDelivery) TLSReportPolicy(ctx context.Context, mx string) (tlsrpt.Policy, *tlsrpt.Failure, bool) {
+	if c.policyFut == nil {
+		return tlsrpt.Policy{}, nil, false
+	}
+	policyI, err := c.policyFut.GetContext(ctx)
+	if err != nil {
+		return tlsrpt.Policy{}, nil, false
+	}
+	policy := policyI.(*mtasts.Policy)
+	if policy.Mode == mtasts.ModeNone {
+		return tlsrpt.Policy{}, nil, false
+	}
+
+	policyStrings := []string{"version: STSv1", "mode: " + string(policy.Mode)}
+	for _, mx := range policy.MX {
+		policyStrings = append(policyStrings, "mx: "+mx)
+	}
+	policyStrings = append(policyStrings, "max_age: "+strconv.Itoa(policy.MaxAge))
+	return tlsrpt.Policy{
+		Type:    tlsrpt.PolicySTS,
+		Strings: policyStrings,
+		MXHosts: policy.MX,
+	}, c.failure, true
+}
+
 func (c *mtastsDelivery) Reset(msgMeta *module.MsgMetadata) {
 	c.policyFut = nil
+	c.failure = nil
 	if msgMeta != nil {
 		c.log = target.DeliveryLogger(c.c.log, msgMeta)
 	}
@@ -354,7 +400,11 @@ type (
 	}
 	daneDelivery struct {
 		c       *danePolicy
+		mx      string
 		tlsaFut *future.Future
+
+		// Failure detected for the last MX, for TLS reports.
+		failure *tlsrpt.Failure
 	}
 )
 
@@ -477,7 +527,9 @@ func (c *daneDelivery) PrepareConn(ctx context.Context, mx string) {
 		return
 	}
 
+	c.mx = mx
 	c.tlsaFut = future.New()
+	c.failure = nil
 
 	go func() {
 		defer func() {
@@ -515,12 +567,18 @@ func (c *daneDelivery) CheckConn(ctx context.Context, mxLevel module.MXLevel, tl
 		// We assume DANE failure in both cases as a safety measure.
 		// However, there is a possibility of a temporary error condition,
 		// so we mark it as such.
+		c.failure = &tlsrpt.Failure{Type: tlsrpt.ResultDNSSECInvalid, Info: err.Error()}
 		return module.TLSNone, exterrors.WithTemporary(err, true)
 	}
 	recs := recsI.([]dns.TLSA)
 
 	overridePKIX, err := verifyDANE(recs, tlsState)
 	if err != nil {
+		if !tlsState.HandshakeComplete {
+			c.failure = &tlsrpt.Failure{Type: tlsrpt.ResultSTARTTLSNotSupported}
+		} else {
+			c.failure = &tlsrpt.Failure{Type: tlsrpt.ResultValidationFailure, Info: "No matching TLSA records"}
+		}
 		return module.TLSNone, err
 	}
 	if overridePKIX {
@@ -529,6 +587,30 @@ func (c *daneDelivery) CheckConn(ctx context.Context, mxLevel module.MXLevel, tl
 	return module.TLSNone, nil
 }
 
+func (c *daneDelivery) TLSReportPolicy(ctx context.Context, mx string) (tlsrpt.Policy, *tlsrpt.Failure, bool) {
+	if c.tlsaFut == nil || c.mx != mx {
+		return tlsrpt.Policy{}, nil, false
+	}
+	recsI, err := c.tlsaFut.GetContext(ctx)
+	if err != nil {
+		if dns.IsNotFound(err) {
+			return tlsrpt.Policy{}, nil, false
+		}
+		return tlsrpt.Policy{Type: tlsrpt.PolicyTLSA, MXHosts: []string{strings.TrimSuffix(mx, ".")}}, c.failure, true
+	}
+	recs := recsI.([]dns.TLSA)
+	if len(recs) == 0 {
+		return tlsrpt.Policy{}, nil, false
+	}
+
+	policy := tlsrpt.Policy{Type: tlsrpt.PolicyTLSA, MXHosts: []string{strings.TrimSuffix(mx, ".")}}
+	for _, rec := range recs {
+		policy.Strings = append(policy.Strings, fmt.Sprintf("%d %d %d %s",
+			rec.Usage, rec.Selector, rec.MatchingType, rec.Certificate))
+	}
+	return policy, c.failure, true
+}
+
 func (c *daneDelivery) Reset(*module.MsgMetadata) {}
 
 type (
@@ -566,7 +648,7 @@ func (c *localPolicy) Init(cfg *config.Map) error {
 	cfg.Enum("min_tls_level", false, false,
 		[]string{"none", "encrypted", "authenticated"}, "encrypted", &minTLSLevel)
 	cfg.Enum("min_mx_level", false, false,
-		[]string{"none", "mtasts", "dnssec"}, "none", &minMXLevel)
+		[]string{"none", "mtasts", "dnssec", "registry"}, "none", &minMXLevel)
 	if _, err := cfg.Process(); err != nil {
 		return err
 	}
@@ -587,6 +669,8 @@ func (c *localPolicy) Init(cfg *config.Map) error {
 		c.minMXLevel = module.MX_MTASTS
 	case "dnssec":
 		c.minMXLevel = module.MX_DNSSEC
+	case "registry":
+		c.minMXLevel = module.MX_REGISTRY
 	}
 
 	return nil
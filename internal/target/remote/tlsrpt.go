@@ -0,0 +1,85 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/internal/tlsrpt"
+)
+
+// tlsReportPolicy is implemented by DeliveryMXAuthPolicy implementations
+// that apply policies covered by SMTP TLS Reporting (RFC 8460).
+type tlsReportPolicy interface {
+	// TLSReportPolicy returns the policy applied to the last connection to
+	// the MX and the failure detected by it, if any. ok is false if there
+	// was no policy to apply.
+	TLSReportPolicy(ctx context.Context, mx string) (policy tlsrpt.Policy, failure *tlsrpt.Failure, ok bool)
+}
+
+func addrIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	return ""
+}
+
+// reportTLSSession passes the result of the session with the MX to the
+// TLS reports collector, if configured.
+//
+// tlsState is the state of the established connection, or nil if the
+// connection was not attempted due to the policy failure.
+func (rd *remoteDelivery) reportTLSSession(ctx context.Context, conn *mxConn, mx string, tlsState *tls.ConnectionState, tlsErr error) {
+	if rd.rt.tlsReports == nil {
+		return
+	}
+
+	res := tlsrpt.Result{
+		Time:        time.Now().UTC(),
+		Domain:      conn.domain,
+		Policy:      tlsrpt.Policy{Type: tlsrpt.PolicyNotFound},
+		ReceivingMX: strings.TrimSuffix(mx, "."),
+	}
+	if tlsState != nil && conn.C != nil {
+		res.SendingIP = addrIP(conn.LocalAddr())
+		res.ReceivingIP = addrIP(conn.RemoteAddr())
+	}
+
+	// DANE takes precedence over MTA-STS (RFC 8461 Section 2).
+	applied := false
+	for _, p := range rd.policies {
+		rp, ok := p.(tlsReportPolicy)
+		if !ok {
+			continue
+		}
+		policy, failure, ok := rp.TLSReportPolicy(ctx, mx)
+		if !ok || (applied && res.Policy.Type == tlsrpt.PolicyTLSA) {
+			continue
+		}
+		applied = true
+		res.Policy = policy
+		res.Failure = failure
+	}
+
+	switch {
+	case !applied && tlsState != nil && !tlsState.HandshakeComplete:
+		// Without a policy, unauthenticated TLS is fine.
+		if tlsErr != nil {
+			failure := tlsrpt.ClassifyTLSError(tlsErr)
+			res.Failure = &failure
+		} else {
+			res.Failure = &tlsrpt.Failure{Type: tlsrpt.ResultSTARTTLSNotSupported}
+		}
+	case res.Failure != nil && res.Failure.Type == tlsrpt.ResultCertNotTrusted && tlsErr != nil:
+		// Policies see only the resulting connection, the actual reason is
+		// known from the first handshake attempt.
+		failure := tlsrpt.ClassifyTLSError(tlsErr)
+		res.Failure = &failure
+	}
+
+	if err := rd.rt.tlsReports.RecordSession(res); err != nil {
+		rd.Log.Error("failed to record TLS session result", err, "domain", conn.domain)
+	}
+}
@@ -0,0 +1,131 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/foxcpp/go-mtasts"
+
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+	"github.com/dsoftgames/MailChat/internal/tlsrpt"
+)
+
+type testRecorder struct {
+	lock    sync.Mutex
+	results []tlsrpt.Result
+}
+
+func (r *testRecorder) RecordSession(res tlsrpt.Result) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.results = append(r.results, res)
+	return nil
+}
+
+func testSTSTarget(t *testing.T, policyMX string, mode mtasts.Mode) (*Target, *testRecorder) {
+	zones := map[string]mockdns.Zone{
+		"example.invalid.": {
+			MX: []net.MX{{Host: "mx.example.invalid.", Pref: 10}},
+		},
+		"mx.example.invalid.": {
+			A: []string{"127.0.0.1"},
+		},
+	}
+	mtastsGet := func(_ context.Context, domain string) (*mtasts.Policy, error) {
+		if domain != "example.invalid" {
+			return nil, errors.New("Wrong domain in lookup")
+		}
+		return &mtasts.Policy{
+			Mode:   mode,
+			MaxAge: 86400,
+			MX:     []string{policyMX},
+		}, nil
+	}
+
+	rec := &testRecorder{}
+	tgt := testTarget(t, zones, nil, []module.MXAuthPolicy{
+		testSTSPolicy(t, zones, mtastsGet),
+	})
+	tgt.tlsReports = rec
+	return tgt, rec
+}
+
+func TestRemoteDelivery_TLSReports(t *testing.T) {
+	clientCfg, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	tgt, rec := testSTSTarget(t, "mx.example.invalid", mtasts.ModeEnforce)
+	tgt.tlsConfig = clientCfg
+	defer tgt.Close()
+
+	testutils.DoTestDelivery(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	be.CheckMsg(t, 0, "test@example.com", []string{"test@example.invalid"})
+
+	if len(rec.results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(rec.results))
+	}
+	res := rec.results[0]
+	if res.Domain != "example.invalid" || res.ReceivingMX != "mx.example.invalid" || res.ReceivingIP != "127.0.0.1" {
+		t.Errorf("wrong session info: %+v", res)
+	}
+	if res.Policy.Type != tlsrpt.PolicySTS || len(res.Policy.MXHosts) != 1 ||
+		res.Policy.Strings[1] != "mode: enforce" {
+		t.Errorf("wrong policy: %+v", res.Policy)
+	}
+	if res.Failure != nil {
+		t.Errorf("unexpected failure: %+v", res.Failure)
+	}
+}
+
+func TestRemoteDelivery_TLSReports_MXMismatch(t *testing.T) {
+	clientCfg, be, srv := testutils.SMTPServerSTARTTLS(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	tgt, rec := testSTSTarget(t, "mx4.example.invalid", mtasts.ModeEnforce)
+	tgt.tlsConfig = clientCfg
+	defer tgt.Close()
+
+	if _, err := testutils.DoTestDeliveryErr(t, tgt, "test@example.com", []string{"test@example.invalid"}); err == nil {
+		t.Fatal("Expected an error, got none")
+	}
+	if be.MailFromCounter != 0 {
+		t.Fatal("MAIL FROM issued for server failing authentication")
+	}
+
+	if len(rec.results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(rec.results))
+	}
+	res := rec.results[0]
+	if res.Policy.Type != tlsrpt.PolicySTS {
+		t.Errorf("wrong policy: %+v", res.Policy)
+	}
+	if res.Failure == nil || res.Failure.Type != tlsrpt.ResultValidationFailure {
+		t.Errorf("wrong failure: %+v", res.Failure)
+	}
+}
+
+func TestRemoteDelivery_TLSReports_NoTLS(t *testing.T) {
+	be, srv := testutils.SMTPServer(t, "127.0.0.1:"+smtpPort)
+	defer srv.Close()
+	defer testutils.CheckSMTPConnLeak(t, srv)
+
+	tgt, rec := testSTSTarget(t, "mx.example.invalid", mtasts.ModeTesting)
+	defer tgt.Close()
+
+	testutils.DoTestDelivery(t, tgt, "test@example.com", []string{"test@example.invalid"})
+	be.CheckMsg(t, 0, "test@example.com", []string{"test@example.invalid"})
+
+	if len(rec.results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(rec.results))
+	}
+	if f := rec.results[0].Failure; f == nil || f.Type != tlsrpt.ResultSTARTTLSNotSupported {
+		t.Errorf("wrong failure: %+v", f)
+	}
+}
@@ -96,7 +96,7 @@ func (f *FileLoader) Init(cfg *config.Map) error {
 		return err
 	}
 
-	hooks.AddHook(hooks.EventReload, func() {
+	hooks.AddOwnedHook(f, hooks.EventReload, func() {
 		f.log.Println("reloading certificates")
 		if err := f.loadCerts(); err != nil {
 			f.log.Error("reload failed", err)
@@ -0,0 +1,77 @@
+package tlsrpt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+// Envelope contains the header fields of the report message.
+type Envelope struct {
+	MsgID string
+	From  string
+	To    []string
+}
+
+// Filename returns the file name for the report attachment as defined in
+// RFC 8460 Section 5.3.
+func (rep *Report) Filename(submitter, domain string) string {
+	return fmt.Sprintf("%s!%s!%d!%d.json.gz", submitter, domain,
+		rep.DateRange.Start.Unix(), rep.DateRange.End.Unix())
+}
+
+// WriteMessage writes the message body containing the compressed report for
+// the domain to w and returns the message header.
+func WriteMessage(envelope Envelope, rep *Report, submitter, domain string, compressed []byte, w io.Writer) (textproto.Header, error) {
+	partWriter := textproto.NewMultipartWriter(w)
+
+	hdr := textproto.Header{}
+	hdr.Add("Date", time.Now().Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	hdr.Add("Message-Id", envelope.MsgID)
+	hdr.Add("MIME-Version", "1.0")
+	hdr.Add("Content-Type", "multipart/report; report-type=\"tlsrpt\"; boundary="+partWriter.Boundary())
+	hdr.Add("Auto-Submitted", "auto-generated")
+	hdr.Add("From", envelope.From)
+	hdr.Add("To", strings.Join(envelope.To, ", "))
+	hdr.Add("Subject", fmt.Sprintf("Report Domain: %s Submitter: %s Report-ID: <%s>",
+		domain, submitter, rep.ReportID))
+	hdr.Add("TLS-Report-Domain", domain)
+	hdr.Add("TLS-Report-Submitter", submitter)
+
+	textHdr := textproto.Header{}
+	textHdr.Add("Content-Type", "text/plain; charset=utf-8")
+	textHdr.Add("Content-Transfer-Encoding", "7bit")
+	textWriter, err := partWriter.CreatePart(textHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	if _, err := fmt.Fprintf(textWriter, "This is an SMTP TLS report for %s\r\n"+
+		"covering the period from %s to %s.\r\n",
+		domain, rep.DateRange.Start.Format(time.RFC1123Z), rep.DateRange.End.Format(time.RFC1123Z)); err != nil {
+		return textproto.Header{}, err
+	}
+
+	filename := rep.Filename(submitter, domain)
+	gzHdr := textproto.Header{}
+	gzHdr.Add("Content-Type", "application/tlsrpt+gzip; name=\""+filename+"\"")
+	gzHdr.Add("Content-Transfer-Encoding", "base64")
+	gzHdr.Add("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	gzWriter, err := partWriter.CreatePart(gzHdr)
+	if err != nil {
+		return textproto.Header{}, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(compressed)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := io.WriteString(gzWriter, encoded[:n]+"\r\n"); err != nil {
+			return textproto.Header{}, err
+		}
+		encoded = encoded[n:]
+	}
+
+	return hdr, partWriter.Close()
+}
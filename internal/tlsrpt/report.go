@@ -0,0 +1,159 @@
+package tlsrpt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is the aggregate report as defined in RFC 8460 Section 4.
+type Report struct {
+	OrganizationName string        `json:"organization-name"`
+	DateRange        DateRange     `json:"date-range"`
+	ContactInfo      string        `json:"contact-info"`
+	ReportID         string        `json:"report-id"`
+	Policies         []PolicyEntry `json:"policies"`
+}
+
+type DateRange struct {
+	Start time.Time `json:"start-datetime"`
+	End   time.Time `json:"end-datetime"`
+}
+
+type PolicyEntry struct {
+	Policy         PolicyDescription `json:"policy"`
+	Summary        Summary           `json:"summary"`
+	FailureDetails []FailureDetails  `json:"failure-details,omitempty"`
+}
+
+type PolicyDescription struct {
+	Type    string   `json:"policy-type"`
+	Strings []string `json:"policy-string,omitempty"`
+	Domain  string   `json:"policy-domain"`
+	MXHosts []string `json:"mx-host,omitempty"`
+}
+
+type Summary struct {
+	Successful int `json:"total-successful-session-count"`
+	Failed     int `json:"total-failure-session-count"`
+}
+
+type FailureDetails struct {
+	ResultType     string `json:"result-type"`
+	SendingMTAIP   string `json:"sending-mta-ip,omitempty"`
+	ReceivingMX    string `json:"receiving-mx-hostname,omitempty"`
+	ReceivingIP    string `json:"receiving-ip,omitempty"`
+	FailedSessions int    `json:"failed-session-count"`
+	AdditionalInfo string `json:"additional-information,omitempty"`
+}
+
+// Metadata contains report fields not derived from session results.
+type Metadata struct {
+	OrganizationName string
+	ContactInfo      string
+	ReportID         string
+	Start, End       time.Time
+}
+
+// Aggregate builds the report for the domain from the session results.
+// Results are grouped by the applied policy, failures with the same type
+// and hosts are merged. nil is returned if there are no results for the
+// domain.
+func Aggregate(meta Metadata, domain string, results []Result) *Report {
+	rep := &Report{
+		OrganizationName: meta.OrganizationName,
+		DateRange:        DateRange{Start: meta.Start.UTC(), End: meta.End.UTC()},
+		ContactInfo:      meta.ContactInfo,
+		ReportID:         meta.ReportID,
+	}
+
+	policies := map[string]int{}
+	failures := map[string]int{}
+	for _, res := range results {
+		if !strings.EqualFold(res.Domain, domain) {
+			continue
+		}
+
+		policyKey := policyKey(res.Policy)
+		idx, ok := policies[policyKey]
+		if !ok {
+			idx = len(rep.Policies)
+			policies[policyKey] = idx
+			rep.Policies = append(rep.Policies, PolicyEntry{
+				Policy: PolicyDescription{
+					Type:    res.Policy.Type,
+					Strings: res.Policy.Strings,
+					Domain:  strings.ToLower(domain),
+					MXHosts: res.Policy.MXHosts,
+				},
+			})
+		}
+		entry := &rep.Policies[idx]
+
+		if res.Failure == nil {
+			entry.Summary.Successful++
+			continue
+		}
+		entry.Summary.Failed++
+
+		details := FailureDetails{
+			ResultType:     res.Failure.Type,
+			SendingMTAIP:   res.SendingIP,
+			ReceivingMX:    res.ReceivingMX,
+			ReceivingIP:    res.ReceivingIP,
+			AdditionalInfo: res.Failure.Info,
+		}
+		failureKey := policyKey + "\x00" + details.ResultType + "\x00" + details.SendingMTAIP + "\x00" +
+			details.ReceivingMX + "\x00" + details.ReceivingIP
+		if fidx, ok := failures[failureKey]; ok {
+			entry.FailureDetails[fidx].FailedSessions++
+			continue
+		}
+		details.FailedSessions = 1
+		failures[failureKey] = len(entry.FailureDetails)
+		entry.FailureDetails = append(entry.FailureDetails, details)
+	}
+	if len(rep.Policies) == 0 {
+		return nil
+	}
+	return rep
+}
+
+func policyKey(p Policy) string {
+	return p.Type + "\x00" + strings.Join(p.Strings, "\x00")
+}
+
+// Domains returns the list of recipient domains the results have been
+// collected for, sorted.
+func Domains(results []Result) []string {
+	set := map[string]struct{}{}
+	for _, res := range results {
+		set[strings.ToLower(res.Domain)] = struct{}{}
+	}
+	domains := make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Compress returns the gzip-compressed JSON document for the report.
+func (rep *Report) Compress() ([]byte, error) {
+	blob, err := json.Marshal(rep)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(blob); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
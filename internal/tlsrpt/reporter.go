@@ -0,0 +1,304 @@
+package tlsrpt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/emersion/go-smtp"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/buffer"
+	"github.com/dsoftgames/MailChat/framework/config"
+	modconfig "github.com/dsoftgames/MailChat/framework/config/module"
+	"github.com/dsoftgames/MailChat/framework/dns"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/reportstore"
+)
+
+// Reporter collects outbound TLS session results passed to it by
+// target.remote ('tls_reports' directive) and periodically sends RFC 8460
+// reports to the rua addresses published by recipient domains in
+// _smtp._tls TXT records.
+//
+// Reports are submitted using the configured delivery target, usually the
+// outbound queue.
+type Reporter struct {
+	instName string
+	log      log.Logger
+
+	hostname   string
+	orgName    string
+	email      string
+	autogenMsg string
+	interval   time.Duration
+	retention  time.Duration
+	stateDir   string
+	target     module.DeliveryTarget
+	resolver   Resolver
+
+	storeLock sync.Mutex
+	store     *reportstore.Store[Result]
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func NewReporter(_, instName string, _, _ []string) (module.Module, error) {
+	return &Reporter{
+		instName: instName,
+		log:      log.Logger{Name: "tlsrpt.reporter", Debug: log.DefaultLogger.Debug},
+		resolver: dns.DefaultResolver(),
+	}, nil
+}
+
+func (r *Reporter) Init(cfg *config.Map) error {
+	cfg.Bool("debug", true, log.DefaultLogger.Debug, &r.log.Debug)
+	cfg.String("hostname", true, true, "", &r.hostname)
+	cfg.String("autogenerated_msg_domain", true, false, "", &r.autogenMsg)
+	cfg.String("org_name", false, false, "", &r.orgName)
+	cfg.String("email", false, false, "", &r.email)
+	cfg.Duration("interval", false, false, 24*time.Hour, &r.interval)
+	cfg.Duration("retention", false, false, 7*24*time.Hour, &r.retention)
+	cfg.String("state_dir", false, false, "", &r.stateDir)
+	cfg.Custom("target", false, true, nil, modconfig.DeliveryDirective, &r.target)
+	if _, err := cfg.Process(); err != nil {
+		return err
+	}
+
+	if r.orgName == "" {
+		r.orgName = r.hostname
+	}
+	if r.email == "" {
+		if r.autogenMsg == "" {
+			return errors.New("tlsrpt.reporter: email or autogenerated_msg_domain is required")
+		}
+		r.email = "noreply-tlsrpt@" + r.autogenMsg
+	}
+	if _, _, err := address.Split(r.email); err != nil {
+		return fmt.Errorf("tlsrpt.reporter: malformed email: %w", err)
+	}
+	if r.interval < time.Hour {
+		return errors.New("tlsrpt.reporter: interval should be at least 1h")
+	}
+	if r.retention < r.interval {
+		return errors.New("tlsrpt.reporter: retention should not be shorter than interval")
+	}
+
+	if r.stateDir == "" {
+		name := r.instName
+		if name == "" {
+			name = "default"
+		}
+		r.stateDir = filepath.Join(config.StateDirectory, "tls_reports", name)
+	}
+	if !filepath.IsAbs(r.stateDir) {
+		r.stateDir = filepath.Join(config.StateDirectory, r.stateDir)
+	}
+
+	var err error
+	r.store, err = reportstore.Open[Result](r.stateDir)
+	if err != nil {
+		return fmt.Errorf("tlsrpt.reporter: %w", err)
+	}
+
+	if module.NoRun {
+		return nil
+	}
+
+	r.stop = make(chan struct{})
+	r.stopped.Add(1)
+	go r.run()
+
+	return nil
+}
+
+func (r *Reporter) Name() string {
+	return "tlsrpt.reporter"
+}
+
+func (r *Reporter) InstanceName() string {
+	return r.instName
+}
+
+// RecordSession saves the session result to be included in the next
+// report.
+func (r *Reporter) RecordSession(res Result) error {
+	r.storeLock.Lock()
+	defer r.storeLock.Unlock()
+	return r.store.Add(res)
+}
+
+func (r *Reporter) run() {
+	defer r.stopped.Done()
+
+	// The delivery target likely depends on target.remote that uses this
+	// module, give it time to finish initialization.
+	next := time.Now().Add(time.Minute)
+	for {
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-t.C:
+		case <-r.stop:
+			t.Stop()
+			return
+		}
+
+		r.report(time.Now())
+		next = time.Now().Truncate(r.interval).Add(r.interval)
+	}
+}
+
+// report sends reports for all reporting periods that ended before now and
+// were not reported yet, then removes results older than the retention
+// window.
+func (r *Reporter) report(now time.Time) {
+	periodEnd := now.UTC().Truncate(r.interval)
+
+	r.storeLock.Lock()
+	reportedUntil, err := r.store.ReportedUntil()
+	r.storeLock.Unlock()
+	if err != nil {
+		r.log.Error("failed to read reporting state", err)
+		return
+	}
+	if oldest := periodEnd.Add(-r.retention).Truncate(r.interval); reportedUntil.Before(oldest) {
+		// Results before that are already pruned (or will be).
+		reportedUntil = oldest
+	}
+
+	for begin := reportedUntil; begin.Before(periodEnd); begin = begin.Add(r.interval) {
+		r.sendReports(begin, begin.Add(r.interval))
+
+		r.storeLock.Lock()
+		err := r.store.SetReportedUntil(begin.Add(r.interval))
+		r.storeLock.Unlock()
+		if err != nil {
+			r.log.Error("failed to save reporting state", err)
+			return
+		}
+	}
+
+	r.storeLock.Lock()
+	err = r.store.Prune(now.Add(-r.retention))
+	r.storeLock.Unlock()
+	if err != nil {
+		r.log.Error("failed to remove old results", err)
+	}
+}
+
+// sendReports sends reports for results collected during [begin, end).
+func (r *Reporter) sendReports(begin, end time.Time) {
+	r.storeLock.Lock()
+	results, err := r.store.Records(begin, end)
+	r.storeLock.Unlock()
+	if err != nil {
+		r.log.Error("failed to read results", err)
+		return
+	}
+
+	for _, domain := range Domains(results) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := r.sendReport(ctx, begin, end, domain, results)
+		cancel()
+		if err != nil {
+			r.log.Error("failed to send report", err, "domain", domain)
+		}
+	}
+}
+
+func (r *Reporter) sendReport(ctx context.Context, begin, end time.Time, domain string, results []Result) error {
+	rua, err := LookupRUA(ctx, r.resolver, domain)
+	if err != nil {
+		return err
+	}
+	if len(rua) == 0 {
+		r.log.DebugMsg("domain does not request reports", "domain", domain)
+		return nil
+	}
+
+	_, submitter, err := address.Split(r.email)
+	if err != nil {
+		return err
+	}
+	rep := Aggregate(Metadata{
+		OrganizationName: r.orgName,
+		ContactInfo:      r.email,
+		ReportID:         strconv.FormatInt(begin.Unix(), 10) + "." + domain + "@" + r.hostname,
+		Start:            begin,
+		End:              end,
+	}, domain, results)
+	if rep == nil {
+		return nil
+	}
+	compressed, err := rep.Compress()
+	if err != nil {
+		return err
+	}
+
+	msgID, err := module.GenerateMsgID()
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	hdr, err := WriteMessage(Envelope{
+		MsgID: "<" + msgID + "@" + submitter + ">",
+		From:  r.email,
+		To:    rua,
+	}, rep, submitter, domain, compressed, &body)
+	if err != nil {
+		return err
+	}
+
+	if err := r.deliver(ctx, msgID, hdr, body.Bytes(), rua); err != nil {
+		return err
+	}
+	r.log.Msg("report sent", "domain", domain, "rcpts", rua, "msg_id", msgID)
+	return nil
+}
+
+func (r *Reporter) deliver(ctx context.Context, msgID string, hdr textproto.Header, body []byte, rcpts []string) error {
+	msgMeta := &module.MsgMetadata{
+		ID:       msgID,
+		SMTPOpts: smtp.MailOptions{},
+	}
+	delivery, err := r.target.Start(ctx, msgMeta, r.email)
+	if err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := delivery.AddRcpt(ctx, rcpt, smtp.RcptOptions{}); err != nil {
+			delivery.Abort(ctx)
+			return err
+		}
+	}
+	if err := delivery.Body(ctx, hdr, buffer.MemoryBuffer{Slice: body}); err != nil {
+		delivery.Abort(ctx)
+		return err
+	}
+	return delivery.Commit(ctx)
+}
+
+func (r *Reporter) Close() error {
+	if r.stop != nil {
+		close(r.stop)
+		r.stopped.Wait()
+	}
+	if r.store == nil {
+		return nil
+	}
+	r.storeLock.Lock()
+	defer r.storeLock.Unlock()
+	return r.store.Close()
+}
+
+func init() {
+	module.Register("tlsrpt.reporter", NewReporter)
+}
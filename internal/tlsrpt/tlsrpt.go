@@ -0,0 +1,169 @@
+// Package tlsrpt implements SMTP TLS Reporting (RFC 8460) for outbound
+// delivery.
+package tlsrpt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dsoftgames/MailChat/framework/address"
+	"github.com/dsoftgames/MailChat/framework/dns"
+)
+
+// Policy types.
+const (
+	PolicySTS      = "sts"
+	PolicyTLSA     = "tlsa"
+	PolicyNotFound = "no-policy-found"
+)
+
+// Result types defined in RFC 8460 Section 4.3.
+const (
+	ResultSTARTTLSNotSupported = "starttls-not-supported"
+	ResultCertHostMismatch     = "certificate-host-mismatch"
+	ResultCertExpired          = "certificate-expired"
+	ResultCertNotTrusted       = "certificate-not-trusted"
+	ResultValidationFailure    = "validation-failure"
+	ResultTLSAInvalid          = "tlsa-invalid"
+	ResultDNSSECInvalid        = "dnssec-invalid"
+	ResultDANERequired         = "dane-required"
+	ResultSTSPolicyFetchError  = "sts-policy-fetch-error"
+	ResultSTSPolicyInvalid     = "sts-policy-invalid"
+	ResultSTSWebPKIInvalid     = "sts-webpki-invalid"
+)
+
+// Policy describes the policy applied to the session.
+type Policy struct {
+	Type    string   `json:"type"`
+	Strings []string `json:"strings,omitempty"`
+	MXHosts []string `json:"mx_hosts,omitempty"`
+}
+
+// Failure describes why the session failed.
+type Failure struct {
+	Type string `json:"type"`
+	Info string `json:"info,omitempty"`
+}
+
+// Result is the outcome of a single outbound TLS session.
+type Result struct {
+	Time   time.Time `json:"time"`
+	Domain string    `json:"domain"`
+	Policy Policy    `json:"policy"`
+
+	SendingIP   string `json:"sending_ip,omitempty"`
+	ReceivingMX string `json:"receiving_mx"`
+	ReceivingIP string `json:"receiving_ip,omitempty"`
+
+	// Failure is nil for successful sessions.
+	Failure *Failure `json:"failure,omitempty"`
+}
+
+func (res Result) RecordTime() time.Time {
+	return res.Time
+}
+
+// Recorder is implemented by modules that collect TLS session results to
+// send reports.
+type Recorder interface {
+	RecordSession(res Result) error
+}
+
+// ClassifyTLSError returns the failure for the TLS handshake error.
+func ClassifyTLSError(err error) Failure {
+	var (
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+		authErr    x509.UnknownAuthorityError
+		verifyErr  *tls.CertificateVerificationError
+	)
+	switch {
+	case errors.As(err, &hostErr):
+		return Failure{Type: ResultCertHostMismatch, Info: err.Error()}
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return Failure{Type: ResultCertExpired, Info: err.Error()}
+	case errors.As(err, &authErr), errors.As(err, &invalidErr), errors.As(err, &verifyErr):
+		return Failure{Type: ResultCertNotTrusted, Info: err.Error()}
+	default:
+		return Failure{Type: ResultValidationFailure, Info: err.Error()}
+	}
+}
+
+// ParseRecord parses the TLSRPT TXT record (RFC 8460 Section 3) and returns
+// the mailto: report addresses. Other URI schemes are ignored.
+func ParseRecord(txt string) ([]string, error) {
+	fields := strings.Split(txt, ";")
+	if strings.TrimSpace(fields[0]) != "v=TLSRPTv1" {
+		return nil, errors.New("tlsrpt: not a TLSRPTv1 record")
+	}
+
+	var rua []string
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || key != "rua" {
+			continue
+		}
+		for _, uri := range strings.Split(value, ",") {
+			addr, ok := mailtoAddress(strings.TrimSpace(uri))
+			if !ok {
+				continue
+			}
+			rua = append(rua, addr)
+		}
+	}
+	if len(rua) == 0 {
+		return nil, errors.New("tlsrpt: no usable rua addresses")
+	}
+	return rua, nil
+}
+
+func mailtoAddress(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || !strings.EqualFold(u.Scheme, "mailto") {
+		return "", false
+	}
+	addr, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return "", false
+	}
+	if _, _, err := address.Split(addr); err != nil {
+		return "", false
+	}
+	return addr, true
+}
+
+// Resolver is the subset of dns.Resolver used to look up TLSRPT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// LookupRUA returns report addresses published by the domain. nil is
+// returned if the domain does not request reports.
+func LookupRUA(ctx context.Context, r Resolver, domain string) ([]string, error) {
+	txts, err := r.LookupTXT(ctx, dns.FQDN("_smtp._tls."+domain))
+	if err != nil {
+		if dns.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Multiple records are treated as no record (RFC 8460 Section 3).
+	var rua []string
+	for _, txt := range txts {
+		addrs, err := ParseRecord(txt)
+		if err != nil {
+			continue
+		}
+		if rua != nil {
+			return nil, nil
+		}
+		rua = addrs
+	}
+	return rua, nil
+}
@@ -0,0 +1,262 @@
+package tlsrpt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/foxcpp/go-mockdns"
+
+	"github.com/dsoftgames/MailChat/internal/reportstore"
+	"github.com/dsoftgames/MailChat/internal/testutils"
+)
+
+func TestParseRecord(t *testing.T) {
+	test := func(txt string, expected []string, fail bool) {
+		t.Helper()
+		rua, err := ParseRecord(txt)
+		if fail {
+			if err == nil {
+				t.Errorf("expected error for %q, got %v", txt, rua)
+			}
+			return
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", txt, err)
+			return
+		}
+		if !reflect.DeepEqual(rua, expected) {
+			t.Errorf("wrong rua for %q: %v", txt, rua)
+		}
+	}
+
+	test("v=TLSRPTv1; rua=mailto:tlsrpt@example.org", []string{"tlsrpt@example.org"}, false)
+	test("v=TLSRPTv1;rua=mailto:a@example.org,mailto:b@example.org", []string{"a@example.org", "b@example.org"}, false)
+	test("v=TLSRPTv1; rua=https://example.org/report,mailto:a@example.org", []string{"a@example.org"}, false)
+	test("v=TLSRPTv1; rua=https://example.org/report", nil, true)
+	test("v=TLSRPTv1", nil, true)
+	test("v=spf1 -all", nil, true)
+}
+
+func TestLookupRUA(t *testing.T) {
+	r := &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+		"_smtp._tls.example.org.": {
+			TXT: []string{"v=TLSRPTv1; rua=mailto:tlsrpt@example.org"},
+		},
+		"_smtp._tls.example.com.": {
+			TXT: []string{
+				"v=TLSRPTv1; rua=mailto:a@example.com",
+				"v=TLSRPTv1; rua=mailto:b@example.com",
+			},
+		},
+		"_smtp._tls.example.net.": {
+			TXT: []string{"v=spf1 -all"},
+		},
+	}}
+
+	rua, err := LookupRUA(context.Background(), r, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rua) != 1 || rua[0] != "tlsrpt@example.org" {
+		t.Errorf("wrong rua: %v", rua)
+	}
+
+	for _, domain := range []string{"example.com", "example.net", "example.invalid"} {
+		rua, err := LookupRUA(context.Background(), r, domain)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", domain, err)
+		}
+		if rua != nil {
+			t.Errorf("expected no rua for %s, got %v", domain, rua)
+		}
+	}
+}
+
+func TestClassifyTLSError(t *testing.T) {
+	test := func(err error, expected string) {
+		t.Helper()
+		if f := ClassifyTLSError(err); f.Type != expected {
+			t.Errorf("wrong result type for %v: %s, expected %s", err, f.Type, expected)
+		}
+	}
+
+	test(x509.HostnameError{Certificate: &x509.Certificate{}, Host: "mx.example.org"}, ResultCertHostMismatch)
+	test(x509.CertificateInvalidError{Reason: x509.Expired}, ResultCertExpired)
+	test(x509.UnknownAuthorityError{}, ResultCertNotTrusted)
+	test(errors.New("handshake failure"), ResultValidationFailure)
+}
+
+func testResult(t time.Time, domain string, failure *Failure) Result {
+	return Result{
+		Time:   t,
+		Domain: domain,
+		Policy: Policy{
+			Type:    PolicySTS,
+			Strings: []string{"version: STSv1", "mode: enforce", "mx: mx.example.org", "max_age: 86400"},
+			MXHosts: []string{"mx.example.org"},
+		},
+		SendingIP:   "192.0.2.1",
+		ReceivingMX: "mx.example.org",
+		ReceivingIP: "203.0.113.1",
+		Failure:     failure,
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	notTrusted := &Failure{Type: ResultCertNotTrusted}
+	results := []Result{
+		testResult(now, "example.org", nil),
+		testResult(now, "example.org", nil),
+		testResult(now, "example.org", notTrusted),
+		testResult(now, "example.org", notTrusted),
+		testResult(now, "example.com", nil),
+		{Time: now, Domain: "example.org", Policy: Policy{Type: PolicyNotFound}, ReceivingMX: "mx.example.org"},
+	}
+
+	if domains := Domains(results); len(domains) != 2 || domains[0] != "example.com" || domains[1] != "example.org" {
+		t.Fatalf("wrong domains: %v", domains)
+	}
+
+	rep := Aggregate(Metadata{OrganizationName: "Test"}, "example.org", results)
+	if rep == nil {
+		t.Fatal("no report")
+	}
+	if len(rep.Policies) != 2 {
+		t.Fatalf("expected 2 policies, got %d", len(rep.Policies))
+	}
+	sts := rep.Policies[0]
+	if sts.Policy.Type != PolicySTS || sts.Policy.Domain != "example.org" ||
+		sts.Summary.Successful != 2 || sts.Summary.Failed != 2 {
+		t.Errorf("wrong STS entry: %+v", sts)
+	}
+	if len(sts.FailureDetails) != 1 || sts.FailureDetails[0].FailedSessions != 2 ||
+		sts.FailureDetails[0].ResultType != ResultCertNotTrusted {
+		t.Errorf("wrong failure details: %+v", sts.FailureDetails)
+	}
+	if none := rep.Policies[1]; none.Policy.Type != PolicyNotFound || none.Summary.Successful != 1 {
+		t.Errorf("wrong no-policy entry: %+v", none)
+	}
+
+	if Aggregate(Metadata{}, "example.net", results) != nil {
+		t.Error("report generated for a domain without results")
+	}
+}
+
+func TestReporter(t *testing.T) {
+	tgt := &testutils.Target{}
+	store, err := reportstore.Open[Result](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Reporter{
+		log:       testutils.Logger(t, "tlsrpt.reporter"),
+		hostname:  "mx.example.com",
+		orgName:   "Example",
+		email:     "noreply-tlsrpt@example.com",
+		interval:  24 * time.Hour,
+		retention: 72 * time.Hour,
+		target:    tgt,
+		resolver: &mockdns.Resolver{Zones: map[string]mockdns.Zone{
+			"_smtp._tls.example.org.": {
+				TXT: []string{"v=TLSRPTv1; rua=mailto:tlsrpt@example.org"},
+			},
+		}},
+		store: store,
+	}
+	defer r.Close()
+
+	now := time.Now().UTC()
+	yesterday := now.Truncate(24 * time.Hour).Add(-time.Hour)
+	for _, res := range []Result{
+		testResult(yesterday, "example.org", nil),
+		testResult(yesterday, "example.org", &Failure{Type: ResultCertExpired}),
+		// Not reported yet, the period is not over.
+		testResult(now, "example.org", nil),
+		// No TLSRPT record.
+		testResult(yesterday, "example.net", nil),
+	} {
+		if err := r.RecordSession(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.report(now)
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(tgt.Messages))
+	}
+	msg := tgt.Messages[0]
+	if msg.MailFrom != "noreply-tlsrpt@example.com" || len(msg.RcptTo) != 1 || msg.RcptTo[0] != "tlsrpt@example.org" {
+		t.Fatalf("wrong envelope: %s -> %v", msg.MailFrom, msg.RcptTo)
+	}
+	if msg.Header.Get("TLS-Report-Domain") != "example.org" || msg.Header.Get("TLS-Report-Submitter") != "example.com" {
+		t.Errorf("wrong report headers: %v, %v", msg.Header.Get("TLS-Report-Domain"), msg.Header.Get("TLS-Report-Submitter"))
+	}
+
+	rep := readReport(t, msg.Header, msg.Body)
+	if rep.OrganizationName != "Example" || len(rep.Policies) != 1 {
+		t.Fatalf("wrong report: %+v", rep)
+	}
+	if s := rep.Policies[0].Summary; s.Successful != 1 || s.Failed != 1 {
+		t.Errorf("wrong summary: %+v", s)
+	}
+	if rep.DateRange.End.Sub(rep.DateRange.Start) != 24*time.Hour {
+		t.Errorf("wrong date range: %+v", rep.DateRange)
+	}
+
+	// Period is reported only once.
+	r.report(now)
+	if len(tgt.Messages) != 1 {
+		t.Fatalf("report sent twice")
+	}
+}
+
+func readReport(t *testing.T, hdr textproto.Header, body []byte) *Report {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(hdr.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/report" || params["report-type"] != "tlsrpt" {
+		t.Fatalf("wrong content type: %s %v", mediaType, params)
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal("no report attachment:", err)
+		}
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), "application/tlsrpt+gzip") {
+			continue
+		}
+
+		gz, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, bufio.NewReader(part)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep := &Report{}
+		if err := json.Unmarshal(blob, rep); err != nil {
+			t.Fatal(err)
+		}
+		return rep
+	}
+}
@@ -18,6 +18,10 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package updatepipe
 
+import (
+	mess "github.com/foxcpp/go-imap-mess"
+)
+
 type BackendMode int
 
 const (
@@ -43,3 +47,14 @@ type Backend interface {
 	// This method is idempotent. All calls after a successful one do nothing.
 	EnableUpdatePipe(mode BackendMode) error
 }
+
+// The Notifier interface is implemented by storage backends that allow other
+// modules to observe the update stream, e.g. to implement push
+// notifications. Updates are reported only if the update pipe is enabled.
+type Notifier interface {
+	// NotifyUpdates registers the function called for each update, both
+	// local and received over the pipe. Username is the owner of the
+	// updated mailbox. The function is called synchronously and should not
+	// block.
+	NotifyUpdates(fn func(username string, upd mess.Update))
+}
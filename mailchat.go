@@ -19,6 +19,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package mailchat
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -38,12 +39,14 @@ import (
 	"github.com/dsoftgames/MailChat/framework/hooks"
 	"github.com/dsoftgames/MailChat/framework/log"
 	"github.com/dsoftgames/MailChat/framework/module"
+	"github.com/dsoftgames/MailChat/internal/auth/bruteforce"
 	"github.com/dsoftgames/MailChat/internal/authz"
 	"github.com/spf13/cobra"
 
 	// Import packages for side-effect of module registration.
 	_ "github.com/dsoftgames/MailChat/internal/auth/dovecot_sasl"
 	_ "github.com/dsoftgames/MailChat/internal/auth/external"
+	_ "github.com/dsoftgames/MailChat/internal/auth/jwt"
 	_ "github.com/dsoftgames/MailChat/internal/auth/ldap"
 	_ "github.com/dsoftgames/MailChat/internal/auth/netauth"
 	_ "github.com/dsoftgames/MailChat/internal/auth/pam"
@@ -52,23 +55,32 @@ import (
 	_ "github.com/dsoftgames/MailChat/internal/auth/plain_separate"
 	_ "github.com/dsoftgames/MailChat/internal/auth/shadow"
 	_ "github.com/dsoftgames/MailChat/internal/blockchain"
+	_ "github.com/dsoftgames/MailChat/internal/check/arc"
 	_ "github.com/dsoftgames/MailChat/internal/check/authorize_sender"
 	_ "github.com/dsoftgames/MailChat/internal/check/command"
 	_ "github.com/dsoftgames/MailChat/internal/check/dkim"
 	_ "github.com/dsoftgames/MailChat/internal/check/dns"
 	_ "github.com/dsoftgames/MailChat/internal/check/dnsbl"
+	_ "github.com/dsoftgames/MailChat/internal/check/greylist"
 	_ "github.com/dsoftgames/MailChat/internal/check/milter"
 	_ "github.com/dsoftgames/MailChat/internal/check/requiretls"
 	_ "github.com/dsoftgames/MailChat/internal/check/rspamd"
 	_ "github.com/dsoftgames/MailChat/internal/check/spf"
+	_ "github.com/dsoftgames/MailChat/internal/dmarc/report"
+	_ "github.com/dsoftgames/MailChat/internal/endpoint/admin"
 	_ "github.com/dsoftgames/MailChat/internal/endpoint/dovecot_sasld"
 	_ "github.com/dsoftgames/MailChat/internal/endpoint/imap"
+	_ "github.com/dsoftgames/MailChat/internal/endpoint/jmap"
+	_ "github.com/dsoftgames/MailChat/internal/endpoint/managesieve"
 	_ "github.com/dsoftgames/MailChat/internal/endpoint/openmetrics"
+	_ "github.com/dsoftgames/MailChat/internal/endpoint/pop3"
 	_ "github.com/dsoftgames/MailChat/internal/endpoint/smtp"
 	_ "github.com/dsoftgames/MailChat/internal/imap_filter"
 	_ "github.com/dsoftgames/MailChat/internal/imap_filter/command"
+	_ "github.com/dsoftgames/MailChat/internal/imap_filter/sieve"
 	_ "github.com/dsoftgames/MailChat/internal/libdns"
 	_ "github.com/dsoftgames/MailChat/internal/modify"
+	_ "github.com/dsoftgames/MailChat/internal/modify/arc"
 	_ "github.com/dsoftgames/MailChat/internal/modify/dkim"
 	_ "github.com/dsoftgames/MailChat/internal/storage/blob/fs"
 	_ "github.com/dsoftgames/MailChat/internal/storage/blob/s3"
@@ -79,6 +91,7 @@ import (
 	_ "github.com/dsoftgames/MailChat/internal/target/smtp"
 	_ "github.com/dsoftgames/MailChat/internal/tls"
 	_ "github.com/dsoftgames/MailChat/internal/tls/acme"
+	_ "github.com/dsoftgames/MailChat/internal/tlsrpt"
 )
 
 var (
@@ -295,9 +308,30 @@ func ensureDirectoryWritable(path string) error {
 func ReadGlobals(cfg []config.Node) (map[string]interface{}, []config.Node, error) {
 	// don't know what caused the inability to set config Default value of StateDirectory， so I set it here
 	config.StateDirectory = DefaultStateDirectory
-	globals := config.NewMap(nil, config.Node{Children: cfg})
-	globals.String("state_dir", false, false, DefaultStateDirectory, &config.StateDirectory)
-	globals.String("runtime_dir", false, false, DefaultRuntimeDirectory, &config.RuntimeDirectory)
+	return readGlobals(module.CurrentRegistry(), cfg, false)
+}
+
+// readGlobals parses global directives, the modules defined by them are
+// added to reg. If reload is true, directives that can't be changed
+// without restart (see restartDirectives) are parsed without side effects.
+func readGlobals(reg *module.Registry, cfg []config.Node, reload bool) (map[string]interface{}, []config.Node, error) {
+	var (
+		stateDir   = &config.StateDirectory
+		runtimeDir = &config.RuntimeDirectory
+		logOut     = &log.DefaultLogger.Out
+		debug      = &log.DefaultLogger.Debug
+		logMapper  = logOutput
+	)
+	if reload {
+		stateDir, runtimeDir, logOut, debug = nil, nil, nil, nil
+		logMapper = func(*config.Map, config.Node) (interface{}, error) {
+			return log.DefaultLogger.Out, nil
+		}
+	}
+
+	globals := config.NewMap(reg.BuildGlobals(nil, reg), config.Node{Children: cfg})
+	globals.String("state_dir", false, false, DefaultStateDirectory, stateDir)
+	globals.String("runtime_dir", false, false, DefaultRuntimeDirectory, runtimeDir)
 	globals.String("hostname", false, false, "", nil)
 	globals.String("autogenerated_msg_domain", false, false, "", nil)
 	globals.Custom("tls", false, false, nil, tls.TLSDirective, nil)
@@ -305,8 +339,12 @@ func ReadGlobals(cfg []config.Node) (map[string]interface{}, []config.Node, erro
 	globals.Bool("storage_perdomain", false, false, nil)
 	globals.Bool("auth_perdomain", false, false, nil)
 	globals.StringList("auth_domains", false, false, nil, nil)
-	globals.Custom("log", false, false, defaultLogOutput, logOutput, &log.DefaultLogger.Out)
-	globals.Bool("debug", false, log.DefaultLogger.Debug, &log.DefaultLogger.Debug)
+	globals.Custom("log", false, false, defaultLogOutput, logMapper, logOut)
+	globals.Bool("debug", false, log.DefaultLogger.Debug, debug)
+	globals.Duration("reload_drain_timeout", false, false, defaultDrainTimeout, nil)
+	globals.Custom("auth_bruteforce", false, false, func() (interface{}, error) {
+		return bruteforce.DefaultConfig(), nil
+	}, bruteforce.ConfigDirective, nil)
 	config.EnumMapped(globals, "auth_map_normalize", true, false, authz.NormalizeFuncs, authz.NormalizeAuto, nil)
 	modconfig.Table(globals, "auth_map", true, false, nil, nil)
 	globals.AllowUnknown()
@@ -315,6 +353,24 @@ func ReadGlobals(cfg []config.Node) (map[string]interface{}, []config.Node, erro
 }
 
 func moduleMain(cfg []config.Node) error {
+	if err := startModules(cfg); err != nil {
+		return err
+	}
+
+	systemdStatus(SDReady, "Listening for incoming connections...")
+
+	handleSignals()
+
+	systemdStatus(SDStopping, "Waiting for running transactions to complete...")
+
+	hooks.RunHooks(hooks.EventShutdown)
+
+	return nil
+}
+
+func startModules(cfg []config.Node) error {
+	reg := module.CurrentRegistry()
+
 	globals, modBlocks, err := ReadGlobals(cfg)
 	fmt.Printf("config.StateDirectory: %v\n", config.StateDirectory)
 	if err != nil {
@@ -325,24 +381,58 @@ func moduleMain(cfg []config.Node) error {
 		return err
 	}
 
-	hooks.AddHook(hooks.EventLogRotate, reinitLogging)
+	bruteforce.Default.Configure(globals["auth_bruteforce"].(bruteforce.Config))
 
-	endpoints, mods, err := RegisterModules(globals, modBlocks)
+	// Output provided by the embedding process can't be reopened.
+	if _, ok := log.DefaultLogger.Out.(logOut); ok {
+		hooks.AddHook(hooks.EventLogRotate, reinitLogging)
+	}
+
+	endpoints, mods, err := registerModules(reg, globals, modBlocks, nil)
 	if err != nil {
 		return err
 	}
 
-	err = initModules(globals, endpoints, mods)
-	if err != nil {
+	if err := initModules(reg, globals, endpoints, mods); err != nil {
 		return err
 	}
 
-	systemdStatus(SDReady, "Listening for incoming connections...")
+	setRunning(cfg, globals, modBlocks, mods, reg)
+	return nil
+}
 
-	handleSignals()
+// RunEmbedded runs the server using the configuration file at cfgPath until
+// ctx is cancelled. It is used to run the server inside another process
+// (the chain node, see 'MailChatd start --mail') that handles termination
+// signals itself.
+//
+// Log messages are written to logOut unless the configuration file
+// specifies the log directive.
+func RunEmbedded(ctx context.Context, cfgPath string, logOut log.Output) error {
+	certmagic.UserAgent = "github.com/dsoftgames/MailChat/" + Version
+	log.DefaultLogger.Out = logOut
 
-	systemdStatus(SDStopping, "Waiting for running transactions to complete...")
+	os.Setenv("PATH", config.LibexecDirectory+string(filepath.ListSeparator)+os.Getenv("PATH"))
 
+	log.Printf("Starting MailChat %s (embedded)\n", Version)
+	f, err := os.Open(cfgPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := parser.Read(f, cfgPath)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := startModules(cfg); err != nil {
+		hooks.RunHooks(hooks.EventShutdown)
+		return err
+	}
+
+	handleSignalsContext(ctx)
+
+	log.Printf("shutting down")
 	hooks.RunHooks(hooks.EventShutdown)
 
 	return nil
@@ -354,6 +444,13 @@ type ModInfo struct {
 }
 
 func RegisterModules(globals map[string]interface{}, nodes []config.Node) (endpoints, mods []ModInfo, err error) {
+	return registerModules(module.CurrentRegistry(), globals, nodes, nil)
+}
+
+// registerModules is RegisterModules that adds instances to reg and uses
+// carry to get instances carried over from the previous configuration.
+// carry returns nil for instances that should be created.
+func registerModules(reg *module.Registry, globals map[string]interface{}, nodes []config.Node, carry func(instName string) module.Module) (endpoints, mods []ModInfo, err error) {
 	mods = make([]ModInfo, 0, len(nodes))
 
 	for _, block := range nodes {
@@ -384,21 +481,26 @@ func RegisterModules(globals map[string]interface{}, nodes []config.Node) (endpo
 			return nil, nil, config.NodeErr(block, "unknown module or global directive: %s", modName)
 		}
 
-		if module.HasInstance(instName) {
+		if reg.HasInstance(instName) {
 			return nil, nil, config.NodeErr(block, "config block named %s already exists", instName)
 		}
 
-		inst, err := factory(modName, instName, modAliases, nil)
-		if err != nil {
-			return nil, nil, err
+		var inst module.Module
+		if carry != nil {
+			inst = carry(instName)
+		}
+		if inst == nil {
+			inst, err = factory(modName, instName, modAliases, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			reg.Register(inst, config.NewMap(reg.BuildGlobals(globals, inst), block))
 		}
-
-		module.RegisterInstance(inst, config.NewMap(globals, block))
 		for _, alias := range modAliases {
-			if module.HasInstance(alias) {
+			if reg.HasInstance(alias) {
 				return nil, nil, config.NodeErr(block, "config block named %s already exists", alias)
 			}
-			module.RegisterAlias(alias, instName)
+			reg.RegisterAlias(alias, instName)
 		}
 
 		log.Debugf("%v:%v: register config block %v %v", block.File, block.Line, instName, modAliases)
@@ -412,15 +514,18 @@ func RegisterModules(globals map[string]interface{}, nodes []config.Node) (endpo
 	return endpoints, mods, nil
 }
 
-func initModules(globals map[string]interface{}, endpoints, mods []ModInfo) error {
+func initModules(reg *module.Registry, globals map[string]interface{}, endpoints, mods []ModInfo) error {
 	for _, endp := range endpoints {
-		if err := endp.Instance.Init(config.NewMap(globals, endp.Cfg)); err != nil {
+		if err := endp.Instance.Init(config.NewMap(reg.BuildGlobals(globals, endp.Instance), endp.Cfg)); err != nil {
 			return err
 		}
+		reg.RegisterEndpointInstance(endp.Instance)
 
+		// The hook is associated with the endpoint so it is removed when
+		// the endpoint is released after the configuration reload.
 		if closer, ok := endp.Instance.(io.Closer); ok {
 			endp := endp
-			hooks.AddHook(hooks.EventShutdown, func() {
+			hooks.AddOwnedHook(endp.Instance, hooks.EventShutdown, func() {
 				log.Debugf("close %s (%s)", endp.Instance.Name(), endp.Instance.InstanceName())
 				if err := closer.Close(); err != nil {
 					log.Printf("module %s (%s) close failed: %v", endp.Instance.Name(), endp.Instance.InstanceName(), err)
@@ -430,7 +535,7 @@ func initModules(globals map[string]interface{}, endpoints, mods []ModInfo) erro
 	}
 
 	for _, inst := range mods {
-		if module.Initialized[inst.Instance.InstanceName()] {
+		if reg.IsInitialized(inst.Instance.InstanceName()) {
 			continue
 		}
 
@@ -0,0 +1,297 @@
+package mailchat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	parser "github.com/dsoftgames/MailChat/framework/cfgparser"
+	"github.com/dsoftgames/MailChat/framework/config"
+	"github.com/dsoftgames/MailChat/framework/hooks"
+	"github.com/dsoftgames/MailChat/framework/log"
+	"github.com/dsoftgames/MailChat/framework/module"
+	adminapi "github.com/dsoftgames/MailChat/internal/admin"
+	"github.com/dsoftgames/MailChat/internal/auth/bruteforce"
+	"github.com/dsoftgames/MailChat/internal/listeners"
+)
+
+// defaultDrainTimeout is the default time the endpoints of the previous
+// configuration are kept running after the reload. It is long because IMAP
+// clients keep IDLE connections open.
+const defaultDrainTimeout = 1 * time.Hour
+
+// restartDirectives are the global directives that are applied only on
+// server start. The configuration reload fails if they are changed.
+var restartDirectives = []string{"state_dir", "runtime_dir", "log", "debug"}
+
+// runningConfig is the configuration used by the server.
+type runningConfig struct {
+	globalNodes  []config.Node
+	mods         []ModInfo
+	reg          *module.Registry
+	drainTimeout time.Duration
+}
+
+var (
+	reloadLck sync.Mutex
+	running   *runningConfig
+)
+
+func init() {
+	adminapi.ReloadServer = reload
+}
+
+func setRunning(cfg []config.Node, globals map[string]interface{}, modBlocks []config.Node, mods []ModInfo, reg *module.Registry) {
+	reloadLck.Lock()
+	defer reloadLck.Unlock()
+	setRunningLocked(cfg, globals, modBlocks, mods, reg)
+}
+
+// reload reloads the configuration file and runs EventReload hooks so
+// modules reload secondary files, such as TLS certificates.
+//
+// If the configuration can't be loaded, the error is logged and returned,
+// the server keeps using the running configuration.
+func reload() error {
+	systemdStatus(SDReloading, "Reloading configuration...")
+	defer systemdStatus(SDReady, "Listening for incoming connections...")
+
+	err := reloadConfig()
+	if err != nil {
+		log.DefaultLogger.Error("configuration reload failed, keeping the running configuration", err)
+	}
+
+	hooks.RunHooks(hooks.EventReload)
+	return err
+}
+
+// reloadConfig creates module instances from the configuration file and
+// switches the server to them.
+//
+// Instances with unchanged configuration are carried over from the running
+// configuration. Endpoints are always recreated, they take over listening
+// sockets of the running endpoints so connections are not refused during
+// the reload. The running endpoints stop accepting connections and are
+// closed, together with the module instances that are no longer used, after
+// their connections are closed or reload_drain_timeout passes.
+func reloadConfig() error {
+	reloadLck.Lock()
+	defer reloadLck.Unlock()
+
+	if running == nil {
+		return errors.New("server is not running")
+	}
+
+	f, err := os.Open(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := parser.Read(f, configPath)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	reg := module.NewRegistry()
+
+	globals, modBlocks, err := readGlobals(reg, cfg, true)
+	if err != nil {
+		release(reg.Created())
+		return err
+	}
+	newGlobals := globalNodes(cfg, modBlocks)
+
+	carried, err := running.carried(newGlobals, modBlocks)
+	if err != nil {
+		release(reg.Created())
+		return err
+	}
+
+	gen := listeners.Begin()
+
+	endpoints, mods, err := registerModules(reg, globals, modBlocks, func(instName string) module.Module {
+		if !carried[instName] || !reg.Carry(running.reg, instName) {
+			return nil
+		}
+		inst, _ := reg.Lookup(instName)
+		return inst
+	})
+	if err == nil {
+		err = initModules(reg, globals, endpoints, mods)
+	}
+	if err != nil {
+		gen.Abort()
+
+		created := reg.Created()
+		// Endpoints that failed to initialize are not in the registry.
+		for _, endp := range endpoints {
+			created[endp.Instance] = true
+		}
+		release(created)
+		return err
+	}
+
+	prev := running
+	module.SetRegistry(reg)
+	bruteforce.Default.Configure(globals["auth_bruteforce"].(bruteforce.Config))
+	prevGen := gen.Commit()
+	setRunningLocked(cfg, globals, modBlocks, mods, reg)
+
+	used := reg.Used()
+	released := make(map[module.Module]bool)
+	for mod := range prev.reg.Used() {
+		if !used[mod] {
+			released[mod] = true
+		}
+	}
+
+	log.Printf("configuration reloaded, %d module instances carried over", len(carried))
+	go drain(prevGen, prev.drainTimeout, released)
+
+	return nil
+}
+
+func setRunningLocked(cfg []config.Node, globals map[string]interface{}, modBlocks []config.Node, mods []ModInfo, reg *module.Registry) {
+	drainTimeout, _ := globals["reload_drain_timeout"].(time.Duration)
+	running = &runningConfig{
+		globalNodes:  globalNodes(cfg, modBlocks),
+		mods:         mods,
+		reg:          reg,
+		drainTimeout: drainTimeout,
+	}
+}
+
+// drain waits for the connections accepted by the endpoints of the previous
+// configuration and releases its modules.
+func drain(gen *listeners.Generation, timeout time.Duration, released map[module.Module]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := gen.Wait(ctx); err != nil {
+		log.Printf("closing connections left after configuration reload (reload_drain_timeout is %v)", timeout)
+	}
+
+	release(released)
+	log.Debugf("released %d module instances of the previous configuration", len(released))
+}
+
+// release removes the hooks of the module instances and closes them.
+func release(mods map[module.Module]bool) {
+	owners := make([]interface{}, 0, len(mods))
+	for mod := range mods {
+		owners = append(owners, mod)
+	}
+	hooks.Release(owners...)
+}
+
+// carried returns the names of module instances that can be carried over to
+// the new configuration.
+//
+// An instance is carried over if its configuration block and the global
+// directives are not changed. It keeps using the instances it references
+// even if they are recreated. Instances of Exclusive modules can't be
+// replaced, see module.CheckExclusive.
+func (rc *runningConfig) carried(newGlobals, modBlocks []config.Node) (map[string]bool, error) {
+	for _, name := range restartDirectives {
+		if !nodesEqual(findNodes(rc.globalNodes, name), findNodes(newGlobals, name)) {
+			return nil, fmt.Errorf("%s directive can't be changed without restart", name)
+		}
+	}
+
+	// tls is inherited only by endpoints that are always recreated,
+	// auth_bruteforce is not used by modules.
+	ignored := []string{"tls", "auth_bruteforce"}
+	globalsChanged := !nodesEqual(withoutNodes(rc.globalNodes, ignored...), withoutNodes(newGlobals, ignored...))
+
+	newBlocks := make(map[string]config.Node, len(modBlocks))
+	for _, block := range modBlocks {
+		if len(block.Args) == 0 {
+			newBlocks[block.Name] = block
+		} else {
+			newBlocks[block.Args[0]] = block
+		}
+	}
+
+	carried := make(map[string]bool)
+	for _, mod := range rc.mods {
+		name := mod.Instance.InstanceName()
+		if !rc.reg.IsInitialized(name) {
+			continue
+		}
+
+		block, ok := newBlocks[name]
+		if !ok {
+			continue
+		}
+		if !globalsChanged && nodesEqual([]config.Node{mod.Cfg}, []config.Node{block}) {
+			carried[name] = true
+		}
+	}
+
+	return carried, nil
+}
+
+// globalNodes returns the global directives from the configuration.
+//
+// modBlocks are the nodes that are not global directives, as returned by
+// readGlobals in the same order as in cfg.
+func globalNodes(cfg, modBlocks []config.Node) []config.Node {
+	var globals []config.Node
+	for _, node := range cfg {
+		if len(modBlocks) != 0 && node.File == modBlocks[0].File && node.Line == modBlocks[0].Line &&
+			nodesEqual([]config.Node{node}, modBlocks[:1]) {
+			modBlocks = modBlocks[1:]
+			continue
+		}
+		globals = append(globals, node)
+	}
+	return globals
+}
+
+func findNodes(nodes []config.Node, name string) []config.Node {
+	var found []config.Node
+	for _, node := range nodes {
+		if node.Name == name {
+			found = append(found, node)
+		}
+	}
+	return found
+}
+
+func withoutNodes(nodes []config.Node, names ...string) []config.Node {
+	var kept []config.Node
+outer:
+	for _, node := range nodes {
+		for _, name := range names {
+			if node.Name == name {
+				continue outer
+			}
+		}
+		kept = append(kept, node)
+	}
+	return kept
+}
+
+// nodesEqual compares configuration nodes ignoring their location in
+// configuration files.
+func nodesEqual(a, b []config.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || len(a[i].Args) != len(b[i].Args) {
+			return false
+		}
+		for j := range a[i].Args {
+			if a[i].Args[j] != b[i].Args[j] {
+				return false
+			}
+		}
+		if !nodesEqual(a[i].Children, b[i].Children) {
+			return false
+		}
+	}
+	return true
+}
@@ -22,6 +22,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package mailchat
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -42,16 +43,8 @@ func handleSignals() os.Signal {
 
 	for {
 		switch s := <-sig; s {
-		case syscall.SIGUSR1:
-			log.Printf("signal received (%s), rotating logs", s.String())
-			systemdStatus(SDReloading, "Reopening logs...")
-			hooks.RunHooks(hooks.EventLogRotate)
-			systemdStatus(SDReady, "Listening for incoming connections...")
-		case syscall.SIGUSR2:
-			log.Printf("signal received (%s), reloading state", s.String())
-			systemdStatus(SDReloading, "Reloading state...")
-			hooks.RunHooks(hooks.EventReload)
-			systemdStatus(SDReady, "Listening for incoming connections...")
+		case syscall.SIGUSR1, syscall.SIGUSR2:
+			handleControlSignal(s)
 		default:
 			go func() {
 				s := handleSignals()
@@ -64,3 +57,36 @@ func handleSignals() os.Signal {
 		}
 	}
 }
+
+// handleSignalsContext is the variant of handleSignals used when the server
+// runs embedded in another process that handles termination signals itself.
+// It returns when ctx is cancelled.
+func handleSignalsContext(ctx context.Context) {
+	sig := make(chan os.Signal, 5)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-sig:
+			handleControlSignal(s)
+		}
+	}
+}
+
+// handleControlSignal handles SIGUSR1 (logs rotation) and SIGUSR2
+// (configuration reload).
+func handleControlSignal(s os.Signal) {
+	switch s {
+	case syscall.SIGUSR1:
+		log.Printf("signal received (%s), rotating logs", s.String())
+		systemdStatus(SDReloading, "Reopening logs...")
+		hooks.RunHooks(hooks.EventLogRotate)
+		systemdStatus(SDReady, "Listening for incoming connections...")
+	case syscall.SIGUSR2:
+		log.Printf("signal received (%s), reloading configuration", s.String())
+		reload()
+	}
+}
@@ -22,6 +22,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package mailchat
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -43,3 +44,7 @@ func handleSignals() os.Signal {
 	log.Printf("signal received (%v), next signal will force immediate shutdown.", s)
 	return s
 }
+
+func handleSignalsContext(ctx context.Context) {
+	<-ctx.Done()
+}
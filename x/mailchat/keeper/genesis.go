@@ -8,6 +8,24 @@ import (
 
 // InitGenesis initializes the module's state from a provided genesis state.
 func (k Keeper) InitGenesis(ctx context.Context, genState types.GenesisState) error {
+	for _, relay := range genState.Relays {
+		if err := k.SetRelay(ctx, relay); err != nil {
+			return err
+		}
+	}
+
+	for _, anchor := range genState.Anchors {
+		if err := k.Anchors.Set(ctx, anchor.Root, anchor); err != nil {
+			return err
+		}
+	}
+
+	for _, quota := range genState.Quotas {
+		if err := k.Quotas.Set(ctx, quota.Mailbox, quota); err != nil {
+			return err
+		}
+	}
+
 	return k.Params.Set(ctx, genState.Params)
 }
 
@@ -21,5 +39,26 @@ func (k Keeper) ExportGenesis(ctx context.Context) (*types.GenesisState, error)
 		return nil, err
 	}
 
+	if err := k.Relays.Walk(ctx, nil, func(_ string, relay types.MailRelay) (bool, error) {
+		genesis.Relays = append(genesis.Relays, relay)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := k.Anchors.Walk(ctx, nil, func(_ string, anchor types.MerkleAnchor) (bool, error) {
+		genesis.Anchors = append(genesis.Anchors, anchor)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := k.Quotas.Walk(ctx, nil, func(_ string, quota types.MailboxQuota) (bool, error) {
+		genesis.Quotas = append(genesis.Quotas, quota)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
 	return genesis, nil
 }
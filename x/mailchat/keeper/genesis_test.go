@@ -11,6 +11,27 @@ import (
 func TestGenesis(t *testing.T) {
 	genesisState := types.GenesisState{
 		Params: types.DefaultParams(),
+		Relays: []types.MailRelay{
+			{
+				Hostname:       "mx.example.org",
+				TlsFingerprint: "a9b5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf",
+				Domains:        []string{"example.org"},
+			},
+		},
+		Anchors: []types.MerkleAnchor{
+			{
+				Root:        "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9",
+				LeafCount:   3,
+				BlockHeight: 10,
+				Timestamp:   1700000000,
+			},
+		},
+		Quotas: []types.MailboxQuota{
+			{
+				Mailbox:        "user@example.org",
+				PurchasedBytes: 1 << 30,
+			},
+		},
 	}
 
 	f := initFixture(t)
@@ -21,4 +42,11 @@ func TestGenesis(t *testing.T) {
 	require.NotNil(t, got)
 
 	require.EqualExportedValues(t, genesisState.Params, got.Params)
+	require.EqualExportedValues(t, genesisState.Relays, got.Relays)
+	require.EqualExportedValues(t, genesisState.Anchors, got.Anchors)
+	require.EqualExportedValues(t, genesisState.Quotas, got.Quotas)
+
+	relays, err := f.keeper.GetRelaysByDomain(f.ctx, "example.org")
+	require.NoError(t, err)
+	require.Len(t, relays, 1)
 }
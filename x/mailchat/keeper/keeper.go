@@ -19,8 +19,18 @@ type Keeper struct {
 	// Typically, this should be the x/gov module account.
 	authority []byte
 
+	bankKeeper types.BankKeeper
+
 	Schema collections.Schema
 	Params collections.Item[types.Params]
+	// Relays maps relay hostnames to registry entries.
+	Relays collections.Map[string, types.MailRelay]
+	// RelayDomains indexes relay hostnames by served domain.
+	RelayDomains collections.KeySet[collections.Pair[string, string]]
+	// Anchors maps Merkle roots to anchor entries.
+	Anchors collections.Map[string, types.MerkleAnchor]
+	// Quotas maps mailbox addresses to purchased storage quotas.
+	Quotas collections.Map[string, types.MailboxQuota]
 }
 
 func NewKeeper(
@@ -28,7 +38,7 @@ func NewKeeper(
 	cdc codec.Codec,
 	addressCodec address.Codec,
 	authority []byte,
-
+	bankKeeper types.BankKeeper,
 ) Keeper {
 	if _, err := addressCodec.BytesToString(authority); err != nil {
 		panic(fmt.Sprintf("invalid authority address %s: %s", authority, err))
@@ -41,8 +51,13 @@ func NewKeeper(
 		cdc:          cdc,
 		addressCodec: addressCodec,
 		authority:    authority,
+		bankKeeper:   bankKeeper,
 
-		Params: collections.NewItem(sb, types.ParamsKey, "params", codec.CollValue[types.Params](cdc)),
+		Params:       collections.NewItem(sb, types.ParamsKey, "params", codec.CollValue[types.Params](cdc)),
+		Relays:       collections.NewMap(sb, types.RelayKey, "relays", collections.StringKey, codec.CollValue[types.MailRelay](cdc)),
+		RelayDomains: collections.NewKeySet(sb, types.RelayDomainKey, "relay_domains", collections.PairKeyCodec(collections.StringKey, collections.StringKey)),
+		Anchors:      collections.NewMap(sb, types.AnchorKey, "anchors", collections.StringKey, codec.CollValue[types.MerkleAnchor](cdc)),
+		Quotas:       collections.NewMap(sb, types.QuotaKey, "quotas", collections.StringKey, codec.CollValue[types.MailboxQuota](cdc)),
 	}
 
 	schema, err := sb.Build()
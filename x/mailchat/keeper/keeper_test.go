@@ -10,6 +10,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/runtime"
 	"github.com/cosmos/cosmos-sdk/testutil"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
 	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 
@@ -22,6 +23,42 @@ type fixture struct {
 	ctx          context.Context
 	keeper       keeper.Keeper
 	addressCodec address.Codec
+	bankKeeper   *mockBankKeeper
+}
+
+// mockBankKeeper keeps account balances in memory.
+type mockBankKeeper struct {
+	balances map[string]sdk.Coins
+	burned   sdk.Coins
+}
+
+func (m *mockBankKeeper) SpendableCoins(_ context.Context, addr sdk.AccAddress) sdk.Coins {
+	return m.balances[addr.String()]
+}
+
+func (m *mockBankKeeper) SendCoins(_ context.Context, from, to sdk.AccAddress, amt sdk.Coins) error {
+	balance, neg := m.balances[from.String()].SafeSub(amt...)
+	if neg {
+		return sdkerrors.ErrInsufficientFunds
+	}
+	m.balances[from.String()] = balance
+	m.balances[to.String()] = m.balances[to.String()].Add(amt...)
+	return nil
+}
+
+func (m *mockBankKeeper) SendCoinsFromAccountToModule(ctx context.Context, from sdk.AccAddress, module string, amt sdk.Coins) error {
+	return m.SendCoins(ctx, from, authtypes.NewModuleAddress(module), amt)
+}
+
+func (m *mockBankKeeper) BurnCoins(_ context.Context, module string, amt sdk.Coins) error {
+	addr := authtypes.NewModuleAddress(module).String()
+	balance, neg := m.balances[addr].SafeSub(amt...)
+	if neg {
+		return sdkerrors.ErrInsufficientFunds
+	}
+	m.balances[addr] = balance
+	m.burned = m.burned.Add(amt...)
+	return nil
 }
 
 func initFixture(t *testing.T) *fixture {
@@ -35,12 +72,14 @@ func initFixture(t *testing.T) *fixture {
 	ctx := testutil.DefaultContextWithDB(t, storeKey, storetypes.NewTransientStoreKey("transient_test")).Ctx
 
 	authority := authtypes.NewModuleAddress(types.GovModuleName)
+	bankKeeper := &mockBankKeeper{balances: map[string]sdk.Coins{}}
 
 	k := keeper.NewKeeper(
 		storeService,
 		encCfg.Codec,
 		addressCodec,
 		authority,
+		bankKeeper,
 	)
 
 	// Initialize params
@@ -52,5 +91,6 @@ func initFixture(t *testing.T) *fixture {
 		ctx:          ctx,
 		keeper:       k,
 		addressCodec: addressCodec,
+		bankKeeper:   bankKeeper,
 	}
 }
@@ -0,0 +1,69 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+
+	"cosmossdk.io/collections"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+// SetRelay stores the relay entry and updates the domain index, dropping
+// index entries for domains the relay no longer serves.
+func (k Keeper) SetRelay(ctx context.Context, relay types.MailRelay) error {
+	old, err := k.Relays.Get(ctx, relay.Hostname)
+	if err != nil && !errors.Is(err, collections.ErrNotFound) {
+		return err
+	}
+	for _, domain := range old.Domains {
+		if err := k.RelayDomains.Remove(ctx, collections.Join(domain, relay.Hostname)); err != nil {
+			return err
+		}
+	}
+
+	for _, domain := range relay.Domains {
+		if err := k.RelayDomains.Set(ctx, collections.Join(domain, relay.Hostname)); err != nil {
+			return err
+		}
+	}
+	return k.Relays.Set(ctx, relay.Hostname, relay)
+}
+
+// DeleteRelay deletes the relay entry and its domain index entries.
+func (k Keeper) DeleteRelay(ctx context.Context, hostname string) error {
+	relay, err := k.Relays.Get(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	for _, domain := range relay.Domains {
+		if err := k.RelayDomains.Remove(ctx, collections.Join(domain, hostname)); err != nil {
+			return err
+		}
+	}
+	return k.Relays.Remove(ctx, hostname)
+}
+
+// GetRelaysByDomain returns all relays serving the domain, ordered by
+// hostname.
+func (k Keeper) GetRelaysByDomain(ctx context.Context, domain string) ([]types.MailRelay, error) {
+	iter, err := k.RelayDomains.Iterate(ctx, collections.NewPrefixedPairRange[string, string](domain))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var relays []types.MailRelay
+	for ; iter.Valid(); iter.Next() {
+		key, err := iter.Key()
+		if err != nil {
+			return nil, err
+		}
+		relay, err := k.Relays.Get(ctx, key.K2())
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, relay)
+	}
+	return relays, nil
+}
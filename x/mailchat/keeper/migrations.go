@@ -0,0 +1,46 @@
+package keeper
+
+import (
+	"errors"
+
+	"cosmossdk.io/collections"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+// Migrator handles in-place store migrations of the module.
+type Migrator struct {
+	keeper Keeper
+}
+
+// NewMigrator returns a new Migrator.
+func NewMigrator(keeper Keeper) Migrator {
+	return Migrator{keeper: keeper}
+}
+
+// Migrate1to2 sets the mailbox quota params added in version 2 to their
+// defaults. Params stored by version 1 have them unset, which would leave
+// mailboxes without the free quota and disable quota purchases.
+func (m Migrator) Migrate1to2(ctx sdk.Context) error {
+	params, err := m.keeper.Params.Get(ctx)
+	if err != nil {
+		if !errors.Is(err, collections.ErrNotFound) {
+			return err
+		}
+		params = types.Params{}
+	}
+
+	defaults := types.DefaultParams()
+	if params.QuotaUnitBytes == 0 {
+		params.QuotaUnitBytes = defaults.QuotaUnitBytes
+	}
+	if params.DefaultQuotaBytes == 0 {
+		params.DefaultQuotaBytes = defaults.DefaultQuotaBytes
+	}
+	if params.QuotaPrice.Denom == "" {
+		params.QuotaPrice = defaults.QuotaPrice
+	}
+
+	return m.keeper.Params.Set(ctx, params)
+}
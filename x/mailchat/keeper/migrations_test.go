@@ -0,0 +1,33 @@
+package keeper_test
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/keeper"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func TestMigrate1to2(t *testing.T) {
+	f := initFixture(t)
+
+	// Params stored before the quota params were added.
+	require.NoError(t, f.keeper.Params.Set(f.ctx, types.Params{}))
+
+	m := keeper.NewMigrator(f.keeper)
+	require.NoError(t, m.Migrate1to2(sdk.UnwrapSDKContext(f.ctx)))
+
+	params, err := f.keeper.Params.Get(f.ctx)
+	require.NoError(t, err)
+	require.Equal(t, types.DefaultParams(), params)
+
+	// Values set by governance are kept.
+	params.QuotaUnitBytes = 1 << 20
+	require.NoError(t, f.keeper.Params.Set(f.ctx, params))
+	require.NoError(t, m.Migrate1to2(sdk.UnwrapSDKContext(f.ctx)))
+	migrated, err := f.keeper.Params.Get(f.ctx)
+	require.NoError(t, err)
+	require.Equal(t, params, migrated)
+}
@@ -0,0 +1,69 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"cosmossdk.io/collections"
+	errorsmod "cosmossdk.io/errors"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func (k msgServer) RegisterRelay(ctx context.Context, msg *types.MsgRegisterRelay) (*types.MsgRegisterRelayResponse, error) {
+	if _, err := k.addressCodec.StringToBytes(msg.Operator); err != nil {
+		return nil, errorsmod.Wrap(err, "invalid operator address")
+	}
+
+	relay := types.MailRelay{
+		Hostname:       types.NormalizeRelayName(msg.Hostname),
+		Operator:       msg.Operator,
+		TlsFingerprint: strings.ToLower(msg.TlsFingerprint),
+		Domains:        make([]string, 0, len(msg.Domains)),
+	}
+	for _, domain := range msg.Domains {
+		relay.Domains = append(relay.Domains, types.NormalizeRelayName(domain))
+	}
+	if err := relay.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing, err := k.Relays.Get(ctx, relay.Hostname)
+	if err != nil && !errors.Is(err, collections.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && existing.Operator != msg.Operator {
+		return nil, errorsmod.Wrapf(types.ErrRelayOwned, "relay %s is registered by %s", relay.Hostname, existing.Operator)
+	}
+
+	if err := k.SetRelay(ctx, relay); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgRegisterRelayResponse{}, nil
+}
+
+func (k msgServer) RemoveRelay(ctx context.Context, msg *types.MsgRemoveRelay) (*types.MsgRemoveRelayResponse, error) {
+	if _, err := k.addressCodec.StringToBytes(msg.Operator); err != nil {
+		return nil, errorsmod.Wrap(err, "invalid operator address")
+	}
+
+	hostname := types.NormalizeRelayName(msg.Hostname)
+	existing, err := k.Relays.Get(ctx, hostname)
+	if err != nil {
+		if errors.Is(err, collections.ErrNotFound) {
+			return nil, errorsmod.Wrap(types.ErrRelayNotFound, hostname)
+		}
+		return nil, err
+	}
+	if existing.Operator != msg.Operator {
+		return nil, errorsmod.Wrapf(types.ErrRelayOwned, "relay %s is registered by %s", hostname, existing.Operator)
+	}
+
+	if err := k.DeleteRelay(ctx, hostname); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgRemoveRelayResponse{}, nil
+}
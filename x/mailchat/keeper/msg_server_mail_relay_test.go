@@ -0,0 +1,125 @@
+package keeper_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/keeper"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+const testFingerprint = "a9b5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf"
+
+func TestMsgRegisterRelay(t *testing.T) {
+	f := initFixture(t)
+	ms := keeper.NewMsgServerImpl(f.keeper)
+	qs := keeper.NewQueryServerImpl(f.keeper)
+
+	operator, err := f.addressCodec.BytesToString([]byte("operator_1__________"))
+	require.NoError(t, err)
+	other, err := f.addressCodec.BytesToString([]byte("operator_2__________"))
+	require.NoError(t, err)
+
+	_, err = ms.RegisterRelay(f.ctx, &types.MsgRegisterRelay{
+		Operator:       operator,
+		Hostname:       "MX.Example.ORG.",
+		TlsFingerprint: testFingerprint,
+		Domains:        []string{"example.org", "Example.COM"},
+	})
+	require.NoError(t, err)
+
+	resp, err := qs.RelaysByDomain(f.ctx, &types.QueryRelaysByDomainRequest{Domain: "example.com"})
+	require.NoError(t, err)
+	require.Len(t, resp.Relays, 1)
+	require.Equal(t, "mx.example.org", resp.Relays[0].Hostname)
+	require.Equal(t, operator, resp.Relays[0].Operator)
+
+	// Entry is owned by the first operator.
+	_, err = ms.RegisterRelay(f.ctx, &types.MsgRegisterRelay{
+		Operator:       other,
+		Hostname:       "mx.example.org",
+		TlsFingerprint: testFingerprint,
+		Domains:        []string{"example.net"},
+	})
+	require.ErrorIs(t, err, types.ErrRelayOwned)
+
+	// Update drops the stale domain index entries.
+	_, err = ms.RegisterRelay(f.ctx, &types.MsgRegisterRelay{
+		Operator:       operator,
+		Hostname:       "mx.example.org",
+		TlsFingerprint: testFingerprint,
+		Domains:        []string{"example.org"},
+	})
+	require.NoError(t, err)
+	resp, err = qs.RelaysByDomain(f.ctx, &types.QueryRelaysByDomainRequest{Domain: "example.com"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Relays)
+
+	_, err = ms.RemoveRelay(f.ctx, &types.MsgRemoveRelay{Operator: other, Hostname: "mx.example.org"})
+	require.ErrorIs(t, err, types.ErrRelayOwned)
+	_, err = ms.RemoveRelay(f.ctx, &types.MsgRemoveRelay{Operator: operator, Hostname: "mx.example.org"})
+	require.NoError(t, err)
+	_, err = ms.RemoveRelay(f.ctx, &types.MsgRemoveRelay{Operator: operator, Hostname: "mx.example.org"})
+	require.ErrorIs(t, err, types.ErrRelayNotFound)
+
+	resp, err = qs.RelaysByDomain(f.ctx, &types.QueryRelaysByDomainRequest{Domain: "example.org"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Relays)
+}
+
+func TestMsgRegisterRelay_Invalid(t *testing.T) {
+	f := initFixture(t)
+	ms := keeper.NewMsgServerImpl(f.keeper)
+
+	operator, err := f.addressCodec.BytesToString([]byte("operator_1__________"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		input *types.MsgRegisterRelay
+	}{
+		{
+			name: "invalid operator",
+			input: &types.MsgRegisterRelay{
+				Operator:       "invalid",
+				Hostname:       "mx.example.org",
+				TlsFingerprint: testFingerprint,
+				Domains:        []string{"example.org"},
+			},
+		},
+		{
+			name: "invalid hostname",
+			input: &types.MsgRegisterRelay{
+				Operator:       operator,
+				Hostname:       "mx..example.org",
+				TlsFingerprint: testFingerprint,
+				Domains:        []string{"example.org"},
+			},
+		},
+		{
+			name: "short fingerprint",
+			input: &types.MsgRegisterRelay{
+				Operator:       operator,
+				Hostname:       "mx.example.org",
+				TlsFingerprint: "a9b5cb4d",
+				Domains:        []string{"example.org"},
+			},
+		},
+		{
+			name: "no domains",
+			input: &types.MsgRegisterRelay{
+				Operator:       operator,
+				Hostname:       "mx.example.org",
+				TlsFingerprint: testFingerprint,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ms.RegisterRelay(f.ctx, tc.input)
+			require.Error(t, err)
+		})
+	}
+}
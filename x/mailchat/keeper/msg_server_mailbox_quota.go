@@ -0,0 +1,84 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+
+	"cosmossdk.io/collections"
+	errorsmod "cosmossdk.io/errors"
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func (k msgServer) PurchaseQuota(ctx context.Context, msg *types.MsgPurchaseQuota) (*types.MsgPurchaseQuotaResponse, error) {
+	buyer, err := k.addressCodec.StringToBytes(msg.Buyer)
+	if err != nil {
+		return nil, errorsmod.Wrap(err, "invalid buyer address")
+	}
+
+	mailbox := types.NormalizeMailbox(msg.Mailbox)
+	if err := types.ValidateMailbox(mailbox); err != nil {
+		return nil, err
+	}
+	if msg.Units == 0 {
+		return nil, errorsmod.Wrap(types.ErrInvalidQuota, "units should be positive")
+	}
+
+	params, err := k.Params.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if params.QuotaUnitBytes == 0 {
+		return nil, errorsmod.Wrap(types.ErrInvalidQuota, "quota purchases are disabled")
+	}
+
+	quota, err := k.Quotas.Get(ctx, mailbox)
+	if err != nil {
+		if !errors.Is(err, collections.ErrNotFound) {
+			return nil, err
+		}
+		quota = types.MailboxQuota{Mailbox: mailbox}
+	}
+
+	added := params.QuotaUnitBytes * msg.Units
+	if added/params.QuotaUnitBytes != msg.Units || quota.PurchasedBytes+added < added {
+		return nil, errorsmod.Wrap(types.ErrInvalidQuota, "purchased storage is too big")
+	}
+	quota.PurchasedBytes += added
+
+	if err := k.payForQuota(ctx, buyer, params, msg.Units); err != nil {
+		return nil, err
+	}
+
+	if err := k.Quotas.Set(ctx, mailbox, quota); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgPurchaseQuotaResponse{
+		LimitBytes: params.QuotaLimit(quota.PurchasedBytes),
+	}, nil
+}
+
+// payForQuota charges the buyer for the specified number of quota units. The
+// payment is sent to the recipient set in params or burned.
+func (k msgServer) payForQuota(ctx context.Context, buyer sdk.AccAddress, params types.Params, units uint64) error {
+	if params.QuotaPrice.Amount.IsNil() || params.QuotaPrice.IsZero() {
+		return nil
+	}
+	cost := sdk.NewCoins(sdk.NewCoin(params.QuotaPrice.Denom, params.QuotaPrice.Amount.Mul(math.NewIntFromUint64(units))))
+
+	if params.QuotaPaymentRecipient != "" {
+		recipient, err := k.addressCodec.StringToBytes(params.QuotaPaymentRecipient)
+		if err != nil {
+			return errorsmod.Wrap(err, "invalid quota payment recipient")
+		}
+		return k.bankKeeper.SendCoins(ctx, buyer, recipient, cost)
+	}
+
+	if err := k.bankKeeper.SendCoinsFromAccountToModule(ctx, buyer, types.ModuleName, cost); err != nil {
+		return err
+	}
+	return k.bankKeeper.BurnCoins(ctx, types.ModuleName, cost)
+}
@@ -0,0 +1,76 @@
+package keeper_test
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/keeper"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func TestMsgPurchaseQuota(t *testing.T) {
+	f := initFixture(t)
+	ms := keeper.NewMsgServerImpl(f.keeper)
+	qs := keeper.NewQueryServerImpl(f.keeper)
+
+	buyerAddr := sdk.AccAddress("buyer_1_____________")
+	buyer, err := f.addressCodec.BytesToString(buyerAddr)
+	require.NoError(t, err)
+	f.bankKeeper.balances[buyerAddr.String()] = sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 2500000))
+
+	params := types.DefaultParams()
+
+	got, err := qs.MailboxQuota(f.ctx, &types.QueryMailboxQuotaRequest{Mailbox: "user@example.org"})
+	require.NoError(t, err)
+	require.Equal(t, params.DefaultQuotaBytes, got.LimitBytes)
+
+	_, err = ms.PurchaseQuota(f.ctx, &types.MsgPurchaseQuota{Buyer: buyer, Mailbox: "not-a-mailbox", Units: 1})
+	require.ErrorIs(t, err, types.ErrInvalidQuota)
+	_, err = ms.PurchaseQuota(f.ctx, &types.MsgPurchaseQuota{Buyer: buyer, Mailbox: "user@example.org"})
+	require.ErrorIs(t, err, types.ErrInvalidQuota)
+
+	resp, err := ms.PurchaseQuota(f.ctx, &types.MsgPurchaseQuota{Buyer: buyer, Mailbox: "User@Example.org", Units: 2})
+	require.NoError(t, err)
+	require.Equal(t, params.DefaultQuotaBytes+2*params.QuotaUnitBytes, resp.LimitBytes)
+	require.Equal(t, "2000000"+sdk.DefaultBondDenom, f.bankKeeper.burned.String())
+
+	got, err = qs.MailboxQuota(f.ctx, &types.QueryMailboxQuotaRequest{Mailbox: "user@example.org"})
+	require.NoError(t, err)
+	require.Equal(t, resp.LimitBytes, got.LimitBytes)
+	require.Equal(t, 2*params.QuotaUnitBytes, got.Quota.PurchasedBytes)
+
+	// Not enough funds left.
+	_, err = ms.PurchaseQuota(f.ctx, &types.MsgPurchaseQuota{Buyer: buyer, Mailbox: "user@example.org", Units: 1})
+	require.ErrorIs(t, err, sdkerrors.ErrInsufficientFunds)
+}
+
+func TestMsgPurchaseQuota_Recipient(t *testing.T) {
+	f := initFixture(t)
+	ms := keeper.NewMsgServerImpl(f.keeper)
+
+	buyerAddr := sdk.AccAddress("buyer_1_____________")
+	buyer, err := f.addressCodec.BytesToString(buyerAddr)
+	require.NoError(t, err)
+	f.bankKeeper.balances[buyerAddr.String()] = sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 1000000))
+
+	recipientAddr := sdk.AccAddress("recipient_1_________")
+	recipient, err := f.addressCodec.BytesToString(recipientAddr)
+	require.NoError(t, err)
+
+	params := types.DefaultParams()
+	params.QuotaPaymentRecipient = recipient
+	require.NoError(t, f.keeper.Params.Set(f.ctx, params))
+
+	_, err = ms.PurchaseQuota(f.ctx, &types.MsgPurchaseQuota{Buyer: buyer, Mailbox: "user@example.org", Units: 1})
+	require.NoError(t, err)
+	require.True(t, f.bankKeeper.burned.IsZero())
+	require.Equal(t, "1000000"+sdk.DefaultBondDenom, f.bankKeeper.balances[recipientAddr.String()].String())
+
+	params.QuotaUnitBytes = 0
+	require.NoError(t, f.keeper.Params.Set(f.ctx, params))
+	_, err = ms.PurchaseQuota(f.ctx, &types.MsgPurchaseQuota{Buyer: buyer, Mailbox: "user@example.org", Units: 1})
+	require.ErrorIs(t, err, types.ErrInvalidQuota)
+}
@@ -0,0 +1,45 @@
+package keeper
+
+import (
+	"context"
+
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func (k msgServer) AnchorRoot(ctx context.Context, msg *types.MsgAnchorRoot) (*types.MsgAnchorRootResponse, error) {
+	if _, err := k.addressCodec.StringToBytes(msg.Submitter); err != nil {
+		return nil, errorsmod.Wrap(err, "invalid submitter address")
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	anchor := types.MerkleAnchor{
+		Root:        types.NormalizeAnchorRoot(msg.Root),
+		Submitter:   msg.Submitter,
+		LeafCount:   msg.LeafCount,
+		BlockHeight: sdkCtx.BlockHeight(),
+		Timestamp:   sdkCtx.BlockTime().Unix(),
+	}
+	if err := anchor.Validate(); err != nil {
+		return nil, err
+	}
+
+	has, err := k.Anchors.Has(ctx, anchor.Root)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		return nil, errorsmod.Wrap(types.ErrAnchorExists, anchor.Root)
+	}
+
+	if err := k.Anchors.Set(ctx, anchor.Root, anchor); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgAnchorRootResponse{
+		BlockHeight: anchor.BlockHeight,
+		Timestamp:   anchor.Timestamp,
+	}, nil
+}
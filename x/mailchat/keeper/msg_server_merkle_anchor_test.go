@@ -0,0 +1,49 @@
+package keeper_test
+
+import (
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/keeper"
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+const testRoot = "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9"
+
+func TestMsgAnchorRoot(t *testing.T) {
+	f := initFixture(t)
+	ms := keeper.NewMsgServerImpl(f.keeper)
+	qs := keeper.NewQueryServerImpl(f.keeper)
+
+	submitter, err := f.addressCodec.BytesToString([]byte("submitter_1_________"))
+	require.NoError(t, err)
+
+	blockTime := time.Unix(1700000000, 0)
+	ctx := sdk.UnwrapSDKContext(f.ctx).WithBlockHeight(42).WithBlockTime(blockTime)
+
+	_, err = ms.AnchorRoot(ctx, &types.MsgAnchorRoot{Submitter: submitter, Root: "not-a-hash", LeafCount: 1})
+	require.ErrorIs(t, err, types.ErrInvalidAnchor)
+	_, err = ms.AnchorRoot(ctx, &types.MsgAnchorRoot{Submitter: submitter, Root: testRoot})
+	require.ErrorIs(t, err, types.ErrInvalidAnchor)
+
+	resp, err := ms.AnchorRoot(ctx, &types.MsgAnchorRoot{Submitter: submitter, Root: testRoot, LeafCount: 3})
+	require.NoError(t, err)
+	require.EqualValues(t, 42, resp.BlockHeight)
+	require.Equal(t, blockTime.Unix(), resp.Timestamp)
+
+	// Roots can be anchored only once so the original timestamp is kept.
+	_, err = ms.AnchorRoot(ctx, &types.MsgAnchorRoot{Submitter: submitter, Root: testRoot, LeafCount: 3})
+	require.ErrorIs(t, err, types.ErrAnchorExists)
+
+	got, err := qs.Anchor(ctx, &types.QueryAnchorRequest{Root: testRoot})
+	require.NoError(t, err)
+	require.Equal(t, submitter, got.Anchor.Submitter)
+	require.EqualValues(t, 3, got.Anchor.LeafCount)
+	require.EqualValues(t, 42, got.Anchor.BlockHeight)
+
+	_, err = qs.Anchor(ctx, &types.QueryAnchorRequest{Root: "00" + testRoot[2:]})
+	require.Error(t, err)
+}
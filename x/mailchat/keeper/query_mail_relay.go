@@ -0,0 +1,62 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+
+	"cosmossdk.io/collections"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func (q queryServer) Relay(ctx context.Context, req *types.QueryRelayRequest) (*types.QueryRelayResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	relay, err := q.k.Relays.Get(ctx, types.NormalizeRelayName(req.Hostname))
+	if err != nil {
+		if errors.Is(err, collections.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &types.QueryRelayResponse{Relay: relay}, nil
+}
+
+func (q queryServer) Relays(ctx context.Context, req *types.QueryRelaysRequest) (*types.QueryRelaysResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	relays, pageRes, err := query.CollectionPaginate(
+		ctx,
+		q.k.Relays,
+		req.Pagination,
+		func(_ string, value types.MailRelay) (types.MailRelay, error) {
+			return value, nil
+		},
+	)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &types.QueryRelaysResponse{Relays: relays, Pagination: pageRes}, nil
+}
+
+func (q queryServer) RelaysByDomain(ctx context.Context, req *types.QueryRelaysByDomainRequest) (*types.QueryRelaysByDomainResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	relays, err := q.k.GetRelaysByDomain(ctx, types.NormalizeRelayName(req.Domain))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &types.QueryRelaysByDomainResponse{Relays: relays}, nil
+}
@@ -0,0 +1,41 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+
+	"cosmossdk.io/collections"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dsoftgames/MailChat/x/mailchat/types"
+)
+
+func (q queryServer) MailboxQuota(ctx context.Context, req *types.QueryMailboxQuotaRequest) (*types.QueryMailboxQuotaResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	mailbox := types.NormalizeMailbox(req.Mailbox)
+	if err := types.ValidateMailbox(mailbox); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	params, err := q.k.Params.Get(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	quota, err := q.k.Quotas.Get(ctx, mailbox)
+	if err != nil {
+		if !errors.Is(err, collections.ErrNotFound) {
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		quota = types.MailboxQuota{Mailbox: mailbox}
+	}
+
+	return &types.QueryMailboxQuotaResponse{
+		Quota:      quota,
+		LimitBytes: params.QuotaLimit(quota.PurchasedBytes),
+	}, nil
+}
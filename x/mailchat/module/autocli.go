@@ -17,6 +17,35 @@ func (am AppModule) AutoCLIOptions() *autocliv1.ModuleOptions {
 					Use:       "params",
 					Short:     "Shows the parameters of the module",
 				},
+				{
+					RpcMethod:      "Relay",
+					Use:            "relay [hostname]",
+					Short:          "Shows a registered mail relay",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{{ProtoField: "hostname"}},
+				},
+				{
+					RpcMethod: "Relays",
+					Use:       "relays",
+					Short:     "Lists registered mail relays",
+				},
+				{
+					RpcMethod:      "RelaysByDomain",
+					Use:            "relays-by-domain [domain]",
+					Short:          "Lists mail relays serving a domain",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{{ProtoField: "domain"}},
+				},
+				{
+					RpcMethod:      "Anchor",
+					Use:            "anchor [root]",
+					Short:          "Shows an anchored Merkle root",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{{ProtoField: "root"}},
+				},
+				{
+					RpcMethod:      "MailboxQuota",
+					Use:            "mailbox-quota [mailbox]",
+					Short:          "Shows the storage quota of a mailbox",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{{ProtoField: "mailbox"}},
+				},
 				// this line is used by ignite scaffolding # autocli/query
 			},
 		},
@@ -28,6 +57,40 @@ func (am AppModule) AutoCLIOptions() *autocliv1.ModuleOptions {
 					RpcMethod: "UpdateParams",
 					Skip:      true, // skipped because authority gated
 				},
+				{
+					RpcMethod: "RegisterRelay",
+					Use:       "register-relay [hostname] [tls-fingerprint] [domain]...",
+					Short:     "Register or update a mail relay",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{
+						{ProtoField: "hostname"},
+						{ProtoField: "tls_fingerprint"},
+						{ProtoField: "domains", Varargs: true},
+					},
+				},
+				{
+					RpcMethod:      "RemoveRelay",
+					Use:            "remove-relay [hostname]",
+					Short:          "Remove a mail relay",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{{ProtoField: "hostname"}},
+				},
+				{
+					RpcMethod: "AnchorRoot",
+					Use:       "anchor-root [root] [leaf-count]",
+					Short:     "Anchor the Merkle root of a batch of message hashes",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{
+						{ProtoField: "root"},
+						{ProtoField: "leaf_count"},
+					},
+				},
+				{
+					RpcMethod: "PurchaseQuota",
+					Use:       "purchase-quota [mailbox] [units]",
+					Short:     "Purchase storage quota for a mailbox",
+					PositionalArgs: []*autocliv1.PositionalArgDescriptor{
+						{ProtoField: "mailbox"},
+						{ProtoField: "units"},
+					},
+				},
 				// this line is used by ignite scaffolding # autocli/tx
 			},
 		},
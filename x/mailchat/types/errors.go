@@ -9,4 +9,11 @@ import (
 // x/mailchat module sentinel errors
 var (
 	ErrInvalidSigner = errors.Register(ModuleName, 1100, "expected gov account as only signer for proposal message")
+	ErrInvalidRelay  = errors.Register(ModuleName, 1101, "invalid mail relay")
+	ErrRelayNotFound = errors.Register(ModuleName, 1102, "mail relay not found")
+	ErrRelayOwned    = errors.Register(ModuleName, 1103, "mail relay is owned by another operator")
+	ErrInvalidAnchor = errors.Register(ModuleName, 1104, "invalid merkle anchor")
+	ErrAnchorExists  = errors.Register(ModuleName, 1105, "merkle root is already anchored")
+	ErrAnchorMissing = errors.Register(ModuleName, 1106, "merkle root is not anchored")
+	ErrInvalidQuota  = errors.Register(ModuleName, 1107, "invalid mailbox quota")
 )
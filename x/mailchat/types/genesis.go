@@ -1,14 +1,55 @@
 package types
 
+import "fmt"
+
 // DefaultGenesis returns the default genesis state
 func DefaultGenesis() *GenesisState {
 	return &GenesisState{
-		Params: DefaultParams(),
+		Params:  DefaultParams(),
+		Relays:  []MailRelay{},
+		Anchors: []MerkleAnchor{},
+		Quotas:  []MailboxQuota{},
 	}
 }
 
 // Validate performs basic genesis state validation returning an error upon any
 // failure.
 func (gs GenesisState) Validate() error {
+	hostnames := make(map[string]struct{}, len(gs.Relays))
+	for _, relay := range gs.Relays {
+		if _, ok := hostnames[relay.Hostname]; ok {
+			return fmt.Errorf("duplicated hostname for mail relay: %s", relay.Hostname)
+		}
+		hostnames[relay.Hostname] = struct{}{}
+
+		if err := relay.Validate(); err != nil {
+			return err
+		}
+	}
+
+	roots := make(map[string]struct{}, len(gs.Anchors))
+	for _, anchor := range gs.Anchors {
+		if _, ok := roots[anchor.Root]; ok {
+			return fmt.Errorf("duplicated root for merkle anchor: %s", anchor.Root)
+		}
+		roots[anchor.Root] = struct{}{}
+
+		if err := anchor.Validate(); err != nil {
+			return err
+		}
+	}
+
+	mailboxes := make(map[string]struct{}, len(gs.Quotas))
+	for _, quota := range gs.Quotas {
+		if _, ok := mailboxes[quota.Mailbox]; ok {
+			return fmt.Errorf("duplicated mailbox for mailbox quota: %s", quota.Mailbox)
+		}
+		mailboxes[quota.Mailbox] = struct{}{}
+
+		if err := quota.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return gs.Params.Validate()
 }
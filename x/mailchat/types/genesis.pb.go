@@ -28,6 +28,12 @@ const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 type GenesisState struct {
 	// params defines all the parameters of the module.
 	Params Params `protobuf:"bytes,1,opt,name=params,proto3" json:"params"`
+	// relays defines the mail relays registered at genesis.
+	Relays []MailRelay `protobuf:"bytes,2,rep,name=relays,proto3" json:"relays"`
+	// anchors defines the Merkle roots anchored at genesis.
+	Anchors []MerkleAnchor `protobuf:"bytes,3,rep,name=anchors,proto3" json:"anchors"`
+	// quotas defines the mailbox quotas purchased at genesis.
+	Quotas []MailboxQuota `protobuf:"bytes,4,rep,name=quotas,proto3" json:"quotas"`
 }
 
 func (m *GenesisState) Reset()         { *m = GenesisState{} }
@@ -70,6 +76,27 @@ func (m *GenesisState) GetParams() Params {
 	return Params{}
 }
 
+func (m *GenesisState) GetRelays() []MailRelay {
+	if m != nil {
+		return m.Relays
+	}
+	return nil
+}
+
+func (m *GenesisState) GetAnchors() []MerkleAnchor {
+	if m != nil {
+		return m.Anchors
+	}
+	return nil
+}
+
+func (m *GenesisState) GetQuotas() []MailboxQuota {
+	if m != nil {
+		return m.Quotas
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*GenesisState)(nil), "mailchat.mailchat.v1.GenesisState")
 }
@@ -79,21 +106,28 @@ func init() {
 }
 
 var fileDescriptor_738068e19686ade0 = []byte{
-	// 216 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x52, 0xca, 0x4d, 0xcc, 0xcc,
-	0x49, 0xce, 0x48, 0x2c, 0xd1, 0x87, 0x33, 0xca, 0x0c, 0xf5, 0xd3, 0x53, 0xf3, 0x52, 0x8b, 0x33,
-	0x8b, 0xf5, 0x0a, 0x8a, 0xf2, 0x4b, 0xf2, 0x85, 0x44, 0x60, 0x52, 0x7a, 0x70, 0x46, 0x99, 0xa1,
-	0x94, 0x60, 0x62, 0x6e, 0x66, 0x5e, 0xbe, 0x3e, 0x98, 0x84, 0x28, 0x94, 0x12, 0x49, 0xcf, 0x4f,
-	0xcf, 0x07, 0x33, 0xf5, 0x41, 0x2c, 0xa8, 0xa8, 0x22, 0x56, 0x2b, 0x0a, 0x12, 0x8b, 0x12, 0x73,
-	0xa1, 0x36, 0x28, 0xf9, 0x73, 0xf1, 0xb8, 0x43, 0xac, 0x0c, 0x2e, 0x49, 0x2c, 0x49, 0x15, 0xb2,
-	0xe7, 0x62, 0x83, 0xc8, 0x4b, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x1b, 0xc9, 0xe8, 0x61, 0x73, 0x82,
-	0x5e, 0x00, 0x58, 0x8d, 0x13, 0xe7, 0x89, 0x7b, 0xf2, 0x0c, 0x2b, 0x9e, 0x6f, 0xd0, 0x62, 0x0c,
-	0x82, 0x6a, 0x73, 0xf2, 0x3c, 0xf1, 0x48, 0x8e, 0xf1, 0xc2, 0x23, 0x39, 0xc6, 0x07, 0x8f, 0xe4,
-	0x18, 0x27, 0x3c, 0x96, 0x63, 0xb8, 0xf0, 0x58, 0x8e, 0xe1, 0xc6, 0x63, 0x39, 0x86, 0x28, 0xfd,
-	0xf4, 0xcc, 0x92, 0x8c, 0xd2, 0x24, 0xbd, 0xe4, 0xfc, 0x5c, 0xfd, 0x94, 0xe2, 0xfc, 0xb4, 0x92,
-	0xf4, 0xc4, 0xdc, 0xd4, 0x62, 0x7d, 0xdf, 0xc4, 0xcc, 0x1c, 0x67, 0x90, 0xd3, 0x2a, 0x10, 0xae,
-	0x2c, 0xa9, 0x2c, 0x48, 0x2d, 0x4e, 0x62, 0x03, 0x3b, 0xd1, 0x18, 0x10, 0x00, 0x00, 0xff, 0xff,
-	0x79, 0x7f, 0xcd, 0xe9, 0x2a, 0x01, 0x00, 0x00,
+	// 334 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x90, 0x3f, 0x4f, 0xf2, 0x40,
+	0x00, 0xc6, 0x7b, 0x40, 0xfa, 0xe6, 0x3d, 0x5c, 0x6c, 0x18, 0x1a, 0x62, 0x0e, 0x24, 0x31, 0x21,
+	0x0e, 0xbd, 0x80, 0xb3, 0x51, 0xeb, 0x60, 0x1c, 0x4c, 0x14, 0x37, 0x17, 0x72, 0xe0, 0x59, 0x1a,
+	0x5b, 0xae, 0xf6, 0x0e, 0x02, 0xdf, 0xc2, 0xc1, 0x0f, 0xe1, 0xe8, 0xc7, 0x60, 0x64, 0x74, 0x32,
+	0xa6, 0x1d, 0xfc, 0x1a, 0xe6, 0xfe, 0x14, 0x97, 0xc2, 0xd2, 0x3c, 0xe9, 0xfd, 0x9e, 0xe7, 0xee,
+	0x79, 0x60, 0x27, 0x26, 0x61, 0x34, 0x9e, 0x10, 0x81, 0x37, 0x62, 0xde, 0xc3, 0x01, 0x9d, 0x52,
+	0x1e, 0x72, 0x2f, 0x49, 0x99, 0x60, 0x4e, 0xa3, 0x38, 0xf2, 0x36, 0x62, 0xde, 0x6b, 0xee, 0x93,
+	0x38, 0x9c, 0x32, 0xac, 0xbe, 0x1a, 0x6c, 0x36, 0x02, 0x16, 0x30, 0x25, 0xb1, 0x54, 0xe6, 0xef,
+	0x51, 0xe9, 0x15, 0x52, 0x0f, 0x53, 0x1a, 0x91, 0xa5, 0xc1, 0xba, 0x5b, 0xb1, 0x11, 0x5b, 0x0c,
+	0x5f, 0x66, 0x4c, 0x90, 0xdd, 0x24, 0x4d, 0x9f, 0x23, 0x3a, 0x24, 0xd3, 0xf1, 0x84, 0xa5, 0x86,
+	0x3c, 0x2c, 0x25, 0x13, 0x92, 0x92, 0xd8, 0x94, 0xeb, 0xbc, 0x55, 0xe0, 0xde, 0x95, 0xae, 0x7b,
+	0x2f, 0x88, 0xa0, 0xce, 0x19, 0xb4, 0x35, 0xe0, 0x82, 0x36, 0xe8, 0xd6, 0xfb, 0x07, 0x5e, 0x59,
+	0x7d, 0xef, 0x56, 0x31, 0xfe, 0xff, 0xd5, 0x57, 0xcb, 0x7a, 0xff, 0xf9, 0x38, 0x06, 0x03, 0x63,
+	0x73, 0x4e, 0xa1, 0xad, 0x7a, 0x71, 0xb7, 0xd2, 0xae, 0x76, 0xeb, 0xfd, 0x56, 0x79, 0xc0, 0x0d,
+	0x09, 0xa3, 0x81, 0xe4, 0xfc, 0x9a, 0xcc, 0x18, 0x18, 0x93, 0xe3, 0xc3, 0x7f, 0xba, 0x03, 0x77,
+	0xab, 0xca, 0xdf, 0xd9, 0xe2, 0x57, 0x7d, 0x2f, 0x14, 0x6a, 0x22, 0x0a, 0xa3, 0x73, 0x0e, 0x6d,
+	0x35, 0x18, 0x77, 0x6b, 0x3b, 0x23, 0xf4, 0xb8, 0x77, 0x12, 0x2d, 0x5e, 0xa1, 0x7d, 0xfe, 0xf5,
+	0x2a, 0x43, 0x60, 0x9d, 0x21, 0xf0, 0x9d, 0x21, 0xf0, 0x9a, 0x23, 0x6b, 0x9d, 0x23, 0xeb, 0x33,
+	0x47, 0xd6, 0x03, 0x0e, 0x42, 0x31, 0x99, 0x8d, 0xbc, 0x31, 0x8b, 0xf1, 0x23, 0x67, 0x4f, 0x22,
+	0x20, 0x31, 0xe5, 0x58, 0x66, 0x5d, 0xca, 0x81, 0x17, 0x7f, 0x5b, 0x8b, 0x65, 0x42, 0xf9, 0xc8,
+	0x56, 0x43, 0x9f, 0xfc, 0x0e, 0x00, 0xa7, 0x3f, 0x4f, 0x73, 0x6b, 0x02, 0x00, 0x00,
 }
 
 func (m *GenesisState) Marshal() (dAtA []byte, err error) {
@@ -116,6 +150,48 @@ func (m *GenesisState) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Quotas) > 0 {
+		for iNdEx := len(m.Quotas) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Quotas[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintGenesis(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if len(m.Anchors) > 0 {
+		for iNdEx := len(m.Anchors) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Anchors[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintGenesis(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Relays) > 0 {
+		for iNdEx := len(m.Relays) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Relays[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintGenesis(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
 	{
 		size, err := m.Params.MarshalToSizedBuffer(dAtA[:i])
 		if err != nil {
@@ -148,6 +224,24 @@ func (m *GenesisState) Size() (n int) {
 	_ = l
 	l = m.Params.Size()
 	n += 1 + l + sovGenesis(uint64(l))
+	if len(m.Relays) > 0 {
+		for _, e := range m.Relays {
+			l = e.Size()
+			n += 1 + l + sovGenesis(uint64(l))
+		}
+	}
+	if len(m.Anchors) > 0 {
+		for _, e := range m.Anchors {
+			l = e.Size()
+			n += 1 + l + sovGenesis(uint64(l))
+		}
+	}
+	if len(m.Quotas) > 0 {
+		for _, e := range m.Quotas {
+			l = e.Size()
+			n += 1 + l + sovGenesis(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -219,6 +313,108 @@ func (m *GenesisState) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Relays", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenesis
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthGenesis
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenesis
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Relays = append(m.Relays, MailRelay{})
+			if err := m.Relays[len(m.Relays)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Anchors", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenesis
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthGenesis
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenesis
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Anchors = append(m.Anchors, MerkleAnchor{})
+			if err := m.Anchors[len(m.Anchors)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Quotas", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenesis
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthGenesis
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenesis
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Quotas = append(m.Quotas, MailboxQuota{})
+			if err := m.Quotas[len(m.Quotas)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipGenesis(dAtA[iNdEx:])
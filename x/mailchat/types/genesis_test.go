@@ -23,6 +23,104 @@ func TestGenesisState_Validate(t *testing.T) {
 			genState: &types.GenesisState{},
 			valid:    true,
 		},
+		{
+			desc: "valid relays",
+			genState: &types.GenesisState{
+				Relays: []types.MailRelay{
+					{
+						Hostname:       "mx.example.org",
+						TlsFingerprint: "a9b5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf",
+						Domains:        []string{"example.org"},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			desc: "duplicated relay",
+			genState: &types.GenesisState{
+				Relays: []types.MailRelay{
+					{
+						Hostname:       "mx.example.org",
+						TlsFingerprint: "a9b5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf",
+						Domains:        []string{"example.org"},
+					},
+					{
+						Hostname:       "mx.example.org",
+						TlsFingerprint: "a9b5cb4d02f996f6385debe9a8952f1af1f4aec7eae0f37c2cd6d0d8ee8391cf",
+						Domains:        []string{"example.com"},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			desc: "valid anchors",
+			genState: &types.GenesisState{
+				Anchors: []types.MerkleAnchor{
+					{
+						Root:      "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9",
+						LeafCount: 3,
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			desc: "valid quotas",
+			genState: &types.GenesisState{
+				Quotas: []types.MailboxQuota{
+					{Mailbox: "user@example.org", PurchasedBytes: 1 << 30},
+				},
+			},
+			valid: true,
+		},
+		{
+			desc: "duplicated quota",
+			genState: &types.GenesisState{
+				Quotas: []types.MailboxQuota{
+					{Mailbox: "user@example.org", PurchasedBytes: 1 << 30},
+					{Mailbox: "user@example.org", PurchasedBytes: 1 << 20},
+				},
+			},
+			valid: false,
+		},
+		{
+			desc: "invalid quota mailbox",
+			genState: &types.GenesisState{
+				Quotas: []types.MailboxQuota{
+					{Mailbox: "User@example.org", PurchasedBytes: 1 << 30},
+				},
+			},
+			valid: false,
+		},
+		{
+			desc: "duplicated anchor",
+			genState: &types.GenesisState{
+				Anchors: []types.MerkleAnchor{
+					{
+						Root:      "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9",
+						LeafCount: 3,
+					},
+					{
+						Root:      "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9",
+						LeafCount: 1,
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			desc: "empty anchor",
+			genState: &types.GenesisState{
+				Anchors: []types.MerkleAnchor{
+					{
+						Root: "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9",
+					},
+				},
+			},
+			valid: false,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -15,5 +15,19 @@ const (
 	GovModuleName = "gov"
 )
 
-// ParamsKey is the prefix to retrieve all Params
-var ParamsKey = collections.NewPrefix("p_mailchat")
+var (
+	// ParamsKey is the prefix to retrieve all Params
+	ParamsKey = collections.NewPrefix("p_mailchat")
+
+	// RelayKey is the prefix to retrieve all MailRelay entries by hostname
+	RelayKey = collections.NewPrefix("relay/value/")
+
+	// RelayDomainKey is the prefix of the domain -> relay hostname index
+	RelayDomainKey = collections.NewPrefix("relay/domain/")
+
+	// AnchorKey is the prefix to retrieve all MerkleAnchor entries by root
+	AnchorKey = collections.NewPrefix("anchor/value/")
+
+	// QuotaKey is the prefix to retrieve all MailboxQuota entries by mailbox
+	QuotaKey = collections.NewPrefix("quota/value/")
+)
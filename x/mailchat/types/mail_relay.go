@@ -0,0 +1,64 @@
+package types
+
+import (
+	"encoding/hex"
+	"strings"
+
+	errorsmod "cosmossdk.io/errors"
+)
+
+// NormalizeRelayName converts a relay hostname or a served domain to the form
+// used as a store key: lower-case and without the trailing dot.
+func NormalizeRelayName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// validateRelayName performs a basic syntax check of a DNS name.
+func validateRelayName(name string) error {
+	if name == "" || len(name) > 253 {
+		return errorsmod.Wrapf(ErrInvalidRelay, "invalid name length: %q", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return errorsmod.Wrapf(ErrInvalidRelay, "invalid name: %q", name)
+		}
+		for _, ch := range label {
+			if (ch < 'a' || ch > 'z') && (ch < '0' || ch > '9') && ch != '-' {
+				return errorsmod.Wrapf(ErrInvalidRelay, "invalid character in name: %q", name)
+			}
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return errorsmod.Wrapf(ErrInvalidRelay, "invalid name: %q", name)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the relay entry is well-formed. Names are expected to
+// be normalized using NormalizeRelayName.
+func (r MailRelay) Validate() error {
+	if err := validateRelayName(r.Hostname); err != nil {
+		return err
+	}
+
+	fp, err := hex.DecodeString(r.TlsFingerprint)
+	if err != nil || len(fp) != 32 || strings.ToLower(r.TlsFingerprint) != r.TlsFingerprint {
+		return errorsmod.Wrap(ErrInvalidRelay, "tls fingerprint should be a lower-case hex-encoded SHA-256 hash")
+	}
+
+	if len(r.Domains) == 0 {
+		return errorsmod.Wrap(ErrInvalidRelay, "at least one domain is required")
+	}
+	seen := make(map[string]struct{}, len(r.Domains))
+	for _, domain := range r.Domains {
+		if err := validateRelayName(domain); err != nil {
+			return err
+		}
+		if _, ok := seen[domain]; ok {
+			return errorsmod.Wrapf(ErrInvalidRelay, "duplicate domain: %s", domain)
+		}
+		seen[domain] = struct{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"strings"
+
+	errorsmod "cosmossdk.io/errors"
+)
+
+// NormalizeMailbox converts a mailbox address to the form used as a store
+// key: lower-case and without surrounding whitespace.
+func NormalizeMailbox(mailbox string) string {
+	return strings.ToLower(strings.TrimSpace(mailbox))
+}
+
+// ValidateMailbox performs a basic syntax check of a normalized mailbox
+// address.
+func ValidateMailbox(mailbox string) error {
+	if len(mailbox) > 320 {
+		return errorsmod.Wrapf(ErrInvalidQuota, "mailbox address is too long: %q", mailbox)
+	}
+	localPart, domain, ok := strings.Cut(mailbox, "@")
+	if !ok || localPart == "" || strings.Contains(domain, "@") {
+		return errorsmod.Wrapf(ErrInvalidQuota, "invalid mailbox address: %q", mailbox)
+	}
+	if strings.ToLower(mailbox) != mailbox || strings.ContainsAny(mailbox, " \t\r\n") {
+		return errorsmod.Wrapf(ErrInvalidQuota, "invalid mailbox address: %q", mailbox)
+	}
+	return validateRelayName(domain)
+}
+
+// Validate checks that the quota entry is well-formed.
+func (q MailboxQuota) Validate() error {
+	if err := ValidateMailbox(q.Mailbox); err != nil {
+		return err
+	}
+	if q.PurchasedBytes == 0 {
+		return errorsmod.Wrap(ErrInvalidQuota, "purchased storage should be positive")
+	}
+	return nil
+}
+
+// QuotaLimit returns the effective storage quota of a mailbox that purchased
+// the specified amount of storage.
+func (p Params) QuotaLimit(purchasedBytes uint64) uint64 {
+	limit := p.DefaultQuotaBytes + purchasedBytes
+	if limit < purchasedBytes {
+		// Overflow, treat as unlimited.
+		return ^uint64(0)
+	}
+	return limit
+}
@@ -0,0 +1,358 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: mailchat/mailchat/v1/mailbox_quota.proto
+
+package types
+
+import (
+	fmt "fmt"
+	proto "github.com/cosmos/gogoproto/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// MailboxQuota records the storage quota purchased for a mailbox.
+type MailboxQuota struct {
+	// mailbox is the lower-case e-mail address of the mailbox. It uniquely
+	// identifies the entry.
+	Mailbox string `protobuf:"bytes,1,opt,name=mailbox,proto3" json:"mailbox,omitempty"`
+	// purchased_bytes is the total amount of storage purchased for the
+	// mailbox. It is added to the default quota.
+	PurchasedBytes uint64 `protobuf:"varint,2,opt,name=purchased_bytes,json=purchasedBytes,proto3" json:"purchased_bytes,omitempty"`
+}
+
+func (m *MailboxQuota) Reset()         { *m = MailboxQuota{} }
+func (m *MailboxQuota) String() string { return proto.CompactTextString(m) }
+func (*MailboxQuota) ProtoMessage()    {}
+func (*MailboxQuota) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5e13dcd1585a23a1, []int{0}
+}
+func (m *MailboxQuota) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MailboxQuota) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MailboxQuota.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MailboxQuota) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MailboxQuota.Merge(m, src)
+}
+func (m *MailboxQuota) XXX_Size() int {
+	return m.Size()
+}
+func (m *MailboxQuota) XXX_DiscardUnknown() {
+	xxx_messageInfo_MailboxQuota.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MailboxQuota proto.InternalMessageInfo
+
+func (m *MailboxQuota) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+func (m *MailboxQuota) GetPurchasedBytes() uint64 {
+	if m != nil {
+		return m.PurchasedBytes
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*MailboxQuota)(nil), "mailchat.mailchat.v1.MailboxQuota")
+}
+
+func init() {
+	proto.RegisterFile("mailchat/mailchat/v1/mailbox_quota.proto", fileDescriptor_5e13dcd1585a23a1)
+}
+
+var fileDescriptor_5e13dcd1585a23a1 = []byte{
+	// 188 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xd2, 0xc8, 0x4d, 0xcc, 0xcc,
+	0x49, 0xce, 0x48, 0x2c, 0xd1, 0x87, 0x33, 0xca, 0x0c, 0xc1, 0xec, 0xa4, 0xfc, 0x8a, 0xf8, 0xc2,
+	0xd2, 0xfc, 0x92, 0x44, 0xbd, 0x82, 0xa2, 0xfc, 0x92, 0x7c, 0x21, 0x11, 0x98, 0x02, 0x3d, 0x38,
+	0xa3, 0xcc, 0x50, 0x29, 0x90, 0x8b, 0xc7, 0x17, 0xa2, 0x38, 0x10, 0xa4, 0x56, 0x48, 0x82, 0x8b,
+	0x1d, 0xaa, 0x59, 0x82, 0x51, 0x81, 0x51, 0x83, 0x33, 0x08, 0xc6, 0x15, 0x52, 0xe7, 0xe2, 0x2f,
+	0x28, 0x2d, 0x4a, 0xce, 0x48, 0x2c, 0x4e, 0x4d, 0x89, 0x4f, 0xaa, 0x2c, 0x49, 0x2d, 0x96, 0x60,
+	0x52, 0x60, 0xd4, 0x60, 0x09, 0xe2, 0x83, 0x0b, 0x3b, 0x81, 0x44, 0x9d, 0x3c, 0x4f, 0x3c, 0x92,
+	0x63, 0xbc, 0xf0, 0x48, 0x8e, 0xf1, 0xc1, 0x23, 0x39, 0xc6, 0x09, 0x8f, 0xe5, 0x18, 0x2e, 0x3c,
+	0x96, 0x63, 0xb8, 0xf1, 0x58, 0x8e, 0x21, 0x4a, 0x3f, 0x3d, 0xb3, 0x24, 0xa3, 0x34, 0x49, 0x2f,
+	0x39, 0x3f, 0x57, 0x3f, 0xa5, 0x38, 0x3f, 0xad, 0x24, 0x3d, 0x31, 0x37, 0xb5, 0x58, 0x1f, 0xe4,
+	0x00, 0x67, 0x90, 0xcb, 0x2b, 0x10, 0x9e, 0x28, 0xa9, 0x2c, 0x48, 0x2d, 0x4e, 0x62, 0x03, 0x3b,
+	0xdd, 0x18, 0x30, 0x00, 0x2f, 0xcc, 0x76, 0x58, 0xe6, 0x00, 0x00, 0x00,
+}
+
+func (m *MailboxQuota) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MailboxQuota) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MailboxQuota) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.PurchasedBytes != 0 {
+		i = encodeVarintMailboxQuota(dAtA, i, uint64(m.PurchasedBytes))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Mailbox) > 0 {
+		i -= len(m.Mailbox)
+		copy(dAtA[i:], m.Mailbox)
+		i = encodeVarintMailboxQuota(dAtA, i, uint64(len(m.Mailbox)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintMailboxQuota(dAtA []byte, offset int, v uint64) int {
+	offset -= sovMailboxQuota(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *MailboxQuota) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Mailbox)
+	if l > 0 {
+		n += 1 + l + sovMailboxQuota(uint64(l))
+	}
+	if m.PurchasedBytes != 0 {
+		n += 1 + sovMailboxQuota(uint64(m.PurchasedBytes))
+	}
+	return n
+}
+
+func sovMailboxQuota(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozMailboxQuota(x uint64) (n int) {
+	return sovMailboxQuota(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *MailboxQuota) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowMailboxQuota
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MailboxQuota: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MailboxQuota: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mailbox", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMailboxQuota
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMailboxQuota
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMailboxQuota
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Mailbox = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PurchasedBytes", wireType)
+			}
+			m.PurchasedBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMailboxQuota
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PurchasedBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipMailboxQuota(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthMailboxQuota
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipMailboxQuota(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowMailboxQuota
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowMailboxQuota
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowMailboxQuota
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthMailboxQuota
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupMailboxQuota
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthMailboxQuota
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthMailboxQuota        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowMailboxQuota          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupMailboxQuota = fmt.Errorf("proto: unexpected end of group")
+)
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"encoding/hex"
+	"strings"
+
+	errorsmod "cosmossdk.io/errors"
+)
+
+// NormalizeAnchorRoot converts a Merkle root to the form used as a store key:
+// lower-case hex without surrounding whitespace.
+func NormalizeAnchorRoot(root string) string {
+	return strings.ToLower(strings.TrimSpace(root))
+}
+
+// ValidateAnchorRoot checks that root is a lower-case hex-encoded SHA-256
+// hash.
+func ValidateAnchorRoot(root string) error {
+	raw, err := hex.DecodeString(root)
+	if err != nil || len(raw) != 32 || strings.ToLower(root) != root {
+		return errorsmod.Wrap(ErrInvalidAnchor, "root should be a lower-case hex-encoded SHA-256 hash")
+	}
+	return nil
+}
+
+// Validate checks that the anchor entry is well-formed.
+func (a MerkleAnchor) Validate() error {
+	if err := ValidateAnchorRoot(a.Root); err != nil {
+		return err
+	}
+	if a.LeafCount == 0 {
+		return errorsmod.Wrap(ErrInvalidAnchor, "leaf count should be positive")
+	}
+	return nil
+}
@@ -0,0 +1,491 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: mailchat/mailchat/v1/merkle_anchor.proto
+
+package types
+
+import (
+	fmt "fmt"
+	_ "github.com/cosmos/cosmos-proto"
+	proto "github.com/cosmos/gogoproto/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// MerkleAnchor records the root of a Merkle tree built over the hashes of a
+// batch of messages accepted by a mail server.
+type MerkleAnchor struct {
+	// root is the hex-encoded SHA-256 Merkle root. It uniquely identifies the
+	// entry.
+	Root string `protobuf:"bytes,1,opt,name=root,proto3" json:"root,omitempty"`
+	// submitter is the account that anchored the root.
+	Submitter string `protobuf:"bytes,2,opt,name=submitter,proto3" json:"submitter,omitempty"`
+	// leaf_count is the number of message hashes covered by the root.
+	LeafCount uint64 `protobuf:"varint,3,opt,name=leaf_count,json=leafCount,proto3" json:"leaf_count,omitempty"`
+	// block_height is the height of the block that included the anchor.
+	BlockHeight int64 `protobuf:"varint,4,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+	// timestamp is the block time (Unix seconds) of the block that included
+	// the anchor.
+	Timestamp int64 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (m *MerkleAnchor) Reset()         { *m = MerkleAnchor{} }
+func (m *MerkleAnchor) String() string { return proto.CompactTextString(m) }
+func (*MerkleAnchor) ProtoMessage()    {}
+func (*MerkleAnchor) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb64c7788104b5bb, []int{0}
+}
+func (m *MerkleAnchor) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MerkleAnchor) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MerkleAnchor.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MerkleAnchor) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MerkleAnchor.Merge(m, src)
+}
+func (m *MerkleAnchor) XXX_Size() int {
+	return m.Size()
+}
+func (m *MerkleAnchor) XXX_DiscardUnknown() {
+	xxx_messageInfo_MerkleAnchor.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MerkleAnchor proto.InternalMessageInfo
+
+func (m *MerkleAnchor) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
+}
+
+func (m *MerkleAnchor) GetSubmitter() string {
+	if m != nil {
+		return m.Submitter
+	}
+	return ""
+}
+
+func (m *MerkleAnchor) GetLeafCount() uint64 {
+	if m != nil {
+		return m.LeafCount
+	}
+	return 0
+}
+
+func (m *MerkleAnchor) GetBlockHeight() int64 {
+	if m != nil {
+		return m.BlockHeight
+	}
+	return 0
+}
+
+func (m *MerkleAnchor) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*MerkleAnchor)(nil), "mailchat.mailchat.v1.MerkleAnchor")
+}
+
+func init() {
+	proto.RegisterFile("mailchat/mailchat/v1/merkle_anchor.proto", fileDescriptor_fb64c7788104b5bb)
+}
+
+var fileDescriptor_fb64c7788104b5bb = []byte{
+	// 289 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x44, 0x90, 0xb1, 0x4e, 0xf3, 0x30,
+	0x14, 0x85, 0xeb, 0xbf, 0xfd, 0x91, 0x62, 0x3a, 0x59, 0x1d, 0x0c, 0x02, 0xab, 0x30, 0x65, 0xa1,
+	0x56, 0x85, 0xc4, 0xde, 0x76, 0x81, 0xa1, 0x4b, 0xd8, 0x58, 0x22, 0xc7, 0x75, 0x13, 0xab, 0x71,
+	0x5d, 0xd9, 0xb7, 0x15, 0xbc, 0x05, 0x0f, 0xc3, 0xc4, 0x13, 0x30, 0x56, 0x4c, 0x8c, 0x28, 0x79,
+	0x11, 0x14, 0x17, 0x9a, 0xed, 0xdc, 0xef, 0x9c, 0x7b, 0x86, 0x83, 0x63, 0x23, 0x74, 0x29, 0x0b,
+	0x01, 0xfc, 0x28, 0x76, 0x63, 0x6e, 0x94, 0x5b, 0x95, 0x2a, 0x15, 0x6b, 0x59, 0x58, 0x37, 0xda,
+	0x38, 0x0b, 0x96, 0x0c, 0xfe, 0x02, 0xa3, 0xa3, 0xd8, 0x8d, 0xcf, 0xcf, 0xa4, 0xf5, 0xc6, 0xfa,
+	0x34, 0x64, 0xf8, 0xe1, 0x38, 0x3c, 0x5c, 0xbf, 0x23, 0xdc, 0x9f, 0x87, 0xa2, 0x49, 0xe8, 0x21,
+	0x04, 0xf7, 0x9c, 0xb5, 0x40, 0xd1, 0x10, 0xc5, 0x51, 0x12, 0x34, 0xb9, 0xc3, 0x91, 0xdf, 0x66,
+	0x46, 0x03, 0x28, 0x47, 0xff, 0x35, 0xc6, 0x94, 0x7e, 0xbe, 0xdd, 0x0c, 0x7e, 0x9b, 0x26, 0x8b,
+	0x85, 0x53, 0xde, 0x3f, 0x82, 0xd3, 0xeb, 0x3c, 0x69, 0xa3, 0xe4, 0x12, 0xe3, 0x52, 0x89, 0x65,
+	0x2a, 0xed, 0x76, 0x0d, 0xb4, 0x3b, 0x44, 0x71, 0x2f, 0x89, 0x1a, 0x32, 0x6b, 0x00, 0xb9, 0xc2,
+	0xfd, 0xac, 0xb4, 0x72, 0x95, 0x16, 0x4a, 0xe7, 0x05, 0xd0, 0xde, 0x10, 0xc5, 0xdd, 0xe4, 0x34,
+	0xb0, 0xfb, 0x80, 0xc8, 0x05, 0x8e, 0x40, 0x1b, 0xe5, 0x41, 0x98, 0x0d, 0xfd, 0x1f, 0xfc, 0x16,
+	0x4c, 0x1f, 0x3e, 0x2a, 0x86, 0xf6, 0x15, 0x43, 0xdf, 0x15, 0x43, 0xaf, 0x35, 0xeb, 0xec, 0x6b,
+	0xd6, 0xf9, 0xaa, 0x59, 0xe7, 0x89, 0xe7, 0x1a, 0x8a, 0x6d, 0x36, 0x92, 0xd6, 0xf0, 0x85, 0xb7,
+	0x4b, 0xc8, 0x85, 0x51, 0x9e, 0xcf, 0x85, 0x2e, 0x67, 0xcd, 0x7c, 0xcf, 0xed, 0x92, 0xf0, 0xb2,
+	0x51, 0x3e, 0x3b, 0x09, 0x73, 0xdc, 0xfe, 0x0c, 0x00, 0x70, 0xb3, 0xd8, 0x92, 0x6b, 0x01, 0x00,
+	0x00,
+}
+
+func (m *MerkleAnchor) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MerkleAnchor) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MerkleAnchor) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Timestamp != 0 {
+		i = encodeVarintMerkleAnchor(dAtA, i, uint64(m.Timestamp))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.BlockHeight != 0 {
+		i = encodeVarintMerkleAnchor(dAtA, i, uint64(m.BlockHeight))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.LeafCount != 0 {
+		i = encodeVarintMerkleAnchor(dAtA, i, uint64(m.LeafCount))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Submitter) > 0 {
+		i -= len(m.Submitter)
+		copy(dAtA[i:], m.Submitter)
+		i = encodeVarintMerkleAnchor(dAtA, i, uint64(len(m.Submitter)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Root) > 0 {
+		i -= len(m.Root)
+		copy(dAtA[i:], m.Root)
+		i = encodeVarintMerkleAnchor(dAtA, i, uint64(len(m.Root)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintMerkleAnchor(dAtA []byte, offset int, v uint64) int {
+	offset -= sovMerkleAnchor(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *MerkleAnchor) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Root)
+	if l > 0 {
+		n += 1 + l + sovMerkleAnchor(uint64(l))
+	}
+	l = len(m.Submitter)
+	if l > 0 {
+		n += 1 + l + sovMerkleAnchor(uint64(l))
+	}
+	if m.LeafCount != 0 {
+		n += 1 + sovMerkleAnchor(uint64(m.LeafCount))
+	}
+	if m.BlockHeight != 0 {
+		n += 1 + sovMerkleAnchor(uint64(m.BlockHeight))
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sovMerkleAnchor(uint64(m.Timestamp))
+	}
+	return n
+}
+
+func sovMerkleAnchor(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozMerkleAnchor(x uint64) (n int) {
+	return sovMerkleAnchor(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *MerkleAnchor) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowMerkleAnchor
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MerkleAnchor: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MerkleAnchor: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Root", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMerkleAnchor
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMerkleAnchor
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Root = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Submitter", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMerkleAnchor
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMerkleAnchor
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Submitter = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeafCount", wireType)
+			}
+			m.LeafCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LeafCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockHeight", wireType)
+			}
+			m.BlockHeight = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BlockHeight |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			m.Timestamp = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Timestamp |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipMerkleAnchor(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthMerkleAnchor
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipMerkleAnchor(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowMerkleAnchor
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowMerkleAnchor
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthMerkleAnchor
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupMerkleAnchor
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthMerkleAnchor
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthMerkleAnchor        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowMerkleAnchor          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupMerkleAnchor = fmt.Errorf("proto: unexpected end of group")
+)
@@ -1,17 +1,53 @@
 package types
 
+import (
+	errorsmod "cosmossdk.io/errors"
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const (
+	// DefaultQuotaUnitBytes is the storage granted by one quota unit (1 GiB).
+	DefaultQuotaUnitBytes uint64 = 1 << 30
+
+	// DefaultQuotaBytes is the free storage of every mailbox (1 GiB).
+	DefaultQuotaBytes uint64 = 1 << 30
+
+	// DefaultQuotaPriceAmount is the price of one quota unit in the bond
+	// denomination.
+	DefaultQuotaPriceAmount int64 = 1000000
+)
+
 // NewParams creates a new Params instance.
-func NewParams() Params {
-	return Params{}
+func NewParams(quotaPrice sdk.Coin, quotaUnitBytes, defaultQuotaBytes uint64, quotaPaymentRecipient string) Params {
+	return Params{
+		QuotaPrice:            quotaPrice,
+		QuotaUnitBytes:        quotaUnitBytes,
+		DefaultQuotaBytes:     defaultQuotaBytes,
+		QuotaPaymentRecipient: quotaPaymentRecipient,
+	}
 }
 
 // DefaultParams returns a default set of parameters.
 func DefaultParams() Params {
-	return NewParams()
+	quotaPrice := sdk.NewCoin(sdk.DefaultBondDenom, math.NewInt(DefaultQuotaPriceAmount))
+	return NewParams(quotaPrice, DefaultQuotaUnitBytes, DefaultQuotaBytes, "")
 }
 
 // Validate validates the set of params.
+//
+// Zero quota_unit_bytes disables quota purchases.
 func (p Params) Validate() error {
+	if p.QuotaPrice.Denom != "" || !p.QuotaPrice.Amount.IsNil() {
+		if err := p.QuotaPrice.Validate(); err != nil {
+			return errorsmod.Wrap(err, "invalid quota price")
+		}
+	}
+	if p.QuotaPaymentRecipient != "" {
+		if _, err := sdk.AccAddressFromBech32(p.QuotaPaymentRecipient); err != nil {
+			return errorsmod.Wrap(err, "invalid quota payment recipient")
+		}
+	}
 
 	return nil
 }
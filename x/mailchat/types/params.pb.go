@@ -5,6 +5,8 @@ package types
 
 import (
 	fmt "fmt"
+	_ "github.com/cosmos/cosmos-proto"
+	types "github.com/cosmos/cosmos-sdk/types"
 	_ "github.com/cosmos/cosmos-sdk/types/tx/amino"
 	_ "github.com/cosmos/gogoproto/gogoproto"
 	proto "github.com/cosmos/gogoproto/proto"
@@ -26,6 +28,17 @@ const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 // Params defines the parameters for the module.
 type Params struct {
+	// quota_price is the price of one unit of mailbox storage quota.
+	QuotaPrice types.Coin `protobuf:"bytes,1,opt,name=quota_price,json=quotaPrice,proto3" json:"quota_price"`
+	// quota_unit_bytes is the amount of storage (in bytes) granted by one
+	// purchased unit.
+	QuotaUnitBytes uint64 `protobuf:"varint,2,opt,name=quota_unit_bytes,json=quotaUnitBytes,proto3" json:"quota_unit_bytes,omitempty"`
+	// default_quota_bytes is the storage quota every mailbox has without
+	// purchases.
+	DefaultQuotaBytes uint64 `protobuf:"varint,3,opt,name=default_quota_bytes,json=defaultQuotaBytes,proto3" json:"default_quota_bytes,omitempty"`
+	// quota_payment_recipient is the account that receives quota payments.
+	// Payments are burned if it is empty.
+	QuotaPaymentRecipient string `protobuf:"bytes,4,opt,name=quota_payment_recipient,json=quotaPaymentRecipient,proto3" json:"quota_payment_recipient,omitempty"`
 }
 
 func (m *Params) Reset()         { *m = Params{} }
@@ -61,6 +74,34 @@ func (m *Params) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Params proto.InternalMessageInfo
 
+func (m *Params) GetQuotaPrice() types.Coin {
+	if m != nil {
+		return m.QuotaPrice
+	}
+	return types.Coin{}
+}
+
+func (m *Params) GetQuotaUnitBytes() uint64 {
+	if m != nil {
+		return m.QuotaUnitBytes
+	}
+	return 0
+}
+
+func (m *Params) GetDefaultQuotaBytes() uint64 {
+	if m != nil {
+		return m.DefaultQuotaBytes
+	}
+	return 0
+}
+
+func (m *Params) GetQuotaPaymentRecipient() string {
+	if m != nil {
+		return m.QuotaPaymentRecipient
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Params)(nil), "mailchat.mailchat.v1.Params")
 }
@@ -68,19 +109,31 @@ func init() {
 func init() { proto.RegisterFile("mailchat/mailchat/v1/params.proto", fileDescriptor_7dbee120bf8cf00f) }
 
 var fileDescriptor_7dbee120bf8cf00f = []byte{
-	// 179 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x52, 0xcc, 0x4d, 0xcc, 0xcc,
-	0x49, 0xce, 0x48, 0x2c, 0xd1, 0x87, 0x33, 0xca, 0x0c, 0xf5, 0x0b, 0x12, 0x8b, 0x12, 0x73, 0x8b,
-	0xf5, 0x0a, 0x8a, 0xf2, 0x4b, 0xf2, 0x85, 0x44, 0x60, 0x32, 0x7a, 0x70, 0x46, 0x99, 0xa1, 0x94,
-	0x60, 0x62, 0x6e, 0x66, 0x5e, 0xbe, 0x3e, 0x98, 0x84, 0x28, 0x94, 0x12, 0x49, 0xcf, 0x4f, 0xcf,
-	0x07, 0x33, 0xf5, 0x41, 0x2c, 0x88, 0xa8, 0x92, 0x2e, 0x17, 0x5b, 0x00, 0xd8, 0x38, 0x2b, 0xe5,
-	0x17, 0x0b, 0xe4, 0x19, 0xbb, 0x9e, 0x6f, 0xd0, 0x92, 0x82, 0xdb, 0x55, 0x81, 0xb0, 0x16, 0xa2,
-	0xc8, 0xc9, 0xf3, 0xc4, 0x23, 0x39, 0xc6, 0x0b, 0x8f, 0xe4, 0x18, 0x1f, 0x3c, 0x92, 0x63, 0x9c,
-	0xf0, 0x58, 0x8e, 0xe1, 0xc2, 0x63, 0x39, 0x86, 0x1b, 0x8f, 0xe5, 0x18, 0xa2, 0xf4, 0xd3, 0x33,
-	0x4b, 0x32, 0x4a, 0x93, 0xf4, 0x92, 0xf3, 0x73, 0xf5, 0x53, 0x8a, 0xf3, 0xd3, 0x4a, 0xd2, 0x13,
-	0x73, 0x53, 0x8b, 0xf5, 0x7d, 0x13, 0x33, 0x73, 0x9c, 0xd1, 0xcc, 0x2a, 0xa9, 0x2c, 0x48, 0x2d,
-	0x4e, 0x62, 0x03, 0x3b, 0xc0, 0x18, 0x10, 0x00, 0x00, 0xff, 0xff, 0x9f, 0x01, 0x0c, 0xd4, 0xe4,
-	0x00, 0x00, 0x00,
+	// 380 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x51, 0xbd, 0x4e, 0xeb, 0x30,
+	0x14, 0x8e, 0x7b, 0xab, 0x4a, 0x4d, 0xa5, 0xab, 0xdb, 0xdc, 0x5e, 0xdd, 0xb4, 0x43, 0x5a, 0x60,
+	0x89, 0x2a, 0x11, 0x2b, 0xb0, 0xb1, 0x91, 0x8a, 0x81, 0x01, 0xa9, 0x04, 0xb1, 0xb0, 0x44, 0x4e,
+	0xe2, 0xa6, 0x96, 0x1a, 0x3b, 0xc4, 0x4e, 0x45, 0x5f, 0x81, 0x89, 0x47, 0xe8, 0xc8, 0xd8, 0x81,
+	0x87, 0xe8, 0x58, 0x31, 0x31, 0x21, 0xd4, 0x0e, 0xe5, 0x31, 0x50, 0xe2, 0xb4, 0x48, 0x2c, 0xd6,
+	0xf1, 0xf7, 0x73, 0xce, 0xa7, 0x73, 0xd4, 0x83, 0x18, 0x91, 0x49, 0x30, 0x46, 0x02, 0xee, 0x8b,
+	0xa9, 0x0d, 0x13, 0x94, 0xa2, 0x98, 0x5b, 0x49, 0xca, 0x04, 0xd3, 0x5a, 0x3b, 0xc6, 0xda, 0x17,
+	0x53, 0xbb, 0xd3, 0x44, 0x31, 0xa1, 0x0c, 0x16, 0xaf, 0x14, 0x76, 0x8c, 0x80, 0xf1, 0x98, 0x71,
+	0xe8, 0x23, 0x8e, 0xe1, 0xd4, 0xf6, 0xb1, 0x40, 0x36, 0x0c, 0x18, 0xa1, 0x25, 0xdf, 0x96, 0xbc,
+	0x57, 0xfc, 0xa0, 0xfc, 0x94, 0x54, 0x2b, 0x62, 0x11, 0x93, 0x78, 0x5e, 0x49, 0xf4, 0x70, 0x5e,
+	0x51, 0x6b, 0xc3, 0x22, 0x8a, 0x76, 0xa1, 0x36, 0xee, 0x33, 0x26, 0x90, 0x97, 0xa4, 0x24, 0xc0,
+	0x3a, 0xe8, 0x01, 0xb3, 0x71, 0xd2, 0xb6, 0xca, 0x26, 0xf9, 0x44, 0xab, 0x9c, 0x68, 0x0d, 0x18,
+	0xa1, 0x4e, 0x7d, 0xf9, 0xde, 0x55, 0x9e, 0xb7, 0x8b, 0x3e, 0x70, 0xd5, 0xc2, 0x38, 0xcc, 0x7d,
+	0x9a, 0xa9, 0xfe, 0x91, 0x6d, 0x32, 0x4a, 0x84, 0xe7, 0xcf, 0x04, 0xe6, 0x7a, 0xa5, 0x07, 0xcc,
+	0xaa, 0xfb, 0xbb, 0xc0, 0x6f, 0x29, 0x11, 0x4e, 0x8e, 0x6a, 0x96, 0xfa, 0x37, 0xc4, 0x23, 0x94,
+	0x4d, 0x84, 0x27, 0x1d, 0x52, 0xfc, 0xab, 0x10, 0x37, 0x4b, 0xea, 0x3a, 0x67, 0xa4, 0x7e, 0xa8,
+	0xfe, 0x2f, 0x03, 0xa2, 0x59, 0x8c, 0xa9, 0xf0, 0x52, 0x1c, 0x90, 0x84, 0x60, 0x2a, 0xf4, 0x6a,
+	0x0f, 0x98, 0x75, 0x47, 0x7f, 0x7d, 0x39, 0x6e, 0x95, 0x79, 0xcf, 0xc3, 0x30, 0xc5, 0x9c, 0xdf,
+	0x88, 0x94, 0xd0, 0xc8, 0xfd, 0x27, 0x03, 0x4a, 0x9f, 0xbb, 0xb3, 0x9d, 0x1d, 0x7d, 0xce, 0xbb,
+	0xe0, 0x71, 0xbb, 0xe8, 0x77, 0xf6, 0xa7, 0x79, 0xf8, 0xbe, 0x92, 0xdc, 0x8b, 0x73, 0xb9, 0x5c,
+	0x1b, 0x60, 0xb5, 0x36, 0xc0, 0xc7, 0xda, 0x00, 0x4f, 0x1b, 0x43, 0x59, 0x6d, 0x0c, 0xe5, 0x6d,
+	0x63, 0x28, 0x77, 0x30, 0x22, 0x62, 0x9c, 0xf9, 0x56, 0xc0, 0x62, 0x18, 0x72, 0x36, 0x12, 0x11,
+	0x8a, 0x31, 0x87, 0x57, 0x88, 0x4c, 0x06, 0x3f, 0x7a, 0x89, 0x59, 0x82, 0xb9, 0x5f, 0x2b, 0x96,
+	0x7e, 0xfa, 0x35, 0x00, 0xf2, 0xa1, 0xe8, 0x41, 0x13, 0x02, 0x00, 0x00,
 }
 
 func (this *Params) Equal(that interface{}) bool {
@@ -102,6 +155,18 @@ func (this *Params) Equal(that interface{}) bool {
 	} else if this == nil {
 		return false
 	}
+	if !this.QuotaPrice.Equal(&that1.QuotaPrice) {
+		return false
+	}
+	if this.QuotaUnitBytes != that1.QuotaUnitBytes {
+		return false
+	}
+	if this.DefaultQuotaBytes != that1.DefaultQuotaBytes {
+		return false
+	}
+	if this.QuotaPaymentRecipient != that1.QuotaPaymentRecipient {
+		return false
+	}
 	return true
 }
 func (m *Params) Marshal() (dAtA []byte, err error) {
@@ -124,6 +189,33 @@ func (m *Params) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.QuotaPaymentRecipient) > 0 {
+		i -= len(m.QuotaPaymentRecipient)
+		copy(dAtA[i:], m.QuotaPaymentRecipient)
+		i = encodeVarintParams(dAtA, i, uint64(len(m.QuotaPaymentRecipient)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.DefaultQuotaBytes != 0 {
+		i = encodeVarintParams(dAtA, i, uint64(m.DefaultQuotaBytes))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.QuotaUnitBytes != 0 {
+		i = encodeVarintParams(dAtA, i, uint64(m.QuotaUnitBytes))
+		i--
+		dAtA[i] = 0x10
+	}
+	{
+		size, err := m.QuotaPrice.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintParams(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0xa
 	return len(dAtA) - i, nil
 }
 
@@ -144,6 +236,18 @@ func (m *Params) Size() (n int) {
 	}
 	var l int
 	_ = l
+	l = m.QuotaPrice.Size()
+	n += 1 + l + sovParams(uint64(l))
+	if m.QuotaUnitBytes != 0 {
+		n += 1 + sovParams(uint64(m.QuotaUnitBytes))
+	}
+	if m.DefaultQuotaBytes != 0 {
+		n += 1 + sovParams(uint64(m.DefaultQuotaBytes))
+	}
+	l = len(m.QuotaPaymentRecipient)
+	if l > 0 {
+		n += 1 + l + sovParams(uint64(l))
+	}
 	return n
 }
 
@@ -182,6 +286,109 @@ func (m *Params) Unmarshal(dAtA []byte) error {
 			return fmt.Errorf("proto: Params: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaPrice", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthParams
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthParams
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.QuotaPrice.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaUnitBytes", wireType)
+			}
+			m.QuotaUnitBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QuotaUnitBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DefaultQuotaBytes", wireType)
+			}
+			m.DefaultQuotaBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DefaultQuotaBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaPaymentRecipient", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowParams
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthParams
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthParams
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.QuotaPaymentRecipient = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipParams(dAtA[iNdEx:])
@@ -6,7 +6,7 @@ package types
 import (
 	context "context"
 	fmt "fmt"
-	_ "github.com/cosmos/cosmos-sdk/types/query"
+	query "github.com/cosmos/cosmos-sdk/types/query"
 	_ "github.com/cosmos/cosmos-sdk/types/tx/amino"
 	_ "github.com/cosmos/gogoproto/gogoproto"
 	grpc1 "github.com/cosmos/gogoproto/grpc"
@@ -114,215 +114,2233 @@ func (m *QueryParamsResponse) GetParams() Params {
 	return Params{}
 }
 
-func init() {
-	proto.RegisterType((*QueryParamsRequest)(nil), "mailchat.mailchat.v1.QueryParamsRequest")
-	proto.RegisterType((*QueryParamsResponse)(nil), "mailchat.mailchat.v1.QueryParamsResponse")
+// QueryRelayRequest is request type for the Query/Relay RPC method.
+type QueryRelayRequest struct {
+	Hostname string `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
 }
 
-func init() { proto.RegisterFile("mailchat/mailchat/v1/query.proto", fileDescriptor_f6a9242049e68edb) }
+func (m *QueryRelayRequest) Reset()         { *m = QueryRelayRequest{} }
+func (m *QueryRelayRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryRelayRequest) ProtoMessage()    {}
+func (*QueryRelayRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{2}
+}
+func (m *QueryRelayRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryRelayRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryRelayRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryRelayRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryRelayRequest.Merge(m, src)
+}
+func (m *QueryRelayRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryRelayRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryRelayRequest.DiscardUnknown(m)
+}
 
-var fileDescriptor_f6a9242049e68edb = []byte{
-	// 328 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x52, 0xc8, 0x4d, 0xcc, 0xcc,
-	0x49, 0xce, 0x48, 0x2c, 0xd1, 0x87, 0x33, 0xca, 0x0c, 0xf5, 0x0b, 0x4b, 0x53, 0x8b, 0x2a, 0xf5,
-	0x0a, 0x8a, 0xf2, 0x4b, 0xf2, 0x85, 0x44, 0x60, 0x12, 0x7a, 0x70, 0x46, 0x99, 0xa1, 0x94, 0x60,
-	0x62, 0x6e, 0x66, 0x5e, 0xbe, 0x3e, 0x98, 0x84, 0x28, 0x94, 0xd2, 0x4a, 0xce, 0x2f, 0xce, 0xcd,
-	0x2f, 0xd6, 0x4f, 0x4a, 0x2c, 0x4e, 0x85, 0x98, 0xa0, 0x5f, 0x66, 0x98, 0x94, 0x5a, 0x92, 0x68,
-	0xa8, 0x5f, 0x90, 0x98, 0x9e, 0x99, 0x97, 0x58, 0x92, 0x99, 0x9f, 0x07, 0x55, 0x2b, 0x92, 0x9e,
-	0x9f, 0x9e, 0x0f, 0x66, 0xea, 0x83, 0x58, 0x50, 0x51, 0x99, 0xf4, 0xfc, 0xfc, 0xf4, 0x9c, 0x54,
-	0xfd, 0xc4, 0x82, 0x4c, 0xfd, 0xc4, 0xbc, 0xbc, 0xfc, 0x12, 0xb0, 0x96, 0x62, 0xa8, 0xac, 0x22,
-	0x56, 0xa7, 0x16, 0x24, 0x16, 0x25, 0xe6, 0x42, 0x95, 0x28, 0x89, 0x70, 0x09, 0x05, 0x82, 0x2c,
-	0x0e, 0x00, 0x0b, 0x06, 0xa5, 0x16, 0x96, 0xa6, 0x16, 0x97, 0x28, 0x85, 0x71, 0x09, 0xa3, 0x88,
-	0x16, 0x17, 0xe4, 0xe7, 0x15, 0xa7, 0x0a, 0xd9, 0x73, 0xb1, 0x41, 0x34, 0x4b, 0x30, 0x2a, 0x30,
-	0x6a, 0x70, 0x1b, 0xc9, 0xe8, 0x61, 0xf3, 0xa9, 0x1e, 0x44, 0x97, 0x13, 0xe7, 0x89, 0x7b, 0xf2,
-	0x0c, 0x2b, 0x9e, 0x6f, 0xd0, 0x62, 0x0c, 0x82, 0x6a, 0x33, 0x9a, 0xc1, 0xc8, 0xc5, 0x0a, 0x36,
-	0x58, 0xa8, 0x8f, 0x91, 0x8b, 0x0d, 0xa2, 0x4e, 0x48, 0x03, 0xbb, 0x29, 0x98, 0xce, 0x92, 0xd2,
-	0x24, 0x42, 0x25, 0xc4, 0xa9, 0x4a, 0xfa, 0x4d, 0x97, 0x9f, 0x4c, 0x66, 0xd2, 0x14, 0x52, 0xd7,
-	0x4f, 0x29, 0xce, 0x4f, 0x2b, 0x49, 0x4f, 0xcc, 0x4d, 0x2d, 0xd6, 0xf7, 0x4d, 0xcc, 0xcc, 0x71,
-	0xc6, 0x1e, 0x1c, 0x4e, 0x9e, 0x27, 0x1e, 0xc9, 0x31, 0x5e, 0x78, 0x24, 0xc7, 0xf8, 0xe0, 0x91,
-	0x1c, 0xe3, 0x84, 0xc7, 0x72, 0x0c, 0x17, 0x1e, 0xcb, 0x31, 0xdc, 0x78, 0x2c, 0xc7, 0x10, 0xa5,
-	0x9f, 0x9e, 0x59, 0x92, 0x51, 0x9a, 0xa4, 0x97, 0x9c, 0x9f, 0x8b, 0xd5, 0xb0, 0x0a, 0x84, 0x71,
-	0x25, 0x95, 0x05, 0xa9, 0xc5, 0x49, 0x6c, 0xe0, 0xa0, 0x35, 0x06, 0x04, 0x00, 0x00, 0xff, 0xff,
-	0x42, 0x9a, 0xc7, 0x59, 0x2a, 0x02, 0x00, 0x00,
+var xxx_messageInfo_QueryRelayRequest proto.InternalMessageInfo
+
+func (m *QueryRelayRequest) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+// QueryRelayResponse is response type for the Query/Relay RPC method.
+type QueryRelayResponse struct {
+	Relay MailRelay `protobuf:"bytes,1,opt,name=relay,proto3" json:"relay"`
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *QueryRelayResponse) Reset()         { *m = QueryRelayResponse{} }
+func (m *QueryRelayResponse) String() string { return proto.CompactTextString(m) }
+func (*QueryRelayResponse) ProtoMessage()    {}
+func (*QueryRelayResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{3}
+}
+func (m *QueryRelayResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryRelayResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryRelayResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryRelayResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryRelayResponse.Merge(m, src)
+}
+func (m *QueryRelayResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryRelayResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryRelayResponse.DiscardUnknown(m)
+}
 
-// QueryClient is the client API for Query service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type QueryClient interface {
-	// Parameters queries the parameters of the module.
-	Params(ctx context.Context, in *QueryParamsRequest, opts ...grpc.CallOption) (*QueryParamsResponse, error)
+var xxx_messageInfo_QueryRelayResponse proto.InternalMessageInfo
+
+func (m *QueryRelayResponse) GetRelay() MailRelay {
+	if m != nil {
+		return m.Relay
+	}
+	return MailRelay{}
 }
 
-type queryClient struct {
-	cc grpc1.ClientConn
+// QueryRelaysRequest is request type for the Query/Relays RPC method.
+type QueryRelaysRequest struct {
+	Pagination *query.PageRequest `protobuf:"bytes,1,opt,name=pagination,proto3" json:"pagination,omitempty"`
 }
 
-func NewQueryClient(cc grpc1.ClientConn) QueryClient {
-	return &queryClient{cc}
+func (m *QueryRelaysRequest) Reset()         { *m = QueryRelaysRequest{} }
+func (m *QueryRelaysRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryRelaysRequest) ProtoMessage()    {}
+func (*QueryRelaysRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{4}
+}
+func (m *QueryRelaysRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryRelaysRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryRelaysRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryRelaysRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryRelaysRequest.Merge(m, src)
+}
+func (m *QueryRelaysRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryRelaysRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryRelaysRequest.DiscardUnknown(m)
 }
 
-func (c *queryClient) Params(ctx context.Context, in *QueryParamsRequest, opts ...grpc.CallOption) (*QueryParamsResponse, error) {
-	out := new(QueryParamsResponse)
-	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/Params", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_QueryRelaysRequest proto.InternalMessageInfo
+
+func (m *QueryRelaysRequest) GetPagination() *query.PageRequest {
+	if m != nil {
+		return m.Pagination
 	}
-	return out, nil
+	return nil
 }
 
-// QueryServer is the server API for Query service.
-type QueryServer interface {
-	// Parameters queries the parameters of the module.
-	Params(context.Context, *QueryParamsRequest) (*QueryParamsResponse, error)
+// QueryRelaysResponse is response type for the Query/Relays RPC method.
+type QueryRelaysResponse struct {
+	Relays     []MailRelay         `protobuf:"bytes,1,rep,name=relays,proto3" json:"relays"`
+	Pagination *query.PageResponse `protobuf:"bytes,2,opt,name=pagination,proto3" json:"pagination,omitempty"`
 }
 
-// UnimplementedQueryServer can be embedded to have forward compatible implementations.
-type UnimplementedQueryServer struct {
+func (m *QueryRelaysResponse) Reset()         { *m = QueryRelaysResponse{} }
+func (m *QueryRelaysResponse) String() string { return proto.CompactTextString(m) }
+func (*QueryRelaysResponse) ProtoMessage()    {}
+func (*QueryRelaysResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{5}
+}
+func (m *QueryRelaysResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryRelaysResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryRelaysResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryRelaysResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryRelaysResponse.Merge(m, src)
+}
+func (m *QueryRelaysResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryRelaysResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryRelaysResponse.DiscardUnknown(m)
 }
 
-func (*UnimplementedQueryServer) Params(ctx context.Context, req *QueryParamsRequest) (*QueryParamsResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Params not implemented")
+var xxx_messageInfo_QueryRelaysResponse proto.InternalMessageInfo
+
+func (m *QueryRelaysResponse) GetRelays() []MailRelay {
+	if m != nil {
+		return m.Relays
+	}
+	return nil
 }
 
-func RegisterQueryServer(s grpc1.Server, srv QueryServer) {
-	s.RegisterService(&_Query_serviceDesc, srv)
+func (m *QueryRelaysResponse) GetPagination() *query.PageResponse {
+	if m != nil {
+		return m.Pagination
+	}
+	return nil
 }
 
-func _Query_Params_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(QueryParamsRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+// QueryRelaysByDomainRequest is request type for the Query/RelaysByDomain RPC
+// method.
+type QueryRelaysByDomainRequest struct {
+	Domain string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+}
+
+func (m *QueryRelaysByDomainRequest) Reset()         { *m = QueryRelaysByDomainRequest{} }
+func (m *QueryRelaysByDomainRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryRelaysByDomainRequest) ProtoMessage()    {}
+func (*QueryRelaysByDomainRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{6}
+}
+func (m *QueryRelaysByDomainRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryRelaysByDomainRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryRelaysByDomainRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if interceptor == nil {
-		return srv.(QueryServer).Params(ctx, in)
+}
+func (m *QueryRelaysByDomainRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryRelaysByDomainRequest.Merge(m, src)
+}
+func (m *QueryRelaysByDomainRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryRelaysByDomainRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryRelaysByDomainRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryRelaysByDomainRequest proto.InternalMessageInfo
+
+func (m *QueryRelaysByDomainRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/mailchat.mailchat.v1.Query/Params",
+	return ""
+}
+
+// QueryRelaysByDomainResponse is response type for the Query/RelaysByDomain
+// RPC method.
+type QueryRelaysByDomainResponse struct {
+	Relays []MailRelay `protobuf:"bytes,1,rep,name=relays,proto3" json:"relays"`
+}
+
+func (m *QueryRelaysByDomainResponse) Reset()         { *m = QueryRelaysByDomainResponse{} }
+func (m *QueryRelaysByDomainResponse) String() string { return proto.CompactTextString(m) }
+func (*QueryRelaysByDomainResponse) ProtoMessage()    {}
+func (*QueryRelaysByDomainResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{7}
+}
+func (m *QueryRelaysByDomainResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryRelaysByDomainResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryRelaysByDomainResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(QueryServer).Params(ctx, req.(*QueryParamsRequest))
+}
+func (m *QueryRelaysByDomainResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryRelaysByDomainResponse.Merge(m, src)
+}
+func (m *QueryRelaysByDomainResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryRelaysByDomainResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryRelaysByDomainResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryRelaysByDomainResponse proto.InternalMessageInfo
+
+func (m *QueryRelaysByDomainResponse) GetRelays() []MailRelay {
+	if m != nil {
+		return m.Relays
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-var Query_serviceDesc = _Query_serviceDesc
-var _Query_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "mailchat.mailchat.v1.Query",
-	HandlerType: (*QueryServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Params",
-			Handler:    _Query_Params_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "mailchat/mailchat/v1/query.proto",
+// QueryAnchorRequest is request type for the Query/Anchor RPC method.
+type QueryAnchorRequest struct {
+	Root string `protobuf:"bytes,1,opt,name=root,proto3" json:"root,omitempty"`
 }
 
-func (m *QueryParamsRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *QueryAnchorRequest) Reset()         { *m = QueryAnchorRequest{} }
+func (m *QueryAnchorRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryAnchorRequest) ProtoMessage()    {}
+func (*QueryAnchorRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{8}
+}
+func (m *QueryAnchorRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryAnchorRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryAnchorRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
+}
+func (m *QueryAnchorRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryAnchorRequest.Merge(m, src)
+}
+func (m *QueryAnchorRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryAnchorRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryAnchorRequest.DiscardUnknown(m)
 }
 
-func (m *QueryParamsRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+var xxx_messageInfo_QueryAnchorRequest proto.InternalMessageInfo
+
+func (m *QueryAnchorRequest) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
 }
 
-func (m *QueryParamsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	return len(dAtA) - i, nil
+// QueryAnchorResponse is response type for the Query/Anchor RPC method.
+type QueryAnchorResponse struct {
+	Anchor MerkleAnchor `protobuf:"bytes,1,opt,name=anchor,proto3" json:"anchor"`
 }
 
-func (m *QueryParamsResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *QueryAnchorResponse) Reset()         { *m = QueryAnchorResponse{} }
+func (m *QueryAnchorResponse) String() string { return proto.CompactTextString(m) }
+func (*QueryAnchorResponse) ProtoMessage()    {}
+func (*QueryAnchorResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{9}
+}
+func (m *QueryAnchorResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryAnchorResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryAnchorResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryAnchorResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryAnchorResponse.Merge(m, src)
+}
+func (m *QueryAnchorResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryAnchorResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryAnchorResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryAnchorResponse proto.InternalMessageInfo
+
+func (m *QueryAnchorResponse) GetAnchor() MerkleAnchor {
+	if m != nil {
+		return m.Anchor
+	}
+	return MerkleAnchor{}
+}
+
+// QueryMailboxQuotaRequest is request type for the Query/MailboxQuota RPC
+// method.
+type QueryMailboxQuotaRequest struct {
+	Mailbox string `protobuf:"bytes,1,opt,name=mailbox,proto3" json:"mailbox,omitempty"`
+}
+
+func (m *QueryMailboxQuotaRequest) Reset()         { *m = QueryMailboxQuotaRequest{} }
+func (m *QueryMailboxQuotaRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryMailboxQuotaRequest) ProtoMessage()    {}
+func (*QueryMailboxQuotaRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{10}
+}
+func (m *QueryMailboxQuotaRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryMailboxQuotaRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryMailboxQuotaRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryMailboxQuotaRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryMailboxQuotaRequest.Merge(m, src)
+}
+func (m *QueryMailboxQuotaRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryMailboxQuotaRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryMailboxQuotaRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryMailboxQuotaRequest proto.InternalMessageInfo
+
+func (m *QueryMailboxQuotaRequest) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+// QueryMailboxQuotaResponse is response type for the Query/MailboxQuota RPC
+// method.
+type QueryMailboxQuotaResponse struct {
+	// quota is the purchase record of the mailbox. It is empty except for the
+	// mailbox field if no quota was purchased.
+	Quota MailboxQuota `protobuf:"bytes,1,opt,name=quota,proto3" json:"quota"`
+	// limit_bytes is the effective storage quota of the mailbox: the default
+	// quota plus the purchased storage.
+	LimitBytes uint64 `protobuf:"varint,2,opt,name=limit_bytes,json=limitBytes,proto3" json:"limit_bytes,omitempty"`
+}
+
+func (m *QueryMailboxQuotaResponse) Reset()         { *m = QueryMailboxQuotaResponse{} }
+func (m *QueryMailboxQuotaResponse) String() string { return proto.CompactTextString(m) }
+func (*QueryMailboxQuotaResponse) ProtoMessage()    {}
+func (*QueryMailboxQuotaResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f6a9242049e68edb, []int{11}
+}
+func (m *QueryMailboxQuotaResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryMailboxQuotaResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryMailboxQuotaResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryMailboxQuotaResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryMailboxQuotaResponse.Merge(m, src)
+}
+func (m *QueryMailboxQuotaResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryMailboxQuotaResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryMailboxQuotaResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryMailboxQuotaResponse proto.InternalMessageInfo
+
+func (m *QueryMailboxQuotaResponse) GetQuota() MailboxQuota {
+	if m != nil {
+		return m.Quota
+	}
+	return MailboxQuota{}
+}
+
+func (m *QueryMailboxQuotaResponse) GetLimitBytes() uint64 {
+	if m != nil {
+		return m.LimitBytes
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*QueryParamsRequest)(nil), "mailchat.mailchat.v1.QueryParamsRequest")
+	proto.RegisterType((*QueryParamsResponse)(nil), "mailchat.mailchat.v1.QueryParamsResponse")
+	proto.RegisterType((*QueryRelayRequest)(nil), "mailchat.mailchat.v1.QueryRelayRequest")
+	proto.RegisterType((*QueryRelayResponse)(nil), "mailchat.mailchat.v1.QueryRelayResponse")
+	proto.RegisterType((*QueryRelaysRequest)(nil), "mailchat.mailchat.v1.QueryRelaysRequest")
+	proto.RegisterType((*QueryRelaysResponse)(nil), "mailchat.mailchat.v1.QueryRelaysResponse")
+	proto.RegisterType((*QueryRelaysByDomainRequest)(nil), "mailchat.mailchat.v1.QueryRelaysByDomainRequest")
+	proto.RegisterType((*QueryRelaysByDomainResponse)(nil), "mailchat.mailchat.v1.QueryRelaysByDomainResponse")
+	proto.RegisterType((*QueryAnchorRequest)(nil), "mailchat.mailchat.v1.QueryAnchorRequest")
+	proto.RegisterType((*QueryAnchorResponse)(nil), "mailchat.mailchat.v1.QueryAnchorResponse")
+	proto.RegisterType((*QueryMailboxQuotaRequest)(nil), "mailchat.mailchat.v1.QueryMailboxQuotaRequest")
+	proto.RegisterType((*QueryMailboxQuotaResponse)(nil), "mailchat.mailchat.v1.QueryMailboxQuotaResponse")
+}
+
+func init() { proto.RegisterFile("mailchat/mailchat/v1/query.proto", fileDescriptor_f6a9242049e68edb) }
+
+var fileDescriptor_f6a9242049e68edb = []byte{
+	// 785 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x95, 0x4f, 0x4f, 0x13, 0x4f,
+	0x18, 0xc7, 0xbb, 0xfc, 0x68, 0xf9, 0xf1, 0x60, 0x4c, 0x18, 0x88, 0xc1, 0x95, 0x14, 0xdc, 0x44,
+	0x29, 0x1c, 0x76, 0x28, 0x10, 0xa3, 0xf8, 0x0f, 0xab, 0xd1, 0x78, 0x20, 0x81, 0x1e, 0x34, 0x31,
+	0x24, 0xcd, 0xb4, 0x8c, 0xdb, 0x8d, 0xdd, 0x9d, 0xd2, 0xdd, 0x12, 0x9a, 0xda, 0x8b, 0x2f, 0xc0,
+	0x98, 0x18, 0x8f, 0xde, 0x3d, 0xea, 0xd1, 0x77, 0x80, 0x37, 0x12, 0x2f, 0x9e, 0x8c, 0x01, 0x13,
+	0xdf, 0x86, 0xd9, 0x99, 0x67, 0x61, 0x57, 0x97, 0x6e, 0x49, 0xbc, 0xc0, 0xcc, 0xf4, 0xf9, 0x3e,
+	0xcf, 0x67, 0x66, 0x9e, 0xef, 0x2c, 0xcc, 0x3a, 0xcc, 0x6e, 0xd4, 0xea, 0xcc, 0xa7, 0xc7, 0x83,
+	0xdd, 0x22, 0xdd, 0x69, 0xf3, 0x56, 0xc7, 0x6c, 0xb6, 0x84, 0x2f, 0xc8, 0x64, 0xf8, 0x83, 0x79,
+	0x3c, 0xd8, 0x2d, 0xea, 0xe3, 0xcc, 0xb1, 0x5d, 0x41, 0xe5, 0x5f, 0x15, 0xa8, 0x2f, 0xd4, 0x84,
+	0xe7, 0x08, 0x8f, 0x56, 0x99, 0xc7, 0x55, 0x06, 0xba, 0x5b, 0xac, 0x72, 0x9f, 0x15, 0x69, 0x93,
+	0x59, 0xb6, 0xcb, 0x7c, 0x5b, 0xb8, 0x18, 0x3b, 0x69, 0x09, 0x4b, 0xc8, 0x21, 0x0d, 0x46, 0xb8,
+	0x3a, 0x6d, 0x09, 0x61, 0x35, 0x38, 0x65, 0x4d, 0x9b, 0x32, 0xd7, 0x15, 0xbe, 0x94, 0x78, 0xf8,
+	0xeb, 0x95, 0x44, 0xd4, 0x60, 0x5c, 0x69, 0xf1, 0x06, 0x43, 0x5e, 0xbd, 0x70, 0x6a, 0x58, 0x55,
+	0xec, 0x55, 0x76, 0xda, 0xc2, 0x67, 0xfd, 0x23, 0x79, 0xeb, 0x45, 0x83, 0x57, 0x98, 0x5b, 0xab,
+	0x8b, 0x16, 0x46, 0x5e, 0x4e, 0x8c, 0x6c, 0xb2, 0x16, 0x73, 0x90, 0xce, 0x98, 0x04, 0xb2, 0x19,
+	0xec, 0x79, 0x43, 0x2e, 0x96, 0xf9, 0x4e, 0x9b, 0x7b, 0xbe, 0xf1, 0x04, 0x26, 0x62, 0xab, 0x5e,
+	0x53, 0xb8, 0x1e, 0x27, 0x77, 0x21, 0xa7, 0xc4, 0x53, 0xda, 0xac, 0x56, 0x18, 0x5b, 0x9a, 0x36,
+	0x93, 0x0e, 0xd9, 0x54, 0xaa, 0xd2, 0xe8, 0xfe, 0xf7, 0x99, 0xcc, 0x87, 0x5f, 0x1f, 0x17, 0xb4,
+	0x32, 0xca, 0x0c, 0x0a, 0xe3, 0x32, 0x6f, 0x39, 0xd8, 0x38, 0x16, 0x23, 0x3a, 0xfc, 0x5f, 0x17,
+	0x9e, 0xef, 0x32, 0x87, 0xcb, 0xbc, 0xa3, 0xe5, 0xe3, 0xb9, 0xb1, 0x09, 0x24, 0x2a, 0x40, 0x8e,
+	0x9b, 0x90, 0x95, 0x47, 0x87, 0x18, 0x33, 0xc9, 0x18, 0xeb, 0xcc, 0x6e, 0x48, 0x5d, 0x69, 0x38,
+	0x20, 0x29, 0x2b, 0x8d, 0xb1, 0x15, 0x4d, 0x19, 0xee, 0x98, 0x3c, 0x04, 0x38, 0xb9, 0x6d, 0xcc,
+	0x7b, 0xd5, 0x54, 0xad, 0x61, 0x06, 0xad, 0x61, 0xaa, 0xe6, 0xc2, 0xd6, 0x30, 0x37, 0x98, 0xc5,
+	0x51, 0x5b, 0x8e, 0x28, 0x8d, 0xf7, 0x1a, 0x4c, 0xc4, 0xd2, 0x23, 0xf2, 0x6d, 0xc8, 0xc9, 0xf2,
+	0xc1, 0xd1, 0xfd, 0x37, 0x38, 0x33, 0x8a, 0xc8, 0xa3, 0x18, 0xde, 0x90, 0xc4, 0x9b, 0x4b, 0xc5,
+	0x53, 0xb5, 0x63, 0x7c, 0x2b, 0xa0, 0x47, 0xf0, 0x4a, 0x9d, 0x07, 0xc2, 0x61, 0xb6, 0x1b, 0x9e,
+	0xc2, 0x05, 0xc8, 0x6d, 0xcb, 0x05, 0xbc, 0x08, 0x9c, 0x19, 0x5b, 0x70, 0x29, 0x51, 0xf5, 0x4f,
+	0x36, 0x67, 0x14, 0xf0, 0x46, 0xee, 0xc9, 0xde, 0x0d, 0x59, 0x08, 0x0c, 0xb7, 0x84, 0xf0, 0x91,
+	0x44, 0x8e, 0x8d, 0xa7, 0x30, 0x11, 0x8b, 0xc4, 0xfa, 0x6b, 0x90, 0x53, 0x7d, 0x8f, 0x17, 0x67,
+	0x9c, 0x52, 0x5f, 0x5a, 0x44, 0x69, 0x43, 0x04, 0xa5, 0x33, 0x56, 0x60, 0x4a, 0x26, 0x5e, 0x57,
+	0x7e, 0xdb, 0x0c, 0xec, 0x16, 0x82, 0x4c, 0xc1, 0x08, 0xda, 0x10, 0x59, 0xc2, 0xa9, 0xf1, 0x12,
+	0x2e, 0x26, 0xa8, 0x10, 0xea, 0x0e, 0x64, 0xa5, 0x6b, 0x53, 0x98, 0x22, 0xd2, 0xb0, 0x4f, 0xa5,
+	0x8c, 0xcc, 0xc0, 0x58, 0xc3, 0x76, 0x6c, 0xbf, 0x52, 0xed, 0xf8, 0xdc, 0x93, 0x77, 0x3e, 0x5c,
+	0x06, 0xb9, 0x54, 0x0a, 0x56, 0x96, 0xbe, 0x8c, 0x40, 0x56, 0x96, 0x27, 0xaf, 0x35, 0xc8, 0x29,
+	0xd3, 0x91, 0x42, 0x72, 0x99, 0xbf, 0x3d, 0xae, 0xcf, 0x0f, 0x10, 0xa9, 0xb6, 0x62, 0xd0, 0x57,
+	0x5f, 0x7f, 0xbe, 0x1d, 0x9a, 0x27, 0x73, 0x74, 0xdb, 0x13, 0xcf, 0x7d, 0x8b, 0x39, 0xdc, 0xa3,
+	0x01, 0xf9, 0xfd, 0xe4, 0xb7, 0x85, 0xbc, 0xd3, 0x20, 0x2b, 0x6f, 0x9a, 0xcc, 0xf5, 0xa9, 0x12,
+	0x7d, 0x05, 0xf4, 0x42, 0x7a, 0x20, 0xd2, 0xdc, 0x90, 0x34, 0xcb, 0xa4, 0x98, 0x4a, 0x23, 0xfb,
+	0x8b, 0x76, 0xc3, 0xd7, 0xa4, 0x27, 0x0f, 0x4a, 0xf5, 0x30, 0x49, 0xad, 0x37, 0xd0, 0x41, 0xc5,
+	0x5d, 0x7e, 0x86, 0x83, 0x42, 0x5f, 0x7f, 0xd6, 0xe0, 0x7c, 0xdc, 0x54, 0x64, 0x31, 0xb5, 0xdc,
+	0x1f, 0xae, 0xd5, 0x8b, 0x67, 0x50, 0x20, 0x68, 0x49, 0x82, 0xde, 0x22, 0xab, 0x03, 0x82, 0x56,
+	0xaa, 0x9d, 0x8a, 0x7a, 0x0b, 0x68, 0x57, 0xfd, 0xef, 0x05, 0x97, 0x9c, 0x53, 0x66, 0xea, 0x7b,
+	0x98, 0x31, 0x57, 0xeb, 0xf3, 0x03, 0x44, 0x22, 0xe3, 0x35, 0xc9, 0xb8, 0x48, 0xcc, 0x54, 0x46,
+	0x65, 0x62, 0xda, 0x0d, 0xde, 0x88, 0x1e, 0xf9, 0xa4, 0xc1, 0xb9, 0xa8, 0xad, 0x88, 0xd9, 0xa7,
+	0x66, 0x82, 0xe1, 0x75, 0x3a, 0x70, 0x3c, 0x92, 0xae, 0x49, 0xd2, 0x55, 0x72, 0x3d, 0x95, 0x34,
+	0xf6, 0x3d, 0xa7, 0x5d, 0x9c, 0xf6, 0x4a, 0x8f, 0xf7, 0x0f, 0xf3, 0xda, 0xc1, 0x61, 0x5e, 0xfb,
+	0x71, 0x98, 0xd7, 0xde, 0x1c, 0xe5, 0x33, 0x07, 0x47, 0xf9, 0xcc, 0xb7, 0xa3, 0x7c, 0xe6, 0x19,
+	0xb5, 0x6c, 0xbf, 0xde, 0xae, 0x9a, 0x35, 0xe1, 0x24, 0x66, 0xdf, 0x3b, 0xc9, 0xef, 0x77, 0x9a,
+	0xdc, 0xab, 0xe6, 0xe4, 0x87, 0x7d, 0xf9, 0xf7, 0x00, 0xca, 0x35, 0x5a, 0x40, 0x23, 0x09, 0x00,
+	0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// QueryClient is the client API for Query service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type QueryClient interface {
+	// Parameters queries the parameters of the module.
+	Params(ctx context.Context, in *QueryParamsRequest, opts ...grpc.CallOption) (*QueryParamsResponse, error)
+	// Relay queries a mail relay by its hostname.
+	Relay(ctx context.Context, in *QueryRelayRequest, opts ...grpc.CallOption) (*QueryRelayResponse, error)
+	// Relays queries all registered mail relays.
+	Relays(ctx context.Context, in *QueryRelaysRequest, opts ...grpc.CallOption) (*QueryRelaysResponse, error)
+	// RelaysByDomain queries the mail relays serving a domain.
+	RelaysByDomain(ctx context.Context, in *QueryRelaysByDomainRequest, opts ...grpc.CallOption) (*QueryRelaysByDomainResponse, error)
+	// Anchor queries an anchored Merkle root.
+	Anchor(ctx context.Context, in *QueryAnchorRequest, opts ...grpc.CallOption) (*QueryAnchorResponse, error)
+	// MailboxQuota queries the storage quota of a mailbox.
+	MailboxQuota(ctx context.Context, in *QueryMailboxQuotaRequest, opts ...grpc.CallOption) (*QueryMailboxQuotaResponse, error)
+}
+
+type queryClient struct {
+	cc grpc1.ClientConn
+}
+
+func NewQueryClient(cc grpc1.ClientConn) QueryClient {
+	return &queryClient{cc}
+}
+
+func (c *queryClient) Params(ctx context.Context, in *QueryParamsRequest, opts ...grpc.CallOption) (*QueryParamsResponse, error) {
+	out := new(QueryParamsResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/Params", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Relay(ctx context.Context, in *QueryRelayRequest, opts ...grpc.CallOption) (*QueryRelayResponse, error) {
+	out := new(QueryRelayResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/Relay", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Relays(ctx context.Context, in *QueryRelaysRequest, opts ...grpc.CallOption) (*QueryRelaysResponse, error) {
+	out := new(QueryRelaysResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/Relays", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) RelaysByDomain(ctx context.Context, in *QueryRelaysByDomainRequest, opts ...grpc.CallOption) (*QueryRelaysByDomainResponse, error) {
+	out := new(QueryRelaysByDomainResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/RelaysByDomain", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Anchor(ctx context.Context, in *QueryAnchorRequest, opts ...grpc.CallOption) (*QueryAnchorResponse, error) {
+	out := new(QueryAnchorResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/Anchor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) MailboxQuota(ctx context.Context, in *QueryMailboxQuotaRequest, opts ...grpc.CallOption) (*QueryMailboxQuotaResponse, error) {
+	out := new(QueryMailboxQuotaResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Query/MailboxQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// QueryServer is the server API for Query service.
+type QueryServer interface {
+	// Parameters queries the parameters of the module.
+	Params(context.Context, *QueryParamsRequest) (*QueryParamsResponse, error)
+	// Relay queries a mail relay by its hostname.
+	Relay(context.Context, *QueryRelayRequest) (*QueryRelayResponse, error)
+	// Relays queries all registered mail relays.
+	Relays(context.Context, *QueryRelaysRequest) (*QueryRelaysResponse, error)
+	// RelaysByDomain queries the mail relays serving a domain.
+	RelaysByDomain(context.Context, *QueryRelaysByDomainRequest) (*QueryRelaysByDomainResponse, error)
+	// Anchor queries an anchored Merkle root.
+	Anchor(context.Context, *QueryAnchorRequest) (*QueryAnchorResponse, error)
+	// MailboxQuota queries the storage quota of a mailbox.
+	MailboxQuota(context.Context, *QueryMailboxQuotaRequest) (*QueryMailboxQuotaResponse, error)
+}
+
+// UnimplementedQueryServer can be embedded to have forward compatible implementations.
+type UnimplementedQueryServer struct {
+}
+
+func (*UnimplementedQueryServer) Params(ctx context.Context, req *QueryParamsRequest) (*QueryParamsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Params not implemented")
+}
+func (*UnimplementedQueryServer) Relay(ctx context.Context, req *QueryRelayRequest) (*QueryRelayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Relay not implemented")
+}
+func (*UnimplementedQueryServer) Relays(ctx context.Context, req *QueryRelaysRequest) (*QueryRelaysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Relays not implemented")
+}
+func (*UnimplementedQueryServer) RelaysByDomain(ctx context.Context, req *QueryRelaysByDomainRequest) (*QueryRelaysByDomainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RelaysByDomain not implemented")
+}
+func (*UnimplementedQueryServer) Anchor(ctx context.Context, req *QueryAnchorRequest) (*QueryAnchorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Anchor not implemented")
+}
+func (*UnimplementedQueryServer) MailboxQuota(ctx context.Context, req *QueryMailboxQuotaRequest) (*QueryMailboxQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MailboxQuota not implemented")
+}
+
+func RegisterQueryServer(s grpc1.Server, srv QueryServer) {
+	s.RegisterService(&_Query_serviceDesc, srv)
+}
+
+func _Query_Params_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryParamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueryServer).Params(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Query/Params",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueryServer).Params(ctx, req.(*QueryParamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Query_Relay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRelayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueryServer).Relay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Query/Relay",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueryServer).Relay(ctx, req.(*QueryRelayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Query_Relays_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRelaysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueryServer).Relays(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Query/Relays",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueryServer).Relays(ctx, req.(*QueryRelaysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Query_RelaysByDomain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRelaysByDomainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueryServer).RelaysByDomain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Query/RelaysByDomain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueryServer).RelaysByDomain(ctx, req.(*QueryRelaysByDomainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Query_Anchor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryAnchorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueryServer).Anchor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Query/Anchor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueryServer).Anchor(ctx, req.(*QueryAnchorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Query_MailboxQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryMailboxQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueryServer).MailboxQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Query/MailboxQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueryServer).MailboxQuota(ctx, req.(*QueryMailboxQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var Query_serviceDesc = _Query_serviceDesc
+var _Query_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "mailchat.mailchat.v1.Query",
+	HandlerType: (*QueryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Params",
+			Handler:    _Query_Params_Handler,
+		},
+		{
+			MethodName: "Relay",
+			Handler:    _Query_Relay_Handler,
+		},
+		{
+			MethodName: "Relays",
+			Handler:    _Query_Relays_Handler,
+		},
+		{
+			MethodName: "RelaysByDomain",
+			Handler:    _Query_RelaysByDomain_Handler,
+		},
+		{
+			MethodName: "Anchor",
+			Handler:    _Query_Anchor_Handler,
+		},
+		{
+			MethodName: "MailboxQuota",
+			Handler:    _Query_MailboxQuota_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "mailchat/mailchat/v1/query.proto",
+}
+
+func (m *QueryParamsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryParamsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryParamsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryParamsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryParamsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryParamsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	{
+		size, err := m.Params.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintQuery(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRelayRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRelayRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRelayRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Hostname) > 0 {
+		i -= len(m.Hostname)
+		copy(dAtA[i:], m.Hostname)
+		i = encodeVarintQuery(dAtA, i, uint64(len(m.Hostname)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRelayResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRelayResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRelayResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	{
+		size, err := m.Relay.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintQuery(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRelaysRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRelaysRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRelaysRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Pagination != nil {
+		{
+			size, err := m.Pagination.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintQuery(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRelaysResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRelaysResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRelaysResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Pagination != nil {
+		{
+			size, err := m.Pagination.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintQuery(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Relays) > 0 {
+		for iNdEx := len(m.Relays) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Relays[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintQuery(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRelaysByDomainRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRelaysByDomainRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRelaysByDomainRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Domain) > 0 {
+		i -= len(m.Domain)
+		copy(dAtA[i:], m.Domain)
+		i = encodeVarintQuery(dAtA, i, uint64(len(m.Domain)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRelaysByDomainResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRelaysByDomainResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRelaysByDomainResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Relays) > 0 {
+		for iNdEx := len(m.Relays) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Relays[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintQuery(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryAnchorRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryAnchorRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryAnchorRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Root) > 0 {
+		i -= len(m.Root)
+		copy(dAtA[i:], m.Root)
+		i = encodeVarintQuery(dAtA, i, uint64(len(m.Root)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryAnchorResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryAnchorResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryAnchorResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	{
+		size, err := m.Anchor.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintQuery(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryMailboxQuotaRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryMailboxQuotaRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryMailboxQuotaRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Mailbox) > 0 {
+		i -= len(m.Mailbox)
+		copy(dAtA[i:], m.Mailbox)
+		i = encodeVarintQuery(dAtA, i, uint64(len(m.Mailbox)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryMailboxQuotaResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryMailboxQuotaResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryMailboxQuotaResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.LimitBytes != 0 {
+		i = encodeVarintQuery(dAtA, i, uint64(m.LimitBytes))
+		i--
+		dAtA[i] = 0x10
+	}
+	{
+		size, err := m.Quota.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintQuery(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintQuery(dAtA []byte, offset int, v uint64) int {
+	offset -= sovQuery(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *QueryParamsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *QueryParamsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = m.Params.Size()
+	n += 1 + l + sovQuery(uint64(l))
+	return n
+}
+
+func (m *QueryRelayRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Hostname)
+	if l > 0 {
+		n += 1 + l + sovQuery(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryRelayResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = m.Relay.Size()
+	n += 1 + l + sovQuery(uint64(l))
+	return n
+}
+
+func (m *QueryRelaysRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Pagination != nil {
+		l = m.Pagination.Size()
+		n += 1 + l + sovQuery(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryRelaysResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Relays) > 0 {
+		for _, e := range m.Relays {
+			l = e.Size()
+			n += 1 + l + sovQuery(uint64(l))
+		}
+	}
+	if m.Pagination != nil {
+		l = m.Pagination.Size()
+		n += 1 + l + sovQuery(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryRelaysByDomainRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Domain)
+	if l > 0 {
+		n += 1 + l + sovQuery(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryRelaysByDomainResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Relays) > 0 {
+		for _, e := range m.Relays {
+			l = e.Size()
+			n += 1 + l + sovQuery(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *QueryAnchorRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Root)
+	if l > 0 {
+		n += 1 + l + sovQuery(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryAnchorResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = m.Anchor.Size()
+	n += 1 + l + sovQuery(uint64(l))
+	return n
+}
+
+func (m *QueryMailboxQuotaRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Mailbox)
+	if l > 0 {
+		n += 1 + l + sovQuery(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryMailboxQuotaResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = m.Quota.Size()
+	n += 1 + l + sovQuery(uint64(l))
+	if m.LimitBytes != 0 {
+		n += 1 + sovQuery(uint64(m.LimitBytes))
+	}
+	return n
+}
+
+func sovQuery(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozQuery(x uint64) (n int) {
+	return sovQuery(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *QueryParamsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryParamsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryParamsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryParamsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryParamsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryParamsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Params", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Params.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryRelayRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryRelayRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryRelayRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hostname = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryRelayResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryRelayResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryRelayResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Relay", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Relay.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryRelaysRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryRelaysRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryRelaysRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Pagination", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Pagination == nil {
+				m.Pagination = &query.PageRequest{}
+			}
+			if err := m.Pagination.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryRelaysResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryRelaysResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryRelaysResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Relays", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Relays = append(m.Relays, MailRelay{})
+			if err := m.Relays[len(m.Relays)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Pagination", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Pagination == nil {
+				m.Pagination = &query.PageResponse{}
+			}
+			if err := m.Pagination.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryRelaysByDomainRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryRelaysByDomainRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryRelaysByDomainRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Domain", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Domain = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryRelaysByDomainResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryRelaysByDomainResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryRelaysByDomainResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Relays", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Relays = append(m.Relays, MailRelay{})
+			if err := m.Relays[len(m.Relays)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
 	}
-	return dAtA[:n], nil
-}
 
-func (m *QueryParamsResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
 }
-
-func (m *QueryParamsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	{
-		size, err := m.Params.MarshalToSizedBuffer(dAtA[:i])
-		if err != nil {
-			return 0, err
+func (m *QueryAnchorRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryAnchorRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryAnchorRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Root", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Root = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
 		}
-		i -= size
-		i = encodeVarintQuery(dAtA, i, uint64(size))
 	}
-	i--
-	dAtA[i] = 0xa
-	return len(dAtA) - i, nil
-}
 
-func encodeVarintQuery(dAtA []byte, offset int, v uint64) int {
-	offset -= sovQuery(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return nil
 }
-func (m *QueryParamsRequest) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *QueryAnchorResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowQuery
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryAnchorResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryAnchorResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Anchor", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Anchor.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipQuery(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
 	}
-	var l int
-	_ = l
-	return n
-}
 
-func (m *QueryParamsResponse) Size() (n int) {
-	if m == nil {
-		return 0
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	var l int
-	_ = l
-	l = m.Params.Size()
-	n += 1 + l + sovQuery(uint64(l))
-	return n
-}
-
-func sovQuery(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozQuery(x uint64) (n int) {
-	return sovQuery(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *QueryParamsRequest) Unmarshal(dAtA []byte) error {
+func (m *QueryMailboxQuotaRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -345,12 +2363,44 @@ func (m *QueryParamsRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: QueryParamsRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: QueryMailboxQuotaRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: QueryParamsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: QueryMailboxQuotaRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mailbox", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthQuery
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthQuery
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Mailbox = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipQuery(dAtA[iNdEx:])
@@ -372,7 +2422,7 @@ func (m *QueryParamsRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *QueryParamsResponse) Unmarshal(dAtA []byte) error {
+func (m *QueryMailboxQuotaResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -395,15 +2445,15 @@ func (m *QueryParamsResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: QueryParamsResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: QueryMailboxQuotaResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: QueryParamsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: QueryMailboxQuotaResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Params", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Quota", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -430,10 +2480,29 @@ func (m *QueryParamsResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := m.Params.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.Quota.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LimitBytes", wireType)
+			}
+			m.LimitBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowQuery
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LimitBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipQuery(dAtA[iNdEx:])
@@ -126,37 +126,509 @@ func (m *MsgUpdateParamsResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_MsgUpdateParamsResponse proto.InternalMessageInfo
 
+// MsgRegisterRelay is the Msg/RegisterRelay request type.
+type MsgRegisterRelay struct {
+	// operator is the account that owns the relay entry.
+	Operator string `protobuf:"bytes,1,opt,name=operator,proto3" json:"operator,omitempty"`
+	// hostname is the DNS name of the relay.
+	Hostname string `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// tls_fingerprint is the hex-encoded SHA-256 hash of the relay's TLS
+	// SubjectPublicKeyInfo.
+	TlsFingerprint string `protobuf:"bytes,3,opt,name=tls_fingerprint,json=tlsFingerprint,proto3" json:"tls_fingerprint,omitempty"`
+	// domains lists the mail domains the relay accepts messages for.
+	Domains []string `protobuf:"bytes,4,rep,name=domains,proto3" json:"domains,omitempty"`
+}
+
+func (m *MsgRegisterRelay) Reset()         { *m = MsgRegisterRelay{} }
+func (m *MsgRegisterRelay) String() string { return proto.CompactTextString(m) }
+func (*MsgRegisterRelay) ProtoMessage()    {}
+func (*MsgRegisterRelay) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{2}
+}
+func (m *MsgRegisterRelay) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgRegisterRelay) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgRegisterRelay.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgRegisterRelay) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgRegisterRelay.Merge(m, src)
+}
+func (m *MsgRegisterRelay) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgRegisterRelay) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgRegisterRelay.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgRegisterRelay proto.InternalMessageInfo
+
+func (m *MsgRegisterRelay) GetOperator() string {
+	if m != nil {
+		return m.Operator
+	}
+	return ""
+}
+
+func (m *MsgRegisterRelay) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
+}
+
+func (m *MsgRegisterRelay) GetTlsFingerprint() string {
+	if m != nil {
+		return m.TlsFingerprint
+	}
+	return ""
+}
+
+func (m *MsgRegisterRelay) GetDomains() []string {
+	if m != nil {
+		return m.Domains
+	}
+	return nil
+}
+
+// MsgRegisterRelayResponse defines the response structure for executing a
+// MsgRegisterRelay message.
+type MsgRegisterRelayResponse struct {
+}
+
+func (m *MsgRegisterRelayResponse) Reset()         { *m = MsgRegisterRelayResponse{} }
+func (m *MsgRegisterRelayResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgRegisterRelayResponse) ProtoMessage()    {}
+func (*MsgRegisterRelayResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{3}
+}
+func (m *MsgRegisterRelayResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgRegisterRelayResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgRegisterRelayResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgRegisterRelayResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgRegisterRelayResponse.Merge(m, src)
+}
+func (m *MsgRegisterRelayResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgRegisterRelayResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgRegisterRelayResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgRegisterRelayResponse proto.InternalMessageInfo
+
+// MsgRemoveRelay is the Msg/RemoveRelay request type.
+type MsgRemoveRelay struct {
+	// operator is the account that owns the relay entry.
+	Operator string `protobuf:"bytes,1,opt,name=operator,proto3" json:"operator,omitempty"`
+	// hostname is the DNS name of the relay to remove.
+	Hostname string `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+}
+
+func (m *MsgRemoveRelay) Reset()         { *m = MsgRemoveRelay{} }
+func (m *MsgRemoveRelay) String() string { return proto.CompactTextString(m) }
+func (*MsgRemoveRelay) ProtoMessage()    {}
+func (*MsgRemoveRelay) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{4}
+}
+func (m *MsgRemoveRelay) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgRemoveRelay) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgRemoveRelay.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgRemoveRelay) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgRemoveRelay.Merge(m, src)
+}
+func (m *MsgRemoveRelay) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgRemoveRelay) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgRemoveRelay.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgRemoveRelay proto.InternalMessageInfo
+
+func (m *MsgRemoveRelay) GetOperator() string {
+	if m != nil {
+		return m.Operator
+	}
+	return ""
+}
+
+func (m *MsgRemoveRelay) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
+}
+
+// MsgRemoveRelayResponse defines the response structure for executing a
+// MsgRemoveRelay message.
+type MsgRemoveRelayResponse struct {
+}
+
+func (m *MsgRemoveRelayResponse) Reset()         { *m = MsgRemoveRelayResponse{} }
+func (m *MsgRemoveRelayResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgRemoveRelayResponse) ProtoMessage()    {}
+func (*MsgRemoveRelayResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{5}
+}
+func (m *MsgRemoveRelayResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgRemoveRelayResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgRemoveRelayResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgRemoveRelayResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgRemoveRelayResponse.Merge(m, src)
+}
+func (m *MsgRemoveRelayResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgRemoveRelayResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgRemoveRelayResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgRemoveRelayResponse proto.InternalMessageInfo
+
+// MsgAnchorRoot is the Msg/AnchorRoot request type.
+type MsgAnchorRoot struct {
+	// submitter is the account that anchors the root.
+	Submitter string `protobuf:"bytes,1,opt,name=submitter,proto3" json:"submitter,omitempty"`
+	// root is the hex-encoded SHA-256 Merkle root.
+	Root string `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
+	// leaf_count is the number of message hashes covered by the root.
+	LeafCount uint64 `protobuf:"varint,3,opt,name=leaf_count,json=leafCount,proto3" json:"leaf_count,omitempty"`
+}
+
+func (m *MsgAnchorRoot) Reset()         { *m = MsgAnchorRoot{} }
+func (m *MsgAnchorRoot) String() string { return proto.CompactTextString(m) }
+func (*MsgAnchorRoot) ProtoMessage()    {}
+func (*MsgAnchorRoot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{6}
+}
+func (m *MsgAnchorRoot) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgAnchorRoot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgAnchorRoot.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgAnchorRoot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgAnchorRoot.Merge(m, src)
+}
+func (m *MsgAnchorRoot) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgAnchorRoot) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgAnchorRoot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgAnchorRoot proto.InternalMessageInfo
+
+func (m *MsgAnchorRoot) GetSubmitter() string {
+	if m != nil {
+		return m.Submitter
+	}
+	return ""
+}
+
+func (m *MsgAnchorRoot) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
+}
+
+func (m *MsgAnchorRoot) GetLeafCount() uint64 {
+	if m != nil {
+		return m.LeafCount
+	}
+	return 0
+}
+
+// MsgAnchorRootResponse defines the response structure for executing a
+// MsgAnchorRoot message.
+type MsgAnchorRootResponse struct {
+	// block_height is the height of the block that included the anchor.
+	BlockHeight int64 `protobuf:"varint,1,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+	// timestamp is the block time (Unix seconds) of the anchor.
+	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (m *MsgAnchorRootResponse) Reset()         { *m = MsgAnchorRootResponse{} }
+func (m *MsgAnchorRootResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgAnchorRootResponse) ProtoMessage()    {}
+func (*MsgAnchorRootResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{7}
+}
+func (m *MsgAnchorRootResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgAnchorRootResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgAnchorRootResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgAnchorRootResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgAnchorRootResponse.Merge(m, src)
+}
+func (m *MsgAnchorRootResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgAnchorRootResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgAnchorRootResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgAnchorRootResponse proto.InternalMessageInfo
+
+func (m *MsgAnchorRootResponse) GetBlockHeight() int64 {
+	if m != nil {
+		return m.BlockHeight
+	}
+	return 0
+}
+
+func (m *MsgAnchorRootResponse) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+// MsgPurchaseQuota is the Msg/PurchaseQuota request type.
+type MsgPurchaseQuota struct {
+	// buyer is the account that pays for the quota.
+	Buyer string `protobuf:"bytes,1,opt,name=buyer,proto3" json:"buyer,omitempty"`
+	// mailbox is the e-mail address of the mailbox the quota is purchased
+	// for.
+	Mailbox string `protobuf:"bytes,2,opt,name=mailbox,proto3" json:"mailbox,omitempty"`
+	// units is the number of quota units to purchase.
+	Units uint64 `protobuf:"varint,3,opt,name=units,proto3" json:"units,omitempty"`
+}
+
+func (m *MsgPurchaseQuota) Reset()         { *m = MsgPurchaseQuota{} }
+func (m *MsgPurchaseQuota) String() string { return proto.CompactTextString(m) }
+func (*MsgPurchaseQuota) ProtoMessage()    {}
+func (*MsgPurchaseQuota) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{8}
+}
+func (m *MsgPurchaseQuota) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgPurchaseQuota) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgPurchaseQuota.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgPurchaseQuota) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgPurchaseQuota.Merge(m, src)
+}
+func (m *MsgPurchaseQuota) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgPurchaseQuota) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgPurchaseQuota.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgPurchaseQuota proto.InternalMessageInfo
+
+func (m *MsgPurchaseQuota) GetBuyer() string {
+	if m != nil {
+		return m.Buyer
+	}
+	return ""
+}
+
+func (m *MsgPurchaseQuota) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+func (m *MsgPurchaseQuota) GetUnits() uint64 {
+	if m != nil {
+		return m.Units
+	}
+	return 0
+}
+
+// MsgPurchaseQuotaResponse defines the response structure for executing a
+// MsgPurchaseQuota message.
+type MsgPurchaseQuotaResponse struct {
+	// limit_bytes is the effective storage quota of the mailbox after the
+	// purchase.
+	LimitBytes uint64 `protobuf:"varint,1,opt,name=limit_bytes,json=limitBytes,proto3" json:"limit_bytes,omitempty"`
+}
+
+func (m *MsgPurchaseQuotaResponse) Reset()         { *m = MsgPurchaseQuotaResponse{} }
+func (m *MsgPurchaseQuotaResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgPurchaseQuotaResponse) ProtoMessage()    {}
+func (*MsgPurchaseQuotaResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cd484027f73a074b, []int{9}
+}
+func (m *MsgPurchaseQuotaResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgPurchaseQuotaResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgPurchaseQuotaResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgPurchaseQuotaResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgPurchaseQuotaResponse.Merge(m, src)
+}
+func (m *MsgPurchaseQuotaResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgPurchaseQuotaResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgPurchaseQuotaResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgPurchaseQuotaResponse proto.InternalMessageInfo
+
+func (m *MsgPurchaseQuotaResponse) GetLimitBytes() uint64 {
+	if m != nil {
+		return m.LimitBytes
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*MsgUpdateParams)(nil), "mailchat.mailchat.v1.MsgUpdateParams")
 	proto.RegisterType((*MsgUpdateParamsResponse)(nil), "mailchat.mailchat.v1.MsgUpdateParamsResponse")
+	proto.RegisterType((*MsgRegisterRelay)(nil), "mailchat.mailchat.v1.MsgRegisterRelay")
+	proto.RegisterType((*MsgRegisterRelayResponse)(nil), "mailchat.mailchat.v1.MsgRegisterRelayResponse")
+	proto.RegisterType((*MsgRemoveRelay)(nil), "mailchat.mailchat.v1.MsgRemoveRelay")
+	proto.RegisterType((*MsgRemoveRelayResponse)(nil), "mailchat.mailchat.v1.MsgRemoveRelayResponse")
+	proto.RegisterType((*MsgAnchorRoot)(nil), "mailchat.mailchat.v1.MsgAnchorRoot")
+	proto.RegisterType((*MsgAnchorRootResponse)(nil), "mailchat.mailchat.v1.MsgAnchorRootResponse")
+	proto.RegisterType((*MsgPurchaseQuota)(nil), "mailchat.mailchat.v1.MsgPurchaseQuota")
+	proto.RegisterType((*MsgPurchaseQuotaResponse)(nil), "mailchat.mailchat.v1.MsgPurchaseQuotaResponse")
 }
 
 func init() { proto.RegisterFile("mailchat/mailchat/v1/tx.proto", fileDescriptor_cd484027f73a074b) }
 
 var fileDescriptor_cd484027f73a074b = []byte{
-	// 350 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0xcd, 0x4d, 0xcc, 0xcc,
-	0x49, 0xce, 0x48, 0x2c, 0xd1, 0x87, 0x33, 0xca, 0x0c, 0xf5, 0x4b, 0x2a, 0xf4, 0x0a, 0x8a, 0xf2,
-	0x4b, 0xf2, 0x85, 0x44, 0x60, 0xa2, 0x7a, 0x70, 0x46, 0x99, 0xa1, 0x94, 0x60, 0x62, 0x6e, 0x66,
-	0x5e, 0xbe, 0x3e, 0x98, 0x84, 0x28, 0x94, 0x12, 0x4f, 0xce, 0x2f, 0xce, 0xcd, 0x2f, 0xd6, 0xcf,
-	0x2d, 0x4e, 0x07, 0x19, 0x90, 0x5b, 0x9c, 0x0e, 0x95, 0x90, 0x84, 0x48, 0xc4, 0x83, 0x79, 0xfa,
-	0x10, 0x0e, 0x54, 0x4a, 0x24, 0x3d, 0x3f, 0x3d, 0x1f, 0x22, 0x0e, 0x62, 0x41, 0x45, 0x15, 0xb1,
-	0xba, 0xa8, 0x20, 0xb1, 0x28, 0x31, 0x17, 0xaa, 0x51, 0xe9, 0x10, 0x23, 0x17, 0xbf, 0x6f, 0x71,
-	0x7a, 0x68, 0x41, 0x4a, 0x62, 0x49, 0x6a, 0x00, 0x58, 0x46, 0xc8, 0x8c, 0x8b, 0x33, 0xb1, 0xb4,
-	0x24, 0x23, 0xbf, 0x28, 0xb3, 0xa4, 0x52, 0x82, 0x51, 0x81, 0x51, 0x83, 0xd3, 0x49, 0xe2, 0xd2,
-	0x16, 0x5d, 0x11, 0xa8, 0x8d, 0x8e, 0x29, 0x29, 0x45, 0xa9, 0xc5, 0xc5, 0xc1, 0x25, 0x45, 0x99,
-	0x79, 0xe9, 0x41, 0x08, 0xa5, 0x42, 0xf6, 0x5c, 0x6c, 0x10, 0xb3, 0x25, 0x98, 0x14, 0x18, 0x35,
-	0xb8, 0x8d, 0x64, 0xf4, 0xb0, 0x79, 0x59, 0x0f, 0x62, 0x8b, 0x13, 0xe7, 0x89, 0x7b, 0xf2, 0x0c,
-	0x2b, 0x9e, 0x6f, 0xd0, 0x62, 0x0c, 0x82, 0x6a, 0xb3, 0x32, 0x6b, 0x7a, 0xbe, 0x41, 0x0b, 0x61,
-	0x60, 0xd7, 0xf3, 0x0d, 0x5a, 0xca, 0x70, 0x97, 0x57, 0x20, 0x3c, 0x81, 0xe6, 0x60, 0x25, 0x49,
-	0x2e, 0x71, 0x34, 0xa1, 0xa0, 0xd4, 0xe2, 0x82, 0xfc, 0xbc, 0xe2, 0x54, 0xa3, 0x22, 0x2e, 0x66,
-	0xdf, 0xe2, 0x74, 0xa1, 0x14, 0x2e, 0x1e, 0x14, 0x2f, 0xaa, 0x62, 0x77, 0x1a, 0x9a, 0x29, 0x52,
-	0xba, 0x44, 0x29, 0x83, 0x59, 0x26, 0xc5, 0xda, 0x00, 0xf2, 0x8e, 0x93, 0xe7, 0x89, 0x47, 0x72,
-	0x8c, 0x17, 0x1e, 0xc9, 0x31, 0x3e, 0x78, 0x24, 0xc7, 0x38, 0xe1, 0xb1, 0x1c, 0xc3, 0x85, 0xc7,
-	0x72, 0x0c, 0x37, 0x1e, 0xcb, 0x31, 0x44, 0xe9, 0xa7, 0x67, 0x96, 0x64, 0x94, 0x26, 0xe9, 0x25,
-	0xe7, 0xe7, 0xea, 0xa7, 0x14, 0xe7, 0xa7, 0x95, 0xa4, 0x27, 0xe6, 0xa6, 0x16, 0xeb, 0xfb, 0x26,
-	0x66, 0xe6, 0x38, 0xa3, 0xf9, 0xb1, 0xa4, 0xb2, 0x20, 0xb5, 0x38, 0x89, 0x0d, 0x1c, 0x4b, 0xc6,
-	0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0x87, 0x17, 0x86, 0x69, 0x5c, 0x02, 0x00, 0x00,
+	// 756 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0xcf, 0x4f, 0xdb, 0x48,
+	0x14, 0x8e, 0x37, 0x81, 0x25, 0x2f, 0xfc, 0xd8, 0xb5, 0xb2, 0x8b, 0xb1, 0x20, 0x80, 0x61, 0x77,
+	0x11, 0x5b, 0x6c, 0xf1, 0x43, 0x1c, 0xe8, 0xa1, 0x22, 0x48, 0x55, 0x7b, 0x88, 0x44, 0x5d, 0x55,
+	0xaa, 0x7a, 0x68, 0x34, 0x49, 0x06, 0x7b, 0x54, 0x8f, 0xc7, 0xf2, 0x8c, 0x11, 0xb9, 0x55, 0xbd,
+	0x54, 0xea, 0xa9, 0xc7, 0xfe, 0x07, 0xed, 0x31, 0x87, 0xfe, 0x05, 0x3d, 0x71, 0x44, 0x3d, 0xf5,
+	0xd4, 0x56, 0x70, 0xe0, 0xdf, 0xa8, 0xfc, 0x23, 0x36, 0x4e, 0x09, 0xe4, 0xd2, 0x4b, 0x34, 0xef,
+	0x9b, 0xef, 0xcd, 0xfb, 0xbe, 0x97, 0x37, 0x63, 0x58, 0xa0, 0x88, 0x38, 0x6d, 0x1b, 0x09, 0x23,
+	0x5d, 0x1c, 0x6f, 0x1a, 0xe2, 0x44, 0xf7, 0x7c, 0x26, 0x98, 0x5c, 0xed, 0xa3, 0x7a, 0xba, 0x38,
+	0xde, 0x54, 0xff, 0x44, 0x94, 0xb8, 0xcc, 0x88, 0x7e, 0x63, 0xa2, 0x3a, 0xdb, 0x66, 0x9c, 0x32,
+	0x6e, 0x50, 0x6e, 0x85, 0x07, 0x50, 0x6e, 0x25, 0x1b, 0x73, 0xf1, 0x46, 0x33, 0x8a, 0x8c, 0x38,
+	0x48, 0xb6, 0xaa, 0x16, 0xb3, 0x58, 0x8c, 0x87, 0xab, 0x04, 0x5d, 0xbe, 0x56, 0x91, 0x87, 0x7c,
+	0x44, 0x93, 0x44, 0xed, 0x93, 0x04, 0x33, 0x0d, 0x6e, 0x3d, 0xf1, 0x3a, 0x48, 0xe0, 0xc3, 0x68,
+	0x47, 0xde, 0x85, 0x32, 0x0a, 0x84, 0xcd, 0x7c, 0x22, 0xba, 0x8a, 0xb4, 0x24, 0xad, 0x95, 0xeb,
+	0xca, 0xe7, 0x8f, 0x1b, 0xd5, 0xa4, 0xe2, 0x7e, 0xa7, 0xe3, 0x63, 0xce, 0x1f, 0x0b, 0x9f, 0xb8,
+	0x96, 0x99, 0x51, 0xe5, 0x7b, 0x30, 0x1e, 0x9f, 0xad, 0xfc, 0xb6, 0x24, 0xad, 0x55, 0xb6, 0xe6,
+	0xf5, 0xeb, 0x2c, 0xeb, 0x71, 0x95, 0x7a, 0xf9, 0xf4, 0xeb, 0x62, 0xe1, 0xc3, 0x65, 0x6f, 0x5d,
+	0x32, 0x93, 0xb4, 0xbd, 0xdd, 0x57, 0x97, 0xbd, 0xf5, 0xec, 0xc0, 0x37, 0x97, 0xbd, 0xf5, 0x95,
+	0x54, 0xf9, 0x49, 0x66, 0x62, 0x40, 0xb0, 0x36, 0x07, 0xb3, 0x03, 0x90, 0x89, 0xb9, 0xc7, 0x5c,
+	0x8e, 0xb5, 0x6f, 0x12, 0xfc, 0xd1, 0xe0, 0x96, 0x89, 0x2d, 0xc2, 0x05, 0xf6, 0x4d, 0xec, 0xa0,
+	0xae, 0xbc, 0x03, 0x13, 0xcc, 0xc3, 0x3e, 0x12, 0xcc, 0xbf, 0xd5, 0x5f, 0xca, 0x94, 0x55, 0x98,
+	0xb0, 0x19, 0x17, 0x2e, 0xa2, 0x38, 0x32, 0x58, 0x36, 0xd3, 0x58, 0xfe, 0x0f, 0x66, 0x84, 0xc3,
+	0x9b, 0x47, 0xc4, 0xb5, 0xb0, 0xef, 0xf9, 0xc4, 0x15, 0x4a, 0x31, 0xa2, 0x4c, 0x0b, 0x87, 0xdf,
+	0xcf, 0x50, 0x59, 0x81, 0xdf, 0x3b, 0x8c, 0x22, 0xe2, 0x72, 0xa5, 0xb4, 0x54, 0x5c, 0x2b, 0x9b,
+	0xfd, 0x30, 0x36, 0x9f, 0x56, 0x0b, 0xbd, 0xaf, 0x0e, 0xf1, 0x9e, 0x33, 0xa3, 0xa9, 0xa0, 0x0c,
+	0x62, 0xa9, 0xfb, 0x77, 0x12, 0x4c, 0x47, 0x9b, 0x94, 0x1d, 0xe3, 0x5f, 0xe4, 0x7d, 0x6f, 0xe7,
+	0x27, 0xe1, 0xda, 0x50, 0xe1, 0xa9, 0x0e, 0x4d, 0x81, 0xbf, 0xf3, 0x48, 0x2a, 0xba, 0x27, 0xc1,
+	0x54, 0x83, 0x5b, 0xfb, 0x6e, 0xdb, 0x66, 0xbe, 0xc9, 0x98, 0x08, 0x07, 0x92, 0x07, 0x2d, 0x4a,
+	0x84, 0xc0, 0xb7, 0x8b, 0xce, 0xa8, 0xb2, 0x0c, 0x25, 0x9f, 0x31, 0x91, 0x28, 0x8e, 0xd6, 0xf2,
+	0x02, 0x80, 0x83, 0xd1, 0x51, 0xb3, 0xcd, 0x82, 0xe4, 0x4f, 0x2a, 0x99, 0xe5, 0x10, 0x39, 0x08,
+	0x81, 0xd8, 0x4c, 0x76, 0x44, 0xe8, 0x66, 0x79, 0x88, 0x9b, 0x4c, 0xa0, 0xf6, 0x14, 0xfe, 0xca,
+	0x01, 0x7d, 0x2f, 0xf2, 0x32, 0x4c, 0xb6, 0x1c, 0xd6, 0x7e, 0xd1, 0xb4, 0x31, 0xb1, 0x6c, 0x11,
+	0x89, 0x2f, 0x9a, 0x95, 0x08, 0x7b, 0x10, 0x41, 0xf2, 0x3c, 0x94, 0x05, 0xa1, 0x98, 0x0b, 0x44,
+	0xbd, 0x48, 0x69, 0xd1, 0xcc, 0x00, 0xed, 0x7d, 0x3c, 0xbf, 0x87, 0x81, 0xdf, 0xb6, 0x11, 0xc7,
+	0x8f, 0x02, 0x26, 0x90, 0xac, 0xc3, 0x58, 0x2b, 0xe8, 0x8e, 0xd0, 0x8b, 0x98, 0x16, 0x0e, 0x5d,
+	0x28, 0xbc, 0xc5, 0x4e, 0x92, 0x56, 0xf4, 0x43, 0xb9, 0x0a, 0x63, 0x81, 0x4b, 0x04, 0x4f, 0x1a,
+	0x11, 0x07, 0x7b, 0xdb, 0x61, 0x13, 0xe2, 0xdc, 0x9b, 0xe6, 0x30, 0x27, 0x4a, 0xbb, 0x0b, 0xca,
+	0x20, 0x96, 0xb6, 0x61, 0x11, 0x2a, 0x0e, 0xa1, 0x44, 0x34, 0x5b, 0x5d, 0x81, 0x79, 0x24, 0xbb,
+	0x64, 0x42, 0x04, 0xd5, 0x43, 0x64, 0xeb, 0x75, 0x09, 0x8a, 0x0d, 0x6e, 0xc9, 0x1d, 0x98, 0xcc,
+	0x3d, 0x45, 0xff, 0x5c, 0xff, 0x84, 0x0c, 0xdc, 0x76, 0x75, 0x63, 0x24, 0x5a, 0x2a, 0xc7, 0x82,
+	0xa9, 0xfc, 0x83, 0xf0, 0xef, 0xd0, 0xfc, 0x1c, 0x4f, 0xd5, 0x47, 0xe3, 0xa5, 0x85, 0x10, 0x54,
+	0xae, 0xde, 0xbd, 0xd5, 0x1b, 0xd2, 0x53, 0x96, 0x7a, 0x67, 0x14, 0x56, 0x5a, 0xe2, 0x39, 0xc0,
+	0x95, 0x9b, 0xb2, 0x32, 0x34, 0x37, 0x23, 0xa9, 0xff, 0x8f, 0x40, 0xba, 0xda, 0xab, 0xfc, 0xf0,
+	0x0d, 0xef, 0x55, 0x8e, 0xa7, 0xea, 0xa3, 0xf1, 0xfa, 0x85, 0xd4, 0xb1, 0x97, 0xe1, 0xb7, 0xa0,
+	0xfe, 0xf0, 0xf4, 0xbc, 0x26, 0x9d, 0x9d, 0xd7, 0xa4, 0xef, 0xe7, 0x35, 0xe9, 0xed, 0x45, 0xad,
+	0x70, 0x76, 0x51, 0x2b, 0x7c, 0xb9, 0xa8, 0x15, 0x9e, 0x19, 0x16, 0x11, 0x76, 0xd0, 0xd2, 0xdb,
+	0x8c, 0x1a, 0x1d, 0xce, 0x8e, 0x84, 0x85, 0x28, 0xe6, 0x46, 0x03, 0x11, 0xe7, 0x60, 0x60, 0x38,
+	0x45, 0xd7, 0xc3, 0xbc, 0x35, 0x1e, 0x7d, 0xe2, 0xb6, 0x7f, 0x0c, 0x00, 0x83, 0x6b, 0xde, 0x12,
+	0x99, 0x07, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -174,6 +646,16 @@ type MsgClient interface {
 	// UpdateParams defines a (governance) operation for updating the module
 	// parameters. The authority defaults to the x/gov module account.
 	UpdateParams(ctx context.Context, in *MsgUpdateParams, opts ...grpc.CallOption) (*MsgUpdateParamsResponse, error)
+	// RegisterRelay registers a mail relay or updates an existing one owned by
+	// the same operator.
+	RegisterRelay(ctx context.Context, in *MsgRegisterRelay, opts ...grpc.CallOption) (*MsgRegisterRelayResponse, error)
+	// RemoveRelay removes a mail relay owned by the operator.
+	RemoveRelay(ctx context.Context, in *MsgRemoveRelay, opts ...grpc.CallOption) (*MsgRemoveRelayResponse, error)
+	// AnchorRoot records the Merkle root of a batch of message hashes.
+	AnchorRoot(ctx context.Context, in *MsgAnchorRoot, opts ...grpc.CallOption) (*MsgAnchorRootResponse, error)
+	// PurchaseQuota buys mailbox storage quota for the price set in the
+	// module parameters.
+	PurchaseQuota(ctx context.Context, in *MsgPurchaseQuota, opts ...grpc.CallOption) (*MsgPurchaseQuotaResponse, error)
 }
 
 type msgClient struct {
@@ -193,20 +675,78 @@ func (c *msgClient) UpdateParams(ctx context.Context, in *MsgUpdateParams, opts
 	return out, nil
 }
 
-// MsgServer is the server API for Msg service.
-type MsgServer interface {
-	// UpdateParams defines a (governance) operation for updating the module
-	// parameters. The authority defaults to the x/gov module account.
-	UpdateParams(context.Context, *MsgUpdateParams) (*MsgUpdateParamsResponse, error)
+func (c *msgClient) RegisterRelay(ctx context.Context, in *MsgRegisterRelay, opts ...grpc.CallOption) (*MsgRegisterRelayResponse, error) {
+	out := new(MsgRegisterRelayResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Msg/RegisterRelay", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// UnimplementedMsgServer can be embedded to have forward compatible implementations.
-type UnimplementedMsgServer struct {
+func (c *msgClient) RemoveRelay(ctx context.Context, in *MsgRemoveRelay, opts ...grpc.CallOption) (*MsgRemoveRelayResponse, error) {
+	out := new(MsgRemoveRelayResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Msg/RemoveRelay", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (*UnimplementedMsgServer) UpdateParams(ctx context.Context, req *MsgUpdateParams) (*MsgUpdateParamsResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method UpdateParams not implemented")
-}
+func (c *msgClient) AnchorRoot(ctx context.Context, in *MsgAnchorRoot, opts ...grpc.CallOption) (*MsgAnchorRootResponse, error) {
+	out := new(MsgAnchorRootResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Msg/AnchorRoot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) PurchaseQuota(ctx context.Context, in *MsgPurchaseQuota, opts ...grpc.CallOption) (*MsgPurchaseQuotaResponse, error) {
+	out := new(MsgPurchaseQuotaResponse)
+	err := c.cc.Invoke(ctx, "/mailchat.mailchat.v1.Msg/PurchaseQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MsgServer is the server API for Msg service.
+type MsgServer interface {
+	// UpdateParams defines a (governance) operation for updating the module
+	// parameters. The authority defaults to the x/gov module account.
+	UpdateParams(context.Context, *MsgUpdateParams) (*MsgUpdateParamsResponse, error)
+	// RegisterRelay registers a mail relay or updates an existing one owned by
+	// the same operator.
+	RegisterRelay(context.Context, *MsgRegisterRelay) (*MsgRegisterRelayResponse, error)
+	// RemoveRelay removes a mail relay owned by the operator.
+	RemoveRelay(context.Context, *MsgRemoveRelay) (*MsgRemoveRelayResponse, error)
+	// AnchorRoot records the Merkle root of a batch of message hashes.
+	AnchorRoot(context.Context, *MsgAnchorRoot) (*MsgAnchorRootResponse, error)
+	// PurchaseQuota buys mailbox storage quota for the price set in the
+	// module parameters.
+	PurchaseQuota(context.Context, *MsgPurchaseQuota) (*MsgPurchaseQuotaResponse, error)
+}
+
+// UnimplementedMsgServer can be embedded to have forward compatible implementations.
+type UnimplementedMsgServer struct {
+}
+
+func (*UnimplementedMsgServer) UpdateParams(ctx context.Context, req *MsgUpdateParams) (*MsgUpdateParamsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateParams not implemented")
+}
+func (*UnimplementedMsgServer) RegisterRelay(ctx context.Context, req *MsgRegisterRelay) (*MsgRegisterRelayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterRelay not implemented")
+}
+func (*UnimplementedMsgServer) RemoveRelay(ctx context.Context, req *MsgRemoveRelay) (*MsgRemoveRelayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveRelay not implemented")
+}
+func (*UnimplementedMsgServer) AnchorRoot(ctx context.Context, req *MsgAnchorRoot) (*MsgAnchorRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnchorRoot not implemented")
+}
+func (*UnimplementedMsgServer) PurchaseQuota(ctx context.Context, req *MsgPurchaseQuota) (*MsgPurchaseQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurchaseQuota not implemented")
+}
 
 func RegisterMsgServer(s grpc1.Server, srv MsgServer) {
 	s.RegisterService(&_Msg_serviceDesc, srv)
@@ -230,6 +770,78 @@ func _Msg_UpdateParams_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Msg_RegisterRelay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgRegisterRelay)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).RegisterRelay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Msg/RegisterRelay",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).RegisterRelay(ctx, req.(*MsgRegisterRelay))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_RemoveRelay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgRemoveRelay)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).RemoveRelay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Msg/RemoveRelay",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).RemoveRelay(ctx, req.(*MsgRemoveRelay))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_AnchorRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgAnchorRoot)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).AnchorRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Msg/AnchorRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).AnchorRoot(ctx, req.(*MsgAnchorRoot))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_PurchaseQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgPurchaseQuota)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).PurchaseQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mailchat.mailchat.v1.Msg/PurchaseQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).PurchaseQuota(ctx, req.(*MsgPurchaseQuota))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var Msg_serviceDesc = _Msg_serviceDesc
 var _Msg_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "mailchat.mailchat.v1.Msg",
@@ -239,6 +851,22 @@ var _Msg_serviceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateParams",
 			Handler:    _Msg_UpdateParams_Handler,
 		},
+		{
+			MethodName: "RegisterRelay",
+			Handler:    _Msg_RegisterRelay_Handler,
+		},
+		{
+			MethodName: "RemoveRelay",
+			Handler:    _Msg_RemoveRelay_Handler,
+		},
+		{
+			MethodName: "AnchorRoot",
+			Handler:    _Msg_AnchorRoot_Handler,
+		},
+		{
+			MethodName: "PurchaseQuota",
+			Handler:    _Msg_PurchaseQuota_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "mailchat/mailchat/v1/tx.proto",
@@ -269,86 +897,1274 @@ func (m *MsgUpdateParams) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
-		i -= size
-		i = encodeVarintTx(dAtA, i, uint64(size))
-	}
-	i--
-	dAtA[i] = 0x12
-	if len(m.Authority) > 0 {
-		i -= len(m.Authority)
-		copy(dAtA[i:], m.Authority)
-		i = encodeVarintTx(dAtA, i, uint64(len(m.Authority)))
-		i--
-		dAtA[i] = 0xa
-	}
-	return len(dAtA) - i, nil
-}
-
-func (m *MsgUpdateParamsResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+		i -= size
+		i = encodeVarintTx(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x12
+	if len(m.Authority) > 0 {
+		i -= len(m.Authority)
+		copy(dAtA[i:], m.Authority)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Authority)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgUpdateParamsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgUpdateParamsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgUpdateParamsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgRegisterRelay) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgRegisterRelay) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgRegisterRelay) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Domains) > 0 {
+		for iNdEx := len(m.Domains) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Domains[iNdEx])
+			copy(dAtA[i:], m.Domains[iNdEx])
+			i = encodeVarintTx(dAtA, i, uint64(len(m.Domains[iNdEx])))
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if len(m.TlsFingerprint) > 0 {
+		i -= len(m.TlsFingerprint)
+		copy(dAtA[i:], m.TlsFingerprint)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.TlsFingerprint)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Hostname) > 0 {
+		i -= len(m.Hostname)
+		copy(dAtA[i:], m.Hostname)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Hostname)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Operator) > 0 {
+		i -= len(m.Operator)
+		copy(dAtA[i:], m.Operator)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Operator)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgRegisterRelayResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgRegisterRelayResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgRegisterRelayResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgRemoveRelay) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgRemoveRelay) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgRemoveRelay) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Hostname) > 0 {
+		i -= len(m.Hostname)
+		copy(dAtA[i:], m.Hostname)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Hostname)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Operator) > 0 {
+		i -= len(m.Operator)
+		copy(dAtA[i:], m.Operator)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Operator)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgRemoveRelayResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgRemoveRelayResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgRemoveRelayResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgAnchorRoot) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgAnchorRoot) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgAnchorRoot) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.LeafCount != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.LeafCount))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Root) > 0 {
+		i -= len(m.Root)
+		copy(dAtA[i:], m.Root)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Root)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Submitter) > 0 {
+		i -= len(m.Submitter)
+		copy(dAtA[i:], m.Submitter)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Submitter)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgAnchorRootResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgAnchorRootResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgAnchorRootResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Timestamp != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.Timestamp))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.BlockHeight != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.BlockHeight))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgPurchaseQuota) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgPurchaseQuota) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgPurchaseQuota) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Units != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.Units))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Mailbox) > 0 {
+		i -= len(m.Mailbox)
+		copy(dAtA[i:], m.Mailbox)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Mailbox)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Buyer) > 0 {
+		i -= len(m.Buyer)
+		copy(dAtA[i:], m.Buyer)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Buyer)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgPurchaseQuotaResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgPurchaseQuotaResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgPurchaseQuotaResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.LimitBytes != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.LimitBytes))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintTx(dAtA []byte, offset int, v uint64) int {
+	offset -= sovTx(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *MsgUpdateParams) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Authority)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = m.Params.Size()
+	n += 1 + l + sovTx(uint64(l))
+	return n
+}
+
+func (m *MsgUpdateParamsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *MsgRegisterRelay) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Operator)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.Hostname)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.TlsFingerprint)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	if len(m.Domains) > 0 {
+		for _, s := range m.Domains {
+			l = len(s)
+			n += 1 + l + sovTx(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *MsgRegisterRelayResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *MsgRemoveRelay) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Operator)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.Hostname)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	return n
+}
+
+func (m *MsgRemoveRelayResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *MsgAnchorRoot) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Submitter)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.Root)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	if m.LeafCount != 0 {
+		n += 1 + sovTx(uint64(m.LeafCount))
+	}
+	return n
+}
+
+func (m *MsgAnchorRootResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.BlockHeight != 0 {
+		n += 1 + sovTx(uint64(m.BlockHeight))
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sovTx(uint64(m.Timestamp))
+	}
+	return n
+}
+
+func (m *MsgPurchaseQuota) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Buyer)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.Mailbox)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	if m.Units != 0 {
+		n += 1 + sovTx(uint64(m.Units))
+	}
+	return n
+}
+
+func (m *MsgPurchaseQuotaResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.LimitBytes != 0 {
+		n += 1 + sovTx(uint64(m.LimitBytes))
+	}
+	return n
+}
+
+func sovTx(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozTx(x uint64) (n int) {
+	return sovTx(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *MsgUpdateParams) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgUpdateParams: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgUpdateParams: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Authority", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Authority = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Params", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Params.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgUpdateParamsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgUpdateParamsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgUpdateParamsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgRegisterRelay) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgRegisterRelay: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgRegisterRelay: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Operator", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Operator = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hostname = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TlsFingerprint", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TlsFingerprint = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Domains", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Domains = append(m.Domains, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgRegisterRelayResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgRegisterRelayResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgRegisterRelayResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgRemoveRelay) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgRemoveRelay: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgRemoveRelay: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Operator", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Operator = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hostname = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgRemoveRelayResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgRemoveRelayResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgRemoveRelayResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgAnchorRoot) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgAnchorRoot: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgAnchorRoot: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Submitter", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Submitter = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Root", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Root = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeafCount", wireType)
+			}
+			m.LeafCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LeafCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
 	}
-	return dAtA[:n], nil
-}
-
-func (m *MsgUpdateParamsResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
-}
-
-func (m *MsgUpdateParamsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	return len(dAtA) - i, nil
-}
 
-func encodeVarintTx(dAtA []byte, offset int, v uint64) int {
-	offset -= sovTx(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return nil
 }
-func (m *MsgUpdateParams) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.Authority)
-	if l > 0 {
-		n += 1 + l + sovTx(uint64(l))
+func (m *MsgAnchorRootResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgAnchorRootResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgAnchorRootResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockHeight", wireType)
+			}
+			m.BlockHeight = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BlockHeight |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			m.Timestamp = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Timestamp |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
 	}
-	l = m.Params.Size()
-	n += 1 + l + sovTx(uint64(l))
-	return n
-}
 
-func (m *MsgUpdateParamsResponse) Size() (n int) {
-	if m == nil {
-		return 0
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	var l int
-	_ = l
-	return n
-}
-
-func sovTx(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozTx(x uint64) (n int) {
-	return sovTx(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *MsgUpdateParams) Unmarshal(dAtA []byte) error {
+func (m *MsgPurchaseQuota) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -371,15 +2187,15 @@ func (m *MsgUpdateParams) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgUpdateParams: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgPurchaseQuota: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgUpdateParams: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgPurchaseQuota: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Authority", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Buyer", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -407,13 +2223,13 @@ func (m *MsgUpdateParams) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Authority = string(dAtA[iNdEx:postIndex])
+			m.Buyer = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Params", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Mailbox", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTx
@@ -423,25 +2239,43 @@ func (m *MsgUpdateParams) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTx
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTx
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := m.Params.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Mailbox = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Units", wireType)
+			}
+			m.Units = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Units |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTx(dAtA[iNdEx:])
@@ -463,7 +2297,7 @@ func (m *MsgUpdateParams) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MsgUpdateParamsResponse) Unmarshal(dAtA []byte) error {
+func (m *MsgPurchaseQuotaResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -486,12 +2320,31 @@ func (m *MsgUpdateParamsResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgUpdateParamsResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgPurchaseQuotaResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgUpdateParamsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgPurchaseQuotaResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LimitBytes", wireType)
+			}
+			m.LimitBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LimitBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTx(dAtA[iNdEx:])